@@ -2,10 +2,10 @@ package main
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
+	"net/mail"
 	"os"
 	"os/signal"
 	"strconv"
@@ -16,13 +16,14 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/joho/godotenv"
-	_ "github.com/mattn/go-sqlite3"
-	"golang.org/x/time/rate"
 
 	"chainforge/internal/auth"
+	"chainforge/internal/calendar"
 	"chainforge/internal/config"
 	"chainforge/internal/database"
+	"chainforge/internal/email"
 	"chainforge/internal/handlers"
+	"chainforge/internal/ratelimit"
 	"chainforge/internal/services"
 )
 
@@ -39,7 +40,7 @@ func main() {
 	}
 
 	// Initialize database
-	db, err := database.New(cfg.Database.URL, cfg.Database.EncryptionKey)
+	db, err := openDatabase(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
@@ -50,28 +51,78 @@ func main() {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	// Encrypt PII columns from here on
+	if err := db.EnableFieldEncryption(context.Background(), cfg.Database.FieldEncryptionKey); err != nil {
+		log.Fatalf("Failed to enable field encryption: %v", err)
+	}
+
 	// Initialize authentication
+	keyRing, err := newKeyRing(db, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize signing keys: %v", err)
+	}
+	if err := keyRing.Sync(context.Background()); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
 	tokenManager := auth.NewTokenManager(
-		cfg.Auth.JWTSecret,
-		cfg.Auth.RefreshSecret,
+		keyRing,
 		cfg.Auth.AccessTokenTTL,
 		cfg.Auth.RefreshTokenTTL,
 		cfg.Auth.Issuer,
+		auth.WithLegacySecrets(cfg.Auth.JWTSecret, cfg.Auth.RefreshSecret),
 	)
-	tokenBlacklist := auth.NewTokenBlacklist()
+	tokenRevocations := database.NewTokenRevocationRepository(db, cfg.Auth.RefreshTokenTTL)
+	relyingParty := auth.NewRelyingParty(cfg.Auth.WebAuthnRPID, cfg.Auth.WebAuthnRPName, cfg.Auth.WebAuthnOrigins)
+	accountEmails, err := newAccountEmails(cfg, db)
+	if err != nil {
+		log.Fatalf("Failed to set up email: %v", err)
+	}
+
+	passwordHasher, err := auth.NewPasswordHasher(auth.PasswordHashConfig{
+		Algorithm:         cfg.Auth.PasswordHash,
+		BcryptCost:        cfg.Auth.PasswordBcryptCost,
+		Argon2Memory:      uint32(cfg.Auth.PasswordArgon2Memory),
+		Argon2Iterations:  uint32(cfg.Auth.PasswordArgon2Iterations),
+		Argon2Parallelism: uint8(cfg.Auth.PasswordArgon2Parallelism),
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up password hashing: %v", err)
+	}
+
+	weekStart, err := calendar.ParseWeekday(cfg.Server.WeekStart)
+	if err != nil {
+		log.Fatalf("Invalid week start: %v", err)
+	}
 
 	// Initialize services
-	userService := services.NewUserService(db, tokenManager)
-	goalService := services.NewGoalService(db)
-	groupService := services.NewGroupService(db)
-	subscriptionService := services.NewSubscriptionService(db, cfg.Stripe.SecretKey)
+	userService := services.NewUserService(db, tokenManager, tokenRevocations, passwordHasher, relyingParty, newOIDCProviders(cfg), accountEmails, services.LoginThrottleConfig{
+		FreeAttempts:   cfg.Auth.LoginBackoffAfter,
+		IPFreeAttempts: cfg.Auth.LoginIPBackoffAfter,
+		MaxBackoff:     cfg.Auth.LoginMaxBackoff,
+		LockAfter:      cfg.Auth.LoginLockAfter,
+		MFALockAfter:   cfg.Auth.MFALockAfter,
+		LockDuration:   cfg.Auth.LoginLockDuration,
+		Window:         cfg.Auth.LoginFailureWindow,
+	}, services.PasswordPolicyConfig{
+		History:     cfg.Auth.PasswordHistory,
+		AdminMaxAge: time.Duration(cfg.Auth.PasswordAdminMaxAgeDays) * 24 * time.Hour,
+	}, weekStart)
+	goalService := services.NewGoalService(db, weekStart)
+	groupService := services.NewGroupService(db, weekStart)
+	subscriptionService := services.NewSubscriptionService(db, cfg.Stripe)
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(userService, tokenManager, tokenBlacklist)
-	userHandler := handlers.NewUserHandler(userService)
-	goalHandler := handlers.NewGoalHandler(goalService, subscriptionService)
-	groupHandler := handlers.NewGroupHandler(groupService, subscriptionService)
+	authHandler := handlers.NewAuthHandler(userService)
+	userHandler := handlers.NewUserHandler(userService, cfg.Storage)
+	mfaHandler := handlers.NewMFAHandler(userService)
+	webAuthnHandler := handlers.NewWebAuthnHandler(userService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(userService)
+	oidcHandler := handlers.NewOIDCHandler(userService)
+	goalHandler := handlers.NewGoalHandler(goalService)
+	groupHandler := handlers.NewGroupHandler(groupService)
 	subscriptionHandler := handlers.NewSubscriptionHandler(subscriptionService)
+	keysHandler := handlers.NewKeysHandler(keyRing)
+	rateLimiter := ratelimit.NewLimiter(newRateLimitStore(cfg, db))
 
 	// Create router
 	r := chi.NewRouter()
@@ -88,23 +139,11 @@ func main() {
 		AllowedOrigins:   cfg.Server.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
-	// Rate limiting
-	limiter := rate.NewLimiter(rate.Limit(cfg.Server.RateLimit), cfg.Server.RateBurst)
-	r.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if !limiter.Allow() {
-				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
-				return
-			}
-			next.ServeHTTP(w, r)
-		})
-	})
-
 	// Security headers
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -117,115 +156,24 @@ func main() {
 		})
 	})
 
-	// Auth middleware
-	authMiddleware := handlers.NewAuthMiddleware(tokenManager, tokenBlacklist)
-
-	// Public routes
-	r.Route("/api/v1", func(r chi.Router) {
-		// Health check
-		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`{"status":"healthy","timestamp":"` + time.Now().UTC().Format(time.RFC3339) + `"}`))
-		})
-
-		// Authentication routes
-		r.Route("/auth", func(r chi.Router) {
-			r.Post("/register", authHandler.Register)
-			r.Post("/login", authHandler.Login)
-			r.Post("/refresh", authHandler.RefreshToken)
-			r.Post("/logout", authHandler.Logout)
-			r.Post("/forgot-password", authHandler.ForgotPassword)
-			r.Post("/reset-password", authHandler.ResetPassword)
-			r.Post("/validate-password", authHandler.ValidatePassword)
-		})
-
-		// Stripe webhooks (public)
-		r.Post("/webhooks/stripe", subscriptionHandler.HandleStripeWebhook)
-
-		// Protected routes
-		r.Group(func(r chi.Router) {
-			r.Use(authMiddleware.RequireAuth)
-
-			// User routes
-			r.Route("/users", func(r chi.Router) {
-				r.Get("/me", userHandler.GetCurrentUser)
-				r.Put("/me", userHandler.UpdateCurrentUser)
-				r.Delete("/me", userHandler.DeleteCurrentUser)
-				r.Get("/me/stats", userHandler.GetUserStats)
-				r.Post("/me/avatar", userHandler.UploadAvatar)
-				r.Post("/me/change-password", userHandler.ChangePassword)
-			})
-
-			// Goal routes
-			r.Route("/goals", func(r chi.Router) {
-				r.Get("/", goalHandler.GetGoals)
-				r.Post("/", goalHandler.CreateGoal)
-				r.Get("/{goalID}", goalHandler.GetGoal)
-				r.Put("/{goalID}", goalHandler.UpdateGoal)
-				r.Delete("/{goalID}", goalHandler.DeleteGoal)
-				r.Post("/{goalID}/progress", goalHandler.AddProgress)
-				r.Get("/{goalID}/progress", goalHandler.GetProgress)
-				r.Get("/{goalID}/analytics", goalHandler.GetAnalytics)
-			})
-
-			// Group routes
-			r.Route("/groups", func(r chi.Router) {
-				r.Get("/", groupHandler.GetGroups)
-				r.Post("/", groupHandler.CreateGroup)
-				r.Post("/join", groupHandler.JoinGroup)
-				r.Get("/{groupID}", groupHandler.GetGroup)
-				r.Put("/{groupID}", groupHandler.UpdateGroup)
-				r.Delete("/{groupID}", groupHandler.DeleteGroup)
-				r.Post("/{groupID}/leave", groupHandler.LeaveGroup)
-				r.Get("/{groupID}/members", groupHandler.GetMembers)
-				r.Put("/{groupID}/members/{userID}", groupHandler.UpdateMember)
-				r.Delete("/{groupID}/members/{userID}", groupHandler.RemoveMember)
-
-				// Group goals
-				r.Route("/{groupID}/goals", func(r chi.Router) {
-					r.Get("/", groupHandler.GetGroupGoals)
-					r.Post("/", groupHandler.CreateGroupGoal)
-					r.Get("/{goalID}", groupHandler.GetGroupGoal)
-					r.Put("/{goalID}", groupHandler.UpdateGroupGoal)
-					r.Delete("/{goalID}", groupHandler.DeleteGroupGoal)
-					r.Post("/{goalID}/target", groupHandler.SetTarget)
-					r.Post("/{goalID}/progress", groupHandler.AddGroupProgress)
-					r.Get("/{goalID}/leaderboard", groupHandler.GetLeaderboard)
-				})
-			})
-
-			// Subscription routes
-			r.Route("/subscription", func(r chi.Router) {
-				r.Get("/", subscriptionHandler.GetSubscription)
-				r.Post("/", subscriptionHandler.CreateSubscription)
-				r.Put("/", subscriptionHandler.UpdateSubscription)
-				r.Delete("/", subscriptionHandler.CancelSubscription)
-				r.Get("/features", subscriptionHandler.GetFeatures)
-				r.Get("/usage", subscriptionHandler.GetUsage)
-				r.Post("/billing-portal", subscriptionHandler.CreateBillingPortal)
-
-				// Payment methods
-				r.Route("/payment-methods", func(r chi.Router) {
-					r.Get("/", subscriptionHandler.GetPaymentMethods)
-					r.Post("/", subscriptionHandler.AddPaymentMethod)
-					r.Delete("/{paymentMethodID}", subscriptionHandler.DeletePaymentMethod)
-					r.Put("/{paymentMethodID}/default", subscriptionHandler.SetDefaultPaymentMethod)
-				})
-
-				// Invoices
-				r.Get("/invoices", subscriptionHandler.GetInvoices)
-			})
-
-			// Analytics routes (premium feature)
-			r.Route("/analytics", func(r chi.Router) {
-				r.Use(authMiddleware.RequirePremium)
-				r.Get("/overview", userHandler.GetAnalyticsOverview)
-				r.Get("/goals", goalHandler.GetGoalsAnalytics)
-				r.Get("/groups", groupHandler.GetGroupsAnalytics)
-			})
-		})
-	})
+	// Public keys for verifying access tokens
+	r.Get("/.well-known/jwks.json", keysHandler.JWKS)
+
+	// API routes
+	r.Mount("/api/v1", apiHandlers{
+		authMiddleware: handlers.NewAuthMiddleware(tokenManager, tokenRevocations, subscriptionService, userService),
+		auth:           authHandler,
+		users:          userHandler,
+		mfa:            mfaHandler,
+		webauthn:       webAuthnHandler,
+		apiKeys:        apiKeyHandler,
+		oidc:           oidcHandler,
+		goals:          goalHandler,
+		groups:         groupHandler,
+		subscriptions:  subscriptionHandler,
+		rateLimiter:    handlers.NewRateLimiter(rateLimiter),
+		limits:         newRateLimits(cfg),
+	}.routes())
 
 	// Serve static files (for uploaded avatars, etc.)
 	fileServer := http.FileServer(http.Dir("./static/"))
@@ -259,10 +207,6 @@ func main() {
 		for {
 			select {
 			case <-ticker.C:
-				// Clean up expired tokens from blacklist
-				tokenBlacklist.Cleanup()
-				log.Printf("Token blacklist cleanup completed. Active tokens: %d", tokenBlacklist.GetBlacklistedCount())
-
 				// Clean up expired group goal periods and create new ones
 				if err := groupService.ProcessPeriodTransitions(context.Background()); err != nil {
 					log.Printf("Error processing period transitions: %v", err)
@@ -276,6 +220,72 @@ func main() {
 		}
 	}()
 
+	// Writes already clear expired token revocations a batch at a time;
+	// this catches up while the server is quiet and drops expired sessions,
+	// stale failed sign-in counters and ended rate limit windows
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if removed, err := tokenRevocations.Cleanup(context.Background(), 1000); err != nil {
+				log.Printf("Error cleaning up token revocations: %v", err)
+			} else if removed > 0 {
+				log.Printf("Removed %d expired token revocations", removed)
+			}
+
+			if removed, err := userService.CleanupSessions(context.Background(), 1000); err != nil {
+				log.Printf("Error cleaning up sessions: %v", err)
+			} else if removed > 0 {
+				log.Printf("Removed %d expired sessions", removed)
+			}
+
+			if removed, err := userService.CleanupLoginThrottles(context.Background(), 1000); err != nil {
+				log.Printf("Error cleaning up login throttles: %v", err)
+			} else if removed > 0 {
+				log.Printf("Removed %d stale login throttles", removed)
+			}
+
+			if removed, err := rateLimiter.Cleanup(context.Background(), 1000); err != nil {
+				log.Printf("Error cleaning up rate limits: %v", err)
+			} else if removed > 0 {
+				log.Printf("Removed %d expired rate limit counters", removed)
+			}
+		}
+	}()
+
+	// Pick up keys created by other instances and rotate when due
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := keyRing.Sync(context.Background()); err != nil {
+				log.Printf("Error syncing signing keys: %v", err)
+			}
+		}
+	}()
+
+	// Deliver queued emails, including any left over from before a restart
+	go accountEmails.Mailer.Run(context.Background(), 30*time.Second)
+
+	// Move encrypted fields to the active data key in small batches
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			count, err := db.ReencryptFields(context.Background(), 500)
+			if err != nil {
+				log.Printf("Error re-encrypting fields: %v", err)
+				continue
+			}
+			if count > 0 {
+				log.Printf("Re-encrypted %d rows with the active field key", count)
+			}
+		}
+	}()
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -300,11 +310,23 @@ func init() {
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
 		case "migrate":
-			runMigrations()
+			runMigrations(os.Args[2:])
 			os.Exit(0)
 		case "seed":
 			seedDatabase()
 			os.Exit(0)
+		case "rekey":
+			rekeyDatabase()
+			os.Exit(0)
+		case "rotate-field-key":
+			rotateFieldKey()
+			os.Exit(0)
+		case "rotate-signing-key":
+			rotateSigningKey()
+			os.Exit(0)
+		case "audit-log":
+			printAuditLog(os.Args[2:])
+			os.Exit(0)
 		case "generate-secret":
 			generateSecret()
 			os.Exit(0)
@@ -312,23 +334,169 @@ func init() {
 	}
 }
 
-func runMigrations() {
+// openDatabase opens the configured database with its pool settings
+func openDatabase(cfg *config.Config) (*database.DB, error) {
+	return database.New(
+		cfg.Database.URL,
+		cfg.Database.EncryptionKey,
+		database.WithPool(cfg.Database.MaxOpenConns, cfg.Database.MaxIdleConns, cfg.Database.ConnMaxLife),
+	)
+}
+
+// newKeyRing creates the JWT signing key ring over the database
+func newKeyRing(db *database.DB, cfg *config.Config) (*auth.KeyRing, error) {
+	return auth.NewKeyRing(database.NewSigningKeyRepository(db), auth.KeyRingConfig{
+		Algorithm:        cfg.Auth.SigningAlgorithm,
+		RotationInterval: cfg.Auth.KeyRotationInterval,
+		Overlap:          cfg.Auth.KeyRotationOverlap,
+		TokenTTL:         max(cfg.Auth.AccessTokenTTL, cfg.Auth.RefreshTokenTTL),
+	})
+}
+
+// newOIDCProviders creates a client for every configured OpenID Connect
+// provider. Discovery happens on first use, so startup does not depend on
+// the providers being reachable.
+func newOIDCProviders(cfg *config.Config) []*auth.OIDCProvider {
+	providers := make([]*auth.OIDCProvider, len(cfg.Auth.OIDCProviders))
+	for i, p := range cfg.Auth.OIDCProviders {
+		providers[i] = auth.NewOIDCProvider(auth.OIDCProviderConfig{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  cfg.Auth.OIDCRedirectURL,
+			Scopes:       p.Scopes,
+		}, nil)
+	}
+	return providers
+}
+
+// newRateLimitStore creates the store the rate limiter counts requests in
+func newRateLimitStore(cfg *config.Config, db *database.DB) ratelimit.Store {
+	if cfg.Server.RateLimitStore == "memory" {
+		return ratelimit.NewMemoryStore(cfg.Server.RateLimitSize)
+	}
+	return database.NewRateLimitRepository(db)
+}
+
+// newRateLimits builds the per-minute rate limit policies of the API
+func newRateLimits(cfg *config.Config) rateLimits {
+	return rateLimits{
+		api:       ratelimit.Policy{Name: "api", Limit: cfg.Server.RateLimit, Window: time.Minute},
+		auth:      ratelimit.Policy{Name: "auth", Limit: cfg.Server.AuthRateLimit, Window: time.Minute},
+		analytics: ratelimit.Policy{Name: "analytics", Limit: cfg.Server.AnalyticsRateLimit, Window: time.Minute},
+		webhooks:  ratelimit.Policy{Name: "webhooks", Limit: cfg.Server.WebhookRateLimit, Window: time.Minute},
+	}
+}
+
+// newAccountEmails sets up the email provider and outbox the user service
+// mails verification and password reset links through
+func newAccountEmails(cfg *config.Config, db *database.DB) (services.AccountEmailConfig, error) {
+	sender, err := email.NewSender(email.Config{
+		Provider: cfg.Email.Provider,
+		From:     mail.Address{Name: cfg.Email.FromName, Address: cfg.Email.FromEmail},
+		ReplyTo:  cfg.Email.ReplyToEmail,
+		SMTP: email.SMTPConfig{
+			Host:     cfg.Email.SMTPHost,
+			Port:     cfg.Email.SMTPPort,
+			Username: cfg.Email.SMTPUsername,
+			Password: cfg.Email.SMTPPassword,
+		},
+		FileDir: cfg.Email.FileDir,
+	})
+	if err != nil {
+		return services.AccountEmailConfig{}, err
+	}
+	templates, err := email.LoadTemplates()
+	if err != nil {
+		return services.AccountEmailConfig{}, err
+	}
+	return services.AccountEmailConfig{
+		Mailer:           services.NewMailer(db, sender, templates),
+		AppURL:           cfg.Email.AppURL,
+		VerifyEmailTTL:   cfg.Auth.VerifyEmailTTL,
+		PasswordResetTTL: cfg.Auth.PasswordResetTTL,
+	}, nil
+}
+
+// runMigrations handles `migrate`, `migrate status`, `migrate up [N]` and `migrate down [N]`
+func runMigrations(args []string) {
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	db, err := database.New(cfg.Database.URL, cfg.Database.EncryptionKey)
+	db, err := openDatabase(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer db.Close()
 
-	if err := database.RunMigrations(db); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
 	}
 
-	log.Println("✅ Database migrations completed successfully")
+	ctx := context.Background()
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	count := 0
+	if len(args) > 1 {
+		count, err = strconv.Atoi(args[1])
+		if err != nil || count < 1 {
+			log.Fatalf("Invalid migration count %q: must be a positive integer", args[1])
+		}
+	}
+
+	switch command {
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		applied := 0
+		for _, s := range statuses {
+			if s.Applied {
+				applied++
+			}
+			state := "pending"
+			switch {
+			case s.Missing:
+				state = "MISSING FILE"
+			case s.Modified:
+				state = "MODIFIED"
+			case s.Applied:
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%03d  %-40s %s\n", s.Version, s.Name, state)
+		}
+		if applied == 0 {
+			fmt.Println("No migrations applied")
+		}
+	case "up":
+		applied, err := migrator.Up(ctx, count)
+		for _, m := range applied {
+			log.Printf("⬆️  Applied %03d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+		log.Printf("✅ Database migrations completed successfully (%d applied)", len(applied))
+	case "down":
+		reverted, err := migrator.Down(ctx, count)
+		for _, m := range reverted {
+			log.Printf("⬇️  Reverted %03d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Failed to revert migrations: %v", err)
+		}
+		log.Printf("✅ Database rollback completed successfully (%d reverted)", len(reverted))
+	default:
+		log.Fatalf("Unknown migrate command %q (expected status, up [N] or down [N])", command)
+	}
 }
 
 func seedDatabase() {
@@ -337,12 +505,16 @@ func seedDatabase() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	db, err := database.New(cfg.Database.URL, cfg.Database.EncryptionKey)
+	db, err := openDatabase(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer db.Close()
 
+	if err := db.EnableFieldEncryption(context.Background(), cfg.Database.FieldEncryptionKey); err != nil {
+		log.Fatalf("Failed to enable field encryption (run migrations first): %v", err)
+	}
+
 	if err := database.SeedDatabase(db); err != nil {
 		log.Fatalf("Failed to seed database: %v", err)
 	}
@@ -350,6 +522,134 @@ func seedDatabase() {
 	log.Println("✅ Database seeded successfully")
 }
 
+// rekeyDatabase re-encrypts the database with NEW_DB_ENCRYPTION_KEY.
+// The key is read from the environment so it never lands in shell history.
+// The current DB_ENCRYPTION_KEY may be shorter than the minimum so that
+// databases created before it was enforced can move to a longer key.
+func rekeyDatabase() {
+	cfg, err := config.LoadForRekey()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	newKey := os.Getenv("NEW_DB_ENCRYPTION_KEY")
+	if err := config.ValidateEncryptionKey(newKey); err != nil {
+		log.Fatalf("Invalid NEW_DB_ENCRYPTION_KEY: %v", err)
+	}
+
+	if err := database.Rekey(cfg.Database.URL, cfg.Database.EncryptionKey, newKey); err != nil {
+		log.Fatalf("Failed to rekey database: %v", err)
+	}
+
+	log.Println("✅ Database re-encrypted successfully")
+	log.Println("Set DB_ENCRYPTION_KEY to the value of NEW_DB_ENCRYPTION_KEY before restarting the server")
+}
+
+// rotateFieldKey adds a new field encryption data key. Running servers pick
+// it up on their next re-encryption pass and migrate rows to it.
+func rotateFieldKey() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	version, err := db.RotateFieldKey(context.Background(), cfg.Database.FieldEncryptionKey)
+	if err != nil {
+		log.Fatalf("Failed to rotate field key: %v", err)
+	}
+
+	log.Printf("✅ Field encryption key v%d is now active", version)
+}
+
+// rotateSigningKey replaces the JWT signing key immediately, for example
+// after a suspected leak. Tokens signed with older keys stay valid until
+// they expire; running servers pick the new key up within a minute.
+func rotateSigningKey() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.EnableFieldEncryption(context.Background(), cfg.Database.FieldEncryptionKey); err != nil {
+		log.Fatalf("Failed to enable field encryption (run migrations first): %v", err)
+	}
+
+	keyRing, err := newKeyRing(db, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize signing keys: %v", err)
+	}
+	kid, err := keyRing.Rotate(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to rotate signing key: %v", err)
+	}
+
+	log.Printf("✅ Signing key %s is now active", kid)
+}
+
+// printAuditLog prints the newest audit entries for the account with the
+// given email: `audit-log <email> [limit]`
+func printAuditLog(args []string) {
+	if len(args) == 0 {
+		log.Fatal("Usage: audit-log <email> [limit]")
+	}
+	limit := 50
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			log.Fatalf("Invalid limit %q: must be a positive integer", args[1])
+		}
+		limit = n
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if err := db.EnableFieldEncryption(ctx, cfg.Database.FieldEncryptionKey); err != nil {
+		log.Fatalf("Failed to enable field encryption (run migrations first): %v", err)
+	}
+
+	user, err := db.Users().GetByEmail(ctx, args[0])
+	if err != nil {
+		log.Fatalf("Failed to find user %s: %v", args[0], err)
+	}
+	entries, err := db.Audit().ListByUser(ctx, user.ID, limit)
+	if err != nil {
+		log.Fatalf("Failed to read audit log: %v", err)
+	}
+
+	for _, e := range entries {
+		ip, values := "-", ""
+		if e.IPAddress != nil {
+			ip = *e.IPAddress
+		}
+		if e.NewValues != nil {
+			values = *e.NewValues
+		}
+		fmt.Printf("%s  %-30s %-15s %s\n", e.CreatedAt.Format(time.RFC3339), e.Action, ip, values)
+	}
+}
+
 func generateSecret() {
 	secret, err := auth.GenerateSecretKey(32)
 	if err != nil {
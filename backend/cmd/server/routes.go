@@ -0,0 +1,232 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"chainforge/internal/handlers"
+	"chainforge/internal/models"
+	"chainforge/internal/ratelimit"
+)
+
+// apiHandlers holds everything mounted under /api/v1
+type apiHandlers struct {
+	authMiddleware *handlers.AuthMiddleware
+	auth           *handlers.AuthHandler
+	users          *handlers.UserHandler
+	mfa            *handlers.MFAHandler
+	webauthn       *handlers.WebAuthnHandler
+	apiKeys        *handlers.APIKeyHandler
+	oidc           *handlers.OIDCHandler
+	goals          *handlers.GoalHandler
+	groups         *handlers.GroupHandler
+	subscriptions  *handlers.SubscriptionHandler
+	rateLimiter    *handlers.RateLimiter
+	limits         rateLimits
+}
+
+// rateLimits are the rate limit policies of the API routes
+type rateLimits struct {
+	api       ratelimit.Policy // Every signed-in route, per user
+	auth      ratelimit.Policy // Sign-in and account recovery, per IP
+	analytics ratelimit.Policy
+	webhooks  ratelimit.Policy
+}
+
+// routes builds the /api/v1 router
+func (h apiHandlers) routes() chi.Router {
+	r := chi.NewRouter()
+
+	// Health check
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"healthy","timestamp":"` + time.Now().UTC().Format(time.RFC3339) + `"}`))
+	})
+
+	// Authentication routes
+	r.Route("/auth", func(r chi.Router) {
+		r.Use(h.rateLimiter.Limit(h.limits.auth))
+		r.Post("/register", h.auth.Register)
+		r.Post("/login", h.auth.Login)
+		r.Post("/login/mfa", h.auth.LoginMFA)
+		r.Post("/refresh", h.auth.RefreshToken)
+		r.Post("/logout", h.auth.Logout)
+		r.Post("/forgot-password", h.auth.ForgotPassword)
+		r.Post("/reset-password", h.auth.ResetPassword)
+		r.Post("/verify-email", h.auth.VerifyEmail)
+		r.Post("/unlock", h.auth.UnlockAccount)
+		r.Post("/validate-password", h.auth.ValidatePassword)
+
+		// Passkeys
+		r.Route("/webauthn", func(r chi.Router) {
+			r.Post("/login/begin", h.webauthn.BeginLogin)
+			r.Post("/login/finish", h.webauthn.FinishLogin)
+
+			r.Group(func(r chi.Router) {
+				r.Use(h.authMiddleware.RequireAuth)
+				r.Post("/register/begin", h.webauthn.BeginRegistration)
+				r.Post("/register/finish", h.webauthn.FinishRegistration)
+				r.Get("/credentials", h.webauthn.ListCredentials)
+				r.Put("/credentials/{credentialID}", h.webauthn.RenameCredential)
+				r.Delete("/credentials/{credentialID}", h.webauthn.DeleteCredential)
+			})
+		})
+
+		// OpenID Connect providers
+		r.Route("/oidc", func(r chi.Router) {
+			r.Get("/providers", h.oidc.Providers)
+			r.Post("/{provider}/authorize", h.oidc.Authorize)
+			r.Post("/{provider}/callback", h.oidc.Callback)
+
+			r.Group(func(r chi.Router) {
+				r.Use(h.authMiddleware.RequireAuth)
+				r.Post("/{provider}/link/authorize", h.oidc.AuthorizeLink)
+				r.Post("/{provider}/link", h.oidc.Link)
+			})
+		})
+	})
+
+	// Stripe webhooks (public)
+	r.With(h.rateLimiter.Limit(h.limits.webhooks)).Post("/webhooks/stripe", h.subscriptions.HandleStripeWebhook)
+
+	// Routes for the signed-in user only
+	r.Group(func(r chi.Router) {
+		r.Use(h.authMiddleware.RequireAuth)
+		r.Use(h.rateLimiter.Limit(h.limits.api))
+
+		// User routes
+		r.Route("/users", func(r chi.Router) {
+			r.Get("/me", h.users.GetCurrentUser)
+			r.Put("/me", h.users.UpdateCurrentUser)
+			r.Delete("/me", h.users.DeleteCurrentUser)
+			r.Get("/me/stats", h.users.GetUserStats)
+			r.Post("/me/avatar", h.users.UploadAvatar)
+			r.Post("/me/change-password", h.users.ChangePassword)
+			r.Post("/me/verification-email", h.users.SendVerificationEmail)
+			r.Get("/me/sessions", h.users.GetSessions)
+			r.Delete("/me/sessions", h.users.DeleteOtherSessions)
+			r.Delete("/me/sessions/{sessionID}", h.users.DeleteSession)
+			r.Get("/me/identities", h.oidc.ListIdentities)
+			r.Delete("/me/identities/{identityID}", h.oidc.Unlink)
+			r.Get("/me/mfa", h.mfa.GetStatus)
+			r.Post("/me/mfa/totp", h.mfa.BeginTOTP)
+			r.Post("/me/mfa/totp/confirm", h.mfa.ConfirmTOTP)
+			r.Post("/me/mfa/recovery-codes", h.mfa.RegenerateRecoveryCodes)
+			r.Post("/me/mfa/disable", h.mfa.Disable)
+			r.Get("/me/api-keys", h.apiKeys.ListKeys)
+			r.Post("/me/api-keys", h.apiKeys.CreateKey)
+			r.Delete("/me/api-keys/{keyID}", h.apiKeys.RevokeKey)
+		})
+
+		// Subscription routes
+		r.Route("/subscription", func(r chi.Router) {
+			r.Get("/", h.subscriptions.GetSubscription)
+			r.Post("/", h.subscriptions.CreateSubscription)
+			r.Put("/", h.subscriptions.UpdateSubscription)
+			r.Delete("/", h.subscriptions.CancelSubscription)
+			r.Post("/cancel", h.subscriptions.CancelSubscription)
+			r.Post("/resume", h.subscriptions.ResumeSubscription)
+			r.Get("/features", h.subscriptions.GetFeatures)
+			r.Get("/usage", h.subscriptions.GetUsage)
+			r.Post("/billing-portal", h.subscriptions.CreateBillingPortal)
+			r.Route("/payment-methods", h.paymentMethodRoutes)
+			r.Get("/invoices", h.subscriptions.GetInvoices)
+		})
+
+		// Billing routes the frontend calls outside /subscription
+		r.Post("/billing-portal", h.subscriptions.CreateBillingPortal)
+		r.Post("/create-payment-intent", h.subscriptions.CreatePaymentIntent)
+		r.Post("/payment-success", h.subscriptions.PaymentSuccess)
+		r.Route("/payment-methods", h.paymentMethodRoutes)
+		r.Route("/invoices", func(r chi.Router) {
+			r.Get("/", h.subscriptions.GetInvoices)
+			r.Get("/{invoiceID}", h.subscriptions.GetInvoice)
+			r.Post("/{invoiceID}/retry", h.subscriptions.RetryInvoice)
+		})
+	})
+
+	// Routes also open to personal API keys with the matching scopes
+	r.Group(func(r chi.Router) {
+		r.Use(h.authMiddleware.RequireAuthOrAPIKey)
+		r.Use(h.rateLimiter.Limit(h.limits.api))
+
+		// Goal routes
+		r.Route("/goals", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(h.authMiddleware.RequireScope(models.ScopeGoalsRead, models.ScopeGoalsWrite))
+				r.Get("/", h.goals.GetGoals)
+				r.Post("/", h.goals.CreateGoal)
+				r.Get("/with-progress", h.goals.GetGoalsWithProgress)
+				r.Get("/{goalID}", h.goals.GetGoal)
+				r.Put("/{goalID}", h.goals.UpdateGoal)
+				r.Delete("/{goalID}", h.goals.DeleteGoal)
+				r.Post("/{goalID}/restart", h.goals.RestartGoal)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(h.authMiddleware.RequireScope(models.ScopeProgressRead, models.ScopeProgressWrite))
+				r.Post("/{goalID}/progress", h.goals.AddProgress)
+				r.Get("/{goalID}/progress", h.goals.GetProgress)
+			})
+			r.With(h.authMiddleware.RequireReadScope(models.ScopeAnalyticsRead)).Get("/{goalID}/analytics", h.goals.GetAnalytics)
+		})
+
+		// Group routes
+		r.Route("/groups", func(r chi.Router) {
+			groupScope := h.authMiddleware.RequireScope(models.ScopeGroupsRead, models.ScopeGroupsWrite)
+			r.Group(func(r chi.Router) {
+				r.Use(groupScope)
+				r.Get("/", h.groups.GetGroups)
+				r.Post("/", h.groups.CreateGroup)
+				r.Post("/join", h.groups.JoinGroup)
+				r.Get("/{groupID}", h.groups.GetGroup)
+				r.Put("/{groupID}", h.groups.UpdateGroup)
+				r.Delete("/{groupID}", h.groups.DeleteGroup)
+				r.Post("/{groupID}/leave", h.groups.LeaveGroup)
+				r.Post("/{groupID}/regenerate-invite", h.groups.RegenerateInviteCode)
+				r.Get("/{groupID}/members", h.groups.GetMembers)
+				r.Put("/{groupID}/members/{userID}", h.groups.UpdateMember)
+				r.Delete("/{groupID}/members/{userID}", h.groups.RemoveMember)
+				r.Post("/{groupID}/members/{userID}/promote", h.groups.PromoteMember)
+			})
+
+			// Group goals
+			r.Route("/{groupID}/goals", func(r chi.Router) {
+				r.Group(func(r chi.Router) {
+					r.Use(groupScope)
+					r.Get("/", h.groups.GetGroupGoals)
+					r.Post("/", h.groups.CreateGroupGoal)
+					r.Get("/{goalID}", h.groups.GetGroupGoal)
+					r.Put("/{goalID}", h.groups.UpdateGroupGoal)
+					r.Delete("/{goalID}", h.groups.DeleteGroupGoal)
+					r.Post("/{goalID}/target", h.groups.SetTarget)
+					r.Get("/{goalID}/leaderboard", h.groups.GetLeaderboard)
+				})
+				r.With(h.authMiddleware.RequireScope(models.ScopeProgressRead, models.ScopeProgressWrite)).Post("/{goalID}/progress", h.groups.AddGroupProgress)
+			})
+		})
+
+		// Analytics routes (premium feature)
+		r.Route("/analytics", func(r chi.Router) {
+			r.Use(h.rateLimiter.Limit(h.limits.analytics))
+			r.Use(h.authMiddleware.RequireReadScope(models.ScopeAnalyticsRead))
+			r.Use(h.authMiddleware.RequirePremium)
+			r.Get("/overview", h.users.GetAnalyticsOverview)
+			r.Get("/goals", h.goals.GetGoalsAnalytics)
+			r.Get("/groups", h.groups.GetGroupsAnalytics)
+		})
+	})
+
+	return r
+}
+
+// paymentMethodRoutes registers the payment method endpoints
+func (h apiHandlers) paymentMethodRoutes(r chi.Router) {
+	r.Get("/", h.subscriptions.GetPaymentMethods)
+	r.Post("/", h.subscriptions.AddPaymentMethod)
+	r.Delete("/{paymentMethodID}", h.subscriptions.DeletePaymentMethod)
+	r.Put("/{paymentMethodID}/default", h.subscriptions.SetDefaultPaymentMethod)
+	r.Post("/{paymentMethodID}/default", h.subscriptions.SetDefaultPaymentMethod)
+}
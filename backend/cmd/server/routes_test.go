@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// frontendStores holds the frontend modules that call the API
+const frontendStores = "../../../frontend/src/lib/stores"
+
+var (
+	// apiCall matches apiClient.get<T>('/path' and friends, including
+	// template literals
+	apiCall = regexp.MustCompile("apiClient\\.(get|post|put|patch|delete)\\s*(?:<[^(]*?>)?\\(\\s*['\"`]([^'\"`]+)['\"`]")
+
+	templateParam = regexp.MustCompile(`\$\{[^}]*\}`)
+	routeParam    = regexp.MustCompile(`\{[^}]*\}`)
+)
+
+// TestFrontendRoutesExist fails when a frontend store calls an endpoint the
+// API router does not serve with that method
+func TestFrontendRoutesExist(t *testing.T) {
+	calls := frontendCalls(t)
+	if len(calls) == 0 {
+		t.Fatal("no API calls found in the frontend stores")
+	}
+	served := servedRoutes(t)
+
+	var missing []string
+	for call, files := range calls {
+		if !served[call] {
+			missing = append(missing, call+" (called from "+strings.Join(files, ", ")+")")
+		}
+	}
+	sort.Strings(missing)
+	for _, m := range missing {
+		t.Errorf("frontend calls %s but the router has no such route", m)
+	}
+}
+
+// frontendCalls returns "METHOD /path" for every API call in the frontend
+// stores, with path parameters written as {}
+func frontendCalls(t *testing.T) map[string][]string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(frontendStores, "*.ts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skipf("frontend stores not found at %s", frontendStores)
+	}
+
+	calls := map[string][]string{}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range apiCall.FindAllStringSubmatch(string(src), -1) {
+			path := templateParam.ReplaceAllString(m[2], "{}")
+			path = strings.SplitN(path, "?", 2)[0]
+			call := strings.ToUpper(m[1]) + " " + normalizePath(path)
+			calls[call] = append(calls[call], filepath.Base(file))
+		}
+	}
+	return calls
+}
+
+// servedRoutes returns "METHOD /path" for every route under /api/v1
+func servedRoutes(t *testing.T) map[string]bool {
+	t.Helper()
+	served := map[string]bool{}
+	err := chi.Walk(apiHandlers{}.routes(), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		served[method+" "+normalizePath(routeParam.ReplaceAllString(route, "{}"))] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return served
+}
+
+// normalizePath drops the trailing slash chi keeps on sub-router roots
+func normalizePath(path string) string {
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return path
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	// APIKeyPrefix starts every API key so keys are easy to tell from
+	// access tokens and to spot in leaked code
+	APIKeyPrefix = "cf_"
+	// apiKeyBytes is the amount of randomness in an API key
+	apiKeyBytes = 32
+	// apiKeyShownLength is how much of a key is kept to identify it
+	apiKeyShownLength = len(APIKeyPrefix) + 8
+)
+
+// GenerateAPIKey returns a new API key, the prefix shown to identify it and
+// the hash to store in its place
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	b := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:apiKeyShownLength], HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the stored form of an API key. Keys carry 256 random
+// bits, so a fast hash is enough.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey reports whether a bearer token is an API key rather than a JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsAPIKey(key) || !strings.HasPrefix(key, prefix) || len(prefix) != apiKeyShownLength {
+		t.Fatalf("key %q, prefix %q", key, prefix)
+	}
+	if hash != HashAPIKey(key) || hash == key {
+		t.Fatalf("hash %q does not match the key", hash)
+	}
+
+	other, _, _, _ := GenerateAPIKey()
+	if other == key {
+		t.Fatal("two keys were the same")
+	}
+	if IsAPIKey("eyJhbGciOiJFUzI1NiJ9.e30.sig") {
+		t.Error("a JWT was taken for an API key")
+	}
+}
@@ -0,0 +1,101 @@
+// Package breached looks up passwords in a bundled list of the most common
+// passwords from public data breaches.
+//
+// passwords.bin.gz is built from passwords.txt by gen.go. It holds, sorted
+// by hash, the first 8 bytes of the SHA-1 of each lowercased password and
+// its rank in the list, so lookups are a binary search and the plain list
+// is not compiled into the binary.
+package breached
+
+//go:generate go run gen.go -in passwords.txt -out passwords.bin.gz
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// magic starts the file, followed by the entry count
+const magic = "CFBP1"
+
+// entrySize is the size of a hash prefix and its rank
+const entrySize = 8 + 4
+
+//go:embed passwords.bin.gz
+var data []byte
+
+var (
+	loadOnce sync.Once
+	hashes   []uint64
+	ranks    []uint32
+)
+
+// Rank returns the 1-based position of password in the list, ignoring
+// case, and whether it is there at all
+func Rank(password string) (int, bool) {
+	loadOnce.Do(load)
+	h := Hash(password)
+	i := sort.Search(len(hashes), func(i int) bool { return hashes[i] >= h })
+	if i == len(hashes) || hashes[i] != h {
+		return 0, false
+	}
+	return int(ranks[i]), true
+}
+
+// Len returns how many passwords the list holds
+func Len() int {
+	loadOnce.Do(load)
+	return len(hashes)
+}
+
+// Hash returns the key password is stored under
+func Hash(password string) uint64 {
+	sum := sha1.Sum([]byte(strings.ToLower(password)))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// load decodes the embedded list. It panics if the file is corrupt, which
+// go generate would have to fix.
+func load() {
+	if err := decode(data); err != nil {
+		panic(fmt.Sprintf("breached: corrupt passwords.bin.gz: %v", err))
+	}
+}
+
+func decode(compressed []byte) error {
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(raw, []byte(magic)) || len(raw) < len(magic)+4 {
+		return fmt.Errorf("missing header")
+	}
+	raw = raw[len(magic):]
+	n := int(binary.BigEndian.Uint32(raw))
+	raw = raw[4:]
+	if len(raw) != n*entrySize {
+		return fmt.Errorf("%d bytes of entries, want %d", len(raw), n*entrySize)
+	}
+
+	hashes, ranks = make([]uint64, n), make([]uint32, n)
+	for i := 0; i < n; i++ {
+		e := raw[i*entrySize:]
+		hashes[i] = binary.BigEndian.Uint64(e)
+		ranks[i] = binary.BigEndian.Uint32(e[8:])
+		if i > 0 && hashes[i] <= hashes[i-1] {
+			return fmt.Errorf("entries are not sorted")
+		}
+	}
+	return nil
+}
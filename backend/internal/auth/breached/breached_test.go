@@ -0,0 +1,17 @@
+package breached
+
+import "testing"
+
+func TestRank(t *testing.T) {
+	if Len() < 500 {
+		t.Fatalf("Len() = %d, want the bundled list", Len())
+	}
+	for pw, want := range map[string]int{"123456": 1, "password": 2, "PASSWORD": 2} {
+		if rank, ok := Rank(pw); !ok || rank != want {
+			t.Errorf("Rank(%q) = %d, %t, want %d", pw, rank, ok, want)
+		}
+	}
+	if _, ok := Rank("Correct-Horse-42"); ok {
+		t.Error("an uncommon password was found")
+	}
+}
@@ -0,0 +1,80 @@
+//go:build ignore
+
+// gen builds passwords.bin.gz from a list of passwords, one per line and
+// most common first:
+//
+//	go run gen.go -in passwords.txt -out passwords.bin.gz
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/binary"
+	"flag"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+const magic = "CFBP1"
+
+type entry struct {
+	hash uint64
+	rank uint32
+}
+
+func main() {
+	in := flag.String("in", "passwords.txt", "list of passwords, most common first")
+	out := flag.String("out", "passwords.bin.gz", "file to write")
+	flag.Parse()
+
+	f, err := os.Open(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	seen := map[uint64]bool{}
+	var entries []entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Keep the best rank of passwords differing only in case
+		sum := sha1.Sum([]byte(strings.ToLower(line)))
+		h := binary.BigEndian.Uint64(sum[:8])
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		entries = append(entries, entry{hash: h, rank: uint32(len(entries) + 1)})
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].hash < entries[j].hash })
+
+	var raw bytes.Buffer
+	raw.WriteString(magic)
+	binary.Write(&raw, binary.BigEndian, uint32(len(entries)))
+	for _, e := range entries {
+		binary.Write(&raw, binary.BigEndian, e.hash)
+		binary.Write(&raw, binary.BigEndian, e.rank)
+	}
+
+	var compressed bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&compressed, gzip.BestCompression)
+	zw.Write(raw.Bytes())
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, compressed.Bytes(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %d passwords to %s", len(entries), *out)
+}
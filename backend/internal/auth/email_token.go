@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// emailTokenBytes is the amount of randomness in a mailed token
+const emailTokenBytes = 32
+
+// GenerateEmailToken returns a random token for a link mailed to a user,
+// such as a password reset, along with the hash to store in its place
+func GenerateEmailToken() (token, hash string, err error) {
+	b := make([]byte, emailTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate email token: %w", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashEmailToken(token), nil
+}
+
+// HashEmailToken returns the stored form of a mailed token. Tokens carry
+// 256 random bits, so a fast hash is enough.
+func HashEmailToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
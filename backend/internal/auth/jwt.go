@@ -16,13 +16,21 @@ type TokenType string
 const (
 	AccessToken  TokenType = "access"
 	RefreshToken TokenType = "refresh"
+
+	// MFAPendingToken proves the password step of a sign-in succeeded; it
+	// is exchanged for a token pair with a second factor
+	MFAPendingToken TokenType = "mfa_pending"
 )
 
+// MFAPendingTTL is how long a user has to enter their second factor
+const MFAPendingTTL = 5 * time.Minute
+
 // Claims represents the JWT claims
 type Claims struct {
 	UserID    uuid.UUID `json:"user_id"`
 	Email     string    `json:"email"`
 	TokenType TokenType `json:"token_type"`
+	FamilyID  uuid.UUID `json:"fid"`
 	jwt.RegisteredClaims
 }
 
@@ -32,62 +40,101 @@ type TokenPair struct {
 	RefreshToken string `json:"refresh_token"`
 	ExpiresIn    int64  `json:"expires_in"`
 	TokenType    string `json:"token_type"`
+
+	// RefreshClaims describes the refresh token so callers can record it
+	RefreshClaims *Claims `json:"-"`
 }
 
-// TokenManager handles JWT token operations
+// TokenManager handles JWT token operations. Tokens are signed with the
+// key ring's current key and name it in the kid header.
 type TokenManager struct {
-	accessSecret  []byte
-	refreshSecret []byte
-	accessTTL     time.Duration
-	refreshTTL    time.Duration
-	issuer        string
+	keys       *KeyRing
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	issuer     string
+
+	// Secrets for HS256 tokens issued before asymmetric signing
+	legacyAccessSecret  []byte
+	legacyRefreshSecret []byte
+}
+
+// TokenOption configures a TokenManager
+type TokenOption func(*TokenManager)
+
+// WithLegacySecrets accepts HS256 tokens without a kid, signed with the
+// given secrets, until they expire. Empty secrets are ignored.
+func WithLegacySecrets(accessSecret, refreshSecret string) TokenOption {
+	return func(tm *TokenManager) {
+		if accessSecret != "" {
+			tm.legacyAccessSecret = []byte(accessSecret)
+		}
+		if refreshSecret != "" {
+			tm.legacyRefreshSecret = []byte(refreshSecret)
+		}
+	}
 }
 
 // NewTokenManager creates a new token manager
-func NewTokenManager(accessSecret, refreshSecret string, accessTTL, refreshTTL time.Duration, issuer string) *TokenManager {
-	return &TokenManager{
-		accessSecret:  []byte(accessSecret),
-		refreshSecret: []byte(refreshSecret),
-		accessTTL:     accessTTL,
-		refreshTTL:    refreshTTL,
-		issuer:        issuer,
+func NewTokenManager(keys *KeyRing, accessTTL, refreshTTL time.Duration, issuer string, opts ...TokenOption) *TokenManager {
+	tm := &TokenManager{
+		keys:       keys,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		issuer:     issuer,
+	}
+	for _, opt := range opts {
+		opt(tm)
 	}
+	return tm
 }
 
-// GenerateTokenPair generates both access and refresh tokens
-func (tm *TokenManager) GenerateTokenPair(userID uuid.UUID, email string) (*TokenPair, error) {
+// GenerateTokenPair generates both access and refresh tokens. Both carry
+// familyID, which ties every pair issued from one sign-in together.
+func (tm *TokenManager) GenerateTokenPair(userID uuid.UUID, email string, familyID uuid.UUID) (*TokenPair, error) {
 	// Generate access token
-	accessToken, err := tm.generateToken(userID, email, AccessToken, tm.accessSecret, tm.accessTTL)
+	accessToken, _, err := tm.generateToken(userID, email, familyID, AccessToken, tm.accessTTL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
 	// Generate refresh token
-	refreshToken, err := tm.generateToken(userID, email, RefreshToken, tm.refreshSecret, tm.refreshTTL)
+	refreshToken, refreshClaims, err := tm.generateToken(userID, email, familyID, RefreshToken, tm.refreshTTL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
 	return &TokenPair{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    int64(tm.accessTTL.Seconds()),
-		TokenType:    "Bearer",
+		AccessToken:   accessToken,
+		RefreshToken:  refreshToken,
+		ExpiresIn:     int64(tm.accessTTL.Seconds()),
+		TokenType:     "Bearer",
+		RefreshClaims: refreshClaims,
 	}, nil
 }
 
+// GenerateMFAToken generates a short-lived mfa_pending token for a user who
+// has passed the password step of a sign-in
+func (tm *TokenManager) GenerateMFAToken(userID uuid.UUID, email string) (string, *Claims, error) {
+	token, claims, err := tm.generateToken(userID, email, uuid.Nil, MFAPendingToken, MFAPendingTTL)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate MFA token: %w", err)
+	}
+	return token, claims, nil
+}
+
 // generateToken creates a JWT token with the specified parameters
-func (tm *TokenManager) generateToken(userID uuid.UUID, email string, tokenType TokenType, secret []byte, ttl time.Duration) (string, error) {
+func (tm *TokenManager) generateToken(userID uuid.UUID, email string, familyID uuid.UUID, tokenType TokenType, ttl time.Duration) (string, *Claims, error) {
 	now := time.Now().UTC()
 	jti, err := generateJTI()
 	if err != nil {
-		return "", fmt.Errorf("failed to generate JTI: %w", err)
+		return "", nil, fmt.Errorf("failed to generate JTI: %w", err)
 	}
 
 	claims := Claims{
 		UserID:    userID,
 		Email:     email,
 		TokenType: tokenType,
+		FamilyID:  familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    tm.issuer,
 			Subject:   userID.String(),
@@ -99,33 +146,57 @@ func (tm *TokenManager) generateToken(userID uuid.UUID, email string, tokenType
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(secret)
+	key, err := tm.keys.signer()
+	if err != nil {
+		return "", nil, err
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	tokenString, err := token.SignedString(key.private)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %w", err)
+		return "", nil, fmt.Errorf("failed to sign token: %w", err)
 	}
 
-	return tokenString, nil
+	return tokenString, &claims, nil
 }
 
 // ValidateAccessToken validates an access token and returns the claims
 func (tm *TokenManager) ValidateAccessToken(tokenString string) (*Claims, error) {
-	return tm.validateToken(tokenString, AccessToken, tm.accessSecret)
+	return tm.validateToken(tokenString, AccessToken, tm.legacyAccessSecret)
 }
 
 // ValidateRefreshToken validates a refresh token and returns the claims
 func (tm *TokenManager) ValidateRefreshToken(tokenString string) (*Claims, error) {
-	return tm.validateToken(tokenString, RefreshToken, tm.refreshSecret)
+	return tm.validateToken(tokenString, RefreshToken, tm.legacyRefreshSecret)
 }
 
-// validateToken validates a JWT token with the specified parameters
-func (tm *TokenManager) validateToken(tokenString string, expectedType TokenType, secret []byte) (*Claims, error) {
+// ValidateMFAToken validates an mfa_pending token and returns the claims
+func (tm *TokenManager) ValidateMFAToken(tokenString string) (*Claims, error) {
+	return tm.validateToken(tokenString, MFAPendingToken, nil)
+}
+
+// validateToken validates a JWT token with the specified parameters. The
+// verification key is picked by the kid header; tokens without one are
+// legacy HS256 tokens checked against legacySecret.
+func (tm *TokenManager) validateToken(tokenString string, expectedType TokenType, legacySecret []byte) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			if token.Method != jwt.SigningMethodHS256 || legacySecret == nil {
+				return nil, fmt.Errorf("token has no key ID")
+			}
+			return legacySecret, nil
+		}
+
+		key, ok := tm.keys.verifier(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key ID: %s", kid)
+		}
 		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if token.Method.Alg() != key.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return secret, nil
+		return key.public, nil
 	})
 
 	if err != nil {
@@ -148,25 +219,13 @@ func (tm *TokenManager) validateToken(tokenString string, expectedType TokenType
 	}
 
 	// Validate audience
-	if !claims.VerifyAudience("chainforge", true) {
+	if !containsAudience(claims.Audience, "chainforge") {
 		return nil, fmt.Errorf("invalid audience")
 	}
 
 	return claims, nil
 }
 
-// RefreshTokens validates a refresh token and generates a new token pair
-func (tm *TokenManager) RefreshTokens(refreshTokenString string) (*TokenPair, error) {
-	// Validate refresh token
-	claims, err := tm.ValidateRefreshToken(refreshTokenString)
-	if err != nil {
-		return nil, fmt.Errorf("invalid refresh token: %w", err)
-	}
-
-	// Generate new token pair
-	return tm.GenerateTokenPair(claims.UserID, claims.Email)
-}
-
 // ExtractUserID extracts user ID from access token without full validation
 func (tm *TokenManager) ExtractUserID(tokenString string) (uuid.UUID, error) {
 	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, &Claims{})
@@ -197,6 +256,16 @@ func (tm *TokenManager) IsTokenExpired(tokenString string) bool {
 	return time.Now().UTC().After(claims.ExpiresAt.Time)
 }
 
+// containsAudience reports whether the audience claim includes the expected value
+func containsAudience(audience jwt.ClaimStrings, expected string) bool {
+	for _, aud := range audience {
+		if aud == expected {
+			return true
+		}
+	}
+	return false
+}
+
 // generateJTI generates a unique JWT ID
 func generateJTI() (string, error) {
 	bytes := make([]byte, 16)
@@ -204,53 +273,4 @@ func generateJTI() (string, error) {
 		return "", err
 	}
 	return hex.EncodeToString(bytes), nil
-}
-
-// TokenBlacklist represents a simple in-memory token blacklist
-// In production, this should be replaced with Redis or database storage
-type TokenBlacklist struct {
-	tokens map[string]time.Time // token_id -> expiry_time
-}
-
-// NewTokenBlacklist creates a new token blacklist
-func NewTokenBlacklist() *TokenBlacklist {
-	return &TokenBlacklist{
-		tokens: make(map[string]time.Time),
-	}
-}
-
-// Add adds a token to the blacklist
-func (tb *TokenBlacklist) Add(jti string, expiryTime time.Time) {
-	tb.tokens[jti] = expiryTime
-}
-
-// IsBlacklisted checks if a token is blacklisted
-func (tb *TokenBlacklist) IsBlacklisted(jti string) bool {
-	expiryTime, exists := tb.tokens[jti]
-	if !exists {
-		return false
-	}
-
-	// If token has expired, remove it from blacklist
-	if time.Now().UTC().After(expiryTime) {
-		delete(tb.tokens, jti)
-		return false
-	}
-
-	return true
-}
-
-// Cleanup removes expired tokens from the blacklist
-func (tb *TokenBlacklist) Cleanup() {
-	now := time.Now().UTC()
-	for jti, expiryTime := range tb.tokens {
-		if now.After(expiryTime) {
-			delete(tb.tokens, jti)
-		}
-	}
-}
-
-// GetBlacklistedCount returns the number of blacklisted tokens
-func (tb *TokenBlacklist) GetBlacklistedCount() int {
-	return len(tb.tokens)
 }
\ No newline at end of file
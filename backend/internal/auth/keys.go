@@ -0,0 +1,389 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithms tokens can be signed with
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// rsaKeyBits is the modulus size of generated RS256 keys
+const rsaKeyBits = 2048
+
+// StoredSigningKey is a signing key as persisted by a SigningKeyStore. A key
+// signs from ActivatesAt until RetiresAt and verifies, and is published in
+// the JWKS, until ExpiresAt.
+type StoredSigningKey struct {
+	ID          string
+	Algorithm   string
+	PrivateKey  []byte // PKCS #8, DER encoded
+	CreatedAt   time.Time
+	ActivatesAt time.Time
+	RetiresAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// SigningKeyStore persists signing keys so every server process signs and
+// verifies with the same set
+type SigningKeyStore interface {
+	// ListSigningKeys returns every key that has not expired by now
+	ListSigningKeys(ctx context.Context, now time.Time) ([]StoredSigningKey, error)
+
+	// CreateSigningKey stores a new key
+	CreateSigningKey(ctx context.Context, key *StoredSigningKey) error
+
+	// DeleteExpiredSigningKeys removes keys that expired by now
+	DeleteExpiredSigningKeys(ctx context.Context, now time.Time) (int, error)
+}
+
+// KeyRingConfig controls which keys a KeyRing creates and when
+type KeyRingConfig struct {
+	// Algorithm new keys use, AlgorithmRS256 or AlgorithmEdDSA
+	Algorithm string
+
+	// RotationInterval is how long each key signs for
+	RotationInterval time.Duration
+
+	// Overlap is how long the next key is published before it starts
+	// signing, so verifiers that cache the JWKS already know it
+	Overlap time.Duration
+
+	// TokenTTL is the longest lifetime of any token; retired keys keep
+	// verifying for this long
+	TokenTTL time.Duration
+}
+
+// signingKey is a parsed key held by a KeyRing
+type signingKey struct {
+	id          string
+	method      jwt.SigningMethod
+	private     crypto.Signer
+	public      crypto.PublicKey
+	activatesAt time.Time
+	retiresAt   time.Time
+}
+
+// KeyRing holds the asymmetric keys tokens are signed and verified with. Sync
+// loads keys from the store and creates the next one when rotation is due.
+type KeyRing struct {
+	store SigningKeyStore
+	cfg   KeyRingConfig
+	now   func() time.Time
+
+	mu   sync.RWMutex
+	keys map[string]*signingKey
+}
+
+// NewKeyRing creates a key ring over store. Call Sync before issuing tokens.
+func NewKeyRing(store SigningKeyStore, cfg KeyRingConfig) (*KeyRing, error) {
+	if signingMethod(cfg.Algorithm) == nil {
+		return nil, fmt.Errorf("unsupported signing algorithm %q (must be %s or %s)", cfg.Algorithm, AlgorithmRS256, AlgorithmEdDSA)
+	}
+	if cfg.RotationInterval <= cfg.Overlap {
+		return nil, errors.New("key rotation interval must be longer than the overlap window")
+	}
+	return &KeyRing{
+		store: store,
+		cfg:   cfg,
+		now:   func() time.Time { return time.Now().UTC() },
+		keys:  make(map[string]*signingKey),
+	}, nil
+}
+
+// Sync reloads keys from the store, creating the next key when the current
+// one is within the overlap window of retiring, and drops expired keys
+func (kr *KeyRing) Sync(ctx context.Context) error {
+	now := kr.now()
+	stored, err := kr.store.ListSigningKeys(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	if activatesAt, due := kr.nextRotation(stored, now); due {
+		key, err := kr.createKey(ctx, activatesAt)
+		if err != nil {
+			return err
+		}
+		stored = append(stored, *key)
+	}
+
+	if err := kr.load(stored); err != nil {
+		return err
+	}
+	if _, err := kr.store.DeleteExpiredSigningKeys(ctx, now); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Rotate creates a key that starts signing immediately, for when the current
+// key must be replaced early. Older keys keep verifying until they expire.
+func (kr *KeyRing) Rotate(ctx context.Context) (string, error) {
+	key, err := kr.createKey(ctx, kr.now())
+	if err != nil {
+		return "", err
+	}
+	return key.ID, kr.Sync(ctx)
+}
+
+// nextRotation reports whether a new key is needed and when it should start signing
+func (kr *KeyRing) nextRotation(stored []StoredSigningKey, now time.Time) (time.Time, bool) {
+	var newest *StoredSigningKey
+	for i := range stored {
+		if newest == nil || stored[i].ActivatesAt.After(newest.ActivatesAt) {
+			newest = &stored[i]
+		}
+	}
+
+	switch {
+	case newest == nil || newest.Algorithm != kr.cfg.Algorithm:
+		return now, true
+	case now.Before(newest.RetiresAt.Add(-kr.cfg.Overlap)):
+		return time.Time{}, false
+	case newest.RetiresAt.Before(now):
+		return now, true
+	default:
+		return newest.RetiresAt, true
+	}
+}
+
+// createKey generates and stores a key that signs from activatesAt
+func (kr *KeyRing) createKey(ctx context.Context, activatesAt time.Time) (*StoredSigningKey, error) {
+	private, err := generatePrivateKey(kr.cfg.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signing key: %w", err)
+	}
+	kid, err := generateJTI()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key ID: %w", err)
+	}
+
+	retiresAt := activatesAt.Add(kr.cfg.RotationInterval)
+	key := &StoredSigningKey{
+		ID:          kid,
+		Algorithm:   kr.cfg.Algorithm,
+		PrivateKey:  der,
+		CreatedAt:   kr.now(),
+		ActivatesAt: activatesAt,
+		RetiresAt:   retiresAt,
+		ExpiresAt:   retiresAt.Add(kr.cfg.TokenTTL),
+	}
+	if err := kr.store.CreateSigningKey(ctx, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// load parses stored keys and replaces the ring's contents with them
+func (kr *KeyRing) load(stored []StoredSigningKey) error {
+	keys := make(map[string]*signingKey, len(stored))
+	for _, s := range stored {
+		method := signingMethod(s.Algorithm)
+		if method == nil {
+			return fmt.Errorf("signing key %s has unsupported algorithm %q", s.ID, s.Algorithm)
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(s.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("failed to parse signing key %s: %w", s.ID, err)
+		}
+		private, ok := parsed.(crypto.Signer)
+		if !ok {
+			return fmt.Errorf("signing key %s cannot sign", s.ID)
+		}
+		keys[s.ID] = &signingKey{
+			id:          s.ID,
+			method:      method,
+			private:     private,
+			public:      private.Public(),
+			activatesAt: s.ActivatesAt,
+			retiresAt:   s.RetiresAt,
+		}
+	}
+
+	kr.mu.Lock()
+	kr.keys = keys
+	kr.mu.Unlock()
+	return nil
+}
+
+// signer returns the most recently activated key. A retired key keeps
+// signing if no successor exists yet, so a late rotation never stops
+// sign-ins.
+func (kr *KeyRing) signer() (*signingKey, error) {
+	now := kr.now()
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	var current *signingKey
+	for _, k := range kr.keys {
+		if k.activatesAt.After(now) {
+			continue
+		}
+		if current == nil || k.activatesAt.After(current.activatesAt) ||
+			(k.activatesAt.Equal(current.activatesAt) && k.id > current.id) {
+			current = k
+		}
+	}
+	if current == nil {
+		return nil, errors.New("no active signing key")
+	}
+	return current, nil
+}
+
+// verifier returns the key with the given ID
+func (kr *KeyRing) verifier(kid string) (*signingKey, bool) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	k, ok := kr.keys[kid]
+	return k, ok
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every key that can sign or verify tokens,
+// including keys published ahead of their activation
+func (kr *KeyRing) JWKS() JWKS {
+	kr.mu.RLock()
+	keys := make([]*signingKey, 0, len(kr.keys))
+	for _, k := range kr.keys {
+		keys = append(keys, k)
+	}
+	kr.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].activatesAt.After(keys[j].activatesAt)
+	})
+
+	set := JWKS{Keys: make([]JWK, 0, len(keys))}
+	for _, k := range keys {
+		jwk := JWK{Use: "sig", KeyID: k.id, Algorithm: k.method.Alg()}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// signingMethod returns the JWT signing method for an algorithm name
+func signingMethod(algorithm string) jwt.SigningMethod {
+	switch algorithm {
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256
+	case AlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA
+	}
+	return nil
+}
+
+// generatePrivateKey creates a private key for an algorithm
+func generatePrivateKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+		}
+		return key, nil
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Ed25519 key: %w", err)
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+}
+
+// MemorySigningKeyStore keeps signing keys in memory. Keys are lost on
+// restart, so it suits tests and single-process development only.
+type MemorySigningKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]StoredSigningKey
+}
+
+// NewMemorySigningKeyStore creates an empty in-memory key store
+func NewMemorySigningKeyStore() *MemorySigningKeyStore {
+	return &MemorySigningKeyStore{keys: make(map[string]StoredSigningKey)}
+}
+
+// ListSigningKeys returns every key that has not expired by now
+func (s *MemorySigningKeyStore) ListSigningKeys(ctx context.Context, now time.Time) ([]StoredSigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := []StoredSigningKey{}
+	for _, k := range s.keys {
+		if now.Before(k.ExpiresAt) {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// CreateSigningKey stores a new key
+func (s *MemorySigningKeyStore) CreateSigningKey(ctx context.Context, key *StoredSigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = *key
+	return nil
+}
+
+// DeleteExpiredSigningKeys removes keys that expired by now
+func (s *MemorySigningKeyStore) DeleteExpiredSigningKeys(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for id, k := range s.keys {
+		if !now.Before(k.ExpiresAt) {
+			delete(s.keys, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+var _ SigningKeyStore = (*MemorySigningKeyStore)(nil)
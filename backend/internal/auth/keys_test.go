@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func newTestKeyRing(t *testing.T, algorithm string) *KeyRing {
+	t.Helper()
+	ring, err := NewKeyRing(NewMemorySigningKeyStore(), KeyRingConfig{
+		Algorithm:        algorithm,
+		RotationInterval: 30 * 24 * time.Hour,
+		Overlap:          24 * time.Hour,
+		TokenTTL:         7 * 24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+	if err := ring.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	return ring
+}
+
+func TestKeyRingRotatesWithOverlap(t *testing.T) {
+	ring := newTestKeyRing(t, AlgorithmEdDSA)
+	ctx := context.Background()
+	start := time.Now().UTC()
+	now := start
+	ring.now = func() time.Time { return now }
+
+	first, err := ring.signer()
+	if err != nil {
+		t.Fatalf("signer: %v", err)
+	}
+
+	// Nothing changes until the overlap window before retirement
+	now = first.retiresAt.Add(-25 * time.Hour)
+	ring.Sync(ctx)
+	if n := len(ring.JWKS().Keys); n != 1 {
+		t.Fatalf("keys before overlap = %d, want 1", n)
+	}
+
+	// The successor is published but does not sign yet
+	now = first.retiresAt.Add(-23 * time.Hour)
+	ring.Sync(ctx)
+	if n := len(ring.JWKS().Keys); n != 2 {
+		t.Fatalf("keys in overlap = %d, want 2", n)
+	}
+	if k, _ := ring.signer(); k.id != first.id {
+		t.Fatal("successor signed before its activation")
+	}
+
+	// After retirement the successor signs and the old key still verifies
+	now = first.retiresAt.Add(time.Minute)
+	ring.Sync(ctx)
+	second, _ := ring.signer()
+	if second.id == first.id || !second.activatesAt.Equal(first.retiresAt) {
+		t.Fatalf("signer after retirement = %s activating %v", second.id, second.activatesAt)
+	}
+	if _, ok := ring.verifier(first.id); !ok {
+		t.Fatal("retired key stopped verifying inside the token lifetime")
+	}
+
+	// Once every token it signed has expired the old key is dropped
+	now = first.retiresAt.Add(7*24*time.Hour + time.Minute)
+	ring.Sync(ctx)
+	if _, ok := ring.verifier(first.id); ok {
+		t.Error("expired key still verifies")
+	}
+}
+
+func TestKeyRingRotatesWhenAlgorithmChanges(t *testing.T) {
+	store := NewMemorySigningKeyStore()
+	ctx := context.Background()
+	cfg := KeyRingConfig{Algorithm: AlgorithmEdDSA, RotationInterval: 24 * time.Hour, Overlap: time.Hour, TokenTTL: time.Hour}
+
+	ed, _ := NewKeyRing(store, cfg)
+	if err := ed.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	old, _ := ed.signer()
+
+	cfg.Algorithm = AlgorithmRS256
+	rs, _ := NewKeyRing(store, cfg)
+	if err := rs.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	current, _ := rs.signer()
+	if current.method != jwt.SigningMethodRS256 {
+		t.Errorf("signing with %s, want RS256", current.method.Alg())
+	}
+	if _, ok := rs.verifier(old.id); !ok {
+		t.Error("EdDSA key stopped verifying after the switch")
+	}
+}
+
+func TestTokensVerifyWithPublishedKeys(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			ring := newTestKeyRing(t, algorithm)
+			tm := NewTokenManager(ring, 15*time.Minute, time.Hour, "chainforge")
+			pair, err := tm.GenerateTokenPair(uuid.New(), "ada@example.com", uuid.New())
+			if err != nil {
+				t.Fatalf("GenerateTokenPair: %v", err)
+			}
+
+			// Verify the way another service would, using only the JWKS
+			jwks := ring.JWKS()
+			token, err := jwt.Parse(pair.AccessToken, func(token *jwt.Token) (interface{}, error) {
+				for _, k := range jwks.Keys {
+					if k.KeyID == token.Header["kid"] {
+						return publicKeyFromJWK(t, k), nil
+					}
+				}
+				return nil, jwt.ErrTokenUnverifiable
+			}, jwt.WithValidMethods([]string{algorithm}))
+			if err != nil || !token.Valid {
+				t.Fatalf("token did not verify with the JWKS: %v", err)
+			}
+
+			if _, err := tm.ValidateAccessToken(pair.AccessToken); err != nil {
+				t.Fatalf("ValidateAccessToken: %v", err)
+			}
+			if _, err := tm.ValidateAccessToken(pair.RefreshToken); err == nil {
+				t.Fatal("refresh token accepted as an access token")
+			}
+		})
+	}
+}
+
+func TestValidateTokenPicksKeyByKid(t *testing.T) {
+	ring := newTestKeyRing(t, AlgorithmRS256)
+	tm := NewTokenManager(ring, 15*time.Minute, time.Hour, "chainforge", WithLegacySecrets("legacy-access", "legacy-refresh"))
+	key, _ := ring.signer()
+
+	claims := Claims{
+		UserID:    uuid.New(),
+		TokenType: AccessToken,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chainforge",
+			Audience:  []string{"chainforge"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			ID:        "jti",
+		},
+	}
+	sign := func(method jwt.SigningMethod, kid string, secret interface{}) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(secret)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return s
+	}
+
+	other := newTestKeyRing(t, AlgorithmRS256)
+	otherKey, _ := other.signer()
+	publicDER := []byte(base64.StdEncoding.EncodeToString(key.public.(*rsa.PublicKey).N.Bytes()))
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"current key", sign(key.method, key.id, key.private), true},
+		{"legacy HS256 without kid", sign(jwt.SigningMethodHS256, "", []byte("legacy-access")), true},
+		{"HS256 with the wrong secret", sign(jwt.SigningMethodHS256, "", []byte("guess")), false},
+		{"unknown kid", sign(otherKey.method, otherKey.id, otherKey.private), false},
+		{"known kid, foreign key", sign(otherKey.method, key.id, otherKey.private), false},
+		{"HS256 under an RSA kid", sign(jwt.SigningMethodHS256, key.id, publicDER), false},
+	}
+	for _, tt := range tests {
+		_, err := tm.ValidateAccessToken(tt.token)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: err = %v, want valid=%v", tt.name, err, tt.valid)
+		}
+	}
+
+	strict := NewTokenManager(ring, 15*time.Minute, time.Hour, "chainforge")
+	if _, err := strict.ValidateAccessToken(tests[1].token); err == nil {
+		t.Error("legacy token accepted without legacy secrets")
+	}
+}
+
+// publicKeyFromJWK rebuilds a public key from its JWK form
+func publicKeyFromJWK(t *testing.T, k JWK) interface{} {
+	t.Helper()
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || strings.ContainsAny(s, "+/=") {
+			t.Fatalf("bad base64url %q: %v", s, err)
+		}
+		return b
+	}
+	switch k.KeyType {
+	case "RSA":
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(decode(k.N)),
+			E: int(new(big.Int).SetBytes(decode(k.E)).Int64()),
+		}
+	case "OKP":
+		return ed25519.PublicKey(decode(k.X))
+	}
+	t.Fatalf("unexpected key type %q", k.KeyType)
+	return nil
+}
@@ -0,0 +1,481 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidOIDCResponse is returned when a provider's answer to a sign-in
+// cannot be trusted: an unknown or expired code, or an ID token that fails
+// verification
+var ErrInvalidOIDCResponse = errors.New("invalid OpenID Connect response")
+
+const (
+	// oidcMetadataTTL is how long a provider's discovery document is cached
+	oidcMetadataTTL = 24 * time.Hour
+
+	// oidcKeyRefreshInterval is the least time between JWKS fetches
+	// triggered by an unknown key ID
+	oidcKeyRefreshInterval = time.Minute
+
+	// oidcMaxResponseSize bounds every response read from a provider
+	oidcMaxResponseSize = 1 << 20
+)
+
+// OIDCProviderConfig configures sign-in with one OpenID Connect provider
+type OIDCProviderConfig struct {
+	Name         string // Used in URLs and stored with linked identities
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string // Requested along with openid; email and profile if empty
+}
+
+// OIDCIdentity is what a provider asserted about a user in a verified ID
+// token
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+// OIDCAuthorization is a started sign-in. State, Nonce and CodeVerifier
+// must be kept until the provider redirects back and are needed once.
+type OIDCAuthorization struct {
+	URL          string
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+// OIDCProvider signs users in with an OpenID Connect provider using the
+// authorization code flow with PKCE. The discovery document and signing
+// keys are fetched on first use and cached.
+type OIDCProvider struct {
+	cfg    OIDCProviderConfig
+	client *http.Client
+	now    func() time.Time
+
+	mu           sync.Mutex
+	metadata     *oidcMetadata
+	metadataAt   time.Time
+	keys         map[string]JWK
+	keysAt       time.Time
+	keysFetching bool
+}
+
+// oidcMetadata is the part of a discovery document the client uses
+type oidcMetadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// NewOIDCProvider creates a provider client. A nil httpClient uses one with
+// a short timeout.
+func NewOIDCProvider(cfg OIDCProviderConfig, httpClient *http.Client) *OIDCProvider {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &OIDCProvider{cfg: cfg, client: httpClient, now: time.Now}
+}
+
+// Name returns the name the provider is configured under
+func (p *OIDCProvider) Name() string {
+	return p.cfg.Name
+}
+
+// Authorize starts a sign-in and returns the URL to send the browser to
+func (p *OIDCProvider) Authorize(ctx context.Context) (*OIDCAuthorization, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var secrets [3]string
+	for i := range secrets {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate OpenID Connect state: %w", err)
+		}
+		secrets[i] = EncodeBase64URL(b)
+	}
+	a := &OIDCAuthorization{State: secrets[0], Nonce: secrets[1], CodeVerifier: secrets[2]}
+
+	scopes := []string{"openid"}
+	extra := p.cfg.Scopes
+	if len(extra) == 0 {
+		extra = []string{"email", "profile"}
+	}
+	for _, s := range extra {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {a.State},
+		"nonce":                 {a.Nonce},
+		"code_challenge":        {PKCEChallenge(a.CodeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	authURL, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+	if authURL.RawQuery != "" {
+		for k, v := range authURL.Query() {
+			if _, ok := query[k]; !ok {
+				query[k] = v
+			}
+		}
+	}
+	authURL.RawQuery = query.Encode()
+	a.URL = authURL.String()
+	return a, nil
+}
+
+// Exchange redeems an authorization code and returns the identity in the
+// verified ID token, which must carry nonce
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*OIDCIdentity, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	basic := p.cfg.ClientSecret != "" &&
+		(len(metadata.TokenAuthMethods) == 0 || slices.Contains(metadata.TokenAuthMethods, "client_secret_basic"))
+	if !basic {
+		form.Set("client_id", p.cfg.ClientID)
+		if p.cfg.ClientSecret != "" {
+			form.Set("client_secret", p.cfg.ClientSecret)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if basic {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s token request failed: %w", p.cfg.Name, err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponseSize)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%s token response (status %d) is not JSON: %w", p.cfg.Name, resp.StatusCode, err)
+	}
+	switch {
+	case body.Error == "invalid_grant":
+		return nil, invalidOIDC("authorization code rejected: %s", body.ErrorDescription)
+	case body.Error != "" || resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s token request failed with status %d: %s %s",
+			p.cfg.Name, resp.StatusCode, body.Error, body.ErrorDescription)
+	case body.IDToken == "":
+		return nil, invalidOIDC("token response has no ID token")
+	}
+
+	return p.verifyIDToken(ctx, body.IDToken, nonce)
+}
+
+// idTokenClaims are the ID token claims the client reads
+type idTokenClaims struct {
+	Nonce           string    `json:"nonce"`
+	AuthorizedParty string    `json:"azp"`
+	Email           string    `json:"email"`
+	EmailVerified   claimBool `json:"email_verified"`
+	GivenName       string    `json:"given_name"`
+	FamilyName      string    `json:"family_name"`
+	jwt.RegisteredClaims
+}
+
+// claimBool is a boolean claim some providers send as a string
+type claimBool bool
+
+func (b *claimBool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", `"true"`:
+		*b = true
+	case "false", `"false"`, "null":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean claim %s", data)
+	}
+	return nil
+}
+
+// verifyIDToken checks an ID token's signature and claims (OpenID Connect
+// Core 1.0, section 3.1.3.7)
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, idToken, nonce string) (*OIDCIdentity, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(p.cfg.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+		jwt.WithTimeFunc(p.now),
+	)
+	var claims idTokenClaims
+	_, err := parser.ParseWithClaims(idToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		jwk, err := p.signingKey(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if jwk.Algorithm != "" && jwk.Algorithm != token.Method.Alg() {
+			return nil, fmt.Errorf("key %s is for %s, not %s", kid, jwk.Algorithm, token.Method.Alg())
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		if !keyMatchesMethod(key, token.Method) {
+			return nil, fmt.Errorf("key %s cannot verify %s", kid, token.Method.Alg())
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, invalidOIDC("ID token: %v", err)
+	}
+
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID {
+		return nil, invalidOIDC("ID token was issued to %q", claims.AuthorizedParty)
+	}
+	if claims.IssuedAt == nil {
+		return nil, invalidOIDC("ID token has no issue time")
+	}
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, invalidOIDC("ID token nonce does not match")
+	}
+	if claims.Subject == "" {
+		return nil, invalidOIDC("ID token has no subject")
+	}
+
+	return &OIDCIdentity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		GivenName:     claims.GivenName,
+		FamilyName:    claims.FamilyName,
+	}, nil
+}
+
+// discover returns the provider's discovery document, fetching it if the
+// cached copy is missing or stale
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcMetadata, error) {
+	p.mu.Lock()
+	if p.metadata != nil && p.now().Sub(p.metadataAt) < oidcMetadataTTL {
+		m := p.metadata
+		p.mu.Unlock()
+		return m, nil
+	}
+	p.mu.Unlock()
+
+	var m oidcMetadata
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", &m); err != nil {
+		return nil, err
+	}
+	if m.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("%s discovery document is for issuer %q", p.cfg.Name, m.Issuer)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return nil, fmt.Errorf("%s discovery document is missing endpoints", p.cfg.Name)
+	}
+
+	p.mu.Lock()
+	p.metadata, p.metadataAt = &m, p.now()
+	p.mu.Unlock()
+	return &m, nil
+}
+
+// signingKey returns the provider key with ID kid. An unknown kid refetches
+// the JWKS, at most once per oidcKeyRefreshInterval, to pick up rotated
+// keys. A token without a kid is accepted if the provider has one key.
+func (p *OIDCProvider) signingKey(ctx context.Context, kid string) (JWK, error) {
+	p.mu.Lock()
+	key, ok := p.lookupKey(kid)
+	stale := p.now().Sub(p.keysAt) >= oidcMetadataTTL
+	refresh := (!ok || stale) && !p.keysFetching && p.now().Sub(p.keysAt) >= oidcKeyRefreshInterval
+	if refresh {
+		p.keysFetching = true
+	}
+	p.mu.Unlock()
+	if ok && !refresh {
+		return key, nil
+	}
+	if !refresh {
+		return JWK{}, fmt.Errorf("unknown key ID %q", kid)
+	}
+
+	keys, err := p.fetchKeys(ctx)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keysFetching = false
+	if err != nil {
+		if ok {
+			return key, nil
+		}
+		return JWK{}, err
+	}
+	p.keys, p.keysAt = keys, p.now()
+	if key, ok = p.lookupKey(kid); !ok {
+		return JWK{}, fmt.Errorf("unknown key ID %q", kid)
+	}
+	return key, nil
+}
+
+// lookupKey finds a cached key. p.mu must be held.
+func (p *OIDCProvider) lookupKey(kid string) (JWK, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k, true
+		}
+	}
+	k, ok := p.keys[kid]
+	return k, ok
+}
+
+// fetchKeys downloads the provider's signing keys
+func (p *OIDCProvider) fetchKeys(ctx context.Context) (map[string]JWK, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var set JWKS
+	if err := p.getJSON(ctx, metadata.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]JWK, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use == "" || k.Use == "sig" {
+			keys[k.KeyID] = k
+		}
+	}
+	return keys, nil
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request for %s: %w", url, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s: status %d", url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponseSize)).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", url, err)
+	}
+	return nil
+}
+
+// PublicKey decodes an RSA, P-256 or Ed25519 JWK
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err1 := DecodeBase64URL(k.N)
+		e, err2 := DecodeBase64URL(k.E)
+		if errors.Join(err1, err2) != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA key %s", k.KeyID)
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA key %s is shorter than 2048 bits", k.KeyID)
+		}
+		return key, nil
+	case "EC":
+		x, err1 := DecodeBase64URL(k.X)
+		y, err2 := DecodeBase64URL(k.Y)
+		if k.Curve != "P-256" || errors.Join(err1, err2) != nil || len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("invalid or unsupported EC key %s", k.KeyID)
+		}
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, fmt.Errorf("EC key %s is not on the curve", k.KeyID)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		x, err := DecodeBase64URL(k.X)
+		if k.Curve != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid or unsupported OKP key %s", k.KeyID)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+}
+
+// keyMatchesMethod reports whether key is the kind method verifies with
+func keyMatchesMethod(key crypto.PublicKey, method jwt.SigningMethod) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return method == jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		return method == jwt.SigningMethodES256
+	case ed25519.PublicKey:
+		return method == jwt.SigningMethodEdDSA
+	}
+	return false
+}
+
+// PKCEChallenge derives the S256 code challenge for a PKCE code verifier
+// (RFC 7636)
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return EncodeBase64URL(sum[:])
+}
+
+func invalidOIDC(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidOIDCResponse, fmt.Sprintf(format, args...))
+}
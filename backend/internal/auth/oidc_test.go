@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"chainforge/internal/auth/oidctest"
+)
+
+const testRedirectURL = "https://app.chainforge.test/auth/callback"
+
+var testOIDCUser = oidctest.User{
+	Subject:       "110169484474386276334",
+	Email:         "ada@example.com",
+	EmailVerified: true,
+	GivenName:     "Ada",
+	FamilyName:    "Lovelace",
+}
+
+func newTestOIDCProvider(issuer *oidctest.Issuer) *OIDCProvider {
+	return NewOIDCProvider(OIDCProviderConfig{
+		Name:         "google",
+		Issuer:       issuer.URL,
+		ClientID:     issuer.ClientID,
+		ClientSecret: issuer.ClientSecret,
+		RedirectURL:  testRedirectURL,
+	}, nil)
+}
+
+// signIn runs the authorization code flow for user and returns the result
+// of the code exchange
+func signIn(t *testing.T, p *OIDCProvider, issuer *oidctest.Issuer, user oidctest.User) (*OIDCIdentity, error) {
+	t.Helper()
+	ctx := context.Background()
+	a, err := p.Authorize(ctx)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	code, state, err := issuer.Approve(a.URL, user)
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if state != a.State {
+		t.Fatalf("state = %q, want %q", state, a.State)
+	}
+	return p.Exchange(ctx, code, a.CodeVerifier, a.Nonce)
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	issuer := oidctest.NewIssuer("chainforge-web", "s3cret")
+	defer issuer.Close()
+	p := newTestOIDCProvider(issuer)
+
+	a, err := p.Authorize(context.Background())
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	u, _ := url.Parse(a.URL)
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("redirect_uri") != testRedirectURL || q.Get("scope") != "openid email profile" {
+		t.Errorf("authorization URL = %s", a.URL)
+	}
+	if q.Get("code_challenge") != PKCEChallenge(a.CodeVerifier) || q.Get("nonce") != a.Nonce {
+		t.Errorf("authorization URL does not carry the PKCE challenge and nonce: %s", a.URL)
+	}
+
+	// Apple sends email_verified as a string
+	issuer.Claims = func(c jwt.MapClaims) { c["email_verified"] = "true" }
+	identity, err := signIn(t, p, issuer, testOIDCUser)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := OIDCIdentity{
+		Subject:       testOIDCUser.Subject,
+		Email:         testOIDCUser.Email,
+		EmailVerified: true,
+		GivenName:     "Ada",
+		FamilyName:    "Lovelace",
+	}
+	if *identity != want {
+		t.Errorf("identity = %+v, want %+v", *identity, want)
+	}
+}
+
+func TestOIDCRejectsUntrustedTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims func(jwt.MapClaims)
+	}{
+		{"wrong nonce", func(c jwt.MapClaims) { c["nonce"] = "replayed" }},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "another-client" }},
+		{"other authorized party", func(c jwt.MapClaims) {
+			c["aud"] = []string{"chainforge-web", "another-client"}
+			c["azp"] = "another-client"
+		}},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{"no subject", func(c jwt.MapClaims) { delete(c, "sub") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := oidctest.NewIssuer("chainforge-web", "s3cret")
+			defer issuer.Close()
+			issuer.Claims = tt.claims
+
+			_, err := signIn(t, newTestOIDCProvider(issuer), issuer, testOIDCUser)
+			if !errors.Is(err, ErrInvalidOIDCResponse) {
+				t.Fatalf("err = %v, want ErrInvalidOIDCResponse", err)
+			}
+		})
+	}
+}
+
+func TestOIDCRejectsWrongCodeVerifier(t *testing.T) {
+	issuer := oidctest.NewIssuer("chainforge-web", "s3cret")
+	defer issuer.Close()
+	p := newTestOIDCProvider(issuer)
+	ctx := context.Background()
+
+	a, _ := p.Authorize(ctx)
+	code, _, err := issuer.Approve(a.URL, testOIDCUser)
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	other, _ := p.Authorize(ctx)
+	if _, err := p.Exchange(ctx, code, other.CodeVerifier, a.Nonce); !errors.Is(err, ErrInvalidOIDCResponse) {
+		t.Fatalf("wrong verifier: err = %v, want ErrInvalidOIDCResponse", err)
+	}
+	// The code was spent by the failed attempt
+	if _, err := p.Exchange(ctx, code, a.CodeVerifier, a.Nonce); !errors.Is(err, ErrInvalidOIDCResponse) {
+		t.Fatalf("reused code: err = %v, want ErrInvalidOIDCResponse", err)
+	}
+}
+
+func TestOIDCRefetchesRotatedKeys(t *testing.T) {
+	issuer := oidctest.NewIssuer("chainforge-web", "s3cret")
+	defer issuer.Close()
+	p := newTestOIDCProvider(issuer)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	if _, err := signIn(t, p, issuer, testOIDCUser); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if _, err := signIn(t, p, issuer, testOIDCUser); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if n := issuer.KeyFetches(); n != 1 {
+		t.Fatalf("JWKS fetched %d times, want the cached set reused", n)
+	}
+
+	// A key the client has not seen is looked up once the refresh interval
+	// has passed
+	issuer.RotateKey()
+	if _, err := signIn(t, p, issuer, testOIDCUser); !errors.Is(err, ErrInvalidOIDCResponse) {
+		t.Fatalf("unknown key within refresh interval: err = %v", err)
+	}
+	now = now.Add(oidcKeyRefreshInterval)
+	if _, err := signIn(t, p, issuer, testOIDCUser); err != nil {
+		t.Fatalf("Exchange with rotated key: %v", err)
+	}
+	if n := issuer.KeyFetches(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", n)
+	}
+}
+
+func TestJWKPublicKeyRejectsWeakKeys(t *testing.T) {
+	weak := JWK{KeyType: "RSA", KeyID: "weak", N: EncodeBase64URL(make([]byte, 128)), E: "AQAB"}
+	if _, err := weak.PublicKey(); err == nil {
+		t.Error("accepted a 1024-bit RSA key")
+	}
+	offCurve := JWK{KeyType: "EC", Curve: "P-256", X: EncodeBase64URL(make([]byte, 32)), Y: EncodeBase64URL(make([]byte, 32))}
+	if _, err := offCurve.PublicKey(); err == nil {
+		t.Error("accepted a point that is not on P-256")
+	}
+}
@@ -4,15 +4,11 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
-	"strings"
-	"unicode"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 const (
-	// BcryptCost defines the cost parameter for bcrypt hashing
-	// 12 provides a good balance between security and performance
+	// BcryptCost defines the cost parameter for bcrypt hashing when bcrypt
+	// is configured. 12 provides a good balance between security and performance
 	BcryptCost = 12
 
 	// MinPasswordLength defines the minimum password length
@@ -47,7 +43,8 @@ func (ps PasswordStrength) String() string {
 	}
 }
 
-// PasswordValidationResult contains password validation information
+// PasswordValidationResult contains password validation information. Score
+// grows with the log of the estimated number of guesses, from 0 to 100.
 type PasswordValidationResult struct {
 	IsValid    bool             `json:"is_valid"`
 	Strength   PasswordStrength `json:"strength"`
@@ -56,27 +53,24 @@ type PasswordValidationResult struct {
 	Suggestions []string        `json:"suggestions,omitempty"`
 }
 
-// HashPassword hashes a password using bcrypt
-func HashPassword(password string) (string, error) {
-	if len(password) < MinPasswordLength {
-		return "", fmt.Errorf("password must be at least %d characters long", MinPasswordLength)
-	}
-
-	if len(password) > MaxPasswordLength {
-		return "", fmt.Errorf("password must be no more than %d characters long", MaxPasswordLength)
-	}
-
-	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
-	if err != nil {
-		return "", fmt.Errorf("failed to hash password: %w", err)
-	}
+// defaultHasher hashes passwords for the package-level functions
+var defaultHasher, _ = NewPasswordHasher(DefaultPasswordHashConfig)
 
-	return string(hashedBytes), nil
+// HashPassword hashes a password with the default argon2id parameters
+func HashPassword(password string) (string, error) {
+	return defaultHasher.Hash(password)
 }
 
-// VerifyPassword verifies a password against its hash
+// VerifyPassword verifies a password against its argon2id or bcrypt hash
 func VerifyPassword(password, hashedPassword string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	_, err := defaultHasher.Verify(password, hashedPassword)
+	return err
+}
+
+// RejectPassword takes as long as VerifyPassword against a real hash and
+// always fails
+func RejectPassword(password string) error {
+	return defaultHasher.Reject(password)
 }
 
 // IsPasswordValid checks if a password meets basic requirements
@@ -84,8 +78,11 @@ func IsPasswordValid(password string) bool {
 	return len(password) >= MinPasswordLength && len(password) <= MaxPasswordLength
 }
 
-// ValidatePassword performs comprehensive password validation
-func ValidatePassword(password string) PasswordValidationResult {
+// ValidatePassword checks a password's length, rejects passwords found in
+// breached password lists and estimates how many guesses it would take to
+// find. userInputs, such as the user's email address and name, are among
+// the first words an attacker tries.
+func ValidatePassword(password string, userInputs ...string) PasswordValidationResult {
 	result := PasswordValidationResult{
 		IsValid:     true,
 		Errors:      []string{},
@@ -105,165 +102,19 @@ func ValidatePassword(password string) PasswordValidationResult {
 		return result
 	}
 
-	// Calculate strength and score
-	result.Strength, result.Score = calculatePasswordStrength(password)
-
-	// Add suggestions based on strength
-	if result.Strength == PasswordWeak {
-		result.Suggestions = append(result.Suggestions, "Consider using a mix of uppercase, lowercase, numbers, and special characters")
-	}
-
-	if result.Strength <= PasswordMedium {
-		result.Suggestions = append(result.Suggestions, "Consider making your password longer")
-	}
-
-	return result
-}
-
-// calculatePasswordStrength calculates password strength and returns a score
-func calculatePasswordStrength(password string) (PasswordStrength, int) {
-	score := 0
-	
-	// Length scoring
-	length := len(password)
-	if length >= 8 {
-		score += 10
-	}
-	if length >= 12 {
-		score += 10
-	}
-	if length >= 16 {
-		score += 10
-	}
-	if length >= 20 {
-		score += 10
-	}
-
-	// Character variety scoring
-	hasLower := false
-	hasUpper := false
-	hasNumber := false
-	hasSpecial := false
-	
-	for _, char := range password {
-		switch {
-		case unicode.IsLower(char):
-			hasLower = true
-		case unicode.IsUpper(char):
-			hasUpper = true
-		case unicode.IsDigit(char):
-			hasNumber = true
-		case unicode.IsPunct(char) || unicode.IsSymbol(char):
-			hasSpecial = true
-		}
-	}
-
-	// Award points for character variety
-	if hasLower {
-		score += 5
-	}
-	if hasUpper {
-		score += 5
-	}
-	if hasNumber {
-		score += 5
-	}
-	if hasSpecial {
-		score += 10
-	}
-
-	// Bonus for having all character types
-	if hasLower && hasUpper && hasNumber && hasSpecial {
-		score += 10
-	}
-
-	// Penalty for common patterns
-	if isCommonPattern(password) {
-		score -= 20
-	}
-
-	// Ensure score is not negative
-	if score < 0 {
-		score = 0
-	}
-
-	// Determine strength based on score
+	estimate := estimateStrength(password, userInputs)
+	result.Strength, result.Score = estimate.strength(), estimate.percent()
 	switch {
-	case score >= 70:
-		return PasswordVeryStrong, score
-	case score >= 50:
-		return PasswordStrong, score
-	case score >= 30:
-		return PasswordMedium, score
-	default:
-		return PasswordWeak, score
-	}
-}
-
-// isCommonPattern checks for common weak patterns
-func isCommonPattern(password string) bool {
-	commonPatterns := []string{
-		"password", "123456", "qwerty", "abc123", "password123",
-		"admin", "letmein", "welcome", "monkey", "dragon",
-		"111111", "123123", "1234567890", "qwertyuiop",
-	}
-
-	lowPassword := strings.ToLower(password)
-	for _, pattern := range commonPatterns {
-		if strings.Contains(lowPassword, pattern) {
-			return true
-		}
-	}
-
-	// Check for simple sequences
-	if isSequence(password) {
-		return true
-	}
-
-	// Check for repeated characters
-	if hasRepeatedChars(password) {
-		return true
-	}
-
-	return false
-}
-
-// isSequence checks if password contains simple sequences
-func isSequence(password string) bool {
-	sequences := []string{
-		"abcdefghijklmnopqrstuvwxyz",
-		"zyxwvutsrqponmlkjihgfedcba",
-		"1234567890",
-		"0987654321",
-		"qwertyuiop",
-		"poiuytrewq",
-	}
-
-	lowPassword := strings.ToLower(password)
-	for _, seq := range sequences {
-		for i := 0; i <= len(seq)-4; i++ {
-			if strings.Contains(lowPassword, seq[i:i+4]) {
-				return true
-			}
-		}
-	}
-
-	return false
-}
-
-// hasRepeatedChars checks if password has too many repeated characters
-func hasRepeatedChars(password string) bool {
-	if len(password) < 4 {
-		return false
-	}
-
-	for i := 0; i <= len(password)-4; i++ {
-		if password[i] == password[i+1] && password[i] == password[i+2] && password[i] == password[i+3] {
-			return true
-		}
+	case estimate.breached:
+		result.IsValid = false
+		result.Errors = append(result.Errors, "This password appears in lists of breached passwords")
+	case estimate.score == 0 && len(password) >= MinPasswordLength:
+		result.IsValid = false
+		result.Errors = append(result.Errors, "Password is too easy to guess")
 	}
+	result.Suggestions = append(result.Suggestions, estimate.suggestions()...)
 
-	return false
+	return result
 }
 
 // GenerateSecurePassword generates a cryptographically secure random password
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms
+const (
+	HashArgon2id = "argon2id"
+	HashBcrypt   = "bcrypt"
+)
+
+const (
+	// argon2SaltLength and argon2KeyLength are the sizes in bytes of the
+	// salt and derived key of new argon2id hashes
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+	// bcryptMaxLength is the longest password bcrypt can hash; it would
+	// ignore anything past it
+	bcryptMaxLength = 72
+)
+
+var (
+	// ErrPasswordMismatch is returned when a password does not match a hash
+	ErrPasswordMismatch = errors.New("password does not match")
+	// ErrUnsupportedHash is returned for a stored hash in an unknown format
+	ErrUnsupportedHash = errors.New("unsupported password hash format")
+)
+
+// PasswordHashConfig chooses how new passwords are hashed. Argon2Memory is
+// in KiB.
+type PasswordHashConfig struct {
+	Algorithm         string
+	BcryptCost        int
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+// DefaultPasswordHashConfig hashes with argon2id using 64 MiB, three passes
+// and two lanes
+var DefaultPasswordHashConfig = PasswordHashConfig{
+	Algorithm:         HashArgon2id,
+	BcryptCost:        BcryptCost,
+	Argon2Memory:      64 * 1024,
+	Argon2Iterations:  3,
+	Argon2Parallelism: 2,
+}
+
+// PasswordHasher hashes passwords into PHC strings such as
+// "$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>" and verifies them against
+// hashes of either supported algorithm
+type PasswordHasher struct {
+	cfg PasswordHashConfig
+
+	rejectOnce sync.Once
+	rejectHash string
+}
+
+// NewPasswordHasher creates a hasher for cfg
+func NewPasswordHasher(cfg PasswordHashConfig) (*PasswordHasher, error) {
+	switch cfg.Algorithm {
+	case HashArgon2id:
+		if cfg.Argon2Memory < 8*uint32(cfg.Argon2Parallelism) || cfg.Argon2Iterations < 1 || cfg.Argon2Parallelism < 1 {
+			return nil, fmt.Errorf("argon2id needs at least one pass, one lane and 8 KiB of memory per lane")
+		}
+	case HashBcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", cfg.Algorithm)
+	}
+	return &PasswordHasher{cfg: cfg}, nil
+}
+
+// Hash hashes a password with the configured algorithm. Passwords too long
+// for bcrypt are hashed with argon2id even when bcrypt is configured.
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters long", MinPasswordLength)
+	}
+	if len(password) > MaxPasswordLength {
+		return "", fmt.Errorf("password must be no more than %d characters long", MaxPasswordLength)
+	}
+	return h.hash(password)
+}
+
+func (h *PasswordHasher) hash(password string) (string, error) {
+	if h.algorithmFor(password) == HashBcrypt {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash password: %w", err)
+		}
+		return string(hashed), nil
+	}
+
+	params := h.argon2Params()
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	params.key = argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, argon2KeyLength)
+	params.salt = salt
+	return params.String(), nil
+}
+
+// Verify checks a password against a stored hash. needsRehash reports that
+// the password matched but the hash uses other parameters or another
+// algorithm than the configured ones, so it should be replaced with Hash.
+func (h *PasswordHasher) Verify(password, hash string) (needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		stored, err := parseArgon2Hash(hash)
+		if err != nil {
+			return false, err
+		}
+		key := argon2.IDKey([]byte(password), stored.salt, stored.iterations, stored.memory, stored.parallelism, uint32(len(stored.key)))
+		if subtle.ConstantTimeCompare(key, stored.key) != 1 {
+			return false, ErrPasswordMismatch
+		}
+		current := h.argon2Params()
+		return h.algorithmFor(password) != HashArgon2id || !stored.sameCost(current), nil
+
+	case strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$"):
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, ErrPasswordMismatch
+			}
+			return false, fmt.Errorf("%w: %v", ErrUnsupportedHash, err)
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrUnsupportedHash, err)
+		}
+		return h.algorithmFor(password) != HashBcrypt || cost != h.cfg.BcryptCost || len(password) > bcryptMaxLength, nil
+
+	default:
+		return false, ErrUnsupportedHash
+	}
+}
+
+// Reject takes as long as Verify against a current hash and always fails.
+// Signing in to an unknown email or an account without a password calls it
+// so response times do not reveal which emails exist.
+func (h *PasswordHasher) Reject(password string) error {
+	h.rejectOnce.Do(func() {
+		// The stand-in is hashed from random bytes no one can type
+		secret, err := GenerateSecretKey(32)
+		if err == nil {
+			h.rejectHash, _ = h.hash(secret)
+		}
+	})
+	if h.rejectHash != "" {
+		h.Verify(password, h.rejectHash)
+	}
+	return ErrPasswordMismatch
+}
+
+// algorithmFor returns the algorithm new hashes of password use
+func (h *PasswordHasher) algorithmFor(password string) string {
+	if h.cfg.Algorithm == HashBcrypt && len(password) <= bcryptMaxLength {
+		return HashBcrypt
+	}
+	return HashArgon2id
+}
+
+// argon2Params returns the configured argon2id parameters, falling back to
+// the defaults when bcrypt is configured
+func (h *PasswordHasher) argon2Params() argon2Hash {
+	cfg := h.cfg
+	if cfg.Algorithm != HashArgon2id {
+		cfg = DefaultPasswordHashConfig
+	}
+	return argon2Hash{memory: cfg.Argon2Memory, iterations: cfg.Argon2Iterations, parallelism: cfg.Argon2Parallelism}
+}
+
+// argon2Hash is a parsed argon2id PHC string
+type argon2Hash struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+// String formats the hash as a PHC string
+func (a argon2Hash) String() string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, a.memory, a.iterations, a.parallelism,
+		base64.RawStdEncoding.EncodeToString(a.salt), base64.RawStdEncoding.EncodeToString(a.key))
+}
+
+// sameCost reports whether two hashes were made with the same parameters
+func (a argon2Hash) sameCost(b argon2Hash) bool {
+	return a.memory == b.memory && a.iterations == b.iterations && a.parallelism == b.parallelism &&
+		len(a.key) == argon2KeyLength && len(a.salt) == argon2SaltLength
+}
+
+// parseArgon2Hash parses an argon2id PHC string
+func parseArgon2Hash(hash string) (argon2Hash, error) {
+	var a argon2Hash
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != HashArgon2id {
+		return a, ErrUnsupportedHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return a, ErrUnsupportedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &a.memory, &a.iterations, &a.parallelism); err != nil {
+		return a, ErrUnsupportedHash
+	}
+	salt, err1 := base64.RawStdEncoding.DecodeString(parts[4])
+	key, err2 := base64.RawStdEncoding.DecodeString(parts[5])
+	if err1 != nil || err2 != nil || len(key) == 0 || a.iterations < 1 || a.parallelism < 1 {
+		return a, ErrUnsupportedHash
+	}
+	a.salt, a.key = salt, key
+	return a, nil
+}
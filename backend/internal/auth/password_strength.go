@@ -0,0 +1,474 @@
+package auth
+
+import (
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"chainforge/internal/auth/breached"
+)
+
+// The strength estimate follows zxcvbn: the password is split into pieces
+// an attacker would guess as a whole (breached passwords, the user's own
+// name and email, sequences, repeats, keyboard runs and years), the rest is
+// brute forced, and the split needing the fewest guesses counts.
+
+// matchKind is the kind of pattern a piece of a password matched
+type matchKind int
+
+const (
+	matchBruteforce matchKind = iota
+	matchBreached
+	matchUserInput
+	matchSequence
+	matchRepeat
+	matchKeyboard
+	matchYear
+)
+
+// passwordMatch is a pattern found at runes [i, j) of a password
+type passwordMatch struct {
+	kind    matchKind
+	i, j    int
+	guesses float64
+}
+
+// strengthEstimate is how many guesses a password would take to find
+type strengthEstimate struct {
+	guesses  float64
+	score    int // 0 to 4, as in zxcvbn
+	matches  []passwordMatch
+	breached bool
+}
+
+const (
+	bruteforceCardinality        = 10
+	minSubmatchGuessesSingleChar = 10
+	minSubmatchGuessesMultiChar  = 50
+	minYearSpace                 = 20
+	// minMatchLength is the shortest breached password or name matched
+	// inside a longer password
+	minMatchLength = 3
+)
+
+// l33tTable undoes common character substitutions
+var l33tTable = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '{': 'c', '3': 'e', '6': 'g',
+	'1': 'i', '!': 'i', '|': 'i', '0': 'o', '$': 's', '5': 's', '7': 't',
+	'+': 't', '2': 'z', '%': 'x',
+}
+
+// keyboardRows is a US keyboard, each row shifted half a key right of the
+// one above
+var keyboardRows = []string{"1234567890-=", "qwertyuiop[]", "asdfghjkl;'", "zxcvbnm,./"}
+
+const (
+	keyboardStartingPositions = 47
+	keyboardAverageDegree     = 4.6
+)
+
+// keyboardKeys maps each key to its row and column
+var keyboardKeys = func() map[rune][2]int {
+	keys := map[rune][2]int{}
+	for r, row := range keyboardRows {
+		for c, k := range row {
+			keys[k] = [2]int{r, c}
+		}
+	}
+	return keys
+}()
+
+// estimateStrength estimates how many guesses password would take, with
+// userInputs among the first words tried
+func estimateStrength(password string, userInputs []string) strengthEstimate {
+	pw := []rune(password)
+	lower := make([]rune, len(pw))
+	unleet := make([]rune, len(pw))
+	for i, r := range pw {
+		lower[i] = unicode.ToLower(r)
+		unleet[i] = lower[i]
+		if sub, ok := l33tTable[lower[i]]; ok {
+			unleet[i] = sub
+		}
+	}
+
+	var matches []passwordMatch
+	matches = append(matches, dictionaryMatches(pw, lower, unleet, userTokens(userInputs))...)
+	matches = append(matches, sequenceMatches(pw)...)
+	matches = append(matches, repeatMatches(pw, userInputs)...)
+	matches = append(matches, keyboardMatches(lower)...)
+	matches = append(matches, yearMatches(pw)...)
+
+	estimate := cheapestSplit(len(pw), matches)
+	_, inLower := breached.Rank(string(lower))
+	_, inUnleet := breached.Rank(string(unleet))
+	estimate.breached = len(pw) > 0 && (inLower || inUnleet)
+	return estimate
+}
+
+// cheapestSplit picks the sequence of matches and brute forced gaps
+// covering n runes that needs the fewest guesses. Like zxcvbn it charges
+// for the order of the pieces, but not a flat amount for each extra one,
+// so passwords built from a few guessable pieces stay weak.
+func cheapestSplit(n int, matches []passwordMatch) strengthEstimate {
+	if n == 0 {
+		return strengthEstimate{guesses: 1}
+	}
+	byEnd := make([][]passwordMatch, n+1)
+	for _, m := range matches {
+		if m.j-m.i < n {
+			m.guesses = math.Max(m.guesses, minSubmatchGuesses(m.j-m.i))
+		}
+		byEnd[m.j] = append(byEnd[m.j], m)
+	}
+
+	// best[k][j] is the smallest product of k pieces covering runes [0, j)
+	best := make([][]float64, n+1)
+	back := make([][]passwordMatch, n+1)
+	for k := range best {
+		best[k] = make([]float64, n+1)
+		back[k] = make([]passwordMatch, n+1)
+		for j := range best[k] {
+			best[k][j] = math.Inf(1)
+		}
+	}
+	best[0][0] = 1
+	for j := 1; j <= n; j++ {
+		candidates := byEnd[j]
+		for i := 0; i < j; i++ {
+			guesses := math.Pow(bruteforceCardinality, float64(j-i))
+			if j-i < n {
+				guesses = math.Max(guesses, minSubmatchGuesses(j-i)+1)
+			}
+			candidates = append(candidates, passwordMatch{kind: matchBruteforce, i: i, j: j, guesses: guesses})
+		}
+		for _, m := range candidates {
+			for k := 1; k <= j; k++ {
+				if g := best[k-1][m.i] * m.guesses; g < best[k][j] {
+					best[k][j], back[k][j] = g, m
+				}
+			}
+		}
+	}
+
+	estimate := strengthEstimate{guesses: math.Inf(1)}
+	bestK := 0
+	for k := 1; k <= n; k++ {
+		g := factorial(k) * best[k][n]
+		if g < estimate.guesses {
+			estimate.guesses, bestK = g, k
+		}
+	}
+	for k, j := bestK, n; k > 0; k-- {
+		m := back[k][j]
+		estimate.matches = append([]passwordMatch{m}, estimate.matches...)
+		j = m.i
+	}
+	estimate.score = guessesScore(estimate.guesses)
+	return estimate
+}
+
+// dictionaryMatches finds breached passwords and the user's own tokens
+// inside the password, also after undoing l33t substitutions
+func dictionaryMatches(pw, lower, unleet []rune, tokens map[string]int) []passwordMatch {
+	var matches []passwordMatch
+	for i := 0; i < len(pw); i++ {
+		for j := i + minMatchLength; j <= len(pw); j++ {
+			substitutions := 0
+			for _, r := range lower[i:j] {
+				if _, ok := l33tTable[r]; ok {
+					substitutions++
+				}
+			}
+			variants := [][]rune{lower[i:j]}
+			if substitutions > 0 {
+				variants = append(variants, unleet[i:j])
+			}
+			for v, word := range variants {
+				factor := uppercaseVariations(pw[i:j])
+				if v == 1 {
+					factor *= math.Pow(2, float64(substitutions))
+				}
+				if rank, ok := tokens[string(word)]; ok {
+					matches = append(matches, passwordMatch{kind: matchUserInput, i: i, j: j, guesses: float64(rank) * factor})
+				}
+				if rank, ok := breached.Rank(string(word)); ok {
+					matches = append(matches, passwordMatch{kind: matchBreached, i: i, j: j, guesses: float64(rank) * factor})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// userTokens lowercases the user's email and names, whole and split into
+// words, ranked in the order given
+func userTokens(userInputs []string) map[string]int {
+	tokens := map[string]int{}
+	add := func(t string) {
+		if len([]rune(t)) >= minMatchLength {
+			if _, ok := tokens[t]; !ok {
+				tokens[t] = len(tokens) + 1
+			}
+		}
+	}
+	for _, input := range userInputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		local, _, _ := strings.Cut(input, "@")
+		add(input)
+		add(local)
+		for _, t := range strings.FieldsFunc(input, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			add(t)
+		}
+	}
+	return tokens
+}
+
+// sequenceMatches finds runs like abcd, 9876 or XYZ
+func sequenceMatches(pw []rune) []passwordMatch {
+	var matches []passwordMatch
+	for i := 0; i < len(pw)-1; {
+		delta := pw[i+1] - pw[i]
+		j := i + 1
+		for (delta == 1 || delta == -1) && j < len(pw) && pw[j]-pw[j-1] == delta && sameClass(pw[j], pw[i]) {
+			j++
+		}
+		if j-i >= 3 {
+			base := 26.0
+			switch first := unicode.ToLower(pw[i]); {
+			case strings.ContainsRune("az019", first):
+				base = 4
+			case unicode.IsDigit(first):
+				base = 10
+			}
+			if delta < 0 {
+				base *= 2
+			}
+			matches = append(matches, passwordMatch{kind: matchSequence, i: i, j: j, guesses: base * float64(j-i)})
+			i = j - 1
+			continue
+		}
+		i++
+	}
+	return matches
+}
+
+// sameClass reports whether two runes are both lowercase, uppercase or
+// digits
+func sameClass(a, b rune) bool {
+	return unicode.IsLower(a) && unicode.IsLower(b) || unicode.IsUpper(a) && unicode.IsUpper(b) ||
+		unicode.IsDigit(a) && unicode.IsDigit(b)
+}
+
+// repeatMatches finds a character or group of characters repeated in a
+// row, like aaaa or abcabc
+func repeatMatches(pw []rune, userInputs []string) []passwordMatch {
+	var matches []passwordMatch
+	for i := 0; i < len(pw); i++ {
+		for unit := 1; i+2*unit <= len(pw); unit++ {
+			count := 1
+			for i+(count+1)*unit <= len(pw) && string(pw[i+count*unit:i+(count+1)*unit]) == string(pw[i:i+unit]) {
+				count++
+			}
+			if count < 2 || unit == 1 && count < 3 {
+				continue
+			}
+			base := estimateStrength(string(pw[i:i+unit]), userInputs).guesses
+			matches = append(matches, passwordMatch{kind: matchRepeat, i: i, j: i + count*unit, guesses: base * float64(count)})
+		}
+	}
+	return matches
+}
+
+// keyboardMatches finds runs of adjacent keys like qwerty or 1qaz
+func keyboardMatches(lower []rune) []passwordMatch {
+	var matches []passwordMatch
+	for i := 0; i < len(lower)-1; {
+		j, turns := i+1, 0
+		var direction [2]int
+		for j < len(lower) {
+			d, ok := keyboardStep(lower[j-1], lower[j])
+			if !ok {
+				break
+			}
+			if j > i+1 && d != direction {
+				turns++
+			}
+			direction = d
+			j++
+		}
+		if j-i >= 4 {
+			matches = append(matches, passwordMatch{kind: matchKeyboard, i: i, j: j, guesses: keyboardGuesses(j-i, turns)})
+			i = j - 1
+			continue
+		}
+		i++
+	}
+	return matches
+}
+
+// keyboardStep returns the direction from key a to key b if they are next
+// to each other
+func keyboardStep(a, b rune) ([2]int, bool) {
+	ka, ok1 := keyboardKeys[a]
+	kb, ok2 := keyboardKeys[b]
+	if !ok1 || !ok2 {
+		return [2]int{}, false
+	}
+	d := [2]int{kb[0] - ka[0], kb[1] - ka[1]}
+	switch d {
+	case [2]int{0, -1}, [2]int{0, 1}, [2]int{-1, 0}, [2]int{-1, 1}, [2]int{1, -1}, [2]int{1, 0}:
+		return d, true
+	}
+	return d, false
+}
+
+// keyboardGuesses counts the keyboard runs of the given length with up to
+// the given number of turns
+func keyboardGuesses(length, turns int) float64 {
+	guesses := 0.0
+	for i := 2; i <= length; i++ {
+		for t := 1; t <= min(turns+1, i-1); t++ {
+			guesses += binomial(i-1, t-1) * keyboardStartingPositions * math.Pow(keyboardAverageDegree, float64(t))
+		}
+	}
+	return guesses
+}
+
+// yearMatches finds years from 1900 to 2099
+func yearMatches(pw []rune) []passwordMatch {
+	var matches []passwordMatch
+	now := time.Now().Year()
+	for i := 0; i+4 <= len(pw); i++ {
+		s := string(pw[i : i+4])
+		if (strings.HasPrefix(s, "19") || strings.HasPrefix(s, "20")) && isDigits(s) {
+			year := int(s[0]-'0')*1000 + int(s[1]-'0')*100 + int(s[2]-'0')*10 + int(s[3]-'0')
+			space := math.Max(math.Abs(float64(year-now)), minYearSpace)
+			matches = append(matches, passwordMatch{kind: matchYear, i: i, j: i + 4, guesses: space})
+		}
+	}
+	return matches
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// uppercaseVariations counts the ways a lowercase word could have been
+// capitalized to give word
+func uppercaseVariations(word []rune) float64 {
+	upper, lower := 0, 0
+	for _, r := range word {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 1
+	}
+	// Capitalized, all caps or only the last letter capitalized
+	if lower == 0 || upper == 1 && (unicode.IsUpper(word[0]) || unicode.IsUpper(word[len(word)-1])) {
+		return 2
+	}
+	variations := 0.0
+	for k := 1; k <= min(upper, lower); k++ {
+		variations += binomial(upper+lower, k)
+	}
+	return variations
+}
+
+// minSubmatchGuesses is the least a piece of a longer password can cost
+func minSubmatchGuesses(length int) float64 {
+	if length == 1 {
+		return minSubmatchGuessesSingleChar
+	}
+	return minSubmatchGuessesMultiChar
+}
+
+// guessesScore maps guesses to zxcvbn's 0 to 4 score
+func guessesScore(guesses float64) int {
+	const delta = 5
+	switch {
+	case guesses < 1e3+delta:
+		return 0
+	case guesses < 1e6+delta:
+		return 1
+	case guesses < 1e8+delta:
+		return 2
+	case guesses < 1e10+delta:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func factorial(n int) float64 {
+	f := 1.0
+	for i := 2; i <= n; i++ {
+		f *= float64(i)
+	}
+	return f
+}
+
+func binomial(n, k int) float64 {
+	if k < 0 || k > n {
+		return 0
+	}
+	r := 1.0
+	for i := 1; i <= k; i++ {
+		r = r * float64(n-k+i) / float64(i)
+	}
+	return r
+}
+
+// strength maps the estimate to a PasswordStrength
+func (e strengthEstimate) strength() PasswordStrength {
+	switch e.score {
+	case 0, 1:
+		return PasswordWeak
+	case 2:
+		return PasswordMedium
+	case 3:
+		return PasswordStrong
+	default:
+		return PasswordVeryStrong
+	}
+}
+
+// percent grows with the log of the guesses, reaching 100 at 10^12
+func (e strengthEstimate) percent() int {
+	return int(math.Min(100, math.Round(math.Log10(e.guesses)*100/12)))
+}
+
+// suggestions explains what makes a weak password easy to guess
+func (e strengthEstimate) suggestions() []string {
+	if e.score > 2 {
+		return nil
+	}
+	messages := map[matchKind]string{
+		matchBreached:  "Avoid common passwords and words found in breached password lists",
+		matchUserInput: "Avoid using your name or email address",
+		matchSequence:  "Avoid sequences like abc or 6543",
+		matchRepeat:    "Avoid repeated words and characters",
+		matchKeyboard:  "Avoid keyboard patterns like qwerty or 1qaz",
+		matchYear:      "Avoid years that are associated with you",
+	}
+	var suggestions []string
+	seen := map[matchKind]bool{}
+	for _, m := range e.matches {
+		if msg, ok := messages[m.kind]; ok && !seen[m.kind] {
+			seen[m.kind] = true
+			suggestions = append(suggestions, msg)
+		}
+	}
+	return append(suggestions, "Add another word or two; uncommon words are better")
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePasswordRejectsBreachedPasswords(t *testing.T) {
+	for _, pw := range []string{"password", "P@ssw0rd", "Qwertyuiop", "1qaz2wsx3edc"} {
+		result := ValidatePassword(pw)
+		if result.IsValid || !strings.Contains(strings.Join(result.Errors, " "), "breached") {
+			t.Errorf("ValidatePassword(%q) = %+v, want rejected as breached", pw, result)
+		}
+		if result.Strength != PasswordWeak {
+			t.Errorf("ValidatePassword(%q).Strength = %s, want weak", pw, result.Strength)
+		}
+	}
+}
+
+func TestValidatePasswordRejectsPatterns(t *testing.T) {
+	for _, pw := range []string{"abcabcabcabc", "aaaaaaaaaaaa", "abcdefghij", "98765432"} {
+		if result := ValidatePassword(pw); result.IsValid {
+			t.Errorf("ValidatePassword(%q) = %+v, want rejected", pw, result)
+		}
+	}
+}
+
+func TestValidatePasswordAcceptsStrongPasswords(t *testing.T) {
+	for _, pw := range []string{"Correct-Horse-42", "kX9#vQ2!mP7z", "Battery-Staple-77"} {
+		result := ValidatePassword(pw)
+		if !result.IsValid || len(result.Errors) != 0 {
+			t.Errorf("ValidatePassword(%q) = %+v, want valid", pw, result)
+		}
+		if result.Strength < PasswordStrong || result.Score < 90 {
+			t.Errorf("ValidatePassword(%q) strength = %s (%d), want strong or better", pw, result.Strength, result.Score)
+		}
+	}
+}
+
+func TestValidatePasswordPenalizesUserInputs(t *testing.T) {
+	pw := "adalovelace1815"
+	alone := ValidatePassword(pw)
+	withName := ValidatePassword(pw, "ada@example.com", "Ada", "Lovelace")
+	if withName.Score >= alone.Score {
+		t.Errorf("score with the user's name = %d, want below %d", withName.Score, alone.Score)
+	}
+
+	result := ValidatePassword("Ada@Example.com", "ada@example.com", "Ada", "Lovelace")
+	if result.IsValid {
+		t.Fatalf("the user's own email was accepted: %+v", result)
+	}
+	if !strings.Contains(strings.Join(result.Suggestions, " "), "name or email") {
+		t.Errorf("suggestions = %q, want advice against using the name or email", result.Suggestions)
+	}
+}
+
+func TestValidatePasswordLength(t *testing.T) {
+	if result := ValidatePassword("x7#"); result.IsValid || len(result.Errors) != 1 {
+		t.Errorf("short password: %+v", result)
+	}
+	if result := ValidatePassword(strings.Repeat("x", MaxPasswordLength+1)); result.IsValid {
+		t.Errorf("long password was accepted")
+	}
+}
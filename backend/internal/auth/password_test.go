@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheapArgon2 keeps the argon2id tests fast
+var cheapArgon2 = PasswordHashConfig{Algorithm: HashArgon2id, Argon2Memory: 64, Argon2Iterations: 1, Argon2Parallelism: 1}
+
+func newTestHasher(t *testing.T, cfg PasswordHashConfig) *PasswordHasher {
+	t.Helper()
+	h, err := NewPasswordHasher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestPasswordHasherArgon2id(t *testing.T) {
+	h := newTestHasher(t, cheapArgon2)
+	hash, err := h.Hash("Correct-Horse-42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("hash = %q, want an argon2id PHC string", hash)
+	}
+	if rehash, err := h.Verify("Correct-Horse-42", hash); err != nil || rehash {
+		t.Fatalf("Verify = %v, %v; want a match needing no rehash", rehash, err)
+	}
+	if _, err := h.Verify("Correct-Horse-43", hash); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("wrong password: err = %v, want ErrPasswordMismatch", err)
+	}
+
+	// Stronger parameters call for a rehash
+	stronger := cheapArgon2
+	stronger.Argon2Iterations = 2
+	if rehash, err := newTestHasher(t, stronger).Verify("Correct-Horse-42", hash); err != nil || !rehash {
+		t.Fatalf("new parameters: Verify = %v, %v; want a match needing a rehash", rehash, err)
+	}
+}
+
+func TestPasswordHasherUpgradesBcrypt(t *testing.T) {
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("Correct-Horse-42"), bcrypt.MinCost)
+	h := newTestHasher(t, cheapArgon2)
+	if rehash, err := h.Verify("Correct-Horse-42", string(legacy)); err != nil || !rehash {
+		t.Fatalf("bcrypt hash: Verify = %v, %v; want a match needing a rehash", rehash, err)
+	}
+	if _, err := h.Verify("Correct-Horse-43", string(legacy)); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("wrong password: err = %v, want ErrPasswordMismatch", err)
+	}
+
+	// Configured for bcrypt, a hash of the same cost is current
+	b := newTestHasher(t, PasswordHashConfig{Algorithm: HashBcrypt, BcryptCost: bcrypt.MinCost})
+	if rehash, err := b.Verify("Correct-Horse-42", string(legacy)); err != nil || rehash {
+		t.Fatalf("bcrypt configured: Verify = %v, %v; want a match needing no rehash", rehash, err)
+	}
+}
+
+func TestPasswordHasherBcryptLongPasswords(t *testing.T) {
+	h := newTestHasher(t, PasswordHashConfig{Algorithm: HashBcrypt, BcryptCost: bcrypt.MinCost})
+	long := strings.Repeat("Correct-Horse-42", 6) // 96 bytes
+	hash, err := h.Hash(long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		t.Fatalf("hash = %q, want argon2id for a password bcrypt would truncate", hash)
+	}
+	if _, err := h.Verify(long[:80], hash); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatal("a different password with the same first 72 bytes matched")
+	}
+}
+
+func TestPasswordHasherRejectsMalformedHashes(t *testing.T) {
+	h := newTestHasher(t, cheapArgon2)
+	for _, hash := range []string{"", "plaintext", "$argon2id$v=19$m=64,t=1$c2FsdA$a2V5", "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5", "$argon2i$v=19$m=64,t=1,p=1$c2FsdA$a2V5"} {
+		if _, err := h.Verify("Correct-Horse-42", hash); !errors.Is(err, ErrUnsupportedHash) {
+			t.Errorf("Verify(%q): err = %v, want ErrUnsupportedHash", hash, err)
+		}
+	}
+}
+
+func TestNewPasswordHasherValidates(t *testing.T) {
+	for _, cfg := range []PasswordHashConfig{
+		{Algorithm: "md5"},
+		{Algorithm: HashBcrypt, BcryptCost: 40},
+		{Algorithm: HashArgon2id, Argon2Memory: 64, Argon2Iterations: 0, Argon2Parallelism: 1},
+		{Algorithm: HashArgon2id, Argon2Memory: 4, Argon2Iterations: 1, Argon2Parallelism: 1},
+	} {
+		if _, err := NewPasswordHasher(cfg); err == nil {
+			t.Errorf("NewPasswordHasher(%+v) accepted the configuration", cfg)
+		}
+	}
+}
+
+func TestRejectPassword(t *testing.T) {
+	if err := newTestHasher(t, cheapArgon2).Reject("chainforge-no-such-account"); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("Reject = %v, want ErrPasswordMismatch", err)
+	}
+	if err := RejectPassword("chainforge-no-such-account"); err == nil {
+		t.Fatal("RejectPassword accepted a password")
+	}
+}
@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokenRevocationStore records revoked tokens until they would have expired.
+// Implementations must be safe for concurrent use.
+type TokenRevocationStore interface {
+	// Revoke marks a token ID, or a family ID covering every token issued
+	// from one sign-in, as revoked until expiresAt
+	Revoke(ctx context.Context, id string, expiresAt time.Time) error
+
+	// RevokeAllForUser revokes every token issued to userID before issuedBefore
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID, issuedBefore time.Time) error
+
+	// IsRevoked reports whether the token described by claims was revoked by
+	// its ID, its family ID or a user-wide cutoff
+	IsRevoked(ctx context.Context, claims *Claims) (bool, error)
+
+	// Cleanup removes up to limit expired entries and returns how many it removed
+	Cleanup(ctx context.Context, limit int) (int, error)
+}
+
+// RevocationCutoff truncates t to the precision of the iat claim. Tokens
+// issued in the same second as the cutoff stay valid, so a token pair issued
+// right after revoking a user's tokens is not caught by the cutoff.
+func RevocationCutoff(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
+// issuedBefore reports whether claims were issued strictly before cutoff
+func issuedBefore(claims *Claims, cutoff time.Time) bool {
+	if claims.IssuedAt == nil {
+		return true
+	}
+	return claims.IssuedAt.Time.Before(cutoff)
+}
+
+const revocationShards = 32
+
+// revocationShard holds the entries whose key hashes to it
+type revocationShard struct {
+	mu      sync.RWMutex
+	tokens  map[string]time.Time        // token or family ID -> expiry
+	cutoffs map[uuid.UUID]revokedBefore // user -> issued-before cutoff
+}
+
+// revokedBefore is a user-wide cutoff and the time it can be forgotten
+type revokedBefore struct {
+	cutoff    time.Time
+	expiresAt time.Time
+}
+
+// MemoryRevocationStore is an in-memory TokenRevocationStore. Entries are
+// spread over shards with their own locks so checks on unrelated tokens do
+// not contend. Revocations are lost on restart.
+type MemoryRevocationStore struct {
+	shards    [revocationShards]revocationShard
+	retention time.Duration
+	next      atomic.Uint32
+	now       func() time.Time
+}
+
+// NewMemoryRevocationStore creates an in-memory revocation store. retention
+// is the longest lifetime of any token, which is how long user-wide cutoffs
+// are kept.
+func NewMemoryRevocationStore(retention time.Duration) *MemoryRevocationStore {
+	s := &MemoryRevocationStore{
+		retention: retention,
+		now:       func() time.Time { return time.Now().UTC() },
+	}
+	for i := range s.shards {
+		s.shards[i].tokens = make(map[string]time.Time)
+		s.shards[i].cutoffs = make(map[uuid.UUID]revokedBefore)
+	}
+	return s
+}
+
+// Revoke marks a token or family ID as revoked until expiresAt
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	shard := s.shard(id)
+	shard.mu.Lock()
+	if expiresAt.After(shard.tokens[id]) {
+		shard.tokens[id] = expiresAt.UTC()
+	}
+	shard.mu.Unlock()
+
+	s.sweep()
+	return nil
+}
+
+// RevokeAllForUser revokes every token issued to userID before issuedBefore
+func (s *MemoryRevocationStore) RevokeAllForUser(ctx context.Context, userID uuid.UUID, issuedBefore time.Time) error {
+	cutoff := RevocationCutoff(issuedBefore)
+	shard := s.shard(userID.String())
+	shard.mu.Lock()
+	if cutoff.After(shard.cutoffs[userID].cutoff) {
+		shard.cutoffs[userID] = revokedBefore{cutoff: cutoff, expiresAt: cutoff.Add(s.retention)}
+	}
+	shard.mu.Unlock()
+
+	s.sweep()
+	return nil
+}
+
+// IsRevoked reports whether the token described by claims was revoked
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, claims *Claims) (bool, error) {
+	now := s.now()
+	if s.revoked(claims.ID, now) {
+		return true, nil
+	}
+	if claims.FamilyID != uuid.Nil && s.revoked(claims.FamilyID.String(), now) {
+		return true, nil
+	}
+
+	shard := s.shard(claims.UserID.String())
+	shard.mu.RLock()
+	user, ok := shard.cutoffs[claims.UserID]
+	shard.mu.RUnlock()
+	return ok && now.Before(user.expiresAt) && issuedBefore(claims, user.cutoff), nil
+}
+
+// revoked reports whether id has an unexpired revocation
+func (s *MemoryRevocationStore) revoked(id string, now time.Time) bool {
+	shard := s.shard(id)
+	shard.mu.RLock()
+	expiresAt, ok := shard.tokens[id]
+	shard.mu.RUnlock()
+	return ok && now.Before(expiresAt)
+}
+
+// Cleanup removes up to limit expired entries, walking every shard
+func (s *MemoryRevocationStore) Cleanup(ctx context.Context, limit int) (int, error) {
+	removed := 0
+	for i := range s.shards {
+		if removed >= limit {
+			break
+		}
+		removed += s.shards[i].removeExpired(s.now(), limit-removed)
+	}
+	return removed, nil
+}
+
+// Count returns the number of revoked tokens and user cutoffs held
+func (s *MemoryRevocationStore) Count() int {
+	count := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.RLock()
+		count += len(shard.tokens) + len(shard.cutoffs)
+		shard.mu.RUnlock()
+	}
+	return count
+}
+
+// sweep clears expired entries from one shard per write, round robin, so
+// memory stays bounded without a background pass over everything
+func (s *MemoryRevocationStore) sweep() {
+	i := s.next.Add(1) % revocationShards
+	s.shards[i].removeExpired(s.now(), -1)
+}
+
+// shard returns the shard that owns key
+func (s *MemoryRevocationStore) shard(key string) *revocationShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.shards[h.Sum32()%revocationShards]
+}
+
+// removeExpired deletes up to limit expired entries; a negative limit removes all
+func (sh *revocationShard) removeExpired(now time.Time, limit int) int {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	removed := 0
+	for jti, expiresAt := range sh.tokens {
+		if removed == limit {
+			return removed
+		}
+		if !now.Before(expiresAt) {
+			delete(sh.tokens, jti)
+			removed++
+		}
+	}
+	for userID, user := range sh.cutoffs {
+		if removed == limit {
+			return removed
+		}
+		if !now.Before(user.expiresAt) {
+			delete(sh.cutoffs, userID)
+			removed++
+		}
+	}
+	return removed
+}
+
+var _ TokenRevocationStore = (*MemoryRevocationStore)(nil)
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func testClaims(userID uuid.UUID, jti string, issuedAt time.Time) *Claims {
+	return &Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       jti,
+			IssuedAt: jwt.NewNumericDate(issuedAt),
+		},
+	}
+}
+
+func TestMemoryRevocationStoreRevokesUntilExpiry(t *testing.T) {
+	store := NewMemoryRevocationStore(time.Hour)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	claims := testClaims(uuid.New(), "token-1", now)
+	if err := store.Revoke(ctx, claims.ID, now.Add(time.Minute)); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if revoked, _ := store.IsRevoked(ctx, claims); !revoked {
+		t.Fatal("revoked token is accepted")
+	}
+	if revoked, _ := store.IsRevoked(ctx, testClaims(claims.UserID, "token-2", now)); revoked {
+		t.Fatal("unrelated token is revoked")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if revoked, _ := store.IsRevoked(ctx, claims); revoked {
+		t.Error("entry outlived the token it revoked")
+	}
+	if removed, _ := store.Cleanup(ctx, 10); removed != 1 || store.Count() != 0 {
+		t.Errorf("Cleanup removed %d, %d left; want 1 and 0", removed, store.Count())
+	}
+}
+
+func TestMemoryRevocationStoreRevokesAllForUser(t *testing.T) {
+	store := NewMemoryRevocationStore(time.Hour)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+	userID := uuid.New()
+
+	if err := store.RevokeAllForUser(ctx, userID, now.Add(500*time.Millisecond)); err != nil {
+		t.Fatalf("RevokeAllForUser: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		claims   *Claims
+		expected bool
+	}{
+		{"issued earlier", testClaims(userID, "a", now.Add(-time.Second)), true},
+		{"issued in the same second", testClaims(userID, "b", now), false},
+		{"issued later", testClaims(userID, "c", now.Add(time.Second)), false},
+		{"other user", testClaims(uuid.New(), "d", now.Add(-time.Second)), false},
+	}
+	for _, tt := range tests {
+		if revoked, _ := store.IsRevoked(ctx, tt.claims); revoked != tt.expected {
+			t.Errorf("%s: revoked = %v, want %v", tt.name, revoked, tt.expected)
+		}
+	}
+
+	// An earlier cutoff never overrides a later one
+	if err := store.RevokeAllForUser(ctx, userID, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("RevokeAllForUser: %v", err)
+	}
+	if revoked, _ := store.IsRevoked(ctx, tests[0].claims); !revoked {
+		t.Error("earlier cutoff replaced the later one")
+	}
+
+	// Once every token it could match has expired the cutoff is dropped
+	now = now.Add(2 * time.Hour)
+	if revoked, _ := store.IsRevoked(ctx, tests[0].claims); revoked {
+		t.Error("cutoff outlived the retention period")
+	}
+}
+
+func TestMemoryRevocationStoreSweepsOnWrite(t *testing.T) {
+	store := NewMemoryRevocationStore(time.Hour)
+	now := time.Now().UTC()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 200; i++ {
+		store.Revoke(ctx, fmt.Sprintf("expired-%d", i), now.Add(time.Second))
+	}
+	now = now.Add(time.Minute)
+	// One write per shard is enough to clear every shard once
+	for i := 0; i < revocationShards; i++ {
+		store.Revoke(ctx, fmt.Sprintf("live-%d", i), now.Add(time.Hour))
+	}
+	if got := store.Count(); got != revocationShards {
+		t.Errorf("Count = %d, want only the %d live entries", got, revocationShards)
+	}
+}
+
+func TestMemoryRevocationStoreConcurrentUse(t *testing.T) {
+	store := NewMemoryRevocationStore(time.Hour)
+	ctx := context.Background()
+	userID := uuid.New()
+	expires := time.Now().Add(time.Hour)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				jti := fmt.Sprintf("%d-%d", w, i)
+				store.Revoke(ctx, jti, expires)
+				if revoked, _ := store.IsRevoked(ctx, testClaims(userID, jti, time.Now())); !revoked {
+					t.Errorf("token %s not revoked", jti)
+					return
+				}
+				if i%100 == 0 {
+					store.RevokeAllForUser(ctx, userID, time.Now())
+					store.Cleanup(ctx, 10)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if got := store.Count(); got != 8*500+1 {
+		t.Errorf("Count = %d, want %d", got, 8*500+1)
+	}
+}
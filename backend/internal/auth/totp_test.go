@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPMatchesRFC6238(t *testing.T) {
+	// Test vectors from RFC 6238 appendix B, truncated to six digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range vectors {
+		got, err := TOTPCode(secret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode: %v", err)
+		}
+		if got != want {
+			t.Errorf("TOTPCode at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestValidateTOTPAllowsOneStepOfDrift(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+
+	for _, offset := range []time.Duration{-TOTPPeriod, 0, TOTPPeriod} {
+		code, _ := TOTPCode(secret, now.Add(offset))
+		step, ok := ValidateTOTP(secret, code, now)
+		if !ok {
+			t.Errorf("code from %v away rejected", offset)
+		}
+		if want := now.Add(offset).Unix() / 30; step != want {
+			t.Errorf("step = %d, want %d", step, want)
+		}
+	}
+
+	stale, _ := TOTPCode(secret, now.Add(-2*TOTPPeriod))
+	if _, ok := ValidateTOTP(secret, stale, now); ok {
+		t.Error("code from two periods ago accepted")
+	}
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Error("short code accepted")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(TOTPProvisioningURI("JBSWY3DPEHPK3PXP", "ChainForge", "ada@example.com"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/ChainForge:ada@example.com" {
+		t.Errorf("uri = %s", uri)
+	}
+	q := uri.Query()
+	if q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "ChainForge" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("query = %v", q)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q is not XXXXX-XXXXX", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+
+	code := codes[0]
+	loose := strings.ToLower(strings.Replace(code, "-", " ", 1))
+	if HashRecoveryCode(loose) != HashRecoveryCode(code) {
+		t.Error("hash depends on case or separators")
+	}
+	if HashRecoveryCode(codes[1]) == HashRecoveryCode(code) {
+		t.Error("different codes hash the same")
+	}
+}
@@ -0,0 +1,351 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// WebAuthnTimeout is how long a browser waits for the user to complete a
+// WebAuthn ceremony
+const WebAuthnTimeout = 5 * time.Minute
+
+// COSE algorithm identifiers of the credential keys we accept, in order of
+// preference
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+// SupportedCOSEAlgorithms lists the algorithms offered to authenticators
+var SupportedCOSEAlgorithms = []int{COSEAlgES256, COSEAlgEdDSA, COSEAlgRS256}
+
+var (
+	// ErrInvalidWebAuthnResponse is returned when a browser's WebAuthn
+	// response fails verification
+	ErrInvalidWebAuthnResponse = errors.New("invalid WebAuthn response")
+
+	// ErrWebAuthnCounterRegressed is returned when an authenticator's
+	// signature counter did not increase, which suggests a cloned
+	// authenticator
+	ErrWebAuthnCounterRegressed = errors.New("WebAuthn signature counter did not increase")
+)
+
+// Authenticator data flags (WebAuthn §6.1)
+const (
+	authFlagUserPresent      = 0x01
+	authFlagUserVerified     = 0x04
+	authFlagAttestedCredData = 0x40
+)
+
+const webAuthnChallengeSize = 32
+
+// RelyingParty verifies WebAuthn registrations and assertions for one site.
+// Only user-verifying authenticators are accepted, so a passkey counts as
+// both factors of a sign-in.
+type RelyingParty struct {
+	ID      string   // Domain credentials are scoped to, e.g. "chainforge.app"
+	Name    string   // Shown by the browser while registering
+	Origins []string // Origins the frontend is served from
+}
+
+// NewRelyingParty creates a relying party
+func NewRelyingParty(id, name string, origins []string) *RelyingParty {
+	return &RelyingParty{ID: id, Name: name, Origins: origins}
+}
+
+// WebAuthnCredential is a public key credential created by an authenticator
+type WebAuthnCredential struct {
+	ID        []byte
+	PublicKey []byte // COSE_Key, CBOR encoded
+	SignCount uint32
+	AAGUID    []byte
+}
+
+// NewWebAuthnChallenge returns a random challenge for one ceremony
+func NewWebAuthnChallenge() ([]byte, error) {
+	challenge := make([]byte, webAuthnChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, fmt.Errorf("failed to generate WebAuthn challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// VerifyRegistration checks the response to navigator.credentials.create
+// against the challenge issued for it and returns the new credential.
+// Attestation is not required: "none" and self attestation are accepted,
+// and certificate chains in other formats are not checked against a
+// trust store.
+func (rp *RelyingParty) VerifyRegistration(challenge, clientDataJSON, attestationObject []byte) (*WebAuthnCredential, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, invalidWebAuthn("attestation object: %v", err)
+	}
+	attestation, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, invalidWebAuthn("attestation object is not a map")
+	}
+	format, _ := attestation["fmt"].(string)
+	rawAuthData, _ := attestation["authData"].([]byte)
+	statement, _ := attestation["attStmt"].(map[any]any)
+
+	data, err := rp.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if data.flags&authFlagAttestedCredData == 0 {
+		return nil, invalidWebAuthn("no attested credential data")
+	}
+	key, alg, err := parseCOSEKey(data.publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "none":
+		if len(statement) != 0 {
+			return nil, invalidWebAuthn(`"none" attestation with a statement`)
+		}
+	case "packed":
+		// Self attestation is signed by the credential key itself
+		if _, hasChain := statement["x5c"]; !hasChain {
+			sigAlg, _ := statement["alg"].(int64)
+			sig, _ := statement["sig"].([]byte)
+			if sigAlg != int64(alg) {
+				return nil, invalidWebAuthn("self attestation algorithm does not match the credential")
+			}
+			if err := verifyCOSESignature(key, alg, signedData(rawAuthData, clientDataJSON), sig); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &WebAuthnCredential{
+		ID:        data.credentialID,
+		PublicKey: data.publicKey,
+		SignCount: data.signCount,
+		AAGUID:    data.aaguid,
+	}, nil
+}
+
+// VerifyAssertion checks the response to navigator.credentials.get against
+// the challenge issued for it and the stored credential, and returns the
+// authenticator's new signature counter. A counter that did not increase
+// fails with ErrWebAuthnCounterRegressed; authenticators that do not count
+// always report zero and are accepted.
+func (rp *RelyingParty) VerifyAssertion(challenge []byte, cred *WebAuthnCredential, clientDataJSON, authenticatorData, signature []byte) (uint32, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	data, err := rp.parseAuthenticatorData(authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	key, alg, err := parseCOSEKey(cred.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	if err := verifyCOSESignature(key, alg, signedData(authenticatorData, clientDataJSON), signature); err != nil {
+		return 0, err
+	}
+
+	if (data.signCount != 0 || cred.SignCount != 0) && data.signCount <= cred.SignCount {
+		return 0, ErrWebAuthnCounterRegressed
+	}
+	return data.signCount, nil
+}
+
+// clientData is the JSON the browser signs over (WebAuthn §5.8.1)
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return invalidWebAuthn("client data: %v", err)
+	}
+	if cd.Type != ceremony {
+		return invalidWebAuthn("client data type is %q, want %q", cd.Type, ceremony)
+	}
+	got, err := DecodeBase64URL(cd.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return invalidWebAuthn("challenge does not match")
+	}
+	if cd.CrossOrigin {
+		return invalidWebAuthn("cross-origin requests are not allowed")
+	}
+	for _, origin := range rp.Origins {
+		if cd.Origin == strings.TrimSuffix(origin, "/") {
+			return nil
+		}
+	}
+	return invalidWebAuthn("origin %q is not allowed", cd.Origin)
+}
+
+// authenticatorData is the parsed form of the authenticator's signed data
+// (WebAuthn §6.1)
+type authenticatorData struct {
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+func (rp *RelyingParty) parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, invalidWebAuthn("authenticator data is too short")
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(raw[:32], rpIDHash[:]) {
+		return nil, invalidWebAuthn("credential belongs to another site")
+	}
+
+	data := &authenticatorData{
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	if data.flags&authFlagUserPresent == 0 {
+		return nil, invalidWebAuthn("user was not present")
+	}
+	if data.flags&authFlagUserVerified == 0 {
+		return nil, invalidWebAuthn("user was not verified")
+	}
+	if data.flags&authFlagAttestedCredData == 0 {
+		return data, nil
+	}
+
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return nil, invalidWebAuthn("attested credential data is too short")
+	}
+	data.aaguid = append([]byte(nil), rest[:16]...)
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+		return nil, invalidWebAuthn("invalid credential ID length")
+	}
+	data.credentialID = append([]byte(nil), rest[:idLen]...)
+	rest = rest[idLen:]
+
+	// The public key is followed by extensions, if any
+	_, after, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, invalidWebAuthn("credential public key: %v", err)
+	}
+	data.publicKey = append([]byte(nil), rest[:len(rest)-len(after)]...)
+	return data, nil
+}
+
+// parseCOSEKey decodes a COSE_Key (RFC 9053) into a Go public key
+func parseCOSEKey(raw []byte) (crypto.PublicKey, int, error) {
+	decoded, _, err := decodeCBOR(raw)
+	if err != nil {
+		return nil, 0, invalidWebAuthn("credential public key: %v", err)
+	}
+	m, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, 0, invalidWebAuthn("credential public key is not a map")
+	}
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == COSEAlgES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, invalidWebAuthn("invalid P-256 key")
+		}
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, 0, invalidWebAuthn("P-256 key is not on the curve")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return key, COSEAlgES256, nil
+	case kty == 1 && alg == COSEAlgEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, invalidWebAuthn("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), COSEAlgEdDSA, nil
+	case kty == 3 && alg == COSEAlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, invalidWebAuthn("invalid RSA key")
+		}
+		exponent := 0
+		for _, b := range e {
+			exponent = exponent<<8 | int(b)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, COSEAlgRS256, nil
+	}
+	return nil, 0, invalidWebAuthn("unsupported key type %d with algorithm %d", kty, alg)
+}
+
+// verifyCOSESignature checks sig over data with key
+func verifyCOSESignature(key crypto.PublicKey, alg int, data, sig []byte) error {
+	digest := sha256.Sum256(data)
+	var ok bool
+	switch alg {
+	case COSEAlgES256:
+		ok = ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), digest[:], sig)
+	case COSEAlgEdDSA:
+		ok = ed25519.Verify(key.(ed25519.PublicKey), data, sig)
+	case COSEAlgRS256:
+		ok = rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], sig) == nil
+	}
+	if !ok {
+		return invalidWebAuthn("signature does not verify")
+	}
+	return nil
+}
+
+// signedData is what an authenticator signs: its data followed by the hash
+// of the client data
+func signedData(authenticatorData, clientDataJSON []byte) []byte {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	return append(append([]byte(nil), authenticatorData...), clientDataHash[:]...)
+}
+
+// EncodeBase64URL encodes binary WebAuthn fields the way browsers do
+func EncodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeBase64URL decodes a base64url field with or without padding
+func DecodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func invalidWebAuthn(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidWebAuthnResponse, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+const testOrigin = "https://app.chainforge.test"
+
+func testRelyingParty() *RelyingParty {
+	return NewRelyingParty("chainforge.test", "ChainForge", []string{testOrigin})
+}
+
+func TestWebAuthnRegistrationAndAssertion(t *testing.T) {
+	for _, alg := range []int{COSEAlgES256, COSEAlgEdDSA} {
+		rp := testRelyingParty()
+		a := newTestAuthenticator(t, alg)
+
+		challenge, _ := NewWebAuthnChallenge()
+		clientData, attestation := a.create(t, rp.ID, testOrigin, challenge)
+		cred, err := rp.VerifyRegistration(challenge, clientData, attestation)
+		if err != nil {
+			t.Fatalf("alg %d: VerifyRegistration: %v", alg, err)
+		}
+		if string(cred.ID) != string(a.credentialID) {
+			t.Errorf("alg %d: credential ID = %x, want %x", alg, cred.ID, a.credentialID)
+		}
+
+		challenge, _ = NewWebAuthnChallenge()
+		clientData, authData, sig := a.get(t, rp.ID, testOrigin, challenge)
+		count, err := rp.VerifyAssertion(challenge, cred, clientData, authData, sig)
+		if err != nil {
+			t.Fatalf("alg %d: VerifyAssertion: %v", alg, err)
+		}
+		if count != a.counter {
+			t.Errorf("alg %d: sign count = %d, want %d", alg, count, a.counter)
+		}
+	}
+}
+
+func TestWebAuthnRejectsMismatchedCeremonies(t *testing.T) {
+	rp := testRelyingParty()
+	a := newTestAuthenticator(t, COSEAlgES256)
+	challenge, _ := NewWebAuthnChallenge()
+	clientData, attestation := a.create(t, rp.ID, testOrigin, challenge)
+	cred, err := rp.VerifyRegistration(challenge, clientData, attestation)
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	other, _ := NewWebAuthnChallenge()
+
+	tests := map[string]func() error{
+		"wrong challenge": func() error {
+			clientData, authData, sig := a.get(t, rp.ID, testOrigin, other)
+			_, err := rp.VerifyAssertion(challenge, cred, clientData, authData, sig)
+			return err
+		},
+		"wrong origin": func() error {
+			clientData, authData, sig := a.get(t, rp.ID, "https://evil.test", challenge)
+			_, err := rp.VerifyAssertion(challenge, cred, clientData, authData, sig)
+			return err
+		},
+		"another site's credential": func() error {
+			clientData, authData, sig := a.get(t, "evil.test", testOrigin, challenge)
+			_, err := rp.VerifyAssertion(challenge, cred, clientData, authData, sig)
+			return err
+		},
+		"assertion used to register": func() error {
+			clientData, authData, _ := a.get(t, rp.ID, testOrigin, challenge)
+			_, err := rp.VerifyRegistration(challenge, clientData, authData)
+			return err
+		},
+		"tampered signature": func() error {
+			clientData, authData, sig := a.get(t, rp.ID, testOrigin, challenge)
+			sig[len(sig)-1] ^= 1
+			_, err := rp.VerifyAssertion(challenge, cred, clientData, authData, sig)
+			return err
+		},
+		"user not verified": func() error {
+			a.flags = authFlagUserPresent
+			defer func() { a.flags = authFlagUserPresent | authFlagUserVerified }()
+			clientData, authData, sig := a.get(t, rp.ID, testOrigin, challenge)
+			_, err := rp.VerifyAssertion(challenge, cred, clientData, authData, sig)
+			return err
+		},
+	}
+	for name, verify := range tests {
+		if err := verify(); !errors.Is(err, ErrInvalidWebAuthnResponse) {
+			t.Errorf("%s: err = %v, want ErrInvalidWebAuthnResponse", name, err)
+		}
+	}
+}
+
+func TestWebAuthnDetectsCounterRegression(t *testing.T) {
+	rp := testRelyingParty()
+	a := newTestAuthenticator(t, COSEAlgES256)
+	challenge, _ := NewWebAuthnChallenge()
+	clientData, attestation := a.create(t, rp.ID, testOrigin, challenge)
+	cred, err := rp.VerifyRegistration(challenge, clientData, attestation)
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+
+	clientData, authData, sig := a.get(t, rp.ID, testOrigin, challenge)
+	if cred.SignCount, err = rp.VerifyAssertion(challenge, cred, clientData, authData, sig); err != nil {
+		t.Fatalf("VerifyAssertion: %v", err)
+	}
+
+	// A clone of the authenticator reports a counter that is not ahead
+	a.counter = cred.SignCount - 1
+	clientData, authData, sig = a.get(t, rp.ID, testOrigin, challenge)
+	if _, err := rp.VerifyAssertion(challenge, cred, clientData, authData, sig); !errors.Is(err, ErrWebAuthnCounterRegressed) {
+		t.Fatalf("regressed counter: err = %v, want ErrWebAuthnCounterRegressed", err)
+	}
+
+	// Authenticators without a counter always report zero
+	cred.SignCount, a.counter = 0, 0
+	a.step = 0
+	clientData, authData, sig = a.get(t, rp.ID, testOrigin, challenge)
+	if _, err := rp.VerifyAssertion(challenge, cred, clientData, authData, sig); err != nil {
+		t.Fatalf("counterless authenticator: %v", err)
+	}
+}
+
+func TestDecodeCBORRejectsMalformedInput(t *testing.T) {
+	inputs := map[string][]byte{
+		"empty":               {},
+		"truncated bytes":     {0x45, 1, 2},
+		"huge array":          {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"indefinite map":      {0xbf, 0xff},
+		"duplicate map keys":  {0xa2, 0x01, 0x01, 0x01, 0x02},
+		"integer overflow":    {0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"array map key":       {0xa1, 0x80, 0x01},
+		"deeply nested array": {0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x00},
+	}
+	for name, input := range inputs {
+		if _, _, err := decodeCBOR(input); err == nil {
+			t.Errorf("%s: decoded without error", name)
+		}
+	}
+
+	v, rest, err := decodeCBOR([]byte{0xa2, 0x01, 0x02, 0x20, 0x43, 'a', 'b', 'c', 0xf5})
+	if err != nil {
+		t.Fatalf("decodeCBOR: %v", err)
+	}
+	m := v.(map[any]any)
+	if m[int64(1)] != int64(2) || string(m[int64(-1)].([]byte)) != "abc" || len(rest) != 1 {
+		t.Errorf("decoded %v with rest %x", m, rest)
+	}
+}
+
+// testAuthenticator plays the part of a platform authenticator
+type testAuthenticator struct {
+	alg          int
+	signer       crypto.Signer
+	credentialID []byte
+	counter      uint32
+	step         uint32 // Added to counter on each use
+	flags        byte
+}
+
+func newTestAuthenticator(t *testing.T, alg int) *testAuthenticator {
+	t.Helper()
+	a := &testAuthenticator{alg: alg, step: 1, flags: authFlagUserPresent | authFlagUserVerified}
+	var err error
+	switch alg {
+	case COSEAlgES256:
+		a.signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case COSEAlgEdDSA:
+		_, a.signer, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	a.credentialID = make([]byte, 16)
+	rand.Read(a.credentialID)
+	return a
+}
+
+// create answers navigator.credentials.create with "none" attestation
+func (a *testAuthenticator) create(t *testing.T, rpID, origin string, challenge []byte) ([]byte, []byte) {
+	t.Helper()
+	clientData := testClientData(t, "webauthn.create", origin, challenge)
+
+	authData := a.authData(rpID, a.flags|authFlagAttestedCredData)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, a.coseKey()...)
+
+	attestation := cborMap(
+		"fmt", cborText("none"),
+		"attStmt", cborMap(),
+		"authData", cborBytes(authData),
+	)
+	return clientData, attestation
+}
+
+// get answers navigator.credentials.get
+func (a *testAuthenticator) get(t *testing.T, rpID, origin string, challenge []byte) ([]byte, []byte, []byte) {
+	t.Helper()
+	a.counter += a.step
+	clientData := testClientData(t, "webauthn.get", origin, challenge)
+	authData := a.authData(rpID, a.flags)
+
+	data := signedData(authData, clientData)
+	var sig []byte
+	var err error
+	if a.alg == COSEAlgEdDSA {
+		sig, err = a.signer.Sign(rand.Reader, data, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(data)
+		sig, err = a.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return clientData, authData, sig
+}
+
+func (a *testAuthenticator) authData(rpID string, flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.counter)
+}
+
+func (a *testAuthenticator) coseKey() []byte {
+	switch key := a.signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		x, y := make([]byte, 32), make([]byte, 32)
+		key.X.FillBytes(x)
+		key.Y.FillBytes(y)
+		return cborIntMap(1, cborInt(2), 3, cborInt(COSEAlgES256), -1, cborInt(1), -2, cborBytes(x), -3, cborBytes(y))
+	case ed25519.PublicKey:
+		return cborIntMap(1, cborInt(1), 3, cborInt(COSEAlgEdDSA), -1, cborInt(6), -2, cborBytes(key))
+	}
+	return nil
+}
+
+func testClientData(t *testing.T, ceremony, origin string, challenge []byte) []byte {
+	t.Helper()
+	data, err := json.Marshal(clientData{Type: ceremony, Challenge: EncodeBase64URL(challenge), Origin: origin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// Minimal CBOR encoding for building authenticator responses
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 1<<8:
+		return []byte{major<<5 | 24, byte(n)}
+	case n < 1<<16:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	}
+	return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+}
+
+func cborInt(n int) []byte {
+	if n < 0 {
+		return cborHead(1, uint64(-1-n))
+	}
+	return cborHead(0, uint64(n))
+}
+
+func cborBytes(b []byte) []byte { return append(cborHead(2, uint64(len(b))), b...) }
+func cborText(s string) []byte  { return append(cborHead(3, uint64(len(s))), s...) }
+
+// cborMap encodes alternating string keys and encoded values
+func cborMap(pairs ...any) []byte {
+	out := cborHead(5, uint64(len(pairs)/2))
+	for i := 0; i < len(pairs); i += 2 {
+		out = append(out, cborText(pairs[i].(string))...)
+		out = append(out, pairs[i+1].([]byte)...)
+	}
+	return out
+}
+
+// cborIntMap encodes alternating integer keys and encoded values
+func cborIntMap(pairs ...any) []byte {
+	out := cborHead(5, uint64(len(pairs)/2))
+	for i := 0; i < len(pairs); i += 2 {
+		out = append(out, cborInt(pairs[i].(int))...)
+		out = append(out, pairs[i+1].([]byte)...)
+	}
+	return out
+}
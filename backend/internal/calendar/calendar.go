@@ -0,0 +1,135 @@
+// Package calendar splits time into days, weeks and months as they fall in
+// a time zone.
+//
+// Goals, habits and group periods are counted in the days of the user they
+// belong to, so that a run logged at 11pm in California counts towards that
+// day rather than the next one in UTC. Days start at local midnight and
+// last 23 or 25 hours when daylight saving time begins or ends; where
+// midnight itself is skipped, a day starts at the first instant it has.
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Period is the span of time from Start up to but not including End
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Contains reports whether t falls within the period
+func (p Period) Contains(t time.Time) bool {
+	return !t.Before(p.Start) && t.Before(p.End)
+}
+
+// Calendar counts days, weeks and months in one time zone, with weeks
+// starting on a set weekday. The zero Calendar is UTC with weeks starting
+// on Sunday.
+type Calendar struct {
+	loc       *time.Location
+	weekStart time.Weekday
+}
+
+// New returns the calendar of loc, UTC if nil, whose weeks start on
+// weekStart
+func New(loc *time.Location, weekStart time.Weekday) Calendar {
+	return Calendar{loc: loc, weekStart: weekStart}
+}
+
+// Load returns the calendar of the IANA time zone called name, such as
+// "America/Los_Angeles", whose weeks start on weekStart
+func Load(name string, weekStart time.Weekday) (Calendar, error) {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return Calendar{}, err
+	}
+	return New(loc, weekStart), nil
+}
+
+// ParseWeekday parses the English name of a weekday, such as "monday" or
+// "Sun"
+func ParseWeekday(s string) (time.Weekday, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if len(name) >= 3 {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if full := strings.ToLower(d.String()); strings.HasPrefix(full, name) {
+				return d, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", s)
+}
+
+// Location returns the time zone of the calendar
+func (c Calendar) Location() *time.Location {
+	if c.loc == nil {
+		return time.UTC
+	}
+	return c.loc
+}
+
+// WeekStart returns the first day of the calendar's weeks
+func (c Calendar) WeekStart() time.Weekday {
+	return c.weekStart
+}
+
+// StartOfDay returns the first instant of the day t falls on
+func (c Calendar) StartOfDay(t time.Time) time.Time {
+	y, m, d := t.In(c.Location()).Date()
+	return c.date(y, m, d)
+}
+
+// AddDays returns the first instant of the day n days after the one t falls
+// on, or before it for negative n
+func (c Calendar) AddDays(t time.Time, n int) time.Time {
+	y, m, d := t.In(c.Location()).Date()
+	return c.date(y, m, d+n)
+}
+
+// DaysBetween counts the days from the one a falls on to the one b falls
+// on: 0 for the same day, 1 for the next one and negative if b's day is
+// earlier
+func (c Calendar) DaysBetween(a, b time.Time) int {
+	ay, am, ad := a.In(c.Location()).Date()
+	by, bm, bd := b.In(c.Location()).Date()
+	// Whole days apart in UTC, which has none shorter or longer than 24 hours
+	diff := time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC).Sub(time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC))
+	return int(diff / (24 * time.Hour))
+}
+
+// Day returns the day t falls on
+func (c Calendar) Day(t time.Time) Period {
+	y, m, d := t.In(c.Location()).Date()
+	return Period{Start: c.date(y, m, d), End: c.date(y, m, d+1)}
+}
+
+// Week returns the week t falls in
+func (c Calendar) Week(t time.Time) Period {
+	local := t.In(c.Location())
+	y, m, d := local.Date()
+	d -= (int(local.Weekday()) - int(c.weekStart) + 7) % 7
+	return Period{Start: c.date(y, m, d), End: c.date(y, m, d+7)}
+}
+
+// Month returns the month t falls in
+func (c Calendar) Month(t time.Time) Period {
+	y, m, _ := t.In(c.Location()).Date()
+	return Period{Start: c.date(y, m, 1), End: c.date(y, m+1, 1)}
+}
+
+// date returns the first instant of a date, normalizing it like time.Date
+func (c Calendar) date(y int, m time.Month, d int) time.Time {
+	loc := c.Location()
+	t := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	// Where the clocks skip midnight, time.Date lands before it on the
+	// previous day; the date then starts when the skipped hour ends
+	if _, _, td := t.Date(); td != time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Day() {
+		if _, end := t.ZoneBounds(); !end.IsZero() {
+			return end
+		}
+	}
+	return t
+}
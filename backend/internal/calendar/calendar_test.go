@@ -0,0 +1,135 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func load(t *testing.T, name string, weekStart time.Weekday) Calendar {
+	t.Helper()
+	cal, err := Load(name, weekStart)
+	if err != nil {
+		t.Skipf("time zone %s: %v", name, err)
+	}
+	return cal
+}
+
+func TestDayAcrossDaylightSavingTime(t *testing.T) {
+	la := load(t, "America/Los_Angeles", time.Monday)
+	loc := la.Location()
+
+	// Clocks went forward at 2am on March 10, 2024, so the day lasted 23 hours
+	day := la.Day(time.Date(2024, 3, 10, 12, 0, 0, 0, loc))
+	if !day.Start.Equal(time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC)) || day.End.Sub(day.Start) != 23*time.Hour {
+		t.Errorf("spring forward = %v - %v", day.Start, day.End)
+	}
+	day = la.Day(time.Date(2024, 11, 3, 12, 0, 0, 0, loc))
+	if day.End.Sub(day.Start) != 25*time.Hour {
+		t.Errorf("fall back lasted %v", day.End.Sub(day.Start))
+	}
+
+	// 11pm in California is the next day in UTC
+	late := time.Date(2024, 3, 12, 23, 0, 0, 0, loc)
+	if got := la.StartOfDay(late); !got.Equal(time.Date(2024, 3, 12, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("StartOfDay(%v) = %v", late, got)
+	}
+	if !la.Day(late).Contains(late) || la.Day(late).Contains(la.Day(late).End) {
+		t.Error("a day contains its start but not its end")
+	}
+}
+
+func TestDayWithoutMidnight(t *testing.T) {
+	santiago := load(t, "America/Santiago", time.Monday)
+
+	// Clocks went from midnight straight to 1am on September 8, 2024
+	day := santiago.Day(time.Date(2024, 9, 8, 12, 0, 0, 0, time.UTC))
+	if !day.Start.Equal(time.Date(2024, 9, 8, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("start = %v, want 1am local", day.Start)
+	}
+	if prev := santiago.Day(time.Date(2024, 9, 7, 12, 0, 0, 0, time.UTC)); !prev.End.Equal(day.Start) {
+		t.Errorf("previous day ends at %v, want %v", prev.End, day.Start)
+	}
+	if got := santiago.AddDays(time.Date(2024, 9, 6, 12, 0, 0, 0, time.UTC), 2); !got.Equal(day.Start) {
+		t.Errorf("AddDays = %v, want %v", got, day.Start)
+	}
+}
+
+func TestWeek(t *testing.T) {
+	wednesday := time.Date(2024, 5, 15, 18, 30, 0, 0, time.UTC)
+	tests := []struct {
+		weekStart  time.Weekday
+		start, end time.Time
+	}{
+		{time.Monday, time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)},
+		{time.Sunday, time.Date(2024, 5, 12, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)},
+		{time.Wednesday, time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 22, 0, 0, 0, 0, time.UTC)},
+		{time.Thursday, time.Date(2024, 5, 9, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		week := New(time.UTC, tt.weekStart).Week(wednesday)
+		if !week.Start.Equal(tt.start) || !week.End.Equal(tt.end) {
+			t.Errorf("%s week = %v - %v, want %v - %v", tt.weekStart, week.Start, week.End, tt.start, tt.end)
+		}
+	}
+
+	// A week with a daylight saving change is an hour short
+	la := load(t, "America/Los_Angeles", time.Sunday)
+	week := la.Week(time.Date(2024, 3, 12, 12, 0, 0, 0, time.UTC))
+	if !week.Start.Equal(time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC)) || week.End.Sub(week.Start) != 7*24*time.Hour-time.Hour {
+		t.Errorf("week = %v - %v", week.Start, week.End)
+	}
+}
+
+func TestMonth(t *testing.T) {
+	tokyo := load(t, "Asia/Tokyo", time.Monday)
+
+	// December 31 at 20:00 UTC is already January in Tokyo
+	month := tokyo.Month(time.Date(2024, 12, 31, 20, 0, 0, 0, time.UTC))
+	if !month.Start.Equal(time.Date(2024, 12, 31, 15, 0, 0, 0, time.UTC)) || !month.End.Equal(time.Date(2025, 1, 31, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("month = %v - %v", month.Start, month.End)
+	}
+
+	month = New(nil, time.Monday).Month(time.Date(2024, 12, 15, 0, 0, 0, 0, time.UTC))
+	if !month.Start.Equal(time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)) || !month.End.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("December = %v - %v", month.Start, month.End)
+	}
+}
+
+func TestDaysBetween(t *testing.T) {
+	la := load(t, "America/Los_Angeles", time.Monday)
+	loc := la.Location()
+
+	tests := []struct {
+		a, b time.Time
+		want int
+	}{
+		{time.Date(2024, 3, 9, 23, 0, 0, 0, loc), time.Date(2024, 3, 10, 0, 30, 0, 0, loc), 1},
+		{time.Date(2024, 3, 9, 12, 0, 0, 0, loc), time.Date(2024, 3, 12, 1, 0, 0, 0, loc), 3},
+		{time.Date(2024, 11, 3, 0, 0, 0, 0, loc), time.Date(2024, 11, 3, 23, 59, 0, 0, loc), 0},
+		{time.Date(2024, 3, 12, 0, 0, 0, 0, loc), time.Date(2024, 3, 9, 23, 0, 0, 0, loc), -3},
+	}
+	for _, tt := range tests {
+		if got := la.DaysBetween(tt.a, tt.b); got != tt.want {
+			t.Errorf("DaysBetween(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseWeekday(t *testing.T) {
+	for s, want := range map[string]time.Weekday{"monday": time.Monday, "Sunday": time.Sunday, " sat ": time.Saturday} {
+		if got, err := ParseWeekday(s); err != nil || got != want {
+			t.Errorf("ParseWeekday(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "mo", "funday"} {
+		if _, err := ParseWeekday(s); err == nil {
+			t.Errorf("ParseWeekday(%q) succeeded", s)
+		}
+	}
+}
+
+func TestLoadUnknownZone(t *testing.T) {
+	if _, err := Load("Mars/Olympus_Mons", time.Monday); err == nil {
+		t.Error("Load of an unknown zone succeeded")
+	}
+}
@@ -2,12 +2,19 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"chainforge/internal/calendar"
 )
 
+// MinEncryptionKeyLength is the shortest accepted DB_ENCRYPTION_KEY
+const MinEncryptionKeyLength = 32
+
 // Config holds all configuration for the application
 type Config struct {
 	Server   ServerConfig   `json:"server"`
@@ -27,27 +34,97 @@ type ServerConfig struct {
 	ReadTimeout    time.Duration `json:"read_timeout"`
 	WriteTimeout   time.Duration `json:"write_timeout"`
 	IdleTimeout    time.Duration `json:"idle_timeout"`
-	RateLimit      float64       `json:"rate_limit"`
-	RateBurst      int           `json:"rate_burst"`
+
+	// Requests per minute per client, which is the user once signed in and
+	// the IP otherwise. RateLimit covers the whole API and the others the
+	// routes they name. RateLimitStore is "sqlite", which keeps counts
+	// across restarts, or "memory", which tracks up to RateLimitSize clients.
+	RateLimit          int    `json:"rate_limit"`
+	AuthRateLimit      int    `json:"auth_rate_limit"`
+	AnalyticsRateLimit int    `json:"analytics_rate_limit"`
+	WebhookRateLimit   int    `json:"webhook_rate_limit"`
+	RateLimitStore     string `json:"rate_limit_store"`
+	RateLimitSize      int    `json:"rate_limit_size"`
+
+	// Day of the week weeks start on when counting habits, analytics and
+	// weekly group goals, such as "monday" or "sunday"
+	WeekStart string `json:"week_start"`
 }
 
 // DatabaseConfig holds database-related configuration
 type DatabaseConfig struct {
-	URL           string `json:"url"`
-	EncryptionKey string `json:"encryption_key"`
-	MaxOpenConns  int    `json:"max_open_conns"`
-	MaxIdleConns  int    `json:"max_idle_conns"`
-	ConnMaxLife   time.Duration `json:"conn_max_life"`
+	URL                string        `json:"url"`
+	EncryptionKey      string        `json:"encryption_key"`
+	FieldEncryptionKey string        `json:"field_encryption_key"`
+	MaxOpenConns       int           `json:"max_open_conns"`
+	MaxIdleConns       int           `json:"max_idle_conns"`
+	ConnMaxLife        time.Duration `json:"conn_max_life"`
 }
 
 // AuthConfig holds authentication-related configuration
 type AuthConfig struct {
-	JWTSecret        string        `json:"jwt_secret"`
-	RefreshSecret    string        `json:"refresh_secret"`
-	AccessTokenTTL   time.Duration `json:"access_token_ttl"`
-	RefreshTokenTTL  time.Duration `json:"refresh_token_ttl"`
-	Issuer           string        `json:"issuer"`
-	PasswordResetTTL time.Duration `json:"password_reset_ttl"`
+	JWTSecret           string        `json:"jwt_secret"`     // Verifies legacy HS256 access tokens
+	RefreshSecret       string        `json:"refresh_secret"` // Verifies legacy HS256 refresh tokens
+	SigningAlgorithm    string        `json:"signing_algorithm"`
+	KeyRotationInterval time.Duration `json:"key_rotation_interval"`
+	KeyRotationOverlap  time.Duration `json:"key_rotation_overlap"`
+	AccessTokenTTL      time.Duration `json:"access_token_ttl"`
+	RefreshTokenTTL     time.Duration `json:"refresh_token_ttl"`
+	Issuer              string        `json:"issuer"`
+	PasswordResetTTL    time.Duration `json:"password_reset_ttl"`
+	VerifyEmailTTL      time.Duration `json:"verify_email_ttl"`
+	WebAuthnRPID        string        `json:"webauthn_rp_id"`   // Domain passkeys are registered to
+	WebAuthnRPName      string        `json:"webauthn_rp_name"`
+	WebAuthnOrigins     []string      `json:"webauthn_origins"` // Origins allowed to use passkeys
+
+	// Sign-in with OpenID Connect providers, which send the browser back to
+	// OIDCRedirectURL
+	OIDCRedirectURL string               `json:"oidc_redirect_url"`
+	OIDCProviders   []OIDCProviderConfig `json:"oidc_providers"`
+
+	// Failed sign-ins: past LoginBackoffAfter failures for an email (or
+	// LoginIPBackoffAfter from an IP) each attempt waits twice as long as the
+	// last, up to LoginMaxBackoff, and LoginLockAfter failures lock the email
+	// for LoginLockDuration. MFALockAfter failed second-factor checks by a
+	// signed-in user lock their checks for as long. Failures are forgotten
+	// after LoginFailureWindow.
+	LoginBackoffAfter   int           `json:"login_backoff_after"`
+	LoginIPBackoffAfter int           `json:"login_ip_backoff_after"`
+	LoginMaxBackoff     time.Duration `json:"login_max_backoff"`
+	LoginLockAfter      int           `json:"login_lock_after"`
+	MFALockAfter        int           `json:"mfa_lock_after"`
+	LoginLockDuration   time.Duration `json:"login_lock_duration"`
+	LoginFailureWindow  time.Duration `json:"login_failure_window"`
+
+	// New passwords are hashed with PasswordHash, argon2id or bcrypt.
+	// Signing in rehashes passwords stored with other settings.
+	PasswordHash              string `json:"password_hash"`
+	PasswordBcryptCost        int    `json:"password_bcrypt_cost"`
+	PasswordArgon2Memory      int    `json:"password_argon2_memory"` // KiB
+	PasswordArgon2Iterations  int    `json:"password_argon2_iterations"`
+	PasswordArgon2Parallelism int    `json:"password_argon2_parallelism"`
+
+	// New passwords may not repeat the latest PasswordHistory ones, and
+	// owners and admins of groups must reset passwords set more than
+	// PasswordAdminMaxAgeDays ago (0 for never).
+	PasswordHistory         int `json:"password_history"`
+	PasswordAdminMaxAgeDays int `json:"password_admin_max_age_days"`
+}
+
+// OIDCProviderConfig configures sign-in with an OpenID Connect provider
+type OIDCProviderConfig struct {
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"-"`
+	Scopes       []string `json:"scopes"`
+}
+
+// wellKnownOIDCIssuers are the issuers used when a provider of this name
+// does not set one
+var wellKnownOIDCIssuers = map[string]string{
+	"google": "https://accounts.google.com",
+	"apple":  "https://appleid.apple.com",
 }
 
 // StripeConfig holds Stripe-related configuration
@@ -66,6 +143,17 @@ type EmailConfig struct {
 	FromEmail    string `json:"from_email"`
 	FromName     string `json:"from_name"`
 	ReplyToEmail string `json:"reply_to_email"`
+	AppURL       string `json:"app_url"` // Web app the links in emails open
+
+	// Relay used by the smtp provider. The password defaults to APIKey,
+	// which is how most hosted providers take SMTP credentials.
+	SMTPHost     string `json:"smtp_host"`
+	SMTPPort     int    `json:"smtp_port"`
+	SMTPUsername string `json:"smtp_username"`
+	SMTPPassword string `json:"-"`
+
+	// Maildir the file provider writes messages to
+	FileDir string `json:"file_dir"`
 }
 
 // StorageConfig holds file storage configuration
@@ -88,6 +176,27 @@ type AWSS3Config struct {
 
 // Load loads configuration from environment variables
 func Load() (*Config, error) {
+	cfg := read()
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+	return cfg, nil
+}
+
+// LoadForRekey loads the configuration for the rekey command. Unlike Load it
+// accepts a DB_ENCRYPTION_KEY shorter than MinEncryptionKeyLength, which
+// releases before the minimum was enforced allowed, so that such databases
+// can be moved to a longer key.
+func LoadForRekey() (*Config, error) {
+	cfg := read()
+	if err := cfg.validate(false); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+	return cfg, nil
+}
+
+// read builds the configuration from the environment
+func read() *Config {
 	cfg := &Config{}
 
 	// Server configuration
@@ -104,27 +213,63 @@ func Load() (*Config, error) {
 		ReadTimeout:  getEnvDuration("READ_TIMEOUT", 15*time.Second),
 		WriteTimeout: getEnvDuration("WRITE_TIMEOUT", 15*time.Second),
 		IdleTimeout:  getEnvDuration("IDLE_TIMEOUT", 60*time.Second),
-		RateLimit:    getEnvFloat("RATE_LIMIT", 100.0),
-		RateBurst:    getEnvInt("RATE_BURST", 20),
+
+		RateLimit:          getEnvInt("RATE_LIMIT", 300),
+		AuthRateLimit:      getEnvInt("RATE_LIMIT_AUTH", 30),
+		AnalyticsRateLimit: getEnvInt("RATE_LIMIT_ANALYTICS", 30),
+		WebhookRateLimit:   getEnvInt("RATE_LIMIT_WEBHOOKS", 600),
+		RateLimitStore:     getEnv("RATE_LIMIT_STORE", "sqlite"),
+		RateLimitSize:      getEnvInt("RATE_LIMIT_SIZE", 100000),
+
+		WeekStart: getEnv("WEEK_START", "monday"),
 	}
 
 	// Database configuration
 	cfg.Database = DatabaseConfig{
-		URL:           getEnv("DATABASE_URL", "./data/chainforge.db"),
-		EncryptionKey: getEnvRequired("DB_ENCRYPTION_KEY"),
-		MaxOpenConns:  getEnvInt("DB_MAX_OPEN_CONNS", 25),
-		MaxIdleConns:  getEnvInt("DB_MAX_IDLE_CONNS", 5),
-		ConnMaxLife:   getEnvDuration("DB_CONN_MAX_LIFE", 5*time.Minute),
+		URL:                getEnv("DATABASE_URL", "./data/chainforge.db"),
+		EncryptionKey:      getEnvRequired("DB_ENCRYPTION_KEY"),
+		FieldEncryptionKey: getEnvRequired("FIELD_ENCRYPTION_KEY"),
+		MaxOpenConns:       getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		MaxIdleConns:       getEnvInt("DB_MAX_IDLE_CONNS", 5),
+		ConnMaxLife:        getEnvDuration("DB_CONN_MAX_LIFE", 5*time.Minute),
 	}
 
 	// Auth configuration
 	cfg.Auth = AuthConfig{
-		JWTSecret:        getEnvRequired("JWT_SECRET"),
-		RefreshSecret:    getEnvRequired("REFRESH_SECRET"),
-		AccessTokenTTL:   getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
-		RefreshTokenTTL:  getEnvDuration("REFRESH_TOKEN_TTL", 7*24*time.Hour),
-		Issuer:           getEnv("JWT_ISSUER", "chainforge"),
-		PasswordResetTTL: getEnvDuration("PASSWORD_RESET_TTL", 1*time.Hour),
+		JWTSecret:           getEnv("JWT_SECRET", ""),
+		RefreshSecret:       getEnv("REFRESH_SECRET", ""),
+		SigningAlgorithm:    getEnv("JWT_SIGNING_ALGORITHM", "RS256"),
+		KeyRotationInterval: getEnvDuration("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour),
+		KeyRotationOverlap:  getEnvDuration("JWT_KEY_ROTATION_OVERLAP", 24*time.Hour),
+		AccessTokenTTL:      getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:     getEnvDuration("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+		Issuer:              getEnv("JWT_ISSUER", "chainforge"),
+		PasswordResetTTL:    getEnvDuration("PASSWORD_RESET_TTL", 1*time.Hour),
+		VerifyEmailTTL:      getEnvDuration("VERIFY_EMAIL_TTL", 48*time.Hour),
+		WebAuthnRPID:        getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnRPName:      getEnv("WEBAUTHN_RP_NAME", "ChainForge"),
+		WebAuthnOrigins:     getEnvStringSlice("WEBAUTHN_ORIGINS", []string{
+			"http://localhost:5173",
+			"http://localhost:3000",
+		}),
+		OIDCRedirectURL:     getEnv("OIDC_REDIRECT_URL", "http://localhost:5173/auth/callback"),
+		OIDCProviders:       loadOIDCProviders(),
+		LoginBackoffAfter:   getEnvInt("LOGIN_BACKOFF_AFTER", 3),
+		LoginIPBackoffAfter: getEnvInt("LOGIN_IP_BACKOFF_AFTER", 20),
+		LoginMaxBackoff:     getEnvDuration("LOGIN_MAX_BACKOFF", 5*time.Minute),
+		LoginLockAfter:      getEnvInt("LOGIN_LOCK_AFTER", 10),
+		MFALockAfter:        getEnvInt("MFA_LOCK_AFTER", 5),
+		LoginLockDuration:   getEnvDuration("LOGIN_LOCK_DURATION", 30*time.Minute),
+		LoginFailureWindow:  getEnvDuration("LOGIN_FAILURE_WINDOW", 24*time.Hour),
+
+		PasswordHash:              getEnv("PASSWORD_HASH", "argon2id"),
+		PasswordBcryptCost:        getEnvInt("PASSWORD_BCRYPT_COST", 12),
+		PasswordArgon2Memory:      getEnvInt("PASSWORD_ARGON2_MEMORY", 64*1024),
+		PasswordArgon2Iterations:  getEnvInt("PASSWORD_ARGON2_ITERATIONS", 3),
+		PasswordArgon2Parallelism: getEnvInt("PASSWORD_ARGON2_PARALLELISM", 2),
+
+		PasswordHistory:         getEnvInt("PASSWORD_HISTORY", 5),
+		PasswordAdminMaxAgeDays: getEnvInt("PASSWORD_ADMIN_MAX_AGE_DAYS", 0),
 	}
 
 	// Stripe configuration
@@ -143,6 +288,12 @@ func Load() (*Config, error) {
 		FromEmail:    getEnv("EMAIL_FROM", "noreply@chainforge.app"),
 		FromName:     getEnv("EMAIL_FROM_NAME", "ChainForge"),
 		ReplyToEmail: getEnv("EMAIL_REPLY_TO", "support@chainforge.app"),
+		AppURL:       getEnv("APP_URL", "http://localhost:5173"),
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", getEnv("EMAIL_API_KEY", "")),
+		FileDir:      getEnv("EMAIL_FILE_DIR", "./data/mail"),
 	}
 
 	// Storage configuration
@@ -169,25 +320,69 @@ func Load() (*Config, error) {
 		}
 	}
 
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("configuration validation failed: %w", err)
-	}
-
-	return cfg, nil
+	return cfg
 }
 
 // Validate validates the configuration
 func (c *Config) Validate() error {
+	return c.validate(true)
+}
+
+// validate validates the configuration, checking the length of
+// DB_ENCRYPTION_KEY only if strictDatabaseKey is set
+func (c *Config) validate(strictDatabaseKey bool) error {
 	// Validate required fields
-	if c.Database.EncryptionKey == "" {
-		return fmt.Errorf("DB_ENCRYPTION_KEY is required")
+	if strictDatabaseKey {
+		if err := ValidateEncryptionKey(c.Database.EncryptionKey); err != nil {
+			return fmt.Errorf("DB_ENCRYPTION_KEY: %w (databases encrypted with a shorter key can move to a longer one with the rekey command)", err)
+		}
+	} else if c.Database.EncryptionKey == "" {
+		return fmt.Errorf("DB_ENCRYPTION_KEY: encryption key is required")
 	}
-	if c.Auth.JWTSecret == "" {
-		return fmt.Errorf("JWT_SECRET is required")
+	if err := ValidateEncryptionKey(c.Database.FieldEncryptionKey); err != nil {
+		return fmt.Errorf("FIELD_ENCRYPTION_KEY: %w", err)
+	}
+	if c.Database.FieldEncryptionKey == c.Database.EncryptionKey {
+		return fmt.Errorf("FIELD_ENCRYPTION_KEY must differ from DB_ENCRYPTION_KEY")
+	}
+	validAlgorithms := []string{"RS256", "EdDSA"}
+	if !contains(validAlgorithms, c.Auth.SigningAlgorithm) {
+		return fmt.Errorf("invalid JWT_SIGNING_ALGORITHM: %s (must be one of: %s)",
+			c.Auth.SigningAlgorithm, strings.Join(validAlgorithms, ", "))
+	}
+	if c.Auth.KeyRotationOverlap <= 0 || c.Auth.KeyRotationInterval <= c.Auth.KeyRotationOverlap {
+		return fmt.Errorf("JWT_KEY_ROTATION_INTERVAL must be longer than JWT_KEY_ROTATION_OVERLAP, which must be positive")
+	}
+	if err := validateWebAuthnOrigins(c.Auth.WebAuthnRPID, c.Auth.WebAuthnOrigins); err != nil {
+		return err
+	}
+	if err := validateOIDCProviders(c.Auth.OIDCRedirectURL, c.Auth.OIDCProviders); err != nil {
+		return err
+	}
+	if c.Auth.LoginBackoffAfter < 1 || c.Auth.LoginIPBackoffAfter < 1 || c.Auth.LoginLockAfter <= c.Auth.LoginBackoffAfter {
+		return fmt.Errorf("LOGIN_BACKOFF_AFTER and LOGIN_IP_BACKOFF_AFTER must be positive and LOGIN_LOCK_AFTER larger than LOGIN_BACKOFF_AFTER")
+	}
+	if c.Auth.MFALockAfter < 1 {
+		return fmt.Errorf("MFA_LOCK_AFTER must be positive")
+	}
+	if c.Auth.LoginMaxBackoff <= 0 || c.Auth.LoginLockDuration <= 0 || c.Auth.LoginFailureWindow <= 0 {
+		return fmt.Errorf("LOGIN_MAX_BACKOFF, LOGIN_LOCK_DURATION and LOGIN_FAILURE_WINDOW must be positive")
+	}
+	switch c.Auth.PasswordHash {
+	case "argon2id":
+		if c.Auth.PasswordArgon2Iterations < 1 || c.Auth.PasswordArgon2Parallelism < 1 || c.Auth.PasswordArgon2Parallelism > 255 ||
+			c.Auth.PasswordArgon2Memory < 8*c.Auth.PasswordArgon2Parallelism {
+			return fmt.Errorf("PASSWORD_ARGON2_ITERATIONS and PASSWORD_ARGON2_PARALLELISM (up to 255) must be positive and PASSWORD_ARGON2_MEMORY at least 8 KiB per lane")
+		}
+	case "bcrypt":
+		if c.Auth.PasswordBcryptCost < 10 || c.Auth.PasswordBcryptCost > 31 {
+			return fmt.Errorf("PASSWORD_BCRYPT_COST must be between 10 and 31")
+		}
+	default:
+		return fmt.Errorf("invalid PASSWORD_HASH: %s (must be one of: argon2id, bcrypt)", c.Auth.PasswordHash)
 	}
-	if c.Auth.RefreshSecret == "" {
-		return fmt.Errorf("REFRESH_SECRET is required")
+	if c.Auth.PasswordHistory < 0 || c.Auth.PasswordAdminMaxAgeDays < 0 {
+		return fmt.Errorf("PASSWORD_HISTORY and PASSWORD_ADMIN_MAX_AGE_DAYS must not be negative")
 	}
 
 	// Validate environment
@@ -202,6 +397,24 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid port: %d (must be between 1 and 65535)", c.Server.Port)
 	}
 
+	// Validate rate limits
+	if c.Server.RateLimit < 1 || c.Server.AuthRateLimit < 1 || c.Server.AnalyticsRateLimit < 1 || c.Server.WebhookRateLimit < 1 {
+		return fmt.Errorf("RATE_LIMIT, RATE_LIMIT_AUTH, RATE_LIMIT_ANALYTICS and RATE_LIMIT_WEBHOOKS must be positive")
+	}
+	validRateLimitStores := []string{"sqlite", "memory"}
+	if !contains(validRateLimitStores, c.Server.RateLimitStore) {
+		return fmt.Errorf("invalid RATE_LIMIT_STORE: %s (must be one of: %s)",
+			c.Server.RateLimitStore, strings.Join(validRateLimitStores, ", "))
+	}
+	if c.Server.RateLimitStore == "memory" && c.Server.RateLimitSize < 1 {
+		return fmt.Errorf("RATE_LIMIT_SIZE must be positive")
+	}
+
+	// Validate week start
+	if _, err := calendar.ParseWeekday(c.Server.WeekStart); err != nil {
+		return fmt.Errorf("invalid WEEK_START: %w", err)
+	}
+
 	// Validate storage provider
 	validProviders := []string{"local", "s3"}
 	if !contains(validProviders, c.Storage.Provider) {
@@ -209,6 +422,25 @@ func (c *Config) Validate() error {
 			c.Storage.Provider, strings.Join(validProviders, ", "))
 	}
 
+	// Validate email provider
+	validEmailProviders := []string{"console", "smtp", "file"}
+	if !contains(validEmailProviders, c.Email.Provider) {
+		return fmt.Errorf("invalid email provider: %s (must be one of: %s)",
+			c.Email.Provider, strings.Join(validEmailProviders, ", "))
+	}
+	if c.Email.Provider == "smtp" && c.Email.SMTPHost == "" {
+		return fmt.Errorf("SMTP_HOST is required when EMAIL_PROVIDER is smtp")
+	}
+	if c.Email.Provider == "file" && c.Email.FileDir == "" {
+		return fmt.Errorf("EMAIL_FILE_DIR is required when EMAIL_PROVIDER is file")
+	}
+	if u, err := url.Parse(c.Email.AppURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("APP_URL must be an absolute http(s) URL")
+	}
+	if c.Auth.PasswordResetTTL <= 0 || c.Auth.VerifyEmailTTL <= 0 {
+		return fmt.Errorf("PASSWORD_RESET_TTL and VERIFY_EMAIL_TTL must be positive")
+	}
+
 	// Validate Stripe configuration for production
 	if c.Server.Environment == "production" {
 		if c.Stripe.SecretKey == "" {
@@ -222,6 +454,78 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// ValidateEncryptionKey checks that a database encryption key is long enough
+func ValidateEncryptionKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("encryption key is required")
+	}
+	if len(key) < MinEncryptionKeyLength {
+		return fmt.Errorf("encryption key must be at least %d characters long", MinEncryptionKeyLength)
+	}
+	return nil
+}
+
+// validateWebAuthnOrigins checks that every origin may use passkeys
+// registered to rpID: browsers only allow it from secure origins whose host
+// is rpID or one of its subdomains
+func validateWebAuthnOrigins(rpID string, origins []string) error {
+	if rpID == "" {
+		return fmt.Errorf("WEBAUTHN_RP_ID is required")
+	}
+	for _, origin := range origins {
+		u, err := url.Parse(strings.TrimSpace(origin))
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid WEBAUTHN_ORIGINS entry: %s", origin)
+		}
+		host := u.Hostname()
+		if u.Scheme != "https" && !(u.Scheme == "http" && host == "localhost") {
+			return fmt.Errorf("WEBAUTHN_ORIGINS entry %s must use https (or be http://localhost)", origin)
+		}
+		if host != rpID && !strings.HasSuffix(host, "."+rpID) {
+			return fmt.Errorf("WEBAUTHN_ORIGINS entry %s is not on WEBAUTHN_RP_ID %s", origin, rpID)
+		}
+	}
+	return nil
+}
+
+// validateOIDCProviders checks that every provider can be signed in with
+func validateOIDCProviders(redirectURL string, providers []OIDCProviderConfig) error {
+	if len(providers) == 0 {
+		return nil
+	}
+	if u, err := url.Parse(redirectURL); err != nil || !u.IsAbs() {
+		return fmt.Errorf("OIDC_REDIRECT_URL must be an absolute URL")
+	}
+	seen := make(map[string]bool, len(providers))
+	for _, p := range providers {
+		key := "OIDC_" + strings.ToUpper(p.Name)
+		if p.Name == "" || strings.Trim(p.Name, "abcdefghijklmnopqrstuvwxyz0123456789") != "" {
+			return fmt.Errorf("invalid OIDC_PROVIDERS entry %q (use lowercase letters and digits)", p.Name)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("OIDC_PROVIDERS lists %s twice", p.Name)
+		}
+		seen[p.Name] = true
+		u, err := url.Parse(p.Issuer)
+		if err != nil || u.Host == "" || (u.Scheme != "https" && !isLoopback(u.Hostname())) {
+			return fmt.Errorf("%s_ISSUER must be an https URL (or http on this machine)", key)
+		}
+		if p.ClientID == "" {
+			return fmt.Errorf("%s_CLIENT_ID is required", key)
+		}
+	}
+	return nil
+}
+
+// isLoopback reports whether host names this machine
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 // IsDevelopment returns true if running in development mode
 func (c *Config) IsDevelopment() bool {
 	return c.Server.Environment == "development"
@@ -306,6 +610,32 @@ func getEnvStringSlice(key string, defaultValue []string) []string {
 	return defaultValue
 }
 
+// loadOIDCProviders reads OIDC_<NAME>_* for every name in OIDC_PROVIDERS
+func loadOIDCProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range getEnvStringSlice("OIDC_PROVIDERS", nil) {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name)
+		var scopes []string
+		for _, scope := range getEnvStringSlice(prefix+"_SCOPES", nil) {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				scopes = append(scopes, scope)
+			}
+		}
+		providers = append(providers, OIDCProviderConfig{
+			Name:         name,
+			Issuer:       getEnv(prefix+"_ISSUER", wellKnownOIDCIssuers[name]),
+			ClientID:     getEnv(prefix+"_CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"_CLIENT_SECRET", ""),
+			Scopes:       scopes,
+		})
+	}
+	return providers
+}
+
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
 		if s == item {
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/models"
+)
+
+// APIKeyRepository persists personal API keys
+type APIKeyRepository struct {
+	q querier
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, last_used_at, expires_at, created_at`
+
+// CreateAPIKey stores a new API key
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, k *models.APIKey) error {
+	scopes := make([]string, len(k.Scopes))
+	for i, s := range k.Scopes {
+		scopes[i] = string(s)
+	}
+	var expiresAt *time.Time
+	if k.ExpiresAt != nil {
+		t := k.ExpiresAt.UTC()
+		expiresAt = &t
+	}
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO api_keys (`+apiKeyColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		k.ID, k.UserID, k.Name, k.Prefix, k.KeyHash, strings.Join(scopes, ","),
+		k.LastUsedAt, expiresAt, k.CreatedAt.UTC(),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKeyByHash returns the API key with the given hash
+func (r *APIKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = ?`, hash)
+	return scanAPIKey(row)
+}
+
+// ListAPIKeys returns a user's API keys, oldest first
+func (r *APIKeyRepository) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+apiKeyColumns+` FROM api_keys
+		WHERE user_id = ? ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// CountAPIKeys returns how many API keys a user has
+func (r *APIKeyRepository) CountAPIKeys(ctx context.Context, userID uuid.UUID) (int, error) {
+	var n int
+	err := r.q.QueryRowContext(ctx, `SELECT COUNT(*) FROM api_keys WHERE user_id = ?`, userID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count API keys: %w", err)
+	}
+	return n, nil
+}
+
+// TouchAPIKey records that an API key was used at the given time
+func (r *APIKeyRepository) TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error {
+	res, err := r.q.ExecContext(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to record API key use: %w", err)
+	}
+	return expectRows(res)
+}
+
+// DeleteUserAPIKeys revokes all of a user's API keys and returns how many
+// there were
+func (r *APIKeyRepository) DeleteUserAPIKeys(ctx context.Context, userID uuid.UUID) (int, error) {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM api_keys WHERE user_id = ?`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete API keys: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// DeleteAPIKey revokes one of a user's API keys
+func (r *APIKeyRepository) DeleteAPIKey(ctx context.Context, userID, id uuid.UUID) error {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM api_keys WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	return expectRows(res)
+}
+
+func scanAPIKey(row scanner) (*models.APIKey, error) {
+	var k models.APIKey
+	var scopes string
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, &scopes,
+		&k.LastUsedAt, &k.ExpiresAt, &k.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	k.Scopes = []models.APIKeyScope{}
+	for _, s := range strings.Split(scopes, ",") {
+		if s != "" {
+			k.Scopes = append(k.Scopes, models.APIKeyScope(s))
+		}
+	}
+	return &k, nil
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/models"
+)
+
+// AuditRepository persists the audit log
+type AuditRepository struct {
+	q querier
+	f *fieldCodec
+}
+
+const auditColumns = `id, user_id, entity_type, entity_id, action, old_values, new_values, ip_address, user_agent, created_at`
+
+// Create appends an entry to the audit log
+func (r *AuditRepository) Create(ctx context.Context, e *models.AuditLog) error {
+	ip, err := r.f.encryptPtr(fieldAuditIP, e.IPAddress)
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, `
+		INSERT INTO audit_logs (`+auditColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.ID, e.UserID, e.EntityType, e.EntityID, e.Action, e.OldValues, e.NewValues, ip, e.UserAgent, e.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// ListByUser returns up to limit entries about a user's account, newest
+// first, including entries written after the account was deleted
+func (r *AuditRepository) ListByUser(ctx context.Context, userID uuid.UUID, limit int) ([]models.AuditLog, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+auditColumns+` FROM audit_logs
+		WHERE entity_type = ? AND entity_id = ?
+		ORDER BY created_at DESC LIMIT ?`,
+		models.AuditEntityUser, userID.String(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditLog{}
+	for rows.Next() {
+		var e models.AuditLog
+		err := rows.Scan(&e.ID, &e.UserID, &e.EntityType, &e.EntityID, &e.Action,
+			&e.OldValues, &e.NewValues, &e.IPAddress, &e.UserAgent, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		if e.IPAddress, err = r.f.decryptPtr(fieldAuditIP, e.IPAddress); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,314 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	sqlcipher "github.com/mutecomm/go-sqlcipher/v4"
+)
+
+var (
+	// ErrNotFound is returned when a requested record does not exist
+	ErrNotFound = errors.New("record not found")
+
+	// ErrDuplicate is returned when a write violates a unique constraint
+	ErrDuplicate = errors.New("record already exists")
+)
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Options holds connection pool settings for the database
+type Options struct {
+	MaxOpenConns int
+	MaxIdleConns int
+	ConnMaxLife  time.Duration
+}
+
+// Option configures the database connection
+type Option func(*Options)
+
+// WithPool sets the connection pool limits
+func WithPool(maxOpen, maxIdle int, maxLife time.Duration) Option {
+	return func(o *Options) {
+		o.MaxOpenConns = maxOpen
+		o.MaxIdleConns = maxIdle
+		o.ConnMaxLife = maxLife
+	}
+}
+
+// DB wraps the SQLite connection pool and exposes the repositories
+type DB struct {
+	*sql.DB
+
+	fields *fieldCodec
+
+	users         *UserRepository
+	goals         *GoalRepository
+	groups        *GroupRepository
+	subscriptions *SubscriptionRepository
+	tokens        *TokenRepository
+	mfa           *MFARepository
+	audit         *AuditRepository
+	webauthn      *WebAuthnRepository
+	identities    *IdentityRepository
+	emailTokens   *EmailTokenRepository
+	outbox        *OutboxRepository
+	throttles     *ThrottleRepository
+	apiKeys       *APIKeyRepository
+}
+
+// New opens the SQLCipher database at path, enables foreign keys and WAL
+// journaling, and applies the connection pool options. The file is encrypted
+// with a key derived from encryptionKey; an empty key opens a plaintext
+// database. A wrong key fails with ErrInvalidEncryptionKey.
+func New(path, encryptionKey string, opts ...Option) (*DB, error) {
+	options := Options{
+		MaxOpenConns: 25,
+		MaxIdleConns: 5,
+		ConnMaxLife:  5 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if path != ":memory:" && !strings.HasPrefix(path, "file:") {
+		if dir := filepath.Dir(path); dir != "" {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return nil, fmt.Errorf("failed to create database directory: %w", err)
+			}
+		}
+	}
+
+	sqlDB, err := sql.Open("sqlite3", buildDSN(path, encryptionKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	sqlDB.SetMaxOpenConns(options.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(options.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(options.ConnMaxLife)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Reading the schema forces SQLCipher to decrypt the first page, which
+	// is where a wrong key shows up
+	var tables int
+	if err := sqlDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master`).Scan(&tables); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", classifyOpenError(path, err))
+	}
+
+	return wrap(sqlDB), nil
+}
+
+// buildDSN appends the connection parameters every connection in the pool needs
+func buildDSN(path, encryptionKey string) string {
+	params := url.Values{}
+	if encryptionKey != "" {
+		params.Set("_pragma_key", rawKeyLiteral(DeriveKey(encryptionKey)))
+		params.Set("_pragma_cipher_page_size", "4096")
+	}
+	params.Set("_foreign_keys", "on")
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", "5000")
+	params.Set("_txlock", "immediate")
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + params.Encode()
+}
+
+// wrap builds a DB around an open connection pool
+func wrap(sqlDB *sql.DB) *DB {
+	fields := &fieldCodec{}
+	return &DB{
+		DB:            sqlDB,
+		fields:        fields,
+		users:         &UserRepository{q: sqlDB, f: fields},
+		goals:         &GoalRepository{q: sqlDB, f: fields},
+		groups:        &GroupRepository{q: sqlDB},
+		subscriptions: &SubscriptionRepository{q: sqlDB},
+		tokens:        &TokenRepository{q: sqlDB, f: fields},
+		mfa:           &MFARepository{q: sqlDB, f: fields},
+		audit:         &AuditRepository{q: sqlDB, f: fields},
+		webauthn:      &WebAuthnRepository{q: sqlDB},
+		identities:    &IdentityRepository{q: sqlDB, f: fields},
+		emailTokens:   &EmailTokenRepository{q: sqlDB},
+		outbox:        &OutboxRepository{q: sqlDB, f: fields},
+		throttles:     &ThrottleRepository{q: sqlDB, f: fields},
+		apiKeys:       &APIKeyRepository{q: sqlDB},
+	}
+}
+
+// Users returns the user repository
+func (db *DB) Users() UserStore {
+	return db.users
+}
+
+// Goals returns the goal repository
+func (db *DB) Goals() GoalStore {
+	return db.goals
+}
+
+// Groups returns the group repository
+func (db *DB) Groups() GroupStore {
+	return db.groups
+}
+
+// Subscriptions returns the subscription repository
+func (db *DB) Subscriptions() SubscriptionStore {
+	return db.subscriptions
+}
+
+// Tokens returns the refresh token repository
+func (db *DB) Tokens() TokenStore {
+	return db.tokens
+}
+
+// MFA returns the two-factor authentication repository
+func (db *DB) MFA() MFAStore {
+	return db.mfa
+}
+
+// Audit returns the audit log repository
+func (db *DB) Audit() AuditStore {
+	return db.audit
+}
+
+// WebAuthn returns the passkey repository
+func (db *DB) WebAuthn() WebAuthnStore {
+	return db.webauthn
+}
+
+// Identities returns the OpenID Connect identity repository
+func (db *DB) Identities() IdentityStore {
+	return db.identities
+}
+
+// EmailTokens returns the repository of tokens mailed to users
+func (db *DB) EmailTokens() EmailTokenStore {
+	return db.emailTokens
+}
+
+// Outbox returns the repository of emails waiting to be delivered
+func (db *DB) Outbox() OutboxStore {
+	return db.outbox
+}
+
+// Throttles returns the repository of failed sign-in counters
+func (db *DB) Throttles() ThrottleStore {
+	return db.throttles
+}
+
+// APIKeys returns the personal API key repository
+func (db *DB) APIKeys() APIKeyStore {
+	return db.apiKeys
+}
+
+// WithTx runs fn inside a transaction, committing if fn returns nil and
+// rolling back otherwise
+func (db *DB) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	sqlTx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(db.bind(sqlTx)); err != nil {
+		if rbErr := sqlTx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// bind returns the repositories running on q
+func (db *DB) bind(q querier) *txStore {
+	return &txStore{
+		users:         &UserRepository{q: q, f: db.fields},
+		goals:         &GoalRepository{q: q, f: db.fields},
+		groups:        &GroupRepository{q: q},
+		subscriptions: &SubscriptionRepository{q: q},
+		tokens:        &TokenRepository{q: q, f: db.fields},
+		mfa:           &MFARepository{q: q, f: db.fields},
+		audit:         &AuditRepository{q: q, f: db.fields},
+		webauthn:      &WebAuthnRepository{q: q},
+		identities:    &IdentityRepository{q: q, f: db.fields},
+		emailTokens:   &EmailTokenRepository{q: q},
+		outbox:        &OutboxRepository{q: q, f: db.fields},
+		throttles:     &ThrottleRepository{q: q, f: db.fields},
+		apiKeys:       &APIKeyRepository{q: q},
+	}
+}
+
+// inTx runs fn in a transaction unless q already is one
+func inTx(ctx context.Context, q querier, fn func(querier) error) error {
+	db, ok := q.(*sql.DB)
+	if !ok {
+		return fn(q)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// notFound converts sql.ErrNoRows into ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// isUniqueViolation reports whether err is a UNIQUE or PRIMARY KEY constraint failure
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlcipher.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.ExtendedCode == sqlcipher.ErrConstraintUnique ||
+		sqliteErr.ExtendedCode == sqlcipher.ErrConstraintPrimaryKey
+}
+
+// expectRows returns ErrNotFound when a write touched no rows
+func expectRows(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
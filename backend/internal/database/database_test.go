@@ -0,0 +1,29 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// openTestDB opens an empty database file encrypted with key, or plaintext
+// if key is empty, and closes it when the test ends
+func openTestDB(t *testing.T, key string) (*DB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := New(path, key)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, path
+}
+
+// newTestDB opens a database with every migration applied
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, _ := openTestDB(t, "")
+	if err := RunMigrations(db); err != nil {
+		t.Fatalf("RunMigrations: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/models"
+)
+
+// EmailTokenRepository persists the single-use tokens mailed to users
+type EmailTokenRepository struct {
+	q querier
+}
+
+const emailTokenColumns = `id, user_id, purpose, token_hash, expires_at, used_at, created_at`
+
+// CreateEmailToken stores a token and clears out expired ones
+func (r *EmailTokenRepository) CreateEmailToken(ctx context.Context, t *models.EmailToken) error {
+	return inTx(ctx, r.q, func(q querier) error {
+		if _, err := q.ExecContext(ctx, `DELETE FROM email_tokens WHERE expires_at <= ?`, t.CreatedAt.UTC()); err != nil {
+			return fmt.Errorf("failed to delete expired email tokens: %w", err)
+		}
+		_, err := q.ExecContext(ctx, `
+			INSERT INTO email_tokens (`+emailTokenColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			t.ID, t.UserID, t.Purpose, t.TokenHash, t.ExpiresAt.UTC(), t.UsedAt, t.CreatedAt.UTC(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create email token: %w", err)
+		}
+		return nil
+	})
+}
+
+// TakeEmailToken marks an unused, unexpired token as used and returns it.
+// It returns ErrNotFound if there is none, so each token is accepted at
+// most once.
+func (r *EmailTokenRepository) TakeEmailToken(ctx context.Context, purpose models.EmailTokenPurpose, tokenHash string, now time.Time) (*models.EmailToken, error) {
+	var t *models.EmailToken
+	err := inTx(ctx, r.q, func(q querier) error {
+		res, err := q.ExecContext(ctx, `
+			UPDATE email_tokens SET used_at = ?
+			WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?`,
+			now.UTC(), tokenHash, purpose, now.UTC())
+		if err != nil {
+			return fmt.Errorf("failed to use email token: %w", err)
+		}
+		if err := expectRows(res); err != nil {
+			return err
+		}
+		row := q.QueryRowContext(ctx, `SELECT `+emailTokenColumns+` FROM email_tokens WHERE token_hash = ?`, tokenHash)
+		t, err = scanEmailToken(row)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// RevokeEmailTokens marks a user's outstanding tokens for purpose as used
+func (r *EmailTokenRepository) RevokeEmailTokens(ctx context.Context, userID uuid.UUID, purpose models.EmailTokenPurpose, at time.Time) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE email_tokens SET used_at = ?
+		WHERE user_id = ? AND purpose = ? AND used_at IS NULL`,
+		at.UTC(), userID, purpose)
+	if err != nil {
+		return fmt.Errorf("failed to revoke email tokens: %w", err)
+	}
+	return nil
+}
+
+func scanEmailToken(row scanner) (*models.EmailToken, error) {
+	var t models.EmailToken
+	err := row.Scan(&t.ID, &t.UserID, &t.Purpose, &t.TokenHash, &t.ExpiresAt, &t.UsedAt, &t.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &t, nil
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	sqlcipher "github.com/mutecomm/go-sqlcipher/v4"
+	"golang.org/x/crypto/hkdf"
+)
+
+var (
+	// ErrInvalidEncryptionKey is returned when the database file is encrypted
+	// with a different key than the one configured
+	ErrInvalidEncryptionKey = errors.New("database encryption key is incorrect (or the file is not a ChainForge database)")
+
+	// ErrDatabaseNotEncrypted is returned when an encryption key is configured
+	// but the database file on disk is plaintext
+	ErrDatabaseNotEncrypted = errors.New("database file is not encrypted")
+)
+
+// keyDerivationInfo binds derived keys to their purpose so the same
+// DB_ENCRYPTION_KEY can safely seed other keys later
+const keyDerivationInfo = "chainforge/sqlcipher/page-key/v1"
+
+// DeriveKey turns the configured passphrase into the 256-bit raw SQLCipher key.
+// Passing a raw key skips SQLCipher's per-connection PBKDF2 run, which would
+// otherwise be paid every time the pool opens a connection.
+func DeriveKey(passphrase string) []byte {
+	reader := hkdf.New(sha256.New, []byte(passphrase), nil, []byte(keyDerivationInfo))
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(reader, key); err != nil {
+		// hkdf only fails when more than 255*HashLen bytes are requested
+		panic(fmt.Sprintf("failed to derive database key: %v", err))
+	}
+	return key
+}
+
+// rawKeyLiteral formats a raw key the way PRAGMA key expects it
+func rawKeyLiteral(key []byte) string {
+	return "x'" + strings.ToUpper(hex.EncodeToString(key)) + "'"
+}
+
+// classifyOpenError turns SQLCipher's generic "file is not a database"
+// into an error that says whether the key or the file is the problem
+func classifyOpenError(path string, err error) error {
+	var sqliteErr sqlcipher.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlcipher.ErrNotADB {
+		return err
+	}
+
+	encrypted, headerErr := sqlcipher.IsEncrypted(filePath(path))
+	if headerErr != nil {
+		return err
+	}
+	if !encrypted {
+		return ErrDatabaseNotEncrypted
+	}
+	return ErrInvalidEncryptionKey
+}
+
+// filePath strips the file: scheme and query string from a DSN path
+func filePath(path string) string {
+	path = strings.TrimPrefix(path, "file:")
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
+
+// Rekey re-encrypts the database at path in place, replacing oldKey with newKey.
+// The server must not be running while the key is rotated. Only encrypted
+// databases can be rekeyed; SQLCipher cannot encrypt a plaintext file in place.
+func Rekey(path, oldKey, newKey string) error {
+	if oldKey == "" {
+		return errors.New("current encryption key must not be empty: only an encrypted database can be rekeyed")
+	}
+	if newKey == "" {
+		return errors.New("new encryption key must not be empty")
+	}
+	if oldKey == newKey {
+		return errors.New("new encryption key must differ from the current key")
+	}
+	if _, err := os.Stat(filePath(path)); err != nil {
+		return fmt.Errorf("database file not found: %w", err)
+	}
+
+	db, err := New(path, oldKey, WithPool(1, 1, 0))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	// SQLCipher cannot rekey a database in WAL mode, so fold the WAL back
+	// into the main file and switch to a rollback journal for the rewrite
+	statements := []string{
+		`PRAGMA wal_checkpoint(TRUNCATE)`,
+		`PRAGMA journal_mode = DELETE`,
+		fmt.Sprintf(`PRAGMA rekey = "%s"`, rawKeyLiteral(DeriveKey(newKey))),
+		`PRAGMA journal_mode = WAL`,
+	}
+	for _, stmt := range statements {
+		if err := execPragma(ctx, conn, stmt); err != nil {
+			if strings.Contains(stmt, "rekey") {
+				return fmt.Errorf("failed to rekey database: %w", err)
+			}
+			return fmt.Errorf("failed to run %q: %w", stmt, err)
+		}
+	}
+
+	conn.Close()
+	db.Close()
+
+	verify, err := New(path, newKey, WithPool(1, 1, 0))
+	if err != nil {
+		return fmt.Errorf("database did not open with the new key: %w", err)
+	}
+	return verify.Close()
+}
+
+// execPragma runs a pragma and drains any result row it produces
+func execPragma(ctx context.Context, conn *sql.Conn, stmt string) error {
+	rows, err := conn.QueryContext(ctx, stmt)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+const (
+	testKey      = "test-database-key-of-32-characters"
+	otherTestKey = "another-database-key-32-characters"
+)
+
+// createTestDB creates a database at a new path holding one row, encrypted
+// with key, and closes it
+func createTestDB(t *testing.T, key string) string {
+	t.Helper()
+	db, path := openTestDB(t, key)
+	if _, err := db.ExecContext(context.Background(), `CREATE TABLE notes (body TEXT); INSERT INTO notes VALUES ('kept')`); err != nil {
+		t.Fatalf("create notes: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return path
+}
+
+func TestOpenWithWrongKey(t *testing.T) {
+	path := createTestDB(t, testKey)
+
+	if _, err := New(path, otherTestKey); !errors.Is(err, ErrInvalidEncryptionKey) {
+		t.Errorf("wrong key: err = %v, want ErrInvalidEncryptionKey", err)
+	}
+	if _, err := New(path, ""); !errors.Is(err, ErrInvalidEncryptionKey) {
+		t.Errorf("no key: err = %v, want ErrInvalidEncryptionKey", err)
+	}
+}
+
+func TestOpenPlaintextWithKey(t *testing.T) {
+	path := createTestDB(t, "")
+
+	if _, err := New(path, testKey); !errors.Is(err, ErrDatabaseNotEncrypted) {
+		t.Errorf("err = %v, want ErrDatabaseNotEncrypted", err)
+	}
+}
+
+func TestRekey(t *testing.T) {
+	path := createTestDB(t, testKey)
+
+	if err := Rekey(path, testKey, otherTestKey); err != nil {
+		t.Fatalf("Rekey: %v", err)
+	}
+	if _, err := New(path, testKey); !errors.Is(err, ErrInvalidEncryptionKey) {
+		t.Errorf("old key after rekey: err = %v, want ErrInvalidEncryptionKey", err)
+	}
+
+	db, err := New(path, otherTestKey)
+	if err != nil {
+		t.Fatalf("New with the new key: %v", err)
+	}
+	defer db.Close()
+	var body string
+	if err := db.QueryRow(`SELECT body FROM notes`).Scan(&body); err != nil || body != "kept" {
+		t.Errorf("row after rekey = %q, %v", body, err)
+	}
+}
+
+func TestRekeyRejectsBadKeys(t *testing.T) {
+	encrypted := createTestDB(t, testKey)
+	plaintext := createTestDB(t, "")
+
+	tests := []struct {
+		name, path, oldKey, newKey string
+	}{
+		{"plaintext database", plaintext, "", testKey},
+		{"empty new key", encrypted, testKey, ""},
+		{"unchanged key", encrypted, testKey, testKey},
+		{"wrong current key", encrypted, otherTestKey, testKey + "-new"},
+	}
+	for _, tt := range tests {
+		if err := Rekey(tt.path, tt.oldKey, tt.newKey); err == nil {
+			t.Errorf("%s: Rekey succeeded", tt.name)
+		}
+	}
+
+	// Failed attempts leave the database readable with its key
+	db, err := New(encrypted, testKey)
+	if err != nil {
+		t.Fatalf("New after failed rekeys: %v", err)
+	}
+	db.Close()
+}
@@ -0,0 +1,424 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"chainforge/internal/fieldcrypt"
+)
+
+// Encrypted columns. The names double as associated data, binding each
+// ciphertext to the column it was written to.
+const (
+	fieldUserEmail      = "users.email"
+	fieldUserFirstName  = "users.first_name"
+	fieldUserLastName   = "users.last_name"
+	fieldGoalPunishment = "goals.punishment"
+	fieldProgressNote   = "goal_progress.note"
+	fieldSigningKey     = "signing_keys.private_key"
+	fieldSessionIP      = "sessions.ip_address"
+	fieldTOTPSecret     = "totp_credentials.secret"
+	fieldAuditIP        = "audit_logs.ip_address"
+	fieldIdentityEmail  = "user_identities.email"
+	fieldOutboxTo       = "email_outbox.recipient"
+	fieldOutboxText     = "email_outbox.text_body"
+	fieldOutboxHTML     = "email_outbox.html_body"
+	fieldThrottleKey    = "login_throttles.subject"
+	fieldRateLimitKey   = "rate_limits.key"
+)
+
+// NormalizeEmail returns the canonical form of an email address used for lookups
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// fieldCodec encrypts and decrypts PII columns. Until a keyring is attached
+// it passes values through unchanged.
+type fieldCodec struct {
+	keyring atomic.Pointer[fieldcrypt.Keyring]
+}
+
+func (c *fieldCodec) enabled() bool {
+	return c.keyring.Load() != nil
+}
+
+func (c *fieldCodec) encrypt(field, value string) (string, error) {
+	kr := c.keyring.Load()
+	if kr == nil {
+		return value, nil
+	}
+	return kr.Encrypt(field, value)
+}
+
+func (c *fieldCodec) encryptPtr(field string, value *string) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	encrypted, err := c.encrypt(field, *value)
+	if err != nil {
+		return nil, err
+	}
+	return &encrypted, nil
+}
+
+func (c *fieldCodec) decrypt(field, value string) (string, error) {
+	kr := c.keyring.Load()
+	if kr == nil {
+		if fieldcrypt.IsEncrypted(value) {
+			return "", fmt.Errorf("%s is encrypted but field encryption is not enabled", field)
+		}
+		return value, nil
+	}
+	return kr.Decrypt(field, value)
+}
+
+func (c *fieldCodec) decryptPtr(field string, value *string) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	decrypted, err := c.decrypt(field, *value)
+	if err != nil {
+		return nil, err
+	}
+	return &decrypted, nil
+}
+
+// blindIndex returns the blind index of value, or value itself until a
+// keyring is attached
+func (c *fieldCodec) blindIndex(field, value string) string {
+	kr := c.keyring.Load()
+	if kr == nil {
+		return value
+	}
+	return kr.BlindIndex(field, value)
+}
+
+// emailHash returns the blind index for an email address
+func (c *fieldCodec) emailHash(email string) string {
+	return c.keyring.Load().BlindIndex(fieldUserEmail, NormalizeEmail(email))
+}
+
+// EnableFieldEncryption loads the data keys wrapped under masterKey, creating
+// the first one if needed, and from then on encrypts PII columns on write.
+// It also indexes users created before encryption was enabled so email
+// lookups keep working. Migrations must have run first.
+func (db *DB) EnableFieldEncryption(ctx context.Context, masterKey string) error {
+	keyring, err := fieldcrypt.NewKeyring(masterKey)
+	if err != nil {
+		return err
+	}
+
+	if err := db.loadFieldKeys(ctx, keyring); err != nil {
+		return err
+	}
+	if keyring.ActiveVersion() == 0 {
+		if _, err := db.createFieldKey(ctx, keyring); err != nil {
+			return err
+		}
+	}
+
+	db.fields.keyring.Store(keyring)
+
+	return db.backfillEmailIndex(ctx)
+}
+
+// RotateFieldKey adds a new data key version, which becomes active for new
+// writes. Existing rows move to it as ReencryptFields runs.
+func (db *DB) RotateFieldKey(ctx context.Context, masterKey string) (int, error) {
+	keyring, err := fieldcrypt.NewKeyring(masterKey)
+	if err != nil {
+		return 0, err
+	}
+	if err := db.loadFieldKeys(ctx, keyring); err != nil {
+		return 0, err
+	}
+	return db.createFieldKey(ctx, keyring)
+}
+
+// ReencryptFields rewrites up to batchSize rows per table whose PII columns
+// are plaintext or encrypted with an older key version. It returns the
+// number of rows rewritten; zero means every row is current.
+func (db *DB) ReencryptFields(ctx context.Context, batchSize int) (int, error) {
+	keyring := db.fields.keyring.Load()
+	if keyring == nil {
+		return 0, errors.New("field encryption is not enabled")
+	}
+
+	// Pick up versions added by RotateFieldKey since startup
+	if err := db.loadFieldKeys(ctx, keyring); err != nil {
+		return 0, err
+	}
+
+	pattern := keyring.ActivePrefix() + "%"
+	total := 0
+
+	users, err := db.reencryptUsers(ctx, pattern, batchSize)
+	total += users
+	if err != nil {
+		return total, err
+	}
+
+	goals, err := db.reencryptColumn(ctx, "goals", "punishment", fieldGoalPunishment, pattern, batchSize)
+	total += goals
+	if err != nil {
+		return total, err
+	}
+
+	notes, err := db.reencryptColumn(ctx, "goal_progress", "note", fieldProgressNote, pattern, batchSize)
+	total += notes
+	if err != nil {
+		return total, err
+	}
+
+	archived, err := db.reencryptColumn(ctx, "goal_progress_archive", "note", fieldProgressNote, pattern, batchSize)
+	total += archived
+	if err != nil {
+		return total, err
+	}
+
+	keys, err := db.reencryptColumn(ctx, "signing_keys", "private_key", fieldSigningKey, pattern, batchSize)
+	total += keys
+	if err != nil {
+		return total, err
+	}
+
+	sessions, err := db.reencryptColumn(ctx, "sessions", "ip_address", fieldSessionIP, pattern, batchSize)
+	total += sessions
+	if err != nil {
+		return total, err
+	}
+
+	secrets, err := db.reencryptColumn(ctx, "totp_credentials", "secret", fieldTOTPSecret, pattern, batchSize)
+	total += secrets
+	if err != nil {
+		return total, err
+	}
+
+	audits, err := db.reencryptColumn(ctx, "audit_logs", "ip_address", fieldAuditIP, pattern, batchSize)
+	total += audits
+	if err != nil {
+		return total, err
+	}
+
+	identities, err := db.reencryptColumn(ctx, "user_identities", "email", fieldIdentityEmail, pattern, batchSize)
+	total += identities
+	if err != nil {
+		return total, err
+	}
+
+	outboxFields := []struct{ column, field string }{
+		{"recipient", fieldOutboxTo},
+		{"text_body", fieldOutboxText},
+		{"html_body", fieldOutboxHTML},
+	}
+	for _, c := range outboxFields {
+		emails, err := db.reencryptColumn(ctx, "email_outbox", c.column, c.field, pattern, batchSize)
+		total += emails
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (db *DB) reencryptUsers(ctx context.Context, pattern string, batchSize int) (int, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, email, first_name, last_name FROM users
+		WHERE email NOT LIKE ? OR first_name NOT LIKE ? OR last_name NOT LIKE ?
+		LIMIT ?`, pattern, pattern, pattern, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find users to re-encrypt: %w", err)
+	}
+
+	type userFields struct{ id, email, firstName, lastName string }
+	var pending []userFields
+	for rows.Next() {
+		var u userFields
+		if err := rows.Scan(&u.id, &u.email, &u.firstName, &u.lastName); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		pending = append(pending, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, u := range pending {
+		err := inTx(ctx, db.DB, func(q querier) error {
+			users := db.bind(q).users
+			values := map[string]*string{
+				fieldUserEmail:     &u.email,
+				fieldUserFirstName: &u.firstName,
+				fieldUserLastName:  &u.lastName,
+			}
+			for field, value := range values {
+				if err := db.reencryptValue(field, value); err != nil {
+					return err
+				}
+			}
+			if _, err := q.ExecContext(ctx,
+				`UPDATE users SET email = ?, first_name = ?, last_name = ? WHERE id = ?`,
+				u.email, u.firstName, u.lastName, u.id,
+			); err != nil {
+				return fmt.Errorf("failed to re-encrypt user: %w", err)
+			}
+			plainEmail, err := db.fields.decrypt(fieldUserEmail, u.email)
+			if err != nil {
+				return err
+			}
+			return users.setEmailIndex(ctx, u.id, plainEmail)
+		})
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(pending), nil
+}
+
+// reencryptColumn rewrites a single nullable encrypted column
+func (db *DB) reencryptColumn(ctx context.Context, table, column, field, pattern string, batchSize int) (int, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT id, %[2]s FROM %[1]s WHERE %[2]s IS NOT NULL AND %[2]s NOT LIKE ? LIMIT ?`, table, column),
+		pattern, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find %s to re-encrypt: %w", table, err)
+	}
+
+	type row struct{ id, value string }
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.value); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan %s: %w", table, err)
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, r := range pending {
+		if err := db.reencryptValue(field, &r.value); err != nil {
+			return i, err
+		}
+		if _, err := db.ExecContext(ctx,
+			fmt.Sprintf(`UPDATE %s SET %s = ? WHERE id = ?`, table, column), r.value, r.id,
+		); err != nil {
+			return i, fmt.Errorf("failed to re-encrypt %s: %w", table, err)
+		}
+	}
+	return len(pending), nil
+}
+
+// reencryptValue decrypts value (which may be plaintext) and encrypts it with the active key
+func (db *DB) reencryptValue(field string, value *string) error {
+	plaintext, err := db.fields.decrypt(field, *value)
+	if err != nil {
+		return err
+	}
+	encrypted, err := db.fields.encrypt(field, plaintext)
+	if err != nil {
+		return err
+	}
+	*value = encrypted
+	return nil
+}
+
+// backfillEmailIndex indexes users that have no blind index row yet
+func (db *DB) backfillEmailIndex(ctx context.Context) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, email FROM users
+		WHERE id NOT IN (SELECT user_id FROM user_email_index)`)
+	if err != nil {
+		return fmt.Errorf("failed to find unindexed users: %w", err)
+	}
+
+	type pendingUser struct{ id, email string }
+	var pending []pendingUser
+	for rows.Next() {
+		var u pendingUser
+		if err := rows.Scan(&u.id, &u.email); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan user: %w", err)
+		}
+		pending = append(pending, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, u := range pending {
+		email, err := db.fields.decrypt(fieldUserEmail, u.email)
+		if err != nil {
+			return err
+		}
+		if err := db.users.setEmailIndex(ctx, u.id, email); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadFieldKeys unwraps every stored data key not yet in the keyring
+func (db *DB) loadFieldKeys(ctx context.Context, keyring *fieldcrypt.Keyring) error {
+	rows, err := db.QueryContext(ctx, `SELECT version, wrapped_key FROM field_keys ORDER BY version`)
+	if err != nil {
+		return fmt.Errorf("failed to load field keys: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var wrapped []byte
+		if err := rows.Scan(&version, &wrapped); err != nil {
+			return fmt.Errorf("failed to scan field key: %w", err)
+		}
+		if keyring.HasKey(version) {
+			continue
+		}
+		key, err := keyring.UnwrapKey(version, wrapped)
+		if err != nil {
+			return err
+		}
+		if err := keyring.AddKey(version, key); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// createFieldKey generates, stores and loads the next data key version
+func (db *DB) createFieldKey(ctx context.Context, keyring *fieldcrypt.Keyring) (int, error) {
+	var current sql.NullInt64
+	if err := db.QueryRowContext(ctx, `SELECT MAX(version) FROM field_keys`).Scan(&current); err != nil {
+		return 0, fmt.Errorf("failed to read field key version: %w", err)
+	}
+	version := int(current.Int64) + 1
+
+	key, err := fieldcrypt.GenerateDataKey()
+	if err != nil {
+		return 0, err
+	}
+	wrapped, err := keyring.WrapKey(version, key)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := db.ExecContext(ctx,
+		`INSERT INTO field_keys (version, wrapped_key) VALUES (?, ?)`, version, wrapped,
+	); err != nil {
+		return 0, fmt.Errorf("failed to store field key: %w", err)
+	}
+	if err := keyring.AddKey(version, key); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"chainforge/internal/models"
+)
+
+const testFieldKey = "test-field-master-key-32-characters"
+
+// storedEmail returns the users.email column as written to disk
+func storedEmail(t *testing.T, db *DB, u *models.User) string {
+	t.Helper()
+	var email string
+	if err := db.QueryRow(`SELECT email FROM users WHERE id = ?`, u.ID).Scan(&email); err != nil {
+		t.Fatalf("read email: %v", err)
+	}
+	return email
+}
+
+// reencryptAll runs ReencryptFields in small batches until every row is current
+func reencryptAll(t *testing.T, db *DB) int {
+	t.Helper()
+	total := 0
+	for i := 0; ; i++ {
+		n, err := db.ReencryptFields(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("ReencryptFields: %v", err)
+		}
+		if n == 0 {
+			return total
+		}
+		if i > 100 {
+			t.Fatal("ReencryptFields never finished")
+		}
+		total += n
+	}
+}
+
+func TestFieldEncryptionAndRotation(t *testing.T) {
+	db, path := openTestDB(t, "")
+	if err := RunMigrations(db); err != nil {
+		t.Fatalf("RunMigrations: %v", err)
+	}
+	ctx := context.Background()
+	users := []*models.User{
+		createTestUser(t, db, "ada@example.com"),
+		createTestUser(t, db, "grace@example.com"),
+	}
+
+	if err := db.EnableFieldEncryption(ctx, testFieldKey); err != nil {
+		t.Fatalf("EnableFieldEncryption: %v", err)
+	}
+	if stored := storedEmail(t, db, users[0]); stored != "ada@example.com" {
+		t.Fatalf("enabling encryption rewrote %q", stored)
+	}
+	if n := reencryptAll(t, db); n != len(users) {
+		t.Errorf("ReencryptFields rewrote %d users, want %d", n, len(users))
+	}
+	if n := reencryptAll(t, db); n != 0 {
+		t.Errorf("second pass rewrote %d rows", n)
+	}
+
+	version, err := db.RotateFieldKey(ctx, testFieldKey)
+	if err != nil || version != 2 {
+		t.Fatalf("RotateFieldKey = %d, %v; want version 2", version, err)
+	}
+	if n := reencryptAll(t, db); n != len(users) {
+		t.Errorf("ReencryptFields after rotation rewrote %d users, want %d", n, len(users))
+	}
+	created := createTestUser(t, db, "Linus@Example.com")
+
+	for _, u := range append(users, created) {
+		if stored := storedEmail(t, db, u); !strings.HasPrefix(stored, "enc:v2:") {
+			t.Errorf("%s stored as %q, want a v2 ciphertext", u.Email, stored)
+		}
+		got, err := db.Users().GetByID(ctx, u.ID)
+		if err != nil || got.Email != u.Email || got.FirstName != "Ada" {
+			t.Errorf("GetByID(%s) = %+v, %v", u.Email, got, err)
+		}
+	}
+
+	// Lookups go through the blind index, which ignores case and whitespace
+	// and survives rotation, for users indexed by the backfill or on create
+	lookups := map[string]*models.User{
+		"ada@example.com":     users[0],
+		" Grace@Example.COM ": users[1],
+		"linus@example.com":   created,
+		"LINUS@example.com\t": created,
+	}
+	for email, want := range lookups {
+		got, err := db.Users().GetByEmail(ctx, email)
+		if err != nil || got.ID != want.ID {
+			t.Errorf("GetByEmail(%q) = %v, %v; want %s", email, got, err, want.ID)
+		}
+	}
+	if _, err := db.Users().GetByEmail(ctx, "nobody@example.com"); err == nil {
+		t.Error("GetByEmail found an unknown address")
+	}
+
+	// Another process with the wrong master key cannot unwrap the data keys
+	other, err := New(path, "")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer other.Close()
+	if err := other.EnableFieldEncryption(ctx, "another-field-master-key"); err == nil {
+		t.Error("EnableFieldEncryption succeeded with the wrong master key")
+	}
+	if _, err := other.Users().GetByID(ctx, users[0].ID); err == nil {
+		t.Error("read an encrypted user without field encryption enabled")
+	}
+	if err := other.EnableFieldEncryption(ctx, testFieldKey); err != nil {
+		t.Fatalf("EnableFieldEncryption with the right key: %v", err)
+	}
+	if got, err := other.Users().GetByEmail(ctx, "ada@example.com"); err != nil || got.ID != users[0].ID {
+		t.Errorf("GetByEmail from another handle = %v, %v", got, err)
+	}
+}
+
+func TestFieldCodecPassesThroughUntilEnabled(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	u := createTestUser(t, db, "ada@example.com")
+
+	if stored := storedEmail(t, db, u); stored != "ada@example.com" {
+		t.Errorf("stored %q before encryption was enabled", stored)
+	}
+	if got, err := db.Users().GetByEmail(ctx, " ADA@example.com"); err != nil || got.ID != u.ID {
+		t.Errorf("GetByEmail = %v, %v", got, err)
+	}
+	if _, err := db.ReencryptFields(ctx, 10); err == nil {
+		t.Error("ReencryptFields ran without field encryption")
+	}
+
+	var indexed int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM user_email_index`).Scan(&indexed); err != nil || indexed != 0 {
+		t.Errorf("indexed %d users before encryption was enabled, %v", indexed, err)
+	}
+	if err := db.EnableFieldEncryption(ctx, testFieldKey); err != nil {
+		t.Fatalf("EnableFieldEncryption: %v", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM user_email_index`).Scan(&indexed); err != nil || indexed != 1 {
+		t.Errorf("backfill indexed %d users, %v; want 1", indexed, err)
+	}
+	if db.fields.emailHash("Ada@Example.com ") != db.fields.emailHash("ada@example.com") {
+		t.Error("emailHash depends on case or whitespace")
+	}
+}
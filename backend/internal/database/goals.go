@@ -0,0 +1,281 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/models"
+)
+
+// GoalRepository persists personal goals and their progress entries.
+// The punishment and progress note columns are encrypted at rest once field
+// encryption is enabled. Habits are the goals with a row in goal_recurrences
+// and measured goals those with one in goal_directions.
+type GoalRepository struct {
+	q querier
+	f *fieldCodec
+}
+
+const goalColumns = `id, user_id, name, description, target_amount, current_amount, unit, category, status,
+	start_date, end_date, punishment, is_public, created_at, updated_at`
+
+// goalSelect selects goalColumns, the recurrence of habits and the direction
+// of measured goals
+const goalSelect = `SELECT ` + goalColumns + `, recurrence, direction, start_amount, min_amount
+	FROM goals
+	LEFT JOIN goal_recurrences ON goal_recurrences.goal_id = goals.id
+	LEFT JOIN goal_directions ON goal_directions.goal_id = goals.id`
+
+const progressColumns = `id, goal_id, amount, note, date, created_at`
+
+// Create inserts a new goal
+func (r *GoalRepository) Create(ctx context.Context, g *models.Goal) error {
+	punishment, err := r.f.encryptPtr(fieldGoalPunishment, g.Punishment)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.q.ExecContext(ctx, `
+		INSERT INTO goals (`+goalColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		g.ID, g.UserID, g.Name, g.Description, g.TargetAmount, g.CurrentAmount, g.Unit,
+		g.Category, g.Status, g.StartDate, g.EndDate, punishment, g.IsPublic, g.CreatedAt, g.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create goal: %w", err)
+	}
+	if g.IsMeasured() {
+		_, err = r.q.ExecContext(ctx, `
+			INSERT INTO goal_directions (goal_id, direction, start_amount, min_amount)
+			VALUES (?, ?, ?, ?)`,
+			g.ID, g.Direction, g.StartAmount, g.MinAmount,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create goal direction: %w", err)
+		}
+	}
+	return r.saveRecurrence(ctx, g)
+}
+
+// GetByID returns the goal with the given ID
+func (r *GoalRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Goal, error) {
+	row := r.q.QueryRowContext(ctx, goalSelect+` WHERE id = ?`, id)
+	return r.scan(row)
+}
+
+// ListByUser returns all goals owned by a user, newest first
+func (r *GoalRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Goal, error) {
+	rows, err := r.q.QueryContext(ctx,
+		goalSelect+` WHERE user_id = ? ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list goals: %w", err)
+	}
+	defer rows.Close()
+
+	goals := []models.Goal{}
+	for rows.Next() {
+		g, err := r.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, *g)
+	}
+	return goals, rows.Err()
+}
+
+// CountActiveByUser returns the number of goals that are not completed or canceled
+func (r *GoalRepository) CountActiveByUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	err := r.q.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM goals WHERE user_id = ? AND status IN ('active', 'in_progress')`, userID,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count goals: %w", err)
+	}
+	return count, nil
+}
+
+// Update saves the mutable fields of a goal
+func (r *GoalRepository) Update(ctx context.Context, g *models.Goal) error {
+	punishment, err := r.f.encryptPtr(fieldGoalPunishment, g.Punishment)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE goals
+		SET name = ?, description = ?, target_amount = ?, current_amount = ?, unit = ?, category = ?,
+			status = ?, start_date = ?, end_date = ?, punishment = ?, is_public = ?, updated_at = ?
+		WHERE id = ?`,
+		g.Name, g.Description, g.TargetAmount, g.CurrentAmount, g.Unit, g.Category,
+		g.Status, g.StartDate, g.EndDate, punishment, g.IsPublic, g.UpdatedAt, g.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update goal: %w", err)
+	}
+	if err := expectRows(res); err != nil {
+		return err
+	}
+	return r.saveRecurrence(ctx, g)
+}
+
+// saveRecurrence stores the recurrence of a habit, or removes it from a goal
+// that has none
+func (r *GoalRepository) saveRecurrence(ctx context.Context, g *models.Goal) error {
+	if g.Recurrence == nil {
+		if _, err := r.q.ExecContext(ctx, `DELETE FROM goal_recurrences WHERE goal_id = ?`, g.ID); err != nil {
+			return fmt.Errorf("failed to delete recurrence: %w", err)
+		}
+		return nil
+	}
+	recurrence, err := json.Marshal(g.Recurrence)
+	if err != nil {
+		return fmt.Errorf("failed to encode recurrence: %w", err)
+	}
+	_, err = r.q.ExecContext(ctx, `
+		INSERT INTO goal_recurrences (goal_id, recurrence) VALUES (?, ?)
+		ON CONFLICT (goal_id) DO UPDATE SET recurrence = excluded.recurrence`,
+		g.ID, string(recurrence),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save recurrence: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a goal and its progress entries
+func (r *GoalRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM goals WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete goal: %w", err)
+	}
+	return expectRows(res)
+}
+
+// AddProgress inserts a progress entry. The schema triggers keep
+// goals.current_amount in sync with the sum of its entries, or with the
+// latest entry of a measured goal.
+func (r *GoalRepository) AddProgress(ctx context.Context, p *models.GoalProgress) error {
+	note, err := r.f.encryptPtr(fieldProgressNote, p.Note)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.q.ExecContext(ctx, `
+		INSERT INTO goal_progress (`+progressColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		p.ID, p.GoalID, p.Amount, note, p.Date, p.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add progress: %w", err)
+	}
+	return nil
+}
+
+// ArchiveProgress moves a goal's progress entries into goal_progress_archive,
+// which resets goals.current_amount through the delete trigger. It returns
+// the number of entries moved and must run inside a transaction.
+func (r *GoalRepository) ArchiveProgress(ctx context.Context, goalID uuid.UUID, archivedAt time.Time) (int, error) {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO goal_progress_archive (`+progressColumns+`, archived_at)
+		SELECT `+progressColumns+`, ? FROM goal_progress WHERE goal_id = ?`,
+		archivedAt, goalID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive progress: %w", err)
+	}
+
+	res, err := r.q.ExecContext(ctx, `DELETE FROM goal_progress WHERE goal_id = ?`, goalID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear progress: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear progress: %w", err)
+	}
+	return int(n), nil
+}
+
+// ListProgress returns progress entries for a goal, newest first.
+// A limit of zero or less returns every entry.
+func (r *GoalRepository) ListProgress(ctx context.Context, goalID uuid.UUID, limit int) ([]models.GoalProgress, error) {
+	query := `SELECT ` + progressColumns + ` FROM goal_progress WHERE goal_id = ? ORDER BY date DESC, created_at DESC`
+	args := []interface{}{goalID}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list progress: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanProgress(rows)
+}
+
+// ListProgressSince returns progress entries for a goal dated on or after since, oldest first
+func (r *GoalRepository) ListProgressSince(ctx context.Context, goalID uuid.UUID, since time.Time) ([]models.GoalProgress, error) {
+	rows, err := r.q.QueryContext(ctx,
+		`SELECT `+progressColumns+` FROM goal_progress WHERE goal_id = ? AND date >= ? ORDER BY date ASC`,
+		goalID, since,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list progress: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanProgress(rows)
+}
+
+func (r *GoalRepository) scan(s scanner) (*models.Goal, error) {
+	var g models.Goal
+	var recurrence *string
+	var direction *models.GoalDirection
+	err := s.Scan(
+		&g.ID, &g.UserID, &g.Name, &g.Description, &g.TargetAmount, &g.CurrentAmount, &g.Unit,
+		&g.Category, &g.Status, &g.StartDate, &g.EndDate, &g.Punishment, &g.IsPublic, &g.CreatedAt, &g.UpdatedAt,
+		&recurrence, &direction, &g.StartAmount, &g.MinAmount,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	g.Direction = models.DirectionIncrease
+	if direction != nil {
+		g.Direction = *direction
+	}
+	g.Kind = models.GoalKindTarget
+	if recurrence != nil {
+		g.Kind, g.Recurrence = models.GoalKindHabit, &models.Recurrence{}
+		if err := json.Unmarshal([]byte(*recurrence), g.Recurrence); err != nil {
+			return nil, fmt.Errorf("failed to decode recurrence of goal %s: %w", g.ID, err)
+		}
+	}
+	if g.Punishment, err = r.f.decryptPtr(fieldGoalPunishment, g.Punishment); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (r *GoalRepository) scanProgress(rows *sql.Rows) ([]models.GoalProgress, error) {
+	entries := []models.GoalProgress{}
+	for rows.Next() {
+		var p models.GoalProgress
+		if err := rows.Scan(&p.ID, &p.GoalID, &p.Amount, &p.Note, &p.Date, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan progress: %w", err)
+		}
+		note, err := r.f.decryptPtr(fieldProgressNote, p.Note)
+		if err != nil {
+			return nil, err
+		}
+		p.Note = note
+		entries = append(entries, p)
+	}
+	return entries, rows.Err()
+}
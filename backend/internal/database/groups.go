@@ -0,0 +1,402 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/models"
+)
+
+// GroupRepository persists groups, memberships, group goals and their periods
+type GroupRepository struct {
+	q querier
+}
+
+const groupColumns = `id, name, description, invite_code, max_members, is_private, status, created_by, created_at, updated_at`
+
+const memberColumns = `id, group_id, user_id, role, joined_at, is_active, created_at, updated_at`
+
+const groupGoalColumns = `id, group_id, name, description, unit, period_type, is_active, created_by, created_at, updated_at`
+
+const periodColumns = `id, group_goal_id, start_date, end_date, is_active, created_at`
+
+const groupProgressColumns = `id, group_goal_period_id, user_id, target_amount, current_amount, penalty_carry_over,
+	daily_entries, is_completed, created_at, updated_at`
+
+// Create inserts a new group
+func (r *GroupRepository) Create(ctx context.Context, g *models.Group) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO groups (`+groupColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		g.ID, g.Name, g.Description, g.InviteCode, g.MaxMembers, g.IsPrivate,
+		g.Status, g.CreatedBy, g.CreatedAt, g.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create group: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns the group with the given ID
+func (r *GroupRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Group, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+groupColumns+` FROM groups WHERE id = ?`, id)
+	return scanGroup(row)
+}
+
+// GetByInviteCode returns the group with the given invite code
+func (r *GroupRepository) GetByInviteCode(ctx context.Context, code string) (*models.Group, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+groupColumns+` FROM groups WHERE invite_code = ?`, code)
+	return scanGroup(row)
+}
+
+// ListByUser returns the groups a user is an active member of
+func (r *GroupRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Group, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT g.id, g.name, g.description, g.invite_code, g.max_members, g.is_private, g.status,
+			g.created_by, g.created_at, g.updated_at
+		FROM groups g
+		JOIN group_members m ON m.group_id = g.id
+		WHERE m.user_id = ? AND m.is_active = 1
+		ORDER BY g.created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list groups: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []models.Group{}
+	for rows.Next() {
+		g, err := scanGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, *g)
+	}
+	return groups, rows.Err()
+}
+
+// Update saves the mutable fields of a group. It returns ErrDuplicate if the
+// invite code is taken.
+func (r *GroupRepository) Update(ctx context.Context, g *models.Group) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE groups
+		SET name = ?, description = ?, invite_code = ?, max_members = ?, is_private = ?, status = ?, updated_at = ?
+		WHERE id = ?`,
+		g.Name, g.Description, g.InviteCode, g.MaxMembers, g.IsPrivate, g.Status, g.UpdatedAt, g.ID,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("failed to update group: %w", err)
+	}
+	return expectRows(res)
+}
+
+// Delete removes a group and, through cascades, its members and goals
+func (r *GroupRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM groups WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+	return expectRows(res)
+}
+
+// AddMember inserts a membership row
+func (r *GroupRepository) AddMember(ctx context.Context, m *models.GroupMember) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO group_members (`+memberColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.ID, m.GroupID, m.UserID, m.Role, m.JoinedAt, m.IsActive, m.CreatedAt, m.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+	return nil
+}
+
+// GetMember returns a user's membership in a group, active or not
+func (r *GroupRepository) GetMember(ctx context.Context, groupID, userID uuid.UUID) (*models.GroupMember, error) {
+	row := r.q.QueryRowContext(ctx,
+		`SELECT `+memberColumns+` FROM group_members WHERE group_id = ? AND user_id = ?`, groupID, userID)
+	return scanMember(row)
+}
+
+// ListMembers returns the active members of a group, oldest first
+func (r *GroupRepository) ListMembers(ctx context.Context, groupID uuid.UUID) ([]models.GroupMember, error) {
+	rows, err := r.q.QueryContext(ctx,
+		`SELECT `+memberColumns+` FROM group_members WHERE group_id = ? AND is_active = 1 ORDER BY joined_at ASC`,
+		groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []models.GroupMember{}
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, *m)
+	}
+	return members, rows.Err()
+}
+
+// CountMembers returns the number of active members in a group
+func (r *GroupRepository) CountMembers(ctx context.Context, groupID uuid.UUID) (int, error) {
+	var count int
+	err := r.q.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM group_members WHERE group_id = ? AND is_active = 1`, groupID,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count members: %w", err)
+	}
+	return count, nil
+}
+
+// UpdateMember saves a membership's role and active flag
+func (r *GroupRepository) UpdateMember(ctx context.Context, m *models.GroupMember) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE group_members SET role = ?, is_active = ?, joined_at = ?, updated_at = ? WHERE id = ?`,
+		m.Role, m.IsActive, m.JoinedAt, m.UpdatedAt, m.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update member: %w", err)
+	}
+	return expectRows(res)
+}
+
+// CreateGoal inserts a new group goal
+func (r *GroupRepository) CreateGoal(ctx context.Context, g *models.GroupGoal) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO group_goals (`+groupGoalColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		g.ID, g.GroupID, g.Name, g.Description, g.Unit, g.PeriodType, g.IsActive,
+		g.CreatedBy, g.CreatedAt, g.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create group goal: %w", err)
+	}
+	return nil
+}
+
+// GetGoal returns the group goal with the given ID
+func (r *GroupRepository) GetGoal(ctx context.Context, id uuid.UUID) (*models.GroupGoal, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+groupGoalColumns+` FROM group_goals WHERE id = ?`, id)
+	return scanGroupGoal(row)
+}
+
+// ListGoals returns the goals of a group, newest first
+func (r *GroupRepository) ListGoals(ctx context.Context, groupID uuid.UUID) ([]models.GroupGoal, error) {
+	return r.listGoals(ctx,
+		`SELECT `+groupGoalColumns+` FROM group_goals WHERE group_id = ? ORDER BY created_at DESC`, groupID)
+}
+
+// ListActiveGoals returns every active group goal across all groups
+func (r *GroupRepository) ListActiveGoals(ctx context.Context) ([]models.GroupGoal, error) {
+	return r.listGoals(ctx, `SELECT `+groupGoalColumns+` FROM group_goals WHERE is_active = 1`)
+}
+
+func (r *GroupRepository) listGoals(ctx context.Context, query string, args ...interface{}) ([]models.GroupGoal, error) {
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group goals: %w", err)
+	}
+	defer rows.Close()
+
+	goals := []models.GroupGoal{}
+	for rows.Next() {
+		g, err := scanGroupGoal(rows)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, *g)
+	}
+	return goals, rows.Err()
+}
+
+// UpdateGoal saves the mutable fields of a group goal
+func (r *GroupRepository) UpdateGoal(ctx context.Context, g *models.GroupGoal) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE group_goals SET name = ?, description = ?, unit = ?, is_active = ?, updated_at = ? WHERE id = ?`,
+		g.Name, g.Description, g.Unit, g.IsActive, g.UpdatedAt, g.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update group goal: %w", err)
+	}
+	return expectRows(res)
+}
+
+// DeleteGoal removes a group goal and its periods
+func (r *GroupRepository) DeleteGoal(ctx context.Context, id uuid.UUID) error {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM group_goals WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete group goal: %w", err)
+	}
+	return expectRows(res)
+}
+
+// CreatePeriod inserts a new group goal period
+func (r *GroupRepository) CreatePeriod(ctx context.Context, p *models.GroupGoalPeriod) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO group_goal_periods (`+periodColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		p.ID, p.GroupGoalID, p.StartDate, p.EndDate, p.IsActive, p.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create period: %w", err)
+	}
+	return nil
+}
+
+// GetActivePeriod returns the active period of a group goal
+func (r *GroupRepository) GetActivePeriod(ctx context.Context, groupGoalID uuid.UUID) (*models.GroupGoalPeriod, error) {
+	row := r.q.QueryRowContext(ctx, `
+		SELECT `+periodColumns+` FROM group_goal_periods
+		WHERE group_goal_id = ? AND is_active = 1
+		ORDER BY start_date DESC LIMIT 1`, groupGoalID)
+	return scanPeriod(row)
+}
+
+// ListExpiredPeriods returns active periods whose end date is before now
+func (r *GroupRepository) ListExpiredPeriods(ctx context.Context, now time.Time) ([]models.GroupGoalPeriod, error) {
+	rows, err := r.q.QueryContext(ctx,
+		`SELECT `+periodColumns+` FROM group_goal_periods WHERE is_active = 1 AND end_date < ?`, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired periods: %w", err)
+	}
+	defer rows.Close()
+
+	periods := []models.GroupGoalPeriod{}
+	for rows.Next() {
+		p, err := scanPeriod(rows)
+		if err != nil {
+			return nil, err
+		}
+		periods = append(periods, *p)
+	}
+	return periods, rows.Err()
+}
+
+// DeactivatePeriod marks a period as finished
+func (r *GroupRepository) DeactivatePeriod(ctx context.Context, id uuid.UUID) error {
+	res, err := r.q.ExecContext(ctx, `UPDATE group_goal_periods SET is_active = 0 WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate period: %w", err)
+	}
+	return expectRows(res)
+}
+
+// CreateProgress inserts a member's progress row for a period
+func (r *GroupRepository) CreateProgress(ctx context.Context, p *models.GroupGoalProgress) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO group_goal_progress (`+groupProgressColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.ID, p.GroupGoalPeriodID, p.UserID, p.TargetAmount, p.CurrentAmount, p.PenaltyCarryOver,
+		p.DailyEntries, p.IsCompleted, p.CreatedAt, p.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create group progress: %w", err)
+	}
+	return nil
+}
+
+// GetProgress returns a member's progress row for a period
+func (r *GroupRepository) GetProgress(ctx context.Context, periodID, userID uuid.UUID) (*models.GroupGoalProgress, error) {
+	row := r.q.QueryRowContext(ctx,
+		`SELECT `+groupProgressColumns+` FROM group_goal_progress WHERE group_goal_period_id = ? AND user_id = ?`,
+		periodID, userID)
+	return scanGroupProgress(row)
+}
+
+// ListProgress returns every member's progress row for a period
+func (r *GroupRepository) ListProgress(ctx context.Context, periodID uuid.UUID) ([]models.GroupGoalProgress, error) {
+	rows, err := r.q.QueryContext(ctx,
+		`SELECT `+groupProgressColumns+` FROM group_goal_progress WHERE group_goal_period_id = ?`, periodID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group progress: %w", err)
+	}
+	defer rows.Close()
+
+	progress := []models.GroupGoalProgress{}
+	for rows.Next() {
+		p, err := scanGroupProgress(rows)
+		if err != nil {
+			return nil, err
+		}
+		progress = append(progress, *p)
+	}
+	return progress, rows.Err()
+}
+
+// UpdateProgress saves a member's progress row
+func (r *GroupRepository) UpdateProgress(ctx context.Context, p *models.GroupGoalProgress) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE group_goal_progress
+		SET target_amount = ?, current_amount = ?, penalty_carry_over = ?, daily_entries = ?,
+			is_completed = ?, updated_at = ?
+		WHERE id = ?`,
+		p.TargetAmount, p.CurrentAmount, p.PenaltyCarryOver, p.DailyEntries, p.IsCompleted, p.UpdatedAt, p.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update group progress: %w", err)
+	}
+	return expectRows(res)
+}
+
+func scanGroup(s scanner) (*models.Group, error) {
+	var g models.Group
+	err := s.Scan(
+		&g.ID, &g.Name, &g.Description, &g.InviteCode, &g.MaxMembers, &g.IsPrivate,
+		&g.Status, &g.CreatedBy, &g.CreatedAt, &g.UpdatedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &g, nil
+}
+
+func scanMember(s scanner) (*models.GroupMember, error) {
+	var m models.GroupMember
+	err := s.Scan(&m.ID, &m.GroupID, &m.UserID, &m.Role, &m.JoinedAt, &m.IsActive, &m.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &m, nil
+}
+
+func scanGroupGoal(s scanner) (*models.GroupGoal, error) {
+	var g models.GroupGoal
+	err := s.Scan(
+		&g.ID, &g.GroupID, &g.Name, &g.Description, &g.Unit, &g.PeriodType, &g.IsActive,
+		&g.CreatedBy, &g.CreatedAt, &g.UpdatedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &g, nil
+}
+
+func scanPeriod(s scanner) (*models.GroupGoalPeriod, error) {
+	var p models.GroupGoalPeriod
+	err := s.Scan(&p.ID, &p.GroupGoalID, &p.StartDate, &p.EndDate, &p.IsActive, &p.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &p, nil
+}
+
+func scanGroupProgress(s scanner) (*models.GroupGoalProgress, error) {
+	var p models.GroupGoalProgress
+	err := s.Scan(
+		&p.ID, &p.GroupGoalPeriodID, &p.UserID, &p.TargetAmount, &p.CurrentAmount, &p.PenaltyCarryOver,
+		&p.DailyEntries, &p.IsCompleted, &p.CreatedAt, &p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/models"
+)
+
+// IdentityRepository persists OpenID Connect identities and sign-in state
+type IdentityRepository struct {
+	q querier
+	f *fieldCodec
+}
+
+const userIdentityColumns = `id, user_id, provider, subject, email, created_at, last_used_at`
+
+// CreateState stores a pending sign-in and clears out expired ones
+func (r *IdentityRepository) CreateState(ctx context.Context, s *models.OIDCState) error {
+	return inTx(ctx, r.q, func(q querier) error {
+		if _, err := q.ExecContext(ctx, `DELETE FROM oidc_states WHERE expires_at <= ?`, s.CreatedAt.UTC()); err != nil {
+			return fmt.Errorf("failed to delete expired OIDC states: %w", err)
+		}
+		_, err := q.ExecContext(ctx, `
+			INSERT INTO oidc_states (state_hash, provider, user_id, nonce, code_verifier, expires_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			s.StateHash, s.Provider, s.UserID, s.Nonce, s.CodeVerifier, s.ExpiresAt.UTC(), s.CreatedAt.UTC(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create OIDC state: %w", err)
+		}
+		return nil
+	})
+}
+
+// TakeState deletes and returns an unexpired pending sign-in. It returns
+// ErrNotFound if there is none, so each state is accepted at most once.
+func (r *IdentityRepository) TakeState(ctx context.Context, stateHash string, now time.Time) (*models.OIDCState, error) {
+	var s models.OIDCState
+	err := inTx(ctx, r.q, func(q querier) error {
+		row := q.QueryRowContext(ctx, `
+			SELECT state_hash, provider, user_id, nonce, code_verifier, expires_at, created_at
+			FROM oidc_states WHERE state_hash = ? AND expires_at > ?`,
+			stateHash, now.UTC())
+		if err := row.Scan(&s.StateHash, &s.Provider, &s.UserID, &s.Nonce, &s.CodeVerifier, &s.ExpiresAt, &s.CreatedAt); err != nil {
+			return notFound(err)
+		}
+		res, err := q.ExecContext(ctx, `DELETE FROM oidc_states WHERE state_hash = ?`, stateHash)
+		if err != nil {
+			return fmt.Errorf("failed to delete OIDC state: %w", err)
+		}
+		return expectRows(res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// CreateIdentity links a provider account to a user. It returns
+// ErrDuplicate if the account is linked already or the user has an
+// identity at the provider.
+func (r *IdentityRepository) CreateIdentity(ctx context.Context, i *models.UserIdentity) error {
+	email, err := r.f.encrypt(fieldIdentityEmail, i.Email)
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, `
+		INSERT INTO user_identities (`+userIdentityColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		i.ID, i.UserID, i.Provider, i.Subject, email, i.CreatedAt.UTC(), i.LastUsedAt,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("failed to create identity: %w", err)
+	}
+	return nil
+}
+
+// GetIdentity returns the identity for a provider's subject
+func (r *IdentityRepository) GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	row := r.q.QueryRowContext(ctx, `
+		SELECT `+userIdentityColumns+` FROM user_identities WHERE provider = ? AND subject = ?`,
+		provider, subject)
+	return r.scanIdentity(row)
+}
+
+// ListIdentities returns a user's linked identities, oldest first
+func (r *IdentityRepository) ListIdentities(ctx context.Context, userID uuid.UUID) ([]models.UserIdentity, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+userIdentityColumns+` FROM user_identities
+		WHERE user_id = ? ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+	defer rows.Close()
+
+	identities := []models.UserIdentity{}
+	for rows.Next() {
+		i, err := r.scanIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, *i)
+	}
+	return identities, rows.Err()
+}
+
+// UseIdentity records a sign-in with an identity and the email the
+// provider reported
+func (r *IdentityRepository) UseIdentity(ctx context.Context, id uuid.UUID, email string, at time.Time) error {
+	encrypted, err := r.f.encrypt(fieldIdentityEmail, email)
+	if err != nil {
+		return err
+	}
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE user_identities SET email = ?, last_used_at = ? WHERE id = ?`,
+		encrypted, at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update identity: %w", err)
+	}
+	return expectRows(res)
+}
+
+// DeleteIdentity unlinks one of a user's identities
+func (r *IdentityRepository) DeleteIdentity(ctx context.Context, userID, id uuid.UUID) error {
+	res, err := r.q.ExecContext(ctx, `
+		DELETE FROM user_identities WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete identity: %w", err)
+	}
+	return expectRows(res)
+}
+
+func (r *IdentityRepository) scanIdentity(row scanner) (*models.UserIdentity, error) {
+	var i models.UserIdentity
+	err := row.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt, &i.LastUsedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if i.Email, err = r.f.decrypt(fieldIdentityEmail, i.Email); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
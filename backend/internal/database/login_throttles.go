@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chainforge/internal/models"
+)
+
+// ThrottleRepository persists failed sign-in counters. Subjects are
+// stored as blind indexes so the table does not list addresses and IPs.
+type ThrottleRepository struct {
+	q querier
+	f *fieldCodec
+}
+
+// GetThrottle returns the counter for subject, or ErrNotFound if it has no
+// recorded failures
+func (r *ThrottleRepository) GetThrottle(ctx context.Context, scope models.ThrottleScope, subject string) (*models.LoginThrottle, error) {
+	var t models.LoginThrottle
+	err := r.q.QueryRowContext(ctx, `
+		SELECT scope, subject, failures, last_failure_at, locked_until
+		FROM login_throttles WHERE scope = ? AND subject = ?`,
+		scope, r.f.blindIndex(fieldThrottleKey, subject),
+	).Scan(&t.Scope, &t.Subject, &t.Failures, &t.LastFailureAt, &t.LockedUntil)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &t, nil
+}
+
+// RecordFailure counts a failed sign-in at now and returns the updated
+// counter. Failures before now minus window are forgotten first.
+func (r *ThrottleRepository) RecordFailure(ctx context.Context, scope models.ThrottleScope, subject string, now time.Time, window time.Duration) (*models.LoginThrottle, error) {
+	hash := r.f.blindIndex(fieldThrottleKey, subject)
+	var t models.LoginThrottle
+	err := inTx(ctx, r.q, func(q querier) error {
+		_, err := q.ExecContext(ctx, `
+			INSERT INTO login_throttles (scope, subject, failures, last_failure_at)
+			VALUES (?, ?, 1, ?)
+			ON CONFLICT (scope, subject) DO UPDATE SET
+				failures = CASE WHEN last_failure_at <= ? THEN 1 ELSE failures + 1 END,
+				last_failure_at = excluded.last_failure_at`,
+			scope, hash, now.UTC(), now.Add(-window).UTC())
+		if err != nil {
+			return fmt.Errorf("failed to record login failure: %w", err)
+		}
+		return q.QueryRowContext(ctx, `
+			SELECT scope, subject, failures, last_failure_at, locked_until
+			FROM login_throttles WHERE scope = ? AND subject = ?`,
+			scope, hash,
+		).Scan(&t.Scope, &t.Subject, &t.Failures, &t.LastFailureAt, &t.LockedUntil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// LockThrottle locks sign-ins for subject until the given time
+func (r *ThrottleRepository) LockThrottle(ctx context.Context, scope models.ThrottleScope, subject string, until time.Time) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE login_throttles SET locked_until = ? WHERE scope = ? AND subject = ?`,
+		until.UTC(), scope, r.f.blindIndex(fieldThrottleKey, subject))
+	if err != nil {
+		return fmt.Errorf("failed to lock login: %w", err)
+	}
+	return expectRows(res)
+}
+
+// ClearThrottle forgets the failures and lock of subject
+func (r *ThrottleRepository) ClearThrottle(ctx context.Context, scope models.ThrottleScope, subject string) error {
+	_, err := r.q.ExecContext(ctx, `DELETE FROM login_throttles WHERE scope = ? AND subject = ?`,
+		scope, r.f.blindIndex(fieldThrottleKey, subject))
+	if err != nil {
+		return fmt.Errorf("failed to clear login throttle: %w", err)
+	}
+	return nil
+}
+
+// DeleteStaleThrottles removes up to limit unlocked counters whose last
+// failure was before the given time
+func (r *ThrottleRepository) DeleteStaleThrottles(ctx context.Context, before time.Time, limit int) (int, error) {
+	res, err := r.q.ExecContext(ctx, `
+		DELETE FROM login_throttles WHERE rowid IN (
+			SELECT rowid FROM login_throttles
+			WHERE last_failure_at <= ? AND (locked_until IS NULL OR locked_until <= ?)
+			LIMIT ?)`,
+		before.UTC(), before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete stale login throttles: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	return int(n), nil
+}
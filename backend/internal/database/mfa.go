@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/models"
+)
+
+// MFARepository persists TOTP credentials and recovery codes
+type MFARepository struct {
+	q querier
+	f *fieldCodec
+}
+
+const totpColumns = `id, user_id, secret, confirmed_at, last_used_step, created_at`
+
+// GetTOTP returns a user's TOTP credential, confirmed or not
+func (r *MFARepository) GetTOTP(ctx context.Context, userID uuid.UUID) (*models.TOTPCredential, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+totpColumns+` FROM totp_credentials WHERE user_id = ?`, userID)
+	var c models.TOTPCredential
+	if err := row.Scan(&c.ID, &c.UserID, &c.Secret, &c.ConfirmedAt, &c.LastUsedStep, &c.CreatedAt); err != nil {
+		return nil, notFound(err)
+	}
+	secret, err := r.f.decrypt(fieldTOTPSecret, c.Secret)
+	if err != nil {
+		return nil, err
+	}
+	c.Secret = secret
+	return &c, nil
+}
+
+// SaveTOTP stores a user's TOTP credential, replacing any existing one
+func (r *MFARepository) SaveTOTP(ctx context.Context, c *models.TOTPCredential) error {
+	secret, err := r.f.encrypt(fieldTOTPSecret, c.Secret)
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, `
+		INSERT INTO totp_credentials (`+totpColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			id = excluded.id,
+			secret = excluded.secret,
+			confirmed_at = excluded.confirmed_at,
+			last_used_step = excluded.last_used_step,
+			created_at = excluded.created_at`,
+		c.ID, c.UserID, secret, c.ConfirmedAt, c.LastUsedStep, c.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save TOTP credential: %w", err)
+	}
+	return nil
+}
+
+// ConfirmTOTP enables a pending credential, recording step as used. It
+// returns ErrNotFound if there is no pending credential.
+func (r *MFARepository) ConfirmTOTP(ctx context.Context, userID uuid.UUID, step int64, at time.Time) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE totp_credentials SET confirmed_at = ?, last_used_step = ?
+		WHERE user_id = ? AND confirmed_at IS NULL`,
+		at.UTC(), step, userID)
+	if err != nil {
+		return fmt.Errorf("failed to confirm TOTP credential: %w", err)
+	}
+	return expectRows(res)
+}
+
+// UseTOTPStep records that a code from step was accepted. It returns
+// ErrNotFound if that step or a later one was already used, so a code
+// cannot be replayed.
+func (r *MFARepository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE totp_credentials SET last_used_step = ?
+		WHERE user_id = ? AND confirmed_at IS NOT NULL AND last_used_step < ?`,
+		step, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to record TOTP use: %w", err)
+	}
+	return expectRows(res)
+}
+
+// DeleteTOTP removes a user's TOTP credential and recovery codes
+func (r *MFARepository) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	return inTx(ctx, r.q, func(q querier) error {
+		if _, err := q.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		res, err := q.ExecContext(ctx, `DELETE FROM totp_credentials WHERE user_id = ?`, userID)
+		if err != nil {
+			return fmt.Errorf("failed to delete TOTP credential: %w", err)
+		}
+		return expectRows(res)
+	})
+}
+
+// ReplaceRecoveryCodes swaps a user's recovery codes for new hashes
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, hashes []string, at time.Time) error {
+	return inTx(ctx, r.q, func(q querier) error {
+		if _, err := q.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		for _, hash := range hashes {
+			_, err := q.ExecContext(ctx, `
+				INSERT INTO recovery_codes (id, user_id, code_hash, created_at) VALUES (?, ?, ?, ?)`,
+				uuid.New(), userID, hash, at.UTC())
+			if err != nil {
+				return fmt.Errorf("failed to store recovery code: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// UseRecoveryCode marks the unused recovery code with the given hash as
+// used. It returns ErrNotFound if there is none.
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string, at time.Time) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE recovery_codes SET used_at = ?
+		WHERE id = (
+			SELECT id FROM recovery_codes
+			WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+			LIMIT 1)`,
+		at.UTC(), userID, hash)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return expectRows(res)
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has
+func (r *MFARepository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	var n int
+	err := r.q.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL`, userID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return n, nil
+}
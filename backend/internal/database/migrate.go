@@ -0,0 +1,451 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"chainforge/migrations"
+)
+
+// baselineTable is created by the first migration; a database that has it
+// but no schema_migrations table predates migration tracking
+const baselineTable = "users"
+
+// Migration is a single versioned schema change loaded from the migrations directory.
+// Up files are named NNN_name.sql or NNN_name.up.sql; the optional paired
+// down file is NNN_name.down.sql.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// HasDown reports whether the migration can be reverted
+func (m Migration) HasDown() bool {
+	return strings.TrimSpace(m.Down) != ""
+}
+
+// MigrationStatus describes whether a migration has been applied
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Modified  bool       `json:"modified"`
+	Missing   bool       `json:"missing"`
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Migrator applies and reverts migrations, recording each applied version
+// and the checksum of its up file in schema_migrations
+type Migrator struct {
+	db         *DB
+	migrations []Migration
+}
+
+// NewMigrator loads the embedded migration files
+func NewMigrator(db *DB) (*Migrator, error) {
+	return NewMigratorFS(db, migrations.FS)
+}
+
+// NewMigratorFS loads migration files from the root of fsys
+func NewMigratorFS(db *DB, fsys fs.FS) (*Migrator, error) {
+	loaded, err := loadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: loaded}, nil
+}
+
+// RunMigrations verifies previously applied migrations and applies any pending ones
+func RunMigrations(db *DB) error {
+	m, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	_, err = m.Up(context.Background(), 0)
+	return err
+}
+
+// Migrations returns the loaded migrations in version order
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Status reports every known migration, plus applied versions whose files are gone.
+// It never writes: until schema_migrations exists every migration is pending.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	known := make(map[int64]bool, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+		status := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if row, ok := applied[mig.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.Modified = row.Checksum != mig.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+
+	for version, row := range applied {
+		if known[version] {
+			continue
+		}
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, MigrationStatus{
+			Version:   version,
+			Name:      row.Name,
+			Applied:   true,
+			AppliedAt: &appliedAt,
+			Missing:   true,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Verify returns an error if an applied migration file was edited or removed
+func (m *Migrator) Verify(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		switch {
+		case s.Modified:
+			return fmt.Errorf("migration %03d_%s was modified after it was applied (checksum mismatch)", s.Version, s.Name)
+		case s.Missing:
+			return fmt.Errorf("migration %03d_%s was applied but its file no longer exists", s.Version, s.Name)
+		}
+	}
+	return nil
+}
+
+// Up applies up to n pending migrations in version order. n <= 0 applies all of them.
+func (m *Migrator) Up(ctx context.Context, n int) ([]Migration, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	if err := m.Verify(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mig := range m.migrations {
+		if n > 0 && len(done) >= n {
+			break
+		}
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		if err := m.apply(ctx, mig); err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down reverts the n most recently applied migrations. n <= 0 reverts one.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	if n <= 0 {
+		n = 1
+	}
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	if err := m.Verify(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < n; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if !mig.HasDown() {
+			return done, fmt.Errorf("migration %03d_%s has no down migration", mig.Version, mig.Name)
+		}
+		if err := m.revert(ctx, mig); err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, mig Migration) error {
+	return m.withoutForeignKeys(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+			return fmt.Errorf("failed to apply migration %03d_%s: %w", mig.Version, mig.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`,
+			mig.Version, mig.Name, mig.Checksum, time.Now().UTC(),
+		); err != nil {
+			return fmt.Errorf("failed to record migration %03d: %w", mig.Version, err)
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) revert(ctx context.Context, mig Migration) error {
+	return m.withoutForeignKeys(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+			return fmt.Errorf("failed to revert migration %03d_%s: %w", mig.Version, mig.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, mig.Version); err != nil {
+			return fmt.Errorf("failed to unrecord migration %03d: %w", mig.Version, err)
+		}
+		return nil
+	})
+}
+
+// withoutForeignKeys runs fn in a transaction with foreign keys off, so a
+// migration can rebuild a table that others reference without cascading
+// deletes into them. Foreign keys are checked before the transaction
+// commits instead.
+func (m *Migrator) withoutForeignKeys(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	// foreign_keys cannot be changed inside a transaction
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return fmt.Errorf("failed to disable foreign keys: %w", err)
+	}
+	err = runMigrationTx(ctx, conn, fn)
+	if _, fkErr := conn.ExecContext(context.Background(), `PRAGMA foreign_keys = ON`); fkErr != nil {
+		// Keep the connection out of the pool rather than hand it out
+		// without foreign keys
+		conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		if err == nil {
+			err = fmt.Errorf("failed to re-enable foreign keys: %w", fkErr)
+		}
+	}
+	return err
+}
+
+// runMigrationTx runs fn in a transaction on conn, committing it only if no
+// row is left referencing a missing one
+func runMigrationTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	var table, parent string
+	var rowID sql.NullInt64
+	var constraint int
+	err = tx.QueryRowContext(ctx, `PRAGMA foreign_key_check`).Scan(&table, &rowID, &parent, &constraint)
+	switch {
+	case err == nil:
+		return fmt.Errorf("migration leaves rows in %s referencing missing %s rows", table, parent)
+	case !errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration: %w", err)
+	}
+	return nil
+}
+
+// ensureTable creates schema_migrations. Databases created before migrations
+// were tracked already contain the initial schema, so the first migration is
+// recorded as applied instead of being run again.
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	exists, err := m.tableExists(ctx, "schema_migrations")
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	baseline, err := m.tableExists(ctx, baselineTable)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin schema_migrations setup: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		CREATE TABLE schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	if baseline && len(m.migrations) > 0 {
+		first := m.migrations[0]
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`,
+			first.Version, first.Name, first.Checksum, time.Now().UTC(),
+		); err != nil {
+			return fmt.Errorf("failed to record baseline migration: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) tableExists(ctx context.Context, name string) (bool, error) {
+	var count int
+	err := m.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name,
+	).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	return count > 0, nil
+}
+
+// applied returns the rows of schema_migrations, or none if the table does not exist yet
+func (m *Migrator) applied(ctx context.Context) (map[int64]appliedMigration, error) {
+	exists, err := m.tableExists(ctx, "schema_migrations")
+	if err != nil || !exists {
+		return map[int64]appliedMigration{}, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		applied[a.Version] = a
+	}
+	return applied, rows.Err()
+}
+
+// loadMigrations reads and pairs the up and down files in fsys
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, file := range files {
+		version, name, direction, err := parseMigrationName(file)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: name}
+			byVersion[version] = mig
+		} else if mig.Name != name {
+			return nil, fmt.Errorf("migration version %03d is used by both %q and %q", version, mig.Name, name)
+		}
+
+		switch direction {
+		case "up":
+			if mig.Up != "" {
+				return nil, fmt.Errorf("migration %03d has more than one up file", version)
+			}
+			mig.Up = string(content)
+			sum := sha256.Sum256(content)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		case "down":
+			mig.Down = string(content)
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %03d_%s has a down file but no up file", mig.Version, mig.Name)
+		}
+		result = append(result, *mig)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+// parseMigrationName splits "001_initial_schema.down.sql" into its version, name and direction
+func parseMigrationName(file string) (int64, string, string, error) {
+	base := strings.TrimSuffix(path.Base(file), ".sql")
+	direction := "up"
+	switch {
+	case strings.HasSuffix(base, ".up"):
+		base = strings.TrimSuffix(base, ".up")
+	case strings.HasSuffix(base, ".down"):
+		base = strings.TrimSuffix(base, ".down")
+		direction = "down"
+	}
+
+	prefix, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", "", fmt.Errorf("invalid migration file name %q (expected NNN_name.sql)", file)
+	}
+	version, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("invalid migration version in %q", file)
+	}
+	return version, name, direction, nil
+}
@@ -0,0 +1,268 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/models"
+)
+
+// testMigrations creates tables a, b and c; b has no down file
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"001_create_a.up.sql":   {Data: []byte(`CREATE TABLE a (id INTEGER PRIMARY KEY);`)},
+		"001_create_a.down.sql": {Data: []byte(`DROP TABLE a;`)},
+		"002_create_b.sql":      {Data: []byte(`CREATE TABLE b (id INTEGER PRIMARY KEY);`)},
+		"003_create_c.up.sql":   {Data: []byte(`CREATE TABLE c (id INTEGER PRIMARY KEY);`)},
+		"003_create_c.down.sql": {Data: []byte(`DROP TABLE c;`)},
+	}
+}
+
+func newTestMigrator(t *testing.T, db *DB, fsys fstest.MapFS) *Migrator {
+	t.Helper()
+	m, err := NewMigratorFS(db, fsys)
+	if err != nil {
+		t.Fatalf("NewMigratorFS: %v", err)
+	}
+	return m
+}
+
+func hasTable(t *testing.T, m *Migrator, name string) bool {
+	t.Helper()
+	exists, err := m.tableExists(context.Background(), name)
+	if err != nil {
+		t.Fatalf("tableExists(%s): %v", name, err)
+	}
+	return exists
+}
+
+func TestMigratorUpAndDown(t *testing.T) {
+	db, _ := openTestDB(t, "")
+	m := newTestMigrator(t, db, testMigrations())
+	ctx := context.Background()
+
+	done, err := m.Up(ctx, 2)
+	if err != nil {
+		t.Fatalf("Up(2): %v", err)
+	}
+	if len(done) != 2 || done[1].Version != 2 || hasTable(t, m, "c") {
+		t.Fatalf("Up(2) applied %+v", done)
+	}
+	if done, err = m.Up(ctx, 0); err != nil || len(done) != 1 || done[0].Version != 3 {
+		t.Fatalf("Up(0) = %+v, %v; want migration 3", done, err)
+	}
+	if done, err = m.Up(ctx, 0); err != nil || len(done) != 0 {
+		t.Fatalf("Up with nothing pending = %+v, %v", done, err)
+	}
+
+	// Down runs the down file and forgets the version
+	if done, err = m.Down(ctx, 1); err != nil || len(done) != 1 || done[0].Version != 3 {
+		t.Fatalf("Down(1) = %+v, %v; want migration 3", done, err)
+	}
+	if hasTable(t, m, "c") {
+		t.Error("down file for migration 3 did not run")
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if statuses[2].Applied || !statuses[1].Applied {
+		t.Errorf("statuses = %+v, want 1 and 2 applied", statuses)
+	}
+
+	// Migration 2 has no down file, so reverting two stops there
+	if done, err = m.Down(ctx, 2); err == nil || len(done) != 0 {
+		t.Errorf("Down past a migration without a down file = %+v, %v", done, err)
+	}
+	if !hasTable(t, m, "b") {
+		t.Error("migration 2 was reverted")
+	}
+}
+
+func TestMigratorStatusIsReadOnly(t *testing.T) {
+	db, _ := openTestDB(t, "")
+	m := newTestMigrator(t, db, testMigrations())
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(statuses) != 3 {
+		t.Fatalf("Status returned %d migrations, want 3", len(statuses))
+	}
+	for _, s := range statuses {
+		if s.Applied {
+			t.Errorf("migration %d reported applied on an empty database", s.Version)
+		}
+	}
+	if hasTable(t, m, "schema_migrations") {
+		t.Error("Status created schema_migrations")
+	}
+}
+
+func TestMigratorRefusesChangedHistory(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		edit func(fstest.MapFS)
+		want string
+	}{
+		{"checksum mismatch", func(fsys fstest.MapFS) {
+			fsys["002_create_b.sql"] = &fstest.MapFile{Data: []byte(`CREATE TABLE b (id INTEGER PRIMARY KEY, name TEXT);`)}
+		}, "checksum mismatch"},
+		{"missing file", func(fsys fstest.MapFS) {
+			delete(fsys, "002_create_b.sql")
+		}, "no longer exists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := openTestDB(t, "")
+			if _, err := newTestMigrator(t, db, testMigrations()).Up(ctx, 2); err != nil {
+				t.Fatalf("Up: %v", err)
+			}
+
+			fsys := testMigrations()
+			tt.edit(fsys)
+			m := newTestMigrator(t, db, fsys)
+			if err := m.Verify(ctx); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Verify = %v, want %q", err, tt.want)
+			}
+			if _, err := m.Up(ctx, 0); err == nil {
+				t.Error("Up applied migrations over a changed history")
+			}
+			if _, err := m.Down(ctx, 1); err == nil {
+				t.Error("Down reverted migrations over a changed history")
+			}
+			if hasTable(t, m, "c") {
+				t.Error("pending migration ran")
+			}
+		})
+	}
+}
+
+func TestMigratorAdoptsBaselineSchema(t *testing.T) {
+	db, _ := openTestDB(t, "")
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, `CREATE TABLE users (id TEXT PRIMARY KEY)`); err != nil {
+		t.Fatalf("create users: %v", err)
+	}
+
+	// Running the first migration again would fail on the existing table
+	fsys := fstest.MapFS{
+		"001_initial_schema.sql": {Data: []byte(`CREATE TABLE users (id TEXT PRIMARY KEY);`)},
+		"002_create_b.sql":       {Data: []byte(`CREATE TABLE b (id INTEGER PRIMARY KEY);`)},
+	}
+	m := newTestMigrator(t, db, fsys)
+	done, err := m.Up(ctx, 0)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(done) != 1 || done[0].Version != 2 {
+		t.Errorf("Up applied %+v, want only migration 2", done)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !statuses[0].Applied || statuses[0].Modified {
+		t.Errorf("baseline status = %+v, want applied", statuses[0])
+	}
+}
+
+func TestLoadMigrationsRejectsBadFiles(t *testing.T) {
+	invalid := map[string]fstest.MapFS{
+		"no name":          {"001.sql": {}},
+		"no version":       {"initial_schema.sql": {}},
+		"version zero":     {"000_initial.sql": {}},
+		"negative version": {"-01_initial.sql": {}},
+		"down without up":  {"001_initial.down.sql": {}},
+		"duplicate version": {
+			"001_initial.sql": {},
+			"001_other.sql":   {},
+		},
+		"two up files": {
+			"001_initial.sql":    {},
+			"001_initial.up.sql": {},
+		},
+	}
+	for name, fsys := range invalid {
+		if _, err := loadMigrations(fsys); err == nil {
+			t.Errorf("%s: loadMigrations succeeded", name)
+		}
+	}
+
+	version, name, direction, err := parseMigrationName("012_goal_progress.down.sql")
+	if err != nil || version != 12 || name != "goal_progress" || direction != "down" {
+		t.Errorf("parseMigrationName = %d, %q, %q, %v", version, name, direction, err)
+	}
+}
+
+// createTestGoal creates a decreasing goal for a new user
+func createTestGoal(t *testing.T, db *DB, target float64) *models.Goal {
+	t.Helper()
+	user := createTestUser(t, db, uuid.NewString()+"@example.com")
+	start := 90.0
+	goal := models.NewGoal(user.ID, models.CreateGoalRequest{
+		Name:         "Weight",
+		Direction:    models.DirectionDecrease,
+		StartAmount:  &start,
+		TargetAmount: target,
+		Unit:         "kg",
+		Category:     models.CategoryHealth,
+		StartDate:    time.Now().UTC(),
+	})
+	if err := db.Goals().Create(context.Background(), goal); err != nil {
+		t.Fatalf("Create goal: %v", err)
+	}
+	return goal
+}
+
+func TestGoalRebuildKeepsReferencingRows(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	goal := createTestGoal(t, db, 80)
+	if err := db.Goals().AddProgress(ctx, models.NewGoalProgress(goal.ID, 85, nil, nil)); err != nil {
+		t.Fatalf("AddProgress: %v", err)
+	}
+
+	// Reverting and reapplying the rebuild must not cascade into progress
+	m, err := NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	if done, err := m.Down(ctx, 1); err != nil || len(done) != 1 || done[0].Name != "goal_directions" {
+		t.Fatalf("Down(1) = %+v, %v", done, err)
+	}
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	entries, err := db.Goals().ListProgress(ctx, goal.ID, 10)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("progress after rebuild = %+v, %v", entries, err)
+	}
+
+	// The progress triggers still update goals after the rebuild
+	measured := createTestGoal(t, db, 80)
+	if err := db.Goals().AddProgress(ctx, models.NewGoalProgress(measured.ID, 82, nil, nil)); err != nil {
+		t.Fatalf("AddProgress: %v", err)
+	}
+	got, err := db.Goals().GetByID(ctx, measured.ID)
+	if err != nil || got.Direction != models.DirectionDecrease || got.CurrentAmount != 82 {
+		t.Fatalf("goal after rebuild = %+v, %v; want decreasing at 82", got, err)
+	}
+
+	// A goal may aim for zero, which the old constraint cannot hold
+	createTestGoal(t, db, 0)
+	if _, err := m.Down(ctx, 1); err == nil {
+		t.Error("Down dropped the zero target constraint with a zero target goal")
+	}
+	if _, err := db.Exec(`INSERT INTO goal_progress (id, goal_id, amount, date) VALUES (?, ?, 1, ?)`,
+		uuid.New(), uuid.New(), time.Now()); err == nil {
+		t.Error("foreign keys are off after migrating")
+	}
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/models"
+)
+
+// OutboxRepository persists emails waiting to be delivered
+type OutboxRepository struct {
+	q querier
+	f *fieldCodec
+}
+
+const outboxColumns = `id, user_id, template, recipient, subject, text_body, html_body, status, attempts, next_attempt_at, last_error, created_at`
+
+// EnqueueEmail adds an email to the outbox
+func (r *OutboxRepository) EnqueueEmail(ctx context.Context, e *models.OutboxEmail) error {
+	recipient, err := r.f.encrypt(fieldOutboxTo, e.Recipient)
+	if err != nil {
+		return err
+	}
+	text, err := r.f.encrypt(fieldOutboxText, e.TextBody)
+	if err != nil {
+		return err
+	}
+	html, err := r.f.encrypt(fieldOutboxHTML, e.HTMLBody)
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, `
+		INSERT INTO email_outbox (`+outboxColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.ID, e.UserID, e.Template, recipient, e.Subject, text, html, e.Status, e.Attempts,
+		e.NextAttemptAt.UTC(), e.LastError, e.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue email: %w", err)
+	}
+	return nil
+}
+
+// ClaimEmails returns up to limit pending emails that are due at now and
+// leases them until leaseUntil, so other workers skip them while they are
+// being sent
+func (r *OutboxRepository) ClaimEmails(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.OutboxEmail, error) {
+	var emails []models.OutboxEmail
+	err := inTx(ctx, r.q, func(q querier) error {
+		rows, err := q.QueryContext(ctx, `
+			SELECT `+outboxColumns+` FROM email_outbox
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at LIMIT ?`,
+			models.OutboxPending, now.UTC(), limit)
+		if err != nil {
+			return fmt.Errorf("failed to list due emails: %w", err)
+		}
+		for rows.Next() {
+			e, err := r.scanEmail(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			emails = append(emails, *e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i := range emails {
+			if _, err := q.ExecContext(ctx, `UPDATE email_outbox SET next_attempt_at = ? WHERE id = ?`,
+				leaseUntil.UTC(), emails[i].ID); err != nil {
+				return fmt.Errorf("failed to claim email: %w", err)
+			}
+			emails[i].NextAttemptAt = leaseUntil.UTC()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
+// DeleteEmail removes an email once it has been sent
+func (r *OutboxRepository) DeleteEmail(ctx context.Context, id uuid.UUID) error {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM email_outbox WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete email: %w", err)
+	}
+	return expectRows(res)
+}
+
+// RetryEmail records a failed attempt and schedules the next one
+func (r *OutboxRepository) RetryEmail(ctx context.Context, id uuid.UUID, attempts int, nextAttemptAt time.Time, lastError string) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE email_outbox SET attempts = ?, next_attempt_at = ?, last_error = ?
+		WHERE id = ?`,
+		attempts, nextAttemptAt.UTC(), lastError, id)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule email: %w", err)
+	}
+	return expectRows(res)
+}
+
+// FailEmail records a final failed attempt and stops retrying the email
+func (r *OutboxRepository) FailEmail(ctx context.Context, id uuid.UUID, attempts int, lastError string) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE email_outbox SET status = ?, attempts = ?, last_error = ?
+		WHERE id = ?`,
+		models.OutboxFailed, attempts, lastError, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark email failed: %w", err)
+	}
+	return expectRows(res)
+}
+
+func (r *OutboxRepository) scanEmail(row scanner) (*models.OutboxEmail, error) {
+	var e models.OutboxEmail
+	err := row.Scan(&e.ID, &e.UserID, &e.Template, &e.Recipient, &e.Subject, &e.TextBody, &e.HTMLBody,
+		&e.Status, &e.Attempts, &e.NextAttemptAt, &e.LastError, &e.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if e.Recipient, err = r.f.decrypt(fieldOutboxTo, e.Recipient); err != nil {
+		return nil, err
+	}
+	if e.TextBody, err = r.f.decrypt(fieldOutboxText, e.TextBody); err != nil {
+		return nil, err
+	}
+	if e.HTMLBody, err = r.f.decrypt(fieldOutboxHTML, e.HTMLBody); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
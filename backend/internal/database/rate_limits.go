@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chainforge/internal/ratelimit"
+)
+
+// RateLimitRepository is a ratelimit.Store backed by SQLite, so request
+// counts survive restarts and are shared by every server process. Keys are
+// stored as blind indexes so the table does not list IPs.
+type RateLimitRepository struct {
+	q querier
+	f *fieldCodec
+}
+
+// NewRateLimitRepository creates a rate limit store on db
+func NewRateLimitRepository(db *DB) *RateLimitRepository {
+	return &RateLimitRepository{q: db.DB, f: db.fields}
+}
+
+// Hit counts a request against key
+func (r *RateLimitRepository) Hit(ctx context.Context, key string, window time.Duration, now time.Time) (int, time.Time, error) {
+	hash := r.f.blindIndex(fieldRateLimitKey, key)
+	var count int
+	var resetAt time.Time
+	err := inTx(ctx, r.q, func(q querier) error {
+		_, err := q.ExecContext(ctx, `
+			INSERT INTO rate_limits (key, hits, reset_at) VALUES (?, 1, ?)
+			ON CONFLICT (key) DO UPDATE SET
+				hits = CASE WHEN reset_at <= ? THEN 1 ELSE hits + 1 END,
+				reset_at = CASE WHEN reset_at <= ? THEN excluded.reset_at ELSE reset_at END`,
+			hash, now.Add(window).UTC(), now.UTC(), now.UTC())
+		if err != nil {
+			return fmt.Errorf("failed to count request: %w", err)
+		}
+		return q.QueryRowContext(ctx, `SELECT hits, reset_at FROM rate_limits WHERE key = ?`, hash).Scan(&count, &resetAt)
+	})
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return count, resetAt, nil
+}
+
+// Cleanup removes up to limit counters whose window has ended
+func (r *RateLimitRepository) Cleanup(ctx context.Context, limit int) (int, error) {
+	res, err := r.q.ExecContext(ctx, `
+		DELETE FROM rate_limits WHERE rowid IN (
+			SELECT rowid FROM rate_limits WHERE reset_at <= ? LIMIT ?)`,
+		time.Now().UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clean up rate limits: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	return int(n), nil
+}
+
+var _ ratelimit.Store = (*RateLimitRepository)(nil)
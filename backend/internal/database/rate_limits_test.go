@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRateLimitRepositoryHit(t *testing.T) {
+	db := newTestDB(t)
+	store := NewRateLimitRepository(db)
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	window := time.Minute
+
+	tests := []struct {
+		name    string
+		key     string
+		at      time.Time
+		count   int
+		resetAt time.Time
+	}{
+		{"first hit", "ip:192.0.2.1", now, 1, now.Add(window)},
+		{"same window", "ip:192.0.2.1", now.Add(30 * time.Second), 2, now.Add(window)},
+		{"other key", "ip:192.0.2.2", now.Add(30 * time.Second), 1, now.Add(30*time.Second + window)},
+		{"just before reset", "ip:192.0.2.1", now.Add(window - time.Nanosecond), 3, now.Add(window)},
+		// The window ends at reset_at, so a hit exactly then starts a new one
+		{"at reset", "ip:192.0.2.1", now.Add(window), 1, now.Add(2 * window)},
+		{"new window", "ip:192.0.2.1", now.Add(window + time.Second), 2, now.Add(2 * window)},
+	}
+	for _, tt := range tests {
+		count, resetAt, err := store.Hit(ctx, tt.key, window, tt.at)
+		if err != nil {
+			t.Fatalf("%s: Hit: %v", tt.name, err)
+		}
+		if count != tt.count || !resetAt.Equal(tt.resetAt) {
+			t.Errorf("%s: Hit = %d, %s; want %d, %s", tt.name, count, resetAt, tt.count, tt.resetAt)
+		}
+	}
+}
+
+func TestRateLimitRepositoryHashesKeys(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.EnableFieldEncryption(context.Background(), testFieldKey); err != nil {
+		t.Fatalf("EnableFieldEncryption: %v", err)
+	}
+	store := NewRateLimitRepository(db)
+
+	for i := 1; i <= 2; i++ {
+		count, _, err := store.Hit(context.Background(), "ip:192.0.2.1", time.Minute, time.Now())
+		if err != nil || count != i {
+			t.Fatalf("Hit %d = %d, %v", i, count, err)
+		}
+	}
+	var key string
+	if err := db.QueryRow(`SELECT key FROM rate_limits`).Scan(&key); err != nil {
+		t.Fatalf("read key: %v", err)
+	}
+	if key == "ip:192.0.2.1" {
+		t.Error("the key was stored in plaintext")
+	}
+}
+
+func TestRateLimitRepositoryCleanup(t *testing.T) {
+	db := newTestDB(t)
+	store := NewRateLimitRepository(db)
+	ctx := context.Background()
+
+	// Three counters whose window ended an hour ago and one still running
+	for i := 0; i < 3; i++ {
+		if _, _, err := store.Hit(ctx, fmt.Sprintf("ip:192.0.2.%d", i), time.Minute, time.Now().Add(-time.Hour)); err != nil {
+			t.Fatalf("Hit: %v", err)
+		}
+	}
+	if _, _, err := store.Hit(ctx, "ip:198.51.100.1", time.Hour, time.Now()); err != nil {
+		t.Fatalf("Hit: %v", err)
+	}
+
+	for _, want := range []int{2, 1, 0} {
+		removed, err := store.Cleanup(ctx, 2)
+		if err != nil || removed != want {
+			t.Fatalf("Cleanup(2) = %d, %v; want %d", removed, err, want)
+		}
+	}
+	if n := countRows(t, db, "rate_limits"); n != 1 {
+		t.Errorf("%d counters left, want the running one", n)
+	}
+	if count, _, err := store.Hit(ctx, "ip:198.51.100.1", time.Hour, time.Now()); err != nil || count != 2 {
+		t.Errorf("running counter after Cleanup = %d, %v; want 2", count, err)
+	}
+}
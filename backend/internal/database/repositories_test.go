@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"chainforge/internal/models"
+)
+
+func createTestUser(t *testing.T, db *DB, email string) *models.User {
+	t.Helper()
+	u := models.NewUser(email, "hash", "Ada", "Lovelace", "UTC")
+	if err := db.Users().Create(context.Background(), u); err != nil {
+		t.Fatalf("Create %s: %v", email, err)
+	}
+	return u
+}
+
+// createRunningGoal creates a 100 mile goal owned by user
+func createRunningGoal(t *testing.T, db *DB, user *models.User) *models.Goal {
+	t.Helper()
+	goal := models.NewGoal(user.ID, models.CreateGoalRequest{
+		Name:         "Run 100 miles",
+		TargetAmount: 100,
+		Unit:         "miles",
+		Category:     models.CategoryFitness,
+		StartDate:    time.Now().UTC(),
+	})
+	if err := db.Goals().Create(context.Background(), goal); err != nil {
+		t.Fatalf("Create goal: %v", err)
+	}
+	return goal
+}
+
+// currentAmount reads goals.current_amount as maintained by the triggers
+func currentAmount(t *testing.T, db *DB, goal *models.Goal) float64 {
+	t.Helper()
+	got, err := db.Goals().GetByID(context.Background(), goal.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	return got.CurrentAmount
+}
+
+func TestGoalProgressTriggersMaintainCurrentAmount(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	goal := createRunningGoal(t, db, createTestUser(t, db, "ada@example.com"))
+
+	first := models.NewGoalProgress(goal.ID, 3.5, nil, nil)
+	for _, p := range []*models.GoalProgress{first, models.NewGoalProgress(goal.ID, 6.5, nil, nil)} {
+		if err := db.Goals().AddProgress(ctx, p); err != nil {
+			t.Fatalf("AddProgress: %v", err)
+		}
+	}
+	if got := currentAmount(t, db, goal); got != 10 {
+		t.Fatalf("current_amount after insert = %v, want 10", got)
+	}
+
+	if _, err := db.ExecContext(ctx, `UPDATE goal_progress SET amount = 1.5 WHERE id = ?`, first.ID); err != nil {
+		t.Fatalf("update progress: %v", err)
+	}
+	if got := currentAmount(t, db, goal); got != 8 {
+		t.Fatalf("current_amount after update = %v, want 8", got)
+	}
+
+	if _, err := db.ExecContext(ctx, `DELETE FROM goal_progress WHERE goal_id = ?`, goal.ID); err != nil {
+		t.Fatalf("delete progress: %v", err)
+	}
+	if got := currentAmount(t, db, goal); got != 0 {
+		t.Fatalf("current_amount after delete = %v, want 0", got)
+	}
+}
+
+func TestGoalProgressConstraints(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	goal := createRunningGoal(t, db, createTestUser(t, db, "ada@example.com"))
+
+	if err := db.Goals().AddProgress(ctx, models.NewGoalProgress(goal.ID, -1, nil, nil)); err == nil {
+		t.Error("AddProgress accepted a negative amount")
+	}
+
+	orphan := models.NewGoalProgress(goal.ID, 1, nil, nil)
+	if err := db.Goals().Delete(ctx, goal.ID); err != nil {
+		t.Fatalf("Delete goal: %v", err)
+	}
+	if err := db.Goals().AddProgress(ctx, orphan); err == nil {
+		t.Error("AddProgress accepted progress for a deleted goal")
+	}
+}
+
+func TestUserEmailIsUnique(t *testing.T) {
+	db := newTestDB(t)
+	createTestUser(t, db, "ada@example.com")
+
+	dup := models.NewUser("ada@example.com", "hash", "Ada", "Byron", "UTC")
+	if err := db.Users().Create(context.Background(), dup); err == nil {
+		t.Fatal("Create accepted a duplicate email")
+	}
+}
+
+func TestDeleteUserCascades(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	user := createTestUser(t, db, "ada@example.com")
+	goal := createRunningGoal(t, db, user)
+	if err := db.Goals().AddProgress(ctx, models.NewGoalProgress(goal.ID, 2, nil, nil)); err != nil {
+		t.Fatalf("AddProgress: %v", err)
+	}
+
+	if err := db.Users().Delete(ctx, user.ID); err != nil {
+		t.Fatalf("Delete user: %v", err)
+	}
+	if _, err := db.Goals().GetByID(ctx, goal.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetByID after user delete: err = %v, want ErrNotFound", err)
+	}
+	var progress int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM goal_progress`).Scan(&progress); err != nil {
+		t.Fatalf("count progress: %v", err)
+	}
+	if progress != 0 {
+		t.Errorf("%d progress rows survived the user delete", progress)
+	}
+}
+
+func TestGroupUniqueConstraints(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	owner := createTestUser(t, db, "ada@example.com")
+
+	group := models.NewGroup("Morning Runners", nil, 10, false, owner.ID)
+	if err := db.Groups().Create(ctx, group); err != nil {
+		t.Fatalf("Create group: %v", err)
+	}
+	if err := db.Groups().AddMember(ctx, models.NewGroupMember(group.ID, owner.ID, models.RoleOwner)); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+	if err := db.Groups().AddMember(ctx, models.NewGroupMember(group.ID, owner.ID, models.RoleMember)); err == nil {
+		t.Error("AddMember accepted a second membership for the same user")
+	}
+
+	clash := models.NewGroup("Evening Runners", nil, 10, false, owner.ID)
+	clash.InviteCode = group.InviteCode
+	if err := db.Groups().Create(ctx, clash); err == nil {
+		t.Error("Create accepted a duplicate invite code")
+	}
+
+	groupGoal := models.NewGroupGoal(group.ID, "Weekly distance", "miles", "weekly", nil, owner.ID)
+	if err := db.Groups().CreateGoal(ctx, groupGoal); err != nil {
+		t.Fatalf("CreateGoal: %v", err)
+	}
+	start := time.Now().UTC().Truncate(24 * time.Hour)
+	period := models.NewGroupGoalPeriod(groupGoal.ID, start, start.AddDate(0, 0, 7))
+	if err := db.Groups().CreatePeriod(ctx, period); err != nil {
+		t.Fatalf("CreatePeriod: %v", err)
+	}
+	if err := db.Groups().CreateProgress(ctx, models.NewGroupGoalProgress(period.ID, owner.ID, 20, 0)); err != nil {
+		t.Fatalf("CreateProgress: %v", err)
+	}
+	if err := db.Groups().CreateProgress(ctx, models.NewGroupGoalProgress(period.ID, owner.ID, 25, 0)); err == nil {
+		t.Error("CreateProgress accepted a second row for the same period and user")
+	}
+}
+
+func TestWithTxRollsBack(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	boom := errors.New("boom")
+	err := db.WithTx(ctx, func(tx Store) error {
+		if err := tx.Users().Create(ctx, models.NewUser("ada@example.com", "hash", "Ada", "Lovelace", "UTC")); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("WithTx err = %v, want %v", err, boom)
+	}
+	if _, err := db.Users().GetByEmail(ctx, "ada@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetByEmail after rollback: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSeedDatabaseIsIdempotent(t *testing.T) {
+	db := newTestDB(t)
+	for i := 0; i < 2; i++ {
+		if err := SeedDatabase(db); err != nil {
+			t.Fatalf("SeedDatabase run %d: %v", i+1, err)
+		}
+	}
+
+	ctx := context.Background()
+	user, err := db.Users().GetByEmail(ctx, SeedUserEmail)
+	if err != nil {
+		t.Fatalf("GetByEmail: %v", err)
+	}
+	goals, err := db.Goals().ListByUser(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	if len(goals) != 2 {
+		t.Fatalf("seeded %d goals, want 2", len(goals))
+	}
+	for _, g := range goals {
+		progress, err := db.Goals().ListProgress(ctx, g.ID, 0)
+		if err != nil {
+			t.Fatalf("ListProgress: %v", err)
+		}
+		var sum float64
+		for _, p := range progress {
+			sum += p.Amount
+		}
+		if g.CurrentAmount != sum {
+			t.Errorf("goal %q current_amount = %v, want %v", g.Name, g.CurrentAmount, sum)
+		}
+	}
+}
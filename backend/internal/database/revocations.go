@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/auth"
+)
+
+// revocationSweepBatch bounds how many expired rows each write clears
+const revocationSweepBatch = 100
+
+// TokenRevocationRepository is a TokenRevocationStore backed by SQLite, so
+// revocations survive restarts and are shared by every server process
+type TokenRevocationRepository struct {
+	q         querier
+	retention time.Duration
+}
+
+// NewTokenRevocationRepository creates a revocation store on db. retention
+// is the longest lifetime of any token, which is how long user-wide cutoffs
+// are kept.
+func NewTokenRevocationRepository(db *DB, retention time.Duration) *TokenRevocationRepository {
+	return &TokenRevocationRepository{q: db.DB, retention: retention}
+}
+
+// Revoke marks a token or family ID as revoked until expiresAt
+func (r *TokenRevocationRepository) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO revoked_tokens (jti, expires_at) VALUES (?, ?)
+		ON CONFLICT(jti) DO UPDATE SET expires_at = MAX(expires_at, excluded.expires_at)`,
+		id, expiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return r.sweep(ctx)
+}
+
+// RevokeAllForUser revokes every token issued to userID before issuedBefore
+func (r *TokenRevocationRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID, issuedBefore time.Time) error {
+	cutoff := auth.RevocationCutoff(issuedBefore)
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO user_token_revocations (user_id, revoked_before, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			revoked_before = MAX(revoked_before, excluded.revoked_before),
+			expires_at = MAX(expires_at, excluded.expires_at)`,
+		userID, cutoff, cutoff.Add(r.retention))
+	if err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+	return r.sweep(ctx)
+}
+
+// IsRevoked reports whether the token described by claims was revoked
+func (r *TokenRevocationRepository) IsRevoked(ctx context.Context, claims *auth.Claims) (bool, error) {
+	now := time.Now().UTC()
+	issuedAt := time.Time{}
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time.UTC()
+	}
+
+	var revoked bool
+	err := r.q.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti IN (?, ?) AND expires_at > ?)
+			OR EXISTS (SELECT 1 FROM user_token_revocations
+				WHERE user_id = ? AND expires_at > ? AND revoked_before > ?)`,
+		claims.ID, claims.FamilyID.String(), now, claims.UserID, now, issuedAt,
+	).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	return revoked, nil
+}
+
+// Cleanup removes up to limit expired rows and returns how many it removed
+func (r *TokenRevocationRepository) Cleanup(ctx context.Context, limit int) (int, error) {
+	now := time.Now().UTC()
+	removed := 0
+	for _, table := range []string{"revoked_tokens", "user_token_revocations"} {
+		if removed >= limit {
+			break
+		}
+		res, err := r.q.ExecContext(ctx, `
+			DELETE FROM `+table+` WHERE rowid IN (
+				SELECT rowid FROM `+table+` WHERE expires_at <= ? LIMIT ?)`,
+			now, limit-removed)
+		if err != nil {
+			return removed, fmt.Errorf("failed to clean up %s: %w", table, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return removed, fmt.Errorf("failed to read affected rows: %w", err)
+		}
+		removed += int(n)
+	}
+	return removed, nil
+}
+
+// sweep clears a small batch of expired rows on every write so the tables
+// stay small without waiting for a background pass
+func (r *TokenRevocationRepository) sweep(ctx context.Context) error {
+	_, err := r.Cleanup(ctx, revocationSweepBatch)
+	return err
+}
+
+var _ auth.TokenRevocationStore = (*TokenRevocationRepository)(nil)
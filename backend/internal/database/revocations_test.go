@@ -0,0 +1,163 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"chainforge/internal/auth"
+)
+
+func testClaims(userID uuid.UUID, jti string, issuedAt time.Time) *auth.Claims {
+	return &auth.Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       jti,
+			IssuedAt: jwt.NewNumericDate(issuedAt),
+		},
+	}
+}
+
+func countRows(t *testing.T, db *DB, table string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return n
+}
+
+func TestTokenRevocationRepositoryRevokesByID(t *testing.T) {
+	db := newTestDB(t)
+	store := NewTokenRevocationRepository(db, time.Hour)
+	ctx := context.Background()
+	now := time.Now()
+
+	userID, familyID := uuid.New(), uuid.New()
+	claims := testClaims(userID, "token-1", now)
+	if err := store.Revoke(ctx, claims.ID, now.Add(time.Minute)); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	sibling := testClaims(userID, "token-2", now)
+	sibling.FamilyID = familyID
+	if err := store.Revoke(ctx, familyID.String(), now.Add(time.Minute)); err != nil {
+		t.Fatalf("Revoke family: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		claims   *auth.Claims
+		expected bool
+	}{
+		{"revoked JTI", claims, true},
+		{"revoked family", sibling, true},
+		{"unrelated token", testClaims(userID, "token-3", now), false},
+	}
+	for _, tt := range tests {
+		revoked, err := store.IsRevoked(ctx, tt.claims)
+		if err != nil || revoked != tt.expected {
+			t.Errorf("%s: IsRevoked = %v, %v; want %v", tt.name, revoked, err, tt.expected)
+		}
+	}
+
+	// Revoking again never shortens the entry, and an expired one no longer counts
+	if err := store.Revoke(ctx, claims.ID, now.Add(-time.Minute)); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if revoked, _ := store.IsRevoked(ctx, claims); !revoked {
+		t.Error("an earlier expiry shortened the revocation")
+	}
+	if err := store.Revoke(ctx, "expired", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if revoked, _ := store.IsRevoked(ctx, testClaims(userID, "expired", now)); revoked {
+		t.Error("an expired revocation is still honoured")
+	}
+}
+
+func TestTokenRevocationRepositoryRevokesAllForUser(t *testing.T) {
+	db := newTestDB(t)
+	store := NewTokenRevocationRepository(db, time.Hour)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	userID := uuid.New()
+
+	if err := store.RevokeAllForUser(ctx, userID, now.Add(500*time.Millisecond)); err != nil {
+		t.Fatalf("RevokeAllForUser: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		claims   *auth.Claims
+		expected bool
+	}{
+		{"issued earlier", testClaims(userID, "a", now.Add(-time.Second)), true},
+		{"issued in the same second", testClaims(userID, "b", now), false},
+		{"issued later", testClaims(userID, "c", now.Add(time.Second)), false},
+		{"other user", testClaims(uuid.New(), "d", now.Add(-time.Second)), false},
+	}
+	for _, tt := range tests {
+		revoked, err := store.IsRevoked(ctx, tt.claims)
+		if err != nil || revoked != tt.expected {
+			t.Errorf("%s: IsRevoked = %v, %v; want %v", tt.name, revoked, err, tt.expected)
+		}
+	}
+
+	// An earlier cutoff never overrides a later one
+	if err := store.RevokeAllForUser(ctx, userID, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("RevokeAllForUser: %v", err)
+	}
+	if revoked, _ := store.IsRevoked(ctx, tests[0].claims); !revoked {
+		t.Error("an earlier cutoff replaced the later one")
+	}
+	if err := store.RevokeAllForUser(ctx, userID, now.Add(2*time.Second)); err != nil {
+		t.Fatalf("RevokeAllForUser: %v", err)
+	}
+	if revoked, _ := store.IsRevoked(ctx, tests[2].claims); !revoked {
+		t.Error("a later cutoff did not move the existing one forward")
+	}
+	if n := countRows(t, db, "user_token_revocations"); n != 1 {
+		t.Errorf("%d cutoff rows for one user, want 1", n)
+	}
+}
+
+func TestTokenRevocationRepositoryCleanup(t *testing.T) {
+	db := newTestDB(t)
+	store := NewTokenRevocationRepository(db, time.Hour)
+	ctx := context.Background()
+	past, future := time.Now().Add(-time.Minute).UTC(), time.Now().Add(time.Hour).UTC()
+
+	// Seed directly: Revoke sweeps expired rows as it writes
+	for i := 0; i < 3; i++ {
+		if _, err := db.Exec(`INSERT INTO revoked_tokens (jti, expires_at) VALUES (?, ?)`, fmt.Sprintf("expired-%d", i), past); err != nil {
+			t.Fatalf("insert revoked token: %v", err)
+		}
+		if _, err := db.Exec(`INSERT INTO user_token_revocations (user_id, revoked_before, expires_at) VALUES (?, ?, ?)`, uuid.New(), past, past); err != nil {
+			t.Fatalf("insert user revocation: %v", err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO revoked_tokens (jti, expires_at) VALUES ('live', ?)`, future); err != nil {
+		t.Fatalf("insert revoked token: %v", err)
+	}
+
+	// Each call removes at most limit rows across both tables
+	for _, want := range []int{2, 2, 2, 0} {
+		removed, err := store.Cleanup(ctx, 2)
+		if err != nil || removed != want {
+			t.Fatalf("Cleanup(2) = %d, %v; want %d", removed, err, want)
+		}
+	}
+	if n := countRows(t, db, "revoked_tokens"); n != 1 {
+		t.Errorf("%d revoked tokens left, want the live one", n)
+	}
+	if n := countRows(t, db, "user_token_revocations"); n != 0 {
+		t.Errorf("%d user revocations left, want 0", n)
+	}
+	if revoked, _ := store.IsRevoked(ctx, testClaims(uuid.New(), "live", time.Now())); !revoked {
+		t.Error("Cleanup removed a live revocation")
+	}
+}
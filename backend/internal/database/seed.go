@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/models"
+)
+
+// Demo account credentials created by SeedDatabase
+const (
+	SeedUserEmail    = "demo@chainforge.app"
+	SeedUserPassword = "ChainForge!2024"
+)
+
+// SeedDatabase inserts a demo user with goals, progress and a group.
+// It does nothing if the demo user already exists.
+func SeedDatabase(db *DB) error {
+	ctx := context.Background()
+
+	if _, err := db.Users().GetByEmail(ctx, SeedUserEmail); err == nil {
+		return nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to check for seed user: %w", err)
+	}
+
+	hash, err := auth.HashPassword(SeedUserPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash seed password: %w", err)
+	}
+
+	return db.WithTx(ctx, func(tx Store) error {
+		user := models.NewUser(SeedUserEmail, hash, "Demo", "User", "America/Los_Angeles")
+		if err := tx.Users().Create(ctx, user); err != nil {
+			return err
+		}
+
+		if err := tx.Subscriptions().Create(ctx, models.NewSubscription(user.ID, models.PlanPremium)); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		endDate := now.AddDate(0, 1, 0)
+		description := "Build an aerobic base before the spring half marathon"
+		punishment := "Donate $20 to a charity of the group's choice"
+
+		goals := []models.CreateGoalRequest{
+			{
+				Name:         "Run 100 miles",
+				Description:  &description,
+				TargetAmount: 100,
+				Unit:         "miles",
+				Category:     models.CategoryFitness,
+				StartDate:    now.AddDate(0, 0, -14),
+				EndDate:      &endDate,
+				Punishment:   &punishment,
+			},
+			{
+				Name:         "Read 12 books",
+				TargetAmount: 12,
+				Unit:         "books",
+				Category:     models.CategoryEducation,
+				StartDate:    now.AddDate(0, 0, -30),
+			},
+		}
+
+		for i, req := range goals {
+			goal := models.NewGoal(user.ID, req)
+			if err := tx.Goals().Create(ctx, goal); err != nil {
+				return err
+			}
+			for day := 1; day <= 5; day++ {
+				date := now.AddDate(0, 0, -day)
+				progress := models.NewGoalProgress(goal.ID, float64(i+day), nil, &date)
+				if err := tx.Goals().AddProgress(ctx, progress); err != nil {
+					return err
+				}
+			}
+		}
+
+		groupDescription := "Weekly accountability for early risers"
+		group := models.NewGroup("Morning Runners", &groupDescription, 10, false, user.ID)
+		if err := tx.Groups().Create(ctx, group); err != nil {
+			return err
+		}
+		if err := tx.Groups().AddMember(ctx, models.NewGroupMember(group.ID, user.ID, models.RoleOwner)); err != nil {
+			return err
+		}
+
+		groupGoal := models.NewGroupGoal(group.ID, "Weekly distance", "miles", "weekly", nil, user.ID)
+		if err := tx.Groups().CreateGoal(ctx, groupGoal); err != nil {
+			return err
+		}
+
+		weekStart := now.Truncate(24 * time.Hour)
+		period := models.NewGroupGoalPeriod(groupGoal.ID, weekStart, weekStart.AddDate(0, 0, 7))
+		if err := tx.Groups().CreatePeriod(ctx, period); err != nil {
+			return err
+		}
+
+		return tx.Groups().CreateProgress(ctx, models.NewGroupGoalProgress(period.ID, user.ID, 20, 0))
+	})
+}
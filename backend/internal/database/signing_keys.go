@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"chainforge/internal/auth"
+)
+
+// SigningKeyRepository is a SigningKeyStore backed by SQLite. Private keys
+// are encrypted at rest once field encryption is enabled.
+type SigningKeyRepository struct {
+	q querier
+	f *fieldCodec
+}
+
+// NewSigningKeyRepository creates a signing key store on db
+func NewSigningKeyRepository(db *DB) *SigningKeyRepository {
+	return &SigningKeyRepository{q: db.DB, f: db.fields}
+}
+
+const signingKeyColumns = `id, algorithm, private_key, created_at, activates_at, retires_at, expires_at`
+
+// ListSigningKeys returns every key that has not expired by now
+func (r *SigningKeyRepository) ListSigningKeys(ctx context.Context, now time.Time) ([]auth.StoredSigningKey, error) {
+	rows, err := r.q.QueryContext(ctx,
+		`SELECT `+signingKeyColumns+` FROM signing_keys WHERE expires_at > ? ORDER BY activates_at`, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []auth.StoredSigningKey{}
+	for rows.Next() {
+		var k auth.StoredSigningKey
+		var private string
+		if err := rows.Scan(&k.ID, &k.Algorithm, &private, &k.CreatedAt, &k.ActivatesAt, &k.RetiresAt, &k.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan signing key: %w", err)
+		}
+		if private, err = r.f.decrypt(fieldSigningKey, private); err != nil {
+			return nil, err
+		}
+		if k.PrivateKey, err = base64.StdEncoding.DecodeString(private); err != nil {
+			return nil, fmt.Errorf("failed to decode signing key %s: %w", k.ID, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// CreateSigningKey stores a new key
+func (r *SigningKeyRepository) CreateSigningKey(ctx context.Context, k *auth.StoredSigningKey) error {
+	private, err := r.f.encrypt(fieldSigningKey, base64.StdEncoding.EncodeToString(k.PrivateKey))
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, `
+		INSERT INTO signing_keys (`+signingKeyColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		k.ID, k.Algorithm, private, k.CreatedAt.UTC(), k.ActivatesAt.UTC(), k.RetiresAt.UTC(), k.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store signing key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredSigningKeys removes keys that expired by now
+func (r *SigningKeyRepository) DeleteExpiredSigningKeys(ctx context.Context, now time.Time) (int, error) {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM signing_keys WHERE expires_at <= ?`, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired signing keys: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	return int(n), nil
+}
+
+var _ auth.SigningKeyStore = (*SigningKeyRepository)(nil)
@@ -0,0 +1,261 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/models"
+)
+
+// Store is the persistence boundary the services depend on. *DB implements it
+// over SQLite; tests can substitute an in-memory implementation.
+type Store interface {
+	Users() UserStore
+	Goals() GoalStore
+	Groups() GroupStore
+	Subscriptions() SubscriptionStore
+	Tokens() TokenStore
+	MFA() MFAStore
+	Audit() AuditStore
+	WebAuthn() WebAuthnStore
+	Identities() IdentityStore
+	EmailTokens() EmailTokenStore
+	Outbox() OutboxStore
+	Throttles() ThrottleStore
+	APIKeys() APIKeyStore
+
+	// WithTx runs fn against a Store bound to a single transaction. Calling
+	// WithTx on a transactional Store reuses the open transaction.
+	WithTx(ctx context.Context, fn func(tx Store) error) error
+}
+
+// UserStore persists users and their password history
+type UserStore interface {
+	Create(ctx context.Context, u *models.User) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetProfiles(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]models.UserProfile, error)
+	Update(ctx context.Context, u *models.User) error
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, id uuid.UUID, at time.Time) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	CountGroupsJoined(ctx context.Context, id uuid.UUID) (int, error)
+	CountGroupsAdministered(ctx context.Context, id uuid.UUID) (int, error)
+
+	AddPasswordHistory(ctx context.Context, userID uuid.UUID, passwordHash string, at time.Time, keep int) error
+	ListPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]models.PasswordHistoryEntry, error)
+}
+
+// GoalStore persists personal goals and their progress entries
+type GoalStore interface {
+	Create(ctx context.Context, g *models.Goal) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Goal, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Goal, error)
+	CountActiveByUser(ctx context.Context, userID uuid.UUID) (int, error)
+	Update(ctx context.Context, g *models.Goal) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	AddProgress(ctx context.Context, p *models.GoalProgress) error
+	ArchiveProgress(ctx context.Context, goalID uuid.UUID, archivedAt time.Time) (int, error)
+	ListProgress(ctx context.Context, goalID uuid.UUID, limit int) ([]models.GoalProgress, error)
+	ListProgressSince(ctx context.Context, goalID uuid.UUID, since time.Time) ([]models.GoalProgress, error)
+}
+
+// GroupStore persists groups, memberships, group goals, periods and progress
+type GroupStore interface {
+	Create(ctx context.Context, g *models.Group) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Group, error)
+	GetByInviteCode(ctx context.Context, code string) (*models.Group, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Group, error)
+	Update(ctx context.Context, g *models.Group) error
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	AddMember(ctx context.Context, m *models.GroupMember) error
+	GetMember(ctx context.Context, groupID, userID uuid.UUID) (*models.GroupMember, error)
+	ListMembers(ctx context.Context, groupID uuid.UUID) ([]models.GroupMember, error)
+	CountMembers(ctx context.Context, groupID uuid.UUID) (int, error)
+	UpdateMember(ctx context.Context, m *models.GroupMember) error
+
+	CreateGoal(ctx context.Context, g *models.GroupGoal) error
+	GetGoal(ctx context.Context, id uuid.UUID) (*models.GroupGoal, error)
+	ListGoals(ctx context.Context, groupID uuid.UUID) ([]models.GroupGoal, error)
+	ListActiveGoals(ctx context.Context) ([]models.GroupGoal, error)
+	UpdateGoal(ctx context.Context, g *models.GroupGoal) error
+	DeleteGoal(ctx context.Context, id uuid.UUID) error
+
+	CreatePeriod(ctx context.Context, p *models.GroupGoalPeriod) error
+	GetActivePeriod(ctx context.Context, groupGoalID uuid.UUID) (*models.GroupGoalPeriod, error)
+	ListExpiredPeriods(ctx context.Context, now time.Time) ([]models.GroupGoalPeriod, error)
+	DeactivatePeriod(ctx context.Context, id uuid.UUID) error
+
+	CreateProgress(ctx context.Context, p *models.GroupGoalProgress) error
+	GetProgress(ctx context.Context, periodID, userID uuid.UUID) (*models.GroupGoalProgress, error)
+	ListProgress(ctx context.Context, periodID uuid.UUID) ([]models.GroupGoalProgress, error)
+	UpdateProgress(ctx context.Context, p *models.GroupGoalProgress) error
+}
+
+// SubscriptionStore persists subscriptions, payment methods and invoices
+type SubscriptionStore interface {
+	Create(ctx context.Context, s *models.Subscription) error
+	GetByUser(ctx context.Context, userID uuid.UUID) (*models.Subscription, error)
+	GetByStripeSubscriptionID(ctx context.Context, stripeID string) (*models.Subscription, error)
+	GetByStripeCustomerID(ctx context.Context, customerID string) (*models.Subscription, error)
+	ListLapsed(ctx context.Context, now time.Time) ([]models.Subscription, error)
+	Update(ctx context.Context, s *models.Subscription) error
+
+	CreatePaymentMethod(ctx context.Context, pm *models.PaymentMethod) error
+	GetPaymentMethod(ctx context.Context, userID, id uuid.UUID) (*models.PaymentMethod, error)
+	ListPaymentMethods(ctx context.Context, userID uuid.UUID) ([]models.PaymentMethod, error)
+	DeletePaymentMethod(ctx context.Context, userID, id uuid.UUID) error
+	SetDefaultPaymentMethod(ctx context.Context, userID, id uuid.UUID) error
+
+	CreateInvoice(ctx context.Context, inv *models.Invoice) error
+	GetInvoice(ctx context.Context, userID, id uuid.UUID) (*models.Invoice, error)
+	GetInvoiceByStripeID(ctx context.Context, stripeInvoiceID string) (*models.Invoice, error)
+	ListInvoices(ctx context.Context, userID uuid.UUID) ([]models.Invoice, error)
+	UpdateInvoice(ctx context.Context, inv *models.Invoice) error
+}
+
+// TokenStore persists refresh token families, their sessions, issued
+// refresh tokens and security events
+type TokenStore interface {
+	CreateFamily(ctx context.Context, f *models.RefreshTokenFamily) error
+	GetFamily(ctx context.Context, id uuid.UUID) (*models.RefreshTokenFamily, error)
+	ExtendFamily(ctx context.Context, id uuid.UUID, expiresAt time.Time) error
+	RevokeFamily(ctx context.Context, id uuid.UUID, reason string, at time.Time) error
+	RevokeUserFamilies(ctx context.Context, userID uuid.UUID, reason string, at time.Time) error
+	DeleteExpiredFamilies(ctx context.Context, now time.Time, limit int) (int, error)
+
+	CreateSession(ctx context.Context, s *models.Session) error
+	ListSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]models.Session, error)
+	TouchSession(ctx context.Context, id uuid.UUID, client models.ClientInfo, at time.Time) error
+
+	CreateRefreshToken(ctx context.Context, t *models.RefreshToken) error
+	GetRefreshToken(ctx context.Context, jti string) (*models.RefreshToken, error)
+	UseRefreshToken(ctx context.Context, jti, replacedBy string, at time.Time) error
+
+	CreateSecurityEvent(ctx context.Context, e *models.SecurityEvent) error
+}
+
+// MFAStore persists TOTP credentials and recovery codes
+type MFAStore interface {
+	GetTOTP(ctx context.Context, userID uuid.UUID) (*models.TOTPCredential, error)
+	SaveTOTP(ctx context.Context, c *models.TOTPCredential) error
+	ConfirmTOTP(ctx context.Context, userID uuid.UUID, step int64, at time.Time) error
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error
+	DeleteTOTP(ctx context.Context, userID uuid.UUID) error
+
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, hashes []string, at time.Time) error
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string, at time.Time) error
+	CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error)
+}
+
+// AuditStore persists the audit log
+type AuditStore interface {
+	Create(ctx context.Context, e *models.AuditLog) error
+	ListByUser(ctx context.Context, userID uuid.UUID, limit int) ([]models.AuditLog, error)
+}
+
+// WebAuthnStore persists passkeys and the challenges issued for them
+type WebAuthnStore interface {
+	CreateChallenge(ctx context.Context, c *models.WebAuthnChallenge) error
+	TakeChallenge(ctx context.Context, id uuid.UUID, ceremony string, now time.Time) (*models.WebAuthnChallenge, error)
+
+	CreateCredential(ctx context.Context, c *models.WebAuthnCredential) error
+	GetCredential(ctx context.Context, credentialID []byte) (*models.WebAuthnCredential, error)
+	ListCredentials(ctx context.Context, userID uuid.UUID) ([]models.WebAuthnCredential, error)
+	UseCredential(ctx context.Context, id uuid.UUID, signCount uint32, at time.Time) error
+	RenameCredential(ctx context.Context, userID, id uuid.UUID, name string) error
+	DeleteCredential(ctx context.Context, userID, id uuid.UUID) error
+}
+
+// IdentityStore persists OpenID Connect identities and pending sign-ins
+type IdentityStore interface {
+	CreateState(ctx context.Context, s *models.OIDCState) error
+	TakeState(ctx context.Context, stateHash string, now time.Time) (*models.OIDCState, error)
+
+	CreateIdentity(ctx context.Context, i *models.UserIdentity) error
+	GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	ListIdentities(ctx context.Context, userID uuid.UUID) ([]models.UserIdentity, error)
+	UseIdentity(ctx context.Context, id uuid.UUID, email string, at time.Time) error
+	DeleteIdentity(ctx context.Context, userID, id uuid.UUID) error
+}
+
+// EmailTokenStore persists the single-use tokens mailed to users
+type EmailTokenStore interface {
+	CreateEmailToken(ctx context.Context, t *models.EmailToken) error
+	TakeEmailToken(ctx context.Context, purpose models.EmailTokenPurpose, tokenHash string, now time.Time) (*models.EmailToken, error)
+	RevokeEmailTokens(ctx context.Context, userID uuid.UUID, purpose models.EmailTokenPurpose, at time.Time) error
+}
+
+// OutboxStore persists emails waiting to be delivered
+type OutboxStore interface {
+	EnqueueEmail(ctx context.Context, e *models.OutboxEmail) error
+	ClaimEmails(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.OutboxEmail, error)
+	DeleteEmail(ctx context.Context, id uuid.UUID) error
+	RetryEmail(ctx context.Context, id uuid.UUID, attempts int, nextAttemptAt time.Time, lastError string) error
+	FailEmail(ctx context.Context, id uuid.UUID, attempts int, lastError string) error
+}
+
+// ThrottleStore persists failed sign-in counters per email and IP, and
+// failed second-factor checks per user
+type ThrottleStore interface {
+	GetThrottle(ctx context.Context, scope models.ThrottleScope, subject string) (*models.LoginThrottle, error)
+	RecordFailure(ctx context.Context, scope models.ThrottleScope, subject string, now time.Time, window time.Duration) (*models.LoginThrottle, error)
+	LockThrottle(ctx context.Context, scope models.ThrottleScope, subject string, until time.Time) error
+	ClearThrottle(ctx context.Context, scope models.ThrottleScope, subject string) error
+	DeleteStaleThrottles(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+// APIKeyStore persists personal API keys
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, k *models.APIKey) error
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error)
+	CountAPIKeys(ctx context.Context, userID uuid.UUID) (int, error)
+	TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error
+	DeleteAPIKey(ctx context.Context, userID, id uuid.UUID) error
+	DeleteUserAPIKeys(ctx context.Context, userID uuid.UUID) (int, error)
+}
+
+// txStore is a Store bound to an open transaction
+type txStore struct {
+	users         *UserRepository
+	goals         *GoalRepository
+	groups        *GroupRepository
+	subscriptions *SubscriptionRepository
+	tokens        *TokenRepository
+	mfa           *MFARepository
+	audit         *AuditRepository
+	webauthn      *WebAuthnRepository
+	identities    *IdentityRepository
+	emailTokens   *EmailTokenRepository
+	outbox        *OutboxRepository
+	throttles     *ThrottleRepository
+	apiKeys       *APIKeyRepository
+}
+
+func (s *txStore) Users() UserStore                 { return s.users }
+func (s *txStore) Goals() GoalStore                 { return s.goals }
+func (s *txStore) Groups() GroupStore               { return s.groups }
+func (s *txStore) Subscriptions() SubscriptionStore { return s.subscriptions }
+func (s *txStore) Tokens() TokenStore               { return s.tokens }
+func (s *txStore) MFA() MFAStore                    { return s.mfa }
+func (s *txStore) Audit() AuditStore                { return s.audit }
+func (s *txStore) WebAuthn() WebAuthnStore          { return s.webauthn }
+func (s *txStore) Identities() IdentityStore        { return s.identities }
+func (s *txStore) EmailTokens() EmailTokenStore     { return s.emailTokens }
+func (s *txStore) Outbox() OutboxStore              { return s.outbox }
+func (s *txStore) Throttles() ThrottleStore         { return s.throttles }
+func (s *txStore) APIKeys() APIKeyStore             { return s.apiKeys }
+
+// WithTx reuses the open transaction
+func (s *txStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	return fn(s)
+}
+
+var (
+	_ Store = (*DB)(nil)
+	_ Store = (*txStore)(nil)
+)
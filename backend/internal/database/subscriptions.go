@@ -0,0 +1,262 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/models"
+)
+
+// SubscriptionRepository persists subscriptions, payment methods and invoices
+type SubscriptionRepository struct {
+	q querier
+}
+
+const subscriptionColumns = `id, user_id, plan, status, stripe_customer_id, stripe_subscription_id, stripe_price_id,
+	trial_start_date, trial_end_date, current_period_start, current_period_end, canceled_at, created_at, updated_at`
+
+const paymentMethodColumns = `id, user_id, stripe_payment_method_id, type, brand, last4, expiry_month, expiry_year,
+	is_default, created_at, updated_at`
+
+const invoiceColumns = `id, user_id, subscription_id, stripe_invoice_id, amount, currency, status,
+	period_start, period_end, paid_at, due_date, invoice_url, created_at`
+
+// Create inserts a new subscription
+func (r *SubscriptionRepository) Create(ctx context.Context, s *models.Subscription) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO subscriptions (`+subscriptionColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.ID, s.UserID, s.Plan, s.Status, s.StripeCustomerID, s.StripeSubscriptionID, s.StripePriceID,
+		s.TrialStartDate, s.TrialEndDate, s.CurrentPeriodStart, s.CurrentPeriodEnd, s.CanceledAt,
+		s.CreatedAt, s.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create subscription: %w", err)
+	}
+	return nil
+}
+
+// GetByUser returns the subscription belonging to a user
+func (r *SubscriptionRepository) GetByUser(ctx context.Context, userID uuid.UUID) (*models.Subscription, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+subscriptionColumns+` FROM subscriptions WHERE user_id = ?`, userID)
+	return scanSubscription(row)
+}
+
+// GetByStripeSubscriptionID returns the subscription linked to a Stripe subscription
+func (r *SubscriptionRepository) GetByStripeSubscriptionID(ctx context.Context, stripeID string) (*models.Subscription, error) {
+	row := r.q.QueryRowContext(ctx,
+		`SELECT `+subscriptionColumns+` FROM subscriptions WHERE stripe_subscription_id = ?`, stripeID)
+	return scanSubscription(row)
+}
+
+// GetByStripeCustomerID returns the subscription linked to a Stripe customer
+func (r *SubscriptionRepository) GetByStripeCustomerID(ctx context.Context, customerID string) (*models.Subscription, error) {
+	row := r.q.QueryRowContext(ctx,
+		`SELECT `+subscriptionColumns+` FROM subscriptions WHERE stripe_customer_id = ?`, customerID)
+	return scanSubscription(row)
+}
+
+// ListLapsed returns trial or active subscriptions whose current period ended before now
+func (r *SubscriptionRepository) ListLapsed(ctx context.Context, now time.Time) ([]models.Subscription, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+subscriptionColumns+` FROM subscriptions
+		WHERE status IN ('trial', 'active', 'canceled') AND current_period_end IS NOT NULL AND current_period_end < ?`,
+		now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lapsed subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []models.Subscription{}
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *s)
+	}
+	return subs, rows.Err()
+}
+
+// Update saves a subscription
+func (r *SubscriptionRepository) Update(ctx context.Context, s *models.Subscription) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE subscriptions
+		SET plan = ?, status = ?, stripe_customer_id = ?, stripe_subscription_id = ?, stripe_price_id = ?,
+			trial_start_date = ?, trial_end_date = ?, current_period_start = ?, current_period_end = ?,
+			canceled_at = ?, updated_at = ?
+		WHERE id = ?`,
+		s.Plan, s.Status, s.StripeCustomerID, s.StripeSubscriptionID, s.StripePriceID,
+		s.TrialStartDate, s.TrialEndDate, s.CurrentPeriodStart, s.CurrentPeriodEnd,
+		s.CanceledAt, s.UpdatedAt, s.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+	return expectRows(res)
+}
+
+// CreatePaymentMethod inserts a payment method
+func (r *SubscriptionRepository) CreatePaymentMethod(ctx context.Context, pm *models.PaymentMethod) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO payment_methods (`+paymentMethodColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		pm.ID, pm.UserID, pm.StripePaymentMethodID, pm.Type, pm.Brand, pm.Last4,
+		pm.ExpiryMonth, pm.ExpiryYear, pm.IsDefault, pm.CreatedAt, pm.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create payment method: %w", err)
+	}
+	return nil
+}
+
+// GetPaymentMethod returns a user's payment method by ID
+func (r *SubscriptionRepository) GetPaymentMethod(ctx context.Context, userID, id uuid.UUID) (*models.PaymentMethod, error) {
+	row := r.q.QueryRowContext(ctx,
+		`SELECT `+paymentMethodColumns+` FROM payment_methods WHERE id = ? AND user_id = ?`, id, userID)
+	return scanPaymentMethod(row)
+}
+
+// ListPaymentMethods returns a user's payment methods, default first
+func (r *SubscriptionRepository) ListPaymentMethods(ctx context.Context, userID uuid.UUID) ([]models.PaymentMethod, error) {
+	rows, err := r.q.QueryContext(ctx,
+		`SELECT `+paymentMethodColumns+` FROM payment_methods WHERE user_id = ? ORDER BY is_default DESC, created_at DESC`,
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payment methods: %w", err)
+	}
+	defer rows.Close()
+
+	methods := []models.PaymentMethod{}
+	for rows.Next() {
+		pm, err := scanPaymentMethod(rows)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, *pm)
+	}
+	return methods, rows.Err()
+}
+
+// DeletePaymentMethod removes a user's payment method
+func (r *SubscriptionRepository) DeletePaymentMethod(ctx context.Context, userID, id uuid.UUID) error {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM payment_methods WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete payment method: %w", err)
+	}
+	return expectRows(res)
+}
+
+// SetDefaultPaymentMethod marks one payment method as default and clears the flag on the rest
+func (r *SubscriptionRepository) SetDefaultPaymentMethod(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := r.q.ExecContext(ctx,
+		`UPDATE payment_methods SET is_default = 0 WHERE user_id = ? AND is_default = 1`, userID); err != nil {
+		return fmt.Errorf("failed to clear default payment method: %w", err)
+	}
+	res, err := r.q.ExecContext(ctx,
+		`UPDATE payment_methods SET is_default = 1 WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to set default payment method: %w", err)
+	}
+	return expectRows(res)
+}
+
+// CreateInvoice inserts an invoice
+func (r *SubscriptionRepository) CreateInvoice(ctx context.Context, inv *models.Invoice) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO invoices (`+invoiceColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		inv.ID, inv.UserID, inv.SubscriptionID, inv.StripeInvoiceID, inv.Amount, inv.Currency, inv.Status,
+		inv.PeriodStart, inv.PeriodEnd, inv.PaidAt, inv.DueDate, inv.InvoiceURL, inv.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create invoice: %w", err)
+	}
+	return nil
+}
+
+// GetInvoice returns a user's invoice by ID
+func (r *SubscriptionRepository) GetInvoice(ctx context.Context, userID, id uuid.UUID) (*models.Invoice, error) {
+	row := r.q.QueryRowContext(ctx,
+		`SELECT `+invoiceColumns+` FROM invoices WHERE id = ? AND user_id = ?`, id, userID)
+	return scanInvoice(row)
+}
+
+// GetInvoiceByStripeID returns the invoice linked to a Stripe invoice
+func (r *SubscriptionRepository) GetInvoiceByStripeID(ctx context.Context, stripeInvoiceID string) (*models.Invoice, error) {
+	row := r.q.QueryRowContext(ctx,
+		`SELECT `+invoiceColumns+` FROM invoices WHERE stripe_invoice_id = ?`, stripeInvoiceID)
+	return scanInvoice(row)
+}
+
+// ListInvoices returns a user's invoices, newest first
+func (r *SubscriptionRepository) ListInvoices(ctx context.Context, userID uuid.UUID) ([]models.Invoice, error) {
+	rows, err := r.q.QueryContext(ctx,
+		`SELECT `+invoiceColumns+` FROM invoices WHERE user_id = ? ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invoices: %w", err)
+	}
+	defer rows.Close()
+
+	invoices := []models.Invoice{}
+	for rows.Next() {
+		inv, err := scanInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, *inv)
+	}
+	return invoices, rows.Err()
+}
+
+// UpdateInvoice saves an invoice's status and payment details
+func (r *SubscriptionRepository) UpdateInvoice(ctx context.Context, inv *models.Invoice) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE invoices SET status = ?, paid_at = ?, invoice_url = ? WHERE id = ?`,
+		inv.Status, inv.PaidAt, inv.InvoiceURL, inv.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update invoice: %w", err)
+	}
+	return expectRows(res)
+}
+
+func scanSubscription(s scanner) (*models.Subscription, error) {
+	var sub models.Subscription
+	err := s.Scan(
+		&sub.ID, &sub.UserID, &sub.Plan, &sub.Status, &sub.StripeCustomerID, &sub.StripeSubscriptionID,
+		&sub.StripePriceID, &sub.TrialStartDate, &sub.TrialEndDate, &sub.CurrentPeriodStart,
+		&sub.CurrentPeriodEnd, &sub.CanceledAt, &sub.CreatedAt, &sub.UpdatedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &sub, nil
+}
+
+func scanPaymentMethod(s scanner) (*models.PaymentMethod, error) {
+	var pm models.PaymentMethod
+	err := s.Scan(
+		&pm.ID, &pm.UserID, &pm.StripePaymentMethodID, &pm.Type, &pm.Brand, &pm.Last4,
+		&pm.ExpiryMonth, &pm.ExpiryYear, &pm.IsDefault, &pm.CreatedAt, &pm.UpdatedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &pm, nil
+}
+
+func scanInvoice(s scanner) (*models.Invoice, error) {
+	var inv models.Invoice
+	err := s.Scan(
+		&inv.ID, &inv.UserID, &inv.SubscriptionID, &inv.StripeInvoiceID, &inv.Amount, &inv.Currency,
+		&inv.Status, &inv.PeriodStart, &inv.PeriodEnd, &inv.PaidAt, &inv.DueDate, &inv.InvoiceURL, &inv.CreatedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &inv, nil
+}
@@ -0,0 +1,216 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/models"
+)
+
+// TokenRepository persists refresh token families, their sessions, issued
+// refresh tokens and security events
+type TokenRepository struct {
+	q querier
+	f *fieldCodec
+}
+
+const familyColumns = `id, user_id, created_at, expires_at, revoked_at, revoke_reason`
+
+const refreshTokenColumns = `jti, family_id, user_id, issued_at, expires_at, used_at, replaced_by`
+
+// CreateFamily inserts a new refresh token family
+func (r *TokenRepository) CreateFamily(ctx context.Context, f *models.RefreshTokenFamily) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO refresh_token_families (`+familyColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		f.ID, f.UserID, f.CreatedAt, f.ExpiresAt, f.RevokedAt, f.RevokeReason,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create token family: %w", err)
+	}
+	return nil
+}
+
+// GetFamily returns a refresh token family by ID
+func (r *TokenRepository) GetFamily(ctx context.Context, id uuid.UUID) (*models.RefreshTokenFamily, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+familyColumns+` FROM refresh_token_families WHERE id = ?`, id)
+	return scanFamily(row)
+}
+
+// ExtendFamily moves a family's expiry forward to cover a newly issued token
+func (r *TokenRepository) ExtendFamily(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE refresh_token_families SET expires_at = MAX(expires_at, ?) WHERE id = ?`,
+		expiresAt.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to extend token family: %w", err)
+	}
+	return expectRows(res)
+}
+
+// RevokeFamily marks a family as revoked. Revoking an already revoked family
+// keeps the original reason.
+func (r *TokenRepository) RevokeFamily(ctx context.Context, id uuid.UUID, reason string, at time.Time) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE refresh_token_families
+		SET revoked_at = COALESCE(revoked_at, ?), revoke_reason = COALESCE(revoke_reason, ?)
+		WHERE id = ?`,
+		at.UTC(), reason, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
+	}
+	return expectRows(res)
+}
+
+// RevokeUserFamilies revokes every live family belonging to a user
+func (r *TokenRepository) RevokeUserFamilies(ctx context.Context, userID uuid.UUID, reason string, at time.Time) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE refresh_token_families SET revoked_at = ?, revoke_reason = ?
+		WHERE user_id = ? AND revoked_at IS NULL`,
+		at.UTC(), reason, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token families: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredFamilies removes up to limit families whose newest token
+// expired before now, along with their tokens
+func (r *TokenRepository) DeleteExpiredFamilies(ctx context.Context, now time.Time, limit int) (int, error) {
+	res, err := r.q.ExecContext(ctx, `
+		DELETE FROM refresh_token_families WHERE id IN (
+			SELECT id FROM refresh_token_families WHERE expires_at <= ? LIMIT ?)`,
+		now.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired token families: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	return int(n), nil
+}
+
+// CreateSession records the device behind a new token family
+func (r *TokenRepository) CreateSession(ctx context.Context, s *models.Session) error {
+	ip, err := r.f.encrypt(fieldSessionIP, s.IPAddress)
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, `
+		INSERT INTO sessions (id, user_id, label, user_agent, ip_address, created_at, last_used_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		s.ID, s.UserID, s.Label, s.UserAgent, ip, s.CreatedAt.UTC(), s.LastUsedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// ListSessions returns a user's sessions whose family is neither revoked nor
+// expired, most recently used first
+func (r *TokenRepository) ListSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]models.Session, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT s.id, s.user_id, s.label, s.user_agent, s.ip_address, s.created_at, s.last_used_at, f.expires_at
+		FROM sessions s
+		JOIN refresh_token_families f ON f.id = s.id
+		WHERE s.user_id = ? AND f.revoked_at IS NULL AND f.expires_at > ?
+		ORDER BY s.last_used_at DESC`,
+		userID, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var s models.Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Label, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		if s.IPAddress, err = r.f.decrypt(fieldSessionIP, s.IPAddress); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// TouchSession records that a session was used from client at the given time
+func (r *TokenRepository) TouchSession(ctx context.Context, id uuid.UUID, client models.ClientInfo, at time.Time) error {
+	ip, err := r.f.encrypt(fieldSessionIP, client.IPAddress)
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, `
+		UPDATE sessions SET user_agent = ?, ip_address = ?, last_used_at = ? WHERE id = ?`,
+		client.UserAgent, ip, at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	return nil
+}
+
+// CreateRefreshToken records an issued refresh token
+func (r *TokenRepository) CreateRefreshToken(ctx context.Context, t *models.RefreshToken) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (`+refreshTokenColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		t.ID, t.FamilyID, t.UserID, t.IssuedAt.UTC(), t.ExpiresAt.UTC(), t.UsedAt, t.ReplacedBy,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+// GetRefreshToken returns an issued refresh token by its JWT ID
+func (r *TokenRepository) GetRefreshToken(ctx context.Context, jti string) (*models.RefreshToken, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+refreshTokenColumns+` FROM refresh_tokens WHERE jti = ?`, jti)
+	var t models.RefreshToken
+	err := row.Scan(&t.ID, &t.FamilyID, &t.UserID, &t.IssuedAt, &t.ExpiresAt, &t.UsedAt, &t.ReplacedBy)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &t, nil
+}
+
+// UseRefreshToken marks a refresh token as rotated into replacedBy. It
+// returns ErrNotFound if the token does not exist or was already used, so
+// two concurrent rotations cannot both succeed.
+func (r *TokenRepository) UseRefreshToken(ctx context.Context, jti, replacedBy string, at time.Time) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE refresh_tokens SET used_at = ?, replaced_by = ?
+		WHERE jti = ? AND used_at IS NULL`,
+		at.UTC(), replacedBy, jti)
+	if err != nil {
+		return fmt.Errorf("failed to use refresh token: %w", err)
+	}
+	return expectRows(res)
+}
+
+// CreateSecurityEvent records a security event
+func (r *TokenRepository) CreateSecurityEvent(ctx context.Context, e *models.SecurityEvent) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO security_events (id, user_id, event_type, details, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		e.ID, e.UserID, e.Type, e.Details, e.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record security event: %w", err)
+	}
+	return nil
+}
+
+func scanFamily(s scanner) (*models.RefreshTokenFamily, error) {
+	var f models.RefreshTokenFamily
+	err := s.Scan(&f.ID, &f.UserID, &f.CreatedAt, &f.ExpiresAt, &f.RevokedAt, &f.RevokeReason)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &f, nil
+}
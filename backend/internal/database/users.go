@@ -0,0 +1,279 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/models"
+)
+
+// UserRepository persists users. Email and names are encrypted at rest once
+// field encryption is enabled; email lookups then go through a blind index.
+// When a user verified their email is kept in email_verifications.
+type UserRepository struct {
+	q querier
+	f *fieldCodec
+}
+
+const userColumns = `id, email, password_hash, first_name, last_name, avatar, timezone, is_active, created_at, updated_at`
+
+// selectUsers reads userColumns of users u and the verification time of
+// their email
+const selectUsers = `
+	SELECT u.id, u.email, u.password_hash, u.first_name, u.last_name, u.avatar,
+		u.timezone, u.is_active, u.created_at, u.updated_at, v.verified_at
+	FROM users u LEFT JOIN email_verifications v ON v.user_id = u.id`
+
+// Create inserts a new user, marking their email verified if
+// EmailVerifiedAt is set. It returns ErrDuplicate if the email is taken.
+func (r *UserRepository) Create(ctx context.Context, u *models.User) error {
+	email, firstName, lastName, err := r.encryptNames(u)
+	if err != nil {
+		return err
+	}
+
+	return inTx(ctx, r.q, func(q querier) error {
+		_, err := q.ExecContext(ctx, `
+			INSERT INTO users (`+userColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			u.ID, email, u.Password, firstName, lastName, u.Avatar,
+			u.Timezone, u.IsActive, u.CreatedAt, u.UpdatedAt,
+		)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return ErrDuplicate
+			}
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		tx := &UserRepository{q: q, f: r.f}
+		if u.EmailVerifiedAt != nil {
+			if err := tx.MarkEmailVerified(ctx, u.ID, *u.EmailVerifiedAt); err != nil {
+				return err
+			}
+		}
+		return tx.setEmailIndex(ctx, u.ID.String(), u.Email)
+	})
+}
+
+// GetByID returns the user with the given ID
+func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	row := r.q.QueryRowContext(ctx, selectUsers+` WHERE u.id = ?`, id)
+	return r.scan(row)
+}
+
+// GetByEmail returns the user with the given email address
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	if !r.f.enabled() {
+		row := r.q.QueryRowContext(ctx,
+			selectUsers+` WHERE lower(u.email) = ?`, NormalizeEmail(email))
+		return r.scan(row)
+	}
+
+	row := r.q.QueryRowContext(ctx, selectUsers+`
+		WHERE u.id = (SELECT user_id FROM user_email_index WHERE email_hash = ?)`,
+		r.f.emailHash(email))
+	return r.scan(row)
+}
+
+// GetProfiles returns the public profiles for the given user IDs
+func (r *UserRepository) GetProfiles(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]models.UserProfile, error) {
+	profiles := make(map[uuid.UUID]models.UserProfile, len(ids))
+	for _, id := range ids {
+		if _, ok := profiles[id]; ok {
+			continue
+		}
+		u, err := r.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		profiles[id] = u.ToProfile()
+	}
+	return profiles, nil
+}
+
+// Update saves the mutable profile fields of a user
+func (r *UserRepository) Update(ctx context.Context, u *models.User) error {
+	email, firstName, lastName, err := r.encryptNames(u)
+	if err != nil {
+		return err
+	}
+
+	return inTx(ctx, r.q, func(q querier) error {
+		res, err := q.ExecContext(ctx, `
+			UPDATE users
+			SET email = ?, first_name = ?, last_name = ?, avatar = ?, timezone = ?, is_active = ?, updated_at = ?
+			WHERE id = ?`,
+			email, firstName, lastName, u.Avatar, u.Timezone, u.IsActive, u.UpdatedAt, u.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+		if err := expectRows(res); err != nil {
+			return err
+		}
+		return (&UserRepository{q: q, f: r.f}).setEmailIndex(ctx, u.ID.String(), u.Email)
+	})
+}
+
+// UpdatePassword replaces the stored password hash
+func (r *UserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	res, err := r.q.ExecContext(ctx, `UPDATE users SET password_hash = ? WHERE id = ?`, passwordHash, id)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	return expectRows(res)
+}
+
+// MarkEmailVerified records that a user verified their email at the given
+// time. A user who already verified keeps the earlier time.
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, at time.Time) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO email_verifications (user_id, verified_at) VALUES (?, ?)
+		ON CONFLICT(user_id) DO NOTHING`,
+		id, at.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to mark email verified: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a user and, through cascades, everything they own
+func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	return expectRows(res)
+}
+
+// CountGroupsJoined returns the number of active group memberships for a user
+func (r *UserRepository) CountGroupsJoined(ctx context.Context, id uuid.UUID) (int, error) {
+	var count int
+	err := r.q.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM group_members WHERE user_id = ? AND is_active = 1`, id,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count groups: %w", err)
+	}
+	return count, nil
+}
+
+// CountGroupsAdministered returns the number of active group memberships
+// in which a user is an owner or admin
+func (r *UserRepository) CountGroupsAdministered(ctx context.Context, id uuid.UUID) (int, error) {
+	var count int
+	err := r.q.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM group_members WHERE user_id = ? AND is_active = 1 AND role IN (?, ?)`,
+		id, models.RoleOwner, models.RoleAdmin,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count administered groups: %w", err)
+	}
+	return count, nil
+}
+
+// AddPasswordHistory records a password hash a user has set and forgets all
+// but the newest keep of their hashes
+func (r *UserRepository) AddPasswordHistory(ctx context.Context, userID uuid.UUID, passwordHash string, at time.Time, keep int) error {
+	return inTx(ctx, r.q, func(q querier) error {
+		_, err := q.ExecContext(ctx, `
+			INSERT INTO password_history (user_id, password_hash, created_at) VALUES (?, ?, ?)`,
+			userID, passwordHash, at.UTC())
+		if err != nil {
+			return fmt.Errorf("failed to record password history: %w", err)
+		}
+		_, err = q.ExecContext(ctx, `
+			DELETE FROM password_history WHERE user_id = ? AND rowid NOT IN (
+				SELECT rowid FROM password_history WHERE user_id = ?
+				ORDER BY created_at DESC, rowid DESC LIMIT ?)`,
+			userID, userID, max(keep, 1))
+		if err != nil {
+			return fmt.Errorf("failed to prune password history: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListPasswordHistory returns up to limit of a user's password hashes,
+// newest first
+func (r *UserRepository) ListPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]models.PasswordHistoryEntry, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT user_id, password_hash, created_at FROM password_history
+		WHERE user_id = ? ORDER BY created_at DESC, rowid DESC LIMIT ?`,
+		userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list password history: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.PasswordHistoryEntry{}
+	for rows.Next() {
+		var e models.PasswordHistoryEntry
+		if err := rows.Scan(&e.UserID, &e.PasswordHash, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan password history: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// setEmailIndex stores the blind index for a user's email. It is a no-op
+// while field encryption is disabled.
+func (r *UserRepository) setEmailIndex(ctx context.Context, userID, email string) error {
+	if !r.f.enabled() {
+		return nil
+	}
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO user_email_index (user_id, email_hash) VALUES (?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET email_hash = excluded.email_hash`,
+		userID, r.f.emailHash(email),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("failed to index email: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepository) encryptNames(u *models.User) (string, string, string, error) {
+	email, err := r.f.encrypt(fieldUserEmail, u.Email)
+	if err != nil {
+		return "", "", "", err
+	}
+	firstName, err := r.f.encrypt(fieldUserFirstName, u.FirstName)
+	if err != nil {
+		return "", "", "", err
+	}
+	lastName, err := r.f.encrypt(fieldUserLastName, u.LastName)
+	if err != nil {
+		return "", "", "", err
+	}
+	return email, firstName, lastName, nil
+}
+
+func (r *UserRepository) scan(s scanner) (*models.User, error) {
+	var u models.User
+	err := s.Scan(
+		&u.ID, &u.Email, &u.Password, &u.FirstName, &u.LastName, &u.Avatar,
+		&u.Timezone, &u.IsActive, &u.CreatedAt, &u.UpdatedAt, &u.EmailVerifiedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	if u.Email, err = r.f.decrypt(fieldUserEmail, u.Email); err != nil {
+		return nil, err
+	}
+	if u.FirstName, err = r.f.decrypt(fieldUserFirstName, u.FirstName); err != nil {
+		return nil, err
+	}
+	if u.LastName, err = r.f.decrypt(fieldUserLastName, u.LastName); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/models"
+)
+
+// WebAuthnRepository persists passkeys and WebAuthn challenges
+type WebAuthnRepository struct {
+	q querier
+}
+
+const webAuthnCredentialColumns = `id, user_id, credential_id, public_key, sign_count, aaguid, transports, name, created_at, last_used_at`
+
+// CreateChallenge stores a challenge and clears out expired ones
+func (r *WebAuthnRepository) CreateChallenge(ctx context.Context, c *models.WebAuthnChallenge) error {
+	return inTx(ctx, r.q, func(q querier) error {
+		if _, err := q.ExecContext(ctx, `DELETE FROM webauthn_challenges WHERE expires_at <= ?`, c.CreatedAt.UTC()); err != nil {
+			return fmt.Errorf("failed to delete expired WebAuthn challenges: %w", err)
+		}
+		_, err := q.ExecContext(ctx, `
+			INSERT INTO webauthn_challenges (id, user_id, ceremony, challenge, expires_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			c.ID, c.UserID, c.Ceremony, c.Challenge, c.ExpiresAt.UTC(), c.CreatedAt.UTC(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create WebAuthn challenge: %w", err)
+		}
+		return nil
+	})
+}
+
+// TakeChallenge deletes and returns an unexpired challenge issued for
+// ceremony. It returns ErrNotFound if there is none, so each challenge is
+// accepted at most once.
+func (r *WebAuthnRepository) TakeChallenge(ctx context.Context, id uuid.UUID, ceremony string, now time.Time) (*models.WebAuthnChallenge, error) {
+	var c models.WebAuthnChallenge
+	err := inTx(ctx, r.q, func(q querier) error {
+		row := q.QueryRowContext(ctx, `
+			SELECT id, user_id, ceremony, challenge, expires_at, created_at
+			FROM webauthn_challenges WHERE id = ? AND ceremony = ? AND expires_at > ?`,
+			id, ceremony, now.UTC())
+		if err := row.Scan(&c.ID, &c.UserID, &c.Ceremony, &c.Challenge, &c.ExpiresAt, &c.CreatedAt); err != nil {
+			return notFound(err)
+		}
+		res, err := q.ExecContext(ctx, `DELETE FROM webauthn_challenges WHERE id = ?`, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete WebAuthn challenge: %w", err)
+		}
+		return expectRows(res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// CreateCredential stores a passkey. It returns ErrDuplicate if the
+// credential is already registered.
+func (r *WebAuthnRepository) CreateCredential(ctx context.Context, c *models.WebAuthnCredential) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO webauthn_credentials (`+webAuthnCredentialColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.ID, c.UserID, encodeCredentialID(c.CredentialID), c.PublicKey, c.SignCount, c.AAGUID,
+		strings.Join(c.Transports, ","), c.Name, c.CreatedAt.UTC(), c.LastUsedAt,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("failed to create WebAuthn credential: %w", err)
+	}
+	return nil
+}
+
+// GetCredential returns the passkey with the authenticator's credential ID
+func (r *WebAuthnRepository) GetCredential(ctx context.Context, credentialID []byte) (*models.WebAuthnCredential, error) {
+	row := r.q.QueryRowContext(ctx, `
+		SELECT `+webAuthnCredentialColumns+` FROM webauthn_credentials WHERE credential_id = ?`,
+		encodeCredentialID(credentialID))
+	return scanWebAuthnCredential(row)
+}
+
+// ListCredentials returns a user's passkeys, oldest first
+func (r *WebAuthnRepository) ListCredentials(ctx context.Context, userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+webAuthnCredentialColumns+` FROM webauthn_credentials
+		WHERE user_id = ? ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list WebAuthn credentials: %w", err)
+	}
+	defer rows.Close()
+
+	creds := []models.WebAuthnCredential{}
+	for rows.Next() {
+		c, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, *c)
+	}
+	return creds, rows.Err()
+}
+
+// UseCredential records a sign-in with a passkey and its new signature
+// counter
+func (r *WebAuthnRepository) UseCredential(ctx context.Context, id uuid.UUID, signCount uint32, at time.Time) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE webauthn_credentials SET sign_count = ?, last_used_at = ? WHERE id = ?`,
+		signCount, at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update WebAuthn credential: %w", err)
+	}
+	return expectRows(res)
+}
+
+// RenameCredential changes the name of one of a user's passkeys
+func (r *WebAuthnRepository) RenameCredential(ctx context.Context, userID, id uuid.UUID, name string) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE webauthn_credentials SET name = ? WHERE id = ? AND user_id = ?`,
+		name, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to rename WebAuthn credential: %w", err)
+	}
+	return expectRows(res)
+}
+
+// DeleteCredential removes one of a user's passkeys
+func (r *WebAuthnRepository) DeleteCredential(ctx context.Context, userID, id uuid.UUID) error {
+	res, err := r.q.ExecContext(ctx, `
+		DELETE FROM webauthn_credentials WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete WebAuthn credential: %w", err)
+	}
+	return expectRows(res)
+}
+
+func scanWebAuthnCredential(row scanner) (*models.WebAuthnCredential, error) {
+	var c models.WebAuthnCredential
+	var credentialID, transports string
+	err := row.Scan(&c.ID, &c.UserID, &credentialID, &c.PublicKey, &c.SignCount, &c.AAGUID,
+		&transports, &c.Name, &c.CreatedAt, &c.LastUsedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if c.CredentialID, err = base64.RawURLEncoding.DecodeString(credentialID); err != nil {
+		return nil, fmt.Errorf("invalid stored credential ID: %w", err)
+	}
+	c.Transports = []string{}
+	if transports != "" {
+		c.Transports = strings.Split(transports, ",")
+	}
+	return &c, nil
+}
+
+// encodeCredentialID returns the stored form of a credential ID, which is
+// also how browsers encode it
+func encodeCredentialID(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
@@ -0,0 +1,41 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ConsoleSender writes the text of messages to a writer instead of
+// delivering them. It is meant for development, where links in emails are
+// followed from the server's output.
+type ConsoleSender struct {
+	cfg Config
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewConsoleSender creates a sender that writes messages to out
+func NewConsoleSender(cfg Config, out io.Writer) *ConsoleSender {
+	return &ConsoleSender{cfg: cfg, out: out}
+}
+
+// Send writes msg to the sender's writer
+func (s *ConsoleSender) Send(ctx context.Context, msg Message) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "----- email -----\n")
+	fmt.Fprintf(&b, "From: %s\n", s.cfg.From.String())
+	if s.cfg.ReplyTo != "" {
+		fmt.Fprintf(&b, "Reply-To: %s\n", s.cfg.ReplyTo)
+	}
+	fmt.Fprintf(&b, "To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, strings.TrimRight(msg.Text, "\n"))
+	fmt.Fprintf(&b, "-----------------\n")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := io.WriteString(s.out, b.String())
+	return err
+}
@@ -0,0 +1,48 @@
+// Package email renders and sends the messages the application mails to
+// users
+package email
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"os"
+)
+
+// Message is an email to a single recipient. HTML is optional; when it is
+// set the message carries both parts.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender delivers messages
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config selects the provider messages are sent through and the addresses
+// they are sent from
+type Config struct {
+	Provider string // "console", "smtp" or "file"
+	From     mail.Address
+	ReplyTo  string
+	SMTP     SMTPConfig
+	FileDir  string // Maildir the file provider writes to
+}
+
+// NewSender returns a Sender for cfg.Provider
+func NewSender(cfg Config) (Sender, error) {
+	switch cfg.Provider {
+	case "console":
+		return NewConsoleSender(cfg, os.Stdout), nil
+	case "smtp":
+		return NewSMTPSender(cfg)
+	case "file":
+		return NewFileSender(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported email provider %q", cfg.Provider)
+	}
+}
@@ -0,0 +1,240 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testConfig = Config{
+	From:    mail.Address{Name: "ChainForge", Address: "no-reply@chainforge.test"},
+	ReplyTo: "support@chainforge.test",
+}
+
+type linkData struct {
+	Name      string
+	Link      string
+	ExpiresIn time.Duration
+}
+
+func TestRenderFallsBackToClosestLocale(t *testing.T) {
+	templates, err := LoadTemplates()
+	if err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	data := linkData{Name: "Ada", Link: "https://app.chainforge.test/auth/verify-email?token=abc", ExpiresIn: 48 * time.Hour}
+
+	tests := []struct {
+		locale, subject, expires string
+	}{
+		{"de", "Bestätige deine E-Mail-Adresse", "2 Tage"},
+		{"de-AT", "Bestätige deine E-Mail-Adresse", "2 Tage"},
+		{"ES_mx", "Confirma tu dirección de correo", "2 días"},
+		{"fr-FR", "Confirm your email address", "2 days"},
+		{"", "Confirm your email address", "2 days"},
+	}
+	for _, tt := range tests {
+		msg, err := templates.Render("verify_email", tt.locale, data)
+		if err != nil {
+			t.Fatalf("Render(%q): %v", tt.locale, err)
+		}
+		if msg.Subject != tt.subject {
+			t.Errorf("Render(%q) subject = %q, want %q", tt.locale, msg.Subject, tt.subject)
+		}
+		for _, body := range []string{msg.Text, msg.HTML} {
+			if !strings.Contains(body, tt.expires) || !strings.Contains(body, "Ada") {
+				t.Errorf("Render(%q) body is missing the name or expiry:\n%s", tt.locale, body)
+			}
+		}
+	}
+
+	if _, err := templates.Render("no_such_email", "en", data); err == nil {
+		t.Error("rendering an unknown template succeeded")
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	templates, err := LoadTemplates()
+	if err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	msg, err := templates.Render("reset_password", "en", linkData{
+		Name:      `<script>alert(1)</script>`,
+		Link:      "https://app.chainforge.test/auth/reset-password?token=a&b",
+		ExpiresIn: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if strings.Contains(msg.HTML, "<script>") {
+		t.Error("name is not escaped in the HTML part")
+	}
+	if !strings.Contains(msg.HTML, `href="https://app.chainforge.test/auth/reset-password?token=a&amp;b"`) {
+		t.Errorf("HTML part does not link to the reset page:\n%s", msg.HTML)
+	}
+	if !strings.Contains(msg.Text, "?token=a&b") {
+		t.Errorf("text part link was altered:\n%s", msg.Text)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		locale string
+		d      time.Duration
+		want   string
+	}{
+		{"en", time.Hour, "1 hour"},
+		{"en", 90 * time.Minute, "90 minutes"},
+		{"en", 24 * time.Hour, "24 hours"},
+		{"en", 48 * time.Hour, "2 days"},
+		{"de", time.Hour, "1 Stunde"},
+		{"es", 72 * time.Hour, "3 días"},
+		{"xx", time.Minute, "1 minute"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.locale, tt.d); got != tt.want {
+			t.Errorf("formatDuration(%q, %s) = %q, want %q", tt.locale, tt.d, got, tt.want)
+		}
+	}
+}
+
+// parseMessage decodes a composed message into its headers and text and
+// HTML parts
+func parseMessage(t *testing.T, raw []byte) (*mail.Message, string, string) {
+	t.Helper()
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("Content-Type: %v", err)
+	}
+	if mediaType == "text/plain" {
+		body, _ := io.ReadAll(quotedprintable.NewReader(m.Body))
+		return m, string(body), ""
+	}
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q", mediaType)
+	}
+	var text, html string
+	r := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		// NextPart decodes quoted-printable parts
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		body, _ := io.ReadAll(p)
+		switch {
+		case strings.HasPrefix(p.Header.Get("Content-Type"), "text/plain"):
+			text = string(body)
+		case strings.HasPrefix(p.Header.Get("Content-Type"), "text/html"):
+			html = string(body)
+		}
+	}
+	return m, text, html
+}
+
+func TestCompose(t *testing.T) {
+	msg := Message{
+		To:      "ada@example.com",
+		Subject: "Bestätige deine E-Mail-Adresse",
+		Text:    "Hallo Ada,\n\nhttps://app.chainforge.test/auth/verify-email?token=abc=\n",
+		HTML:    "<p>Hallo Ada,</p>",
+	}
+	raw, err := compose(testConfig, msg, time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("compose: %v", err)
+	}
+	m, text, html := parseMessage(t, raw)
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("Subject = %q (%v), want %q", subject, err, msg.Subject)
+	}
+	if from, _ := m.Header.AddressList("From"); len(from) != 1 || from[0].Address != "no-reply@chainforge.test" {
+		t.Errorf("From = %v", from)
+	}
+	if got := m.Header.Get("Reply-To"); got != testConfig.ReplyTo {
+		t.Errorf("Reply-To = %q, want %q", got, testConfig.ReplyTo)
+	}
+	if got := m.Header.Get("Message-ID"); !strings.HasSuffix(got, "@chainforge.test>") {
+		t.Errorf("Message-ID = %q", got)
+	}
+	if text != strings.ReplaceAll(msg.Text, "\n", "\r\n") {
+		t.Errorf("text part = %q", text)
+	}
+	if html != msg.HTML {
+		t.Errorf("HTML part = %q", html)
+	}
+
+	if _, err := compose(testConfig, Message{To: "not an address"}, time.Now()); err == nil {
+		t.Error("composing to an invalid address succeeded")
+	}
+}
+
+func TestComposeTextOnly(t *testing.T) {
+	raw, err := compose(testConfig, Message{To: "ada@example.com", Subject: "Hi", Text: "Hello\n"}, time.Now())
+	if err != nil {
+		t.Fatalf("compose: %v", err)
+	}
+	_, text, html := parseMessage(t, raw)
+	if text != "Hello\r\n" || html != "" {
+		t.Errorf("text = %q, html = %q", text, html)
+	}
+}
+
+func TestFileSender(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "maildir")
+	cfg := testConfig
+	cfg.FileDir = dir
+	sender, err := NewFileSender(cfg)
+	if err != nil {
+		t.Fatalf("NewFileSender: %v", err)
+	}
+	for _, to := range []string{"ada@example.com", "grace@example.com"} {
+		if err := sender.Send(context.Background(), Message{To: to, Subject: "Hi", Text: "Hello"}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	delivered, _ := os.ReadDir(filepath.Join(dir, "new"))
+	if len(delivered) != 2 {
+		t.Fatalf("%d messages in new/, want 2", len(delivered))
+	}
+	if pending, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(pending) != 0 {
+		t.Errorf("%d messages left in tmp/", len(pending))
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "new", delivered[0].Name()))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if m, text, _ := parseMessage(t, raw); m.Header.Get("To") == "" || text != "Hello" {
+		t.Errorf("delivered message: To = %q, text = %q", m.Header.Get("To"), text)
+	}
+}
+
+func TestNewSender(t *testing.T) {
+	if _, err := NewSender(Config{Provider: "carrier-pigeon"}); err == nil {
+		t.Error("an unknown provider was accepted")
+	}
+	if _, err := NewSender(Config{Provider: "smtp"}); err == nil {
+		t.Error("SMTP without a host was accepted")
+	}
+	if _, err := NewSender(Config{Provider: "file"}); err == nil {
+		t.Error("file provider without a directory was accepted")
+	}
+}
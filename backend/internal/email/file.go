@@ -0,0 +1,60 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// FileSender delivers messages into a Maildir instead of sending them, so
+// tests and local setups can read what would have been mailed with any mail
+// client
+type FileSender struct {
+	cfg Config
+	dir string
+	seq atomic.Uint64
+	now func() time.Time
+}
+
+// NewFileSender creates a sender writing to the Maildir at cfg.FileDir,
+// creating it if needed
+func NewFileSender(cfg Config) (*FileSender, error) {
+	if cfg.FileDir == "" {
+		return nil, errors.New("email file directory is required")
+	}
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(cfg.FileDir, sub), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	return &FileSender{cfg: cfg, dir: cfg.FileDir, now: time.Now}, nil
+}
+
+// Send writes msg to tmp/ and moves it into new/ once complete, so readers
+// never see a partial message
+func (s *FileSender) Send(ctx context.Context, msg Message) error {
+	now := s.now()
+	data, err := compose(s.cfg, msg, now)
+	if err != nil {
+		return err
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	name := fmt.Sprintf("%d.P%dQ%d.%s", now.Unix(), os.Getpid(), s.seq.Add(1), host)
+
+	tmp := filepath.Join(s.dir, "tmp", name)
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, "new", name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPConfig is the server the SMTP provider relays messages through
+type SMTPConfig struct {
+	Host     string
+	Port     int // 465 uses implicit TLS, other ports upgrade with STARTTLS
+	Username string
+	Password string
+}
+
+// SMTPSender delivers messages through an SMTP relay
+type SMTPSender struct {
+	cfg Config
+
+	// tlsConfig is overridden in tests to trust the test server
+	tlsConfig *tls.Config
+	now       func() time.Time
+}
+
+// NewSMTPSender creates a sender relaying through cfg.SMTP
+func NewSMTPSender(cfg Config) (*SMTPSender, error) {
+	if cfg.SMTP.Host == "" {
+		return nil, errors.New("SMTP host is required")
+	}
+	if cfg.SMTP.Port == 0 {
+		cfg.SMTP.Port = 587
+	}
+	return &SMTPSender{
+		cfg:       cfg,
+		tlsConfig: &tls.Config{ServerName: cfg.SMTP.Host, MinVersion: tls.VersionTLS12},
+		now:       time.Now,
+	}, nil
+}
+
+// Send delivers msg. Servers that offer STARTTLS are always upgraded, and
+// credentials are only sent over TLS.
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	data, err := compose(s.cfg, msg, s.now())
+	if err != nil {
+		return err
+	}
+	rcpt, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.cfg.SMTP.Host, strconv.Itoa(s.cfg.SMTP.Port))
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	if s.cfg.SMTP.Port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: s.tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connect to SMTP server: %w", err)
+	}
+	// Bound the whole conversation by the context
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Minute)
+	}
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, s.cfg.SMTP.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP greeting: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(s.tlsConfig); err != nil {
+			return fmt.Errorf("SMTP STARTTLS: %w", err)
+		}
+	}
+	if s.cfg.SMTP.Username != "" {
+		if _, isTLS := c.TLSConnectionState(); !isTLS {
+			return errors.New("SMTP server does not support TLS, refusing to send credentials")
+		}
+		auth := smtp.PlainAuth("", s.cfg.SMTP.Username, s.cfg.SMTP.Password, s.cfg.SMTP.Host)
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP auth: %w", err)
+		}
+	}
+
+	if err := c.Mail(s.cfg.From.Address); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM: %w", err)
+	}
+	if err := c.Rcpt(rcpt.Address); err != nil {
+		return fmt.Errorf("SMTP RCPT TO: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("SMTP DATA: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP DATA: %w", err)
+	}
+	return c.Quit()
+}
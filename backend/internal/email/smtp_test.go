@@ -0,0 +1,197 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"net"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smtpServer is a minimal SMTP server that records what it is sent
+type smtpServer struct {
+	ln  net.Listener
+	tls *tls.Config // offered through STARTTLS when set
+
+	mu       sync.Mutex
+	from     string
+	rcpt     []string
+	data     string
+	auth     string
+	startTLS bool
+}
+
+func newSMTPServer(t *testing.T, tlsConfig *tls.Config) *smtpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	s := &smtpServer{ln: ln, tls: tlsConfig}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *smtpServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpServer) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+	secure := false
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			ext := []string{"250-localhost"}
+			if s.tls != nil && !secure {
+				ext = append(ext, "250-STARTTLS")
+			}
+			ext = append(ext, "250 AUTH PLAIN")
+			for _, l := range ext {
+				tp.PrintfLine("%s", l)
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+			tp = textproto.NewConn(conn)
+			s.mu.Lock()
+			s.startTLS = true
+			s.mu.Unlock()
+		case "AUTH":
+			_, creds, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(creds)
+			s.mu.Lock()
+			s.auth = string(decoded)
+			s.mu.Unlock()
+			tp.PrintfLine("235 ok")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = arg
+			s.mu.Unlock()
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			s.mu.Lock()
+			s.rcpt = append(s.rcpt, arg)
+			s.mu.Unlock()
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			lines, err := tp.ReadDotLines()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = strings.Join(lines, "\r\n")
+			s.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+func newTestSMTPSender(t *testing.T, srv *smtpServer, username string, roots *x509.CertPool) *SMTPSender {
+	t.Helper()
+	cfg := testConfig
+	cfg.SMTP = SMTPConfig{Host: "127.0.0.1", Port: srv.port(), Username: username, Password: "hunter2"}
+	sender, err := NewSMTPSender(cfg)
+	if err != nil {
+		t.Fatalf("NewSMTPSender: %v", err)
+	}
+	sender.tlsConfig.RootCAs = roots
+	return sender
+}
+
+func TestSMTPSender(t *testing.T) {
+	srv := newSMTPServer(t, nil)
+	sender := newTestSMTPSender(t, srv, "", nil)
+
+	msg := Message{To: "Ada Lovelace <ada@example.com>", Subject: "Hi", Text: "Hello", HTML: "<p>Hello</p>"}
+	if err := sender.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.from != "FROM:<no-reply@chainforge.test>" {
+		t.Errorf("MAIL %s", srv.from)
+	}
+	if len(srv.rcpt) != 1 || srv.rcpt[0] != "TO:<ada@example.com>" {
+		t.Errorf("RCPT %v", srv.rcpt)
+	}
+	if !strings.Contains(srv.data, "multipart/alternative") || !strings.Contains(srv.data, "Subject: Hi") {
+		t.Errorf("DATA:\n%s", srv.data)
+	}
+	if srv.auth != "" {
+		t.Error("credentials were sent without a username configured")
+	}
+}
+
+func TestSMTPSenderAuthenticatesOverSTARTTLS(t *testing.T) {
+	// Borrow httptest's certificate, which is valid for 127.0.0.1
+	https := httptest.NewTLSServer(nil)
+	defer https.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(https.Certificate())
+
+	srv := newSMTPServer(t, &tls.Config{Certificates: https.TLS.Certificates})
+	sender := newTestSMTPSender(t, srv, "apikey", roots)
+	if err := sender.Send(context.Background(), Message{To: "ada@example.com", Subject: "Hi", Text: "Hello"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if !srv.startTLS {
+		t.Error("connection was not upgraded with STARTTLS")
+	}
+	if srv.auth != "\x00apikey\x00hunter2" {
+		t.Errorf("AUTH PLAIN credentials = %q", srv.auth)
+	}
+	if srv.data == "" {
+		t.Error("message was not sent")
+	}
+}
+
+func TestSMTPSenderRefusesCredentialsWithoutTLS(t *testing.T) {
+	srv := newSMTPServer(t, nil)
+	sender := newTestSMTPSender(t, srv, "apikey", nil)
+	if err := sender.Send(context.Background(), Message{To: "ada@example.com", Subject: "Hi", Text: "Hello"}); err == nil {
+		t.Fatal("Send succeeded over a plaintext connection with credentials")
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.auth != "" || srv.data != "" {
+		t.Error("credentials or the message were sent in the clear")
+	}
+}
@@ -0,0 +1,150 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// DefaultLocale is used when no template exists for the requested locale
+const DefaultLocale = "en"
+
+//go:embed templates
+var templateFS embed.FS
+
+// Templates renders the emails in templates/. Each locale directory holds a
+// <name>.txt defining "subject" and "text" and a <name>.html defining the
+// "content" of the shared HTML layout.
+type Templates struct {
+	text map[string]*texttemplate.Template // keyed by locale/name
+	html map[string]*htmltemplate.Template
+}
+
+// LoadTemplates parses every embedded template, so a broken one fails at
+// startup instead of when it is first sent
+func LoadTemplates() (*Templates, error) {
+	t := &Templates{
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+	locales, err := fs.ReadDir(templateFS, "templates")
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range locales {
+		if !dir.IsDir() {
+			continue
+		}
+		locale := dir.Name()
+		files, err := fs.Glob(templateFS, path.Join("templates", locale, "*.txt"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			name := strings.TrimSuffix(path.Base(file), ".txt")
+			key := locale + "/" + name
+
+			text, err := texttemplate.New(name).Funcs(texttemplate.FuncMap(templateFuncs(locale))).ParseFS(templateFS, file)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", file, err)
+			}
+			t.text[key] = text
+
+			html, err := htmltemplate.New(name).Funcs(htmltemplate.FuncMap(templateFuncs(locale))).
+				ParseFS(templateFS, "templates/layout.html", file, strings.TrimSuffix(file, ".txt")+".html")
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", key+".html", err)
+			}
+			t.html[key] = html
+		}
+	}
+	if len(t.text) == 0 {
+		return nil, fmt.Errorf("no email templates found")
+	}
+	return t, nil
+}
+
+// Render renders the email name in the closest locale available: the exact
+// tag, then its base language, then DefaultLocale. The returned message has
+// no recipient.
+func (t *Templates) Render(name, locale string, data any) (Message, error) {
+	key, ok := t.resolve(name, locale)
+	if !ok {
+		return Message{}, fmt.Errorf("no email template %q", name)
+	}
+	var subject, text, html bytes.Buffer
+	if err := t.text[key].ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := t.text[key].ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, err
+	}
+	if err := t.html[key].ExecuteTemplate(&html, "html", data); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+func (t *Templates) resolve(name, locale string) (string, bool) {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	candidates := []string{locale}
+	if base, _, ok := strings.Cut(locale, "-"); ok {
+		candidates = append(candidates, base)
+	}
+	candidates = append(candidates, DefaultLocale)
+	for _, c := range candidates {
+		if _, ok := t.text[c+"/"+name]; ok {
+			return c + "/" + name, true
+		}
+	}
+	return "", false
+}
+
+// templateFuncs are the functions templates in locale can call
+func templateFuncs(locale string) map[string]any {
+	return map[string]any{
+		"locale":   func() string { return locale },
+		"duration": func(d time.Duration) string { return formatDuration(locale, d) },
+		"button": func(link, label string) map[string]string {
+			return map[string]string{"Link": link, "Label": label}
+		},
+	}
+}
+
+// durationUnits are the singular and plural unit names per locale
+var durationUnits = map[string]map[string][2]string{
+	"en": {"minute": {"minute", "minutes"}, "hour": {"hour", "hours"}, "day": {"day", "days"}},
+	"de": {"minute": {"Minute", "Minuten"}, "hour": {"Stunde", "Stunden"}, "day": {"Tag", "Tage"}},
+	"es": {"minute": {"minuto", "minutos"}, "hour": {"hora", "horas"}, "day": {"día", "días"}},
+}
+
+// formatDuration formats how long a link stays valid, e.g. "1 hour". Whole
+// days are used from two days on so a day-long link reads "24 hours".
+func formatDuration(locale string, d time.Duration) string {
+	unit, n := "minute", int(d.Round(time.Minute)/time.Minute)
+	switch {
+	case d >= 48*time.Hour && d%(24*time.Hour) == 0:
+		unit, n = "day", int(d/(24*time.Hour))
+	case d >= time.Hour && d%time.Hour == 0:
+		unit, n = "hour", int(d/time.Hour)
+	}
+	units, ok := durationUnits[locale]
+	if !ok {
+		units = durationUnits[DefaultLocale]
+	}
+	name := units[unit][1]
+	if n == 1 {
+		name = units[unit][0]
+	}
+	return fmt.Sprintf("%d %s", n, name)
+}
@@ -0,0 +1,263 @@
+// Package fieldcrypt implements application-level envelope encryption for
+// individual database columns.
+//
+// A key-encryption key (KEK) is derived from configuration and only ever
+// wraps randomly generated data keys. Each data key has a version, and every
+// ciphertext records the version that produced it so rows can be
+// re-encrypted after a rotation.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Prefix marks an encrypted value. The full format is enc:v<version>:<base64(nonce|ciphertext)>
+const Prefix = "enc:v"
+
+// minSealedSize is the length of a sealed empty value: a GCM nonce and tag
+const minSealedSize = 12 + 16
+
+// DataKeySize is the length of a data key in bytes (AES-256)
+const DataKeySize = 32
+
+var (
+	// ErrUnknownKeyVersion is returned when a ciphertext names a data key that is not loaded
+	ErrUnknownKeyVersion = errors.New("unknown field encryption key version")
+
+	// ErrNoActiveKey is returned when encrypting before any data key was added
+	ErrNoActiveKey = errors.New("no active field encryption key")
+
+	// ErrMalformedCiphertext is returned when a wrapped data key is too short to hold one
+	ErrMalformedCiphertext = errors.New("malformed encrypted field")
+)
+
+// Keyring holds the KEK, the blind index key and every unwrapped data key
+type Keyring struct {
+	kek      cipher.AEAD
+	indexKey []byte
+
+	mu     sync.RWMutex
+	keys   map[int]cipher.AEAD
+	active int
+}
+
+// NewKeyring derives the KEK and blind index key from masterKey
+func NewKeyring(masterKey string) (*Keyring, error) {
+	if masterKey == "" {
+		return nil, errors.New("field encryption master key is required")
+	}
+
+	kek, err := newAEAD(derive(masterKey, "chainforge/fieldcrypt/kek/v1", DataKeySize))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keyring{
+		kek:      kek,
+		indexKey: derive(masterKey, "chainforge/fieldcrypt/blind-index/v1", 32),
+		keys:     make(map[int]cipher.AEAD),
+	}, nil
+}
+
+// GenerateDataKey returns a new random data key
+func GenerateDataKey() ([]byte, error) {
+	key := make([]byte, DataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return key, nil
+}
+
+// WrapKey encrypts a data key with the KEK for storage
+func (k *Keyring) WrapKey(version int, dataKey []byte) ([]byte, error) {
+	nonce := make([]byte, k.kek.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return k.kek.Seal(nonce, nonce, dataKey, wrapAAD(version)), nil
+}
+
+// UnwrapKey decrypts a stored data key. It fails if the KEK is wrong.
+func (k *Keyring) UnwrapKey(version int, wrapped []byte) ([]byte, error) {
+	size := k.kek.NonceSize()
+	if len(wrapped) < size {
+		return nil, ErrMalformedCiphertext
+	}
+	key, err := k.kek.Open(nil, wrapped[:size], wrapped[size:], wrapAAD(version))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap field key v%d (is FIELD_ENCRYPTION_KEY correct?): %w", version, err)
+	}
+	return key, nil
+}
+
+// AddKey loads a data key. The highest version becomes the active key.
+func (k *Keyring) AddKey(version int, dataKey []byte) error {
+	if version <= 0 {
+		return fmt.Errorf("invalid key version %d", version)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[version] = aead
+	if version > k.active {
+		k.active = version
+	}
+	return nil
+}
+
+// HasKey reports whether the given version is loaded
+func (k *Keyring) HasKey(version int) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	_, ok := k.keys[version]
+	return ok
+}
+
+// ActiveVersion returns the version new values are encrypted with
+func (k *Keyring) ActiveVersion() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// ActivePrefix returns the prefix shared by every value encrypted with the active key
+func (k *Keyring) ActivePrefix() string {
+	return versionPrefix(k.ActiveVersion())
+}
+
+// Encrypt seals plaintext with the active data key. field is bound to the
+// ciphertext as associated data so values cannot be moved between columns.
+func (k *Keyring) Encrypt(field, plaintext string) (string, error) {
+	k.mu.RLock()
+	version := k.active
+	aead := k.keys[version]
+	k.mu.RUnlock()
+
+	if aead == nil {
+		return "", ErrNoActiveKey
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(field))
+	return versionPrefix(version) + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt. Values that are not in the
+// encrypted format are returned unchanged so rows written before encryption
+// was enabled stay readable until they are re-encrypted, even if they happen
+// to start with Prefix. A value in the format that does not open is an error,
+// never plaintext.
+func (k *Keyring) Decrypt(field, value string) (string, error) {
+	version, sealed, ok := parse(value)
+	if !ok {
+		return value, nil
+	}
+
+	k.mu.RLock()
+	aead := k.keys[version]
+	k.mu.RUnlock()
+	if aead == nil {
+		return "", fmt.Errorf("%w: v%d", ErrUnknownKeyVersion, version)
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", field, err)
+	}
+	return string(plaintext), nil
+}
+
+// BlindIndex returns a deterministic keyed hash of value for equality lookups.
+// It does not change when data keys are rotated.
+func (k *Keyring) BlindIndex(field, value string) string {
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted reports whether value is in the encrypted format: the prefix,
+// a key version and a payload long enough to hold a nonce and tag
+func IsEncrypted(value string) bool {
+	_, _, ok := parse(value)
+	return ok
+}
+
+// VersionOf returns the key version recorded in an encrypted value
+func VersionOf(value string) (int, bool) {
+	version, _, ok := parse(value)
+	return version, ok
+}
+
+// parse splits an encrypted value into its key version and sealed bytes. It
+// reports false unless the whole value is in the encrypted format.
+func parse(value string) (int, []byte, bool) {
+	rest, found := strings.CutPrefix(value, Prefix)
+	if !found {
+		return 0, nil, false
+	}
+	versionText, payload, found := strings.Cut(rest, ":")
+	if !found {
+		return 0, nil, false
+	}
+	version, err := strconv.Atoi(versionText)
+	if err != nil || version <= 0 || strconv.Itoa(version) != versionText {
+		return 0, nil, false
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < minSealedSize {
+		return 0, nil, false
+	}
+	return version, sealed, true
+}
+
+func versionPrefix(version int) string {
+	return Prefix + strconv.Itoa(version) + ":"
+}
+
+func wrapAAD(version int) []byte {
+	return []byte("chainforge/fieldcrypt/data-key/v" + strconv.Itoa(version))
+}
+
+func derive(masterKey, info string, size int) []byte {
+	reader := hkdf.New(sha256.New, []byte(masterKey), nil, []byte(info))
+	key := make([]byte, size)
+	if _, err := io.ReadFull(reader, key); err != nil {
+		panic(fmt.Sprintf("failed to derive %s: %v", info, err))
+	}
+	return key
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return aead, nil
+}
@@ -0,0 +1,202 @@
+package fieldcrypt
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testMasterKey = "test-field-master-key-32-characters"
+
+// newTestKeyring returns a keyring with a random data key for each version
+// from 1 to versions
+func newTestKeyring(t *testing.T, masterKey string, versions int) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(masterKey)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	for v := 1; v <= versions; v++ {
+		key, err := GenerateDataKey()
+		if err != nil {
+			t.Fatalf("GenerateDataKey: %v", err)
+		}
+		if err := k.AddKey(v, key); err != nil {
+			t.Fatalf("AddKey(%d): %v", v, err)
+		}
+	}
+	return k
+}
+
+func encrypt(t *testing.T, k *Keyring, field, plaintext string) string {
+	t.Helper()
+	value, err := k.Encrypt(field, plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	return value
+}
+
+func TestEncryptDecryptAcrossVersions(t *testing.T) {
+	k := newTestKeyring(t, testMasterKey, 1)
+	first := encrypt(t, k, "users.email", "ada@example.com")
+
+	key, err := GenerateDataKey()
+	if err != nil {
+		t.Fatalf("GenerateDataKey: %v", err)
+	}
+	if err := k.AddKey(2, key); err != nil {
+		t.Fatalf("AddKey: %v", err)
+	}
+	second := encrypt(t, k, "users.email", "ada@example.com")
+
+	tests := []struct {
+		value   string
+		version int
+	}{
+		{first, 1},
+		{second, 2},
+		{encrypt(t, k, "users.email", ""), 2},
+	}
+	for _, tt := range tests {
+		if v, ok := VersionOf(tt.value); !ok || v != tt.version {
+			t.Errorf("VersionOf(%q) = %d, %v; want %d", tt.value, v, ok, tt.version)
+		}
+		if !strings.HasPrefix(tt.value, k.ActivePrefix()) && tt.version == k.ActiveVersion() {
+			t.Errorf("%q does not start with the active prefix %q", tt.value, k.ActivePrefix())
+		}
+		if _, err := k.Decrypt("users.email", tt.value); err != nil {
+			t.Errorf("Decrypt(%q): %v", tt.value, err)
+		}
+	}
+	if plaintext, _ := k.Decrypt("users.email", first); plaintext != "ada@example.com" {
+		t.Errorf("v1 value decrypted to %q", plaintext)
+	}
+	if first == second {
+		t.Error("the same plaintext encrypted twice gave the same ciphertext")
+	}
+}
+
+func TestEncryptWithoutKey(t *testing.T) {
+	k := newTestKeyring(t, testMasterKey, 0)
+	if _, err := k.Encrypt("users.email", "ada@example.com"); !errors.Is(err, ErrNoActiveKey) {
+		t.Errorf("err = %v, want ErrNoActiveKey", err)
+	}
+	if _, err := NewKeyring(""); err == nil {
+		t.Error("NewKeyring accepted an empty master key")
+	}
+}
+
+func TestWrapKey(t *testing.T) {
+	k := newTestKeyring(t, testMasterKey, 0)
+	key, err := GenerateDataKey()
+	if err != nil {
+		t.Fatalf("GenerateDataKey: %v", err)
+	}
+	wrapped, err := k.WrapKey(3, key)
+	if err != nil {
+		t.Fatalf("WrapKey: %v", err)
+	}
+
+	// The same master key unwraps it in another process
+	unwrapped, err := newTestKeyring(t, testMasterKey, 0).UnwrapKey(3, wrapped)
+	if err != nil || string(unwrapped) != string(key) {
+		t.Fatalf("UnwrapKey = %x, %v; want %x", unwrapped, err, key)
+	}
+
+	tampered := append([]byte(nil), wrapped...)
+	tampered[len(tampered)-1] ^= 1
+	failures := map[string]func() ([]byte, error){
+		"wrong KEK":     func() ([]byte, error) { return newTestKeyring(t, "another-master-key", 0).UnwrapKey(3, wrapped) },
+		"wrong version": func() ([]byte, error) { return k.UnwrapKey(4, wrapped) },
+		"tampered":      func() ([]byte, error) { return k.UnwrapKey(3, tampered) },
+		"truncated":     func() ([]byte, error) { return k.UnwrapKey(3, wrapped[:4]) },
+	}
+	for name, unwrap := range failures {
+		if key, err := unwrap(); err == nil {
+			t.Errorf("%s: UnwrapKey succeeded with %x", name, key)
+		}
+	}
+}
+
+func TestDecryptRefusesForeignAndTamperedValues(t *testing.T) {
+	k := newTestKeyring(t, testMasterKey, 1)
+	value := encrypt(t, k, "users.email", "ada@example.com")
+
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, k.ActivePrefix()))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	sealed[len(sealed)-1] ^= 1
+	tampered := k.ActivePrefix() + base64.RawStdEncoding.EncodeToString(sealed)
+
+	tests := []struct {
+		name  string
+		k     *Keyring
+		field string
+		value string
+	}{
+		{"tampered ciphertext", k, "users.email", tampered},
+		{"other column", k, "users.first_name", value},
+		{"other data key", newTestKeyring(t, testMasterKey, 1), "users.email", value},
+		{"key version not loaded", newTestKeyring(t, testMasterKey, 0), "users.email", value},
+	}
+	for _, tt := range tests {
+		if plaintext, err := tt.k.Decrypt(tt.field, tt.value); err == nil {
+			t.Errorf("%s: Decrypt succeeded with %q", tt.name, plaintext)
+		}
+	}
+	if _, err := newTestKeyring(t, testMasterKey, 0).Decrypt("users.email", value); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Errorf("missing version: err = %v, want ErrUnknownKeyVersion", err)
+	}
+}
+
+func TestDecryptPassesPlaintextThrough(t *testing.T) {
+	k := newTestKeyring(t, testMasterKey, 1)
+	plaintexts := []string{
+		"",
+		"ada@example.com",
+		"enc:v",
+		"enc:v1",
+		"enc:v1:not base64!",
+		"enc:v1:c2hvcnQ",                     // too short to hold a nonce and tag
+		"enc:v01:" + strings.Repeat("A", 40), // not a canonical version
+		"enc:v0:" + strings.Repeat("A", 40),
+		"enc:vx:" + strings.Repeat("A", 40),
+	}
+	for _, p := range plaintexts {
+		if IsEncrypted(p) {
+			t.Errorf("IsEncrypted(%q) = true", p)
+		}
+		if got, err := k.Decrypt("users.email", p); err != nil || got != p {
+			t.Errorf("Decrypt(%q) = %q, %v; want it unchanged", p, got, err)
+		}
+	}
+	if value := encrypt(t, k, "users.email", "enc:v1:x"); !IsEncrypted(value) {
+		t.Errorf("IsEncrypted(%q) = false", value)
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	k := newTestKeyring(t, testMasterKey, 1)
+	index := k.BlindIndex("users.email", "ada@example.com")
+
+	// Stable across keyrings built from the same master key and data key rotations
+	other := newTestKeyring(t, testMasterKey, 2)
+	if got := other.BlindIndex("users.email", "ada@example.com"); got != index {
+		t.Errorf("BlindIndex changed between keyrings: %s and %s", index, got)
+	}
+
+	different := map[string]string{
+		"other value":      k.BlindIndex("users.email", "bob@example.com"),
+		"other field":      k.BlindIndex("user_identities.email", "ada@example.com"),
+		"other master key": newTestKeyring(t, "another-master-key", 1).BlindIndex("users.email", "ada@example.com"),
+		"case sensitive":   k.BlindIndex("users.email", "Ada@example.com"),
+	}
+	for name, got := range different {
+		if got == index {
+			t.Errorf("%s: BlindIndex collides", name)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+
+	"chainforge/internal/models"
+	"chainforge/internal/services"
+)
+
+// APIKeyHandler handles personal API key management
+type APIKeyHandler struct {
+	users *services.UserService
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(users *services.UserService) *APIKeyHandler {
+	return &APIKeyHandler{users: users}
+}
+
+// ListKeys returns the signed-in user's API keys
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.users.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, keys)
+}
+
+// CreateKey creates an API key and returns it, the only time the key
+// itself is shown
+func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.CreateAPIKeyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	key, err := h.users.CreateAPIKey(r.Context(), userID, req, clientInfo(r))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, key)
+}
+
+// RevokeKey deletes one of the user's API keys
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := uuidParam(w, r, "keyID")
+	if !ok {
+		return
+	}
+
+	if err := h.users.RevokeAPIKey(r.Context(), userID, id, clientInfo(r)); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"net/http"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/models"
+	"chainforge/internal/services"
+)
+
+// AuthHandler handles registration, sign-in and token endpoints
+type AuthHandler struct {
+	users *services.UserService
+}
+
+// NewAuthHandler creates a new auth handler
+func NewAuthHandler(users *services.UserService) *AuthHandler {
+	return &AuthHandler{users: users}
+}
+
+// Register creates an account and signs the user in
+func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateUserRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	user, tokens, err := h.users.Register(r.Context(), req, clientInfo(r))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, loginResponse(user, tokens))
+}
+
+// Login signs a user in with email and password
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req models.LoginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	result, err := h.users.Login(r.Context(), req, clientInfo(r))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeLoginResult(w, r, result)
+}
+
+// LoginMFA completes a sign-in with a TOTP or recovery code
+func (h *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.MFALoginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	user, tokens, err := h.users.CompleteMFALogin(r.Context(), req.MFAToken, req.Code, clientInfo(r))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, loginResponse(user, tokens))
+}
+
+// RefreshToken exchanges a refresh token for a new pair. The old refresh
+// token is revoked so it cannot be used again.
+func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshTokenRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	user, tokens, err := h.users.RefreshTokens(r.Context(), req.RefreshToken, clientInfo(r))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, loginResponse(user, tokens))
+}
+
+// Logout revokes the bearer access token and, if given, the refresh token
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req models.LogoutRequest
+	if !decodeOptionalJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.users.Logout(r.Context(), bearerToken(r), req.RefreshToken); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ForgotPassword mails a password reset link. It is accepted whether or
+// not an account uses the email.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ForgotPasswordRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.users.ForgotPassword(r.Context(), req, clientInfo(r)); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword sets a new password with a reset token and signs the
+// account out everywhere
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.users.ResetPassword(r.Context(), req, clientInfo(r)); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnlockAccount lifts a sign-in lock with the token from an unlock link
+func (h *AuthHandler) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	var req models.UnlockAccountRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.users.UnlockAccount(r.Context(), req, clientInfo(r)); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail verifies an email address with the token from a verification
+// link
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.users.VerifyEmail(r.Context(), req, clientInfo(r)); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ValidatePassword reports the strength of a candidate password, penalizing
+// one built from the optional email and names sent with it
+func (h *AuthHandler) ValidatePassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ValidatePasswordRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	writeJSON(w, r, http.StatusOK, auth.ValidatePassword(req.Password, req.Email, req.FirstName, req.LastName))
+}
+
+// writeLoginResult writes the tokens of a completed sign-in, or the
+// challenge for a second factor
+func writeLoginResult(w http.ResponseWriter, r *http.Request, result *services.LoginResult) {
+	if result.Tokens == nil {
+		writeJSON(w, r, http.StatusOK, models.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    result.MFAToken,
+			ExpiresIn:   int64(auth.MFAPendingTTL.Seconds()),
+		})
+		return
+	}
+	writeJSON(w, r, http.StatusOK, loginResponse(result.User, result.Tokens))
+}
+
+// loginResponse builds the response returned after signing in
+func loginResponse(user *models.User, tokens *auth.TokenPair) models.LoginResponse {
+	return models.LoginResponse{
+		User:         user.ToProfile(),
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	}
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"chainforge/internal/models"
+	"chainforge/internal/services"
+)
+
+// defaultProgressLimit is the number of progress entries returned when no
+// limit is given
+const defaultProgressLimit = 50
+
+// GoalHandler handles personal goal endpoints
+type GoalHandler struct {
+	goals *services.GoalService
+}
+
+// NewGoalHandler creates a new goal handler
+func NewGoalHandler(goals *services.GoalService) *GoalHandler {
+	return &GoalHandler{goals: goals}
+}
+
+// addProgressResponse is returned after logging progress
+type addProgressResponse struct {
+	Progress *models.GoalProgress `json:"progress"`
+	Goal     *models.Goal         `json:"goal"`
+}
+
+// GetGoals lists the user's goals
+func (h *GoalHandler) GetGoals(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	goals, err := h.goals.ListGoals(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, goals)
+}
+
+// GetGoalsWithProgress lists the user's goals with recent progress and pacing figures
+func (h *GoalHandler) GetGoalsWithProgress(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	goals, err := h.goals.ListGoalsWithProgress(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, goals)
+}
+
+// CreateGoal creates a personal goal
+func (h *GoalHandler) CreateGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.CreateGoalRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	goal, err := h.goals.CreateGoal(r.Context(), userID, req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, goal)
+}
+
+// GetGoal returns one goal
+func (h *GoalHandler) GetGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	goalID, ok := uuidParam(w, r, "goalID")
+	if !ok {
+		return
+	}
+
+	goal, err := h.goals.GetGoal(r.Context(), userID, goalID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, goal)
+}
+
+// UpdateGoal updates a goal
+func (h *GoalHandler) UpdateGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	goalID, ok := uuidParam(w, r, "goalID")
+	if !ok {
+		return
+	}
+	var req models.UpdateGoalRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	goal, err := h.goals.UpdateGoal(r.Context(), userID, goalID, req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, goal)
+}
+
+// DeleteGoal deletes a goal and its progress
+func (h *GoalHandler) DeleteGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	goalID, ok := uuidParam(w, r, "goalID")
+	if !ok {
+		return
+	}
+
+	if err := h.goals.DeleteGoal(r.Context(), userID, goalID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddProgress logs progress against a goal
+func (h *GoalHandler) AddProgress(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	goalID, ok := uuidParam(w, r, "goalID")
+	if !ok {
+		return
+	}
+	var req models.AddProgressRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	progress, goal, err := h.goals.AddProgress(r.Context(), userID, goalID, req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, addProgressResponse{Progress: progress, Goal: goal})
+}
+
+// RestartGoal archives a goal's progress and starts it again
+func (h *GoalHandler) RestartGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	goalID, ok := uuidParam(w, r, "goalID")
+	if !ok {
+		return
+	}
+
+	goal, err := h.goals.RestartGoal(r.Context(), userID, goalID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, goal)
+}
+
+// GetProgress lists a goal's progress entries, newest first. The optional
+// limit query parameter caps the number returned.
+func (h *GoalHandler) GetProgress(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	goalID, ok := uuidParam(w, r, "goalID")
+	if !ok {
+		return
+	}
+
+	limit := defaultProgressLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeError(w, r, http.StatusBadRequest, CodeBadRequest, "limit must be a positive integer", nil)
+			return
+		}
+		limit = n
+	}
+
+	progress, err := h.goals.ListProgress(r.Context(), userID, goalID, limit)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, progress)
+}
+
+// GetAnalytics returns analytics for one goal
+func (h *GoalHandler) GetAnalytics(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	goalID, ok := uuidParam(w, r, "goalID")
+	if !ok {
+		return
+	}
+
+	analytics, err := h.goals.GetAnalytics(r.Context(), userID, goalID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, analytics)
+}
+
+// GetGoalsAnalytics returns analytics for every goal the user owns
+func (h *GoalHandler) GetGoalsAnalytics(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	analytics, err := h.goals.GetGoalsAnalytics(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, analytics)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/database"
+	"chainforge/internal/models"
+	"chainforge/internal/services"
+)
+
+func TestAddProgressAcceptsZeroMeasurement(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"), "")
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("RunMigrations: %v", err)
+	}
+
+	ctx := context.Background()
+	user := models.NewUser("ada@example.com", "hash", "Ada", "Lovelace", "UTC")
+	if err := db.Users().Create(ctx, user); err != nil {
+		t.Fatalf("Create user: %v", err)
+	}
+	start := 5.0
+	goal := models.NewGoal(user.ID, models.CreateGoalRequest{
+		Name:         "Quit smoking",
+		Direction:    models.DirectionDecrease,
+		StartAmount:  &start,
+		TargetAmount: 0,
+		Unit:         "cigarettes",
+		Category:     models.CategoryHealth,
+		StartDate:    time.Now().UTC().AddDate(0, 0, -1),
+	})
+	if err := db.Goals().Create(ctx, goal); err != nil {
+		t.Fatalf("Create goal: %v", err)
+	}
+
+	// Route the request the way the router does, signed in as the owner
+	r := httptest.NewRequest(http.MethodPost, "/api/goals/"+goal.ID.String()+"/progress", strings.NewReader(`{"amount":0}`))
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("goalID", goal.ID.String())
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx))
+	r = r.WithContext(context.WithValue(r.Context(), claimsKey, &auth.Claims{UserID: user.ID}))
+	rec := httptest.NewRecorder()
+	NewGoalHandler(services.NewGoalService(db, time.Monday)).AddProgress(rec, r)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	var resp addProgressResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Progress.Amount != 0 || resp.Goal.CurrentAmount != 0 {
+		t.Errorf("progress = %v, current_amount = %v; want both 0", resp.Progress.Amount, resp.Goal.CurrentAmount)
+	}
+}
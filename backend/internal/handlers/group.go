@@ -0,0 +1,474 @@
+package handlers
+
+import (
+	"net/http"
+
+	"chainforge/internal/models"
+	"chainforge/internal/services"
+)
+
+// GroupHandler handles group, membership and group goal endpoints
+type GroupHandler struct {
+	groups *services.GroupService
+}
+
+// NewGroupHandler creates a new group handler
+func NewGroupHandler(groups *services.GroupService) *GroupHandler {
+	return &GroupHandler{groups: groups}
+}
+
+// inviteCodeResponse is returned after regenerating an invite code
+type inviteCodeResponse struct {
+	InviteCode string `json:"invite_code"`
+}
+
+// GetGroups lists the groups the user belongs to
+func (h *GroupHandler) GetGroups(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	groups, err := h.groups.ListGroups(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, groups)
+}
+
+// CreateGroup creates a group owned by the user
+func (h *GroupHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.CreateGroupRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	group, err := h.groups.CreateGroup(r.Context(), userID, req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, group)
+}
+
+// JoinGroup joins a group by invite code
+func (h *GroupHandler) JoinGroup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.JoinGroupRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	group, err := h.groups.JoinGroup(r.Context(), userID, req.InviteCode)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, group)
+}
+
+// GetGroup returns a group with its members
+func (h *GroupHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+
+	group, err := h.groups.GetGroup(r.Context(), userID, groupID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, group)
+}
+
+// UpdateGroup updates a group's settings and returns it with its members
+func (h *GroupHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+	var req models.UpdateGroupRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if _, err := h.groups.UpdateGroup(r.Context(), userID, groupID, req); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	group, err := h.groups.GetGroup(r.Context(), userID, groupID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, group)
+}
+
+// DeleteGroup deletes a group
+func (h *GroupHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+
+	if err := h.groups.DeleteGroup(r.Context(), userID, groupID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LeaveGroup removes the user from a group
+func (h *GroupHandler) LeaveGroup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+
+	if err := h.groups.LeaveGroup(r.Context(), userID, groupID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMembers lists a group's members
+func (h *GroupHandler) GetMembers(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+
+	members, err := h.groups.ListMembers(r.Context(), userID, groupID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, members)
+}
+
+// UpdateMember changes a member's role
+func (h *GroupHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+	memberID, ok := uuidParam(w, r, "userID")
+	if !ok {
+		return
+	}
+	var req models.UpdateMemberRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	member, err := h.groups.UpdateMemberRole(r.Context(), userID, groupID, memberID, req.Role)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, member)
+}
+
+// PromoteMember makes a member a group admin
+func (h *GroupHandler) PromoteMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+	memberID, ok := uuidParam(w, r, "userID")
+	if !ok {
+		return
+	}
+
+	member, err := h.groups.UpdateMemberRole(r.Context(), userID, groupID, memberID, models.RoleAdmin)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, member)
+}
+
+// RegenerateInviteCode replaces a group's invite code
+func (h *GroupHandler) RegenerateInviteCode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+
+	code, err := h.groups.RegenerateInviteCode(r.Context(), userID, groupID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, inviteCodeResponse{InviteCode: code})
+}
+
+// RemoveMember removes a member from a group
+func (h *GroupHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+	memberID, ok := uuidParam(w, r, "userID")
+	if !ok {
+		return
+	}
+
+	if err := h.groups.RemoveMember(r.Context(), userID, groupID, memberID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetGroupGoals lists a group's goals with current period progress
+func (h *GroupHandler) GetGroupGoals(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+
+	goals, err := h.groups.ListGroupGoals(r.Context(), userID, groupID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, goals)
+}
+
+// CreateGroupGoal creates a goal shared by the group
+func (h *GroupHandler) CreateGroupGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+	var req models.CreateGroupGoalRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	goal, err := h.groups.CreateGroupGoal(r.Context(), userID, groupID, req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, goal)
+}
+
+// GetGroupGoal returns one group goal with current period progress
+func (h *GroupHandler) GetGroupGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+	goalID, ok := uuidParam(w, r, "goalID")
+	if !ok {
+		return
+	}
+
+	goal, err := h.groups.GetGroupGoal(r.Context(), userID, groupID, goalID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, goal)
+}
+
+// UpdateGroupGoal updates a group goal
+func (h *GroupHandler) UpdateGroupGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+	goalID, ok := uuidParam(w, r, "goalID")
+	if !ok {
+		return
+	}
+	var req models.UpdateGroupGoalRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	goal, err := h.groups.UpdateGroupGoal(r.Context(), userID, groupID, goalID, req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, goal)
+}
+
+// DeleteGroupGoal deletes a group goal
+func (h *GroupHandler) DeleteGroupGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+	goalID, ok := uuidParam(w, r, "goalID")
+	if !ok {
+		return
+	}
+
+	if err := h.groups.DeleteGroupGoal(r.Context(), userID, groupID, goalID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetTarget sets the user's target for the current period
+func (h *GroupHandler) SetTarget(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+	goalID, ok := uuidParam(w, r, "goalID")
+	if !ok {
+		return
+	}
+	var req models.SetTargetRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	progress, err := h.groups.SetTarget(r.Context(), userID, groupID, goalID, req.TargetAmount)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, progress)
+}
+
+// AddGroupProgress logs progress for the current period
+func (h *GroupHandler) AddGroupProgress(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+	goalID, ok := uuidParam(w, r, "goalID")
+	if !ok {
+		return
+	}
+	var req models.AddGroupProgressRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	progress, err := h.groups.AddProgress(r.Context(), userID, groupID, goalID, req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, progress)
+}
+
+// GetLeaderboard returns the current period's rankings
+func (h *GroupHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := uuidParam(w, r, "groupID")
+	if !ok {
+		return
+	}
+	goalID, ok := uuidParam(w, r, "goalID")
+	if !ok {
+		return
+	}
+
+	leaderboard, err := h.groups.GetLeaderboard(r.Context(), userID, groupID, goalID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, leaderboard.Rankings)
+}
+
+// GetGroupsAnalytics returns progress across all of the user's group goals
+func (h *GroupHandler) GetGroupsAnalytics(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	analytics, err := h.groups.GetGroupsAnalytics(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, analytics)
+}
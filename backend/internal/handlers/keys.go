@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"chainforge/internal/auth"
+)
+
+// KeysHandler publishes the public keys tokens are signed with
+type KeysHandler struct {
+	keys *auth.KeyRing
+}
+
+// NewKeysHandler creates a new keys handler
+func NewKeysHandler(keys *auth.KeyRing) *KeysHandler {
+	return &KeysHandler{keys: keys}
+}
+
+// JWKS serves the JSON Web Key Set other services verify access tokens
+// with. Keys are published ahead of use, so a short cache is safe.
+func (h *KeysHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, r, http.StatusOK, h.keys.JWKS())
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+
+	"chainforge/internal/models"
+	"chainforge/internal/services"
+)
+
+// MFAHandler handles two-factor authentication settings for the signed-in user
+type MFAHandler struct {
+	users *services.UserService
+}
+
+// NewMFAHandler creates a new two-factor authentication handler
+func NewMFAHandler(users *services.UserService) *MFAHandler {
+	return &MFAHandler{users: users}
+}
+
+// GetStatus reports whether two-factor authentication is enabled
+func (h *MFAHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := h.users.GetMFAStatus(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, status)
+}
+
+// BeginTOTP starts enrolling an authenticator app and returns its secret
+// and provisioning URI for a QR code
+func (h *MFAHandler) BeginTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.users.BeginTOTPEnrollment(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, enrollment)
+}
+
+// ConfirmTOTP enables two-factor authentication with a code from the
+// authenticator and returns the recovery codes
+func (h *MFAHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.ConfirmTOTPRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	codes, err := h.users.ConfirmTOTPEnrollment(r.Context(), userID, req.Code, clientInfo(r))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes
+func (h *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.RegenerateRecoveryCodesRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	codes, err := h.users.RegenerateRecoveryCodes(r.Context(), userID, req.Code, clientInfo(r))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable turns two-factor authentication off
+func (h *MFAHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.DisableMFARequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.users.DisableMFA(r.Context(), userID, req, clientInfo(r)); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
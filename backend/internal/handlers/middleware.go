@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/models"
+	"chainforge/internal/services"
+)
+
+// maxUserAgentLength bounds the user agent stored with a session
+const maxUserAgentLength = 512
+
+type contextKey string
+
+const (
+	claimsKey contextKey = "claims"
+	apiKeyKey contextKey = "api_key"
+)
+
+// AuthMiddleware authenticates requests with bearer access tokens or, where
+// allowed, personal API keys
+type AuthMiddleware struct {
+	tokens        *auth.TokenManager
+	revocations   auth.TokenRevocationStore
+	subscriptions *services.SubscriptionService
+	users         *services.UserService
+}
+
+// NewAuthMiddleware creates a new auth middleware
+func NewAuthMiddleware(tokens *auth.TokenManager, revocations auth.TokenRevocationStore, subscriptions *services.SubscriptionService, users *services.UserService) *AuthMiddleware {
+	return &AuthMiddleware{tokens: tokens, revocations: revocations, subscriptions: subscriptions, users: users}
+}
+
+// RequireAuth rejects requests without a valid, non-revoked access token and
+// stores the token claims in the request context. API keys are refused.
+func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Missing bearer token", nil)
+			return
+		}
+		if auth.IsAPIKey(token) {
+			writeError(w, r, http.StatusForbidden, CodeForbidden, "API keys cannot be used for this endpoint", nil)
+			return
+		}
+
+		claims, err := m.tokens.ValidateAccessToken(token)
+		if err != nil {
+			writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Invalid or expired token", nil)
+			return
+		}
+		if claims.ID == "" {
+			writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Token has been revoked", nil)
+			return
+		}
+		revoked, err := m.revocations.IsRevoked(r.Context(), claims)
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		if revoked {
+			writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Token has been revoked", nil)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), claimsKey, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireAuthOrAPIKey is RequireAuth that also accepts a personal API key,
+// storing the key in the request context. Routes behind it must check the
+// key's scopes with RequireScope.
+func (m *AuthMiddleware) RequireAuthOrAPIKey(next http.Handler) http.Handler {
+	withToken := m.RequireAuth(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if !auth.IsAPIKey(token) {
+			withToken.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := m.users.AuthenticateAPIKey(r.Context(), token)
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		ctx := context.WithValue(r.Context(), apiKeyKey, key)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireScope rejects API keys without read for GET and HEAD requests, or
+// without write for the other methods. Requests signed in with an access
+// token pass.
+func (m *AuthMiddleware) RequireScope(read, write models.APIKeyScope) func(http.Handler) http.Handler {
+	return m.requireScope(read, write, true)
+}
+
+// RequireReadScope is RequireScope for read-only API access: API keys with
+// read may make GET and HEAD requests and are refused for every other method
+func (m *AuthMiddleware) RequireReadScope(read models.APIKeyScope) func(http.Handler) http.Handler {
+	return m.requireScope(read, "", false)
+}
+
+func (m *AuthMiddleware) requireScope(read, write models.APIKeyScope, allowWrites bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := APIKeyFromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			scope := read
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				if !allowWrites {
+					writeError(w, r, http.StatusForbidden, CodeForbidden, "API keys cannot be used for this endpoint", nil)
+					return
+				}
+				scope = write
+			}
+			if !key.HasScope(scope) {
+				writeError(w, r, http.StatusForbidden, CodeForbidden, "This API key lacks the "+string(scope)+" scope", nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePremium rejects users without an active premium plan. It must run
+// after RequireAuth.
+func (m *AuthMiddleware) RequirePremium(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := UserIDFromContext(r.Context())
+		if !ok {
+			writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Authentication required", nil)
+			return
+		}
+
+		premium, err := m.subscriptions.IsPremium(r.Context(), userID)
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		if !premium {
+			writeError(w, r, http.StatusPaymentRequired, CodePremiumRequired, "This feature requires a premium subscription", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClaimsFromContext returns the access token claims stored by RequireAuth
+func ClaimsFromContext(ctx context.Context) (*auth.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*auth.Claims)
+	return claims, ok
+}
+
+// APIKeyFromContext returns the API key stored by RequireAuthOrAPIKey
+func APIKeyFromContext(ctx context.Context) (*models.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyKey).(*models.APIKey)
+	return key, ok
+}
+
+// UserIDFromContext returns the authenticated user's ID
+func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		return claims.UserID, true
+	}
+	if key, ok := APIKeyFromContext(ctx); ok {
+		return key.UserID, true
+	}
+	return uuid.Nil, false
+}
+
+// currentUser returns the authenticated user's ID, writing a 401 if the
+// request was not authenticated
+func currentUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Authentication required", nil)
+	}
+	return userID, ok
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// clientInfo describes the device behind a request. middleware.RealIP has
+// already replaced RemoteAddr with the forwarded client address, if any.
+func clientInfo(r *http.Request) models.ClientInfo {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	return models.ClientInfo{
+		UserAgent: userAgent,
+		IPAddress: ip,
+		Locale:    preferredLocale(r.Header.Get("Accept-Language")),
+	}
+}
+
+// preferredLocale returns the language tag with the highest weight in an
+// Accept-Language header, or "" if there is none
+func preferredLocale(header string) string {
+	best, bestQ := "", 0.0
+	for _, item := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" || len(tag) > 35 || strings.Trim(tag, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-") != "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return best
+}
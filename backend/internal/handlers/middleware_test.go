@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/models"
+)
+
+func newTestTokenManager(t *testing.T) *auth.TokenManager {
+	t.Helper()
+	keys, err := auth.NewKeyRing(auth.NewMemorySigningKeyStore(), auth.KeyRingConfig{
+		Algorithm:        auth.AlgorithmEdDSA,
+		RotationInterval: 24 * time.Hour,
+		Overlap:          time.Hour,
+		TokenTTL:         time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+	if err := keys.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	return auth.NewTokenManager(keys, 15*time.Minute, time.Hour, "chainforge")
+}
+
+func TestRequireAuth(t *testing.T) {
+	tokens := newTestTokenManager(t)
+	revocations := auth.NewMemoryRevocationStore(time.Hour)
+	m := NewAuthMiddleware(tokens, revocations, nil, nil)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	issue := func() (string, *auth.Claims) {
+		pair, err := tokens.GenerateTokenPair(userID, "ada@example.com", uuid.New())
+		if err != nil {
+			t.Fatalf("GenerateTokenPair: %v", err)
+		}
+		claims, err := tokens.ValidateAccessToken(pair.AccessToken)
+		if err != nil {
+			t.Fatalf("ValidateAccessToken: %v", err)
+		}
+		return pair.AccessToken, claims
+	}
+	active, _ := issue()
+	revoked, revokedClaims := issue()
+	if err := revocations.Revoke(ctx, revokedClaims.ID, revokedClaims.ExpiresAt.Time); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	handler := m.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := UserIDFromContext(r.Context()); !ok || id != userID {
+			t.Errorf("UserIDFromContext = %s, %v; want %s", id, ok, userID)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		message       string
+	}{
+		{"valid token", "Bearer " + active, http.StatusNoContent, ""},
+		{"lower-case scheme", "bearer " + active, http.StatusNoContent, ""},
+		{"revoked JTI", "Bearer " + revoked, http.StatusUnauthorized, "Token has been revoked"},
+		{"missing token", "", http.StatusUnauthorized, "Missing bearer token"},
+		{"basic auth", "Basic YWRhOnNlY3JldA==", http.StatusUnauthorized, "Missing bearer token"},
+		{"malformed token", "Bearer not-a-jwt", http.StatusUnauthorized, "Invalid or expired token"},
+		{"API key", "Bearer " + auth.APIKeyPrefix + "abc", http.StatusForbidden, "API keys cannot be used for this endpoint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/goals", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if tt.message == "" {
+				if rec.Code != tt.status {
+					t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+				}
+				return
+			}
+			apiErr := decodeError(t, rec, tt.status)
+			if apiErr.Message != tt.message {
+				t.Errorf("message = %q, want %q", apiErr.Message, tt.message)
+			}
+		})
+	}
+
+	// Revoking every token issued so far also refuses the active one
+	if err := revocations.RevokeAllForUser(ctx, userID, time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("RevokeAllForUser: %v", err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/api/goals", nil)
+	r.Header.Set("Authorization", "Bearer "+active)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if apiErr := decodeError(t, rec, http.StatusUnauthorized); apiErr.Code != CodeUnauthorized {
+		t.Errorf("code = %q, want %q", apiErr.Code, CodeUnauthorized)
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	m := NewAuthMiddleware(nil, nil, nil, nil)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	readWrite := m.RequireScope(models.ScopeGoalsRead, models.ScopeGoalsWrite)(next)
+	readOnly := m.RequireReadScope(models.ScopeAnalyticsRead)(next)
+	key := &models.APIKey{Scopes: []models.APIKeyScope{models.ScopeGoalsRead, models.ScopeAnalyticsRead}}
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		method  string
+		key     *models.APIKey
+		status  int
+		message string
+	}{
+		{"read with scope", readWrite, http.MethodGet, key, http.StatusNoContent, ""},
+		{"write without scope", readWrite, http.MethodPost, key, http.StatusForbidden, "This API key lacks the goals:write scope"},
+		{"access token write", readWrite, http.MethodPost, nil, http.StatusNoContent, ""},
+		{"read-only GET", readOnly, http.MethodGet, key, http.StatusNoContent, ""},
+		{"read-only POST", readOnly, http.MethodPost, key, http.StatusForbidden, "API keys cannot be used for this endpoint"},
+		{"read-only without scope", readOnly, http.MethodGet, &models.APIKey{}, http.StatusForbidden, "This API key lacks the analytics:read scope"},
+		{"read-only access token POST", readOnly, http.MethodPost, nil, http.StatusNoContent, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/analytics/overview", nil)
+			if tt.key != nil {
+				r = r.WithContext(context.WithValue(r.Context(), apiKeyKey, tt.key))
+			}
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, r)
+
+			if tt.message == "" {
+				if rec.Code != tt.status {
+					t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+				}
+				return
+			}
+			if apiErr := decodeError(t, rec, tt.status); apiErr.Message != tt.message {
+				t.Errorf("message = %q, want %q", apiErr.Message, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"chainforge/internal/models"
+	"chainforge/internal/services"
+)
+
+// OIDCHandler handles sign-in with OpenID Connect providers and the
+// identities linked to an account
+type OIDCHandler struct {
+	users *services.UserService
+}
+
+// NewOIDCHandler creates a new OpenID Connect handler
+func NewOIDCHandler(users *services.UserService) *OIDCHandler {
+	return &OIDCHandler{users: users}
+}
+
+// Providers lists the providers users can sign in with
+func (h *OIDCHandler) Providers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, models.OIDCProvidersResponse{Providers: h.users.OIDCProviders()})
+}
+
+// Authorize starts signing in with a provider
+func (h *OIDCHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	authorization, err := h.users.BeginOIDCLogin(r.Context(), chi.URLParam(r, "provider"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, authorization)
+}
+
+// Callback signs in the user a provider redirected back with
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	var req models.OIDCCallbackRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	result, err := h.users.FinishOIDCLogin(r.Context(), chi.URLParam(r, "provider"), req, clientInfo(r))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeLoginResult(w, r, result)
+}
+
+// AuthorizeLink starts linking an identity at a provider to the signed-in
+// user
+func (h *OIDCHandler) AuthorizeLink(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	authorization, err := h.users.BeginOIDCLink(r.Context(), userID, chi.URLParam(r, "provider"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, authorization)
+}
+
+// Link links the identity a provider redirected back with
+func (h *OIDCHandler) Link(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.OIDCCallbackRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	identity, err := h.users.FinishOIDCLink(r.Context(), userID, chi.URLParam(r, "provider"), req, clientInfo(r))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, identity)
+}
+
+// ListIdentities returns the signed-in user's linked identities
+func (h *OIDCHandler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	identities, err := h.users.ListIdentities(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, identities)
+}
+
+// Unlink removes one of the signed-in user's linked identities
+func (h *OIDCHandler) Unlink(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := uuidParam(w, r, "identityID")
+	if !ok {
+		return
+	}
+
+	if err := h.users.UnlinkIdentity(r.Context(), userID, id, clientInfo(r)); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chainforge/internal/ratelimit"
+)
+
+// RateLimiter limits how often each client may call a route
+type RateLimiter struct {
+	limiter *ratelimit.Limiter
+}
+
+// NewRateLimiter creates rate limiting middleware counting in limiter
+func NewRateLimiter(limiter *ratelimit.Limiter) *RateLimiter {
+	return &RateLimiter{limiter: limiter}
+}
+
+// Limit returns middleware that applies policy to each client: the signed-in
+// user when it runs after RequireAuth, the client IP otherwise. Responses
+// carry RateLimit-* headers, and rejected requests a Retry-After header. If
+// the counters cannot be read the request is let through.
+func (l *RateLimiter) Limit(policy ratelimit.Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := "ip:" + clientInfo(r).IPAddress
+			if userID, ok := UserIDFromContext(r.Context()); ok {
+				key = "user:" + userID.String()
+			}
+
+			decision, err := l.limiter.Allow(r.Context(), policy, key)
+			if err != nil {
+				log.Printf("%s %s: rate limiter: %v", r.Method, r.URL.Path, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			reset := strconv.FormatInt(int64((decision.Reset+time.Second-1)/time.Second), 10)
+			w.Header().Set("RateLimit-Policy", policy.String())
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			w.Header().Set("RateLimit-Reset", reset)
+			if !decision.Allowed {
+				w.Header().Set("Retry-After", reset)
+				writeError(w, r, http.StatusTooManyRequests, CodeRateLimited, "Too many requests, please slow down", nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
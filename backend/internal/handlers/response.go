@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	"chainforge/internal/services"
+)
+
+// maxBodySize caps JSON request bodies
+const maxBodySize = 1 << 20
+
+// Error codes sent in ApiError.Code
+const (
+	CodeBadRequest         = "bad_request"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeForbidden          = "forbidden"
+	CodeEmailUnverified    = "email_unverified"
+	CodePasswordExpired    = "password_expired"
+	CodePremiumRequired    = "premium_required"
+	CodePlanLimit          = "plan_limit"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodePaymentFailed      = "payment_failed"
+	CodeUnavailable        = "service_unavailable"
+	CodeTooManyAttempts    = "too_many_attempts"
+	CodeRateLimited        = "rate_limited"
+	CodeInternal           = "internal_error"
+)
+
+// ApiError is the error envelope returned by every endpoint. It matches the
+// frontend's ApiError type.
+type ApiError struct {
+	Message string      `json:"message"`
+	Status  int         `json:"status"`
+	Code    string      `json:"code,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+var validate = newValidator()
+
+// newValidator builds a validator that reports fields by their JSON names
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	render.Status(r, status)
+	render.JSON(w, r, v)
+}
+
+// writeError writes an ApiError response
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string, details interface{}) {
+	writeJSON(w, r, status, ApiError{Message: message, Status: status, Code: code, Details: details})
+}
+
+// writeServiceError maps a service error to its HTTP status. Unexpected
+// errors are logged and reported as a generic 500.
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	var svcErr *services.Error
+	if !errors.As(err, &svcErr) {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		writeError(w, r, http.StatusInternalServerError, CodeInternal, "Internal server error", nil)
+		return
+	}
+
+	status, code := http.StatusInternalServerError, CodeInternal
+	switch {
+	case errors.Is(err, services.ErrInvalidInput):
+		status, code = http.StatusBadRequest, CodeBadRequest
+	case errors.Is(err, services.ErrInvalidCredentials):
+		status, code = http.StatusUnauthorized, CodeInvalidCredentials
+	case errors.Is(err, services.ErrPremiumRequired):
+		status, code = http.StatusPaymentRequired, CodePremiumRequired
+	case errors.Is(err, services.ErrPlanLimit):
+		status, code = http.StatusForbidden, CodePlanLimit
+	case errors.Is(err, services.ErrEmailUnverified):
+		status, code = http.StatusForbidden, CodeEmailUnverified
+	case errors.Is(err, services.ErrPasswordExpired):
+		status, code = http.StatusForbidden, CodePasswordExpired
+	case errors.Is(err, services.ErrForbidden):
+		status, code = http.StatusForbidden, CodeForbidden
+	case errors.Is(err, services.ErrNotFound):
+		status, code = http.StatusNotFound, CodeNotFound
+	case errors.Is(err, services.ErrConflict):
+		status, code = http.StatusConflict, CodeConflict
+	case errors.Is(err, services.ErrPaymentFailed):
+		status, code = http.StatusPaymentRequired, CodePaymentFailed
+	case errors.Is(err, services.ErrUnavailable):
+		status, code = http.StatusServiceUnavailable, CodeUnavailable
+	case errors.Is(err, services.ErrTooManyAttempts):
+		status, code = http.StatusTooManyRequests, CodeTooManyAttempts
+		if svcErr.RetryAfter > 0 {
+			seconds := int64((svcErr.RetryAfter + time.Second - 1) / time.Second)
+			w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+		}
+	}
+	writeError(w, r, status, code, capitalize(svcErr.Message), nil)
+}
+
+// decodeJSON decodes the request body into dst and enforces its validate
+// tags. It writes the error response itself and reports whether the handler
+// should continue.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxBodySize))
+	if err := dec.Decode(dst); err != nil {
+		message := "Invalid JSON body"
+		if errors.Is(err, io.EOF) {
+			message = "Request body is required"
+		}
+		writeError(w, r, http.StatusBadRequest, CodeBadRequest, message, nil)
+		return false
+	}
+	return validateRequest(w, r, dst)
+}
+
+// decodeOptionalJSON is decodeJSON for endpoints whose body may be empty
+func decodeOptionalJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxBodySize))
+	if err := dec.Decode(dst); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, r, http.StatusBadRequest, CodeBadRequest, "Invalid JSON body", nil)
+		return false
+	}
+	return validateRequest(w, r, dst)
+}
+
+// validateRequest checks dst against its validate tags, reporting failures
+// per field in the error details
+func validateRequest(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	err := validate.Struct(dst)
+	if err == nil {
+		return true
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		writeError(w, r, http.StatusBadRequest, CodeValidationFailed, "Validation failed", nil)
+		return false
+	}
+
+	details := make(map[string]string, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		details[fe.Field()] = fieldMessage(fe)
+	}
+	first := fieldErrs[0]
+	writeError(w, r, http.StatusBadRequest, CodeValidationFailed,
+		capitalize(first.Field()+" "+details[first.Field()]), details)
+	return false
+}
+
+// fieldMessage describes a failed validation rule
+func fieldMessage(fe validator.FieldError) string {
+	isString := fe.Kind() == reflect.String
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "len":
+		if isString {
+			return fmt.Sprintf("must be exactly %s characters", fe.Param())
+		}
+		return "must have exactly " + fe.Param() + " items"
+	case "min":
+		if isString {
+			return fmt.Sprintf("must be at least %s characters", fe.Param())
+		}
+		return "must be at least " + fe.Param()
+	case "max":
+		if isString {
+			return fmt.Sprintf("must be at most %s characters", fe.Param())
+		}
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be at least " + fe.Param()
+	}
+	return "is invalid"
+}
+
+// uuidParam parses a UUID path parameter, writing a 400 when it is malformed
+func uuidParam(w http.ResponseWriter, r *http.Request, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, name))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeBadRequest, "Invalid "+name, nil)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// capitalize upper-cases the first letter of a service message
+func capitalize(s string) string {
+	first, size := utf8.DecodeRuneInString(s)
+	if first == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(first)) + s[size:]
+}
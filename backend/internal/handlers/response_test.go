@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"chainforge/internal/models"
+	"chainforge/internal/services"
+)
+
+// decodeError reads an ApiError response, failing unless it carries status
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder, status int) ApiError {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, status, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	// Decode strictly so fields outside the envelope are caught
+	dec := json.NewDecoder(rec.Body)
+	dec.DisallowUnknownFields()
+	var apiErr ApiError
+	if err := dec.Decode(&apiErr); err != nil {
+		t.Fatalf("decode ApiError: %v", err)
+	}
+	if apiErr.Status != status {
+		t.Errorf("envelope status = %d, want %d", apiErr.Status, status)
+	}
+	return apiErr
+}
+
+func TestWriteErrorEnvelope(t *testing.T) {
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	writeError(rec, r, http.StatusNotFound, CodeNotFound, "Goal not found", nil)
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &raw); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := map[string]interface{}{"message": "Goal not found", "status": float64(404), "code": "not_found"}
+	if len(raw) != len(want) {
+		t.Errorf("envelope = %v, want %v without details", raw, want)
+	}
+	for key, value := range want {
+		if raw[key] != value {
+			t.Errorf("%s = %v, want %v", key, raw[key], value)
+		}
+	}
+}
+
+func TestWriteServiceError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{"invalid input", &services.Error{Kind: services.ErrInvalidInput, Message: "amount is too large"},
+			http.StatusBadRequest, CodeBadRequest, "Amount is too large"},
+		{"not found", &services.Error{Kind: services.ErrNotFound, Message: "goal not found"},
+			http.StatusNotFound, CodeNotFound, "Goal not found"},
+		{"premium", &services.Error{Kind: services.ErrPremiumRequired, Message: "upgrade to add more goals"},
+			http.StatusPaymentRequired, CodePremiumRequired, "Upgrade to add more goals"},
+		{"throttled", &services.Error{Kind: services.ErrTooManyAttempts, Message: "try again later", RetryAfter: 1500 * time.Millisecond},
+			http.StatusTooManyRequests, CodeTooManyAttempts, "Try again later"},
+		{"unexpected", errors.New("disk I/O error: /var/lib/chainforge.db"),
+			http.StatusInternalServerError, CodeInternal, "Internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeServiceError(rec, httptest.NewRequest(http.MethodGet, "/", nil), tt.err)
+
+			apiErr := decodeError(t, rec, tt.status)
+			if apiErr.Code != tt.code || apiErr.Message != tt.message || apiErr.Details != nil {
+				t.Errorf("ApiError = %+v, want code %q and message %q", apiErr, tt.code, tt.message)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	writeServiceError(rec, httptest.NewRequest(http.MethodGet, "/", nil), tests[3].err)
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want it rounded up to 2", got)
+	}
+}
+
+func TestDecodeJSONValidation(t *testing.T) {
+	// Validation fails before the handler reaches its service
+	register := NewAuthHandler(nil).Register
+	valid := `"email":"ada@example.com","password":"correct horse","first_name":"Ada","last_name":"Lovelace","timezone":"UTC"`
+
+	tests := []struct {
+		name    string
+		body    string
+		code    string
+		message string
+		details map[string]string
+	}{
+		{"empty body", ``, CodeBadRequest, "Request body is required", nil},
+		{"malformed JSON", `{"email":`, CodeBadRequest, "Invalid JSON body", nil},
+		{"bad email", `{` + strings.Replace(valid, "ada@example.com", "ada", 1) + `}`,
+			CodeValidationFailed, "Email must be a valid email address",
+			map[string]string{"email": "must be a valid email address"}},
+		{"short password", `{` + strings.Replace(valid, "correct horse", "short", 1) + `}`,
+			CodeValidationFailed, "Password must be at least 8 characters",
+			map[string]string{"password": "must be at least 8 characters"}},
+		{"missing fields", `{"email":"ada@example.com","password":"correct horse"}`,
+			CodeValidationFailed, "First_name is required",
+			map[string]string{"first_name": "is required", "last_name": "is required", "timezone": "is required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			register(rec, httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(tt.body)))
+
+			apiErr := decodeError(t, rec, http.StatusBadRequest)
+			if apiErr.Code != tt.code || apiErr.Message != tt.message {
+				t.Errorf("ApiError = %+v, want code %q and message %q", apiErr, tt.code, tt.message)
+			}
+			details, _ := apiErr.Details.(map[string]interface{})
+			if len(details) != len(tt.details) {
+				t.Errorf("details = %v, want %v", apiErr.Details, tt.details)
+			}
+			for field, message := range tt.details {
+				if details[field] != message {
+					t.Errorf("details[%s] = %v, want %q", field, details[field], message)
+				}
+			}
+		})
+	}
+}
+
+func TestProgressAmountValidation(t *testing.T) {
+	tests := []struct {
+		body  string
+		valid bool
+	}{
+		{`{"amount":0}`, true},
+		{`{"amount":2.5}`, true},
+		{`{"amount":-1}`, false},
+	}
+	for _, tt := range tests {
+		var req models.AddProgressRequest
+		if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+			t.Fatalf("decode %s: %v", tt.body, err)
+		}
+		if err := validate.Struct(&req); (err == nil) != tt.valid {
+			t.Errorf("%s: validation error = %v, want valid %v", tt.body, err, tt.valid)
+		}
+	}
+}
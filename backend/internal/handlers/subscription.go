@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"chainforge/internal/models"
+	"chainforge/internal/services"
+)
+
+// maxWebhookSize caps Stripe webhook payloads
+const maxWebhookSize = 64 << 10
+
+// SubscriptionHandler handles subscription, billing and webhook endpoints
+type SubscriptionHandler struct {
+	subscriptions *services.SubscriptionService
+}
+
+// NewSubscriptionHandler creates a new subscription handler
+func NewSubscriptionHandler(subscriptions *services.SubscriptionService) *SubscriptionHandler {
+	return &SubscriptionHandler{subscriptions: subscriptions}
+}
+
+// GetSubscription returns the user's subscription with features and usage
+func (h *SubscriptionHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	summary, err := h.subscriptions.GetSummary(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, summary)
+}
+
+// CreateSubscription starts a premium subscription or trial
+func (h *SubscriptionHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.CreateSubscriptionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	sub, err := h.subscriptions.CreateSubscription(r.Context(), userID, req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, sub)
+}
+
+// UpdateSubscription changes the user's plan
+func (h *SubscriptionHandler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.UpdateSubscriptionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	sub, err := h.subscriptions.UpdateSubscription(r.Context(), userID, req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, sub)
+}
+
+// CancelSubscription cancels the user's subscription, immediately or at
+// the end of the period
+func (h *SubscriptionHandler) CancelSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.CancelSubscriptionRequest
+	if !decodeOptionalJSON(w, r, &req) {
+		return
+	}
+
+	sub, err := h.subscriptions.CancelSubscription(r.Context(), userID, req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, sub)
+}
+
+// ResumeSubscription undoes a cancellation scheduled for the period end
+func (h *SubscriptionHandler) ResumeSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	sub, err := h.subscriptions.ResumeSubscription(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, sub)
+}
+
+// GetFeatures returns the features of the user's plan
+func (h *SubscriptionHandler) GetFeatures(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	features, err := h.subscriptions.GetFeatures(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, features)
+}
+
+// GetUsage returns the user's usage for the current period
+func (h *SubscriptionHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	usage, err := h.subscriptions.GetUsage(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, usage)
+}
+
+// CreateBillingPortal returns a Stripe billing portal URL
+func (h *SubscriptionHandler) CreateBillingPortal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.BillingPortalRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	url, err := h.subscriptions.CreateBillingPortal(r.Context(), userID, req.ReturnURL)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, models.BillingPortalResponse{URL: url})
+}
+
+// GetPaymentMethods lists the user's saved payment methods
+func (h *SubscriptionHandler) GetPaymentMethods(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	methods, err := h.subscriptions.ListPaymentMethods(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, methods)
+}
+
+// AddPaymentMethod saves a Stripe payment method for the user
+func (h *SubscriptionHandler) AddPaymentMethod(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.AddPaymentMethodRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	method, err := h.subscriptions.AddPaymentMethod(r.Context(), userID, req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, method)
+}
+
+// DeletePaymentMethod removes a saved payment method
+func (h *SubscriptionHandler) DeletePaymentMethod(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	methodID, ok := uuidParam(w, r, "paymentMethodID")
+	if !ok {
+		return
+	}
+
+	if err := h.subscriptions.DeletePaymentMethod(r.Context(), userID, methodID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetDefaultPaymentMethod makes a saved payment method the default
+func (h *SubscriptionHandler) SetDefaultPaymentMethod(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	methodID, ok := uuidParam(w, r, "paymentMethodID")
+	if !ok {
+		return
+	}
+
+	if err := h.subscriptions.SetDefaultPaymentMethod(r.Context(), userID, methodID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetInvoices lists the user's invoices
+func (h *SubscriptionHandler) GetInvoices(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	invoices, err := h.subscriptions.ListInvoices(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, invoices)
+}
+
+// GetInvoice returns one of the user's invoices
+func (h *SubscriptionHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	invoiceID, ok := uuidParam(w, r, "invoiceID")
+	if !ok {
+		return
+	}
+
+	invoice, err := h.subscriptions.GetInvoice(r.Context(), userID, invoiceID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, invoice)
+}
+
+// RetryInvoice retries payment of an open invoice
+func (h *SubscriptionHandler) RetryInvoice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	invoiceID, ok := uuidParam(w, r, "invoiceID")
+	if !ok {
+		return
+	}
+
+	invoice, err := h.subscriptions.RetryInvoice(r.Context(), userID, invoiceID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, invoice)
+}
+
+// CreatePaymentIntent starts a one-time payment
+func (h *SubscriptionHandler) CreatePaymentIntent(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.CreatePaymentIntentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	intent, err := h.subscriptions.CreatePaymentIntent(r.Context(), userID, req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, intent)
+}
+
+// PaymentSuccess confirms a completed payment and returns the updated summary
+func (h *SubscriptionHandler) PaymentSuccess(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.PaymentSuccessRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	summary, err := h.subscriptions.ConfirmPayment(r.Context(), userID, req.PaymentIntentID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, summary)
+}
+
+// HandleStripeWebhook verifies and applies a Stripe webhook event
+func (h *SubscriptionHandler) HandleStripeWebhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookSize))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeBadRequest, "Could not read request body", nil)
+		return
+	}
+
+	if err := h.subscriptions.HandleWebhook(r.Context(), payload, r.Header.Get("Stripe-Signature")); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]bool{"received": true})
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"chainforge/internal/config"
+	"chainforge/internal/models"
+	"chainforge/internal/services"
+)
+
+// avatarExtensions maps accepted avatar content types to file extensions
+var avatarExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// UserHandler handles the current user's profile endpoints
+type UserHandler struct {
+	users   *services.UserService
+	storage config.StorageConfig
+}
+
+// NewUserHandler creates a new user handler
+func NewUserHandler(users *services.UserService, storage config.StorageConfig) *UserHandler {
+	return &UserHandler{users: users, storage: storage}
+}
+
+// GetCurrentUser returns the signed-in user
+func (h *UserHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.users.GetUser(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, user)
+}
+
+// UpdateCurrentUser updates the signed-in user's profile
+func (h *UserHandler) UpdateCurrentUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.UpdateUserRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	user, err := h.users.UpdateUser(r.Context(), userID, req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, user)
+}
+
+// DeleteCurrentUser deletes the signed-in user's account
+func (h *UserHandler) DeleteCurrentUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.users.DeleteUser(r.Context(), userID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUserStats returns goal and group statistics for the signed-in user
+func (h *UserHandler) GetUserStats(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := h.users.GetStats(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, stats)
+}
+
+// GetAnalyticsOverview returns the premium analytics overview
+func (h *UserHandler) GetAnalyticsOverview(w http.ResponseWriter, r *http.Request) {
+	h.GetUserStats(w, r)
+}
+
+// UploadAvatar stores an uploaded image and sets it as the user's avatar
+func (h *UserHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	if h.storage.Provider != "local" {
+		writeError(w, r, http.StatusServiceUnavailable, CodeUnavailable, "Avatar uploads are not available", nil)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.storage.MaxFileSize+(1<<20))
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, CodeBadRequest, "File is too large", nil)
+			return
+		}
+		writeError(w, r, http.StatusBadRequest, CodeBadRequest, "A file field is required", nil)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > h.storage.MaxFileSize {
+		writeError(w, r, http.StatusRequestEntityTooLarge, CodeBadRequest, "File is too large", nil)
+		return
+	}
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		writeError(w, r, http.StatusBadRequest, CodeBadRequest, "Could not read file", nil)
+		return
+	}
+	contentType := http.DetectContentType(sniff[:n])
+	ext, known := avatarExtensions[contentType]
+	if !known || !h.allowedType(contentType) {
+		writeError(w, r, http.StatusUnsupportedMediaType, CodeBadRequest, "Unsupported image type", nil)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	name, err := randomName(userID.String(), ext)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if err := h.save(file, name); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	user, err := h.users.SetAvatar(r.Context(), userID, h.publicURL(name))
+	if err != nil {
+		if rmErr := os.Remove(filepath.Join(h.storage.LocalPath, name)); rmErr != nil {
+			log.Printf("Failed to remove orphaned avatar %s: %v", name, rmErr)
+		}
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, user)
+}
+
+// ChangePassword changes the signed-in user's password. Other sessions are
+// signed out and the response carries a fresh token pair.
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.ChangePasswordRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	user, tokens, err := h.users.ChangePassword(r.Context(), userID, req, clientInfo(r))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, loginResponse(user, tokens))
+}
+
+// SendVerificationEmail mails the signed-in user a new link to verify
+// their email address
+func (h *UserHandler) SendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.users.SendVerificationEmail(r.Context(), userID, clientInfo(r)); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// GetSessions lists the signed-in user's sessions
+func (h *UserHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ClaimsFromContext(r.Context())
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Authentication required", nil)
+		return
+	}
+
+	sessions, err := h.users.ListSessions(r.Context(), claims.UserID, claims.FamilyID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, sessions)
+}
+
+// DeleteSession signs out one of the user's sessions
+func (h *UserHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	sessionID, ok := uuidParam(w, r, "sessionID")
+	if !ok {
+		return
+	}
+
+	if err := h.users.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteOtherSessions signs out every session except the current one
+func (h *UserHandler) DeleteOtherSessions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ClaimsFromContext(r.Context())
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Authentication required", nil)
+		return
+	}
+
+	revoked, err := h.users.RevokeOtherSessions(r.Context(), claims.UserID, claims.FamilyID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]int{"revoked": revoked})
+}
+
+// allowedType reports whether the storage config accepts a content type
+func (h *UserHandler) allowedType(contentType string) bool {
+	for _, t := range h.storage.AllowedMimeTypes {
+		if strings.EqualFold(t, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// save writes an upload into the local storage directory
+func (h *UserHandler) save(src io.Reader, name string) error {
+	if err := os.MkdirAll(h.storage.LocalPath, 0o755); err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(filepath.Join(h.storage.LocalPath, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, io.LimitReader(src, h.storage.MaxFileSize)); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// publicURL returns the URL under /static that serves a stored file
+func (h *UserHandler) publicURL(name string) string {
+	dir := filepath.ToSlash(filepath.Clean(h.storage.LocalPath))
+	dir = strings.TrimPrefix(strings.TrimPrefix(dir, "./"), "static")
+	return path.Join("/static", dir, name)
+}
+
+// randomName builds an unguessable file name
+func randomName(prefix, ext string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + "-" + hex.EncodeToString(b) + ext, nil
+}
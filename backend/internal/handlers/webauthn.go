@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+
+	"chainforge/internal/models"
+	"chainforge/internal/services"
+)
+
+// WebAuthnHandler handles passkey registration, sign-in and management
+type WebAuthnHandler struct {
+	users *services.UserService
+}
+
+// NewWebAuthnHandler creates a new passkey handler
+func NewWebAuthnHandler(users *services.UserService) *WebAuthnHandler {
+	return &WebAuthnHandler{users: users}
+}
+
+// BeginRegistration returns the options for navigator.credentials.create
+func (h *WebAuthnHandler) BeginRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	options, err := h.users.BeginWebAuthnRegistration(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, options)
+}
+
+// FinishRegistration verifies the new passkey and stores it
+func (h *WebAuthnHandler) FinishRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.FinishWebAuthnRegistrationRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	cred, err := h.users.FinishWebAuthnRegistration(r.Context(), userID, req, clientInfo(r))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, cred)
+}
+
+// BeginLogin returns the options for navigator.credentials.get
+func (h *WebAuthnHandler) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	var req models.BeginWebAuthnLoginRequest
+	if !decodeOptionalJSON(w, r, &req) {
+		return
+	}
+
+	options, err := h.users.BeginWebAuthnLogin(r.Context(), req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, options)
+}
+
+// FinishLogin signs a user in with a passkey
+func (h *WebAuthnHandler) FinishLogin(w http.ResponseWriter, r *http.Request) {
+	var req models.FinishWebAuthnLoginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	user, tokens, err := h.users.FinishWebAuthnLogin(r.Context(), req, clientInfo(r))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, loginResponse(user, tokens))
+}
+
+// ListCredentials returns the signed-in user's passkeys
+func (h *WebAuthnHandler) ListCredentials(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	creds, err := h.users.ListWebAuthnCredentials(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, creds)
+}
+
+// RenameCredential renames one of the user's passkeys
+func (h *WebAuthnHandler) RenameCredential(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := uuidParam(w, r, "credentialID")
+	if !ok {
+		return
+	}
+	var req models.UpdateWebAuthnCredentialRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.users.RenameWebAuthnCredential(r.Context(), userID, id, req.Name); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteCredential removes one of the user's passkeys
+func (h *WebAuthnHandler) DeleteCredential(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := uuidParam(w, r, "credentialID")
+	if !ok {
+		return
+	}
+
+	if err := h.users.DeleteWebAuthnCredential(r.Context(), userID, id, clientInfo(r)); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyScope names what an API key may do
+type APIKeyScope string
+
+const (
+	ScopeGoalsRead     APIKeyScope = "goals:read"
+	ScopeGoalsWrite    APIKeyScope = "goals:write"
+	ScopeProgressRead  APIKeyScope = "progress:read"
+	ScopeProgressWrite APIKeyScope = "progress:write"
+	ScopeGroupsRead    APIKeyScope = "groups:read"
+	ScopeGroupsWrite   APIKeyScope = "groups:write"
+	ScopeAnalyticsRead APIKeyScope = "analytics:read"
+)
+
+// APIKeyScopes lists every scope a key can be given
+var APIKeyScopes = []APIKeyScope{
+	ScopeGoalsRead, ScopeGoalsWrite,
+	ScopeProgressRead, ScopeProgressWrite,
+	ScopeGroupsRead, ScopeGroupsWrite,
+	ScopeAnalyticsRead,
+}
+
+// APIKey is a long-lived credential a user created to call the API from
+// scripts and integrations. Only a hash of the key is stored; Prefix is its
+// first characters so users can tell their keys apart.
+type APIKey struct {
+	ID         uuid.UUID     `json:"id" db:"id"`
+	UserID     uuid.UUID     `json:"-" db:"user_id"`
+	Name       string        `json:"name" db:"name"`
+	Prefix     string        `json:"prefix" db:"prefix"`
+	KeyHash    string        `json:"-" db:"key_hash"`
+	Scopes     []APIKeyScope `json:"scopes" db:"scopes"`
+	LastUsedAt *time.Time    `json:"last_used_at" db:"last_used_at"`
+	ExpiresAt  *time.Time    `json:"expires_at" db:"expires_at"`
+	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+}
+
+// NewAPIKey creates an API key record for the key with the given prefix and
+// hash
+func NewAPIKey(userID uuid.UUID, name, prefix, hash string, scopes []APIKeyScope, expiresAt *time.Time) *APIKey {
+	return &APIKey{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// HasScope reports whether the key was given scope
+func (k *APIKey) HasScope(scope APIKeyScope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired reports whether the key has expired at now
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !k.ExpiresAt.After(now)
+}
+
+// CreateAPIKeyRequest represents a request to create an API key. ExpiresAt
+// is optional; keys without it work until they are revoked.
+type CreateAPIKeyRequest struct {
+	Name      string        `json:"name" validate:"required,max=100"`
+	Scopes    []APIKeyScope `json:"scopes" validate:"required,min=1,dive,required"`
+	ExpiresAt *time.Time    `json:"expires_at"`
+}
+
+// CreatedAPIKey is a new API key along with the key itself, which is shown
+// only this once
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
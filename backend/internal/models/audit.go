@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditAction identifies an audited change
+type AuditAction string
+
+const (
+	AuditMFAEnabled             AuditAction = "mfa.enabled"
+	AuditMFADisabled            AuditAction = "mfa.disabled"
+	AuditRecoveryCodesGenerated AuditAction = "mfa.recovery_codes_generated"
+	AuditRecoveryCodeUsed       AuditAction = "mfa.recovery_code_used"
+	AuditMFALocked              AuditAction = "mfa.locked"
+	AuditPasskeyAdded           AuditAction = "webauthn.credential_added"
+	AuditPasskeyRemoved         AuditAction = "webauthn.credential_removed"
+	AuditIdentityLinked         AuditAction = "oidc.identity_linked"
+	AuditIdentityUnlinked       AuditAction = "oidc.identity_unlinked"
+	AuditEmailVerified          AuditAction = "email.verified"
+	AuditPasswordReset          AuditAction = "password.reset"
+	AuditPasswordChanged        AuditAction = "password.changed"
+	AuditLoginFailed            AuditAction = "login.failed"
+	AuditAccountLocked          AuditAction = "account.locked"
+	AuditAccountUnlocked        AuditAction = "account.unlocked"
+	AuditAPIKeyCreated          AuditAction = "api_key.created"
+	AuditAPIKeyRevoked          AuditAction = "api_key.revoked"
+)
+
+// AuditEntityUser marks audit entries about a user account
+const AuditEntityUser = "user"
+
+// AuditLog records a security-relevant change for administrators. Entries
+// outlive the account they describe: UserID is cleared when the user is
+// deleted but EntityID keeps the ID.
+type AuditLog struct {
+	ID         uuid.UUID   `json:"id" db:"id"`
+	UserID     *uuid.UUID  `json:"user_id" db:"user_id"`
+	EntityType string      `json:"entity_type" db:"entity_type"`
+	EntityID   string      `json:"entity_id" db:"entity_id"`
+	Action     AuditAction `json:"action" db:"action"`
+	OldValues  *string     `json:"old_values" db:"old_values"` // JSON
+	NewValues  *string     `json:"new_values" db:"new_values"` // JSON
+	IPAddress  *string     `json:"ip_address" db:"ip_address"`
+	UserAgent  *string     `json:"user_agent" db:"user_agent"`
+	CreatedAt  time.Time   `json:"created_at" db:"created_at"`
+}
+
+// NewUserAuditLog creates an audit entry for a change a user made to their
+// own account from client. newValues is JSON and may be empty.
+func NewUserAuditLog(userID uuid.UUID, action AuditAction, newValues string, client ClientInfo) *AuditLog {
+	e := &AuditLog{
+		ID:         uuid.New(),
+		UserID:     &userID,
+		EntityType: AuditEntityUser,
+		EntityID:   userID.String(),
+		Action:     action,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if newValues != "" {
+		e.NewValues = &newValues
+	}
+	if client.IPAddress != "" {
+		e.IPAddress = &client.IPAddress
+	}
+	if client.UserAgent != "" {
+		e.UserAgent = &client.UserAgent
+	}
+	return e
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxStatus is the delivery state of a queued email
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	OutboxFailed  OutboxStatus = "failed"
+)
+
+// OutboxEmail is a rendered email waiting to be delivered. Sent emails are
+// deleted; ones that keep failing are kept as failed.
+type OutboxEmail struct {
+	ID            uuid.UUID    `db:"id"`
+	UserID        uuid.UUID    `db:"user_id"`
+	Template      string       `db:"template"`
+	Recipient     string       `db:"recipient"`
+	Subject       string       `db:"subject"`
+	TextBody      string       `db:"text_body"`
+	HTMLBody      string       `db:"html_body"`
+	Status        OutboxStatus `db:"status"`
+	Attempts      int          `db:"attempts"`
+	NextAttemptAt time.Time    `db:"next_attempt_at"`
+	LastError     *string      `db:"last_error"`
+	CreatedAt     time.Time    `db:"created_at"`
+}
+
+// NewOutboxEmail creates an email to user that is due immediately
+func NewOutboxEmail(userID uuid.UUID, template, recipient, subject, textBody, htmlBody string) *OutboxEmail {
+	now := time.Now().UTC()
+	return &OutboxEmail{
+		ID:            uuid.New(),
+		UserID:        userID,
+		Template:      template,
+		Recipient:     recipient,
+		Subject:       subject,
+		TextBody:      textBody,
+		HTMLBody:      htmlBody,
+		Status:        OutboxPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
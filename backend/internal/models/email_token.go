@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailTokenPurpose identifies what a mailed token can be used for
+type EmailTokenPurpose string
+
+const (
+	EmailTokenVerifyEmail   EmailTokenPurpose = "verify_email"
+	EmailTokenResetPassword EmailTokenPurpose = "reset_password"
+	EmailTokenUnlockAccount EmailTokenPurpose = "unlock_account"
+)
+
+// EmailToken is a single-use token mailed to a user. Only the hash of the
+// token is stored.
+type EmailToken struct {
+	ID        uuid.UUID         `db:"id"`
+	UserID    uuid.UUID         `db:"user_id"`
+	Purpose   EmailTokenPurpose `db:"purpose"`
+	TokenHash string            `db:"token_hash"`
+	ExpiresAt time.Time         `db:"expires_at"`
+	UsedAt    *time.Time        `db:"used_at"`
+	CreatedAt time.Time         `db:"created_at"`
+}
+
+// VerifyEmailRequest carries the token from a verification email
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required,max=256"`
+}
+
+// NewEmailToken creates a token for purpose that expires after ttl
+func NewEmailToken(userID uuid.UUID, purpose EmailTokenPurpose, tokenHash string, ttl time.Duration) *EmailToken {
+	now := time.Now().UTC()
+	return &EmailToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"chainforge/internal/calendar"
 )
 
 // GoalStatus represents the status of a goal
@@ -31,14 +33,66 @@ const (
 	CategoryOther       GoalCategory = "other"
 )
 
+// GoalKind tells one-off goals from recurring habits
+type GoalKind string
+
+const (
+	// GoalKindTarget goals accumulate progress until TargetAmount is reached
+	GoalKindTarget GoalKind = "target"
+	// GoalKindHabit goals are due again and again as their Recurrence says,
+	// each time for TargetAmount
+	GoalKindHabit GoalKind = "habit"
+)
+
+// RecurrenceFrequency is how often a habit goal is due
+type RecurrenceFrequency string
+
+const (
+	RecurDaily    RecurrenceFrequency = "daily"
+	RecurWeekly   RecurrenceFrequency = "weekly"   // TimesPerWeek days a week, any days
+	RecurWeekdays RecurrenceFrequency = "weekdays" // Each of Weekdays
+)
+
+// GoalDirection is which way a goal's amount should go. Amounts and
+// measurements are never negative; decreasing and stay_under goals may aim
+// for a TargetAmount of 0.
+type GoalDirection string
+
+const (
+	// DirectionIncrease goals add up their progress entries until they reach
+	// TargetAmount
+	DirectionIncrease GoalDirection = "increase"
+	// DirectionDecrease goals log measurements that should come down from
+	// StartAmount to TargetAmount, such as a weight
+	DirectionDecrease GoalDirection = "decrease"
+	// DirectionStayUnder goals log measurements that should not go over
+	// TargetAmount, such as daily screen time
+	DirectionStayUnder GoalDirection = "stay_under"
+	// DirectionStayWithin goals log measurements that should stay between
+	// MinAmount and TargetAmount
+	DirectionStayWithin GoalDirection = "stay_within"
+)
+
+// Recurrence is when a habit goal is due
+type Recurrence struct {
+	Frequency    RecurrenceFrequency `json:"frequency"`
+	TimesPerWeek int                 `json:"times_per_week,omitempty"`
+	Weekdays     []time.Weekday      `json:"weekdays,omitempty"` // 0 is Sunday
+}
+
 // Goal represents a personal goal
 type Goal struct {
 	ID            uuid.UUID    `json:"id" db:"id"`
 	UserID        uuid.UUID    `json:"user_id" db:"user_id"`
 	Name          string       `json:"name" db:"name"`
+	Kind          GoalKind     `json:"kind" db:"kind"`
+	Recurrence    *Recurrence  `json:"recurrence" db:"recurrence"`
+	Direction     GoalDirection `json:"direction" db:"direction"`
 	Description   *string      `json:"description" db:"description"`
 	TargetAmount  float64      `json:"target_amount" db:"target_amount"`
-	CurrentAmount float64      `json:"current_amount" db:"current_amount"`
+	CurrentAmount float64      `json:"current_amount" db:"current_amount"` // Latest measurement of measured goals
+	StartAmount   *float64     `json:"start_amount" db:"start_amount"`     // Measured goals' amount before their first entry
+	MinAmount     *float64     `json:"min_amount" db:"min_amount"`         // Lower limit of stay_within goals
 	Unit          string       `json:"unit" db:"unit"`
 	Category      GoalCategory `json:"category" db:"category"`
 	Status        GoalStatus   `json:"status" db:"status"`
@@ -68,13 +122,31 @@ type GoalWithProgress struct {
 	DaysRemaining     *int           `json:"days_remaining"`
 	AverageDaily      float64        `json:"average_daily"`
 	RequiredDaily     float64        `json:"required_daily"`
+	Streak            *HabitStreak   `json:"streak,omitempty"`
+}
+
+// HabitStreak summarizes how well a habit goal has been kept. Streaks count
+// periods in a row in which the habit was done as often as it was due:
+// days for daily habits, due days for weekday habits and weeks for weekly
+// ones. Days are counted in the user's timezone.
+type HabitStreak struct {
+	CurrentStreak int `json:"current_streak"`
+	LongestStreak int `json:"longest_streak"`
+	// Times the habit was done and is due in the current period
+	PeriodDone     int `json:"period_done"`
+	PeriodRequired int `json:"period_required"`
 }
 
 // CreateGoalRequest represents the request to create a new goal
 type CreateGoalRequest struct {
 	Name         string       `json:"name" validate:"required,min=1,max=100"`
+	Kind         GoalKind     `json:"kind,omitempty"` // Defaults to target
+	Recurrence   *Recurrence  `json:"recurrence,omitempty"` // Required for habits
+	Direction    GoalDirection `json:"direction,omitempty"` // Defaults to increase
+	StartAmount  *float64     `json:"start_amount,omitempty" validate:"omitempty,gte=0"` // Required to decrease
+	MinAmount    *float64     `json:"min_amount,omitempty" validate:"omitempty,gte=0"`   // Required to stay within
 	Description  *string      `json:"description,omitempty" validate:"omitempty,max=500"`
-	TargetAmount float64      `json:"target_amount" validate:"required,gt=0"`
+	TargetAmount float64      `json:"target_amount" validate:"gte=0"` // Per occurrence for habits, upper limit for limits; 0 only to decrease or stay under
 	Unit         string       `json:"unit" validate:"required,min=1,max=20"`
 	Category     GoalCategory `json:"category" validate:"required"`
 	StartDate    time.Time    `json:"start_date" validate:"required"`
@@ -86,6 +158,7 @@ type CreateGoalRequest struct {
 // UpdateGoalRequest represents the request to update a goal
 type UpdateGoalRequest struct {
 	Name        *string      `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Recurrence  *Recurrence  `json:"recurrence,omitempty"` // Habits only
 	Description *string      `json:"description,omitempty" validate:"omitempty,max=500"`
 	Unit        *string      `json:"unit,omitempty" validate:"omitempty,min=1,max=20"`
 	Category    *GoalCategory `json:"category,omitempty"`
@@ -97,7 +170,7 @@ type UpdateGoalRequest struct {
 
 // AddProgressRequest represents the request to add progress to a goal
 type AddProgressRequest struct {
-	Amount float64  `json:"amount" validate:"required,gte=0"`
+	Amount float64  `json:"amount" validate:"gte=0"` // A measurement for measured goals, which may be 0
 	Note   *string  `json:"note,omitempty" validate:"omitempty,max=200"`
 	Date   *time.Time `json:"date,omitempty"`
 }
@@ -115,6 +188,7 @@ type GoalAnalytics struct {
 	BestDayAmount        float64         `json:"best_day_amount"`
 	ConsistencyScore     float64         `json:"consistency_score"`
 	ProjectedCompletion  *time.Time      `json:"projected_completion"`
+	Streak               *HabitStreak    `json:"streak,omitempty"`
 	WeeklyProgress       []WeeklyStats   `json:"weekly_progress"`
 	MonthlyProgress      []MonthlyStats  `json:"monthly_progress"`
 }
@@ -133,12 +207,26 @@ type MonthlyStats struct {
 	DaysActive int    `json:"days_active"`
 }
 
-// NewGoal creates a new goal
+// NewGoal creates a new goal, a one-off increasing target unless req says
+// otherwise
 func NewGoal(userID uuid.UUID, req CreateGoalRequest) *Goal {
-	return &Goal{
+	kind := req.Kind
+	if kind == "" {
+		kind = GoalKindTarget
+	}
+	direction := req.Direction
+	if direction == "" {
+		direction = DirectionIncrease
+	}
+	goal := &Goal{
 		ID:            uuid.New(),
 		UserID:        userID,
 		Name:          req.Name,
+		Kind:          kind,
+		Recurrence:    req.Recurrence,
+		Direction:     direction,
+		StartAmount:   req.StartAmount,
+		MinAmount:     req.MinAmount,
 		Description:   req.Description,
 		TargetAmount:  req.TargetAmount,
 		CurrentAmount: 0,
@@ -152,6 +240,8 @@ func NewGoal(userID uuid.UUID, req CreateGoalRequest) *Goal {
 		CreatedAt:     time.Now().UTC(),
 		UpdatedAt:     time.Now().UTC(),
 	}
+	goal.CurrentAmount = goal.InitialAmount()
+	return goal
 }
 
 // NewGoalProgress creates a new progress entry
@@ -171,46 +261,130 @@ func NewGoalProgress(goalID uuid.UUID, amount float64, note *string, date *time.
 	}
 }
 
-// CalculateProgressPercentage calculates the progress percentage
+// CalculateProgressPercentage calculates the progress percentage. A
+// decreasing goal is measured from its StartAmount down to its target. A
+// limit is at 100 while its latest measurement keeps to it and falls the
+// further the measurement strays.
 func (g *Goal) CalculateProgressPercentage() float64 {
-	if g.TargetAmount <= 0 {
+	var percentage float64
+	switch {
+	case g.IsLimit():
+		switch {
+		case g.CurrentAmount > g.TargetAmount:
+			percentage = g.TargetAmount / g.CurrentAmount * 100
+		case g.MinAmount != nil && g.CurrentAmount < *g.MinAmount:
+			percentage = g.CurrentAmount / *g.MinAmount * 100
+		default:
+			percentage = 100
+		}
+	case g.Direction == DirectionDecrease:
+		span := g.InitialAmount() - g.TargetAmount
+		if span <= 0 {
+			return 0
+		}
+		percentage = g.Progress() / span * 100
+	default:
+		if g.TargetAmount <= 0 {
+			return 0
+		}
+		percentage = (g.CurrentAmount / g.TargetAmount) * 100
+	}
+	return min(max(percentage, 0), 100)
+}
+
+// IsMeasured reports whether the goal's progress entries are measurements,
+// the latest of which is its CurrentAmount, rather than amounts to add up
+func (g *Goal) IsMeasured() bool {
+	return g.Direction != "" && g.Direction != DirectionIncrease
+}
+
+// IsLimit reports whether the goal is to keep its measurements within limits
+// rather than to reach a target
+func (g *Goal) IsLimit() bool {
+	return g.Direction == DirectionStayUnder || g.Direction == DirectionStayWithin
+}
+
+// WithinLimits reports whether a measurement keeps to the goal's limits
+func (g *Goal) WithinLimits(amount float64) bool {
+	return amount <= g.TargetAmount && (g.MinAmount == nil || amount >= *g.MinAmount)
+}
+
+// InitialAmount is the CurrentAmount of the goal before any progress: the
+// StartAmount of measured goals that have one, otherwise 0
+func (g *Goal) InitialAmount() float64 {
+	if g.IsMeasured() && g.StartAmount != nil {
+		return *g.StartAmount
+	}
+	return 0
+}
+
+// Progress is how far the goal has come: the total of an increasing goal or
+// how far a decreasing one has come down. Limits are kept rather than
+// approached, so they make none.
+func (g *Goal) Progress() float64 {
+	switch {
+	case g.IsLimit():
 		return 0
+	case g.Direction == DirectionDecrease:
+		return g.InitialAmount() - g.CurrentAmount
 	}
-	percentage := (g.CurrentAmount / g.TargetAmount) * 100
-	if percentage > 100 {
-		return 100
+	return g.CurrentAmount
+}
+
+// Remaining is how far the goal still has to go to reach its target, 0 for
+// limits and goals already there
+func (g *Goal) Remaining() float64 {
+	switch {
+	case g.IsLimit():
+		return 0
+	case g.Direction == DirectionDecrease:
+		return max(g.CurrentAmount-g.TargetAmount, 0)
 	}
-	return percentage
+	return max(g.TargetAmount-g.CurrentAmount, 0)
 }
 
-// IsCompleted checks if the goal is completed
+// IsHabit reports whether the goal recurs
+func (g *Goal) IsHabit() bool {
+	return g.Kind == GoalKindHabit
+}
+
+// IsCompleted checks if the goal is completed. Habits and limits are only
+// completed when the user says so.
 func (g *Goal) IsCompleted() bool {
-	return g.CurrentAmount >= g.TargetAmount || g.Status == GoalStatusCompleted
+	if g.IsHabit() || g.IsLimit() {
+		return g.Status == GoalStatusCompleted
+	}
+	return g.Remaining() <= 0 || g.Status == GoalStatusCompleted
 }
 
-// DaysRemaining calculates days remaining until end date
-func (g *Goal) DaysRemaining() *int {
+// DaysRemaining calculates days remaining from now until end date, counting
+// days in cal; 0 on the day the goal ends
+func (g *Goal) DaysRemaining(cal calendar.Calendar, now time.Time) *int {
 	if g.EndDate == nil {
 		return nil
 	}
 	
-	now := time.Now().UTC()
 	if g.EndDate.Before(now) {
 		return nil
 	}
 	
-	days := int(g.EndDate.Sub(now).Hours() / 24)
+	days := cal.DaysBetween(now, *g.EndDate)
 	return &days
 }
 
-// RequiredDailyProgress calculates required daily progress to meet goal
-func (g *Goal) RequiredDailyProgress() float64 {
-	remaining := g.TargetAmount - g.CurrentAmount
+// RequiredDailyProgress calculates required daily progress to meet goal:
+// how much to add, or for decreasing goals how much to come down, each day.
+// Habits and limits have no overall target, so none is required.
+func (g *Goal) RequiredDailyProgress(cal calendar.Calendar, now time.Time) float64 {
+	if g.IsHabit() {
+		return 0
+	}
+	remaining := g.Remaining()
 	if remaining <= 0 {
 		return 0
 	}
 	
-	daysLeft := g.DaysRemaining()
+	daysLeft := g.DaysRemaining(cal, now)
 	if daysLeft == nil || *daysLeft <= 0 {
 		return remaining // All remaining progress needed immediately
 	}
@@ -220,5 +394,6 @@ func (g *Goal) RequiredDailyProgress() float64 {
 
 // IsValid validates the goal data
 func (g *Goal) IsValid() bool {
-	return g.Name != "" && g.TargetAmount > 0 && g.Unit != ""
+	canBeZero := g.Direction == DirectionDecrease || g.Direction == DirectionStayUnder
+	return g.Name != "" && (g.TargetAmount > 0 || canBeZero && g.TargetAmount == 0) && g.Unit != ""
 }
\ No newline at end of file
@@ -188,6 +188,10 @@ type UpdateGroupGoalRequest struct {
 	IsActive    *bool   `json:"is_active,omitempty"`
 }
 
+type UpdateMemberRequest struct {
+	Role MemberRole `json:"role" validate:"required,oneof=owner admin member"`
+}
+
 type SetTargetRequest struct {
 	TargetAmount float64 `json:"target_amount" validate:"required,gt=0"`
 }
@@ -268,6 +272,12 @@ func NewGroupGoalProgress(periodID, userID uuid.UUID, targetAmount, penaltyCarry
 	}
 }
 
+// RotateInviteCode replaces the invite code so earlier invitations stop working
+func (g *Group) RotateInviteCode() {
+	g.InviteCode = generateInviteCode()
+	g.UpdatedAt = time.Now().UTC()
+}
+
 // Utility functions
 func generateInviteCode() string {
 	bytes := make([]byte, 4)
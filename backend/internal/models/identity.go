@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity is an account at an OpenID Connect provider that can sign a
+// user in
+type UserIdentity struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"-" db:"user_id"`
+	Provider   string     `json:"provider" db:"provider"`
+	Subject    string     `json:"-" db:"subject"`
+	Email      string     `json:"email" db:"email"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+}
+
+// OIDCState is a sign-in waiting for the provider to redirect back. It can
+// be used once. UserID is set when an identity is being linked to an
+// account that is already signed in.
+type OIDCState struct {
+	StateHash    string     `db:"state_hash"`
+	Provider     string     `db:"provider"`
+	UserID       *uuid.UUID `db:"user_id"`
+	Nonce        string     `db:"nonce"`
+	CodeVerifier string     `db:"code_verifier"`
+	ExpiresAt    time.Time  `db:"expires_at"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+// OIDCProvidersResponse lists the providers users can sign in with
+type OIDCProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+// OIDCAuthorizationResponse starts a sign-in: the browser is sent to
+// AuthorizationURL and comes back to the redirect URL with a code and state
+type OIDCAuthorizationResponse struct {
+	AuthorizationURL string    `json:"authorization_url"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// OIDCCallbackRequest carries what the provider redirected back with
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" validate:"required,max=2048"`
+	State string `json:"state" validate:"required,max=256"`
+}
+
+// NewUserIdentity creates an identity linking a provider account to a user
+func NewUserIdentity(userID uuid.UUID, provider, subject, email string) *UserIdentity {
+	return &UserIdentity{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Provider:  provider,
+		Subject:   subject,
+		Email:     email,
+		CreatedAt: time.Now().UTC(),
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// ThrottleScope is what failed sign-ins are counted against
+type ThrottleScope string
+
+const (
+	ThrottleEmail ThrottleScope = "email"
+	ThrottleIP    ThrottleScope = "ip"
+	ThrottleMFA   ThrottleScope = "mfa" // Failed second-factor checks per user ID while signed in
+)
+
+// LoginThrottle counts recent failed sign-ins for an email address or IP, or
+// failed second-factor checks for a user
+type LoginThrottle struct {
+	Scope         ThrottleScope `db:"scope"`
+	Subject       string        `db:"subject"`
+	Failures      int           `db:"failures"`
+	LastFailureAt time.Time     `db:"last_failure_at"`
+	LockedUntil   *time.Time    `db:"locked_until"`
+}
+
+// Locked reports whether the throttle locks sign-ins at now
+func (t *LoginThrottle) Locked(now time.Time) bool {
+	return t.LockedUntil != nil && t.LockedUntil.After(now)
+}
+
+// UnlockAccountRequest carries the token from an unlock email
+type UnlockAccountRequest struct {
+	Token string `json:"token" validate:"required,max=256"`
+}
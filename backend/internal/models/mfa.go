@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TOTPCredential is a user's authenticator app secret. It only protects
+// sign-ins once ConfirmedAt is set.
+type TOTPCredential struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	UserID       uuid.UUID  `json:"user_id" db:"user_id"`
+	Secret       string     `json:"-" db:"secret"`
+	ConfirmedAt  *time.Time `json:"confirmed_at" db:"confirmed_at"`
+	LastUsedStep int64      `json:"-" db:"last_used_step"` // Newest time step accepted, to stop replays
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+// MFAStatus reports a user's two-factor settings
+type MFAStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// TOTPEnrollment is returned when a user starts enrolling an authenticator
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// RecoveryCodesResponse carries newly generated recovery codes. They are
+// shown once and only their hashes are stored.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAChallengeResponse is returned by login when a second factor is needed
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// MFALoginRequest completes a sign-in with a TOTP or recovery code
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+// ConfirmTOTPRequest confirms enrollment with a code from the authenticator
+type ConfirmTOTPRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// DisableMFARequest turns two-factor authentication off
+type DisableMFARequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+// RegenerateRecoveryCodesRequest replaces a user's recovery codes
+type RegenerateRecoveryCodesRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// NewTOTPCredential creates an unconfirmed credential
+func NewTOTPCredential(userID uuid.UUID, secret string) *TOTPCredential {
+	return &TOTPCredential{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	}
+}
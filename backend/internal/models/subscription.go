@@ -123,6 +123,23 @@ type AddPaymentMethodRequest struct {
 	SetAsDefault    bool   `json:"set_as_default"`
 }
 
+type CreatePaymentIntentRequest struct {
+	Amount   int64  `json:"amount" validate:"required,gt=0"` // in the currency's smallest unit
+	Currency string `json:"currency" validate:"required,len=3"`
+}
+
+type PaymentIntentResponse struct {
+	ID           string `json:"id"`
+	ClientSecret string `json:"client_secret"`
+	Amount       int64  `json:"amount"`
+	Currency     string `json:"currency"`
+	Status       string `json:"status"`
+}
+
+type PaymentSuccessRequest struct {
+	PaymentIntentID string `json:"payment_intent_id" validate:"required"`
+}
+
 type SubscriptionSummary struct {
 	Subscription Subscription         `json:"subscription"`
 	Features     SubscriptionFeatures `json:"features"`
@@ -283,6 +300,10 @@ func (s *Subscription) HasAdvancedAnalytics() bool {
 	return s.GetFeatures().AdvancedAnalytics
 }
 
+func (s *Subscription) HasAPIAccess() bool {
+	return s.GetFeatures().APIAccess
+}
+
 // Plan pricing constants (in cents)
 const (
 	PremiumMonthlyPrice = 999  // $9.99/month
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshTokenFamily groups every refresh token rotated from one sign-in
+type RefreshTokenFamily struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	UserID       uuid.UUID  `json:"user_id" db:"user_id"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at" db:"expires_at"` // Expiry of the newest token
+	RevokedAt    *time.Time `json:"revoked_at" db:"revoked_at"`
+	RevokeReason *string    `json:"revoke_reason" db:"revoke_reason"`
+}
+
+// RefreshToken records an issued refresh token by its JWT ID. A token is
+// used once; rotating it sets UsedAt and ReplacedBy.
+type RefreshToken struct {
+	ID         string     `json:"id" db:"jti"`
+	FamilyID   uuid.UUID  `json:"family_id" db:"family_id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	IssuedAt   time.Time  `json:"issued_at" db:"issued_at"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt     *time.Time `json:"used_at" db:"used_at"`
+	ReplacedBy *string    `json:"replaced_by" db:"replaced_by"`
+}
+
+// Session describes the device behind a refresh token family. Its ID is
+// the family ID.
+type Session struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	UserID     uuid.UUID `json:"-" db:"user_id"`
+	Label      string    `json:"label" db:"label"`
+	UserAgent  string    `json:"user_agent" db:"user_agent"`
+	IPAddress  string    `json:"ip_address" db:"ip_address"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at" db:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at" db:"-"`
+	Current    bool      `json:"current" db:"-"`
+}
+
+// ClientInfo identifies the device a request came from
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+	Locale    string // Preferred language from Accept-Language, if any
+}
+
+// Reasons a refresh token family was revoked
+const (
+	RevokeReasonLogout         = "logout"
+	RevokeReasonReuse          = "reuse_detected"
+	RevokeReasonPasswordChange = "password_change"
+	RevokeReasonPasswordReset  = "password_reset"
+	RevokeReasonSignedOut      = "signed_out"
+)
+
+// SecurityEventType identifies a recorded security event
+type SecurityEventType string
+
+const (
+	SecurityEventRefreshTokenReuse SecurityEventType = "refresh_token_reuse"
+
+	// SecurityEventPasskeyCounterRegressed means a passkey's signature
+	// counter went backwards, which suggests a cloned authenticator
+	SecurityEventPasskeyCounterRegressed SecurityEventType = "passkey_counter_regressed"
+)
+
+// SecurityEvent records something suspicious about an account
+type SecurityEvent struct {
+	ID        uuid.UUID         `json:"id" db:"id"`
+	UserID    uuid.UUID         `json:"user_id" db:"user_id"`
+	Type      SecurityEventType `json:"type" db:"event_type"`
+	Details   string            `json:"details" db:"details"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+}
+
+// NewRefreshTokenFamily creates a family for a new sign-in
+func NewRefreshTokenFamily(userID uuid.UUID) *RefreshTokenFamily {
+	return &RefreshTokenFamily{
+		ID:        uuid.New(),
+		UserID:    userID,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// NewSession creates a session for a new token family
+func NewSession(family *RefreshTokenFamily, label string, client ClientInfo) *Session {
+	return &Session{
+		ID:         family.ID,
+		UserID:     family.UserID,
+		Label:      label,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		CreatedAt:  family.CreatedAt,
+		LastUsedAt: family.CreatedAt,
+	}
+}
+
+// NewSecurityEvent creates a security event for a user
+func NewSecurityEvent(userID uuid.UUID, eventType SecurityEventType, details string) *SecurityEvent {
+	return &SecurityEvent{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Type:      eventType,
+		Details:   details,
+		CreatedAt: time.Now().UTC(),
+	}
+}
@@ -18,6 +18,9 @@ type User struct {
 	IsActive  bool      `json:"is_active" db:"is_active"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// Set once the user has followed the link mailed to their address
+	EmailVerifiedAt *time.Time `json:"email_verified_at" db:"email_verified_at"`
 }
 
 // UserProfile represents the user's public profile
@@ -79,10 +82,34 @@ type RefreshTokenRequest struct {
 
 // ChangePasswordRequest represents the change password request
 type ChangePasswordRequest struct {
-	CurrentPassword string `json:"current_password" validate:"required"`
+	CurrentPassword string `json:"current_password"` // Empty if the account has no password yet
 	NewPassword     string `json:"new_password" validate:"required,min=8"`
 }
 
+// LogoutRequest represents the logout request
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// ForgotPasswordRequest represents the request to start a password reset
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest represents the request to set a new password with a reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8"`
+}
+
+// ValidatePasswordRequest represents the request to check password strength
+type ValidatePasswordRequest struct {
+	Password  string `json:"password" validate:"required"`
+	Email     string `json:"email,omitempty"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+}
+
 // NewUser creates a new user with a generated UUID
 func NewUser(email, passwordHash, firstName, lastName, timezone string) *User {
 	return &User{
@@ -112,4 +139,22 @@ func (u *User) ToProfile() UserProfile {
 // FullName returns the user's full name
 func (u *User) FullName() string {
 	return u.FirstName + " " + u.LastName
-}
\ No newline at end of file
+}
+
+// HasPassword reports whether the user can sign in with a password. Users
+// created through an OpenID Connect provider have none until they set one.
+func (u *User) HasPassword() bool {
+	return u.Password != ""
+}
+
+// EmailVerified reports whether the user has confirmed they own their email
+// address
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+// PasswordHistoryEntry is a password hash a user has had
+type PasswordHistoryEntry struct {
+	UserID       uuid.UUID `json:"-" db:"user_id"`
+	PasswordHash string    `json:"-" db:"password_hash"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
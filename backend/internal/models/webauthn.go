@@ -0,0 +1,174 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthn ceremonies a challenge can be used for
+const (
+	WebAuthnRegistration = "registration"
+	WebAuthnLogin        = "login"
+)
+
+// WebAuthnCredential is a passkey registered to a user
+type WebAuthnCredential struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	UserID       uuid.UUID  `json:"-" db:"user_id"`
+	CredentialID []byte     `json:"-" db:"credential_id"`
+	PublicKey    []byte     `json:"-" db:"public_key"` // COSE_Key
+	SignCount    uint32     `json:"-" db:"sign_count"`
+	AAGUID       []byte     `json:"-" db:"aaguid"`
+	Transports   []string   `json:"transports" db:"transports"`
+	Name         string     `json:"name" db:"name"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at" db:"last_used_at"`
+}
+
+// WebAuthnChallenge is a challenge issued for one registration or sign-in.
+// It can be used once. UserID is nil for a sign-in where the user has not
+// said who they are.
+type WebAuthnChallenge struct {
+	ID        uuid.UUID  `db:"id"`
+	UserID    *uuid.UUID `db:"user_id"`
+	Ceremony  string     `db:"ceremony"`
+	Challenge []byte     `db:"challenge"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// The PublicKeyCredential*Options types follow the browser's JSON form
+// (PublicKeyCredential.parseCreationOptionsFromJSON), so binary values are
+// base64url strings and field names are camelCase.
+
+// PublicKeyCredentialRPEntity names the relying party
+type PublicKeyCredentialRPEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// PublicKeyCredentialUserEntity identifies the account a passkey is for
+type PublicKeyCredentialUserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// PublicKeyCredentialParameters names an accepted key algorithm
+type PublicKeyCredentialParameters struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// PublicKeyCredentialDescriptor refers to an existing credential
+type PublicKeyCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// AuthenticatorSelectionCriteria says which authenticators may be used
+type AuthenticatorSelectionCriteria struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// PublicKeyCredentialCreationOptions is passed to navigator.credentials.create
+type PublicKeyCredentialCreationOptions struct {
+	RP                     PublicKeyCredentialRPEntity     `json:"rp"`
+	User                   PublicKeyCredentialUserEntity   `json:"user"`
+	Challenge              string                          `json:"challenge"`
+	PubKeyCredParams       []PublicKeyCredentialParameters `json:"pubKeyCredParams"`
+	Timeout                int64                           `json:"timeout"`
+	ExcludeCredentials     []PublicKeyCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelectionCriteria  `json:"authenticatorSelection"`
+	Attestation            string                          `json:"attestation"`
+}
+
+// PublicKeyCredentialRequestOptions is passed to navigator.credentials.get
+type PublicKeyCredentialRequestOptions struct {
+	Challenge        string                          `json:"challenge"`
+	Timeout          int64                           `json:"timeout"`
+	RPID             string                          `json:"rpId"`
+	AllowCredentials []PublicKeyCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                          `json:"userVerification"`
+}
+
+// WebAuthnRegistrationOptions starts registering a passkey. ChallengeID
+// must be sent back with the browser's response.
+type WebAuthnRegistrationOptions struct {
+	ChallengeID uuid.UUID                          `json:"challenge_id"`
+	PublicKey   PublicKeyCredentialCreationOptions `json:"public_key"`
+}
+
+// WebAuthnLoginOptions starts a passkey sign-in. ChallengeID must be sent
+// back with the browser's response.
+type WebAuthnLoginOptions struct {
+	ChallengeID uuid.UUID                         `json:"challenge_id"`
+	PublicKey   PublicKeyCredentialRequestOptions `json:"public_key"`
+}
+
+// AuthenticatorAttestationResponse is the browser's JSON form of a
+// credential returned by navigator.credentials.create
+type AuthenticatorAttestationResponse struct {
+	ID       string `json:"id" validate:"required"`
+	RawID    string `json:"rawId" validate:"required"`
+	Type     string `json:"type" validate:"required,eq=public-key"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON" validate:"required"`
+		AttestationObject string   `json:"attestationObject" validate:"required"`
+		Transports        []string `json:"transports" validate:"max=8,dive,max=32"`
+	} `json:"response"`
+}
+
+// AuthenticatorAssertionResponse is the browser's JSON form of a credential
+// returned by navigator.credentials.get
+type AuthenticatorAssertionResponse struct {
+	ID       string `json:"id" validate:"required"`
+	RawID    string `json:"rawId" validate:"required"`
+	Type     string `json:"type" validate:"required,eq=public-key"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" validate:"required"`
+		AuthenticatorData string `json:"authenticatorData" validate:"required"`
+		Signature         string `json:"signature" validate:"required"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// FinishWebAuthnRegistrationRequest completes registering a passkey
+type FinishWebAuthnRegistrationRequest struct {
+	ChallengeID uuid.UUID                        `json:"challenge_id" validate:"required"`
+	Name        string                           `json:"name" validate:"max=100"`
+	Credential  AuthenticatorAttestationResponse `json:"credential"`
+}
+
+// BeginWebAuthnLoginRequest starts a passkey sign-in. Without an email the
+// browser offers every passkey it holds for the site.
+type BeginWebAuthnLoginRequest struct {
+	Email string `json:"email" validate:"omitempty,email"`
+}
+
+// FinishWebAuthnLoginRequest completes a passkey sign-in
+type FinishWebAuthnLoginRequest struct {
+	ChallengeID uuid.UUID                      `json:"challenge_id" validate:"required"`
+	Credential  AuthenticatorAssertionResponse `json:"credential"`
+}
+
+// UpdateWebAuthnCredentialRequest renames a passkey
+type UpdateWebAuthnCredentialRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// NewWebAuthnChallenge creates a challenge that expires after ttl
+func NewWebAuthnChallenge(userID *uuid.UUID, ceremony string, challenge []byte, ttl time.Duration) *WebAuthnChallenge {
+	now := time.Now().UTC()
+	return &WebAuthnChallenge{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Ceremony:  ceremony,
+		Challenge: challenge,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-memory Store that tracks at most capacity keys,
+// forgetting the least recently seen one to make room. Counts are lost on
+// restart and are not shared between processes.
+type MemoryStore struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // Most recently hit first
+	counters map[string]*list.Element
+}
+
+// counter is the value of the elements of MemoryStore.order
+type counter struct {
+	key     string
+	count   int
+	resetAt time.Time
+}
+
+// NewMemoryStore creates an in-memory store tracking up to capacity keys
+func NewMemoryStore(capacity int) *MemoryStore {
+	return &MemoryStore{
+		capacity: max(capacity, 1),
+		order:    list.New(),
+		counters: make(map[string]*list.Element),
+	}
+}
+
+// Hit counts a request against key
+func (s *MemoryStore) Hit(ctx context.Context, key string, window time.Duration, now time.Time) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.counters[key]; ok {
+		c := e.Value.(*counter)
+		if !c.resetAt.After(now) {
+			c.count, c.resetAt = 0, now.Add(window)
+		}
+		c.count++
+		s.order.MoveToFront(e)
+		return c.count, c.resetAt, nil
+	}
+
+	if s.order.Len() >= s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.counters, oldest.Value.(*counter).key)
+	}
+	c := &counter{key: key, count: 1, resetAt: now.Add(window)}
+	s.counters[key] = s.order.PushFront(c)
+	return c.count, c.resetAt, nil
+}
+
+// Cleanup removes up to limit counters whose window has ended
+func (s *MemoryStore) Cleanup(ctx context.Context, limit int) (int, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for e := s.order.Back(); e != nil && removed < limit; {
+		prev := e.Prev()
+		if c := e.Value.(*counter); !c.resetAt.After(now) {
+			s.order.Remove(e)
+			delete(s.counters, c.key)
+			removed++
+		}
+		e = prev
+	}
+	return removed, nil
+}
+
+// Len returns how many keys the store tracks
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+var _ Store = (*MemoryStore)(nil)
@@ -0,0 +1,79 @@
+// Package ratelimit counts requests per client in fixed windows.
+//
+// A Policy allows Limit requests per Window for each key, such as a user ID
+// or an IP address. The counters live in a Store, which is either kept in
+// memory or in the database so limits survive restarts.
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Policy limits how many requests one client may make in a window. Name
+// keeps the counters of different policies apart.
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// String formats the policy for the RateLimit-Policy header, e.g. "100;w=60"
+func (p Policy) String() string {
+	return strconv.Itoa(p.Limit) + ";w=" + strconv.Itoa(int(p.Window/time.Second))
+}
+
+// Store keeps request counters. Implementations must be safe for concurrent
+// use.
+type Store interface {
+	// Hit counts a request against key and returns the number of requests
+	// in the current window, which ends at resetAt. A window starts at the
+	// first request after the previous one ended and lasts window.
+	Hit(ctx context.Context, key string, window time.Duration, now time.Time) (count int, resetAt time.Time, err error)
+
+	// Cleanup removes up to limit counters whose window has ended and
+	// returns how many it removed
+	Cleanup(ctx context.Context, limit int) (int, error)
+}
+
+// Decision is the outcome of counting one request
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the window ends and the count starts over
+	Reset time.Duration
+}
+
+// Limiter applies policies to requests, counting them in a store
+type Limiter struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewLimiter creates a limiter counting requests in store
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{store: store, now: time.Now}
+}
+
+// Allow counts a request by the client identified by key against policy
+// and reports whether it is within the limit
+func (l *Limiter) Allow(ctx context.Context, policy Policy, key string) (Decision, error) {
+	now := l.now().UTC()
+	count, resetAt, err := l.store.Hit(ctx, policy.Name+":"+key, policy.Window, now)
+	if err != nil {
+		return Decision{}, err
+	}
+	return Decision{
+		Allowed:   count <= policy.Limit,
+		Limit:     policy.Limit,
+		Remaining: max(policy.Limit-count, 0),
+		Reset:     max(resetAt.Sub(now), 0),
+	}, nil
+}
+
+// Cleanup removes up to limit expired counters from the store
+func (l *Limiter) Cleanup(ctx context.Context, limit int) (int, error) {
+	return l.store.Cleanup(ctx, limit)
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestLimiter returns a limiter over a memory store whose clock the test
+// moves with the returned pointer
+func newTestLimiter(capacity int) (*Limiter, *MemoryStore, *time.Time) {
+	store := NewMemoryStore(capacity)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(store)
+	l.now = func() time.Time { return now }
+	return l, store, &now
+}
+
+func TestLimiterAllowsUpToLimit(t *testing.T) {
+	l, _, now := newTestLimiter(10)
+	ctx := context.Background()
+	policy := Policy{Name: "auth", Limit: 3, Window: time.Minute}
+
+	for i := 1; i <= 3; i++ {
+		d, err := l.Allow(ctx, policy, "ip:203.0.113.7")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d.Allowed || d.Remaining != 3-i || d.Limit != 3 || d.Reset != time.Minute {
+			t.Fatalf("request %d: %+v", i, d)
+		}
+	}
+
+	*now = now.Add(20 * time.Second)
+	d, _ := l.Allow(ctx, policy, "ip:203.0.113.7")
+	if d.Allowed || d.Remaining != 0 || d.Reset != 40*time.Second {
+		t.Fatalf("over the limit: %+v, want rejected with the window ending in 40s", d)
+	}
+
+	// The next window starts over
+	*now = now.Add(40 * time.Second)
+	d, _ = l.Allow(ctx, policy, "ip:203.0.113.7")
+	if !d.Allowed || d.Remaining != 2 || d.Reset != time.Minute {
+		t.Fatalf("next window: %+v", d)
+	}
+}
+
+func TestLimiterCountsPoliciesAndKeysApart(t *testing.T) {
+	l, _, _ := newTestLimiter(10)
+	ctx := context.Background()
+	auth := Policy{Name: "auth", Limit: 1, Window: time.Minute}
+	api := Policy{Name: "api", Limit: 1, Window: time.Minute}
+
+	l.Allow(ctx, auth, "ip:203.0.113.7")
+	if d, _ := l.Allow(ctx, auth, "ip:198.51.100.20"); !d.Allowed {
+		t.Error("another client was limited")
+	}
+	if d, _ := l.Allow(ctx, api, "ip:203.0.113.7"); !d.Allowed {
+		t.Error("another policy was limited")
+	}
+	if d, _ := l.Allow(ctx, auth, "ip:203.0.113.7"); d.Allowed {
+		t.Error("the second request was allowed")
+	}
+}
+
+func TestMemoryStoreForgetsLeastRecentlyUsed(t *testing.T) {
+	l, store, _ := newTestLimiter(2)
+	ctx := context.Background()
+	policy := Policy{Name: "api", Limit: 1, Window: time.Minute}
+
+	l.Allow(ctx, policy, "a")
+	l.Allow(ctx, policy, "b")
+	l.Allow(ctx, policy, "a") // b is now the least recently used
+	l.Allow(ctx, policy, "c")
+
+	if store.Len() != 2 {
+		t.Fatalf("store tracks %d keys, want 2", store.Len())
+	}
+	if d, _ := l.Allow(ctx, policy, "a"); d.Allowed {
+		t.Error("a recently used key was forgotten")
+	}
+	if d, _ := l.Allow(ctx, policy, "b"); !d.Allowed {
+		t.Error("the least recently used key was kept")
+	}
+}
+
+func TestMemoryStoreCleanup(t *testing.T) {
+	store := NewMemoryStore(10)
+	ctx := context.Background()
+	now := time.Now()
+
+	store.Hit(ctx, "ended", time.Minute, now.Add(-2*time.Minute))
+	store.Hit(ctx, "current", time.Minute, now)
+	if removed, _ := store.Cleanup(ctx, 10); removed != 1 {
+		t.Fatalf("removed %d counters, want the ended one", removed)
+	}
+	if store.Len() != 1 {
+		t.Fatalf("store tracks %d keys, want 1", store.Len())
+	}
+}
+
+func TestPolicyString(t *testing.T) {
+	if got := (Policy{Limit: 100, Window: time.Minute}).String(); got != "100;w=60" {
+		t.Errorf("String() = %q, want 100;w=60", got)
+	}
+}
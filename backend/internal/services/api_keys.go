@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/database"
+	"chainforge/internal/models"
+)
+
+const (
+	// maxAPIKeys is how many API keys one account can have
+	maxAPIKeys = 20
+	// apiKeyTouchInterval is how stale the last use of a key may get before
+	// it is written again, so busy keys do not write on every request
+	apiKeyTouchInterval = time.Minute
+)
+
+// invalidAPIKey is returned for every API key that is not accepted
+func invalidAPIKey() error {
+	return newError(ErrInvalidCredentials, "invalid or expired API key")
+}
+
+// CreateAPIKey creates a personal API key. The key itself is returned only
+// this once; afterwards just its prefix is known.
+func (s *UserService) CreateAPIKey(ctx context.Context, userID uuid.UUID, req models.CreateAPIKeyRequest, client models.ClientInfo) (*models.CreatedAPIKey, error) {
+	if err := requireAPIAccess(ctx, s.store, userID); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, newError(ErrInvalidInput, "name is required")
+	}
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil {
+		t := req.ExpiresAt.UTC()
+		if !t.After(s.now()) {
+			return nil, newError(ErrInvalidInput, "expires_at must be in the future")
+		}
+		expiresAt = &t
+	}
+
+	count, err := s.store.APIKeys().CountAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxAPIKeys {
+		return nil, newError(ErrConflict, "an account can have at most %d API keys", maxAPIKeys)
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	apiKey := models.NewAPIKey(userID, name, prefix, hash, scopes, expiresAt)
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		if err := tx.APIKeys().CreateAPIKey(ctx, apiKey); err != nil {
+			return err
+		}
+		details := fmt.Sprintf(`{"api_key_id":%q,"prefix":%q}`, apiKey.ID, apiKey.Prefix)
+		return tx.Audit().Create(ctx, models.NewUserAuditLog(userID, models.AuditAPIKeyCreated, details, client))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.CreatedAPIKey{APIKey: *apiKey, Key: key}, nil
+}
+
+// ListAPIKeys returns a user's API keys
+func (s *UserService) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	return s.store.APIKeys().ListAPIKeys(ctx, userID)
+}
+
+// RevokeAPIKey deletes one of a user's API keys
+func (s *UserService) RevokeAPIKey(ctx context.Context, userID, id uuid.UUID, client models.ClientInfo) error {
+	return s.store.WithTx(ctx, func(tx database.Store) error {
+		if err := tx.APIKeys().DeleteAPIKey(ctx, userID, id); err != nil {
+			return notFound(err, "API key")
+		}
+		details := fmt.Sprintf(`{"api_key_id":%q}`, id)
+		return tx.Audit().Create(ctx, models.NewUserAuditLog(userID, models.AuditAPIKeyRevoked, details, client))
+	})
+}
+
+// AuthenticateAPIKey returns the API key a request presented. Keys stop
+// working when they expire, when their owner is deactivated and while the
+// owner's plan does not include API access.
+func (s *UserService) AuthenticateAPIKey(ctx context.Context, key string) (*models.APIKey, error) {
+	apiKey, err := s.store.APIKeys().GetAPIKeyByHash(ctx, auth.HashAPIKey(key))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, invalidAPIKey()
+		}
+		return nil, err
+	}
+	now := s.now().UTC()
+	if apiKey.Expired(now) {
+		return nil, invalidAPIKey()
+	}
+	user, err := s.store.Users().GetByID(ctx, apiKey.UserID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, invalidAPIKey()
+		}
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, invalidAPIKey()
+	}
+	if err := requireAPIAccess(ctx, s.store, apiKey.UserID); err != nil {
+		return nil, err
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.store.APIKeys().TouchAPIKey(ctx, apiKey.ID, now); err != nil {
+			return nil, err
+		}
+		apiKey.LastUsedAt = &now
+	}
+	return apiKey, nil
+}
+
+// requireAPIAccess checks that a user's plan includes API access
+func requireAPIAccess(ctx context.Context, store database.Store, userID uuid.UUID) error {
+	sub, err := store.Subscriptions().GetByUser(ctx, userID)
+	if err != nil {
+		return notFound(err, "subscription")
+	}
+	if !effectivePlan(sub).HasAPIAccess() {
+		return newError(ErrPremiumRequired, "API keys require a premium subscription")
+	}
+	return nil
+}
+
+// normalizeScopes checks that every scope exists and drops duplicates
+func normalizeScopes(scopes []models.APIKeyScope) ([]models.APIKeyScope, error) {
+	normalized := []models.APIKeyScope{}
+	for _, scope := range scopes {
+		if !slices.Contains(models.APIKeyScopes, scope) {
+			return nil, newError(ErrInvalidInput, "unknown scope %q", scope)
+		}
+		if !slices.Contains(normalized, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, newError(ErrInvalidInput, "at least one scope is required")
+	}
+	return normalized, nil
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/models"
+)
+
+func TestCreateAndAuthenticateAPIKey(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+	user := seedUser(t, store, models.PlanPremium)
+
+	created, err := svc.CreateAPIKey(ctx, user.ID, models.CreateAPIKeyRequest{
+		Name:   " CI ",
+		Scopes: []models.APIKeyScope{models.ScopeGoalsRead, models.ScopeProgressWrite, models.ScopeGoalsRead},
+	}, testClient)
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	if created.Name != "CI" || len(created.Scopes) != 2 || !strings.HasPrefix(created.Key, created.Prefix) {
+		t.Fatalf("created key = %+v", created)
+	}
+	if store.apiKeys[created.ID].KeyHash != auth.HashAPIKey(created.Key) {
+		t.Fatal("the key was stored in the clear")
+	}
+	if !hasAuditEntry(store, user.ID, models.AuditAPIKeyCreated) {
+		t.Error("the new key was not audited")
+	}
+
+	key, err := svc.AuthenticateAPIKey(ctx, created.Key)
+	if err != nil {
+		t.Fatalf("AuthenticateAPIKey: %v", err)
+	}
+	if key.UserID != user.ID || !key.HasScope(models.ScopeProgressWrite) || key.HasScope(models.ScopeGoalsWrite) {
+		t.Fatalf("authenticated key = %+v", key)
+	}
+	if store.apiKeys[created.ID].LastUsedAt == nil {
+		t.Error("the key's last use was not recorded")
+	}
+
+	if _, err := svc.AuthenticateAPIKey(ctx, created.Key+"x"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("wrong key: err = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestCreateAPIKeyValidates(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+	user := seedUser(t, store, models.PlanPremium)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []models.CreateAPIKeyRequest{
+		{Name: "  ", Scopes: []models.APIKeyScope{models.ScopeGoalsRead}},
+		{Name: "CI", Scopes: []models.APIKeyScope{"admin"}},
+		{Name: "CI", Scopes: []models.APIKeyScope{}},
+		{Name: "CI", Scopes: []models.APIKeyScope{models.ScopeGoalsRead}, ExpiresAt: &past},
+	}
+	for _, req := range tests {
+		if _, err := svc.CreateAPIKey(ctx, user.ID, req, testClient); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("CreateAPIKey(%+v): err = %v, want ErrInvalidInput", req, err)
+		}
+	}
+	if len(store.apiKeys) != 0 {
+		t.Error("an invalid key was stored")
+	}
+}
+
+func TestAPIKeysRequireAPIAccess(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+	req := models.CreateAPIKeyRequest{Name: "CI", Scopes: []models.APIKeyScope{models.ScopeGoalsRead}}
+
+	free := seedUser(t, store, models.PlanFree)
+	if _, err := svc.CreateAPIKey(ctx, free.ID, req, testClient); !errors.Is(err, ErrPremiumRequired) {
+		t.Fatalf("free plan: err = %v, want ErrPremiumRequired", err)
+	}
+
+	// Keys stop working once their owner drops to the free plan
+	user := seedUser(t, store, models.PlanPremium)
+	created, err := svc.CreateAPIKey(ctx, user.ID, req, testClient)
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	sub, _ := store.Subscriptions().GetByUser(ctx, user.ID)
+	sub.Plan = models.PlanFree
+	store.subscriptions[sub.ID] = *sub
+	if _, err := svc.AuthenticateAPIKey(ctx, created.Key); !errors.Is(err, ErrPremiumRequired) {
+		t.Errorf("after the downgrade: err = %v, want ErrPremiumRequired", err)
+	}
+}
+
+func TestAPIKeyExpiresAndIsRevoked(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+	user := seedUser(t, store, models.PlanPremium)
+	expiresAt := time.Now().Add(time.Hour)
+
+	created, err := svc.CreateAPIKey(ctx, user.ID, models.CreateAPIKeyRequest{
+		Name: "CI", Scopes: []models.APIKeyScope{models.ScopeGoalsRead}, ExpiresAt: &expiresAt,
+	}, testClient)
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	k := store.apiKeys[created.ID]
+	expired := time.Now().Add(-time.Minute)
+	k.ExpiresAt = &expired
+	store.apiKeys[created.ID] = k
+	if _, err := svc.AuthenticateAPIKey(ctx, created.Key); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expired key: err = %v, want ErrInvalidCredentials", err)
+	}
+
+	other := seedUser(t, store, models.PlanPremium)
+	if err := svc.RevokeAPIKey(ctx, other.ID, created.ID, testClient); !errors.Is(err, ErrNotFound) {
+		t.Errorf("revoking another user's key: err = %v, want ErrNotFound", err)
+	}
+	if err := svc.RevokeAPIKey(ctx, user.ID, created.ID, testClient); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	if keys, _ := svc.ListAPIKeys(ctx, user.ID); len(keys) != 0 {
+		t.Errorf("%d keys left after revoking", len(keys))
+	}
+	if !hasAuditEntry(store, user.ID, models.AuditAPIKeyRevoked) {
+		t.Error("the revocation was not audited")
+	}
+}
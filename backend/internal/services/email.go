@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/database"
+	"chainforge/internal/models"
+)
+
+// AccountEmailConfig configures the emails users are sent to verify their
+// address and reset their password
+type AccountEmailConfig struct {
+	Mailer           *Mailer
+	AppURL           string // Links in emails open the web app here
+	VerifyEmailTTL   time.Duration
+	PasswordResetTTL time.Duration
+}
+
+// Templates of the account emails
+const (
+	emailVerifyEmail   = "verify_email"
+	emailResetPassword = "reset_password"
+)
+
+// linkEmail is the data of emails carrying a single-use link
+type linkEmail struct {
+	Name      string
+	Link      string
+	ExpiresIn time.Duration
+}
+
+// SendVerificationEmail mails a user a new link to verify their email
+// address. Links sent earlier stop working.
+func (s *UserService) SendVerificationEmail(ctx context.Context, userID uuid.UUID, client models.ClientInfo) error {
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		user, err := tx.Users().GetByID(ctx, userID)
+		if err != nil {
+			return notFound(err, "user")
+		}
+		if user.EmailVerified() {
+			return newError(ErrConflict, "your email address is already verified")
+		}
+		return s.queueVerificationEmail(ctx, tx, user, client.Locale)
+	})
+	if err != nil {
+		return err
+	}
+	s.mail.Mailer.Notify()
+	return nil
+}
+
+// VerifyEmail marks the email address of the user a verification link was
+// sent to as verified
+func (s *UserService) VerifyEmail(ctx context.Context, req models.VerifyEmailRequest, client models.ClientInfo) error {
+	return s.store.WithTx(ctx, func(tx database.Store) error {
+		now := s.now().UTC()
+		token, err := tx.EmailTokens().TakeEmailToken(ctx, models.EmailTokenVerifyEmail, auth.HashEmailToken(req.Token), now)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				return newError(ErrInvalidInput, "this verification link is invalid or has expired")
+			}
+			return err
+		}
+		user, err := tx.Users().GetByID(ctx, token.UserID)
+		if err != nil {
+			return notFound(err, "user")
+		}
+		if user.EmailVerified() {
+			return nil
+		}
+		return s.markEmailVerified(ctx, tx, user.ID, now, client)
+	})
+}
+
+// ForgotPassword mails a password reset link to the account with the given
+// email. It succeeds whether or not there is such an account so the
+// response does not reveal who has one.
+func (s *UserService) ForgotPassword(ctx context.Context, req models.ForgotPasswordRequest, client models.ClientInfo) error {
+	user, err := s.store.Users().GetByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !user.IsActive {
+		return nil
+	}
+
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		token, err := s.issueEmailToken(ctx, tx, user.ID, models.EmailTokenResetPassword, s.mail.PasswordResetTTL)
+		if err != nil {
+			return err
+		}
+		return s.mail.Mailer.Queue(ctx, tx, user, emailResetPassword, client.Locale, linkEmail{
+			Name:      user.FirstName,
+			Link:      s.emailLink("/auth/reset-password", token),
+			ExpiresIn: s.mail.PasswordResetTTL,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	s.mail.Mailer.Notify()
+	return nil
+}
+
+// ResetPassword sets a new password with the token from a reset link. Every
+// session and API key of the account is revoked and a sign-in lock is
+// lifted, and following the link proves the user owns the address, so it
+// counts as verified. Recently used passwords are refused.
+func (s *UserService) ResetPassword(ctx context.Context, req models.ResetPasswordRequest, client models.ClientInfo) error {
+	// Check the password first so a rejected one does not spend the link
+	if result := auth.ValidatePassword(req.NewPassword); !result.IsValid {
+		return newError(ErrInvalidInput, "%s", strings.Join(result.Errors, "; "))
+	}
+	hash, err := s.passwords.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	now := s.now().UTC()
+	var userID uuid.UUID
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		token, err := tx.EmailTokens().TakeEmailToken(ctx, models.EmailTokenResetPassword, auth.HashEmailToken(req.Token), now)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				return newError(ErrInvalidInput, "this password reset link is invalid or has expired")
+			}
+			return err
+		}
+		user, err := tx.Users().GetByID(ctx, token.UserID)
+		if err != nil {
+			return notFound(err, "user")
+		}
+		if !user.IsActive {
+			return newError(ErrForbidden, "this account has been deactivated")
+		}
+		// A password made from the user's own name or email is only caught
+		// now; failing rolls back taking the token, so the link still works
+		if result := auth.ValidatePassword(req.NewPassword, user.Email, user.FirstName, user.LastName); !result.IsValid {
+			return newError(ErrInvalidInput, "%s", strings.Join(result.Errors, "; "))
+		}
+		if err := s.checkPasswordReuse(ctx, tx, user, req.NewPassword); err != nil {
+			return err
+		}
+		userID = user.ID
+
+		if err := tx.Users().UpdatePassword(ctx, user.ID, hash); err != nil {
+			return notFound(err, "user")
+		}
+		if err := s.recordPassword(ctx, tx, user.ID, hash, now); err != nil {
+			return err
+		}
+		if err := s.revokeCredentials(ctx, tx, user.ID, models.RevokeReasonPasswordReset, client, now); err != nil {
+			return err
+		}
+		if err := tx.Throttles().ClearThrottle(ctx, models.ThrottleEmail, database.NormalizeEmail(user.Email)); err != nil {
+			return err
+		}
+		if err := tx.Audit().Create(ctx, models.NewUserAuditLog(user.ID, models.AuditPasswordReset, "", client)); err != nil {
+			return err
+		}
+		if user.EmailVerified() {
+			return nil
+		}
+		return s.markEmailVerified(ctx, tx, user.ID, now, client)
+	})
+	if err != nil {
+		return err
+	}
+	return s.revocations.RevokeAllForUser(ctx, userID, now)
+}
+
+// queueVerificationEmail queues an email with a link to verify user's
+// email address
+func (s *UserService) queueVerificationEmail(ctx context.Context, tx database.Store, user *models.User, locale string) error {
+	token, err := s.issueEmailToken(ctx, tx, user.ID, models.EmailTokenVerifyEmail, s.mail.VerifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	return s.mail.Mailer.Queue(ctx, tx, user, emailVerifyEmail, locale, linkEmail{
+		Name:      user.FirstName,
+		Link:      s.emailLink("/auth/verify-email", token),
+		ExpiresIn: s.mail.VerifyEmailTTL,
+	})
+}
+
+// issueEmailToken stores a new token for purpose, revoking the user's
+// earlier ones, and returns the token to mail
+func (s *UserService) issueEmailToken(ctx context.Context, tx database.Store, userID uuid.UUID, purpose models.EmailTokenPurpose, ttl time.Duration) (string, error) {
+	token, hash, err := auth.GenerateEmailToken()
+	if err != nil {
+		return "", err
+	}
+	t := models.NewEmailToken(userID, purpose, hash, ttl)
+	if err := tx.EmailTokens().RevokeEmailTokens(ctx, userID, purpose, t.CreatedAt); err != nil {
+		return "", err
+	}
+	if err := tx.EmailTokens().CreateEmailToken(ctx, t); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// markEmailVerified records that a user verified their email and audits it
+func (s *UserService) markEmailVerified(ctx context.Context, tx database.Store, userID uuid.UUID, at time.Time, client models.ClientInfo) error {
+	if err := tx.Users().MarkEmailVerified(ctx, userID, at); err != nil {
+		return err
+	}
+	if err := tx.EmailTokens().RevokeEmailTokens(ctx, userID, models.EmailTokenVerifyEmail, at); err != nil {
+		return err
+	}
+	return tx.Audit().Create(ctx, models.NewUserAuditLog(userID, models.AuditEmailVerified, "", client))
+}
+
+// emailLink returns the link to path in the web app carrying token
+func (s *UserService) emailLink(path, token string) string {
+	return strings.TrimRight(s.mail.AppURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// requireVerifiedEmail fails with ErrEmailUnverified unless the user has
+// verified their email address. action completes "verify your email
+// address to ...".
+func requireVerifiedEmail(ctx context.Context, store database.Store, userID uuid.UUID, action string) error {
+	user, err := store.Users().GetByID(ctx, userID)
+	if err != nil {
+		return notFound(err, "user")
+	}
+	if !user.EmailVerified() {
+		return newError(ErrEmailUnverified, "verify your email address to %s", action)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/email"
+	"chainforge/internal/models"
+)
+
+// testTemplates are the embedded email templates
+var testTemplates = func() *email.Templates {
+	templates, err := email.LoadTemplates()
+	if err != nil {
+		panic(err)
+	}
+	return templates
+}()
+
+// mailbox is an email.Sender that keeps what it is sent. Reading it
+// delivers what the services queued first.
+type mailbox struct {
+	mailer *Mailer
+
+	mu       sync.Mutex
+	messages []email.Message
+}
+
+// newMailbox creates a mailbox with a mailer delivering from store into it
+func newMailbox(store *memStore) *mailbox {
+	box := &mailbox{}
+	box.mailer = NewMailer(store, box, testTemplates)
+	return box
+}
+
+func (b *mailbox) Send(ctx context.Context, msg email.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = append(b.messages, msg)
+	return nil
+}
+
+// last delivers queued emails and returns the newest message sent to addr
+func (b *mailbox) last(t *testing.T, addr string) email.Message {
+	t.Helper()
+	if _, err := b.mailer.Deliver(context.Background()); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := len(b.messages) - 1; i >= 0; i-- {
+		if b.messages[i].To == addr {
+			return b.messages[i]
+		}
+	}
+	t.Fatalf("no email sent to %s", addr)
+	return email.Message{}
+}
+
+func testAccountEmails(box *mailbox) AccountEmailConfig {
+	return AccountEmailConfig{
+		Mailer:           box.mailer,
+		AppURL:           "https://app.chainforge.test/",
+		VerifyEmailTTL:   48 * time.Hour,
+		PasswordResetTTL: time.Hour,
+	}
+}
+
+func newMailingUserService(store *memStore) (*UserService, *mailbox) {
+	box := newMailbox(store)
+	svc := NewUserService(store, newTestTokenManager(), auth.NewMemoryRevocationStore(time.Hour), testPasswords, testRelyingParty,
+		nil, testAccountEmails(box), testLoginThrottle, testPasswordPolicy, time.Monday)
+	return svc, box
+}
+
+var linkPattern = regexp.MustCompile(`https://\S+`)
+
+// linkToken returns the token of the link to path in msg
+func linkToken(t *testing.T, msg email.Message, path string) string {
+	t.Helper()
+	u, err := url.Parse(linkPattern.FindString(msg.Text))
+	if err != nil || u.Path != path || u.Query().Get("token") == "" {
+		t.Fatalf("no link to %s in %q", path, msg.Text)
+	}
+	return u.Query().Get("token")
+}
+
+func TestRegisterRequiresEmailVerification(t *testing.T) {
+	store := newMemStore()
+	svc, box := newMailingUserService(store)
+	groups := NewGroupService(store, time.Monday)
+	ctx := context.Background()
+
+	user, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if user.EmailVerified() {
+		t.Fatal("a new account starts out verified")
+	}
+	msg := box.last(t, "ada@example.com")
+	token := linkToken(t, msg, "/auth/verify-email")
+
+	if _, err := groups.CreateGroup(ctx, user.ID, models.CreateGroupRequest{Name: "Runners", MaxMembers: 5}); !errors.Is(err, ErrEmailUnverified) {
+		t.Fatalf("CreateGroup before verifying: err = %v, want ErrEmailUnverified", err)
+	}
+
+	if err := svc.VerifyEmail(ctx, models.VerifyEmailRequest{Token: token}, testClient); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if user, _ = svc.GetUser(ctx, user.ID); !user.EmailVerified() {
+		t.Fatal("email is not verified")
+	}
+	if !hasAuditEntry(store, user.ID, models.AuditEmailVerified) {
+		t.Error("verification was not audited")
+	}
+	if err := svc.VerifyEmail(ctx, models.VerifyEmailRequest{Token: token}, testClient); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("reused link: err = %v, want ErrInvalidInput", err)
+	}
+
+	// Verified, the user is now only held back by their plan
+	if _, err := groups.CreateGroup(ctx, user.ID, models.CreateGroupRequest{Name: "Runners", MaxMembers: 5}); !errors.Is(err, ErrPremiumRequired) {
+		t.Fatalf("CreateGroup after verifying: err = %v, want ErrPremiumRequired", err)
+	}
+	if err := svc.SendVerificationEmail(ctx, user.ID, testClient); !errors.Is(err, ErrConflict) {
+		t.Fatalf("resending once verified: err = %v, want ErrConflict", err)
+	}
+}
+
+func TestSendVerificationEmailReplacesEarlierLink(t *testing.T) {
+	store := newMemStore()
+	svc, box := newMailingUserService(store)
+	ctx := context.Background()
+
+	user, _, _ := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	first := linkToken(t, box.last(t, "ada@example.com"), "/auth/verify-email")
+	if err := svc.SendVerificationEmail(ctx, user.ID, testClient); err != nil {
+		t.Fatalf("SendVerificationEmail: %v", err)
+	}
+	second := linkToken(t, box.last(t, "ada@example.com"), "/auth/verify-email")
+
+	if err := svc.VerifyEmail(ctx, models.VerifyEmailRequest{Token: first}, testClient); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("replaced link: err = %v, want ErrInvalidInput", err)
+	}
+	if err := svc.VerifyEmail(ctx, models.VerifyEmailRequest{Token: second}, testClient); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+}
+
+func TestForgotPasswordDoesNotRevealAccounts(t *testing.T) {
+	store := newMemStore()
+	svc, box := newMailingUserService(store)
+
+	if err := svc.ForgotPassword(context.Background(), models.ForgotPasswordRequest{Email: "nobody@example.com"}, testClient); err != nil {
+		t.Fatalf("unknown email: err = %v, want success", err)
+	}
+	if len(box.messages) != 0 || len(store.outbox) != 0 || len(store.emailTokens) != 0 {
+		t.Error("a reset was started for an address without an account")
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	store := newMemStore()
+	svc, box := newMailingUserService(store)
+	ctx := context.Background()
+
+	user, tokens, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := svc.ForgotPassword(ctx, models.ForgotPasswordRequest{Email: "Ada@Example.com"}, testClient); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	token := linkToken(t, box.last(t, "ada@example.com"), "/auth/reset-password")
+
+	// A rejected password leaves the link usable
+	if err := svc.ResetPassword(ctx, models.ResetPasswordRequest{Token: token, NewPassword: "short"}, testClient); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("weak password: err = %v, want ErrInvalidInput", err)
+	}
+	if err := svc.ResetPassword(ctx, models.ResetPasswordRequest{Token: token, NewPassword: "Battery-Staple-77"}, testClient); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if err := svc.ResetPassword(ctx, models.ResetPasswordRequest{Token: token, NewPassword: "Another-Pass-99"}, testClient); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("reused link: err = %v, want ErrInvalidInput", err)
+	}
+
+	if _, _, err := svc.RefreshTokens(ctx, tokens.RefreshToken, testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("refresh token from before the reset: err = %v, want ErrInvalidCredentials", err)
+	}
+	for _, f := range store.families {
+		if f.UserID == user.ID && (f.RevokeReason == nil || *f.RevokeReason != models.RevokeReasonPasswordReset) {
+			t.Errorf("family %s revoke reason = %v, want %s", f.ID, f.RevokeReason, models.RevokeReasonPasswordReset)
+		}
+	}
+	if _, err := svc.Login(ctx, models.LoginRequest{Email: "ada@example.com", Password: "Correct-Horse-42"}, testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("old password: err = %v, want ErrInvalidCredentials", err)
+	}
+	result, err := svc.Login(ctx, models.LoginRequest{Email: "ada@example.com", Password: "Battery-Staple-77"}, testClient)
+	if err != nil {
+		t.Fatalf("Login with new password: %v", err)
+	}
+	if !result.User.EmailVerified() || !hasAuditEntry(store, user.ID, models.AuditPasswordReset) {
+		t.Error("the reset did not verify the email or was not audited")
+	}
+}
+
+func TestResetLinkExpires(t *testing.T) {
+	store := newMemStore()
+	svc, box := newMailingUserService(store)
+	ctx := context.Background()
+
+	svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	svc.ForgotPassword(ctx, models.ForgotPasswordRequest{Email: "ada@example.com"}, testClient)
+	token := linkToken(t, box.last(t, "ada@example.com"), "/auth/reset-password")
+	for id, et := range store.emailTokens {
+		if et.Purpose == models.EmailTokenResetPassword {
+			if ttl := et.ExpiresAt.Sub(et.CreatedAt); ttl != time.Hour {
+				t.Errorf("link valid for %s, want PasswordResetTTL", ttl)
+			}
+			et.ExpiresAt = time.Now().Add(-time.Second)
+			store.emailTokens[id] = et
+		}
+	}
+
+	if err := svc.ResetPassword(ctx, models.ResetPasswordRequest{Token: token, NewPassword: "Battery-Staple-77"}, testClient); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expired link: err = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestAccountEmailsUseClientLocale(t *testing.T) {
+	store := newMemStore()
+	svc, box := newMailingUserService(store)
+	ctx := context.Background()
+
+	client := testClient
+	client.Locale = "de-CH"
+	if _, _, err := svc.Register(ctx, registerRequest("ada@example.com"), client); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	msg := box.last(t, "ada@example.com")
+	if msg.Subject != "Bestätige deine E-Mail-Adresse" || !strings.Contains(msg.Text, "2 Tage") {
+		t.Errorf("verification email is not in German: %q\n%s", msg.Subject, msg.Text)
+	}
+	if !strings.Contains(msg.HTML, `lang="de"`) || !strings.Contains(msg.HTML, "/auth/verify-email?token=") {
+		t.Errorf("HTML part is missing or does not link to the verification page:\n%s", msg.HTML)
+	}
+
+	client.Locale = "pt-BR"
+	svc.ForgotPassword(ctx, models.ForgotPasswordRequest{Email: "ada@example.com"}, client)
+	if msg := box.last(t, "ada@example.com"); msg.Subject != "Reset your ChainForge password" {
+		t.Errorf("unsupported locale subject = %q, want the English one", msg.Subject)
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"chainforge/internal/database"
+)
+
+// Error kinds returned by the services. Handlers map them to HTTP statuses
+// with errors.Is.
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrForbidden          = errors.New("forbidden")
+	ErrConflict           = errors.New("conflict")
+	ErrInvalidInput       = errors.New("invalid input")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrPlanLimit          = errors.New("plan limit reached")
+	ErrPremiumRequired    = errors.New("premium subscription required")
+	ErrPaymentFailed      = errors.New("payment failed")
+	ErrUnavailable        = errors.New("service unavailable")
+	ErrEmailUnverified    = errors.New("email address not verified")
+	ErrTooManyAttempts    = errors.New("too many attempts")
+	ErrPasswordExpired    = errors.New("password expired")
+)
+
+// Error is a service error carrying a message that is safe to show to users
+type Error struct {
+	Kind    error
+	Message string
+	// RetryAfter is how long the caller should wait before trying again,
+	// set on ErrTooManyAttempts
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// newError builds an Error of the given kind
+func newError(kind error, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// tooManyAttempts builds an ErrTooManyAttempts error asking the caller to
+// wait retryAfter
+func tooManyAttempts(retryAfter time.Duration, format string, args ...interface{}) error {
+	return &Error{Kind: ErrTooManyAttempts, Message: fmt.Sprintf(format, args...), RetryAfter: retryAfter}
+}
+
+// notFound converts database.ErrNotFound into a user-facing not found error
+// for the named resource and passes other errors through
+func notFound(err error, resource string) error {
+	if errors.Is(err, database.ErrNotFound) {
+		return newError(ErrNotFound, "%s not found", resource)
+	}
+	return err
+}
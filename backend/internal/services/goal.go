@@ -0,0 +1,513 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/calendar"
+	"chainforge/internal/database"
+	"chainforge/internal/models"
+)
+
+// recentProgressLimit is how many entries GoalWithProgress includes
+const recentProgressLimit = 10
+
+var goalCategories = map[models.GoalCategory]bool{
+	models.CategoryFitness:      true,
+	models.CategoryHealth:       true,
+	models.CategoryEducation:    true,
+	models.CategoryCareer:       true,
+	models.CategoryFinance:      true,
+	models.CategoryHobbies:      true,
+	models.CategoryRelationship: true,
+	models.CategoryPersonal:     true,
+	models.CategoryOther:        true,
+}
+
+var goalStatuses = map[models.GoalStatus]bool{
+	models.GoalStatusActive:     true,
+	models.GoalStatusInProgress: true,
+	models.GoalStatusCompleted:  true,
+	models.GoalStatusCanceled:   true,
+}
+
+// GoalService manages personal goals and their progress
+type GoalService struct {
+	store     database.Store
+	weekStart time.Weekday
+	now       func() time.Time
+}
+
+// NewGoalService creates a new goal service that counts weeks from weekStart
+func NewGoalService(store database.Store, weekStart time.Weekday) *GoalService {
+	return &GoalService{store: store, weekStart: weekStart, now: time.Now}
+}
+
+// ListGoals returns all goals owned by a user
+func (s *GoalService) ListGoals(ctx context.Context, userID uuid.UUID) ([]models.Goal, error) {
+	return s.store.Goals().ListByUser(ctx, userID)
+}
+
+// ListGoalsWithProgress returns all of a user's goals with recent entries and pacing figures
+func (s *GoalService) ListGoalsWithProgress(ctx context.Context, userID uuid.UUID) ([]models.GoalWithProgress, error) {
+	goals, err := s.store.Goals().ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.GoalWithProgress, 0, len(goals))
+	for i := range goals {
+		view, err := s.withProgress(ctx, s.store, &goals[i])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *view)
+	}
+	return result, nil
+}
+
+// CreateGoal creates a goal, enforcing the active goal limit of the user's plan
+func (s *GoalService) CreateGoal(ctx context.Context, userID uuid.UUID, req models.CreateGoalRequest) (*models.Goal, error) {
+	if !goalCategories[req.Category] {
+		return nil, newError(ErrInvalidInput, "unknown category %q", req.Category)
+	}
+	if req.EndDate != nil && !req.EndDate.After(req.StartDate) {
+		return nil, newError(ErrInvalidInput, "end date must be after the start date")
+	}
+	if err := validateGoalKind(req.Kind, req.Recurrence); err != nil {
+		return nil, err
+	}
+	if err := validateGoalDirection(req); err != nil {
+		return nil, err
+	}
+
+	goal := models.NewGoal(userID, req)
+	goal.StartDate = goal.StartDate.UTC()
+	if goal.EndDate != nil {
+		end := goal.EndDate.UTC()
+		goal.EndDate = &end
+	}
+
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		if err := checkGoalLimit(ctx, tx, userID); err != nil {
+			return err
+		}
+		return tx.Goals().Create(ctx, goal)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return goal, nil
+}
+
+// GetGoal returns a goal the user owns or that is public
+func (s *GoalService) GetGoal(ctx context.Context, userID, goalID uuid.UUID) (*models.Goal, error) {
+	goal, err := s.store.Goals().GetByID(ctx, goalID)
+	if err != nil {
+		return nil, notFound(err, "goal")
+	}
+	if goal.UserID != userID && !goal.IsPublic {
+		return nil, newError(ErrNotFound, "goal not found")
+	}
+	return goal, nil
+}
+
+// GetGoalWithProgress returns a goal with its recent entries and pacing figures
+func (s *GoalService) GetGoalWithProgress(ctx context.Context, userID, goalID uuid.UUID) (*models.GoalWithProgress, error) {
+	goal, err := s.GetGoal(ctx, userID, goalID)
+	if err != nil {
+		return nil, err
+	}
+	return s.withProgress(ctx, s.store, goal)
+}
+
+// UpdateGoal applies a partial update to a goal the user owns
+func (s *GoalService) UpdateGoal(ctx context.Context, userID, goalID uuid.UUID, req models.UpdateGoalRequest) (*models.Goal, error) {
+	if req.Category != nil && !goalCategories[*req.Category] {
+		return nil, newError(ErrInvalidInput, "unknown category %q", *req.Category)
+	}
+	if req.Status != nil && !goalStatuses[*req.Status] {
+		return nil, newError(ErrInvalidInput, "unknown status %q", *req.Status)
+	}
+
+	var goal *models.Goal
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		var err error
+		goal, err = ownedGoal(ctx, tx, userID, goalID)
+		if err != nil {
+			return err
+		}
+
+		if req.Name != nil {
+			goal.Name = *req.Name
+		}
+		if req.Recurrence != nil {
+			if !goal.IsHabit() {
+				return newError(ErrInvalidInput, "only habit goals have a recurrence")
+			}
+			if err := validateRecurrence(req.Recurrence); err != nil {
+				return err
+			}
+			goal.Recurrence = req.Recurrence
+		}
+		if req.Description != nil {
+			goal.Description = req.Description
+		}
+		if req.Unit != nil {
+			goal.Unit = *req.Unit
+		}
+		if req.Category != nil {
+			goal.Category = *req.Category
+		}
+		if req.EndDate != nil {
+			end := req.EndDate.UTC()
+			if !end.After(goal.StartDate) {
+				return newError(ErrInvalidInput, "end date must be after the start date")
+			}
+			goal.EndDate = &end
+		}
+		if req.Punishment != nil {
+			goal.Punishment = req.Punishment
+		}
+		if req.IsPublic != nil {
+			goal.IsPublic = *req.IsPublic
+		}
+		if req.Status != nil {
+			goal.Status = *req.Status
+		}
+		goal.UpdatedAt = s.now().UTC()
+
+		return tx.Goals().Update(ctx, goal)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return goal, nil
+}
+
+// DeleteGoal removes a goal the user owns
+func (s *GoalService) DeleteGoal(ctx context.Context, userID, goalID uuid.UUID) error {
+	return s.store.WithTx(ctx, func(tx database.Store) error {
+		if _, err := ownedGoal(ctx, tx, userID, goalID); err != nil {
+			return err
+		}
+		return tx.Goals().Delete(ctx, goalID)
+	})
+}
+
+// AddProgress records a progress entry and advances the goal's status in the
+// same transaction. It returns the entry and the updated goal. Habits and
+// limits stay in progress whatever is logged.
+func (s *GoalService) AddProgress(ctx context.Context, userID, goalID uuid.UUID, req models.AddProgressRequest) (*models.GoalProgress, *models.Goal, error) {
+	now := s.now().UTC()
+	date := now
+	if req.Date != nil {
+		date = req.Date.UTC()
+	}
+	cal, err := userCalendar(ctx, s.store, userID, s.weekStart)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !date.Before(cal.Day(now).End) {
+		return nil, nil, newError(ErrInvalidInput, "progress cannot be logged for a future date")
+	}
+
+	var entry *models.GoalProgress
+	var goal *models.Goal
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		var err error
+		goal, err = ownedGoal(ctx, tx, userID, goalID)
+		if err != nil {
+			return err
+		}
+		if goal.Status == models.GoalStatusCompleted || goal.Status == models.GoalStatusCanceled {
+			return newError(ErrConflict, "progress cannot be added to a %s goal", goal.Status)
+		}
+
+		entry = models.NewGoalProgress(goal.ID, req.Amount, req.Note, &date)
+		if entry.Date.Before(cal.StartOfDay(goal.StartDate)) {
+			return newError(ErrInvalidInput, "progress cannot be logged before the goal starts")
+		}
+		if err := tx.Goals().AddProgress(ctx, entry); err != nil {
+			return err
+		}
+
+		// Reload to pick up the current_amount maintained by the store
+		goal, err = tx.Goals().GetByID(ctx, goal.ID)
+		if err != nil {
+			return err
+		}
+		goal.Status = models.GoalStatusInProgress
+		if goal.IsCompleted() {
+			goal.Status = models.GoalStatusCompleted
+		}
+		goal.UpdatedAt = now
+		return tx.Goals().Update(ctx, goal)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return entry, goal, nil
+}
+
+// RestartGoal archives a goal's progress and starts it again from today with
+// current_amount back at its initial amount. A goal with an end date keeps its original
+// duration. Restarting a finished goal counts against the plan's goal limit.
+func (s *GoalService) RestartGoal(ctx context.Context, userID, goalID uuid.UUID) (*models.GoalWithProgress, error) {
+	now := s.now().UTC()
+
+	var view *models.GoalWithProgress
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		goal, err := ownedGoal(ctx, tx, userID, goalID)
+		if err != nil {
+			return err
+		}
+		if goal.Status == models.GoalStatusCompleted || goal.Status == models.GoalStatusCanceled {
+			if err := checkGoalLimit(ctx, tx, userID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Goals().ArchiveProgress(ctx, goal.ID, now); err != nil {
+			return err
+		}
+
+		if goal.EndDate != nil {
+			end := now.Add(goal.EndDate.Sub(goal.StartDate))
+			goal.EndDate = &end
+		}
+		goal.StartDate = now
+		goal.CurrentAmount = goal.InitialAmount()
+		goal.Status = models.GoalStatusActive
+		goal.UpdatedAt = now
+		if err := tx.Goals().Update(ctx, goal); err != nil {
+			return err
+		}
+
+		view, err = s.withProgress(ctx, tx, goal)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// ListProgress returns a goal's progress entries, newest first
+func (s *GoalService) ListProgress(ctx context.Context, userID, goalID uuid.UUID, limit int) ([]models.GoalProgress, error) {
+	if _, err := s.GetGoal(ctx, userID, goalID); err != nil {
+		return nil, err
+	}
+	return s.store.Goals().ListProgress(ctx, goalID, limit)
+}
+
+// GetAnalytics computes pacing and consistency figures for one goal
+func (s *GoalService) GetAnalytics(ctx context.Context, userID, goalID uuid.UUID) (*models.GoalAnalytics, error) {
+	goal, err := ownedGoal(ctx, s.store, userID, goalID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.store.Goals().ListProgress(ctx, goal.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := userCalendar(ctx, s.store, userID, s.weekStart)
+	if err != nil {
+		return nil, err
+	}
+	return analyzeGoal(goal, entries, cal, s.now().UTC()), nil
+}
+
+// GetGoalsAnalytics computes analytics for every goal a user owns
+func (s *GoalService) GetGoalsAnalytics(ctx context.Context, userID uuid.UUID) ([]models.GoalAnalytics, error) {
+	goals, err := s.store.Goals().ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cal, err := userCalendar(ctx, s.store, userID, s.weekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	analytics := make([]models.GoalAnalytics, 0, len(goals))
+	for i := range goals {
+		entries, err := s.store.Goals().ListProgress(ctx, goals[i].ID, 0)
+		if err != nil {
+			return nil, err
+		}
+		analytics = append(analytics, *analyzeGoal(&goals[i], entries, cal, now))
+	}
+	return analytics, nil
+}
+
+// withProgress builds the GoalWithProgress view of a goal, with days counted
+// in its owner's timezone. A habit's percentage is how much of its current
+// period is done.
+func (s *GoalService) withProgress(ctx context.Context, store database.Store, goal *models.Goal) (*models.GoalWithProgress, error) {
+	recent, err := store.Goals().ListProgress(ctx, goal.ID, recentProgressLimit)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := userCalendar(ctx, store, goal.UserID, s.weekStart)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	view := &models.GoalWithProgress{
+		Goal:               *goal,
+		RecentProgress:     recent,
+		ProgressPercentage: goal.CalculateProgressPercentage(),
+		DaysRemaining:      goal.DaysRemaining(cal, now),
+		AverageDaily:       averageDaily(goal, cal, now),
+		RequiredDaily:      goal.RequiredDailyProgress(cal, now),
+	}
+	if !goal.IsHabit() {
+		return view, nil
+	}
+
+	entries, err := store.Goals().ListProgress(ctx, goal.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	view.Streak = habitStreak(goal, entries, cal, now)
+	view.ProgressPercentage = habitPercentage(view.Streak)
+	return view, nil
+}
+
+// checkGoalLimit returns ErrPlanLimit when the user's plan allows no more active goals
+func checkGoalLimit(ctx context.Context, store database.Store, userID uuid.UUID) error {
+	sub, err := store.Subscriptions().GetByUser(ctx, userID)
+	if err != nil {
+		return notFound(err, "subscription")
+	}
+	active, err := store.Goals().CountActiveByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	plan := effectivePlan(sub)
+	if !plan.CanCreatePersonalGoal(active) {
+		return newError(ErrPlanLimit, "the free plan allows %d active goals; upgrade to premium for unlimited goals",
+			*plan.GetFeatures().MaxPersonalGoals)
+	}
+	return nil
+}
+
+// ownedGoal loads a goal and checks that userID owns it
+func ownedGoal(ctx context.Context, store database.Store, userID, goalID uuid.UUID) (*models.Goal, error) {
+	goal, err := store.Goals().GetByID(ctx, goalID)
+	if err != nil {
+		return nil, notFound(err, "goal")
+	}
+	if goal.UserID != userID {
+		return nil, newError(ErrNotFound, "goal not found")
+	}
+	return goal, nil
+}
+
+// analyzeGoal derives GoalAnalytics from a goal and its progress entries,
+// with days, weeks and months counted in cal. Measured goals report their
+// latest measurement of each day, week and month rather than a total.
+func analyzeGoal(goal *models.Goal, entries []models.GoalProgress, cal calendar.Calendar, now time.Time) *models.GoalAnalytics {
+	analytics := &models.GoalAnalytics{
+		GoalID:             goal.ID,
+		TotalProgress:      goal.Progress(),
+		ProgressPercentage: goal.CalculateProgressPercentage(),
+		DaysRemaining:      goal.DaysRemaining(cal, now),
+		AverageDaily:       averageDaily(goal, cal, now),
+		RequiredDaily:      goal.RequiredDailyProgress(cal, now),
+		WeeklyProgress:     []models.WeeklyStats{},
+		MonthlyProgress:    []models.MonthlyStats{},
+	}
+
+	daily := dailyAmounts(goal, entries, cal)
+	days := make([]time.Time, 0, len(daily))
+	for day := range daily {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	analytics.DaysActive = len(days)
+
+	add := func(total *float64, amount float64) {
+		if goal.IsMeasured() {
+			*total = amount
+		} else {
+			*total += amount
+		}
+	}
+	weeks := map[string]*models.WeeklyStats{}
+	months := map[string]*models.MonthlyStats{}
+	for _, day := range days {
+		amount := daily[day]
+		if analytics.BestDay == nil || betterDay(goal, amount, analytics.BestDayAmount) {
+			best := day
+			analytics.BestDay = &best
+			analytics.BestDayAmount = amount
+		}
+
+		// Weeks are named after the ISO week their fourth day falls in,
+		// which for weeks starting on Monday is the ISO week itself
+		year, week := cal.AddDays(cal.Week(day).Start, 3).ISOWeek()
+		weekKey := fmt.Sprintf("%d-W%02d", year, week)
+		if weeks[weekKey] == nil {
+			weeks[weekKey] = &models.WeeklyStats{Week: weekKey}
+		}
+		add(&weeks[weekKey].Amount, amount)
+		weeks[weekKey].DaysActive++
+
+		monthKey := day.Format("2006-01")
+		if months[monthKey] == nil {
+			months[monthKey] = &models.MonthlyStats{Month: monthKey}
+		}
+		add(&months[monthKey].Amount, amount)
+		months[monthKey].DaysActive++
+	}
+
+	for _, w := range weeks {
+		analytics.WeeklyProgress = append(analytics.WeeklyProgress, *w)
+	}
+	sort.Slice(analytics.WeeklyProgress, func(i, j int) bool {
+		return analytics.WeeklyProgress[i].Week < analytics.WeeklyProgress[j].Week
+	})
+	for _, m := range months {
+		analytics.MonthlyProgress = append(analytics.MonthlyProgress, *m)
+	}
+	sort.Slice(analytics.MonthlyProgress, func(i, j int) bool {
+		return analytics.MonthlyProgress[i].Month < analytics.MonthlyProgress[j].Month
+	})
+
+	elapsed := elapsedDays(goal, cal, now)
+	analytics.ConsistencyScore = math.Min(float64(analytics.DaysActive)/float64(elapsed)*100, 100)
+
+	if goal.IsHabit() {
+		analytics.Streak = habitStreak(goal, entries, cal, now)
+		analytics.ProgressPercentage = habitPercentage(analytics.Streak)
+		return analytics
+	}
+
+	remaining := goal.Remaining()
+	if remaining > 0 && analytics.AverageDaily > 0 {
+		days := math.Ceil(remaining / analytics.AverageDaily)
+		projected := cal.AddDays(now, int(days))
+		analytics.ProjectedCompletion = &projected
+	}
+
+	return analytics
+}
+
+// averageDaily is the goal's progress per elapsed day since it started
+func averageDaily(goal *models.Goal, cal calendar.Calendar, now time.Time) float64 {
+	return goal.Progress() / float64(elapsedDays(goal, cal, now))
+}
+
+// elapsedDays counts the days in cal since a goal started, including today;
+// at least 1
+func elapsedDays(goal *models.Goal, cal calendar.Calendar, now time.Time) int {
+	return max(cal.DaysBetween(goal.StartDate, now)+1, 1)
+}
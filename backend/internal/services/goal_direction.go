@@ -0,0 +1,90 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"chainforge/internal/calendar"
+	"chainforge/internal/models"
+)
+
+// validateGoalDirection checks that a new goal has the amounts its direction
+// needs and no others. Only decreasing and stay_under goals may aim for 0.
+func validateGoalDirection(req models.CreateGoalRequest) error {
+	habit := req.Kind == models.GoalKindHabit
+	if habit && req.StartAmount != nil {
+		return newError(ErrInvalidInput, "habits have no start amount")
+	}
+	if req.MinAmount != nil && req.Direction != models.DirectionStayWithin {
+		return newError(ErrInvalidInput, "only stay_within goals have a min_amount")
+	}
+
+	switch req.Direction {
+	case "", models.DirectionIncrease:
+		if req.StartAmount != nil {
+			return newError(ErrInvalidInput, "only measured goals have a start_amount")
+		}
+		if req.TargetAmount <= 0 {
+			return newError(ErrInvalidInput, "increasing goals need a target above 0")
+		}
+	case models.DirectionDecrease:
+		if habit {
+			return newError(ErrInvalidInput, "habits cannot decrease; use stay_under to keep each day under a limit")
+		}
+		if req.StartAmount == nil || *req.StartAmount <= req.TargetAmount {
+			return newError(ErrInvalidInput, "decreasing goals need a start_amount above their target")
+		}
+	case models.DirectionStayUnder:
+	case models.DirectionStayWithin:
+		if req.MinAmount == nil || *req.MinAmount >= req.TargetAmount {
+			return newError(ErrInvalidInput, "stay_within goals need a min_amount below their target")
+		}
+	default:
+		return newError(ErrInvalidInput, "unknown goal direction %q", req.Direction)
+	}
+	return nil
+}
+
+// dailyAmounts returns a goal's amount on each day with entries, keyed by the
+// start of the day in cal: the total of the day's entries, or for measured
+// goals the day's latest measurement
+func dailyAmounts(goal *models.Goal, entries []models.GoalProgress, cal calendar.Calendar) map[time.Time]float64 {
+	daily := map[time.Time]float64{}
+	if !goal.IsMeasured() {
+		for _, e := range entries {
+			daily[cal.StartOfDay(e.Date)] += e.Amount
+		}
+		return daily
+	}
+
+	latest := map[time.Time]models.GoalProgress{}
+	for _, e := range entries {
+		day := cal.StartOfDay(e.Date)
+		last, ok := latest[day]
+		if !ok || e.Date.After(last.Date) || (e.Date.Equal(last.Date) && e.CreatedAt.After(last.CreatedAt)) {
+			latest[day] = e
+		}
+	}
+	for day, e := range latest {
+		daily[day] = e.Amount
+	}
+	return daily
+}
+
+// betterDay reports whether amount a makes a better day than b: more
+// progress for increasing goals, a lower measurement for decreasing and
+// stay_under goals, and one nearer the middle of its limits for stay_within
+// goals
+func betterDay(goal *models.Goal, a, b float64) bool {
+	switch goal.Direction {
+	case models.DirectionDecrease, models.DirectionStayUnder:
+		return a < b
+	case models.DirectionStayWithin:
+		mid := goal.TargetAmount / 2
+		if goal.MinAmount != nil {
+			mid = (*goal.MinAmount + goal.TargetAmount) / 2
+		}
+		return math.Abs(a-mid) < math.Abs(b-mid)
+	}
+	return a > b
+}
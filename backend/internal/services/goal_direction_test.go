@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"chainforge/internal/models"
+)
+
+func amount(v float64) *float64 { return &v }
+
+// logMeasurement logs value on the goal daysAgo days ago and returns the goal
+func logMeasurement(t *testing.T, svc *GoalService, goal *models.Goal, value float64, daysAgo int) *models.Goal {
+	t.Helper()
+	date := time.Now().UTC().AddDate(0, 0, -daysAgo)
+	_, updated, err := svc.AddProgress(context.Background(), goal.UserID, goal.ID, models.AddProgressRequest{Amount: value, Date: &date})
+	if err != nil {
+		t.Fatalf("AddProgress(%v): %v", value, err)
+	}
+	return updated
+}
+
+func TestDecreasingGoalCompletesAtTarget(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanPremium)
+	svc := NewGoalService(store, time.Monday)
+
+	req := goalRequest(80)
+	req.Direction, req.StartAmount = models.DirectionDecrease, amount(90)
+	goal, err := svc.CreateGoal(context.Background(), user.ID, req)
+	if err != nil {
+		t.Fatalf("CreateGoal: %v", err)
+	}
+	if goal.CurrentAmount != 90 || goal.CalculateProgressPercentage() != 0 {
+		t.Fatalf("new goal at %v (%v%%), want 90 (0%%)", goal.CurrentAmount, goal.CalculateProgressPercentage())
+	}
+
+	// Entries are measurements: the latest one is where the goal stands
+	logMeasurement(t, svc, goal, 85, 3)
+	goal = logMeasurement(t, svc, goal, 88, 2)
+	if goal.CurrentAmount != 88 || goal.Status != models.GoalStatusInProgress {
+		t.Fatalf("goal at %v (%s), want 88 in progress", goal.CurrentAmount, goal.Status)
+	}
+	if p := goal.CalculateProgressPercentage(); p != 20 {
+		t.Errorf("percentage = %v, want 20", p)
+	}
+	if goal.Remaining() != 8 || goal.Progress() != 2 {
+		t.Errorf("remaining = %v, progress = %v, want 8 and 2", goal.Remaining(), goal.Progress())
+	}
+
+	goal = logMeasurement(t, svc, goal, 79.5, 1)
+	if goal.Status != models.GoalStatusCompleted || goal.CalculateProgressPercentage() != 100 {
+		t.Errorf("goal at %v is %s, want completed", goal.CurrentAmount, goal.Status)
+	}
+}
+
+func TestLimitGoalsStayInProgress(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanPremium)
+	svc := NewGoalService(store, time.Monday)
+
+	req := goalRequest(120)
+	req.Direction = models.DirectionStayUnder
+	goal, err := svc.CreateGoal(context.Background(), user.ID, req)
+	if err != nil {
+		t.Fatalf("CreateGoal: %v", err)
+	}
+
+	goal = logMeasurement(t, svc, goal, 100, 2)
+	if goal.Status != models.GoalStatusInProgress || goal.CalculateProgressPercentage() != 100 {
+		t.Errorf("under the limit: %s at %v%%", goal.Status, goal.CalculateProgressPercentage())
+	}
+	goal = logMeasurement(t, svc, goal, 150, 1)
+	if goal.Status != models.GoalStatusInProgress || goal.CalculateProgressPercentage() != 80 {
+		t.Errorf("over the limit: %s at %v%%, want in progress at 80%%", goal.Status, goal.CalculateProgressPercentage())
+	}
+	if required := goal.RequiredDailyProgress(utc, time.Now()); required != 0 || goal.IsCompleted() {
+		t.Errorf("limit requires %v a day, completed %v", required, goal.IsCompleted())
+	}
+}
+
+func TestMeasuredGoalLogsZero(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanPremium)
+	svc := NewGoalService(store, time.Monday)
+
+	req := goalRequest(60)
+	req.Direction = models.DirectionStayUnder
+	goal, err := svc.CreateGoal(context.Background(), user.ID, req)
+	if err != nil {
+		t.Fatalf("CreateGoal: %v", err)
+	}
+
+	// A zero measurement replaces the earlier one rather than being skipped
+	logMeasurement(t, svc, goal, 90, 2)
+	goal = logMeasurement(t, svc, goal, 0, 1)
+	if goal.CurrentAmount != 0 || goal.CalculateProgressPercentage() != 100 {
+		t.Errorf("goal at %v (%v%%), want 0 (100%%)", goal.CurrentAmount, goal.CalculateProgressPercentage())
+	}
+}
+
+func TestGoalsCanAimForZero(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanPremium)
+	svc := NewGoalService(store, time.Monday)
+
+	req := goalRequest(0)
+	req.Direction, req.StartAmount = models.DirectionDecrease, amount(20)
+	quit, err := svc.CreateGoal(context.Background(), user.ID, req)
+	if err != nil {
+		t.Fatalf("CreateGoal decreasing to 0: %v", err)
+	}
+	quit = logMeasurement(t, svc, quit, 5, 2)
+	if quit.Status != models.GoalStatusInProgress || quit.CalculateProgressPercentage() != 75 {
+		t.Errorf("goal at %v is %s at %v%%, want in progress at 75%%", quit.CurrentAmount, quit.Status, quit.CalculateProgressPercentage())
+	}
+	if quit = logMeasurement(t, svc, quit, 0, 1); quit.Status != models.GoalStatusCompleted {
+		t.Errorf("goal at 0 is %s, want completed", quit.Status)
+	}
+
+	req = goalRequest(0)
+	req.Kind, req.Recurrence = models.GoalKindHabit, &models.Recurrence{Frequency: models.RecurDaily}
+	req.Direction = models.DirectionStayUnder
+	if _, err := svc.CreateGoal(context.Background(), user.ID, req); err != nil {
+		t.Errorf("CreateGoal staying under 0: %v", err)
+	}
+}
+
+func TestCreateGoalValidatesDirection(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanPremium)
+	svc := NewGoalService(store, time.Monday)
+
+	invalid := map[string]func(*models.CreateGoalRequest){
+		"start amount to increase": func(r *models.CreateGoalRequest) { r.StartAmount = amount(1) },
+		"decrease without start":   func(r *models.CreateGoalRequest) { r.Direction = models.DirectionDecrease },
+		"decrease from below": func(r *models.CreateGoalRequest) {
+			r.Direction, r.StartAmount = models.DirectionDecrease, amount(5)
+		},
+		"range without minimum": func(r *models.CreateGoalRequest) { r.Direction = models.DirectionStayWithin },
+		"empty range": func(r *models.CreateGoalRequest) {
+			r.Direction, r.MinAmount = models.DirectionStayWithin, amount(10)
+		},
+		"minimum under a cap": func(r *models.CreateGoalRequest) {
+			r.Direction, r.MinAmount = models.DirectionStayUnder, amount(1)
+		},
+		"decreasing habit": func(r *models.CreateGoalRequest) {
+			r.Kind, r.Recurrence = models.GoalKindHabit, &models.Recurrence{Frequency: models.RecurDaily}
+			r.Direction, r.StartAmount = models.DirectionDecrease, amount(20)
+		},
+		"unknown direction": func(r *models.CreateGoalRequest) { r.Direction = "sideways" },
+		"increase to zero":  func(r *models.CreateGoalRequest) { r.TargetAmount = 0 },
+		"range up to zero": func(r *models.CreateGoalRequest) {
+			r.Direction, r.MinAmount, r.TargetAmount = models.DirectionStayWithin, amount(0), 0
+		},
+	}
+	for name, edit := range invalid {
+		req := goalRequest(10)
+		edit(&req)
+		if _, err := svc.CreateGoal(context.Background(), user.ID, req); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: err = %v, want ErrInvalidInput", name, err)
+		}
+	}
+}
+
+func TestAnalyzeMeasuredGoal(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	goal := &models.Goal{
+		Direction:     models.DirectionDecrease,
+		TargetAmount:  80,
+		StartAmount:   amount(90),
+		CurrentAmount: 86,
+		StartDate:     time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	entries := []models.GoalProgress{
+		{Amount: 86, Date: time.Date(2024, 3, 9, 9, 0, 0, 0, time.UTC)},
+		{Amount: 88, Date: time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)},
+		{Amount: 89, Date: time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC)},
+	}
+
+	a := analyzeGoal(goal, entries, utc, now)
+	if a.TotalProgress != 4 || a.AverageDaily != 0.4 {
+		t.Errorf("total = %v, average = %v, want 4 and 0.4", a.TotalProgress, a.AverageDaily)
+	}
+	if a.BestDayAmount != 86 || !a.BestDay.Equal(time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("best day = %v (%v), want 2024-03-09 (86)", a.BestDay, a.BestDayAmount)
+	}
+	if len(a.WeeklyProgress) != 2 || a.WeeklyProgress[0].Amount != 88 || a.MonthlyProgress[0].Amount != 86 {
+		t.Errorf("weeks = %+v, months = %+v, want the latest measurement of each", a.WeeklyProgress, a.MonthlyProgress)
+	}
+	if want := time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC); a.ProjectedCompletion == nil || !a.ProjectedCompletion.Equal(want) {
+		t.Errorf("ProjectedCompletion = %v, want %v", a.ProjectedCompletion, want)
+	}
+}
+
+func TestLimitHabitStreak(t *testing.T) {
+	goal := habit(time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), models.Recurrence{Frequency: models.RecurDaily})
+	goal.Direction, goal.MinAmount, goal.TargetAmount = models.DirectionStayWithin, amount(7), 9
+	at := func(day, hour int, value float64) models.GoalProgress {
+		return models.GoalProgress{Amount: value, Date: time.Date(2026, 3, day, hour, 0, 0, 0, time.UTC)}
+	}
+
+	// A day counts when its latest measurement keeps within the limits
+	entries := []models.GoalProgress{at(9, 8, 8), at(10, 8, 6), at(10, 22, 7.5)}
+	want := models.HabitStreak{CurrentStreak: 3, LongestStreak: 3, PeriodDone: 0, PeriodRequired: 1}
+	if got := habitStreak(goal, append(entries, at(11, 8, 8)), utc, habitNow); *got != want {
+		t.Fatalf("streak = %+v, want %+v", *got, want)
+	}
+
+	// Going over the limits or measuring nothing breaks the streak
+	for _, entries := range [][]models.GoalProgress{append(entries, at(11, 8, 10)), entries} {
+		if got := habitStreak(goal, entries, utc, habitNow); got.CurrentStreak != 0 || got.LongestStreak != 2 {
+			t.Errorf("streak = %+v, want 0 and 2", *got)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"chainforge/internal/models"
+)
+
+func goalRequest(target float64) models.CreateGoalRequest {
+	return models.CreateGoalRequest{
+		Name:         "Read books",
+		TargetAmount: target,
+		Unit:         "books",
+		Category:     models.CategoryEducation,
+		StartDate:    time.Now().UTC().AddDate(0, 0, -7),
+	}
+}
+
+func TestCreateGoalEnforcesFreePlanLimit(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanFree)
+	svc := NewGoalService(store, time.Monday)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := svc.CreateGoal(ctx, user.ID, goalRequest(10)); err != nil {
+			t.Fatalf("goal %d: %v", i+1, err)
+		}
+	}
+	if _, err := svc.CreateGoal(ctx, user.ID, goalRequest(10)); !errors.Is(err, ErrPlanLimit) {
+		t.Fatalf("fourth goal: err = %v, want ErrPlanLimit", err)
+	}
+
+	premium := seedUser(t, store, models.PlanPremium)
+	for i := 0; i < 4; i++ {
+		if _, err := svc.CreateGoal(ctx, premium.ID, goalRequest(10)); err != nil {
+			t.Fatalf("premium goal %d: %v", i+1, err)
+		}
+	}
+}
+
+func TestAddProgressAdvancesGoalStatus(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanFree)
+	svc := NewGoalService(store, time.Monday)
+	ctx := context.Background()
+
+	goal, err := svc.CreateGoal(ctx, user.ID, goalRequest(5))
+	if err != nil {
+		t.Fatalf("CreateGoal: %v", err)
+	}
+
+	_, updated, err := svc.AddProgress(ctx, user.ID, goal.ID, models.AddProgressRequest{Amount: 2})
+	if err != nil {
+		t.Fatalf("AddProgress: %v", err)
+	}
+	if updated.CurrentAmount != 2 || updated.Status != models.GoalStatusInProgress {
+		t.Fatalf("after first entry: amount=%v status=%s", updated.CurrentAmount, updated.Status)
+	}
+
+	_, updated, err = svc.AddProgress(ctx, user.ID, goal.ID, models.AddProgressRequest{Amount: 3})
+	if err != nil {
+		t.Fatalf("AddProgress: %v", err)
+	}
+	if updated.Status != models.GoalStatusCompleted {
+		t.Fatalf("status = %s, want completed", updated.Status)
+	}
+
+	if _, _, err := svc.AddProgress(ctx, user.ID, goal.ID, models.AddProgressRequest{Amount: 1}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("progress on completed goal: err = %v, want ErrConflict", err)
+	}
+}
+
+func TestRestartGoalArchivesProgress(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanFree)
+	svc := NewGoalService(store, time.Monday)
+	ctx := context.Background()
+
+	req := goalRequest(5)
+	end := req.StartDate.AddDate(0, 0, 30)
+	req.EndDate = &end
+	goal, err := svc.CreateGoal(ctx, user.ID, req)
+	if err != nil {
+		t.Fatalf("CreateGoal: %v", err)
+	}
+	if _, _, err := svc.AddProgress(ctx, user.ID, goal.ID, models.AddProgressRequest{Amount: 5}); err != nil {
+		t.Fatalf("AddProgress: %v", err)
+	}
+
+	// A failed archive leaves the goal untouched
+	store.failOn = "Goals.ArchiveProgress"
+	if _, err := svc.RestartGoal(ctx, user.ID, goal.ID); err == nil {
+		t.Fatal("expected injected failure")
+	}
+	store.failOn = ""
+	if g, _ := store.Goals().GetByID(ctx, goal.ID); g.CurrentAmount != 5 || g.Status != models.GoalStatusCompleted {
+		t.Fatalf("goal changed by failed restart: %+v", g)
+	}
+
+	restarted, err := svc.RestartGoal(ctx, user.ID, goal.ID)
+	if err != nil {
+		t.Fatalf("RestartGoal: %v", err)
+	}
+	g := restarted.Goal
+	if g.CurrentAmount != 0 || g.Status != models.GoalStatusActive || len(restarted.RecentProgress) != 0 {
+		t.Fatalf("restarted goal = amount %v status %s entries %d", g.CurrentAmount, g.Status, len(restarted.RecentProgress))
+	}
+	if time.Since(g.StartDate) > time.Minute || g.EndDate.Sub(g.StartDate) != 30*24*time.Hour {
+		t.Errorf("dates = %v - %v, want a 30 day run starting now", g.StartDate, g.EndDate)
+	}
+	if len(store.archived) != 1 {
+		t.Errorf("archived entries = %d, want 1", len(store.archived))
+	}
+
+	other := seedUser(t, store, models.PlanFree)
+	if _, err := svc.RestartGoal(ctx, other.ID, goal.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("restart by other user: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGoalsAreScopedToTheirOwner(t *testing.T) {
+	store := newMemStore()
+	owner := seedUser(t, store, models.PlanFree)
+	other := seedUser(t, store, models.PlanFree)
+	svc := NewGoalService(store, time.Monday)
+	ctx := context.Background()
+
+	goal, err := svc.CreateGoal(ctx, owner.ID, goalRequest(5))
+	if err != nil {
+		t.Fatalf("CreateGoal: %v", err)
+	}
+
+	if _, err := svc.GetGoal(ctx, other.ID, goal.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("private goal: err = %v, want ErrNotFound", err)
+	}
+	if err := svc.DeleteGoal(ctx, other.ID, goal.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("delete by other user: err = %v, want ErrNotFound", err)
+	}
+
+	public := true
+	if _, err := svc.UpdateGoal(ctx, owner.ID, goal.ID, models.UpdateGoalRequest{IsPublic: &public}); err != nil {
+		t.Fatalf("UpdateGoal: %v", err)
+	}
+	if _, err := svc.GetGoal(ctx, other.ID, goal.ID); err != nil {
+		t.Fatalf("public goal: %v", err)
+	}
+}
+
+func TestAnalyzeGoal(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	goal := &models.Goal{
+		TargetAmount:  100,
+		CurrentAmount: 30,
+		StartDate:     time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	entries := []models.GoalProgress{
+		{Amount: 10, Date: time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC)},
+		{Amount: 5, Date: time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)},
+		{Amount: 15, Date: time.Date(2024, 3, 9, 9, 0, 0, 0, time.UTC)},
+	}
+
+	a := analyzeGoal(goal, entries, utc, now)
+	if a.DaysActive != 2 {
+		t.Errorf("DaysActive = %d, want 2", a.DaysActive)
+	}
+	if a.AverageDaily != 3 {
+		t.Errorf("AverageDaily = %v, want 3", a.AverageDaily)
+	}
+	if a.ConsistencyScore != 20 {
+		t.Errorf("ConsistencyScore = %v, want 20", a.ConsistencyScore)
+	}
+	if a.BestDayAmount != 15 || !a.BestDay.Equal(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("best day = %v (%v), want 2024-03-02 (15)", a.BestDay, a.BestDayAmount)
+	}
+	if len(a.WeeklyProgress) != 2 || len(a.MonthlyProgress) != 1 {
+		t.Errorf("weeks=%d months=%d, want 2 and 1", len(a.WeeklyProgress), len(a.MonthlyProgress))
+	}
+	if want := time.Date(2024, 4, 3, 0, 0, 0, 0, time.UTC); a.ProjectedCompletion == nil || !a.ProjectedCompletion.Equal(want) {
+		t.Errorf("ProjectedCompletion = %v, want %v", a.ProjectedCompletion, want)
+	}
+}
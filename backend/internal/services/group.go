@@ -0,0 +1,994 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/calendar"
+	"chainforge/internal/database"
+	"chainforge/internal/models"
+)
+
+// Group goal period types
+const (
+	PeriodWeekly  = "weekly"
+	PeriodMonthly = "monthly"
+)
+
+// completionBonus is the leaderboard bonus for finishing a period's target
+const completionBonus = 50
+
+// inviteCodeAttempts bounds retries when a new invite code collides
+const inviteCodeAttempts = 3
+
+// GroupService manages groups, memberships, group goals and their periods
+type GroupService struct {
+	store     database.Store
+	weekStart time.Weekday
+	now       func() time.Time
+}
+
+// NewGroupService creates a new group service whose weekly goals run from
+// weekStart
+func NewGroupService(store database.Store, weekStart time.Weekday) *GroupService {
+	return &GroupService{store: store, weekStart: weekStart, now: time.Now}
+}
+
+// ListGroups returns the groups a user belongs to
+func (s *GroupService) ListGroups(ctx context.Context, userID uuid.UUID) ([]models.GroupWithMembers, error) {
+	groups, err := s.store.Groups().ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.GroupWithMembers, 0, len(groups))
+	for i := range groups {
+		view, err := groupWithMembers(ctx, s.store, &groups[i], userID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *view)
+	}
+	return result, nil
+}
+
+// CreateGroup creates a group owned by userID. Groups are a premium feature
+// for users with a verified email address.
+func (s *GroupService) CreateGroup(ctx context.Context, userID uuid.UUID, req models.CreateGroupRequest) (*models.GroupWithMembers, error) {
+	group := models.NewGroup(req.Name, req.Description, req.MaxMembers, req.IsPrivate, userID)
+
+	var view *models.GroupWithMembers
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		if err := requireVerifiedEmail(ctx, tx, userID, "create groups"); err != nil {
+			return err
+		}
+		if err := requirePremium(ctx, tx, userID); err != nil {
+			return err
+		}
+		if err := tx.Groups().Create(ctx, group); err != nil {
+			return err
+		}
+		if err := tx.Groups().AddMember(ctx, models.NewGroupMember(group.ID, userID, models.RoleOwner)); err != nil {
+			return err
+		}
+
+		var err error
+		view, err = groupWithMembers(ctx, tx, group, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// JoinGroup adds userID to the group with the given invite code
+func (s *GroupService) JoinGroup(ctx context.Context, userID uuid.UUID, inviteCode string) (*models.GroupWithMembers, error) {
+	var view *models.GroupWithMembers
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		if err := requireVerifiedEmail(ctx, tx, userID, "join groups"); err != nil {
+			return err
+		}
+		if err := requirePremium(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		group, err := tx.Groups().GetByInviteCode(ctx, inviteCode)
+		if err != nil {
+			return notFound(err, "group")
+		}
+		if group.Status != models.GroupStatusActive {
+			return newError(ErrConflict, "this group is no longer accepting members")
+		}
+
+		existing, err := tx.Groups().GetMember(ctx, group.ID, userID)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return err
+		}
+		if existing != nil && existing.IsActive {
+			return newError(ErrConflict, "you are already a member of this group")
+		}
+
+		count, err := tx.Groups().CountMembers(ctx, group.ID)
+		if err != nil {
+			return err
+		}
+		if count >= group.MaxMembers {
+			return newError(ErrConflict, "this group is full")
+		}
+
+		now := s.now().UTC()
+		if existing != nil {
+			existing.IsActive = true
+			existing.Role = models.RoleMember
+			existing.JoinedAt = now
+			existing.UpdatedAt = now
+			err = tx.Groups().UpdateMember(ctx, existing)
+		} else {
+			err = tx.Groups().AddMember(ctx, models.NewGroupMember(group.ID, userID, models.RoleMember))
+		}
+		if err != nil {
+			return err
+		}
+
+		view, err = groupWithMembers(ctx, tx, group, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// GetGroup returns a group the user belongs to
+func (s *GroupService) GetGroup(ctx context.Context, userID, groupID uuid.UUID) (*models.GroupWithMembers, error) {
+	if _, err := activeMember(ctx, s.store, groupID, userID); err != nil {
+		return nil, err
+	}
+	group, err := s.store.Groups().GetByID(ctx, groupID)
+	if err != nil {
+		return nil, notFound(err, "group")
+	}
+	return groupWithMembers(ctx, s.store, group, userID)
+}
+
+// UpdateGroup applies a partial update. Only admins may change settings.
+func (s *GroupService) UpdateGroup(ctx context.Context, userID, groupID uuid.UUID, req models.UpdateGroupRequest) (*models.Group, error) {
+	var group *models.Group
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		member, err := activeMember(ctx, tx, groupID, userID)
+		if err != nil {
+			return err
+		}
+		if !member.CanManageGroup() {
+			return newError(ErrForbidden, "only group admins can change group settings")
+		}
+
+		group, err = tx.Groups().GetByID(ctx, groupID)
+		if err != nil {
+			return notFound(err, "group")
+		}
+
+		if req.Name != nil {
+			group.Name = *req.Name
+		}
+		if req.Description != nil {
+			group.Description = req.Description
+		}
+		if req.MaxMembers != nil {
+			count, err := tx.Groups().CountMembers(ctx, groupID)
+			if err != nil {
+				return err
+			}
+			if *req.MaxMembers < count {
+				return newError(ErrInvalidInput, "max members cannot be lower than the current member count (%d)", count)
+			}
+			group.MaxMembers = *req.MaxMembers
+		}
+		if req.IsPrivate != nil {
+			group.IsPrivate = *req.IsPrivate
+		}
+		group.UpdatedAt = s.now().UTC()
+
+		return tx.Groups().Update(ctx, group)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// RegenerateInviteCode gives a group a fresh invite code. Only admins may
+// do this; the old code stops working immediately.
+func (s *GroupService) RegenerateInviteCode(ctx context.Context, userID, groupID uuid.UUID) (string, error) {
+	var code string
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		member, err := activeMember(ctx, tx, groupID, userID)
+		if err != nil {
+			return err
+		}
+		if !member.CanManageMembers() {
+			return newError(ErrForbidden, "only group admins can change the invite code")
+		}
+
+		group, err := tx.Groups().GetByID(ctx, groupID)
+		if err != nil {
+			return notFound(err, "group")
+		}
+
+		for attempt := 0; attempt < inviteCodeAttempts; attempt++ {
+			group.RotateInviteCode()
+			err = tx.Groups().Update(ctx, group)
+			if !errors.Is(err, database.ErrDuplicate) {
+				break
+			}
+		}
+		code = group.InviteCode
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// DeleteGroup removes a group. Only the owner may delete it.
+func (s *GroupService) DeleteGroup(ctx context.Context, userID, groupID uuid.UUID) error {
+	return s.store.WithTx(ctx, func(tx database.Store) error {
+		member, err := activeMember(ctx, tx, groupID, userID)
+		if err != nil {
+			return err
+		}
+		if !member.IsOwner() {
+			return newError(ErrForbidden, "only the group owner can delete the group")
+		}
+		return tx.Groups().Delete(ctx, groupID)
+	})
+}
+
+// LeaveGroup removes userID from a group. An owner leaving hands ownership
+// to the longest-standing admin, or member if there are no admins; the last
+// member leaving deletes the group.
+func (s *GroupService) LeaveGroup(ctx context.Context, userID, groupID uuid.UUID) error {
+	return s.store.WithTx(ctx, func(tx database.Store) error {
+		member, err := activeMember(ctx, tx, groupID, userID)
+		if err != nil {
+			return err
+		}
+
+		members, err := tx.Groups().ListMembers(ctx, groupID)
+		if err != nil {
+			return err
+		}
+		if len(members) <= 1 {
+			return tx.Groups().Delete(ctx, groupID)
+		}
+
+		now := s.now().UTC()
+		if member.IsOwner() {
+			successor := nextOwner(members, userID)
+			successor.Role = models.RoleOwner
+			successor.UpdatedAt = now
+			if err := tx.Groups().UpdateMember(ctx, successor); err != nil {
+				return err
+			}
+		}
+
+		member.IsActive = false
+		member.UpdatedAt = now
+		return tx.Groups().UpdateMember(ctx, member)
+	})
+}
+
+// ListMembers returns the active members of a group with their profiles
+func (s *GroupService) ListMembers(ctx context.Context, userID, groupID uuid.UUID) ([]models.GroupMemberProfile, error) {
+	if _, err := activeMember(ctx, s.store, groupID, userID); err != nil {
+		return nil, err
+	}
+	return memberProfiles(ctx, s.store, groupID)
+}
+
+// UpdateMemberRole changes a member's role. Only the owner can grant or
+// revoke admin rights; assigning the owner role transfers ownership.
+func (s *GroupService) UpdateMemberRole(ctx context.Context, userID, groupID, memberID uuid.UUID, role models.MemberRole) (*models.GroupMember, error) {
+	switch role {
+	case models.RoleOwner, models.RoleAdmin, models.RoleMember:
+	default:
+		return nil, newError(ErrInvalidInput, "unknown role %q", role)
+	}
+
+	var target *models.GroupMember
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		actor, err := activeMember(ctx, tx, groupID, userID)
+		if err != nil {
+			return err
+		}
+		if !actor.IsOwner() {
+			return newError(ErrForbidden, "only the group owner can change member roles")
+		}
+		if memberID == userID {
+			return newError(ErrInvalidInput, "you cannot change your own role")
+		}
+
+		target, err = tx.Groups().GetMember(ctx, groupID, memberID)
+		if err != nil || !target.IsActive {
+			return newError(ErrNotFound, "member not found")
+		}
+
+		now := s.now().UTC()
+		if role == models.RoleOwner {
+			actor.Role = models.RoleAdmin
+			actor.UpdatedAt = now
+			if err := tx.Groups().UpdateMember(ctx, actor); err != nil {
+				return err
+			}
+		}
+
+		target.Role = role
+		target.UpdatedAt = now
+		return tx.Groups().UpdateMember(ctx, target)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// RemoveMember removes another member from a group. Admins can remove
+// members; only the owner can remove admins. The owner cannot be removed.
+func (s *GroupService) RemoveMember(ctx context.Context, userID, groupID, memberID uuid.UUID) error {
+	if memberID == userID {
+		return newError(ErrInvalidInput, "leave the group instead of removing yourself")
+	}
+
+	return s.store.WithTx(ctx, func(tx database.Store) error {
+		actor, err := activeMember(ctx, tx, groupID, userID)
+		if err != nil {
+			return err
+		}
+		if !actor.CanManageMembers() {
+			return newError(ErrForbidden, "only group admins can remove members")
+		}
+
+		target, err := tx.Groups().GetMember(ctx, groupID, memberID)
+		if err != nil || !target.IsActive {
+			return newError(ErrNotFound, "member not found")
+		}
+		if target.IsOwner() {
+			return newError(ErrForbidden, "the group owner cannot be removed")
+		}
+		if target.IsAdmin() && !actor.IsOwner() {
+			return newError(ErrForbidden, "only the group owner can remove admins")
+		}
+
+		target.IsActive = false
+		target.UpdatedAt = s.now().UTC()
+		return tx.Groups().UpdateMember(ctx, target)
+	})
+}
+
+// ListGroupGoals returns a group's goals with current period progress
+func (s *GroupService) ListGroupGoals(ctx context.Context, userID, groupID uuid.UUID) ([]models.GroupGoalWithProgress, error) {
+	if _, err := activeMember(ctx, s.store, groupID, userID); err != nil {
+		return nil, err
+	}
+
+	goals, err := s.store.Groups().ListGoals(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.GroupGoalWithProgress, 0, len(goals))
+	for i := range goals {
+		view, err := s.groupGoalWithProgress(ctx, s.store, &goals[i])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *view)
+	}
+	return result, nil
+}
+
+// CreateGroupGoal creates a group goal and opens its first period
+func (s *GroupService) CreateGroupGoal(ctx context.Context, userID, groupID uuid.UUID, req models.CreateGroupGoalRequest) (*models.GroupGoalWithProgress, error) {
+	if req.PeriodType != PeriodWeekly && req.PeriodType != PeriodMonthly {
+		return nil, newError(ErrInvalidInput, "period type must be weekly or monthly")
+	}
+
+	goal := models.NewGroupGoal(groupID, req.Name, req.Unit, req.PeriodType, req.Description, userID)
+
+	var view *models.GroupGoalWithProgress
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		member, err := activeMember(ctx, tx, groupID, userID)
+		if err != nil {
+			return err
+		}
+		if !member.CanManageGroup() {
+			return newError(ErrForbidden, "only group admins can create group goals")
+		}
+
+		if err := tx.Groups().CreateGoal(ctx, goal); err != nil {
+			return err
+		}
+		cal, err := s.goalCalendar(ctx, tx, goal)
+		if err != nil {
+			return err
+		}
+		period := periodOf(cal, goal.PeriodType, s.now())
+		if err := tx.Groups().CreatePeriod(ctx, models.NewGroupGoalPeriod(goal.ID, period.Start.UTC(), period.End.UTC())); err != nil {
+			return err
+		}
+
+		view, err = s.groupGoalWithProgress(ctx, tx, goal)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// GetGroupGoal returns a group goal with current period progress
+func (s *GroupService) GetGroupGoal(ctx context.Context, userID, groupID, goalID uuid.UUID) (*models.GroupGoalWithProgress, error) {
+	if _, err := activeMember(ctx, s.store, groupID, userID); err != nil {
+		return nil, err
+	}
+	goal, err := groupGoal(ctx, s.store, groupID, goalID)
+	if err != nil {
+		return nil, err
+	}
+	return s.groupGoalWithProgress(ctx, s.store, goal)
+}
+
+// UpdateGroupGoal applies a partial update to a group goal. Reactivating a
+// goal opens a new period if it has none.
+func (s *GroupService) UpdateGroupGoal(ctx context.Context, userID, groupID, goalID uuid.UUID, req models.UpdateGroupGoalRequest) (*models.GroupGoal, error) {
+	var goal *models.GroupGoal
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		member, err := activeMember(ctx, tx, groupID, userID)
+		if err != nil {
+			return err
+		}
+		if !member.CanManageGroup() {
+			return newError(ErrForbidden, "only group admins can change group goals")
+		}
+
+		goal, err = groupGoal(ctx, tx, groupID, goalID)
+		if err != nil {
+			return err
+		}
+
+		if req.Name != nil {
+			goal.Name = *req.Name
+		}
+		if req.Description != nil {
+			goal.Description = req.Description
+		}
+		if req.Unit != nil {
+			goal.Unit = *req.Unit
+		}
+		if req.IsActive != nil {
+			goal.IsActive = *req.IsActive
+		}
+		goal.UpdatedAt = s.now().UTC()
+
+		if err := tx.Groups().UpdateGoal(ctx, goal); err != nil {
+			return err
+		}
+
+		if goal.IsActive {
+			_, err := tx.Groups().GetActivePeriod(ctx, goal.ID)
+			if errors.Is(err, database.ErrNotFound) {
+				cal, err := s.goalCalendar(ctx, tx, goal)
+				if err != nil {
+					return err
+				}
+				period := periodOf(cal, goal.PeriodType, s.now())
+				return tx.Groups().CreatePeriod(ctx, models.NewGroupGoalPeriod(goal.ID, period.Start.UTC(), period.End.UTC()))
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return goal, nil
+}
+
+// DeleteGroupGoal removes a group goal with its periods and progress
+func (s *GroupService) DeleteGroupGoal(ctx context.Context, userID, groupID, goalID uuid.UUID) error {
+	return s.store.WithTx(ctx, func(tx database.Store) error {
+		member, err := activeMember(ctx, tx, groupID, userID)
+		if err != nil {
+			return err
+		}
+		if !member.CanManageGroup() {
+			return newError(ErrForbidden, "only group admins can delete group goals")
+		}
+		if _, err := groupGoal(ctx, tx, groupID, goalID); err != nil {
+			return err
+		}
+		return tx.Groups().DeleteGoal(ctx, goalID)
+	})
+}
+
+// SetTarget sets the user's own target for the current period of a group
+// goal. Any penalty carried over from the previous period is added on top.
+func (s *GroupService) SetTarget(ctx context.Context, userID, groupID, goalID uuid.UUID, target float64) (*models.GroupGoalProgress, error) {
+	if target <= 0 {
+		return nil, newError(ErrInvalidInput, "target must be greater than zero")
+	}
+
+	var progress *models.GroupGoalProgress
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		period, err := s.activePeriod(ctx, tx, userID, groupID, goalID)
+		if err != nil {
+			return err
+		}
+
+		progress, err = tx.Groups().GetProgress(ctx, period.ID, userID)
+		if errors.Is(err, database.ErrNotFound) {
+			progress = models.NewGroupGoalProgress(period.ID, userID, target, 0)
+			return tx.Groups().CreateProgress(ctx, progress)
+		}
+		if err != nil {
+			return err
+		}
+
+		progress.TargetAmount = target + progress.PenaltyCarryOver
+		progress.IsCompleted = progress.CurrentAmount >= progress.TargetAmount
+		progress.UpdatedAt = s.now().UTC()
+		return tx.Groups().UpdateProgress(ctx, progress)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// AddProgress logs progress against the user's target for the current
+// period. The running total, the daily entry log and the completion flag
+// are updated together in one transaction.
+func (s *GroupService) AddProgress(ctx context.Context, userID, groupID, goalID uuid.UUID, req models.AddGroupProgressRequest) (*models.GroupGoalProgress, error) {
+	now := s.now().UTC()
+	date := now
+	if req.Date != nil {
+		date = req.Date.UTC()
+	}
+
+	var progress *models.GroupGoalProgress
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		period, err := s.activePeriod(ctx, tx, userID, groupID, goalID)
+		if err != nil {
+			return err
+		}
+		if date.Before(period.StartDate) || !date.Before(period.EndDate) {
+			return newError(ErrInvalidInput, "progress must be dated within the current period (%s to %s)",
+				period.StartDate.Format("2006-01-02"), period.EndDate.Format("2006-01-02"))
+		}
+
+		progress, err = tx.Groups().GetProgress(ctx, period.ID, userID)
+		if errors.Is(err, database.ErrNotFound) {
+			return newError(ErrConflict, "set your target for this period before logging progress")
+		}
+		if err != nil {
+			return err
+		}
+
+		var entries []models.DailyEntry
+		if err := json.Unmarshal([]byte(progress.DailyEntries), &entries); err != nil {
+			return fmt.Errorf("failed to decode daily entries: %w", err)
+		}
+		entries = append(entries, models.DailyEntry{Date: date, Amount: req.Amount, Note: req.Note})
+		encoded, err := json.Marshal(entries)
+		if err != nil {
+			return fmt.Errorf("failed to encode daily entries: %w", err)
+		}
+
+		progress.CurrentAmount += req.Amount
+		progress.DailyEntries = string(encoded)
+		progress.IsCompleted = progress.CurrentAmount >= progress.TargetAmount
+		progress.UpdatedAt = now
+		return tx.Groups().UpdateProgress(ctx, progress)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// GetLeaderboard ranks members by their progress in the current period
+func (s *GroupService) GetLeaderboard(ctx context.Context, userID, groupID, goalID uuid.UUID) (*models.Leaderboard, error) {
+	if _, err := activeMember(ctx, s.store, groupID, userID); err != nil {
+		return nil, err
+	}
+	goal, err := groupGoal(ctx, s.store, groupID, goalID)
+	if err != nil {
+		return nil, err
+	}
+	view, err := s.groupGoalWithProgress(ctx, s.store, goal)
+	if err != nil {
+		return nil, err
+	}
+
+	board := &models.Leaderboard{
+		GroupID:   groupID,
+		Rankings:  []models.LeaderboardEntry{},
+		UpdatedAt: s.now().UTC(),
+	}
+	if view.CurrentPeriod == nil {
+		return board, nil
+	}
+	board.PeriodID = view.CurrentPeriod.ID
+
+	// MemberProgress is already sorted best first
+	for i, m := range view.MemberProgress {
+		points := int(math.Round(m.ProgressPercentage))
+		if m.IsCompleted {
+			points += completionBonus
+		}
+		board.Rankings = append(board.Rankings, models.LeaderboardEntry{
+			Rank:               i + 1,
+			UserID:             m.UserID,
+			User:               m.User,
+			CurrentAmount:      m.CurrentAmount,
+			TargetAmount:       m.TargetAmount,
+			ProgressPercentage: m.ProgressPercentage,
+			PenaltyCarryOver:   m.PenaltyCarryOver,
+			IsCompleted:        m.IsCompleted,
+			Points:             points,
+		})
+	}
+	return board, nil
+}
+
+// GetGroupsAnalytics returns current period progress for every goal in
+// every group the user belongs to
+func (s *GroupService) GetGroupsAnalytics(ctx context.Context, userID uuid.UUID) ([]models.GroupGoalWithProgress, error) {
+	groups, err := s.store.Groups().ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.GroupGoalWithProgress{}
+	for _, g := range groups {
+		goals, err := s.store.Groups().ListGoals(ctx, g.ID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range goals {
+			view, err := s.groupGoalWithProgress(ctx, s.store, &goals[i])
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, *view)
+		}
+	}
+	return result, nil
+}
+
+// ProcessPeriodTransitions closes every expired period and opens the next
+// one for active goals. Members keep their base target and any shortfall is
+// carried over as a penalty. Each period is handled in its own transaction.
+func (s *GroupService) ProcessPeriodTransitions(ctx context.Context) error {
+	now := s.now().UTC()
+	expired, err := s.store.Groups().ListExpiredPeriods(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range expired {
+		period := &expired[i]
+		err := s.store.WithTx(ctx, func(tx database.Store) error {
+			return s.transitionPeriod(ctx, tx, period, now)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("period %s: %w", period.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// transitionPeriod closes period and, if its goal is still active, opens
+// the period containing now with carried-over targets
+func (s *GroupService) transitionPeriod(ctx context.Context, tx database.Store, period *models.GroupGoalPeriod, now time.Time) error {
+	if err := tx.Groups().DeactivatePeriod(ctx, period.ID); err != nil {
+		return err
+	}
+
+	goal, err := tx.Groups().GetGoal(ctx, period.GroupGoalID)
+	if err != nil {
+		return err
+	}
+	if !goal.IsActive {
+		return nil
+	}
+
+	cal, err := s.goalCalendar(ctx, tx, goal)
+	if err != nil {
+		return err
+	}
+
+	// Skip over any periods missed while the job was not running
+	following := nextPeriod(cal, goal.PeriodType, period.EndDate)
+	for !following.End.After(now) {
+		following = nextPeriod(cal, goal.PeriodType, following.End)
+	}
+
+	next := models.NewGroupGoalPeriod(goal.ID, following.Start.UTC(), following.End.UTC())
+	if err := tx.Groups().CreatePeriod(ctx, next); err != nil {
+		return err
+	}
+
+	previous, err := tx.Groups().ListProgress(ctx, period.ID)
+	if err != nil {
+		return err
+	}
+	for _, p := range previous {
+		member, err := tx.Groups().GetMember(ctx, goal.GroupID, p.UserID)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return err
+		}
+		if member == nil || !member.IsActive {
+			continue
+		}
+
+		base := p.TargetAmount - p.PenaltyCarryOver
+		row := models.NewGroupGoalProgress(next.ID, p.UserID, base, p.CalculatePenaltyCarryOver())
+		if err := tx.Groups().CreateProgress(ctx, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activePeriod checks membership and returns the current period of a group goal
+func (s *GroupService) activePeriod(ctx context.Context, tx database.Store, userID, groupID, goalID uuid.UUID) (*models.GroupGoalPeriod, error) {
+	if _, err := activeMember(ctx, tx, groupID, userID); err != nil {
+		return nil, err
+	}
+	goal, err := groupGoal(ctx, tx, groupID, goalID)
+	if err != nil {
+		return nil, err
+	}
+	if !goal.IsActive {
+		return nil, newError(ErrConflict, "this group goal is not active")
+	}
+
+	period, err := tx.Groups().GetActivePeriod(ctx, goalID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, newError(ErrConflict, "this group goal has no active period")
+		}
+		return nil, err
+	}
+	return period, nil
+}
+
+// activeMember returns the caller's membership. Non-members get a not found
+// error so group IDs cannot be probed.
+func activeMember(ctx context.Context, store database.Store, groupID, userID uuid.UUID) (*models.GroupMember, error) {
+	member, err := store.Groups().GetMember(ctx, groupID, userID)
+	if err != nil {
+		return nil, notFound(err, "group")
+	}
+	if !member.IsActive {
+		return nil, newError(ErrNotFound, "group not found")
+	}
+	return member, nil
+}
+
+// groupGoal loads a group goal and checks it belongs to groupID
+func groupGoal(ctx context.Context, store database.Store, groupID, goalID uuid.UUID) (*models.GroupGoal, error) {
+	goal, err := store.Groups().GetGoal(ctx, goalID)
+	if err != nil {
+		return nil, notFound(err, "group goal")
+	}
+	if goal.GroupID != groupID {
+		return nil, newError(ErrNotFound, "group goal not found")
+	}
+	return goal, nil
+}
+
+// requirePremium fails unless the user's plan includes groups
+func requirePremium(ctx context.Context, store database.Store, userID uuid.UUID) error {
+	sub, err := store.Subscriptions().GetByUser(ctx, userID)
+	if err != nil {
+		return notFound(err, "subscription")
+	}
+	if !effectivePlan(sub).CanJoinGroups() {
+		return newError(ErrPremiumRequired, "groups require a premium subscription")
+	}
+	return nil
+}
+
+// nextOwner picks who inherits a group: the earliest admin, else the earliest member
+func nextOwner(members []models.GroupMember, leaving uuid.UUID) *models.GroupMember {
+	var successor *models.GroupMember
+	for i := range members {
+		m := &members[i]
+		if m.UserID == leaving {
+			continue
+		}
+		switch {
+		case successor == nil:
+			successor = m
+		case m.Role == models.RoleAdmin && successor.Role != models.RoleAdmin:
+			successor = m
+		case m.Role == successor.Role && m.JoinedAt.Before(successor.JoinedAt):
+			successor = m
+		}
+	}
+	return successor
+}
+
+// groupWithMembers builds the GroupWithMembers view for userID
+func groupWithMembers(ctx context.Context, store database.Store, group *models.Group, userID uuid.UUID) (*models.GroupWithMembers, error) {
+	members, err := memberProfiles(ctx, store, group.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &models.GroupWithMembers{
+		Group:       *group,
+		Members:     members,
+		MemberCount: len(members),
+	}
+	for _, m := range members {
+		if m.GroupMember.UserID == userID {
+			view.UserRole = m.GroupMember.Role
+		}
+	}
+	return view, nil
+}
+
+// memberProfiles returns the active members of a group with their profiles
+func memberProfiles(ctx context.Context, store database.Store, groupID uuid.UUID) ([]models.GroupMemberProfile, error) {
+	members, err := store.Groups().ListMembers(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(members))
+	for i, m := range members {
+		ids[i] = m.UserID
+	}
+	profiles, err := store.Users().GetProfiles(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.GroupMemberProfile, len(members))
+	for i, m := range members {
+		result[i] = models.GroupMemberProfile{GroupMember: m, User: profiles[m.UserID]}
+	}
+	return result, nil
+}
+
+// groupGoalWithProgress builds the current period view of a group goal,
+// with members sorted best first
+func (s *GroupService) groupGoalWithProgress(ctx context.Context, store database.Store, goal *models.GroupGoal) (*models.GroupGoalWithProgress, error) {
+	view := &models.GroupGoalWithProgress{
+		GroupGoal:      *goal,
+		MemberProgress: []models.MemberProgressSummary{},
+	}
+
+	period, err := store.Groups().GetActivePeriod(ctx, goal.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return view, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	view.CurrentPeriod = period
+
+	rows, err := store.Groups().ListProgress(ctx, period.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return view, nil
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, p := range rows {
+		ids[i] = p.UserID
+	}
+	profiles, err := store.Users().GetProfiles(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	completed := 0
+	for i := range rows {
+		summary, err := summarizeProgress(&rows[i], profiles[rows[i].UserID], s.weekStart)
+		if err != nil {
+			return nil, err
+		}
+		view.MemberProgress = append(view.MemberProgress, *summary)
+		view.TotalProgress += summary.CurrentAmount
+		if summary.IsCompleted {
+			completed++
+		}
+	}
+	view.AverageProgress = view.TotalProgress / float64(len(rows))
+	view.CompletionRate = float64(completed) / float64(len(rows)) * 100
+
+	sort.SliceStable(view.MemberProgress, func(i, j int) bool {
+		a, b := view.MemberProgress[i], view.MemberProgress[j]
+		if a.ProgressPercentage != b.ProgressPercentage {
+			return a.ProgressPercentage > b.ProgressPercentage
+		}
+		return a.CurrentAmount > b.CurrentAmount
+	})
+	return view, nil
+}
+
+// summarizeProgress builds a MemberProgressSummary from a progress row,
+// counting the days the member was active in their own timezone
+func summarizeProgress(p *models.GroupGoalProgress, profile models.UserProfile, weekStart time.Weekday) (*models.MemberProgressSummary, error) {
+	var entries []models.DailyEntry
+	if err := json.Unmarshal([]byte(p.DailyEntries), &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode daily entries: %w", err)
+	}
+
+	summary := &models.MemberProgressSummary{
+		UserID:             p.UserID,
+		User:               profile,
+		TargetAmount:       p.TargetAmount,
+		CurrentAmount:      p.CurrentAmount,
+		PenaltyCarryOver:   p.PenaltyCarryOver,
+		ProgressPercentage: p.CalculateProgressPercentage(),
+		IsCompleted:        p.IsCompleted,
+	}
+
+	cal, err := calendar.Load(profile.Timezone, weekStart)
+	if err != nil {
+		cal = calendar.New(time.UTC, weekStart)
+	}
+	days := map[time.Time]bool{}
+	for _, e := range entries {
+		days[cal.StartOfDay(e.Date)] = true
+		if summary.LastActivity == nil || e.Date.After(*summary.LastActivity) {
+			last := e.Date
+			summary.LastActivity = &last
+		}
+	}
+	summary.DaysActive = len(days)
+	return summary, nil
+}
+
+// goalCalendar returns the calendar a group goal's periods follow: that of
+// the member who created it, or of UTC once they have deleted their account
+func (s *GroupService) goalCalendar(ctx context.Context, store database.Store, goal *models.GroupGoal) (calendar.Calendar, error) {
+	cal, err := userCalendar(ctx, store, goal.CreatedBy, s.weekStart)
+	if errors.Is(err, ErrNotFound) {
+		return calendar.New(time.UTC, s.weekStart), nil
+	}
+	return cal, err
+}
+
+// periodOf returns the period of the given type containing t
+func periodOf(cal calendar.Calendar, periodType string, t time.Time) calendar.Period {
+	if periodType == PeriodMonthly {
+		return cal.Month(t)
+	}
+	return cal.Week(t)
+}
+
+// nextPeriod returns the period that takes over from one ending at end. It
+// runs to the end of the period containing the day after end, so periods
+// opened in another timezone or with another week start are brought back in
+// line with cal.
+func nextPeriod(cal calendar.Calendar, periodType string, end time.Time) calendar.Period {
+	return calendar.Period{Start: end, End: periodOf(cal, periodType, cal.AddDays(end, 1)).End}
+}
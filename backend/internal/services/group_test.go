@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/calendar"
+	"chainforge/internal/models"
+)
+
+// seedGroup creates a group owned by a new premium user with one weekly goal
+func seedGroup(t *testing.T, store *memStore) (*GroupService, *models.User, uuid.UUID, uuid.UUID) {
+	t.Helper()
+	ctx := context.Background()
+	svc := NewGroupService(store, time.Monday)
+	owner := seedUser(t, store, models.PlanPremium)
+
+	group, err := svc.CreateGroup(ctx, owner.ID, models.CreateGroupRequest{Name: "Runners", MaxMembers: 5})
+	if err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	goal, err := svc.CreateGroupGoal(ctx, owner.ID, group.Group.ID, models.CreateGroupGoalRequest{
+		Name: "Distance", Unit: "km", PeriodType: PeriodWeekly,
+	})
+	if err != nil {
+		t.Fatalf("CreateGroupGoal: %v", err)
+	}
+	return svc, owner, group.Group.ID, goal.GroupGoal.ID
+}
+
+func TestCreateGroupRequiresPremium(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanFree)
+
+	_, err := NewGroupService(store, time.Monday).CreateGroup(context.Background(), user.ID,
+		models.CreateGroupRequest{Name: "Runners", MaxMembers: 5})
+	if !errors.Is(err, ErrPremiumRequired) {
+		t.Fatalf("err = %v, want ErrPremiumRequired", err)
+	}
+	if len(store.groups) != 0 {
+		t.Error("group was created for a free user")
+	}
+}
+
+func TestAddGroupProgressUpdatesRowAtomically(t *testing.T) {
+	store := newMemStore()
+	svc, owner, groupID, goalID := seedGroup(t, store)
+	ctx := context.Background()
+
+	if _, err := svc.AddProgress(ctx, owner.ID, groupID, goalID, models.AddGroupProgressRequest{Amount: 1}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("progress before target: err = %v, want ErrConflict", err)
+	}
+	if _, err := svc.SetTarget(ctx, owner.ID, groupID, goalID, 10); err != nil {
+		t.Fatalf("SetTarget: %v", err)
+	}
+
+	progress, err := svc.AddProgress(ctx, owner.ID, groupID, goalID, models.AddGroupProgressRequest{Amount: 4})
+	if err != nil {
+		t.Fatalf("AddProgress: %v", err)
+	}
+	if progress.CurrentAmount != 4 || progress.IsCompleted {
+		t.Fatalf("after 4: amount=%v completed=%v", progress.CurrentAmount, progress.IsCompleted)
+	}
+
+	// A failed write must leave the amount, entries and completion flag untouched
+	store.failOn = "Groups.UpdateProgress"
+	if _, err := svc.AddProgress(ctx, owner.ID, groupID, goalID, models.AddGroupProgressRequest{Amount: 6}); err == nil {
+		t.Fatal("expected injected failure")
+	}
+	store.failOn = ""
+	stored, err := store.Groups().GetProgress(ctx, progress.GroupGoalPeriodID, owner.ID)
+	if err != nil {
+		t.Fatalf("GetProgress: %v", err)
+	}
+	if stored.CurrentAmount != 4 || stored.IsCompleted || countEntries(t, stored) != 1 {
+		t.Fatalf("row changed by failed write: %+v", stored)
+	}
+
+	note := "long run"
+	progress, err = svc.AddProgress(ctx, owner.ID, groupID, goalID, models.AddGroupProgressRequest{Amount: 6, Note: &note})
+	if err != nil {
+		t.Fatalf("AddProgress: %v", err)
+	}
+	if progress.CurrentAmount != 10 || !progress.IsCompleted || countEntries(t, progress) != 2 {
+		t.Fatalf("after 10: %+v", progress)
+	}
+}
+
+func TestAddGroupProgressRejectsDatesOutsidePeriod(t *testing.T) {
+	store := newMemStore()
+	svc, owner, groupID, goalID := seedGroup(t, store)
+	ctx := context.Background()
+
+	if _, err := svc.SetTarget(ctx, owner.ID, groupID, goalID, 10); err != nil {
+		t.Fatalf("SetTarget: %v", err)
+	}
+	lastMonth := time.Now().UTC().AddDate(0, -1, 0)
+	_, err := svc.AddProgress(ctx, owner.ID, groupID, goalID, models.AddGroupProgressRequest{Amount: 1, Date: &lastMonth})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("err = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestProcessPeriodTransitionsCarriesPenalty(t *testing.T) {
+	store := newMemStore()
+	svc, owner, groupID, goalID := seedGroup(t, store)
+	ctx := context.Background()
+
+	if _, err := svc.SetTarget(ctx, owner.ID, groupID, goalID, 10); err != nil {
+		t.Fatalf("SetTarget: %v", err)
+	}
+	if _, err := svc.AddProgress(ctx, owner.ID, groupID, goalID, models.AddGroupProgressRequest{Amount: 7}); err != nil {
+		t.Fatalf("AddProgress: %v", err)
+	}
+
+	// Move the current period two weeks into the past
+	old, err := store.Groups().GetActivePeriod(ctx, goalID)
+	if err != nil {
+		t.Fatalf("GetActivePeriod: %v", err)
+	}
+	old.StartDate = old.StartDate.AddDate(0, 0, -14)
+	old.EndDate = old.EndDate.AddDate(0, 0, -14)
+	store.periods[old.ID] = *old
+
+	if err := svc.ProcessPeriodTransitions(ctx); err != nil {
+		t.Fatalf("ProcessPeriodTransitions: %v", err)
+	}
+
+	next, err := store.Groups().GetActivePeriod(ctx, goalID)
+	if err != nil {
+		t.Fatalf("no new period: %v", err)
+	}
+	now := time.Now().UTC()
+	if next.ID == old.ID || next.StartDate.After(now) || !next.EndDate.After(now) {
+		t.Fatalf("new period %v - %v does not contain now", next.StartDate, next.EndDate)
+	}
+	if store.periods[old.ID].IsActive {
+		t.Error("old period is still active")
+	}
+
+	row, err := store.Groups().GetProgress(ctx, next.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("no progress row in new period: %v", err)
+	}
+	if row.PenaltyCarryOver != 3 || row.TargetAmount != 13 || row.CurrentAmount != 0 {
+		t.Fatalf("new row = target %v penalty %v amount %v, want 13, 3, 0",
+			row.TargetAmount, row.PenaltyCarryOver, row.CurrentAmount)
+	}
+
+	// Changing the target keeps the penalty on top
+	row, err = svc.SetTarget(ctx, owner.ID, groupID, goalID, 20)
+	if err != nil {
+		t.Fatalf("SetTarget: %v", err)
+	}
+	if row.TargetAmount != 23 {
+		t.Errorf("target = %v, want 23", row.TargetAmount)
+	}
+}
+
+func TestLeaveGroupTransfersOwnership(t *testing.T) {
+	store := newMemStore()
+	svc, owner, groupID, _ := seedGroup(t, store)
+	ctx := context.Background()
+
+	group, err := store.Groups().GetByID(ctx, groupID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	member := seedUser(t, store, models.PlanPremium)
+	if _, err := svc.JoinGroup(ctx, member.ID, group.InviteCode); err != nil {
+		t.Fatalf("JoinGroup: %v", err)
+	}
+	if _, err := svc.JoinGroup(ctx, member.ID, group.InviteCode); !errors.Is(err, ErrConflict) {
+		t.Fatalf("second join: err = %v, want ErrConflict", err)
+	}
+
+	if err := svc.LeaveGroup(ctx, owner.ID, groupID); err != nil {
+		t.Fatalf("LeaveGroup: %v", err)
+	}
+	m, err := store.Groups().GetMember(ctx, groupID, member.ID)
+	if err != nil || m.Role != models.RoleOwner {
+		t.Fatalf("remaining member role = %v (%v), want owner", m, err)
+	}
+
+	if err := svc.LeaveGroup(ctx, member.ID, groupID); err != nil {
+		t.Fatalf("LeaveGroup: %v", err)
+	}
+	if _, ok := store.groups[groupID]; ok {
+		t.Error("group should be deleted when the last member leaves")
+	}
+}
+
+func TestRegenerateInviteCodeRequiresAdmin(t *testing.T) {
+	store := newMemStore()
+	svc, owner, groupID, _ := seedGroup(t, store)
+	ctx := context.Background()
+
+	group, _ := store.Groups().GetByID(ctx, groupID)
+	oldCode := group.InviteCode
+	member := seedUser(t, store, models.PlanPremium)
+	if _, err := svc.JoinGroup(ctx, member.ID, oldCode); err != nil {
+		t.Fatalf("JoinGroup: %v", err)
+	}
+
+	if _, err := svc.RegenerateInviteCode(ctx, member.ID, groupID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("member regenerate: err = %v, want ErrForbidden", err)
+	}
+
+	code, err := svc.RegenerateInviteCode(ctx, owner.ID, groupID)
+	if err != nil {
+		t.Fatalf("RegenerateInviteCode: %v", err)
+	}
+	if code == oldCode || len(code) != 8 {
+		t.Fatalf("new code %q, old %q", code, oldCode)
+	}
+	if _, err := store.Groups().GetByInviteCode(ctx, oldCode); err == nil {
+		t.Error("old invite code still resolves")
+	}
+}
+
+func TestPeriodOf(t *testing.T) {
+	wednesday := time.Date(2024, 5, 15, 18, 30, 0, 0, time.UTC)
+
+	p := periodOf(utc, PeriodWeekly, wednesday)
+	if !p.Start.Equal(time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)) || !p.End.Equal(time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("weekly = %v - %v", p.Start, p.End)
+	}
+
+	p = periodOf(utc, PeriodMonthly, wednesday)
+	if !p.Start.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) || !p.End.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("monthly = %v - %v", p.Start, p.End)
+	}
+
+	la, err := calendar.Load("America/Los_Angeles", time.Sunday)
+	if err != nil {
+		t.Skip(err)
+	}
+	// Late on Sunday in Los Angeles is already Monday in UTC
+	sunday := time.Date(2024, 5, 20, 5, 0, 0, 0, time.UTC)
+	p = periodOf(la, PeriodWeekly, sunday)
+	if !p.Start.Equal(time.Date(2024, 5, 19, 7, 0, 0, 0, time.UTC)) || !p.End.Equal(time.Date(2024, 5, 26, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("weekly in Los Angeles = %v - %v", p.Start, p.End)
+	}
+
+	// A period ending at midnight UTC is followed by one running to the end
+	// of the next week in Los Angeles
+	p = nextPeriod(la, PeriodWeekly, time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC))
+	if !p.Start.Equal(time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)) || !p.End.Equal(time.Date(2024, 5, 26, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("next period = %v - %v", p.Start, p.End)
+	}
+}
+
+func TestGroupPeriodsFollowCreatorTimezone(t *testing.T) {
+	store := newMemStore()
+	svc, owner, groupID, _ := seedGroup(t, store)
+	owner.Timezone = "America/Los_Angeles"
+	if err := store.Users().Update(context.Background(), owner); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	// Monday 02:00 UTC is still Sunday evening in Los Angeles
+	now := time.Date(2024, 5, 20, 2, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	goal, err := svc.CreateGroupGoal(context.Background(), owner.ID, groupID, models.CreateGroupGoalRequest{
+		Name: "Steps", Unit: "steps", PeriodType: PeriodWeekly,
+	})
+	if err != nil {
+		t.Fatalf("CreateGroupGoal: %v", err)
+	}
+	period := goal.CurrentPeriod
+	if !period.StartDate.Equal(time.Date(2024, 5, 13, 7, 0, 0, 0, time.UTC)) || !period.EndDate.Equal(time.Date(2024, 5, 20, 7, 0, 0, 0, time.UTC)) {
+		t.Fatalf("period = %v - %v, want Monday to Monday in Los Angeles", period.StartDate, period.EndDate)
+	}
+
+	now = period.EndDate.Add(time.Hour)
+	if err := svc.ProcessPeriodTransitions(context.Background()); err != nil {
+		t.Fatalf("ProcessPeriodTransitions: %v", err)
+	}
+	next, err := store.Groups().GetActivePeriod(context.Background(), goal.GroupGoal.ID)
+	if err != nil {
+		t.Fatalf("GetActivePeriod: %v", err)
+	}
+	if !next.StartDate.Equal(period.EndDate) || !next.EndDate.Equal(time.Date(2024, 5, 27, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("next period = %v - %v", next.StartDate, next.EndDate)
+	}
+}
+
+func countEntries(t *testing.T, p *models.GroupGoalProgress) int {
+	t.Helper()
+	var entries []models.DailyEntry
+	if err := json.Unmarshal([]byte(p.DailyEntries), &entries); err != nil {
+		t.Fatalf("daily entries: %v", err)
+	}
+	return len(entries)
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"slices"
+	"time"
+
+	"chainforge/internal/calendar"
+	"chainforge/internal/models"
+)
+
+// validateGoalKind checks that only habits have a recurrence and that a
+// habit's recurrence is valid, normalizing it
+func validateGoalKind(kind models.GoalKind, rec *models.Recurrence) error {
+	switch kind {
+	case "", models.GoalKindTarget:
+		if rec != nil {
+			return newError(ErrInvalidInput, "only habit goals have a recurrence")
+		}
+		return nil
+	case models.GoalKindHabit:
+		return validateRecurrence(rec)
+	default:
+		return newError(ErrInvalidInput, "unknown goal kind %q", kind)
+	}
+}
+
+// validateRecurrence checks a habit's recurrence, clearing the fields its
+// frequency does not use and sorting its weekdays
+func validateRecurrence(rec *models.Recurrence) error {
+	if rec == nil {
+		return newError(ErrInvalidInput, "habit goals need a recurrence")
+	}
+	switch rec.Frequency {
+	case models.RecurDaily:
+		rec.TimesPerWeek, rec.Weekdays = 0, nil
+	case models.RecurWeekly:
+		if rec.TimesPerWeek < 1 || rec.TimesPerWeek > 7 {
+			return newError(ErrInvalidInput, "times_per_week must be between 1 and 7")
+		}
+		rec.Weekdays = nil
+	case models.RecurWeekdays:
+		if len(rec.Weekdays) == 0 {
+			return newError(ErrInvalidInput, "weekdays must name at least one day")
+		}
+		for _, d := range rec.Weekdays {
+			if d < time.Sunday || d > time.Saturday {
+				return newError(ErrInvalidInput, "weekdays must be between 0 (Sunday) and 6 (Saturday)")
+			}
+		}
+		slices.Sort(rec.Weekdays)
+		rec.Weekdays = slices.Compact(rec.Weekdays)
+		rec.TimesPerWeek = 0
+	default:
+		return newError(ErrInvalidInput, "unknown recurrence frequency %q", rec.Frequency)
+	}
+	return nil
+}
+
+// habitPeriod is one period of a habit's recurrence
+type habitPeriod struct {
+	done     int  // Days in the period on which the habit was done
+	required int  // Days it had to be done on
+	open     bool // The period has not ended yet
+}
+
+// habitPeriods splits the days from a habit's start until today, in cal,
+// into the periods of its recurrence, oldest first
+func habitPeriods(goal *models.Goal, entries []models.GoalProgress, cal calendar.Calendar, now time.Time) []habitPeriod {
+	daily := dailyAmounts(goal, entries, cal)
+	done := func(day time.Time) int {
+		amount, logged := daily[day]
+		kept := amount >= goal.TargetAmount
+		if goal.IsLimit() {
+			// A day without a measurement was not kept to the limits
+			kept = logged && goal.WithinLimits(amount)
+		}
+		if kept {
+			return 1
+		}
+		return 0
+	}
+
+	first, today := cal.StartOfDay(goal.StartDate), cal.StartOfDay(now)
+	last := today
+	var end *time.Time
+	if goal.EndDate != nil {
+		e := cal.StartOfDay(*goal.EndDate)
+		end = &e
+		if e.Before(last) {
+			last = e
+		}
+	}
+
+	var periods []habitPeriod
+	switch goal.Recurrence.Frequency {
+	case models.RecurDaily:
+		for day := first; !day.After(last); day = cal.AddDays(day, 1) {
+			periods = append(periods, habitPeriod{done: done(day), required: 1, open: day.Equal(today)})
+		}
+
+	case models.RecurWeekdays:
+		for day := first; !day.After(last); day = cal.AddDays(day, 1) {
+			if slices.Contains(goal.Recurrence.Weekdays, day.Weekday()) {
+				periods = append(periods, habitPeriod{done: done(day), required: 1, open: day.Equal(today)})
+			}
+		}
+
+	case models.RecurWeekly:
+		for week := cal.Week(first).Start; !week.After(last); week = cal.AddDays(week, 7) {
+			p := habitPeriod{}
+			days := 0
+			for i := 0; i < 7; i++ {
+				day := cal.AddDays(week, i)
+				if day.Before(first) || (end != nil && day.After(*end)) {
+					continue
+				}
+				days++
+				p.done += done(day)
+				p.open = p.open || day.Equal(today)
+			}
+			// A week cut short by the start or end date asks for no more
+			// days than it has
+			p.required = min(goal.Recurrence.TimesPerWeek, days)
+			periods = append(periods, p)
+		}
+	}
+	return periods
+}
+
+// habitStreak computes the streaks of a habit goal with days counted in cal.
+// A period still running breaks no streak; it only adds to one once the
+// habit has been done often enough.
+func habitStreak(goal *models.Goal, entries []models.GoalProgress, cal calendar.Calendar, now time.Time) *models.HabitStreak {
+	periods := habitPeriods(goal, entries, cal, now)
+	streak := &models.HabitStreak{}
+	run := 0
+	for _, p := range periods {
+		switch {
+		case p.done >= p.required:
+			run++
+		case !p.open:
+			run = 0
+		}
+		streak.LongestStreak = max(streak.LongestStreak, run)
+	}
+	streak.CurrentStreak = run
+	if n := len(periods); n > 0 && periods[n-1].open {
+		streak.PeriodDone, streak.PeriodRequired = periods[n-1].done, periods[n-1].required
+	}
+	return streak
+}
+
+// habitPercentage is how much of the current period of a habit is done
+func habitPercentage(streak *models.HabitStreak) float64 {
+	if streak.PeriodRequired == 0 {
+		return 0
+	}
+	return min(float64(streak.PeriodDone)/float64(streak.PeriodRequired), 1) * 100
+}
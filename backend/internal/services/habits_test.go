@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"chainforge/internal/calendar"
+	"chainforge/internal/models"
+)
+
+// habitNow is a Thursday
+var habitNow = time.Date(2026, 3, 12, 18, 0, 0, 0, time.UTC)
+
+// utc counts days in UTC with weeks starting on Monday
+var utc = calendar.New(time.UTC, time.Monday)
+
+// habit returns a habit goal due once per occurrence from start
+func habit(start time.Time, rec models.Recurrence) *models.Goal {
+	return &models.Goal{Kind: models.GoalKindHabit, Recurrence: &rec, TargetAmount: 1, StartDate: start}
+}
+
+// doneOn returns one entry at noon UTC on each of the given days of March 2026
+func doneOn(days ...int) []models.GoalProgress {
+	entries := make([]models.GoalProgress, len(days))
+	for i, d := range days {
+		entries[i] = models.GoalProgress{Amount: 1, Date: time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC)}
+	}
+	return entries
+}
+
+func TestHabitStreakDaily(t *testing.T) {
+	goal := habit(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), models.Recurrence{Frequency: models.RecurDaily})
+	entries := doneOn(1, 2, 3, 5, 6, 7, 8, 9, 10, 11)
+
+	// Today is not over, so not having done it yet breaks nothing
+	got := habitStreak(goal, entries, utc, habitNow)
+	want := models.HabitStreak{CurrentStreak: 7, LongestStreak: 7, PeriodDone: 0, PeriodRequired: 1}
+	if *got != want {
+		t.Fatalf("streak = %+v, want %+v", *got, want)
+	}
+
+	got = habitStreak(goal, append(entries, doneOn(12)...), utc, habitNow)
+	if got.CurrentStreak != 8 || got.PeriodDone != 1 {
+		t.Errorf("after doing it today: %+v", *got)
+	}
+
+	// A day short of the target amount does not count
+	goal.TargetAmount = 2
+	if got := habitStreak(goal, entries, utc, habitNow); got.LongestStreak != 0 {
+		t.Errorf("streak with too little done = %+v", *got)
+	}
+}
+
+func TestHabitStreakWeekdays(t *testing.T) {
+	goal := habit(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), models.Recurrence{
+		Frequency: models.RecurWeekdays,
+		Weekdays:  []time.Weekday{time.Monday, time.Wednesday, time.Friday},
+	})
+
+	// Days it is not due neither break nor extend the streak
+	got := habitStreak(goal, doneOn(2, 4, 6, 7, 9, 11), utc, habitNow)
+	want := models.HabitStreak{CurrentStreak: 5, LongestStreak: 5}
+	if *got != want {
+		t.Fatalf("streak = %+v, want %+v", *got, want)
+	}
+
+	got = habitStreak(goal, doneOn(2, 4, 9, 11), utc, habitNow)
+	if got.CurrentStreak != 2 || got.LongestStreak != 2 {
+		t.Errorf("streak after missing Friday = %+v, want 2 and 2", *got)
+	}
+}
+
+func TestHabitStreakWeekly(t *testing.T) {
+	goal := habit(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), models.Recurrence{Frequency: models.RecurWeekly, TimesPerWeek: 3})
+
+	got := habitStreak(goal, doneOn(2, 3, 8, 10, 11), utc, habitNow)
+	want := models.HabitStreak{CurrentStreak: 1, LongestStreak: 1, PeriodDone: 2, PeriodRequired: 3}
+	if *got != want {
+		t.Fatalf("streak = %+v, want %+v", *got, want)
+	}
+
+	// A first week starting on Saturday only asks for the two days it has
+	goal.StartDate = time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)
+	got = habitStreak(goal, doneOn(7, 8, 9, 10, 12), utc, habitNow)
+	if got.CurrentStreak != 2 || got.PeriodDone != 3 {
+		t.Errorf("streak from a short first week = %+v, want 2 weeks", *got)
+	}
+}
+
+func TestHabitStreakUsesUserTimezone(t *testing.T) {
+	nyc, err := calendar.Load("America/New_York", time.Monday)
+	if err != nil {
+		t.Skip(err)
+	}
+	ny := nyc.Location()
+	goal := habit(time.Date(2026, 3, 10, 12, 0, 0, 0, ny), models.Recurrence{Frequency: models.RecurDaily})
+	entries := []models.GoalProgress{
+		{Amount: 1, Date: time.Date(2026, 3, 10, 23, 30, 0, 0, ny)}, // March 11 in UTC
+		{Amount: 1, Date: time.Date(2026, 3, 11, 20, 0, 0, 0, ny)},  // March 12 in UTC
+	}
+
+	// In New York both entries were late in the evening and today is not
+	// done yet; in UTC the first day was missed and today is done
+	want := models.HabitStreak{CurrentStreak: 2, LongestStreak: 2, PeriodDone: 0, PeriodRequired: 1}
+	if got := habitStreak(goal, entries, nyc, habitNow); *got != want {
+		t.Errorf("streak in New York = %+v, want %+v", *got, want)
+	}
+	want.PeriodDone = 1
+	if got := habitStreak(goal, entries, utc, habitNow); *got != want {
+		t.Errorf("streak in UTC = %+v, want %+v", *got, want)
+	}
+}
+
+func TestCreateHabitGoalValidatesRecurrence(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanPremium)
+	svc := NewGoalService(store, time.Monday)
+	ctx := context.Background()
+
+	invalid := []struct {
+		kind models.GoalKind
+		rec  *models.Recurrence
+	}{
+		{models.GoalKindHabit, nil},
+		{models.GoalKindTarget, &models.Recurrence{Frequency: models.RecurDaily}},
+		{models.GoalKindHabit, &models.Recurrence{Frequency: models.RecurWeekly, TimesPerWeek: 8}},
+		{models.GoalKindHabit, &models.Recurrence{Frequency: models.RecurWeekdays}},
+		{models.GoalKindHabit, &models.Recurrence{Frequency: models.RecurWeekdays, Weekdays: []time.Weekday{7}}},
+		{models.GoalKindHabit, &models.Recurrence{Frequency: "hourly"}},
+		{"chore", nil},
+	}
+	for _, tt := range invalid {
+		req := goalRequest(1)
+		req.Kind, req.Recurrence = tt.kind, tt.rec
+		if _, err := svc.CreateGoal(ctx, user.ID, req); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("kind %q recurrence %+v: err = %v, want ErrInvalidInput", tt.kind, tt.rec, err)
+		}
+	}
+
+	req := goalRequest(1)
+	req.Kind = models.GoalKindHabit
+	req.Recurrence = &models.Recurrence{Frequency: models.RecurWeekdays, Weekdays: []time.Weekday{5, 1, 5}, TimesPerWeek: 2}
+	goal, err := svc.CreateGoal(ctx, user.ID, req)
+	if err != nil {
+		t.Fatalf("CreateGoal: %v", err)
+	}
+	if rec := goal.Recurrence; len(rec.Weekdays) != 2 || rec.Weekdays[0] != time.Monday || rec.TimesPerWeek != 0 {
+		t.Errorf("recurrence = %+v, want Monday and Friday only", rec)
+	}
+}
+
+func TestHabitProgressFeedsUserStats(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanPremium)
+	goals := NewGoalService(store, time.Monday)
+	users := newTestUserService(store)
+	ctx := context.Background()
+
+	// Both services see the same day, however close to midnight the test runs
+	now := time.Date(2024, 3, 13, 23, 59, 0, 0, time.UTC)
+	goals.now = func() time.Time { return now }
+	users.now = func() time.Time { return now }
+
+	req := goalRequest(1)
+	req.Kind = models.GoalKindHabit
+	req.Recurrence = &models.Recurrence{Frequency: models.RecurDaily}
+	req.StartDate = now.AddDate(0, 0, -3)
+	goal, err := goals.CreateGoal(ctx, user.ID, req)
+	if err != nil {
+		t.Fatalf("CreateGoal: %v", err)
+	}
+	for days := 3; days >= 1; days-- {
+		date := now.AddDate(0, 0, -days)
+		_, updated, err := goals.AddProgress(ctx, user.ID, goal.ID, models.AddProgressRequest{Amount: 1, Date: &date})
+		if err != nil {
+			t.Fatalf("AddProgress: %v", err)
+		}
+		if updated.Status != models.GoalStatusInProgress {
+			t.Fatalf("habit status = %s, want in_progress", updated.Status)
+		}
+	}
+
+	view, err := goals.GetGoalWithProgress(ctx, user.ID, goal.ID)
+	if err != nil {
+		t.Fatalf("GetGoalWithProgress: %v", err)
+	}
+	if view.Streak == nil || view.Streak.CurrentStreak != 3 || view.ProgressPercentage != 0 {
+		t.Errorf("view streak = %+v, percentage = %v", view.Streak, view.ProgressPercentage)
+	}
+
+	stats, err := users.GetStats(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if stats.CurrentStreak != 3 || stats.LongestStreak != 3 {
+		t.Errorf("stats streaks = %d and %d, want 3 and 3", stats.CurrentStreak, stats.LongestStreak)
+	}
+}
+
+func TestProgressLateInTheEveningCountsTowardsThatDay(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanPremium)
+	user.Timezone = "America/Los_Angeles"
+	if err := store.Users().Update(context.Background(), user); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	la, err := calendar.Load(user.Timezone, time.Monday)
+	if err != nil {
+		t.Skip(err)
+	}
+	svc := NewGoalService(store, time.Monday)
+	ctx := context.Background()
+
+	// 11pm on Thursday in California, already Friday in UTC
+	now := time.Date(2026, 3, 12, 23, 0, 0, 0, la.Location())
+	svc.now = func() time.Time { return now }
+
+	req := goalRequest(1)
+	req.Kind = models.GoalKindHabit
+	req.Recurrence = &models.Recurrence{Frequency: models.RecurDaily}
+	req.StartDate = time.Date(2026, 3, 10, 9, 0, 0, 0, la.Location())
+	goal, err := svc.CreateGoal(ctx, user.ID, req)
+	if err != nil {
+		t.Fatalf("CreateGoal: %v", err)
+	}
+	for _, date := range []time.Time{req.StartDate, req.StartDate.AddDate(0, 0, 1)} {
+		if _, _, err := svc.AddProgress(ctx, user.ID, goal.ID, models.AddProgressRequest{Amount: 1, Date: &date}); err != nil {
+			t.Fatalf("AddProgress(%v): %v", date, err)
+		}
+	}
+	if _, _, err := svc.AddProgress(ctx, user.ID, goal.ID, models.AddProgressRequest{Amount: 1}); err != nil {
+		t.Fatalf("AddProgress: %v", err)
+	}
+
+	view, err := svc.GetGoalWithProgress(ctx, user.ID, goal.ID)
+	if err != nil {
+		t.Fatalf("GetGoalWithProgress: %v", err)
+	}
+	want := models.HabitStreak{CurrentStreak: 3, LongestStreak: 3, PeriodDone: 1, PeriodRequired: 1}
+	if *view.Streak != want {
+		t.Errorf("streak = %+v, want %+v", *view.Streak, want)
+	}
+
+	// Friday has not started in California yet
+	friday := time.Date(2026, 3, 13, 0, 30, 0, 0, la.Location())
+	if _, _, err := svc.AddProgress(ctx, user.ID, goal.ID, models.AddProgressRequest{Amount: 1, Date: &friday}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("progress for tomorrow: err = %v, want ErrInvalidInput", err)
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"chainforge/internal/database"
+	"chainforge/internal/email"
+	"chainforge/internal/models"
+)
+
+const (
+	// outboxBatchSize bounds how many emails one delivery pass sends
+	outboxBatchSize = 20
+	// outboxLease is how long a claimed email is hidden from other passes
+	// while it is being sent
+	outboxLease = 5 * time.Minute
+	// outboxMaxAttempts is how often an email is tried before it is failed
+	outboxMaxAttempts = 8
+	// outboxMaxBackoff caps the wait between attempts
+	outboxMaxBackoff = 6 * time.Hour
+)
+
+// Mailer renders emails into the outbox and delivers them from there. An
+// email queued in a transaction is sent only if the transaction commits,
+// and is still sent if the server restarts before delivering it.
+type Mailer struct {
+	store     database.Store
+	sender    email.Sender
+	templates *email.Templates
+	wake      chan struct{}
+	now       func() time.Time
+}
+
+// NewMailer creates a mailer delivering through sender
+func NewMailer(store database.Store, sender email.Sender, templates *email.Templates) *Mailer {
+	return &Mailer{
+		store:     store,
+		sender:    sender,
+		templates: templates,
+		wake:      make(chan struct{}, 1),
+		now:       time.Now,
+	}
+}
+
+// Queue renders the email template in the closest locale to locale and adds
+// it to the outbox through tx. Call Notify once tx commits to send it
+// without waiting for the next delivery pass.
+func (m *Mailer) Queue(ctx context.Context, tx database.Store, user *models.User, template, locale string, data any) error {
+	msg, err := m.templates.Render(template, locale, data)
+	if err != nil {
+		return err
+	}
+	return tx.Outbox().EnqueueEmail(ctx, models.NewOutboxEmail(user.ID, template, user.Email, msg.Subject, msg.Text, msg.HTML))
+}
+
+// Notify wakes Run to deliver newly queued emails
+func (m *Mailer) Notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers due emails every interval and whenever Notify is called,
+// until ctx is done
+func (m *Mailer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for {
+			sent, err := m.Deliver(ctx)
+			if err != nil {
+				log.Printf("Error delivering emails: %v", err)
+			}
+			// A full batch means more may be due
+			if err != nil || sent < outboxBatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-m.wake:
+		}
+	}
+}
+
+// Deliver sends a batch of due emails and returns how many were attempted.
+// Failed sends are retried with exponential backoff, up to
+// outboxMaxAttempts attempts.
+func (m *Mailer) Deliver(ctx context.Context) (int, error) {
+	now := m.now().UTC()
+	emails, err := m.store.Outbox().ClaimEmails(ctx, now, now.Add(outboxLease), outboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range emails {
+		err := m.sender.Send(ctx, email.Message{
+			To:      e.Recipient,
+			Subject: e.Subject,
+			Text:    e.TextBody,
+			HTML:    e.HTMLBody,
+		})
+		if err == nil {
+			if err := m.store.Outbox().DeleteEmail(ctx, e.ID); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err := m.recordFailure(ctx, e, err); err != nil {
+			return 0, err
+		}
+	}
+	return len(emails), nil
+}
+
+func (m *Mailer) recordFailure(ctx context.Context, e models.OutboxEmail, sendErr error) error {
+	attempts := e.Attempts + 1
+	if attempts >= outboxMaxAttempts {
+		log.Printf("Email: giving up on %s email %s after %d attempts: %v", e.Template, e.ID, attempts, sendErr)
+		return m.store.Outbox().FailEmail(ctx, e.ID, attempts, sendErr.Error())
+	}
+	log.Printf("Email: failed to send %s email %s (attempt %d): %v", e.Template, e.ID, attempts, sendErr)
+	return m.store.Outbox().RetryEmail(ctx, e.ID, attempts, m.now().UTC().Add(outboxBackoff(attempts)), sendErr.Error())
+}
+
+// outboxBackoff is the wait after the given number of failed attempts:
+// one minute, doubling each time up to outboxMaxBackoff
+func outboxBackoff(attempts int) time.Duration {
+	d := time.Minute
+	for i := 1; i < attempts && d < outboxMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, outboxMaxBackoff)
+}
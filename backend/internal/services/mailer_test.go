@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"chainforge/internal/email"
+	"chainforge/internal/models"
+)
+
+// flakySender fails the first failures sends
+type flakySender struct {
+	failures int
+	sent     []email.Message
+}
+
+func (s *flakySender) Send(ctx context.Context, msg email.Message) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("connection refused")
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestQueuedEmailSurvivesRestart(t *testing.T) {
+	store := newMemStore()
+	svc, _ := newMailingUserService(store)
+	ctx := context.Background()
+
+	// Registering queues the email with the account; nothing is sent yet
+	if _, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if len(store.outbox) != 1 {
+		t.Fatalf("%d emails queued, want 1", len(store.outbox))
+	}
+
+	// A mailer started after a restart picks it up from the outbox
+	sender := &flakySender{}
+	if sent, err := NewMailer(store, sender, testTemplates).Deliver(ctx); err != nil || sent != 1 {
+		t.Fatalf("Deliver = %d, %v; want 1 email", sent, err)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].To != "ada@example.com" {
+		t.Fatalf("sent %v", sender.sent)
+	}
+	if len(store.outbox) != 0 {
+		t.Error("a delivered email is still queued")
+	}
+}
+
+func TestRegisterRollsBackWithoutQueuedEmail(t *testing.T) {
+	store := newMemStore()
+	svc, _ := newMailingUserService(store)
+	store.failOn = "Outbox.EnqueueEmail"
+
+	if _, _, err := svc.Register(context.Background(), registerRequest("ada@example.com"), testClient); !errors.Is(err, errInjected) {
+		t.Fatalf("Register: err = %v, want the injected failure", err)
+	}
+	if len(store.users) != 0 || len(store.emailTokens) != 0 {
+		t.Error("the account was created without its verification email")
+	}
+}
+
+func TestMailerRetriesWithBackoff(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanFree)
+	ctx := context.Background()
+
+	sender := &flakySender{failures: 2}
+	mailer := NewMailer(store, sender, testTemplates)
+	err := mailer.Queue(ctx, store, user, emailResetPassword, "en", linkEmail{Link: "https://app.chainforge.test/x", ExpiresIn: time.Hour})
+	if err != nil {
+		t.Fatalf("Queue: %v", err)
+	}
+	now := time.Now().UTC()
+	mailer.now = func() time.Time { return now }
+	queued := func() models.OutboxEmail {
+		for _, e := range store.outbox {
+			return e
+		}
+		t.Fatal("outbox is empty")
+		return models.OutboxEmail{}
+	}
+
+	// First failure: retried a minute later
+	mailer.Deliver(ctx)
+	if e := queued(); e.Attempts != 1 || !e.NextAttemptAt.Equal(now.Add(time.Minute)) || e.LastError == nil {
+		t.Fatalf("after one failure: attempts = %d, next attempt = %s", e.Attempts, e.NextAttemptAt)
+	}
+	if sent, _ := mailer.Deliver(ctx); sent != 0 {
+		t.Fatal("a failed email was retried before its backoff")
+	}
+
+	// Second failure: the wait doubles
+	now = now.Add(time.Minute)
+	mailer.Deliver(ctx)
+	if e := queued(); e.Attempts != 2 || !e.NextAttemptAt.Equal(now.Add(2*time.Minute)) {
+		t.Fatalf("after two failures: attempts = %d, next attempt = %s", e.Attempts, e.NextAttemptAt)
+	}
+
+	now = now.Add(2 * time.Minute)
+	mailer.Deliver(ctx)
+	if len(sender.sent) != 1 || len(store.outbox) != 0 {
+		t.Fatalf("third attempt: %d sent, %d queued", len(sender.sent), len(store.outbox))
+	}
+}
+
+func TestMailerGivesUp(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanFree)
+	ctx := context.Background()
+
+	mailer := NewMailer(store, &flakySender{failures: 100}, testTemplates)
+	mailer.Queue(ctx, store, user, emailVerifyEmail, "en", linkEmail{Link: "https://app.chainforge.test/x", ExpiresIn: time.Hour})
+	now := time.Now()
+	mailer.now = func() time.Time { return now }
+
+	for i := 0; i < outboxMaxAttempts+2; i++ {
+		mailer.Deliver(ctx)
+		now = now.Add(outboxMaxBackoff)
+	}
+	if len(store.outbox) != 1 {
+		t.Fatalf("%d emails in the outbox, want the failed one kept", len(store.outbox))
+	}
+	for _, e := range store.outbox {
+		if e.Status != models.OutboxFailed || e.Attempts != outboxMaxAttempts {
+			t.Errorf("status = %s after %d attempts, want failed after %d", e.Status, e.Attempts, outboxMaxAttempts)
+		}
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		5:  16 * time.Minute,
+		9:  256 * time.Minute,
+		10: outboxMaxBackoff,
+		40: outboxMaxBackoff,
+	}
+	for attempts, want := range tests {
+		if got := outboxBackoff(attempts); got != want {
+			t.Errorf("outboxBackoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
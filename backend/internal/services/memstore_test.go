@@ -0,0 +1,1285 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/database"
+	"chainforge/internal/models"
+)
+
+// memStore is an in-memory database.Store for unit tests. WithTx snapshots
+// every table and restores the snapshot when fn fails, so tests can check
+// that failed operations leave no partial writes behind.
+type memStore struct {
+	users          map[uuid.UUID]models.User
+	goals          map[uuid.UUID]models.Goal
+	progress       map[uuid.UUID]models.GoalProgress
+	archived       map[uuid.UUID]models.GoalProgress
+	groups         map[uuid.UUID]models.Group
+	members        map[uuid.UUID]models.GroupMember
+	groupGoals     map[uuid.UUID]models.GroupGoal
+	periods        map[uuid.UUID]models.GroupGoalPeriod
+	groupProgress  map[uuid.UUID]models.GroupGoalProgress
+	subscriptions  map[uuid.UUID]models.Subscription
+	paymentMethods map[uuid.UUID]models.PaymentMethod
+	invoices       map[uuid.UUID]models.Invoice
+	families       map[uuid.UUID]models.RefreshTokenFamily
+	sessions       map[uuid.UUID]models.Session
+	refreshTokens  map[string]models.RefreshToken
+	securityEvents map[uuid.UUID]models.SecurityEvent
+	totp           map[uuid.UUID]models.TOTPCredential
+	recoveryCodes  map[uuid.UUID]memRecoveryCode
+	auditLogs      map[uuid.UUID]models.AuditLog
+	challenges     map[uuid.UUID]models.WebAuthnChallenge
+	passkeys       map[uuid.UUID]models.WebAuthnCredential
+	oidcStates     map[string]models.OIDCState
+	identities     map[uuid.UUID]models.UserIdentity
+	emailTokens    map[uuid.UUID]models.EmailToken
+	outbox         map[uuid.UUID]models.OutboxEmail
+	throttles      map[string]models.LoginThrottle
+	apiKeys        map[uuid.UUID]models.APIKey
+	passwords      map[uuid.UUID][]models.PasswordHistoryEntry // Newest first
+
+	// failOn makes the named operation return errInjected
+	failOn string
+}
+
+var errInjected = newError(ErrConflict, "injected failure")
+
+func newMemStore() *memStore {
+	return &memStore{
+		users:          map[uuid.UUID]models.User{},
+		goals:          map[uuid.UUID]models.Goal{},
+		progress:       map[uuid.UUID]models.GoalProgress{},
+		archived:       map[uuid.UUID]models.GoalProgress{},
+		groups:         map[uuid.UUID]models.Group{},
+		members:        map[uuid.UUID]models.GroupMember{},
+		groupGoals:     map[uuid.UUID]models.GroupGoal{},
+		periods:        map[uuid.UUID]models.GroupGoalPeriod{},
+		groupProgress:  map[uuid.UUID]models.GroupGoalProgress{},
+		subscriptions:  map[uuid.UUID]models.Subscription{},
+		paymentMethods: map[uuid.UUID]models.PaymentMethod{},
+		invoices:       map[uuid.UUID]models.Invoice{},
+		families:       map[uuid.UUID]models.RefreshTokenFamily{},
+		sessions:       map[uuid.UUID]models.Session{},
+		refreshTokens:  map[string]models.RefreshToken{},
+		securityEvents: map[uuid.UUID]models.SecurityEvent{},
+		totp:           map[uuid.UUID]models.TOTPCredential{},
+		recoveryCodes:  map[uuid.UUID]memRecoveryCode{},
+		auditLogs:      map[uuid.UUID]models.AuditLog{},
+		challenges:     map[uuid.UUID]models.WebAuthnChallenge{},
+		passkeys:       map[uuid.UUID]models.WebAuthnCredential{},
+		oidcStates:     map[string]models.OIDCState{},
+		identities:     map[uuid.UUID]models.UserIdentity{},
+		emailTokens:    map[uuid.UUID]models.EmailToken{},
+		outbox:         map[uuid.UUID]models.OutboxEmail{},
+		throttles:      map[string]models.LoginThrottle{},
+		apiKeys:        map[uuid.UUID]models.APIKey{},
+		passwords:      map[uuid.UUID][]models.PasswordHistoryEntry{},
+	}
+}
+
+func (m *memStore) Users() database.UserStore                 { return memUsers{m} }
+func (m *memStore) Goals() database.GoalStore                 { return memGoals{m} }
+func (m *memStore) Groups() database.GroupStore               { return memGroups{m} }
+func (m *memStore) Subscriptions() database.SubscriptionStore { return memSubscriptions{m} }
+func (m *memStore) Tokens() database.TokenStore               { return memTokens{m} }
+func (m *memStore) MFA() database.MFAStore                    { return memMFA{m} }
+func (m *memStore) Audit() database.AuditStore                { return memAudit{m} }
+func (m *memStore) WebAuthn() database.WebAuthnStore          { return memWebAuthn{m} }
+func (m *memStore) Identities() database.IdentityStore        { return memIdentities{m} }
+func (m *memStore) EmailTokens() database.EmailTokenStore     { return memEmailTokens{m} }
+func (m *memStore) Outbox() database.OutboxStore              { return memOutbox{m} }
+func (m *memStore) Throttles() database.ThrottleStore         { return memThrottles{m} }
+func (m *memStore) APIKeys() database.APIKeyStore             { return memAPIKeys{m} }
+
+func (m *memStore) WithTx(ctx context.Context, fn func(tx database.Store) error) error {
+	snapshot := m.clone()
+	if err := fn(m); err != nil {
+		failOn := m.failOn
+		*m = *snapshot
+		m.failOn = failOn
+		return err
+	}
+	return nil
+}
+
+func (m *memStore) clone() *memStore {
+	return &memStore{
+		users:          cloneMap(m.users),
+		goals:          cloneMap(m.goals),
+		progress:       cloneMap(m.progress),
+		archived:       cloneMap(m.archived),
+		groups:         cloneMap(m.groups),
+		members:        cloneMap(m.members),
+		groupGoals:     cloneMap(m.groupGoals),
+		periods:        cloneMap(m.periods),
+		groupProgress:  cloneMap(m.groupProgress),
+		subscriptions:  cloneMap(m.subscriptions),
+		paymentMethods: cloneMap(m.paymentMethods),
+		invoices:       cloneMap(m.invoices),
+		families:       cloneMap(m.families),
+		sessions:       cloneMap(m.sessions),
+		refreshTokens:  cloneMap(m.refreshTokens),
+		securityEvents: cloneMap(m.securityEvents),
+		totp:           cloneMap(m.totp),
+		recoveryCodes:  cloneMap(m.recoveryCodes),
+		auditLogs:      cloneMap(m.auditLogs),
+		challenges:     cloneMap(m.challenges),
+		passkeys:       cloneMap(m.passkeys),
+		oidcStates:     cloneMap(m.oidcStates),
+		identities:     cloneMap(m.identities),
+		emailTokens:    cloneMap(m.emailTokens),
+		outbox:         cloneMap(m.outbox),
+		throttles:      cloneMap(m.throttles),
+		apiKeys:        cloneMap(m.apiKeys),
+		passwords:      cloneMap(m.passwords),
+	}
+}
+
+func (m *memStore) fail(op string) error {
+	if m.failOn == op {
+		return errInjected
+	}
+	return nil
+}
+
+func cloneMap[K comparable, V any](src map[K]V) map[K]V {
+	dst := make(map[K]V, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+func get[K comparable, V any](table map[K]V, id K) (*V, error) {
+	v, ok := table[id]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	return &v, nil
+}
+
+func update[K comparable, V any](table map[K]V, id K, v V) error {
+	if _, ok := table[id]; !ok {
+		return database.ErrNotFound
+	}
+	table[id] = v
+	return nil
+}
+
+func remove[K comparable, V any](table map[K]V, id K) error {
+	if _, ok := table[id]; !ok {
+		return database.ErrNotFound
+	}
+	delete(table, id)
+	return nil
+}
+
+type memUsers struct{ m *memStore }
+
+func (r memUsers) Create(ctx context.Context, u *models.User) error {
+	for _, existing := range r.m.users {
+		if database.NormalizeEmail(existing.Email) == database.NormalizeEmail(u.Email) {
+			return database.ErrDuplicate
+		}
+	}
+	r.m.users[u.ID] = *u
+	return nil
+}
+
+func (r memUsers) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return get(r.m.users, id)
+}
+
+func (r memUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	for _, u := range r.m.users {
+		if database.NormalizeEmail(u.Email) == database.NormalizeEmail(email) {
+			return &u, nil
+		}
+	}
+	return nil, database.ErrNotFound
+}
+
+func (r memUsers) GetProfiles(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]models.UserProfile, error) {
+	profiles := map[uuid.UUID]models.UserProfile{}
+	for _, id := range ids {
+		u, err := r.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		profiles[id] = u.ToProfile()
+	}
+	return profiles, nil
+}
+
+func (r memUsers) Update(ctx context.Context, u *models.User) error {
+	return update(r.m.users, u.ID, *u)
+}
+
+func (r memUsers) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	u, err := r.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.Password = passwordHash
+	r.m.users[id] = *u
+	return nil
+}
+
+func (r memUsers) MarkEmailVerified(ctx context.Context, id uuid.UUID, at time.Time) error {
+	u, err := r.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if u.EmailVerifiedAt == nil {
+		u.EmailVerifiedAt = &at
+		r.m.users[id] = *u
+	}
+	return nil
+}
+
+func (r memUsers) Delete(ctx context.Context, id uuid.UUID) error {
+	return remove(r.m.users, id)
+}
+
+func (r memUsers) CountGroupsJoined(ctx context.Context, id uuid.UUID) (int, error) {
+	count := 0
+	for _, gm := range r.m.members {
+		if gm.UserID == id && gm.IsActive {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r memUsers) CountGroupsAdministered(ctx context.Context, id uuid.UUID) (int, error) {
+	count := 0
+	for _, gm := range r.m.members {
+		if gm.UserID == id && gm.IsActive && gm.IsAdmin() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r memUsers) AddPasswordHistory(ctx context.Context, userID uuid.UUID, passwordHash string, at time.Time, keep int) error {
+	// Build a new slice so snapshots taken by clone are not modified
+	entry := models.PasswordHistoryEntry{UserID: userID, PasswordHash: passwordHash, CreatedAt: at}
+	entries := append([]models.PasswordHistoryEntry{entry}, r.m.passwords[userID]...)
+	r.m.passwords[userID] = entries[:min(len(entries), max(keep, 1))]
+	return nil
+}
+
+func (r memUsers) ListPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]models.PasswordHistoryEntry, error) {
+	entries := r.m.passwords[userID]
+	return append([]models.PasswordHistoryEntry{}, entries[:min(len(entries), limit)]...), nil
+}
+
+type memGoals struct{ m *memStore }
+
+func (r memGoals) Create(ctx context.Context, g *models.Goal) error {
+	r.m.goals[g.ID] = *g
+	return nil
+}
+
+func (r memGoals) GetByID(ctx context.Context, id uuid.UUID) (*models.Goal, error) {
+	return get(r.m.goals, id)
+}
+
+func (r memGoals) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Goal, error) {
+	goals := []models.Goal{}
+	for _, g := range r.m.goals {
+		if g.UserID == userID {
+			goals = append(goals, g)
+		}
+	}
+	sort.Slice(goals, func(i, j int) bool { return goals[i].CreatedAt.After(goals[j].CreatedAt) })
+	return goals, nil
+}
+
+func (r memGoals) CountActiveByUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	count := 0
+	for _, g := range r.m.goals {
+		if g.UserID == userID && (g.Status == models.GoalStatusActive || g.Status == models.GoalStatusInProgress) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r memGoals) Update(ctx context.Context, g *models.Goal) error {
+	return update(r.m.goals, g.ID, *g)
+}
+
+func (r memGoals) Delete(ctx context.Context, id uuid.UUID) error {
+	for pid, p := range r.m.progress {
+		if p.GoalID == id {
+			delete(r.m.progress, pid)
+		}
+	}
+	return remove(r.m.goals, id)
+}
+
+// AddProgress mirrors the schema trigger that keeps current_amount in sync
+func (r memGoals) AddProgress(ctx context.Context, p *models.GoalProgress) error {
+	if err := r.m.fail("Goals.AddProgress"); err != nil {
+		return err
+	}
+	g, err := r.GetByID(ctx, p.GoalID)
+	if err != nil {
+		return err
+	}
+	r.m.progress[p.ID] = *p
+	if !g.IsMeasured() {
+		g.CurrentAmount += p.Amount
+	} else if latest, _ := r.ListProgress(ctx, g.ID, 1); len(latest) > 0 {
+		g.CurrentAmount = latest[0].Amount
+	}
+	r.m.goals[g.ID] = *g
+	return nil
+}
+
+// ArchiveProgress mirrors the delete trigger by resetting current_amount
+func (r memGoals) ArchiveProgress(ctx context.Context, goalID uuid.UUID, archivedAt time.Time) (int, error) {
+	if err := r.m.fail("Goals.ArchiveProgress"); err != nil {
+		return 0, err
+	}
+	g, err := r.GetByID(ctx, goalID)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for id, p := range r.m.progress {
+		if p.GoalID == goalID {
+			r.m.archived[id] = p
+			delete(r.m.progress, id)
+			count++
+		}
+	}
+	g.CurrentAmount = g.InitialAmount()
+	r.m.goals[g.ID] = *g
+	return count, nil
+}
+
+func (r memGoals) ListProgress(ctx context.Context, goalID uuid.UUID, limit int) ([]models.GoalProgress, error) {
+	entries := []models.GoalProgress{}
+	for _, p := range r.m.progress {
+		if p.GoalID == goalID {
+			entries = append(entries, p)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Date.After(entries[j].Date) })
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func (r memGoals) ListProgressSince(ctx context.Context, goalID uuid.UUID, since time.Time) ([]models.GoalProgress, error) {
+	entries := []models.GoalProgress{}
+	for _, p := range r.m.progress {
+		if p.GoalID == goalID && !p.Date.Before(since) {
+			entries = append(entries, p)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Date.Before(entries[j].Date) })
+	return entries, nil
+}
+
+type memGroups struct{ m *memStore }
+
+func (r memGroups) Create(ctx context.Context, g *models.Group) error {
+	r.m.groups[g.ID] = *g
+	return nil
+}
+
+func (r memGroups) GetByID(ctx context.Context, id uuid.UUID) (*models.Group, error) {
+	return get(r.m.groups, id)
+}
+
+func (r memGroups) GetByInviteCode(ctx context.Context, code string) (*models.Group, error) {
+	for _, g := range r.m.groups {
+		if strings.EqualFold(g.InviteCode, code) {
+			return &g, nil
+		}
+	}
+	return nil, database.ErrNotFound
+}
+
+func (r memGroups) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Group, error) {
+	groups := []models.Group{}
+	for _, gm := range r.m.members {
+		if gm.UserID == userID && gm.IsActive {
+			if g, ok := r.m.groups[gm.GroupID]; ok {
+				groups = append(groups, g)
+			}
+		}
+	}
+	return groups, nil
+}
+
+func (r memGroups) Update(ctx context.Context, g *models.Group) error {
+	return update(r.m.groups, g.ID, *g)
+}
+
+func (r memGroups) Delete(ctx context.Context, id uuid.UUID) error {
+	for mid, gm := range r.m.members {
+		if gm.GroupID == id {
+			delete(r.m.members, mid)
+		}
+	}
+	for gid, g := range r.m.groupGoals {
+		if g.GroupID == id {
+			r.DeleteGoal(ctx, gid)
+		}
+	}
+	return remove(r.m.groups, id)
+}
+
+func (r memGroups) AddMember(ctx context.Context, gm *models.GroupMember) error {
+	if _, err := r.GetMember(ctx, gm.GroupID, gm.UserID); err == nil {
+		return database.ErrDuplicate
+	}
+	r.m.members[gm.ID] = *gm
+	return nil
+}
+
+func (r memGroups) GetMember(ctx context.Context, groupID, userID uuid.UUID) (*models.GroupMember, error) {
+	for _, gm := range r.m.members {
+		if gm.GroupID == groupID && gm.UserID == userID {
+			return &gm, nil
+		}
+	}
+	return nil, database.ErrNotFound
+}
+
+func (r memGroups) ListMembers(ctx context.Context, groupID uuid.UUID) ([]models.GroupMember, error) {
+	members := []models.GroupMember{}
+	for _, gm := range r.m.members {
+		if gm.GroupID == groupID && gm.IsActive {
+			members = append(members, gm)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].JoinedAt.Before(members[j].JoinedAt) })
+	return members, nil
+}
+
+func (r memGroups) CountMembers(ctx context.Context, groupID uuid.UUID) (int, error) {
+	members, err := r.ListMembers(ctx, groupID)
+	return len(members), err
+}
+
+func (r memGroups) UpdateMember(ctx context.Context, gm *models.GroupMember) error {
+	return update(r.m.members, gm.ID, *gm)
+}
+
+func (r memGroups) CreateGoal(ctx context.Context, g *models.GroupGoal) error {
+	r.m.groupGoals[g.ID] = *g
+	return nil
+}
+
+func (r memGroups) GetGoal(ctx context.Context, id uuid.UUID) (*models.GroupGoal, error) {
+	return get(r.m.groupGoals, id)
+}
+
+func (r memGroups) ListGoals(ctx context.Context, groupID uuid.UUID) ([]models.GroupGoal, error) {
+	goals := []models.GroupGoal{}
+	for _, g := range r.m.groupGoals {
+		if g.GroupID == groupID {
+			goals = append(goals, g)
+		}
+	}
+	return goals, nil
+}
+
+func (r memGroups) ListActiveGoals(ctx context.Context) ([]models.GroupGoal, error) {
+	goals := []models.GroupGoal{}
+	for _, g := range r.m.groupGoals {
+		if g.IsActive {
+			goals = append(goals, g)
+		}
+	}
+	return goals, nil
+}
+
+func (r memGroups) UpdateGoal(ctx context.Context, g *models.GroupGoal) error {
+	return update(r.m.groupGoals, g.ID, *g)
+}
+
+func (r memGroups) DeleteGoal(ctx context.Context, id uuid.UUID) error {
+	for pid, p := range r.m.periods {
+		if p.GroupGoalID == id {
+			for gpid, gp := range r.m.groupProgress {
+				if gp.GroupGoalPeriodID == pid {
+					delete(r.m.groupProgress, gpid)
+				}
+			}
+			delete(r.m.periods, pid)
+		}
+	}
+	return remove(r.m.groupGoals, id)
+}
+
+func (r memGroups) CreatePeriod(ctx context.Context, p *models.GroupGoalPeriod) error {
+	r.m.periods[p.ID] = *p
+	return nil
+}
+
+func (r memGroups) GetActivePeriod(ctx context.Context, groupGoalID uuid.UUID) (*models.GroupGoalPeriod, error) {
+	var active *models.GroupGoalPeriod
+	for _, p := range r.m.periods {
+		if p.GroupGoalID == groupGoalID && p.IsActive && (active == nil || p.StartDate.After(active.StartDate)) {
+			p := p
+			active = &p
+		}
+	}
+	if active == nil {
+		return nil, database.ErrNotFound
+	}
+	return active, nil
+}
+
+func (r memGroups) ListExpiredPeriods(ctx context.Context, now time.Time) ([]models.GroupGoalPeriod, error) {
+	periods := []models.GroupGoalPeriod{}
+	for _, p := range r.m.periods {
+		if p.IsActive && p.EndDate.Before(now) {
+			periods = append(periods, p)
+		}
+	}
+	return periods, nil
+}
+
+func (r memGroups) DeactivatePeriod(ctx context.Context, id uuid.UUID) error {
+	p, err := get(r.m.periods, id)
+	if err != nil {
+		return err
+	}
+	p.IsActive = false
+	r.m.periods[id] = *p
+	return nil
+}
+
+func (r memGroups) CreateProgress(ctx context.Context, p *models.GroupGoalProgress) error {
+	if _, err := r.GetProgress(ctx, p.GroupGoalPeriodID, p.UserID); err == nil {
+		return database.ErrDuplicate
+	}
+	r.m.groupProgress[p.ID] = *p
+	return nil
+}
+
+func (r memGroups) GetProgress(ctx context.Context, periodID, userID uuid.UUID) (*models.GroupGoalProgress, error) {
+	for _, p := range r.m.groupProgress {
+		if p.GroupGoalPeriodID == periodID && p.UserID == userID {
+			return &p, nil
+		}
+	}
+	return nil, database.ErrNotFound
+}
+
+func (r memGroups) ListProgress(ctx context.Context, periodID uuid.UUID) ([]models.GroupGoalProgress, error) {
+	rows := []models.GroupGoalProgress{}
+	for _, p := range r.m.groupProgress {
+		if p.GroupGoalPeriodID == periodID {
+			rows = append(rows, p)
+		}
+	}
+	return rows, nil
+}
+
+func (r memGroups) UpdateProgress(ctx context.Context, p *models.GroupGoalProgress) error {
+	if err := r.m.fail("Groups.UpdateProgress"); err != nil {
+		return err
+	}
+	return update(r.m.groupProgress, p.ID, *p)
+}
+
+type memSubscriptions struct{ m *memStore }
+
+func (r memSubscriptions) Create(ctx context.Context, s *models.Subscription) error {
+	if err := r.m.fail("Subscriptions.Create"); err != nil {
+		return err
+	}
+	r.m.subscriptions[s.ID] = *s
+	return nil
+}
+
+func (r memSubscriptions) find(match func(models.Subscription) bool) (*models.Subscription, error) {
+	for _, s := range r.m.subscriptions {
+		if match(s) {
+			return &s, nil
+		}
+	}
+	return nil, database.ErrNotFound
+}
+
+func (r memSubscriptions) GetByUser(ctx context.Context, userID uuid.UUID) (*models.Subscription, error) {
+	return r.find(func(s models.Subscription) bool { return s.UserID == userID })
+}
+
+func (r memSubscriptions) GetByStripeSubscriptionID(ctx context.Context, stripeID string) (*models.Subscription, error) {
+	return r.find(func(s models.Subscription) bool {
+		return s.StripeSubscriptionID != nil && *s.StripeSubscriptionID == stripeID
+	})
+}
+
+func (r memSubscriptions) GetByStripeCustomerID(ctx context.Context, customerID string) (*models.Subscription, error) {
+	return r.find(func(s models.Subscription) bool {
+		return s.StripeCustomerID != nil && *s.StripeCustomerID == customerID
+	})
+}
+
+func (r memSubscriptions) ListLapsed(ctx context.Context, now time.Time) ([]models.Subscription, error) {
+	subs := []models.Subscription{}
+	for _, s := range r.m.subscriptions {
+		switch s.Status {
+		case models.SubscriptionStatusTrial, models.SubscriptionStatusActive, models.SubscriptionStatusCanceled:
+			if s.CurrentPeriodEnd != nil && s.CurrentPeriodEnd.Before(now) {
+				subs = append(subs, s)
+			}
+		}
+	}
+	return subs, nil
+}
+
+func (r memSubscriptions) Update(ctx context.Context, s *models.Subscription) error {
+	return update(r.m.subscriptions, s.ID, *s)
+}
+
+func (r memSubscriptions) CreatePaymentMethod(ctx context.Context, pm *models.PaymentMethod) error {
+	r.m.paymentMethods[pm.ID] = *pm
+	return nil
+}
+
+func (r memSubscriptions) GetPaymentMethod(ctx context.Context, userID, id uuid.UUID) (*models.PaymentMethod, error) {
+	pm, err := get(r.m.paymentMethods, id)
+	if err != nil || pm.UserID != userID {
+		return nil, database.ErrNotFound
+	}
+	return pm, nil
+}
+
+func (r memSubscriptions) ListPaymentMethods(ctx context.Context, userID uuid.UUID) ([]models.PaymentMethod, error) {
+	methods := []models.PaymentMethod{}
+	for _, pm := range r.m.paymentMethods {
+		if pm.UserID == userID {
+			methods = append(methods, pm)
+		}
+	}
+	return methods, nil
+}
+
+func (r memSubscriptions) DeletePaymentMethod(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := r.GetPaymentMethod(ctx, userID, id); err != nil {
+		return err
+	}
+	delete(r.m.paymentMethods, id)
+	return nil
+}
+
+func (r memSubscriptions) SetDefaultPaymentMethod(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := r.GetPaymentMethod(ctx, userID, id); err != nil {
+		return err
+	}
+	for pid, pm := range r.m.paymentMethods {
+		if pm.UserID == userID {
+			pm.IsDefault = pid == id
+			r.m.paymentMethods[pid] = pm
+		}
+	}
+	return nil
+}
+
+func (r memSubscriptions) CreateInvoice(ctx context.Context, inv *models.Invoice) error {
+	r.m.invoices[inv.ID] = *inv
+	return nil
+}
+
+func (r memSubscriptions) GetInvoice(ctx context.Context, userID, id uuid.UUID) (*models.Invoice, error) {
+	inv, err := get(r.m.invoices, id)
+	if err != nil || inv.UserID != userID {
+		return nil, database.ErrNotFound
+	}
+	return inv, nil
+}
+
+func (r memSubscriptions) GetInvoiceByStripeID(ctx context.Context, stripeInvoiceID string) (*models.Invoice, error) {
+	for _, inv := range r.m.invoices {
+		if inv.StripeInvoiceID == stripeInvoiceID {
+			return &inv, nil
+		}
+	}
+	return nil, database.ErrNotFound
+}
+
+func (r memSubscriptions) ListInvoices(ctx context.Context, userID uuid.UUID) ([]models.Invoice, error) {
+	invoices := []models.Invoice{}
+	for _, inv := range r.m.invoices {
+		if inv.UserID == userID {
+			invoices = append(invoices, inv)
+		}
+	}
+	return invoices, nil
+}
+
+func (r memSubscriptions) UpdateInvoice(ctx context.Context, inv *models.Invoice) error {
+	return update(r.m.invoices, inv.ID, *inv)
+}
+
+type memTokens struct{ m *memStore }
+
+func (r memTokens) CreateFamily(ctx context.Context, f *models.RefreshTokenFamily) error {
+	r.m.families[f.ID] = *f
+	return nil
+}
+
+func (r memTokens) GetFamily(ctx context.Context, id uuid.UUID) (*models.RefreshTokenFamily, error) {
+	return get(r.m.families, id)
+}
+
+func (r memTokens) ExtendFamily(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	f, err := get(r.m.families, id)
+	if err != nil {
+		return err
+	}
+	if expiresAt.After(f.ExpiresAt) {
+		f.ExpiresAt = expiresAt
+	}
+	r.m.families[id] = *f
+	return nil
+}
+
+func (r memTokens) RevokeFamily(ctx context.Context, id uuid.UUID, reason string, at time.Time) error {
+	f, err := get(r.m.families, id)
+	if err != nil {
+		return err
+	}
+	if f.RevokedAt == nil {
+		f.RevokedAt, f.RevokeReason = &at, &reason
+	}
+	r.m.families[id] = *f
+	return nil
+}
+
+func (r memTokens) RevokeUserFamilies(ctx context.Context, userID uuid.UUID, reason string, at time.Time) error {
+	for id, f := range r.m.families {
+		if f.UserID == userID && f.RevokedAt == nil {
+			f.RevokedAt, f.RevokeReason = &at, &reason
+			r.m.families[id] = f
+		}
+	}
+	return nil
+}
+
+func (r memTokens) DeleteExpiredFamilies(ctx context.Context, now time.Time, limit int) (int, error) {
+	removed := 0
+	for id, f := range r.m.families {
+		if removed == limit {
+			break
+		}
+		if !f.ExpiresAt.After(now) {
+			delete(r.m.families, id)
+			delete(r.m.sessions, id)
+			for jti, t := range r.m.refreshTokens {
+				if t.FamilyID == id {
+					delete(r.m.refreshTokens, jti)
+				}
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (r memTokens) CreateSession(ctx context.Context, s *models.Session) error {
+	r.m.sessions[s.ID] = *s
+	return nil
+}
+
+func (r memTokens) ListSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]models.Session, error) {
+	sessions := []models.Session{}
+	for _, s := range r.m.sessions {
+		f := r.m.families[s.ID]
+		if s.UserID == userID && f.RevokedAt == nil && f.ExpiresAt.After(now) {
+			s.ExpiresAt = f.ExpiresAt
+			sessions = append(sessions, s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+	return sessions, nil
+}
+
+func (r memTokens) TouchSession(ctx context.Context, id uuid.UUID, client models.ClientInfo, at time.Time) error {
+	if s, ok := r.m.sessions[id]; ok {
+		s.UserAgent, s.IPAddress, s.LastUsedAt = client.UserAgent, client.IPAddress, at
+		r.m.sessions[id] = s
+	}
+	return nil
+}
+
+func (r memTokens) CreateRefreshToken(ctx context.Context, t *models.RefreshToken) error {
+	r.m.refreshTokens[t.ID] = *t
+	return nil
+}
+
+func (r memTokens) GetRefreshToken(ctx context.Context, jti string) (*models.RefreshToken, error) {
+	return get(r.m.refreshTokens, jti)
+}
+
+func (r memTokens) UseRefreshToken(ctx context.Context, jti, replacedBy string, at time.Time) error {
+	t, err := get(r.m.refreshTokens, jti)
+	if err != nil || t.UsedAt != nil {
+		return database.ErrNotFound
+	}
+	t.UsedAt, t.ReplacedBy = &at, &replacedBy
+	r.m.refreshTokens[jti] = *t
+	return nil
+}
+
+func (r memTokens) CreateSecurityEvent(ctx context.Context, e *models.SecurityEvent) error {
+	r.m.securityEvents[e.ID] = *e
+	return nil
+}
+
+type memRecoveryCode struct {
+	userID uuid.UUID
+	hash   string
+	usedAt *time.Time
+}
+
+type memMFA struct{ m *memStore }
+
+func (r memMFA) GetTOTP(ctx context.Context, userID uuid.UUID) (*models.TOTPCredential, error) {
+	return get(r.m.totp, userID)
+}
+
+func (r memMFA) SaveTOTP(ctx context.Context, c *models.TOTPCredential) error {
+	r.m.totp[c.UserID] = *c
+	return nil
+}
+
+func (r memMFA) ConfirmTOTP(ctx context.Context, userID uuid.UUID, step int64, at time.Time) error {
+	c, ok := r.m.totp[userID]
+	if !ok || c.ConfirmedAt != nil {
+		return database.ErrNotFound
+	}
+	c.ConfirmedAt, c.LastUsedStep = &at, step
+	r.m.totp[userID] = c
+	return nil
+}
+
+func (r memMFA) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	c, ok := r.m.totp[userID]
+	if !ok || c.ConfirmedAt == nil || c.LastUsedStep >= step {
+		return database.ErrNotFound
+	}
+	c.LastUsedStep = step
+	r.m.totp[userID] = c
+	return nil
+}
+
+func (r memMFA) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	r.ReplaceRecoveryCodes(ctx, userID, nil, time.Time{})
+	return remove(r.m.totp, userID)
+}
+
+func (r memMFA) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, hashes []string, at time.Time) error {
+	for id, c := range r.m.recoveryCodes {
+		if c.userID == userID {
+			delete(r.m.recoveryCodes, id)
+		}
+	}
+	for _, hash := range hashes {
+		r.m.recoveryCodes[uuid.New()] = memRecoveryCode{userID: userID, hash: hash}
+	}
+	return nil
+}
+
+func (r memMFA) UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string, at time.Time) error {
+	for id, c := range r.m.recoveryCodes {
+		if c.userID == userID && c.hash == hash && c.usedAt == nil {
+			c.usedAt = &at
+			r.m.recoveryCodes[id] = c
+			return nil
+		}
+	}
+	return database.ErrNotFound
+}
+
+func (r memMFA) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	n := 0
+	for _, c := range r.m.recoveryCodes {
+		if c.userID == userID && c.usedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+type memAudit struct{ m *memStore }
+
+func (r memAudit) Create(ctx context.Context, e *models.AuditLog) error {
+	r.m.auditLogs[e.ID] = *e
+	return nil
+}
+
+func (r memAudit) ListByUser(ctx context.Context, userID uuid.UUID, limit int) ([]models.AuditLog, error) {
+	entries := []models.AuditLog{}
+	for _, e := range r.m.auditLogs {
+		if e.EntityType == models.AuditEntityUser && e.EntityID == userID.String() {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].CreatedAt.After(entries[j].CreatedAt) })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+type memWebAuthn struct{ m *memStore }
+
+func (r memWebAuthn) CreateChallenge(ctx context.Context, c *models.WebAuthnChallenge) error {
+	r.m.challenges[c.ID] = *c
+	return nil
+}
+
+func (r memWebAuthn) TakeChallenge(ctx context.Context, id uuid.UUID, ceremony string, now time.Time) (*models.WebAuthnChallenge, error) {
+	c, ok := r.m.challenges[id]
+	if !ok || c.Ceremony != ceremony || !c.ExpiresAt.After(now) {
+		return nil, database.ErrNotFound
+	}
+	delete(r.m.challenges, id)
+	return &c, nil
+}
+
+func (r memWebAuthn) CreateCredential(ctx context.Context, c *models.WebAuthnCredential) error {
+	if _, err := r.GetCredential(ctx, c.CredentialID); err == nil {
+		return database.ErrDuplicate
+	}
+	r.m.passkeys[c.ID] = *c
+	return nil
+}
+
+func (r memWebAuthn) GetCredential(ctx context.Context, credentialID []byte) (*models.WebAuthnCredential, error) {
+	for _, c := range r.m.passkeys {
+		if string(c.CredentialID) == string(credentialID) {
+			return &c, nil
+		}
+	}
+	return nil, database.ErrNotFound
+}
+
+func (r memWebAuthn) ListCredentials(ctx context.Context, userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	creds := []models.WebAuthnCredential{}
+	for _, c := range r.m.passkeys {
+		if c.UserID == userID {
+			creds = append(creds, c)
+		}
+	}
+	sort.Slice(creds, func(i, j int) bool { return creds[i].CreatedAt.Before(creds[j].CreatedAt) })
+	return creds, nil
+}
+
+func (r memWebAuthn) UseCredential(ctx context.Context, id uuid.UUID, signCount uint32, at time.Time) error {
+	c, ok := r.m.passkeys[id]
+	if !ok {
+		return database.ErrNotFound
+	}
+	c.SignCount, c.LastUsedAt = signCount, &at
+	r.m.passkeys[id] = c
+	return nil
+}
+
+func (r memWebAuthn) RenameCredential(ctx context.Context, userID, id uuid.UUID, name string) error {
+	c, ok := r.m.passkeys[id]
+	if !ok || c.UserID != userID {
+		return database.ErrNotFound
+	}
+	c.Name = name
+	r.m.passkeys[id] = c
+	return nil
+}
+
+func (r memWebAuthn) DeleteCredential(ctx context.Context, userID, id uuid.UUID) error {
+	c, ok := r.m.passkeys[id]
+	if !ok || c.UserID != userID {
+		return database.ErrNotFound
+	}
+	delete(r.m.passkeys, id)
+	return nil
+}
+
+type memIdentities struct{ m *memStore }
+
+func (r memIdentities) CreateState(ctx context.Context, s *models.OIDCState) error {
+	r.m.oidcStates[s.StateHash] = *s
+	return nil
+}
+
+func (r memIdentities) TakeState(ctx context.Context, stateHash string, now time.Time) (*models.OIDCState, error) {
+	s, ok := r.m.oidcStates[stateHash]
+	if !ok || !s.ExpiresAt.After(now) {
+		return nil, database.ErrNotFound
+	}
+	delete(r.m.oidcStates, stateHash)
+	return &s, nil
+}
+
+func (r memIdentities) CreateIdentity(ctx context.Context, i *models.UserIdentity) error {
+	for _, existing := range r.m.identities {
+		if existing.Provider == i.Provider && (existing.Subject == i.Subject || existing.UserID == i.UserID) {
+			return database.ErrDuplicate
+		}
+	}
+	r.m.identities[i.ID] = *i
+	return nil
+}
+
+func (r memIdentities) GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	for _, i := range r.m.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return &i, nil
+		}
+	}
+	return nil, database.ErrNotFound
+}
+
+func (r memIdentities) ListIdentities(ctx context.Context, userID uuid.UUID) ([]models.UserIdentity, error) {
+	identities := []models.UserIdentity{}
+	for _, i := range r.m.identities {
+		if i.UserID == userID {
+			identities = append(identities, i)
+		}
+	}
+	sort.Slice(identities, func(a, b int) bool { return identities[a].CreatedAt.Before(identities[b].CreatedAt) })
+	return identities, nil
+}
+
+func (r memIdentities) UseIdentity(ctx context.Context, id uuid.UUID, email string, at time.Time) error {
+	i, ok := r.m.identities[id]
+	if !ok {
+		return database.ErrNotFound
+	}
+	i.Email, i.LastUsedAt = email, &at
+	r.m.identities[id] = i
+	return nil
+}
+
+func (r memIdentities) DeleteIdentity(ctx context.Context, userID, id uuid.UUID) error {
+	i, ok := r.m.identities[id]
+	if !ok || i.UserID != userID {
+		return database.ErrNotFound
+	}
+	delete(r.m.identities, id)
+	return nil
+}
+
+type memEmailTokens struct{ m *memStore }
+
+func (r memEmailTokens) CreateEmailToken(ctx context.Context, t *models.EmailToken) error {
+	r.m.emailTokens[t.ID] = *t
+	return nil
+}
+
+func (r memEmailTokens) TakeEmailToken(ctx context.Context, purpose models.EmailTokenPurpose, tokenHash string, now time.Time) (*models.EmailToken, error) {
+	for id, t := range r.m.emailTokens {
+		if t.TokenHash != tokenHash || t.Purpose != purpose || t.UsedAt != nil || !t.ExpiresAt.After(now) {
+			continue
+		}
+		t.UsedAt = &now
+		r.m.emailTokens[id] = t
+		return &t, nil
+	}
+	return nil, database.ErrNotFound
+}
+
+func (r memEmailTokens) RevokeEmailTokens(ctx context.Context, userID uuid.UUID, purpose models.EmailTokenPurpose, at time.Time) error {
+	for id, t := range r.m.emailTokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &at
+			r.m.emailTokens[id] = t
+		}
+	}
+	return nil
+}
+
+type memOutbox struct{ m *memStore }
+
+func (r memOutbox) EnqueueEmail(ctx context.Context, e *models.OutboxEmail) error {
+	if err := r.m.fail("Outbox.EnqueueEmail"); err != nil {
+		return err
+	}
+	r.m.outbox[e.ID] = *e
+	return nil
+}
+
+func (r memOutbox) ClaimEmails(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.OutboxEmail, error) {
+	var due []models.OutboxEmail
+	for _, e := range r.m.outbox {
+		if e.Status == models.OutboxPending && !e.NextAttemptAt.After(now) {
+			due = append(due, e)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for i := range due {
+		due[i].NextAttemptAt = leaseUntil
+		r.m.outbox[due[i].ID] = due[i]
+	}
+	return due, nil
+}
+
+func (r memOutbox) DeleteEmail(ctx context.Context, id uuid.UUID) error {
+	if _, ok := r.m.outbox[id]; !ok {
+		return database.ErrNotFound
+	}
+	delete(r.m.outbox, id)
+	return nil
+}
+
+func (r memOutbox) RetryEmail(ctx context.Context, id uuid.UUID, attempts int, nextAttemptAt time.Time, lastError string) error {
+	e, ok := r.m.outbox[id]
+	if !ok {
+		return database.ErrNotFound
+	}
+	e.Attempts, e.NextAttemptAt, e.LastError = attempts, nextAttemptAt, &lastError
+	r.m.outbox[id] = e
+	return nil
+}
+
+func (r memOutbox) FailEmail(ctx context.Context, id uuid.UUID, attempts int, lastError string) error {
+	e, ok := r.m.outbox[id]
+	if !ok {
+		return database.ErrNotFound
+	}
+	e.Status, e.Attempts, e.LastError = models.OutboxFailed, attempts, &lastError
+	r.m.outbox[id] = e
+	return nil
+}
+
+type memThrottles struct{ m *memStore }
+
+func throttleKey(scope models.ThrottleScope, subject string) string {
+	return string(scope) + ":" + subject
+}
+
+func (r memThrottles) GetThrottle(ctx context.Context, scope models.ThrottleScope, subject string) (*models.LoginThrottle, error) {
+	return get(r.m.throttles, throttleKey(scope, subject))
+}
+
+func (r memThrottles) RecordFailure(ctx context.Context, scope models.ThrottleScope, subject string, now time.Time, window time.Duration) (*models.LoginThrottle, error) {
+	key := throttleKey(scope, subject)
+	t, ok := r.m.throttles[key]
+	if !ok {
+		t = models.LoginThrottle{Scope: scope, Subject: subject}
+	}
+	if !t.LastFailureAt.After(now.Add(-window)) {
+		t.Failures = 0
+	}
+	t.Failures++
+	t.LastFailureAt = now
+	r.m.throttles[key] = t
+	return &t, nil
+}
+
+func (r memThrottles) LockThrottle(ctx context.Context, scope models.ThrottleScope, subject string, until time.Time) error {
+	key := throttleKey(scope, subject)
+	t, ok := r.m.throttles[key]
+	if !ok {
+		return database.ErrNotFound
+	}
+	t.LockedUntil = &until
+	r.m.throttles[key] = t
+	return nil
+}
+
+func (r memThrottles) ClearThrottle(ctx context.Context, scope models.ThrottleScope, subject string) error {
+	delete(r.m.throttles, throttleKey(scope, subject))
+	return nil
+}
+
+func (r memThrottles) DeleteStaleThrottles(ctx context.Context, before time.Time, limit int) (int, error) {
+	n := 0
+	for key, t := range r.m.throttles {
+		if n < limit && !t.LastFailureAt.After(before) && (t.LockedUntil == nil || !t.LockedUntil.After(before)) {
+			delete(r.m.throttles, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+type memAPIKeys struct{ m *memStore }
+
+func (r memAPIKeys) CreateAPIKey(ctx context.Context, k *models.APIKey) error {
+	if _, err := r.GetAPIKeyByHash(ctx, k.KeyHash); err == nil {
+		return database.ErrDuplicate
+	}
+	r.m.apiKeys[k.ID] = *k
+	return nil
+}
+
+func (r memAPIKeys) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	for _, k := range r.m.apiKeys {
+		if k.KeyHash == hash {
+			return &k, nil
+		}
+	}
+	return nil, database.ErrNotFound
+}
+
+func (r memAPIKeys) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	for _, k := range r.m.apiKeys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (r memAPIKeys) CountAPIKeys(ctx context.Context, userID uuid.UUID) (int, error) {
+	keys, _ := r.ListAPIKeys(ctx, userID)
+	return len(keys), nil
+}
+
+func (r memAPIKeys) TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error {
+	k, ok := r.m.apiKeys[id]
+	if !ok {
+		return database.ErrNotFound
+	}
+	k.LastUsedAt = &at
+	r.m.apiKeys[id] = k
+	return nil
+}
+
+func (r memAPIKeys) DeleteAPIKey(ctx context.Context, userID, id uuid.UUID) error {
+	k, ok := r.m.apiKeys[id]
+	if !ok || k.UserID != userID {
+		return database.ErrNotFound
+	}
+	delete(r.m.apiKeys, id)
+	return nil
+}
+
+func (r memAPIKeys) DeleteUserAPIKeys(ctx context.Context, userID uuid.UUID) (int, error) {
+	n := 0
+	for id, k := range r.m.apiKeys {
+		if k.UserID == userID {
+			delete(r.m.apiKeys, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+var _ database.Store = (*memStore)(nil)
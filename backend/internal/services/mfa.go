@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/database"
+	"chainforge/internal/models"
+)
+
+const (
+	// totpIssuer names the account in authenticator apps
+	totpIssuer = "ChainForge"
+
+	// recoveryCodeCount is how many recovery codes a user gets at a time
+	recoveryCodeCount = 10
+)
+
+// invalidMFACode is returned for every second factor that is rejected
+func invalidMFACode() error {
+	return newError(ErrInvalidCredentials, "invalid authentication code")
+}
+
+// CompleteMFALogin finishes a sign-in started by Login with a TOTP code or
+// one of the user's recovery codes. Wrong codes are throttled like wrong
+// passwords, per email and per IP.
+func (s *UserService) CompleteMFALogin(ctx context.Context, mfaToken, code string, client models.ClientInfo) (*models.User, *auth.TokenPair, error) {
+	expired := newError(ErrInvalidCredentials, "sign-in has expired, please sign in again")
+	claims, err := s.tokens.ValidateMFAToken(mfaToken)
+	if err != nil {
+		return nil, nil, expired
+	}
+	revoked, err := s.revocations.IsRevoked(ctx, claims)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked {
+		return nil, nil, expired
+	}
+
+	user, err := s.store.Users().GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, nil, expired
+		}
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return nil, nil, newError(ErrForbidden, "this account has been deactivated")
+	}
+
+	now := s.now().UTC()
+	email := database.NormalizeEmail(user.Email)
+	if err := s.checkLoginThrottle(ctx, email, client.IPAddress, now); err != nil {
+		return nil, nil, err
+	}
+	if err := s.verifySecondFactor(ctx, user.ID, code, client); err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			if err := s.loginFailed(ctx, email, user, client, now); !errors.Is(err, ErrInvalidCredentials) {
+				return nil, nil, err
+			}
+		}
+		return nil, nil, err
+	}
+	if err := s.store.Throttles().ClearThrottle(ctx, models.ThrottleEmail, email); err != nil {
+		return nil, nil, err
+	}
+
+	// The challenge is single use
+	if err := s.revokeToken(ctx, claims); err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.startSession(ctx, s.store, user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// GetMFAStatus reports whether a user has two-factor authentication enabled
+func (s *UserService) GetMFAStatus(ctx context.Context, userID uuid.UUID) (*models.MFAStatus, error) {
+	status := &models.MFAStatus{}
+	cred, err := s.store.MFA().GetTOTP(ctx, userID)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && cred.ConfirmedAt == nil) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	status.Enabled, status.EnabledAt = true, cred.ConfirmedAt
+	status.RecoveryCodesRemaining, err = s.store.MFA().CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// BeginTOTPEnrollment creates a new authenticator secret for a user. It
+// protects nothing until confirmed with a code from the authenticator.
+func (s *UserService) BeginTOTPEnrollment(ctx context.Context, userID uuid.UUID) (*models.TOTPEnrollment, error) {
+	user, err := s.store.Users().GetByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, "user")
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		existing, err := tx.MFA().GetTOTP(ctx, userID)
+		if err == nil && existing.ConfirmedAt != nil {
+			return newError(ErrConflict, "two-factor authentication is already enabled")
+		}
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return err
+		}
+		return tx.MFA().SaveTOTP(ctx, models.NewTOTPCredential(userID, secret))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(secret, totpIssuer, user.Email),
+	}, nil
+}
+
+// ConfirmTOTPEnrollment turns two-factor authentication on once the user
+// proves their authenticator works, and returns their recovery codes
+func (s *UserService) ConfirmTOTPEnrollment(ctx context.Context, userID uuid.UUID, code string, client models.ClientInfo) ([]string, error) {
+	cred, err := s.store.MFA().GetTOTP(ctx, userID)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, newError(ErrInvalidInput, "start enrolling an authenticator first")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cred.ConfirmedAt != nil {
+		return nil, newError(ErrConflict, "two-factor authentication is already enabled")
+	}
+
+	step, ok := auth.ValidateTOTP(cred.Secret, code, s.now())
+	if !ok {
+		return nil, invalidMFACode()
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		if err := tx.MFA().ConfirmTOTP(ctx, userID, step, now); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				return newError(ErrConflict, "two-factor authentication is already enabled")
+			}
+			return err
+		}
+		if err := tx.MFA().ReplaceRecoveryCodes(ctx, userID, hashes, now); err != nil {
+			return err
+		}
+		return tx.Audit().Create(ctx, models.NewUserAuditLog(userID, models.AuditMFAEnabled, `{"method":"totp"}`, client))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableMFA turns two-factor authentication off after checking the user's
+// password and a current code
+func (s *UserService) DisableMFA(ctx context.Context, userID uuid.UUID, req models.DisableMFARequest, client models.ClientInfo) error {
+	user, err := s.store.Users().GetByID(ctx, userID)
+	if err != nil {
+		return notFound(err, "user")
+	}
+
+	return s.throttleSecondFactor(ctx, userID, client, func() error {
+		if _, err := s.passwords.Verify(req.Password, user.Password); err != nil {
+			return newError(ErrInvalidCredentials, "password is incorrect")
+		}
+		return s.store.WithTx(ctx, func(tx database.Store) error {
+			if err := s.verifySecondFactorTx(ctx, tx, userID, req.Code, client); err != nil {
+				return err
+			}
+			if err := tx.MFA().DeleteTOTP(ctx, userID); err != nil {
+				return err
+			}
+			return tx.Audit().Create(ctx, models.NewUserAuditLog(userID, models.AuditMFADisabled, `{"method":"totp"}`, client))
+		})
+	})
+}
+
+// RegenerateRecoveryCodes replaces a user's recovery codes after checking a
+// current code
+func (s *UserService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string, client models.ClientInfo) ([]string, error) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.throttleSecondFactor(ctx, userID, client, func() error {
+		return s.store.WithTx(ctx, func(tx database.Store) error {
+			if err := s.verifySecondFactorTx(ctx, tx, userID, code, client); err != nil {
+				return err
+			}
+			if err := tx.MFA().ReplaceRecoveryCodes(ctx, userID, hashes, s.now().UTC()); err != nil {
+				return err
+			}
+			return tx.Audit().Create(ctx, models.NewUserAuditLog(userID, models.AuditRecoveryCodesGenerated, fmt.Sprintf(`{"recovery_codes":%d}`, len(codes)), client))
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// mfaEnabled reports whether a user must give a second factor to sign in
+func (s *UserService) mfaEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	cred, err := s.store.MFA().GetTOTP(ctx, userID)
+	if errors.Is(err, database.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return cred.ConfirmedAt != nil, nil
+}
+
+// throttleSecondFactor runs check, which verifies a signed-in user's second
+// factor, unless too many earlier checks failed. Failures are recorded
+// outside check's transaction so rolling it back keeps them, and
+// MFALockAfter of them lock the user's checks for LockDuration.
+func (s *UserService) throttleSecondFactor(ctx context.Context, userID uuid.UUID, client models.ClientInfo, check func() error) error {
+	now := s.now().UTC()
+	subject := userID.String()
+	t, err := s.getThrottle(ctx, models.ThrottleMFA, subject)
+	if err != nil {
+		return err
+	}
+	if t != nil && t.Locked(now) {
+		wait := t.LockedUntil.Sub(now)
+		return tooManyAttempts(wait, "two-factor checks are locked after too many failed attempts; try again in %s", retryIn(wait))
+	}
+
+	err = check()
+	if err == nil {
+		if t == nil {
+			return nil
+		}
+		return s.store.Throttles().ClearThrottle(ctx, models.ThrottleMFA, subject)
+	}
+	if !errors.Is(err, ErrInvalidCredentials) {
+		return err
+	}
+
+	lockErr := s.store.WithTx(ctx, func(tx database.Store) error {
+		t, err := tx.Throttles().RecordFailure(ctx, models.ThrottleMFA, subject, now, s.throttle.Window)
+		if err != nil {
+			return err
+		}
+		if t.Failures < s.throttle.MFALockAfter || t.Locked(now) {
+			return nil
+		}
+		if err := tx.Throttles().LockThrottle(ctx, models.ThrottleMFA, subject, now.Add(s.throttle.LockDuration)); err != nil {
+			return err
+		}
+		log.Printf("Security: locked two-factor checks of user %s after %d failed attempts", userID, t.Failures)
+		return tx.Audit().Create(ctx, models.NewUserAuditLog(userID, models.AuditMFALocked, fmt.Sprintf(`{"failures":%d}`, t.Failures), client))
+	})
+	if lockErr != nil {
+		return lockErr
+	}
+	return err
+}
+
+// verifySecondFactor checks a TOTP or recovery code in its own transaction
+func (s *UserService) verifySecondFactor(ctx context.Context, userID uuid.UUID, code string, client models.ClientInfo) error {
+	return s.store.WithTx(ctx, func(tx database.Store) error {
+		return s.verifySecondFactorTx(ctx, tx, userID, code, client)
+	})
+}
+
+// verifySecondFactorTx accepts a TOTP code that has not been used before or
+// an unused recovery code, which is then spent
+func (s *UserService) verifySecondFactorTx(ctx context.Context, tx database.Store, userID uuid.UUID, code string, client models.ClientInfo) error {
+	cred, err := tx.MFA().GetTOTP(ctx, userID)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && cred.ConfirmedAt == nil) {
+		return newError(ErrInvalidInput, "two-factor authentication is not enabled")
+	}
+	if err != nil {
+		return err
+	}
+
+	if step, ok := auth.ValidateTOTP(cred.Secret, code, s.now()); ok {
+		if err := tx.MFA().UseTOTPStep(ctx, userID, step); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				// Replayed code
+				return invalidMFACode()
+			}
+			return err
+		}
+		return nil
+	}
+
+	if err := tx.MFA().UseRecoveryCode(ctx, userID, auth.HashRecoveryCode(code), s.now().UTC()); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return invalidMFACode()
+		}
+		return err
+	}
+	return tx.Audit().Create(ctx, models.NewUserAuditLog(userID, models.AuditRecoveryCodeUsed, "", client))
+}
+
+// newRecoveryCodes generates recovery codes and their stored hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
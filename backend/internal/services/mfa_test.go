@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/models"
+)
+
+// enrollTOTP turns on two-factor authentication for a user and returns the
+// secret and recovery codes
+func enrollTOTP(t *testing.T, svc *UserService, userID uuid.UUID) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	enrollment, err := svc.BeginTOTPEnrollment(ctx, userID)
+	if err != nil {
+		t.Fatalf("BeginTOTPEnrollment: %v", err)
+	}
+	code, _ := auth.TOTPCode(enrollment.Secret, time.Now())
+	codes, err := svc.ConfirmTOTPEnrollment(ctx, userID, code, testClient)
+	if err != nil {
+		t.Fatalf("ConfirmTOTPEnrollment: %v", err)
+	}
+	return enrollment.Secret, codes
+}
+
+func TestLoginRequiresSecondFactorOnceEnrolled(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+	login := models.LoginRequest{Email: "ada@example.com", Password: "Correct-Horse-42"}
+
+	user, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	// A pending enrollment does not affect sign-in
+	if _, err := svc.BeginTOTPEnrollment(ctx, user.ID); err != nil {
+		t.Fatalf("BeginTOTPEnrollment: %v", err)
+	}
+	if result, err := svc.Login(ctx, login, testClient); err != nil || result.Tokens == nil {
+		t.Fatalf("Login with pending enrollment = %+v, %v", result, err)
+	}
+
+	secret, recovery := enrollTOTP(t, svc, user.ID)
+	if len(recovery) != recoveryCodeCount {
+		t.Fatalf("recovery codes = %d, want %d", len(recovery), recoveryCodeCount)
+	}
+
+	result, err := svc.Login(ctx, login, testClient)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if result.Tokens != nil || result.MFAToken == "" {
+		t.Fatal("Login issued tokens without a second factor")
+	}
+	if _, err := svc.tokens.ValidateAccessToken(result.MFAToken); err == nil {
+		t.Fatal("mfa_pending token accepted as an access token")
+	}
+
+	// The enrollment code's step is spent, so use the next one
+	code, _ := auth.TOTPCode(secret, time.Now().Add(auth.TOTPPeriod))
+	_, tokens, err := svc.CompleteMFALogin(ctx, result.MFAToken, code, testClient)
+	if err != nil {
+		t.Fatalf("CompleteMFALogin: %v", err)
+	}
+	if tokens == nil || tokens.AccessToken == "" {
+		t.Fatal("no tokens after the second factor")
+	}
+
+	// Neither the challenge nor the code can be used twice
+	if _, _, err := svc.CompleteMFALogin(ctx, result.MFAToken, code, testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("reused challenge: err = %v, want ErrInvalidCredentials", err)
+	}
+	again, _ := svc.Login(ctx, login, testClient)
+	if _, _, err := svc.CompleteMFALogin(ctx, again.MFAToken, code, testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("replayed code: err = %v, want ErrInvalidCredentials", err)
+	}
+
+	// A recovery code works once
+	if _, _, err := svc.CompleteMFALogin(ctx, again.MFAToken, recovery[0], testClient); err != nil {
+		t.Fatalf("CompleteMFALogin with recovery code: %v", err)
+	}
+	third, _ := svc.Login(ctx, login, testClient)
+	if _, _, err := svc.CompleteMFALogin(ctx, third.MFAToken, recovery[0], testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("reused recovery code: err = %v, want ErrInvalidCredentials", err)
+	}
+
+	status, err := svc.GetMFAStatus(ctx, user.ID)
+	if err != nil || !status.Enabled || status.RecoveryCodesRemaining != recoveryCodeCount-1 {
+		t.Errorf("status = %+v, %v", status, err)
+	}
+}
+
+func TestWrongSignInCodesLockTheEmail(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+	login := models.LoginRequest{Email: "ada@example.com", Password: "Correct-Horse-42"}
+
+	user, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	secret, _ := enrollTOTP(t, svc, user.ID)
+
+	result, err := svc.Login(ctx, login, testClient)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	for i := 0; i < testLoginThrottle.LockAfter; i++ {
+		ageThrottles(store, testLoginThrottle.MaxBackoff)
+		if _, _, err := svc.CompleteMFALogin(ctx, result.MFAToken, "000000", testClient); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+
+	code, _ := auth.TOTPCode(secret, time.Now().Add(auth.TOTPPeriod))
+	if _, _, err := svc.CompleteMFALogin(ctx, result.MFAToken, code, testClient); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("right code after %d failures: err = %v, want ErrTooManyAttempts", testLoginThrottle.LockAfter, err)
+	}
+
+	// The counts are stored, so a restarted service still refuses the password
+	svc = newTestUserService(store)
+	if _, err := svc.Login(ctx, login, testClient); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("Login after %d wrong codes: err = %v, want ErrTooManyAttempts", testLoginThrottle.LockAfter, err)
+	}
+
+	entries, err := store.Audit().ListByUser(ctx, user.ID, 20)
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	failed, locked := 0, false
+	for _, e := range entries {
+		switch e.Action {
+		case models.AuditLoginFailed:
+			failed++
+		case models.AuditAccountLocked:
+			locked = true
+		}
+	}
+	if failed != testLoginThrottle.LockAfter || !locked {
+		t.Errorf("audited %d failed sign-ins and locked %v, want %d and true", failed, locked, testLoginThrottle.LockAfter)
+	}
+}
+
+func TestSecondFactorChecksLockWhileSignedIn(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+
+	user, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	secret, recovery := enrollTOTP(t, svc, user.ID)
+
+	// Wrong codes and wrong passwords both count
+	if _, err := svc.RegenerateRecoveryCodes(ctx, user.ID, "000000", testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong code: err = %v, want ErrInvalidCredentials", err)
+	}
+	disable := models.DisableMFARequest{Password: "wrong", Code: "000000"}
+	if err := svc.DisableMFA(ctx, user.ID, disable, testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong password: err = %v, want ErrInvalidCredentials", err)
+	}
+
+	// A success in between starts the count again
+	if _, err := svc.RegenerateRecoveryCodes(ctx, user.ID, recovery[0], testClient); err != nil {
+		t.Fatalf("RegenerateRecoveryCodes: %v", err)
+	}
+	for i := 0; i < testLoginThrottle.MFALockAfter; i++ {
+		if _, err := svc.RegenerateRecoveryCodes(ctx, user.ID, "000000", testClient); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+
+	// Locked, even for a restarted service and the right code
+	svc = newTestUserService(store)
+	code, _ := auth.TOTPCode(secret, time.Now().Add(auth.TOTPPeriod))
+	disable = models.DisableMFARequest{Password: "Correct-Horse-42", Code: code}
+	if wait := retryAfter(t, svc.DisableMFA(ctx, user.ID, disable, testClient)); wait <= 0 || wait > testLoginThrottle.LockDuration {
+		t.Errorf("Retry-After = %v, want up to %v", wait, testLoginThrottle.LockDuration)
+	}
+
+	entries, err := store.Audit().ListByUser(ctx, user.ID, 20)
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	locks := 0
+	for _, e := range entries {
+		if e.Action == models.AuditMFALocked {
+			locks++
+		}
+	}
+	if locks != 1 {
+		t.Errorf("%d mfa.locked audit entries, want 1", locks)
+	}
+
+	// The lock runs out
+	ageThrottles(store, testLoginThrottle.LockDuration)
+	if err := svc.DisableMFA(ctx, user.ID, disable, testClient); err != nil {
+		t.Fatalf("DisableMFA after the lock: %v", err)
+	}
+}
+
+func TestDisableMFAIsAudited(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+
+	user, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	secret, _ := enrollTOTP(t, svc, user.ID)
+	code, _ := auth.TOTPCode(secret, time.Now().Add(auth.TOTPPeriod))
+
+	err = svc.DisableMFA(ctx, user.ID, models.DisableMFARequest{Password: "wrong", Code: code}, testClient)
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong password: err = %v, want ErrInvalidCredentials", err)
+	}
+	if err := svc.DisableMFA(ctx, user.ID, models.DisableMFARequest{Password: "Correct-Horse-42", Code: code}, testClient); err != nil {
+		t.Fatalf("DisableMFA: %v", err)
+	}
+
+	result, err := svc.Login(ctx, models.LoginRequest{Email: "ada@example.com", Password: "Correct-Horse-42"}, testClient)
+	if err != nil || result.Tokens == nil {
+		t.Fatalf("Login after disabling = %+v, %v", result, err)
+	}
+	if len(store.recoveryCodes) != 0 {
+		t.Error("recovery codes kept after disabling")
+	}
+
+	entries, err := store.Audit().ListByUser(ctx, user.ID, 10)
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	actions := map[models.AuditAction]bool{}
+	for _, e := range entries {
+		actions[e.Action] = true
+		if e.IPAddress == nil || *e.IPAddress != testClient.IPAddress {
+			t.Errorf("%s logged from %v, want %q", e.Action, e.IPAddress, testClient.IPAddress)
+		}
+	}
+	if !actions[models.AuditMFAEnabled] || !actions[models.AuditMFADisabled] {
+		t.Errorf("audit actions = %v, want enable and disable", actions)
+	}
+}
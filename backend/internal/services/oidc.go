@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/database"
+	"chainforge/internal/models"
+)
+
+// oidcStateTTL is how long a user has to finish signing in at a provider
+const oidcStateTTL = 10 * time.Minute
+
+// OIDCProviders returns the names of the providers users can sign in with
+func (s *UserService) OIDCProviders() []string {
+	names := make([]string, 0, len(s.oidc))
+	for name := range s.oidc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BeginOIDCLogin starts signing in with a provider
+func (s *UserService) BeginOIDCLogin(ctx context.Context, provider string) (*models.OIDCAuthorizationResponse, error) {
+	return s.beginOIDC(ctx, provider, nil)
+}
+
+// FinishOIDCLogin signs in the user the provider redirected back with.
+// An identity seen before signs its user in. Otherwise the provider must
+// have verified the email address: an account with that email gets the
+// identity linked to it, and if there is none an account is created.
+func (s *UserService) FinishOIDCLogin(ctx context.Context, provider string, req models.OIDCCallbackRequest, client models.ClientInfo) (*LoginResult, error) {
+	state, identity, err := s.finishOIDC(ctx, provider, req)
+	if err != nil {
+		return nil, err
+	}
+	if state.UserID != nil {
+		// Started by BeginOIDCLink and must be finished by FinishOIDCLink
+		return nil, oidcExpired()
+	}
+
+	var user *models.User
+	linked, err := s.store.Identities().GetIdentity(ctx, provider, identity.Subject)
+	switch {
+	case err == nil:
+		if user, err = s.store.Users().GetByID(ctx, linked.UserID); err != nil {
+			return nil, notFound(err, "user")
+		}
+	case errors.Is(err, database.ErrNotFound):
+		if identity.Email == "" || !identity.EmailVerified {
+			return nil, newError(ErrForbidden, "your %s account has no verified email address", provider)
+		}
+		user, err = s.store.Users().GetByEmail(ctx, identity.Email)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+	if user != nil && !user.IsActive {
+		return nil, newError(ErrForbidden, "this account has been deactivated")
+	}
+
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		now := s.now().UTC()
+		if linked != nil {
+			return tx.Identities().UseIdentity(ctx, linked.ID, identity.Email, now)
+		}
+		// The provider verified the address, so the account's is too
+		if user == nil {
+			user = models.NewUser(database.NormalizeEmail(identity.Email), "",
+				strings.TrimSpace(identity.GivenName), strings.TrimSpace(identity.FamilyName), "UTC")
+			user.EmailVerifiedAt = &now
+			if err := tx.Users().Create(ctx, user); err != nil {
+				if errors.Is(err, database.ErrDuplicate) {
+					return newError(ErrConflict, "an account with this email already exists")
+				}
+				return err
+			}
+			if err := tx.Subscriptions().Create(ctx, models.NewSubscription(user.ID, models.PlanFree)); err != nil {
+				return err
+			}
+		} else if !user.EmailVerified() {
+			if err := s.markEmailVerified(ctx, tx, user.ID, now, client); err != nil {
+				return err
+			}
+			user.EmailVerifiedAt = &now
+		}
+		_, err := s.linkIdentity(ctx, tx, user.ID, provider, identity, client)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.signIn(ctx, user, client)
+}
+
+// BeginOIDCLink starts linking an identity at a provider to a signed-in
+// user
+func (s *UserService) BeginOIDCLink(ctx context.Context, userID uuid.UUID, provider string) (*models.OIDCAuthorizationResponse, error) {
+	identities, err := s.store.Identities().ListIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range identities {
+		if i.Provider == provider {
+			return nil, newError(ErrConflict, "a %s account is already linked", provider)
+		}
+	}
+	return s.beginOIDC(ctx, provider, &userID)
+}
+
+// FinishOIDCLink links the identity the provider redirected back with to
+// the user who started linking it. The user has proved they control both
+// accounts, so the provider's email does not need to match or be verified.
+func (s *UserService) FinishOIDCLink(ctx context.Context, userID uuid.UUID, provider string, req models.OIDCCallbackRequest, client models.ClientInfo) (*models.UserIdentity, error) {
+	state, identity, err := s.finishOIDC(ctx, provider, req)
+	if err != nil {
+		return nil, err
+	}
+	if state.UserID == nil || *state.UserID != userID {
+		return nil, oidcExpired()
+	}
+
+	existing, err := s.store.Identities().GetIdentity(ctx, provider, identity.Subject)
+	switch {
+	case err == nil && existing.UserID == userID:
+		return nil, newError(ErrConflict, "this %s account is already linked", provider)
+	case err == nil:
+		return nil, newError(ErrConflict, "this %s account is linked to another account", provider)
+	case !errors.Is(err, database.ErrNotFound):
+		return nil, err
+	}
+
+	var linked *models.UserIdentity
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		var err error
+		linked, err = s.linkIdentity(ctx, tx, userID, provider, identity, client)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return linked, nil
+}
+
+// ListIdentities returns a user's linked identities
+func (s *UserService) ListIdentities(ctx context.Context, userID uuid.UUID) ([]models.UserIdentity, error) {
+	return s.store.Identities().ListIdentities(ctx, userID)
+}
+
+// UnlinkIdentity removes one of a user's linked identities, unless it is
+// the only way left to sign in to the account
+func (s *UserService) UnlinkIdentity(ctx context.Context, userID, id uuid.UUID, client models.ClientInfo) error {
+	return s.store.WithTx(ctx, func(tx database.Store) error {
+		user, err := tx.Users().GetByID(ctx, userID)
+		if err != nil {
+			return notFound(err, "user")
+		}
+		identities, err := tx.Identities().ListIdentities(ctx, userID)
+		if err != nil {
+			return err
+		}
+		var identity *models.UserIdentity
+		for i := range identities {
+			if identities[i].ID == id {
+				identity = &identities[i]
+			}
+		}
+		if identity == nil {
+			return newError(ErrNotFound, "identity not found")
+		}
+
+		if !user.HasPassword() && len(identities) == 1 {
+			passkeys, err := tx.WebAuthn().ListCredentials(ctx, userID)
+			if err != nil {
+				return err
+			}
+			if len(passkeys) == 0 {
+				return newError(ErrConflict, "set a password or add a passkey before unlinking your only sign-in method")
+			}
+		}
+
+		if err := tx.Identities().DeleteIdentity(ctx, userID, id); err != nil {
+			return notFound(err, "identity")
+		}
+		details := fmt.Sprintf(`{"identity_id":%q,"provider":%q}`, id, identity.Provider)
+		return tx.Audit().Create(ctx, models.NewUserAuditLog(userID, models.AuditIdentityUnlinked, details, client))
+	})
+}
+
+// beginOIDC stores the state of a new sign-in at provider and returns
+// where to send the browser. userID is set when linking an identity.
+func (s *UserService) beginOIDC(ctx context.Context, provider string, userID *uuid.UUID) (*models.OIDCAuthorizationResponse, error) {
+	p, err := s.oidcProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := p.Authorize(ctx)
+	if err != nil {
+		log.Printf("OIDC: failed to start sign-in with %s: %v", provider, err)
+		return nil, oidcUnavailable(provider)
+	}
+
+	now := s.now().UTC()
+	state := &models.OIDCState{
+		StateHash:    hashOIDCState(authorization.State),
+		Provider:     provider,
+		UserID:       userID,
+		Nonce:        authorization.Nonce,
+		CodeVerifier: authorization.CodeVerifier,
+		ExpiresAt:    now.Add(oidcStateTTL),
+		CreatedAt:    now,
+	}
+	if err := s.store.Identities().CreateState(ctx, state); err != nil {
+		return nil, err
+	}
+	return &models.OIDCAuthorizationResponse{
+		AuthorizationURL: authorization.URL,
+		ExpiresAt:        state.ExpiresAt,
+	}, nil
+}
+
+// finishOIDC spends the state a provider redirected back with and redeems
+// the authorization code for the identity it vouches for
+func (s *UserService) finishOIDC(ctx context.Context, provider string, req models.OIDCCallbackRequest) (*models.OIDCState, *auth.OIDCIdentity, error) {
+	p, err := s.oidcProvider(provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	state, err := s.store.Identities().TakeState(ctx, hashOIDCState(req.State), s.now().UTC())
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, nil, oidcExpired()
+		}
+		return nil, nil, err
+	}
+	if state.Provider != provider {
+		return nil, nil, oidcExpired()
+	}
+
+	identity, err := p.Exchange(ctx, req.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidOIDCResponse) {
+			log.Printf("OIDC: rejected sign-in with %s: %v", provider, err)
+			return nil, nil, newError(ErrInvalidCredentials, "sign-in with %s failed", provider)
+		}
+		log.Printf("OIDC: failed to finish sign-in with %s: %v", provider, err)
+		return nil, nil, oidcUnavailable(provider)
+	}
+	return state, identity, nil
+}
+
+// linkIdentity links a provider account to userID and audits it
+func (s *UserService) linkIdentity(ctx context.Context, tx database.Store, userID uuid.UUID, provider string, identity *auth.OIDCIdentity, client models.ClientInfo) (*models.UserIdentity, error) {
+	linked := models.NewUserIdentity(userID, provider, identity.Subject, identity.Email)
+	linked.LastUsedAt = &linked.CreatedAt
+	if err := tx.Identities().CreateIdentity(ctx, linked); err != nil {
+		if errors.Is(err, database.ErrDuplicate) {
+			return nil, newError(ErrConflict, "the account is already linked to a different %s account", provider)
+		}
+		return nil, err
+	}
+	details := fmt.Sprintf(`{"identity_id":%q,"provider":%q}`, linked.ID, provider)
+	if err := tx.Audit().Create(ctx, models.NewUserAuditLog(userID, models.AuditIdentityLinked, details, client)); err != nil {
+		return nil, err
+	}
+	return linked, nil
+}
+
+func (s *UserService) oidcProvider(name string) (*auth.OIDCProvider, error) {
+	p, ok := s.oidc[name]
+	if !ok {
+		return nil, newError(ErrNotFound, "unknown sign-in provider %q", name)
+	}
+	return p, nil
+}
+
+func oidcExpired() error {
+	return newError(ErrInvalidCredentials, "sign-in has expired, please try again")
+}
+
+func oidcUnavailable(provider string) error {
+	return newError(ErrUnavailable, "sign-in with %s is unavailable, please try again later", provider)
+}
+
+// hashOIDCState returns the stored form of a state parameter
+func hashOIDCState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/auth/oidctest"
+	"chainforge/internal/models"
+)
+
+// newOIDCTestService returns a user service that can sign in with a local
+// issuer configured as "google"
+func newOIDCTestService(t *testing.T, store *memStore) (*UserService, *oidctest.Issuer) {
+	t.Helper()
+	issuer := oidctest.NewIssuer("chainforge-web", "s3cret")
+	t.Cleanup(issuer.Close)
+	provider := auth.NewOIDCProvider(auth.OIDCProviderConfig{
+		Name:         "google",
+		Issuer:       issuer.URL,
+		ClientID:     issuer.ClientID,
+		ClientSecret: issuer.ClientSecret,
+		RedirectURL:  "https://app.chainforge.test/auth/callback",
+	}, nil)
+	svc := NewUserService(store, newTestTokenManager(), auth.NewMemoryRevocationStore(time.Hour), testPasswords, testRelyingParty,
+		[]*auth.OIDCProvider{provider}, testAccountEmails(newMailbox(store)), testLoginThrottle, testPasswordPolicy, time.Monday)
+	return svc, issuer
+}
+
+// approve has the issuer sign user in at the URL the service started with
+func approve(t *testing.T, issuer *oidctest.Issuer, authorization *models.OIDCAuthorizationResponse, user oidctest.User) models.OIDCCallbackRequest {
+	t.Helper()
+	code, state, err := issuer.Approve(authorization.AuthorizationURL, user)
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	return models.OIDCCallbackRequest{Code: code, State: state}
+}
+
+func oidcSignIn(t *testing.T, svc *UserService, issuer *oidctest.Issuer, user oidctest.User) (*LoginResult, error) {
+	t.Helper()
+	authorization, err := svc.BeginOIDCLogin(context.Background(), "google")
+	if err != nil {
+		t.Fatalf("BeginOIDCLogin: %v", err)
+	}
+	return svc.FinishOIDCLogin(context.Background(), "google", approve(t, issuer, authorization, user), testClient)
+}
+
+var googleAda = oidctest.User{
+	Subject:       "110169484474386276334",
+	Email:         "Ada@Example.com",
+	EmailVerified: true,
+	GivenName:     "Ada",
+	FamilyName:    "Lovelace",
+}
+
+func TestOIDCLoginCreatesAccount(t *testing.T) {
+	store := newMemStore()
+	svc, issuer := newOIDCTestService(t, store)
+
+	result, err := oidcSignIn(t, svc, issuer, googleAda)
+	if err != nil {
+		t.Fatalf("FinishOIDCLogin: %v", err)
+	}
+	user := result.User
+	if result.Tokens == nil || user.Email != "ada@example.com" || user.FirstName != "Ada" || user.HasPassword() || !user.EmailVerified() {
+		t.Fatalf("signed in as %+v with %+v", user, result.Tokens)
+	}
+	if _, err := store.Subscriptions().GetByUser(context.Background(), user.ID); err != nil {
+		t.Errorf("subscription of the new account: %v", err)
+	}
+	if !hasAuditEntry(store, user.ID, models.AuditIdentityLinked) {
+		t.Error("linking the identity was not audited")
+	}
+
+	// Signing in again finds the same account by its subject, even after
+	// the email changed at the provider
+	renamed := googleAda
+	renamed.Email, renamed.EmailVerified = "ada@lovelace.dev", false
+	again, err := oidcSignIn(t, svc, issuer, renamed)
+	if err != nil {
+		t.Fatalf("second FinishOIDCLogin: %v", err)
+	}
+	if again.User.ID != user.ID || len(store.users) != 1 {
+		t.Fatalf("second sign-in as %s, %d users; want the first account", again.User.ID, len(store.users))
+	}
+
+	// The account has no password to sign in with
+	if _, err := svc.Login(context.Background(), models.LoginRequest{Email: "ada@example.com"}, testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("password login without a password: err = %v", err)
+	}
+}
+
+func TestOIDCLoginLinksAccountByVerifiedEmail(t *testing.T) {
+	store := newMemStore()
+	svc, issuer := newOIDCTestService(t, store)
+	ctx := context.Background()
+
+	user, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	unverified := googleAda
+	unverified.EmailVerified = false
+	if _, err := oidcSignIn(t, svc, issuer, unverified); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("unverified email: err = %v, want ErrForbidden", err)
+	}
+	if len(store.identities) != 0 {
+		t.Fatal("an identity with an unverified email was linked")
+	}
+
+	// Accounts with two-factor authentication still ask for a code
+	enrollTOTP(t, svc, user.ID)
+	result, err := oidcSignIn(t, svc, issuer, googleAda)
+	if err != nil {
+		t.Fatalf("FinishOIDCLogin: %v", err)
+	}
+	if result.User.ID != user.ID || result.Tokens != nil || result.MFAToken == "" {
+		t.Fatalf("result = %+v, want an MFA challenge for the existing account", result)
+	}
+	identities, _ := svc.ListIdentities(ctx, user.ID)
+	if len(identities) != 1 || identities[0].Provider != "google" {
+		t.Errorf("identities = %+v, want the Google account linked", identities)
+	}
+	if !result.User.EmailVerified() || !hasAuditEntry(store, user.ID, models.AuditEmailVerified) {
+		t.Error("the email the provider verified is not verified for the account")
+	}
+}
+
+func TestOIDCLoginRejectsReplayedState(t *testing.T) {
+	store := newMemStore()
+	svc, issuer := newOIDCTestService(t, store)
+	ctx := context.Background()
+
+	authorization, err := svc.BeginOIDCLogin(ctx, "google")
+	if err != nil {
+		t.Fatalf("BeginOIDCLogin: %v", err)
+	}
+	req := approve(t, issuer, authorization, googleAda)
+	if _, err := svc.FinishOIDCLogin(ctx, "google", req, testClient); err != nil {
+		t.Fatalf("FinishOIDCLogin: %v", err)
+	}
+	if _, err := svc.FinishOIDCLogin(ctx, "google", req, testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("replayed state: err = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := svc.BeginOIDCLogin(ctx, "github"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unknown provider: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestOIDCLinkAndUnlink(t *testing.T) {
+	store := newMemStore()
+	svc, issuer := newOIDCTestService(t, store)
+	ctx := context.Background()
+
+	ada, _, _ := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	grace, _, _ := svc.Register(ctx, registerRequest("grace@example.com"), testClient)
+
+	// The provider account may use any email when linked explicitly
+	work := oidctest.User{Subject: "work-1", Email: "ada@analytical.engine"}
+	authorization, err := svc.BeginOIDCLink(ctx, ada.ID, "google")
+	if err != nil {
+		t.Fatalf("BeginOIDCLink: %v", err)
+	}
+	req := approve(t, issuer, authorization, work)
+	if _, err := svc.FinishOIDCLink(ctx, grace.ID, "google", req, testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("finished by another user: err = %v, want ErrInvalidCredentials", err)
+	}
+
+	authorization, _ = svc.BeginOIDCLink(ctx, ada.ID, "google")
+	identity, err := svc.FinishOIDCLink(ctx, ada.ID, "google", approve(t, issuer, authorization, work), testClient)
+	if err != nil {
+		t.Fatalf("FinishOIDCLink: %v", err)
+	}
+	if identity.Email != work.Email || !hasAuditEntry(store, ada.ID, models.AuditIdentityLinked) {
+		t.Errorf("linked %+v", identity)
+	}
+	if result, err := oidcSignIn(t, svc, issuer, work); err != nil || result.User.ID != ada.ID {
+		t.Fatalf("sign in with linked identity: %v", err)
+	}
+	if _, err := svc.BeginOIDCLink(ctx, ada.ID, "google"); !errors.Is(err, ErrConflict) {
+		t.Fatalf("linking a second Google account: err = %v, want ErrConflict", err)
+	}
+
+	// Grace cannot link the account Ada already has
+	authorization, _ = svc.BeginOIDCLink(ctx, grace.ID, "google")
+	if _, err := svc.FinishOIDCLink(ctx, grace.ID, "google", approve(t, issuer, authorization, work), testClient); !errors.Is(err, ErrConflict) {
+		t.Fatalf("linking another user's identity: err = %v, want ErrConflict", err)
+	}
+
+	if err := svc.UnlinkIdentity(ctx, grace.ID, identity.ID, testClient); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unlinking another user's identity: err = %v, want ErrNotFound", err)
+	}
+	if err := svc.UnlinkIdentity(ctx, ada.ID, identity.ID, testClient); err != nil {
+		t.Fatalf("UnlinkIdentity: %v", err)
+	}
+	if !hasAuditEntry(store, ada.ID, models.AuditIdentityUnlinked) {
+		t.Error("unlinking was not audited")
+	}
+}
+
+func TestUnlinkKeepsLastSignInMethod(t *testing.T) {
+	store := newMemStore()
+	svc, issuer := newOIDCTestService(t, store)
+	ctx := context.Background()
+
+	result, err := oidcSignIn(t, svc, issuer, googleAda)
+	if err != nil {
+		t.Fatalf("FinishOIDCLogin: %v", err)
+	}
+	userID := result.User.ID
+	identities, _ := svc.ListIdentities(ctx, userID)
+
+	if err := svc.UnlinkIdentity(ctx, userID, identities[0].ID, testClient); !errors.Is(err, ErrConflict) {
+		t.Fatalf("unlinking the only sign-in method: err = %v, want ErrConflict", err)
+	}
+
+	// Setting a first password needs no current one
+	req := models.ChangePasswordRequest{NewPassword: "c0rrect-Horse-battery"}
+	if _, _, err := svc.ChangePassword(ctx, userID, req, testClient); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if err := svc.UnlinkIdentity(ctx, userID, identities[0].ID, testClient); err != nil {
+		t.Fatalf("UnlinkIdentity with a password set: %v", err)
+	}
+	if err := svc.UnlinkIdentity(ctx, userID, uuid.New(), testClient); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unlinking an unknown identity: err = %v, want ErrNotFound", err)
+	}
+}
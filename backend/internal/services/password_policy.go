@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/database"
+	"chainforge/internal/models"
+)
+
+// PasswordPolicyConfig sets which passwords users may not reuse and how
+// long owners and admins of groups may keep one
+type PasswordPolicyConfig struct {
+	History     int           // Latest passwords, the current one included, that cannot be reused; 0 allows any
+	AdminMaxAge time.Duration // 0 lets admins keep a password for ever
+}
+
+// checkPasswordReuse fails with ErrInvalidInput if password is the user's
+// current password or one of their latest History passwords
+func (s *UserService) checkPasswordReuse(ctx context.Context, store database.Store, user *models.User, password string) error {
+	if s.policy.History < 1 {
+		return nil
+	}
+	history, err := store.Users().ListPasswordHistory(ctx, user.ID, s.policy.History)
+	if err != nil {
+		return err
+	}
+	hashes := make([]string, 0, len(history)+1)
+	if user.HasPassword() {
+		hashes = append(hashes, user.Password)
+	}
+	for _, h := range history {
+		hashes = append(hashes, h.PasswordHash)
+	}
+	for _, hash := range hashes {
+		// Verify only fails with a mismatch or for hashes in an unknown
+		// format, which cannot match either
+		if _, err := s.passwords.Verify(password, hash); err == nil {
+			return newError(ErrInvalidInput, "choose a password other than your last %d", s.policy.History)
+		}
+	}
+	return nil
+}
+
+// recordPassword adds a password hash the user just set to their history
+func (s *UserService) recordPassword(ctx context.Context, tx database.Store, userID uuid.UUID, hash string, at time.Time) error {
+	return tx.Users().AddPasswordHistory(ctx, userID, hash, at, s.policy.History)
+}
+
+// passwordExpired reports whether user must choose a new password before
+// signing in with it again: they own or administer a group and set their
+// password more than AdminMaxAge ago
+func (s *UserService) passwordExpired(ctx context.Context, user *models.User, now time.Time) (bool, error) {
+	if s.policy.AdminMaxAge <= 0 {
+		return false, nil
+	}
+	history, err := s.store.Users().ListPasswordHistory(ctx, user.ID, 1)
+	if err != nil {
+		return false, err
+	}
+	setAt := user.CreatedAt
+	if len(history) > 0 {
+		setAt = history[0].CreatedAt
+	}
+	if now.Sub(setAt) < s.policy.AdminMaxAge {
+		return false, nil
+	}
+	groups, err := s.store.Users().CountGroupsAdministered(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	return groups > 0, nil
+}
+
+// revokeCredentials signs a user whose password changed out everywhere:
+// their refresh tokens, unused password reset links and API keys stop
+// working. Access tokens are revoked by the caller once tx commits.
+func (s *UserService) revokeCredentials(ctx context.Context, tx database.Store, userID uuid.UUID, reason string, client models.ClientInfo, now time.Time) error {
+	if err := tx.Tokens().RevokeUserFamilies(ctx, userID, reason, now); err != nil {
+		return err
+	}
+	if err := tx.EmailTokens().RevokeEmailTokens(ctx, userID, models.EmailTokenResetPassword, now); err != nil {
+		return err
+	}
+	keys, err := tx.APIKeys().DeleteUserAPIKeys(ctx, userID)
+	if err != nil || keys == 0 {
+		return err
+	}
+	details := fmt.Sprintf(`{"api_keys":%d,"reason":%q}`, keys, reason)
+	return tx.Audit().Create(ctx, models.NewUserAuditLog(userID, models.AuditAPIKeyRevoked, details, client))
+}
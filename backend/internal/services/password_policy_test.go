@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/models"
+)
+
+var testPasswordPolicy = PasswordPolicyConfig{History: 3, AdminMaxAge: 90 * 24 * time.Hour}
+
+// changePassword changes the password of the user registered with
+// registerRequest from current to next
+func changePassword(svc *UserService, userID uuid.UUID, current, next string) error {
+	_, _, err := svc.ChangePassword(context.Background(), userID, models.ChangePasswordRequest{
+		CurrentPassword: current,
+		NewPassword:     next,
+	}, testClient)
+	return err
+}
+
+func TestChangePasswordRejectsRecentPasswords(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+
+	user, _, err := svc.Register(context.Background(), registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	passwords := []string{"Correct-Horse-42", "Battery-Staple-77", "Quiet-Meadow-31", "Amber-Lantern-58"}
+	for i := 1; i < len(passwords); i++ {
+		if err := changePassword(svc, user.ID, passwords[i-1], passwords[i]); err != nil {
+			t.Fatalf("change to %q: %v", passwords[i], err)
+		}
+	}
+
+	// The history holds the current password and the two before it
+	if n := len(store.passwords[user.ID]); n != testPasswordPolicy.History {
+		t.Fatalf("history holds %d passwords, want %d", n, testPasswordPolicy.History)
+	}
+	if err := changePassword(svc, user.ID, "Amber-Lantern-58", "Battery-Staple-77"); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("recent password: err = %v, want ErrInvalidInput", err)
+	}
+	if err := changePassword(svc, user.ID, "Amber-Lantern-58", "Correct-Horse-42"); err != nil {
+		t.Fatalf("password older than the history: %v", err)
+	}
+}
+
+func TestChangePasswordRevokesAPIKeysAndResetLinks(t *testing.T) {
+	store := newMemStore()
+	svc, box := newMailingUserService(store)
+	ctx := context.Background()
+
+	user, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	key := models.NewAPIKey(user.ID, "CI", "cf_abcd", "hash", []models.APIKeyScope{models.ScopeGoalsRead}, nil)
+	store.apiKeys[key.ID] = *key
+	if err := svc.ForgotPassword(ctx, models.ForgotPasswordRequest{Email: "ada@example.com"}, testClient); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	token := linkToken(t, box.last(t, "ada@example.com"), "/auth/reset-password")
+
+	if err := changePassword(svc, user.ID, "Correct-Horse-42", "Battery-Staple-77"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if len(store.apiKeys) != 0 {
+		t.Error("an API key survived the password change")
+	}
+	if !hasAuditEntry(store, user.ID, models.AuditAPIKeyRevoked) || !hasAuditEntry(store, user.ID, models.AuditPasswordChanged) {
+		t.Error("the change was not audited")
+	}
+	err = svc.ResetPassword(ctx, models.ResetPasswordRequest{Token: token, NewPassword: "Quiet-Meadow-31"}, testClient)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("reset link from before the change: err = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestResetPasswordRejectsRecentPasswords(t *testing.T) {
+	store := newMemStore()
+	svc, box := newMailingUserService(store)
+	ctx := context.Background()
+
+	if _, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	svc.ForgotPassword(ctx, models.ForgotPasswordRequest{Email: "ada@example.com"}, testClient)
+	token := linkToken(t, box.last(t, "ada@example.com"), "/auth/reset-password")
+
+	// The rejected password leaves the link usable
+	err := svc.ResetPassword(ctx, models.ResetPasswordRequest{Token: token, NewPassword: "Correct-Horse-42"}, testClient)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("current password: err = %v, want ErrInvalidInput", err)
+	}
+	if err := svc.ResetPassword(ctx, models.ResetPasswordRequest{Token: token, NewPassword: "Battery-Staple-77"}, testClient); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+}
+
+func TestLoginRequiresAdminsToRotatePasswords(t *testing.T) {
+	store := newMemStore()
+	svc, box := newMailingUserService(store)
+	ctx := context.Background()
+	login := models.LoginRequest{Email: "ada@example.com", Password: "Correct-Horse-42"}
+
+	user, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	store.passwords[user.ID][0].CreatedAt = time.Now().Add(-testPasswordPolicy.AdminMaxAge - time.Hour)
+
+	// Members who administer no group keep their password
+	if _, err := svc.Login(ctx, login, testClient); err != nil {
+		t.Fatalf("Login as a member: %v", err)
+	}
+
+	member := models.GroupMember{ID: uuid.New(), GroupID: uuid.New(), UserID: user.ID, Role: models.RoleOwner, IsActive: true}
+	store.members[member.ID] = member
+	if _, err := svc.Login(ctx, login, testClient); !errors.Is(err, ErrPasswordExpired) {
+		t.Fatalf("Login as an owner: err = %v, want ErrPasswordExpired", err)
+	}
+
+	svc.ForgotPassword(ctx, models.ForgotPasswordRequest{Email: "ada@example.com"}, testClient)
+	token := linkToken(t, box.last(t, "ada@example.com"), "/auth/reset-password")
+	if err := svc.ResetPassword(ctx, models.ResetPasswordRequest{Token: token, NewPassword: "Battery-Staple-77"}, testClient); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	login.Password = "Battery-Staple-77"
+	if _, err := svc.Login(ctx, login, testClient); err != nil {
+		t.Fatalf("Login after the reset: %v", err)
+	}
+}
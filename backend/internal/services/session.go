@@ -0,0 +1,324 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/database"
+	"chainforge/internal/models"
+)
+
+// errRefreshReuse signals that a rotated refresh token was presented again
+var errRefreshReuse = errors.New("refresh token reused")
+
+// invalidRefreshToken is returned for every refresh token that cannot be
+// exchanged, so callers learn nothing about why
+func invalidRefreshToken() error {
+	return newError(ErrInvalidCredentials, "invalid or expired refresh token")
+}
+
+// RefreshTokens exchanges a refresh token for a new pair in the same family.
+// Each refresh token works once; presenting one that was already rotated
+// revokes the whole family and records a security event, since either the
+// client or an attacker holds a stolen copy.
+func (s *UserService) RefreshTokens(ctx context.Context, refreshToken string, client models.ClientInfo) (*models.User, *auth.TokenPair, error) {
+	claims, err := s.tokens.ValidateRefreshToken(refreshToken)
+	if err != nil {
+		return nil, nil, invalidRefreshToken()
+	}
+	revoked, err := s.revocations.IsRevoked(ctx, claims)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked {
+		return nil, nil, invalidRefreshToken()
+	}
+
+	var (
+		user   *models.User
+		tokens *auth.TokenPair
+		family *models.RefreshTokenFamily
+	)
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		issued, err := tx.Tokens().GetRefreshToken(ctx, claims.ID)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				return invalidRefreshToken()
+			}
+			return err
+		}
+		family, err = tx.Tokens().GetFamily(ctx, issued.FamilyID)
+		if err != nil {
+			return err
+		}
+		if family.RevokedAt != nil {
+			return invalidRefreshToken()
+		}
+		if issued.UsedAt != nil {
+			return errRefreshReuse
+		}
+
+		user, err = tx.Users().GetByID(ctx, issued.UserID)
+		if err != nil {
+			return err
+		}
+		if !user.IsActive {
+			return newError(ErrForbidden, "this account has been deactivated")
+		}
+
+		tokens, err = s.issueTokens(ctx, tx, user, family.ID)
+		if err != nil {
+			return err
+		}
+		now := s.now().UTC()
+		err = tx.Tokens().UseRefreshToken(ctx, issued.ID, tokens.RefreshClaims.ID, now)
+		if errors.Is(err, database.ErrNotFound) {
+			// Another request rotated the token first
+			return errRefreshReuse
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Tokens().TouchSession(ctx, family.ID, client, now)
+	})
+	if errors.Is(err, errRefreshReuse) {
+		if err := s.handleRefreshReuse(ctx, family, claims.ID); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, invalidRefreshToken()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// Logout ends the session behind the given access token and, if not empty,
+// refresh token. Tokens that are already invalid are ignored.
+func (s *UserService) Logout(ctx context.Context, accessToken, refreshToken string) error {
+	if claims, err := s.tokens.ValidateAccessToken(accessToken); err == nil {
+		if err := s.revokeToken(ctx, claims); err != nil {
+			return err
+		}
+		if err := s.revokeFamily(ctx, claims.FamilyID, models.RevokeReasonLogout); err != nil {
+			return err
+		}
+	}
+	if refreshToken != "" {
+		if claims, err := s.tokens.ValidateRefreshToken(refreshToken); err == nil {
+			if err := s.revokeFamily(ctx, claims.FamilyID, models.RevokeReasonLogout); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ListSessions returns a user's signed-in sessions, marking the one behind
+// the current access token
+func (s *UserService) ListSessions(ctx context.Context, userID, current uuid.UUID) ([]models.Session, error) {
+	sessions, err := s.store.Tokens().ListSessions(ctx, userID, s.now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs one of a user's sessions out
+func (s *UserService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	family, err := s.store.Tokens().GetFamily(ctx, sessionID)
+	if err != nil {
+		return notFound(err, "session")
+	}
+	if family.UserID != userID || family.RevokedAt != nil {
+		return newError(ErrNotFound, "session not found")
+	}
+	return s.revokeFamily(ctx, family.ID, models.RevokeReasonSignedOut)
+}
+
+// RevokeOtherSessions signs out every session of a user except current and
+// returns how many were signed out
+func (s *UserService) RevokeOtherSessions(ctx context.Context, userID, current uuid.UUID) (int, error) {
+	now := s.now().UTC()
+	sessions, err := s.store.Tokens().ListSessions(ctx, userID, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var revoked []models.Session
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		for _, session := range sessions {
+			if session.ID == current {
+				continue
+			}
+			err := tx.Tokens().RevokeFamily(ctx, session.ID, models.RevokeReasonSignedOut, now)
+			if errors.Is(err, database.ErrNotFound) {
+				// Expired and cleaned up since it was listed
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			revoked = append(revoked, session)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, session := range revoked {
+		if err := s.revocations.Revoke(ctx, session.ID.String(), session.ExpiresAt); err != nil {
+			return 0, err
+		}
+	}
+	return len(revoked), nil
+}
+
+// CleanupSessions deletes up to limit token families whose tokens have all expired
+func (s *UserService) CleanupSessions(ctx context.Context, limit int) (int, error) {
+	return s.store.Tokens().DeleteExpiredFamilies(ctx, s.now().UTC(), limit)
+}
+
+// startSession creates a token family and session for a new sign-in from
+// client and issues its first pair
+func (s *UserService) startSession(ctx context.Context, store database.Store, user *models.User, client models.ClientInfo) (*auth.TokenPair, error) {
+	var tokens *auth.TokenPair
+	err := store.WithTx(ctx, func(tx database.Store) error {
+		family := models.NewRefreshTokenFamily(user.ID)
+		family.ExpiresAt = family.CreatedAt
+		if err := tx.Tokens().CreateFamily(ctx, family); err != nil {
+			return err
+		}
+		if err := tx.Tokens().CreateSession(ctx, models.NewSession(family, sessionLabel(client.UserAgent), client)); err != nil {
+			return err
+		}
+
+		var err error
+		tokens, err = s.issueTokens(ctx, tx, user, family.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// issueTokens generates a pair in familyID and records its refresh token
+func (s *UserService) issueTokens(ctx context.Context, tx database.Store, user *models.User, familyID uuid.UUID) (*auth.TokenPair, error) {
+	tokens, err := s.tokens.GenerateTokenPair(user.ID, user.Email, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := tokens.RefreshClaims
+	err = tx.Tokens().CreateRefreshToken(ctx, &models.RefreshToken{
+		ID:        claims.ID,
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Tokens().ExtendFamily(ctx, familyID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// handleRefreshReuse revokes a family whose rotated refresh token came back
+// and records the event
+func (s *UserService) handleRefreshReuse(ctx context.Context, family *models.RefreshTokenFamily, jti string) error {
+	log.Printf("Security: refresh token %s reused; revoking token family %s of user %s", jti, family.ID, family.UserID)
+
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		if err := tx.Tokens().RevokeFamily(ctx, family.ID, models.RevokeReasonReuse, s.now().UTC()); err != nil {
+			return err
+		}
+		details := fmt.Sprintf("refresh token %s was presented after rotation; token family %s revoked", jti, family.ID)
+		return tx.Tokens().CreateSecurityEvent(ctx, models.NewSecurityEvent(family.UserID, models.SecurityEventRefreshTokenReuse, details))
+	})
+	if err != nil {
+		return err
+	}
+	// Access tokens carry the family ID, so this signs the family out at once
+	return s.revocations.Revoke(ctx, family.ID.String(), family.ExpiresAt)
+}
+
+// revokeFamily ends a session: no token in the family can be refreshed or
+// used for access again. Unknown families are ignored.
+func (s *UserService) revokeFamily(ctx context.Context, familyID uuid.UUID, reason string) error {
+	family, err := s.store.Tokens().GetFamily(ctx, familyID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if err := s.store.Tokens().RevokeFamily(ctx, family.ID, reason, s.now().UTC()); err != nil {
+		return err
+	}
+	return s.revocations.Revoke(ctx, family.ID.String(), family.ExpiresAt)
+}
+
+// revokeToken records a single token as revoked until it would have expired anyway
+func (s *UserService) revokeToken(ctx context.Context, claims *auth.Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	return s.revocations.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+}
+
+// sessionLabel names a device from its user agent, such as "Firefox on macOS"
+func sessionLabel(userAgent string) string {
+	browser := firstMatch(userAgent, [][2]string{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"okhttp", "Android app"},
+		{"CFNetwork", "iOS app"},
+	})
+	platform := firstMatch(userAgent, [][2]string{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"CrOS", "ChromeOS"},
+		{"Mac OS X", "macOS"},
+		{"Windows", "Windows"},
+		{"Linux", "Linux"},
+	})
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform + " device"
+	default:
+		return "Unknown device"
+	}
+}
+
+// firstMatch returns the name paired with the first marker found in s
+func firstMatch(s string, markers [][2]string) string {
+	for _, m := range markers {
+		if strings.Contains(s, m[0]) {
+			return m[1]
+		}
+	}
+	return ""
+}
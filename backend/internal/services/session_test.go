@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/models"
+)
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+
+	user, first, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	_, second, err := svc.RefreshTokens(ctx, first.RefreshToken, testClient)
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+	if second.RefreshClaims.FamilyID != first.RefreshClaims.FamilyID {
+		t.Fatal("rotated token left its family")
+	}
+
+	// Replaying the rotated token signs out everything issued from it
+	if _, _, err := svc.RefreshTokens(ctx, first.RefreshToken, testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("reused token: err = %v, want ErrInvalidCredentials", err)
+	}
+	if _, _, err := svc.RefreshTokens(ctx, second.RefreshToken, testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("latest token after reuse: err = %v, want ErrInvalidCredentials", err)
+	}
+	if revoked := accessRevoked(t, svc, second); !revoked {
+		t.Error("access token from the revoked family still works")
+	}
+
+	family := store.families[first.RefreshClaims.FamilyID]
+	if family.RevokedAt == nil || *family.RevokeReason != models.RevokeReasonReuse {
+		t.Errorf("family = %+v, want revoked for reuse", family)
+	}
+	if len(store.securityEvents) != 1 {
+		t.Fatalf("security events = %d, want 1", len(store.securityEvents))
+	}
+	for _, e := range store.securityEvents {
+		if e.UserID != user.ID || e.Type != models.SecurityEventRefreshTokenReuse {
+			t.Errorf("security event = %+v", e)
+		}
+	}
+
+	// Other sign-ins are untouched
+	other, err := svc.Login(ctx, models.LoginRequest{Email: "ada@example.com", Password: "Correct-Horse-42"}, testClient)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if _, _, err := svc.RefreshTokens(ctx, other.Tokens.RefreshToken, testClient); err != nil {
+		t.Fatalf("refresh in another family: %v", err)
+	}
+}
+
+func TestRefreshRejectsUnknownTokens(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+
+	_, tokens, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	delete(store.refreshTokens, tokens.RefreshClaims.ID)
+
+	if _, _, err := svc.RefreshTokens(ctx, tokens.RefreshToken, testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("unrecorded token: err = %v, want ErrInvalidCredentials", err)
+	}
+	if len(store.securityEvents) != 0 {
+		t.Error("an unknown token was treated as reuse")
+	}
+}
+
+func TestLogoutEndsSession(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+
+	_, tokens, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	// Logging out with only the access token is enough to end the session
+	if err := svc.Logout(ctx, tokens.AccessToken, ""); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if !accessRevoked(t, svc, tokens) {
+		t.Error("access token still works after logout")
+	}
+	if _, _, err := svc.RefreshTokens(ctx, tokens.RefreshToken, testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("refresh after logout: err = %v, want ErrInvalidCredentials", err)
+	}
+	if len(store.securityEvents) != 0 {
+		t.Error("logout recorded a security event")
+	}
+}
+
+func TestSessionsRecordDeviceAndSignOutOthers(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+
+	user, laptop, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	phone := models.ClientInfo{
+		UserAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Mobile Safari/537.36",
+		IPAddress: "198.51.100.20",
+	}
+	login, err := svc.Login(ctx, models.LoginRequest{Email: "ada@example.com", Password: "Correct-Horse-42"}, phone)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	phoneTokens := login.Tokens
+
+	// Refreshing from a new address updates the session
+	moved := models.ClientInfo{UserAgent: testClient.UserAgent, IPAddress: "192.0.2.99"}
+	if _, laptop, err = svc.RefreshTokens(ctx, laptop.RefreshToken, moved); err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+
+	current := laptop.RefreshClaims.FamilyID
+	sessions, err := svc.ListSessions(ctx, user.ID, current)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("sessions = %d, want 2", len(sessions))
+	}
+	for _, s := range sessions {
+		switch s.ID {
+		case current:
+			if !s.Current || s.Label != "Safari on macOS" || s.IPAddress != "192.0.2.99" {
+				t.Errorf("laptop session = %+v", s)
+			}
+		case phoneTokens.RefreshClaims.FamilyID:
+			if s.Current || s.Label != "Chrome on Android" || s.IPAddress != phone.IPAddress {
+				t.Errorf("phone session = %+v", s)
+			}
+		default:
+			t.Errorf("unexpected session %s", s.ID)
+		}
+	}
+
+	revoked, err := svc.RevokeOtherSessions(ctx, user.ID, current)
+	if err != nil || revoked != 1 {
+		t.Fatalf("RevokeOtherSessions = %d, %v; want 1", revoked, err)
+	}
+	if !accessRevoked(t, svc, phoneTokens) {
+		t.Error("signed-out session can still use its access token")
+	}
+	if accessRevoked(t, svc, laptop) {
+		t.Error("current session was signed out")
+	}
+	if sessions, _ := svc.ListSessions(ctx, user.ID, current); len(sessions) != 1 || sessions[0].ID != current {
+		t.Errorf("sessions after sign-out = %+v", sessions)
+	}
+}
+
+func TestRevokeSessionChecksOwner(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+
+	ada, adaTokens, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	grace, _, err := svc.Register(ctx, registerRequest("grace@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	session := adaTokens.RefreshClaims.FamilyID
+	if err := svc.RevokeSession(ctx, grace.ID, session); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("revoking another user's session: err = %v, want ErrNotFound", err)
+	}
+	if err := svc.RevokeSession(ctx, ada.ID, session); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if _, _, err := svc.RefreshTokens(ctx, adaTokens.RefreshToken, testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("refresh after revoking: err = %v, want ErrInvalidCredentials", err)
+	}
+	if err := svc.RevokeSession(ctx, ada.ID, session); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("revoking twice: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSessionLabel(t *testing.T) {
+	tests := map[string]string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36 Edg/126.0":      "Edge on Windows",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0":                                                     "Firefox on Linux",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 Safari/604.1": "Safari on iOS",
+		"okhttp/4.12.0": "Android app",
+		"curl/8.5.0":    "Unknown device",
+		"":              "Unknown device",
+	}
+	for userAgent, want := range tests {
+		if got := sessionLabel(userAgent); got != want {
+			t.Errorf("sessionLabel(%q) = %q, want %q", userAgent, got, want)
+		}
+	}
+}
+
+// accessRevoked reports whether the access token of pair has been revoked
+func accessRevoked(t *testing.T, svc *UserService, pair *auth.TokenPair) bool {
+	t.Helper()
+	claims, err := svc.tokens.ValidateAccessToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken: %v", err)
+	}
+	revoked, err := svc.revocations.IsRevoked(context.Background(), claims)
+	if err != nil {
+		t.Fatalf("IsRevoked: %v", err)
+	}
+	return revoked
+}
@@ -0,0 +1,878 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/client"
+	"github.com/stripe/stripe-go/v76/webhook"
+
+	"chainforge/internal/config"
+	"chainforge/internal/database"
+	"chainforge/internal/models"
+)
+
+// trialDays is the length of the one-time premium trial
+const trialDays = 30
+
+// SubscriptionService manages plans, billing and Stripe synchronization.
+// Without a Stripe secret key it runs in local mode: trials work, but
+// anything that needs a payment returns ErrUnavailable.
+type SubscriptionService struct {
+	store  database.Store
+	stripe *client.API
+	cfg    config.StripeConfig
+}
+
+// NewSubscriptionService creates a new subscription service
+func NewSubscriptionService(store database.Store, cfg config.StripeConfig) *SubscriptionService {
+	s := &SubscriptionService{store: store, cfg: cfg}
+	if cfg.SecretKey != "" {
+		s.stripe = client.New(cfg.SecretKey, nil)
+	}
+	return s
+}
+
+// BillingEnabled reports whether Stripe is configured
+func (s *SubscriptionService) BillingEnabled() bool {
+	return s.stripe != nil
+}
+
+// GetSubscription returns a user's subscription, creating a free one for
+// users that predate subscriptions
+func (s *SubscriptionService) GetSubscription(ctx context.Context, userID uuid.UUID) (*models.Subscription, error) {
+	sub, err := s.store.Subscriptions().GetByUser(ctx, userID)
+	if !errors.Is(err, database.ErrNotFound) {
+		return sub, err
+	}
+
+	sub = models.NewSubscription(userID, models.PlanFree)
+	if err := s.store.Subscriptions().Create(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// IsPremium reports whether the user currently has premium features
+func (s *SubscriptionService) IsPremium(ctx context.Context, userID uuid.UUID) (bool, error) {
+	sub, err := s.GetSubscription(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return effectivePlan(sub).Plan == models.PlanPremium, nil
+}
+
+// GetSummary returns the subscription with its features and usage
+func (s *SubscriptionService) GetSummary(ctx context.Context, userID uuid.UUID) (*models.SubscriptionSummary, error) {
+	sub, err := s.GetSubscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.usage(ctx, sub)
+	if err != nil {
+		return nil, err
+	}
+	return &models.SubscriptionSummary{
+		Subscription: *sub,
+		Features:     effectivePlan(sub).GetFeatures(),
+		Usage:        usage,
+	}, nil
+}
+
+// GetFeatures returns the features the user is currently entitled to
+func (s *SubscriptionService) GetFeatures(ctx context.Context, userID uuid.UUID) (*models.SubscriptionFeatures, error) {
+	sub, err := s.GetSubscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	features := effectivePlan(sub).GetFeatures()
+	return &features, nil
+}
+
+// GetUsage returns the user's usage for the current billing period
+func (s *SubscriptionService) GetUsage(ctx context.Context, userID uuid.UUID) (*models.SubscriptionUsage, error) {
+	sub, err := s.GetSubscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.usage(ctx, sub)
+}
+
+// CreateSubscription upgrades a user with a verified email address to
+// premium. Users who never had a trial get one; with Stripe configured a
+// Stripe subscription is created.
+func (s *SubscriptionService) CreateSubscription(ctx context.Context, userID uuid.UUID, req models.CreateSubscriptionRequest) (*models.Subscription, error) {
+	switch req.Plan {
+	case models.PlanPremium:
+	case models.PlanFree:
+		return s.CancelSubscription(ctx, userID, models.CancelSubscriptionRequest{CancelAtPeriodEnd: true})
+	default:
+		return nil, newError(ErrInvalidInput, "unknown plan %q", req.Plan)
+	}
+	if err := requireVerifiedEmail(ctx, s.store, userID, "upgrade to premium"); err != nil {
+		return nil, err
+	}
+
+	sub, err := s.GetSubscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if effectivePlan(sub).Plan == models.PlanPremium && sub.Status != models.SubscriptionStatusCanceled {
+		return nil, newError(ErrConflict, "you already have a premium subscription")
+	}
+	eligibleForTrial := sub.TrialStartDate == nil
+
+	if s.stripe == nil {
+		if !eligibleForTrial {
+			return nil, newError(ErrUnavailable, "billing is not configured")
+		}
+		startTrial(sub, time.Now().UTC())
+		if err := s.store.Subscriptions().Update(ctx, sub); err != nil {
+			return nil, err
+		}
+		return sub, nil
+	}
+
+	if s.cfg.PriceIDMonthly == "" {
+		return nil, newError(ErrUnavailable, "billing is not configured")
+	}
+	if err := s.ensureCustomer(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	params := &stripe.SubscriptionParams{
+		Customer: sub.StripeCustomerID,
+		Items: []*stripe.SubscriptionItemsParams{
+			{Price: stripe.String(s.cfg.PriceIDMonthly)},
+		},
+		PromotionCode: req.PromotionCode,
+	}
+	params.Context = ctx
+	params.AddMetadata("user_id", userID.String())
+	if req.PaymentMethodID != nil {
+		if _, err := s.AddPaymentMethod(ctx, userID, models.AddPaymentMethodRequest{
+			PaymentMethodID: *req.PaymentMethodID,
+			SetAsDefault:    true,
+		}); err != nil {
+			return nil, err
+		}
+		params.DefaultPaymentMethod = req.PaymentMethodID
+	}
+	if eligibleForTrial {
+		params.TrialPeriodDays = stripe.Int64(trialDays)
+	}
+
+	remote, err := s.stripe.Subscriptions.New(params)
+	if err != nil {
+		return nil, paymentError(err)
+	}
+
+	// Reload in case AddPaymentMethod touched the row
+	sub, err = s.store.Subscriptions().GetByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	applyStripeSubscription(sub, remote)
+	if err := s.store.Subscriptions().Update(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// UpdateSubscription changes the user's plan
+func (s *SubscriptionService) UpdateSubscription(ctx context.Context, userID uuid.UUID, req models.UpdateSubscriptionRequest) (*models.Subscription, error) {
+	if req.Plan == nil {
+		return s.GetSubscription(ctx, userID)
+	}
+	return s.CreateSubscription(ctx, userID, models.CreateSubscriptionRequest{Plan: *req.Plan})
+}
+
+// CancelSubscription cancels premium either immediately or at the end of
+// the current period. Premium features stay available until then.
+func (s *SubscriptionService) CancelSubscription(ctx context.Context, userID uuid.UUID, req models.CancelSubscriptionRequest) (*models.Subscription, error) {
+	sub, err := s.GetSubscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Plan != models.PlanPremium || sub.Status == models.SubscriptionStatusCanceled {
+		return nil, newError(ErrConflict, "there is no active premium subscription to cancel")
+	}
+
+	if sub.StripeSubscriptionID != nil {
+		if s.stripe == nil {
+			return nil, newError(ErrUnavailable, "billing is not configured")
+		}
+
+		var remote *stripe.Subscription
+		if req.CancelAtPeriodEnd {
+			params := &stripe.SubscriptionParams{CancelAtPeriodEnd: stripe.Bool(true)}
+			params.Context = ctx
+			remote, err = s.stripe.Subscriptions.Update(*sub.StripeSubscriptionID, params)
+		} else {
+			params := &stripe.SubscriptionCancelParams{}
+			params.Context = ctx
+			remote, err = s.stripe.Subscriptions.Cancel(*sub.StripeSubscriptionID, params)
+		}
+		if err != nil {
+			return nil, paymentError(err)
+		}
+		applyStripeSubscription(sub, remote)
+	}
+
+	now := time.Now().UTC()
+	sub.Status = models.SubscriptionStatusCanceled
+	sub.CanceledAt = &now
+	if !req.CancelAtPeriodEnd || sub.CurrentPeriodEnd == nil {
+		downgrade(sub)
+	}
+	sub.UpdatedAt = now
+
+	if err := s.store.Subscriptions().Update(ctx, sub); err != nil {
+		return nil, err
+	}
+	if req.CancellationReason != nil {
+		log.Printf("Subscription %s canceled: %s", sub.ID, *req.CancellationReason)
+	}
+	return sub, nil
+}
+
+// ResumeSubscription undoes a cancellation scheduled for the end of the
+// period, as long as the period has not ended yet
+func (s *SubscriptionService) ResumeSubscription(ctx context.Context, userID uuid.UUID) (*models.Subscription, error) {
+	sub, err := s.GetSubscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if sub.Plan != models.PlanPremium || sub.Status != models.SubscriptionStatusCanceled ||
+		sub.CurrentPeriodEnd == nil || !now.Before(*sub.CurrentPeriodEnd) {
+		return nil, newError(ErrConflict, "there is no canceled subscription to resume")
+	}
+
+	if sub.StripeSubscriptionID != nil {
+		if s.stripe == nil {
+			return nil, newError(ErrUnavailable, "billing is not configured")
+		}
+		params := &stripe.SubscriptionParams{CancelAtPeriodEnd: stripe.Bool(false)}
+		params.Context = ctx
+		remote, err := s.stripe.Subscriptions.Update(*sub.StripeSubscriptionID, params)
+		if err != nil {
+			return nil, paymentError(err)
+		}
+		applyStripeSubscription(sub, remote)
+	} else {
+		sub.Status = models.SubscriptionStatusActive
+		if sub.TrialEndDate != nil && now.Before(*sub.TrialEndDate) {
+			sub.Status = models.SubscriptionStatusTrial
+		}
+	}
+
+	sub.CanceledAt = nil
+	sub.UpdatedAt = now
+	if err := s.store.Subscriptions().Update(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// CreatePaymentIntent starts a one-time Stripe payment for the user
+func (s *SubscriptionService) CreatePaymentIntent(ctx context.Context, userID uuid.UUID, req models.CreatePaymentIntentRequest) (*models.PaymentIntentResponse, error) {
+	if s.stripe == nil {
+		return nil, newError(ErrUnavailable, "billing is not configured")
+	}
+	if err := requireVerifiedEmail(ctx, s.store, userID, "make payments"); err != nil {
+		return nil, err
+	}
+	sub, err := s.GetSubscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureCustomer(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	params := &stripe.PaymentIntentParams{
+		Amount:   stripe.Int64(req.Amount),
+		Currency: stripe.String(strings.ToLower(req.Currency)),
+		Customer: sub.StripeCustomerID,
+		AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{
+			Enabled: stripe.Bool(true),
+		},
+	}
+	params.Context = ctx
+	params.AddMetadata("user_id", userID.String())
+	intent, err := s.stripe.PaymentIntents.New(params)
+	if err != nil {
+		return nil, paymentError(err)
+	}
+
+	return &models.PaymentIntentResponse{
+		ID:           intent.ID,
+		ClientSecret: intent.ClientSecret,
+		Amount:       intent.Amount,
+		Currency:     string(intent.Currency),
+		Status:       string(intent.Status),
+	}, nil
+}
+
+// ConfirmPayment checks that a payment intent belonging to the user has
+// succeeded and applies its effects without waiting for the webhook: the
+// invoice it paid is recorded and the Stripe subscription is refreshed.
+func (s *SubscriptionService) ConfirmPayment(ctx context.Context, userID uuid.UUID, paymentIntentID string) (*models.SubscriptionSummary, error) {
+	if s.stripe == nil {
+		return nil, newError(ErrUnavailable, "billing is not configured")
+	}
+	sub, err := s.GetSubscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &stripe.PaymentIntentParams{}
+	params.Context = ctx
+	params.AddExpand("invoice")
+	intent, err := s.stripe.PaymentIntents.Get(paymentIntentID, params)
+	if err != nil {
+		return nil, paymentError(err)
+	}
+	if sub.StripeCustomerID == nil || intent.Customer == nil || intent.Customer.ID != *sub.StripeCustomerID {
+		return nil, newError(ErrNotFound, "payment not found")
+	}
+	if intent.Status != stripe.PaymentIntentStatusSucceeded {
+		return nil, newError(ErrPaymentFailed, "the payment has not completed (status %s)", intent.Status)
+	}
+
+	if intent.Invoice != nil && intent.Invoice.ID != "" {
+		if intent.Invoice.Customer == nil {
+			intent.Invoice.Customer = intent.Customer
+		}
+		if err := s.syncInvoice(ctx, intent.Invoice, false); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.refreshFromStripe(ctx, sub); err != nil {
+		return nil, err
+	}
+	return s.GetSummary(ctx, userID)
+}
+
+// GetInvoice returns one of the user's invoices
+func (s *SubscriptionService) GetInvoice(ctx context.Context, userID, invoiceID uuid.UUID) (*models.Invoice, error) {
+	inv, err := s.store.Subscriptions().GetInvoice(ctx, userID, invoiceID)
+	if err != nil {
+		return nil, notFound(err, "invoice")
+	}
+	return inv, nil
+}
+
+// RetryInvoice attempts to collect an unpaid invoice again with the
+// customer's default payment method
+func (s *SubscriptionService) RetryInvoice(ctx context.Context, userID, invoiceID uuid.UUID) (*models.Invoice, error) {
+	if s.stripe == nil {
+		return nil, newError(ErrUnavailable, "billing is not configured")
+	}
+	inv, err := s.GetInvoice(ctx, userID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if inv.Status != string(stripe.InvoiceStatusOpen) {
+		return nil, newError(ErrConflict, "only open invoices can be retried; this one is %s", inv.Status)
+	}
+
+	params := &stripe.InvoicePayParams{}
+	params.Context = ctx
+	remote, err := s.stripe.Invoices.Pay(inv.StripeInvoiceID, params)
+	if err != nil {
+		return nil, paymentError(err)
+	}
+	if err := s.syncInvoice(ctx, remote, remote.Status != stripe.InvoiceStatusPaid); err != nil {
+		return nil, err
+	}
+
+	sub, err := s.GetSubscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refreshFromStripe(ctx, sub); err != nil {
+		return nil, err
+	}
+	return s.GetInvoice(ctx, userID, invoiceID)
+}
+
+// CreateBillingPortal returns a Stripe billing portal URL for the user
+func (s *SubscriptionService) CreateBillingPortal(ctx context.Context, userID uuid.UUID, returnURL string) (string, error) {
+	if s.stripe == nil {
+		return "", newError(ErrUnavailable, "billing is not configured")
+	}
+	sub, err := s.GetSubscription(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.ensureCustomer(ctx, sub); err != nil {
+		return "", err
+	}
+
+	params := &stripe.BillingPortalSessionParams{
+		Customer:  sub.StripeCustomerID,
+		ReturnURL: stripe.String(returnURL),
+	}
+	params.Context = ctx
+	session, err := s.stripe.BillingPortalSessions.New(params)
+	if err != nil {
+		return "", paymentError(err)
+	}
+	return session.URL, nil
+}
+
+// ListPaymentMethods returns a user's saved payment methods
+func (s *SubscriptionService) ListPaymentMethods(ctx context.Context, userID uuid.UUID) ([]models.PaymentMethod, error) {
+	return s.store.Subscriptions().ListPaymentMethods(ctx, userID)
+}
+
+// AddPaymentMethod attaches a Stripe payment method to the user's customer.
+// The first method added becomes the default.
+func (s *SubscriptionService) AddPaymentMethod(ctx context.Context, userID uuid.UUID, req models.AddPaymentMethodRequest) (*models.PaymentMethod, error) {
+	if s.stripe == nil {
+		return nil, newError(ErrUnavailable, "billing is not configured")
+	}
+	if err := requireVerifiedEmail(ctx, s.store, userID, "add a payment method"); err != nil {
+		return nil, err
+	}
+	sub, err := s.GetSubscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureCustomer(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	params := &stripe.PaymentMethodAttachParams{Customer: sub.StripeCustomerID}
+	params.Context = ctx
+	remote, err := s.stripe.PaymentMethods.Attach(req.PaymentMethodID, params)
+	if err != nil {
+		return nil, paymentError(err)
+	}
+
+	var brand, last4 *string
+	var expMonth, expYear *int
+	if remote.Card != nil {
+		b, l := string(remote.Card.Brand), remote.Card.Last4
+		m, y := int(remote.Card.ExpMonth), int(remote.Card.ExpYear)
+		brand, last4, expMonth, expYear = &b, &l, &m, &y
+	}
+	method := models.NewPaymentMethod(userID, remote.ID, string(remote.Type), brand, last4, expMonth, expYear)
+
+	existing, err := s.store.Subscriptions().ListPaymentMethods(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	makeDefault := req.SetAsDefault || len(existing) == 0
+
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		if err := tx.Subscriptions().CreatePaymentMethod(ctx, method); err != nil {
+			if errors.Is(err, database.ErrDuplicate) {
+				return newError(ErrConflict, "this payment method is already saved")
+			}
+			return err
+		}
+		if makeDefault {
+			return tx.Subscriptions().SetDefaultPaymentMethod(ctx, userID, method.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if makeDefault {
+		if err := s.setStripeDefault(ctx, *sub.StripeCustomerID, remote.ID); err != nil {
+			return nil, err
+		}
+		method.IsDefault = true
+	}
+	return method, nil
+}
+
+// DeletePaymentMethod detaches and removes a saved payment method
+func (s *SubscriptionService) DeletePaymentMethod(ctx context.Context, userID, methodID uuid.UUID) error {
+	method, err := s.store.Subscriptions().GetPaymentMethod(ctx, userID, methodID)
+	if err != nil {
+		return notFound(err, "payment method")
+	}
+
+	if s.stripe != nil {
+		params := &stripe.PaymentMethodDetachParams{}
+		params.Context = ctx
+		if _, err := s.stripe.PaymentMethods.Detach(method.StripePaymentMethodID, params); err != nil {
+			return paymentError(err)
+		}
+	}
+	return s.store.Subscriptions().DeletePaymentMethod(ctx, userID, methodID)
+}
+
+// SetDefaultPaymentMethod makes a saved payment method the default
+func (s *SubscriptionService) SetDefaultPaymentMethod(ctx context.Context, userID, methodID uuid.UUID) error {
+	method, err := s.store.Subscriptions().GetPaymentMethod(ctx, userID, methodID)
+	if err != nil {
+		return notFound(err, "payment method")
+	}
+
+	if s.stripe != nil {
+		sub, err := s.GetSubscription(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if sub.StripeCustomerID != nil {
+			if err := s.setStripeDefault(ctx, *sub.StripeCustomerID, method.StripePaymentMethodID); err != nil {
+				return err
+			}
+		}
+	}
+	return s.store.Subscriptions().SetDefaultPaymentMethod(ctx, userID, methodID)
+}
+
+// ListInvoices returns a user's invoices, newest first
+func (s *SubscriptionService) ListInvoices(ctx context.Context, userID uuid.UUID) ([]models.Invoice, error) {
+	return s.store.Subscriptions().ListInvoices(ctx, userID)
+}
+
+// HandleWebhook verifies and applies a Stripe webhook event
+func (s *SubscriptionService) HandleWebhook(ctx context.Context, payload []byte, signature string) error {
+	if s.cfg.WebhookSecret == "" {
+		return newError(ErrUnavailable, "webhooks are not configured")
+	}
+	event, err := webhook.ConstructEvent(payload, signature, s.cfg.WebhookSecret)
+	if err != nil {
+		return newError(ErrInvalidInput, "invalid webhook signature")
+	}
+
+	switch event.Type {
+	case "customer.subscription.created", "customer.subscription.updated", "customer.subscription.deleted":
+		var remote stripe.Subscription
+		if err := json.Unmarshal(event.Data.Raw, &remote); err != nil {
+			return newError(ErrInvalidInput, "invalid subscription payload")
+		}
+		return s.syncSubscription(ctx, &remote)
+
+	case "invoice.created", "invoice.finalized", "invoice.paid", "invoice.payment_failed", "invoice.voided":
+		var remote stripe.Invoice
+		if err := json.Unmarshal(event.Data.Raw, &remote); err != nil {
+			return newError(ErrInvalidInput, "invalid invoice payload")
+		}
+		return s.syncInvoice(ctx, &remote, event.Type == "invoice.payment_failed")
+	}
+	return nil
+}
+
+// UpdateSubscriptionStatuses handles subscriptions whose period has ended:
+// Stripe-backed ones are refreshed from Stripe, local trials and canceled
+// subscriptions fall back to the free plan.
+func (s *SubscriptionService) UpdateSubscriptionStatuses(ctx context.Context) error {
+	now := time.Now().UTC()
+	lapsed, err := s.store.Subscriptions().ListLapsed(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range lapsed {
+		sub := &lapsed[i]
+		if err := s.expire(ctx, sub, now); err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s: %w", sub.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expire moves a single lapsed subscription to its next state
+func (s *SubscriptionService) expire(ctx context.Context, sub *models.Subscription, now time.Time) error {
+	if sub.StripeSubscriptionID != nil && sub.Status != models.SubscriptionStatusCanceled {
+		if s.stripe == nil {
+			sub.Status = models.SubscriptionStatusPastDue
+		} else {
+			params := &stripe.SubscriptionParams{}
+			params.Context = ctx
+			remote, err := s.stripe.Subscriptions.Get(*sub.StripeSubscriptionID, params)
+			if err != nil {
+				return paymentError(err)
+			}
+			applyStripeSubscription(sub, remote)
+		}
+	} else {
+		downgrade(sub)
+	}
+
+	sub.UpdatedAt = now
+	return s.store.Subscriptions().Update(ctx, sub)
+}
+
+// syncSubscription applies a Stripe subscription object to the local row
+func (s *SubscriptionService) syncSubscription(ctx context.Context, remote *stripe.Subscription) error {
+	return s.store.WithTx(ctx, func(tx database.Store) error {
+		sub, err := tx.Subscriptions().GetByStripeSubscriptionID(ctx, remote.ID)
+		if errors.Is(err, database.ErrNotFound) && remote.Customer != nil {
+			sub, err = tx.Subscriptions().GetByStripeCustomerID(ctx, remote.Customer.ID)
+		}
+		if errors.Is(err, database.ErrNotFound) {
+			// Not ours, or created before the customer was linked
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		applyStripeSubscription(sub, remote)
+		sub.UpdatedAt = time.Now().UTC()
+		return tx.Subscriptions().Update(ctx, sub)
+	})
+}
+
+// syncInvoice records a Stripe invoice for the customer it belongs to
+func (s *SubscriptionService) syncInvoice(ctx context.Context, remote *stripe.Invoice, paymentFailed bool) error {
+	if remote.Customer == nil {
+		return nil
+	}
+
+	return s.store.WithTx(ctx, func(tx database.Store) error {
+		sub, err := tx.Subscriptions().GetByStripeCustomerID(ctx, remote.Customer.ID)
+		if errors.Is(err, database.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		inv, err := tx.Subscriptions().GetInvoiceByStripeID(ctx, remote.ID)
+		if errors.Is(err, database.ErrNotFound) {
+			dueDate := remote.DueDate
+			if dueDate == 0 {
+				dueDate = remote.Created
+			}
+			inv = models.NewInvoice(sub.UserID, sub.ID, remote.ID, float64(remote.AmountDue)/100,
+				string(remote.Currency), string(remote.Status),
+				unixTime(remote.PeriodStart), unixTime(remote.PeriodEnd), unixTime(dueDate))
+			err = tx.Subscriptions().CreateInvoice(ctx, inv)
+		} else if err == nil {
+			inv.Status = string(remote.Status)
+		}
+		if err != nil {
+			return err
+		}
+
+		if remote.StatusTransitions != nil && remote.StatusTransitions.PaidAt != 0 {
+			paidAt := unixTime(remote.StatusTransitions.PaidAt)
+			inv.PaidAt = &paidAt
+		}
+		if remote.HostedInvoiceURL != "" {
+			url := remote.HostedInvoiceURL
+			inv.InvoiceURL = &url
+		}
+		if err := tx.Subscriptions().UpdateInvoice(ctx, inv); err != nil {
+			return err
+		}
+
+		if paymentFailed && sub.Status != models.SubscriptionStatusCanceled {
+			sub.Status = models.SubscriptionStatusPastDue
+			sub.UpdatedAt = time.Now().UTC()
+			return tx.Subscriptions().Update(ctx, sub)
+		}
+		return nil
+	})
+}
+
+// refreshFromStripe reloads sub's Stripe subscription, if it has one
+func (s *SubscriptionService) refreshFromStripe(ctx context.Context, sub *models.Subscription) error {
+	if sub.StripeSubscriptionID == nil {
+		return nil
+	}
+	params := &stripe.SubscriptionParams{}
+	params.Context = ctx
+	remote, err := s.stripe.Subscriptions.Get(*sub.StripeSubscriptionID, params)
+	if err != nil {
+		return paymentError(err)
+	}
+	return s.syncSubscription(ctx, remote)
+}
+
+// ensureCustomer creates the Stripe customer for a subscription if needed
+func (s *SubscriptionService) ensureCustomer(ctx context.Context, sub *models.Subscription) error {
+	if sub.StripeCustomerID != nil {
+		return nil
+	}
+
+	user, err := s.store.Users().GetByID(ctx, sub.UserID)
+	if err != nil {
+		return notFound(err, "user")
+	}
+
+	params := &stripe.CustomerParams{
+		Email: stripe.String(user.Email),
+		Name:  stripe.String(user.FullName()),
+	}
+	params.Context = ctx
+	params.AddMetadata("user_id", user.ID.String())
+	customer, err := s.stripe.Customers.New(params)
+	if err != nil {
+		return paymentError(err)
+	}
+
+	sub.StripeCustomerID = &customer.ID
+	sub.UpdatedAt = time.Now().UTC()
+	return s.store.Subscriptions().Update(ctx, sub)
+}
+
+// setStripeDefault makes a payment method the customer's invoice default
+func (s *SubscriptionService) setStripeDefault(ctx context.Context, customerID, paymentMethodID string) error {
+	params := &stripe.CustomerParams{
+		InvoiceSettings: &stripe.CustomerInvoiceSettingsParams{
+			DefaultPaymentMethod: stripe.String(paymentMethodID),
+		},
+	}
+	params.Context = ctx
+	if _, err := s.stripe.Customers.Update(customerID, params); err != nil {
+		return paymentError(err)
+	}
+	return nil
+}
+
+// usage counts what a user is consuming against their plan
+func (s *SubscriptionService) usage(ctx context.Context, sub *models.Subscription) (*models.SubscriptionUsage, error) {
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	if sub.CurrentPeriodStart != nil && sub.CurrentPeriodEnd != nil {
+		start, end = *sub.CurrentPeriodStart, *sub.CurrentPeriodEnd
+	}
+
+	usage := models.NewSubscriptionUsage(sub.UserID, sub.ID, start, end)
+
+	var err error
+	if usage.PersonalGoals, err = s.store.Goals().CountActiveByUser(ctx, sub.UserID); err != nil {
+		return nil, err
+	}
+	if usage.GroupsJoined, err = s.store.Users().CountGroupsJoined(ctx, sub.UserID); err != nil {
+		return nil, err
+	}
+
+	groups, err := s.store.Groups().ListByUser(ctx, sub.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		goals, err := s.store.Groups().ListGoals(ctx, g.ID)
+		if err != nil {
+			return nil, err
+		}
+		usage.GroupGoals += len(goals)
+	}
+	return usage, nil
+}
+
+// effectivePlan returns sub as it applies to feature checks: premium only
+// while active, in trial, or canceled with time left in the paid period
+func effectivePlan(sub *models.Subscription) *models.Subscription {
+	if sub.Plan == models.PlanPremium {
+		if sub.IsActive() {
+			return sub
+		}
+		if sub.Status == models.SubscriptionStatusCanceled && sub.CurrentPeriodEnd != nil &&
+			time.Now().UTC().Before(*sub.CurrentPeriodEnd) {
+			return sub
+		}
+	}
+	free := *sub
+	free.Plan = models.PlanFree
+	return &free
+}
+
+// startTrial puts sub on a premium trial starting at now
+func startTrial(sub *models.Subscription, now time.Time) {
+	trialEnd := now.AddDate(0, 0, trialDays)
+	sub.Plan = models.PlanPremium
+	sub.Status = models.SubscriptionStatusTrial
+	sub.TrialStartDate = &now
+	sub.TrialEndDate = &trialEnd
+	sub.CurrentPeriodStart = &now
+	sub.CurrentPeriodEnd = &trialEnd
+	sub.CanceledAt = nil
+	sub.UpdatedAt = now
+}
+
+// downgrade returns sub to the free plan. Trial dates are kept so the
+// trial cannot be taken twice.
+func downgrade(sub *models.Subscription) {
+	sub.Plan = models.PlanFree
+	sub.Status = models.SubscriptionStatusActive
+	sub.StripeSubscriptionID = nil
+	sub.StripePriceID = nil
+	sub.CurrentPeriodStart = nil
+	sub.CurrentPeriodEnd = nil
+}
+
+// applyStripeSubscription copies the state of a Stripe subscription onto sub
+func applyStripeSubscription(sub *models.Subscription, remote *stripe.Subscription) {
+	sub.StripeSubscriptionID = &remote.ID
+	if remote.Customer != nil && remote.Customer.ID != "" {
+		sub.StripeCustomerID = &remote.Customer.ID
+	}
+	if remote.Items != nil && len(remote.Items.Data) > 0 && remote.Items.Data[0].Price != nil {
+		sub.StripePriceID = &remote.Items.Data[0].Price.ID
+	}
+	if remote.CurrentPeriodStart != 0 {
+		start := unixTime(remote.CurrentPeriodStart)
+		sub.CurrentPeriodStart = &start
+	}
+	if remote.CurrentPeriodEnd != 0 {
+		end := unixTime(remote.CurrentPeriodEnd)
+		sub.CurrentPeriodEnd = &end
+	}
+	if remote.TrialStart != 0 {
+		start := unixTime(remote.TrialStart)
+		sub.TrialStartDate = &start
+	}
+	if remote.TrialEnd != 0 {
+		end := unixTime(remote.TrialEnd)
+		sub.TrialEndDate = &end
+	}
+	if remote.CanceledAt != 0 {
+		canceled := unixTime(remote.CanceledAt)
+		sub.CanceledAt = &canceled
+	}
+
+	sub.Plan = models.PlanPremium
+	switch remote.Status {
+	case stripe.SubscriptionStatusTrialing:
+		sub.Status = models.SubscriptionStatusTrial
+	case stripe.SubscriptionStatusActive:
+		sub.Status = models.SubscriptionStatusActive
+		if remote.CancelAtPeriodEnd {
+			sub.Status = models.SubscriptionStatusCanceled
+		}
+	case stripe.SubscriptionStatusPastDue, stripe.SubscriptionStatusUnpaid,
+		stripe.SubscriptionStatusIncomplete, stripe.SubscriptionStatusPaused:
+		sub.Status = models.SubscriptionStatusPastDue
+	case stripe.SubscriptionStatusCanceled, stripe.SubscriptionStatusIncompleteExpired:
+		downgrade(sub)
+	}
+}
+
+// paymentError wraps a Stripe error as ErrPaymentFailed with Stripe's message.
+// Errors without one are logged and reported generically.
+func paymentError(err error) error {
+	var stripeErr *stripe.Error
+	if errors.As(err, &stripeErr) && stripeErr.Msg != "" {
+		return newError(ErrPaymentFailed, "%s", stripeErr.Msg)
+	}
+	log.Printf("Stripe request failed: %v", err)
+	return newError(ErrPaymentFailed, "the payment provider could not process the request")
+}
+
+func unixTime(seconds int64) time.Time {
+	return time.Unix(seconds, 0).UTC()
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"chainforge/internal/config"
+	"chainforge/internal/models"
+)
+
+func TestCreateSubscriptionStartsLocalTrialOnce(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanFree)
+	svc := NewSubscriptionService(store, config.StripeConfig{})
+	ctx := context.Background()
+
+	sub, err := svc.CreateSubscription(ctx, user.ID, models.CreateSubscriptionRequest{Plan: models.PlanPremium})
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+	if sub.Plan != models.PlanPremium || sub.Status != models.SubscriptionStatusTrial {
+		t.Fatalf("plan=%s status=%s, want premium trial", sub.Plan, sub.Status)
+	}
+
+	if _, err := svc.CancelSubscription(ctx, user.ID, models.CancelSubscriptionRequest{}); err != nil {
+		t.Fatalf("CancelSubscription: %v", err)
+	}
+	_, err = svc.CreateSubscription(ctx, user.ID, models.CreateSubscriptionRequest{Plan: models.PlanPremium})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("second trial: err = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestCancelAtPeriodEndKeepsPremiumUntilPeriodEnds(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanPremium)
+	svc := NewSubscriptionService(store, config.StripeConfig{})
+	ctx := context.Background()
+
+	sub, err := svc.CancelSubscription(ctx, user.ID, models.CancelSubscriptionRequest{CancelAtPeriodEnd: true})
+	if err != nil {
+		t.Fatalf("CancelSubscription: %v", err)
+	}
+	if sub.Status != models.SubscriptionStatusCanceled {
+		t.Fatalf("status = %s, want canceled", sub.Status)
+	}
+	if premium, _ := svc.IsPremium(ctx, user.ID); !premium {
+		t.Fatal("premium should last until the end of the period")
+	}
+
+	// Let the period run out
+	past := time.Now().UTC().Add(-time.Hour)
+	sub.CurrentPeriodEnd = &past
+	store.subscriptions[sub.ID] = *sub
+
+	if premium, _ := svc.IsPremium(ctx, user.ID); premium {
+		t.Fatal("premium should end with the period")
+	}
+	if err := svc.UpdateSubscriptionStatuses(ctx); err != nil {
+		t.Fatalf("UpdateSubscriptionStatuses: %v", err)
+	}
+	sub, _ = store.Subscriptions().GetByUser(ctx, user.ID)
+	if sub.Plan != models.PlanFree || sub.Status != models.SubscriptionStatusActive {
+		t.Fatalf("plan=%s status=%s, want active free plan", sub.Plan, sub.Status)
+	}
+}
+
+func TestResumeSubscriptionBeforePeriodEnds(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanPremium)
+	svc := NewSubscriptionService(store, config.StripeConfig{})
+	ctx := context.Background()
+
+	if _, err := svc.ResumeSubscription(ctx, user.ID); !errors.Is(err, ErrConflict) {
+		t.Fatalf("resume active subscription: err = %v, want ErrConflict", err)
+	}
+	if _, err := svc.CancelSubscription(ctx, user.ID, models.CancelSubscriptionRequest{CancelAtPeriodEnd: true}); err != nil {
+		t.Fatalf("CancelSubscription: %v", err)
+	}
+
+	sub, err := svc.ResumeSubscription(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("ResumeSubscription: %v", err)
+	}
+	if sub.Status != models.SubscriptionStatusTrial || sub.CanceledAt != nil {
+		t.Fatalf("status=%s canceled_at=%v, want trial and no cancellation", sub.Status, sub.CanceledAt)
+	}
+}
+
+func TestUpdateSubscriptionStatusesDowngradesExpiredTrial(t *testing.T) {
+	store := newMemStore()
+	user := seedUser(t, store, models.PlanPremium)
+	svc := NewSubscriptionService(store, config.StripeConfig{})
+	ctx := context.Background()
+
+	sub, _ := store.Subscriptions().GetByUser(ctx, user.ID)
+	ended := time.Now().UTC().AddDate(0, 0, -1)
+	sub.TrialEndDate = &ended
+	sub.CurrentPeriodEnd = &ended
+	store.subscriptions[sub.ID] = *sub
+
+	if err := svc.UpdateSubscriptionStatuses(ctx); err != nil {
+		t.Fatalf("UpdateSubscriptionStatuses: %v", err)
+	}
+	sub, _ = store.Subscriptions().GetByUser(ctx, user.ID)
+	if sub.Plan != models.PlanFree {
+		t.Fatalf("plan = %s, want free", sub.Plan)
+	}
+	if sub.TrialStartDate == nil {
+		t.Error("trial history must be kept so the trial cannot be reused")
+	}
+}
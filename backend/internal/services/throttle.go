@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/database"
+	"chainforge/internal/models"
+)
+
+// emailUnlockAccount is the template of the email sent when sign-ins to an
+// account are locked
+const emailUnlockAccount = "unlock_account"
+
+// LoginThrottleConfig sets how failed sign-ins slow down later attempts.
+// Past the free attempts, each failure doubles the wait before the next
+// attempt, starting at one second. Failures are counted per email address
+// and per IP; only email addresses are locked. Wrong second-factor codes
+// at sign-in count as failed sign-ins; signed-in users changing their
+// two-factor settings are counted and locked per user instead.
+type LoginThrottleConfig struct {
+	FreeAttempts   int // Failures per email before attempts are delayed
+	IPFreeAttempts int // Failures per IP before attempts are delayed
+	MaxBackoff     time.Duration
+	LockAfter      int // Failures per email that lock it
+	MFALockAfter   int // Failed second-factor checks per signed-in user that lock them
+	LockDuration   time.Duration
+	Window         time.Duration // Failures are forgotten after this long without another
+}
+
+// checkLoginThrottle fails with ErrTooManyAttempts if sign-ins to email are
+// locked or email or ip must still wait after recent failures
+func (s *UserService) checkLoginThrottle(ctx context.Context, email, ip string, now time.Time) error {
+	t, err := s.getThrottle(ctx, models.ThrottleEmail, email)
+	if err != nil {
+		return err
+	}
+	if t != nil && t.Locked(now) {
+		return tooManyAttempts(t.LockedUntil.Sub(now),
+			"sign-ins to this account are locked after too many failed attempts; use the link we emailed you to unlock it or try again later")
+	}
+	wait := s.loginBackoff(t, s.throttle.FreeAttempts, now)
+
+	if ip != "" {
+		t, err := s.getThrottle(ctx, models.ThrottleIP, ip)
+		if err != nil {
+			return err
+		}
+		wait = max(wait, s.loginBackoff(t, s.throttle.IPFreeAttempts, now))
+	}
+	if wait > 0 {
+		return tooManyAttempts(wait, "too many failed sign-in attempts; try again in %s", retryIn(wait))
+	}
+	return nil
+}
+
+// getThrottle returns the failure counter of subject, or nil if it has none
+func (s *UserService) getThrottle(ctx context.Context, scope models.ThrottleScope, subject string) (*models.LoginThrottle, error) {
+	t, err := s.store.Throttles().GetThrottle(ctx, scope, subject)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil
+	}
+	return t, err
+}
+
+// loginBackoff is how long after now the next attempt against t must wait
+// once more than free failures have been recorded
+func (s *UserService) loginBackoff(t *models.LoginThrottle, free int, now time.Time) time.Duration {
+	if t == nil || t.Failures < free || !t.LastFailureAt.After(now.Add(-s.throttle.Window)) {
+		return 0
+	}
+	delay := s.throttle.MaxBackoff
+	if n := t.Failures - free; n < 30 {
+		delay = min(time.Second<<n, s.throttle.MaxBackoff)
+	}
+	return max(t.LastFailureAt.Add(delay).Sub(now), 0)
+}
+
+// loginFailed records a failed sign-in with email from client and returns
+// the error to report. user is the account with that email, or nil if there
+// is none; unknown addresses are counted and locked the same way so the
+// responses do not reveal which ones have accounts. Reaching the lock
+// threshold locks the email and mails the account a link to unlock it.
+func (s *UserService) loginFailed(ctx context.Context, email string, user *models.User, client models.ClientInfo, now time.Time) error {
+	if client.IPAddress != "" {
+		if _, err := s.store.Throttles().RecordFailure(ctx, models.ThrottleIP, client.IPAddress, now, s.throttle.Window); err != nil {
+			return err
+		}
+	}
+
+	locked := false
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		t, err := tx.Throttles().RecordFailure(ctx, models.ThrottleEmail, email, now, s.throttle.Window)
+		if err != nil {
+			return err
+		}
+		lock := t.Failures >= s.throttle.LockAfter && !t.Locked(now)
+		if lock {
+			if err := tx.Throttles().LockThrottle(ctx, models.ThrottleEmail, email, now.Add(s.throttle.LockDuration)); err != nil {
+				return err
+			}
+		}
+		if user == nil {
+			return nil
+		}
+
+		entry := models.NewUserAuditLog(user.ID, models.AuditLoginFailed, fmt.Sprintf(`{"failures":%d}`, t.Failures), client)
+		if err := tx.Audit().Create(ctx, entry); err != nil {
+			return err
+		}
+		if !lock {
+			return nil
+		}
+		log.Printf("Security: locked sign-ins to user %s after %d failed attempts", user.ID, t.Failures)
+		entry = models.NewUserAuditLog(user.ID, models.AuditAccountLocked, fmt.Sprintf(`{"failures":%d}`, t.Failures), client)
+		if err := tx.Audit().Create(ctx, entry); err != nil {
+			return err
+		}
+		if !user.IsActive {
+			return nil
+		}
+		token, err := s.issueEmailToken(ctx, tx, user.ID, models.EmailTokenUnlockAccount, s.throttle.LockDuration)
+		if err != nil {
+			return err
+		}
+		locked = true
+		return s.mail.Mailer.Queue(ctx, tx, user, emailUnlockAccount, client.Locale, linkEmail{
+			Name:      user.FirstName,
+			Link:      s.emailLink("/auth/unlock", token),
+			ExpiresIn: s.throttle.LockDuration,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if locked {
+		s.mail.Mailer.Notify()
+	}
+	return newError(ErrInvalidCredentials, "invalid email or password")
+}
+
+// UnlockAccount lifts the sign-in lock of the account an unlock link was
+// sent to and forgets its failed attempts
+func (s *UserService) UnlockAccount(ctx context.Context, req models.UnlockAccountRequest, client models.ClientInfo) error {
+	return s.store.WithTx(ctx, func(tx database.Store) error {
+		now := s.now().UTC()
+		token, err := tx.EmailTokens().TakeEmailToken(ctx, models.EmailTokenUnlockAccount, auth.HashEmailToken(req.Token), now)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				return newError(ErrInvalidInput, "this unlock link is invalid or has expired")
+			}
+			return err
+		}
+		user, err := tx.Users().GetByID(ctx, token.UserID)
+		if err != nil {
+			return notFound(err, "user")
+		}
+		if err := tx.Throttles().ClearThrottle(ctx, models.ThrottleEmail, database.NormalizeEmail(user.Email)); err != nil {
+			return err
+		}
+		return tx.Audit().Create(ctx, models.NewUserAuditLog(user.ID, models.AuditAccountUnlocked, "", client))
+	})
+}
+
+// CleanupLoginThrottles deletes up to limit failure counters that have
+// been quiet for longer than the failure window
+func (s *UserService) CleanupLoginThrottles(ctx context.Context, limit int) (int, error) {
+	return s.store.Throttles().DeleteStaleThrottles(ctx, s.now().UTC().Add(-s.throttle.Window), limit)
+}
+
+// retryIn formats a wait for error messages, rounded up to whole seconds
+func retryIn(d time.Duration) string {
+	return ((d + time.Second - 1) / time.Second * time.Second).String()
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"chainforge/internal/models"
+)
+
+// testLoginThrottle delays the fourth failed sign-in and locks on the
+// fifth, and locks a signed-in user's two-factor checks on the third failure
+var testLoginThrottle = LoginThrottleConfig{
+	FreeAttempts:   3,
+	IPFreeAttempts: 8,
+	MaxBackoff:     time.Minute,
+	LockAfter:      5,
+	MFALockAfter:   3,
+	LockDuration:   30 * time.Minute,
+	Window:         24 * time.Hour,
+}
+
+// ageThrottles moves the recorded failures and locks d into the past
+func ageThrottles(store *memStore, d time.Duration) {
+	for key, t := range store.throttles {
+		t.LastFailureAt = t.LastFailureAt.Add(-d)
+		if t.LockedUntil != nil {
+			until := t.LockedUntil.Add(-d)
+			t.LockedUntil = &until
+		}
+		store.throttles[key] = t
+	}
+}
+
+// failLogins signs in with a wrong password n times, waiting out the
+// backoff before each attempt
+func failLogins(t *testing.T, svc *UserService, store *memStore, email string, client models.ClientInfo, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		ageThrottles(store, testLoginThrottle.MaxBackoff)
+		_, err := svc.Login(context.Background(), models.LoginRequest{Email: email, Password: "wrong-password"}, client)
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failed sign-in %d: err = %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+}
+
+// retryAfter returns the wait an ErrTooManyAttempts error asks for
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+	var svcErr *Error
+	if !errors.Is(err, ErrTooManyAttempts) || !errors.As(err, &svcErr) {
+		t.Fatalf("err = %v, want ErrTooManyAttempts", err)
+	}
+	return svcErr.RetryAfter
+}
+
+func TestLoginBacksOffAfterFailures(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+	login := models.LoginRequest{Email: "ada@example.com", Password: "Correct-Horse-42"}
+
+	if _, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	// The free attempts are not delayed
+	for i := 0; i < testLoginThrottle.FreeAttempts; i++ {
+		_, err := svc.Login(ctx, models.LoginRequest{Email: "ada@example.com", Password: "wrong-password"}, testClient)
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failed sign-in %d: err = %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+
+	// Then even the right password has to wait, twice as long each time
+	_, err := svc.Login(ctx, login, testClient)
+	if wait := retryAfter(t, err); wait <= 0 || wait > time.Second {
+		t.Fatalf("after %d failures: retry after %s, want up to 1s", testLoginThrottle.FreeAttempts, wait)
+	}
+	failLogins(t, svc, store, "ada@example.com", testClient, 1)
+	_, err = svc.Login(ctx, login, testClient)
+	if wait := retryAfter(t, err); wait <= time.Second || wait > 2*time.Second {
+		t.Fatalf("after %d failures: retry after %s, want up to 2s", testLoginThrottle.FreeAttempts+1, wait)
+	}
+
+	// Signing in once the wait is over forgets the failures of the email
+	ageThrottles(store, 2*time.Second)
+	if _, err := svc.Login(ctx, login, testClient); err != nil {
+		t.Fatalf("Login after the backoff: %v", err)
+	}
+	if _, err := store.Throttles().GetThrottle(ctx, models.ThrottleEmail, "ada@example.com"); err == nil {
+		t.Error("the email's failures were kept after a successful sign-in")
+	}
+}
+
+func TestLoginLocksAccountAndMailsUnlockLink(t *testing.T) {
+	store := newMemStore()
+	svc, box := newMailingUserService(store)
+	ctx := context.Background()
+	login := models.LoginRequest{Email: "ada@example.com", Password: "Correct-Horse-42"}
+
+	user, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	failLogins(t, svc, store, "Ada@Example.com", testClient, testLoginThrottle.LockAfter)
+
+	_, err = svc.Login(ctx, login, testClient)
+	if wait := retryAfter(t, err); wait <= testLoginThrottle.MaxBackoff || wait > testLoginThrottle.LockDuration {
+		t.Fatalf("locked account: retry after %s, want the lock duration", wait)
+	}
+	if !hasAuditEntry(store, user.ID, models.AuditLoginFailed) || !hasAuditEntry(store, user.ID, models.AuditAccountLocked) {
+		t.Error("the failures and lock were not audited")
+	}
+
+	msg := box.last(t, "ada@example.com")
+	if !strings.Contains(msg.Subject, "locked") {
+		t.Errorf("subject = %q", msg.Subject)
+	}
+	token := linkToken(t, msg, "/auth/unlock")
+	if err := svc.UnlockAccount(ctx, models.UnlockAccountRequest{Token: token}, testClient); err != nil {
+		t.Fatalf("UnlockAccount: %v", err)
+	}
+	if err := svc.UnlockAccount(ctx, models.UnlockAccountRequest{Token: token}, testClient); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("reused link: err = %v, want ErrInvalidInput", err)
+	}
+	if !hasAuditEntry(store, user.ID, models.AuditAccountUnlocked) {
+		t.Error("the unlock was not audited")
+	}
+	if _, err := svc.Login(ctx, login, testClient); err != nil {
+		t.Fatalf("Login after unlocking: %v", err)
+	}
+}
+
+func TestLoginLockExpires(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+
+	if _, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	failLogins(t, svc, store, "ada@example.com", testClient, testLoginThrottle.LockAfter)
+
+	ageThrottles(store, testLoginThrottle.LockDuration)
+	if _, err := svc.Login(ctx, models.LoginRequest{Email: "ada@example.com", Password: "Correct-Horse-42"}, testClient); err != nil {
+		t.Fatalf("Login after the lock expired: %v", err)
+	}
+}
+
+func TestLoginThrottlesUnknownEmailsAlike(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+
+	failLogins(t, svc, store, "nobody@example.com", testClient, testLoginThrottle.LockAfter)
+	_, err := svc.Login(context.Background(), models.LoginRequest{Email: "nobody@example.com", Password: "wrong-password"}, testClient)
+	if wait := retryAfter(t, err); wait <= testLoginThrottle.MaxBackoff {
+		t.Errorf("unknown email: retry after %s, want it locked like an account", wait)
+	}
+	if len(store.outbox) != 0 || len(store.emailTokens) != 0 || len(store.auditLogs) != 0 {
+		t.Error("a lock of an address without an account sent mail or was audited")
+	}
+}
+
+func TestLoginThrottlesIP(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+
+	if _, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	// Spreading guesses over many emails still slows down their IP
+	for i := 0; i < testLoginThrottle.IPFreeAttempts; i++ {
+		failLogins(t, svc, store, "user"+string(rune('a'+i))+"@example.com", testClient, 1)
+	}
+	login := models.LoginRequest{Email: "ada@example.com", Password: "Correct-Horse-42"}
+	if _, err := svc.Login(ctx, login, testClient); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("same IP: err = %v, want ErrTooManyAttempts", err)
+	}
+
+	other := testClient
+	other.IPAddress = "198.51.100.20"
+	if _, err := svc.Login(ctx, login, other); err != nil {
+		t.Fatalf("other IP: %v", err)
+	}
+}
+
+func TestResetPasswordLiftsLoginLock(t *testing.T) {
+	store := newMemStore()
+	svc, box := newMailingUserService(store)
+	ctx := context.Background()
+
+	if _, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	failLogins(t, svc, store, "ada@example.com", testClient, testLoginThrottle.LockAfter)
+
+	if err := svc.ForgotPassword(ctx, models.ForgotPasswordRequest{Email: "ada@example.com"}, testClient); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	token := linkToken(t, box.last(t, "ada@example.com"), "/auth/reset-password")
+	if err := svc.ResetPassword(ctx, models.ResetPasswordRequest{Token: token, NewPassword: "Battery-Staple-77"}, testClient); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if _, err := svc.Login(ctx, models.LoginRequest{Email: "ada@example.com", Password: "Battery-Staple-77"}, testClient); err != nil {
+		t.Fatalf("Login after the reset: %v", err)
+	}
+}
+
+func TestCleanupLoginThrottles(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+
+	failLogins(t, svc, store, "ada@example.com", testClient, 1)
+	if removed, _ := svc.CleanupLoginThrottles(context.Background(), 100); removed != 0 {
+		t.Fatalf("removed %d recent counters", removed)
+	}
+	ageThrottles(store, testLoginThrottle.Window)
+	if removed, _ := svc.CleanupLoginThrottles(context.Background(), 100); removed != 2 {
+		t.Fatalf("removed %d stale counters, want the email's and the IP's", removed)
+	}
+}
+
+func TestLoginBackoff(t *testing.T) {
+	svc := &UserService{throttle: testLoginThrottle}
+	now := time.Now()
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{8, 32 * time.Second},
+		{9, time.Minute},
+		{100, time.Minute},
+	}
+	for _, tt := range tests {
+		got := svc.loginBackoff(&models.LoginThrottle{Failures: tt.failures, LastFailureAt: now}, 3, now)
+		if got != tt.want {
+			t.Errorf("loginBackoff(%d failures) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+	old := &models.LoginThrottle{Failures: 9, LastFailureAt: now.Add(-testLoginThrottle.Window)}
+	if got := svc.loginBackoff(old, 3, now); got != 0 {
+		t.Errorf("failures outside the window: backoff %s, want none", got)
+	}
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/calendar"
+	"chainforge/internal/database"
+	"chainforge/internal/models"
+)
+
+// UserService handles registration, authentication and profile management
+type UserService struct {
+	store       database.Store
+	tokens      *auth.TokenManager
+	revocations auth.TokenRevocationStore
+	passwords   *auth.PasswordHasher
+	webauthn    *auth.RelyingParty
+	oidc        map[string]*auth.OIDCProvider
+	mail        AccountEmailConfig
+	throttle    LoginThrottleConfig
+	policy      PasswordPolicyConfig
+	weekStart   time.Weekday
+	now         func() time.Time
+}
+
+// NewUserService creates a new user service that signs users in with
+// passwords hashed by passwords, passkeys verified by webauthn and the given
+// OpenID Connect providers, mails verification and password reset links as
+// mail configures, slows down password guessing as throttle configures,
+// enforces policy on new passwords and counts habit weeks from weekStart
+func NewUserService(store database.Store, tokens *auth.TokenManager, revocations auth.TokenRevocationStore, passwords *auth.PasswordHasher, webauthn *auth.RelyingParty, oidc []*auth.OIDCProvider, mail AccountEmailConfig, throttle LoginThrottleConfig, policy PasswordPolicyConfig, weekStart time.Weekday) *UserService {
+	providers := make(map[string]*auth.OIDCProvider, len(oidc))
+	for _, p := range oidc {
+		providers[p.Name()] = p
+	}
+	return &UserService{
+		store:       store,
+		tokens:      tokens,
+		revocations: revocations,
+		passwords:   passwords,
+		webauthn:    webauthn,
+		oidc:        providers,
+		mail:        mail,
+		throttle:    throttle,
+		policy:      policy,
+		weekStart:   weekStart,
+		now:         time.Now,
+	}
+}
+
+// LoginResult is the outcome of a password or OpenID Connect sign-in. When
+// the account has two-factor authentication enabled, Tokens is nil and
+// MFAToken must be exchanged with CompleteMFALogin.
+type LoginResult struct {
+	User     *models.User
+	Tokens   *auth.TokenPair
+	MFAToken string
+}
+
+// Register creates a user with a free subscription, signs them in and
+// mails them a link to verify their email address
+func (s *UserService) Register(ctx context.Context, req models.CreateUserRequest, client models.ClientInfo) (*models.User, *auth.TokenPair, error) {
+	if result := auth.ValidatePassword(req.Password, req.Email, req.FirstName, req.LastName); !result.IsValid {
+		return nil, nil, newError(ErrInvalidInput, "%s", strings.Join(result.Errors, "; "))
+	}
+	if err := validateTimezone(req.Timezone); err != nil {
+		return nil, nil, err
+	}
+
+	hash, err := s.passwords.Hash(req.Password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user := models.NewUser(
+		database.NormalizeEmail(req.Email), hash,
+		strings.TrimSpace(req.FirstName), strings.TrimSpace(req.LastName), req.Timezone,
+	)
+
+	var tokens *auth.TokenPair
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		if err := tx.Users().Create(ctx, user); err != nil {
+			if errors.Is(err, database.ErrDuplicate) {
+				return newError(ErrConflict, "an account with this email already exists")
+			}
+			return err
+		}
+		if err := s.recordPassword(ctx, tx, user.ID, hash, user.CreatedAt); err != nil {
+			return err
+		}
+		if err := tx.Subscriptions().Create(ctx, models.NewSubscription(user.ID, models.PlanFree)); err != nil {
+			return err
+		}
+		if err := s.queueVerificationEmail(ctx, tx, user, client.Locale); err != nil {
+			return err
+		}
+		tokens, err = s.startSession(ctx, tx, user, client)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	s.mail.Mailer.Notify()
+	return user, tokens, nil
+}
+
+// Login verifies credentials and issues a token pair, or an mfa_pending
+// token if the account needs a second factor. Repeated failures for an
+// email or from an IP delay further attempts and eventually lock the email.
+// A group admin whose password is older than the policy allows must reset
+// it before signing in with a password again.
+func (s *UserService) Login(ctx context.Context, req models.LoginRequest, client models.ClientInfo) (*LoginResult, error) {
+	now := s.now().UTC()
+	email := database.NormalizeEmail(req.Email)
+	if err := s.checkLoginThrottle(ctx, email, client.IPAddress, now); err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.Users().GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			// Spend as long as a real check so timing does not reveal
+			// which emails have accounts
+			s.passwords.Reject(req.Password)
+			return nil, s.loginFailed(ctx, email, nil, client, now)
+		}
+		return nil, err
+	}
+
+	needsRehash := false
+	if user.Password == "" {
+		// Accounts created through OpenID Connect have no password
+		err = s.passwords.Reject(req.Password)
+	} else {
+		needsRehash, err = s.passwords.Verify(req.Password, user.Password)
+	}
+	if err != nil {
+		return nil, s.loginFailed(ctx, email, user, client, now)
+	}
+	if !user.IsActive {
+		return nil, newError(ErrForbidden, "this account has been deactivated")
+	}
+	if err := s.store.Throttles().ClearThrottle(ctx, models.ThrottleEmail, email); err != nil {
+		return nil, err
+	}
+	expired, err := s.passwordExpired(ctx, user, now)
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, newError(ErrPasswordExpired, "your password has expired; use the forgot password link to choose a new one")
+	}
+	if needsRehash {
+		s.rehashPassword(ctx, user, req.Password)
+	}
+	return s.signIn(ctx, user, client)
+}
+
+// rehashPassword replaces a user's password hash with one made with the
+// current algorithm and parameters. Failing to is logged but does not stop
+// the sign-in; the next one tries again.
+func (s *UserService) rehashPassword(ctx context.Context, user *models.User, password string) {
+	hash, err := s.passwords.Hash(password)
+	if err == nil {
+		err = s.store.Users().UpdatePassword(ctx, user.ID, hash)
+	}
+	if err != nil {
+		log.Printf("Failed to rehash the password of user %s: %v", user.ID, err)
+		return
+	}
+	user.Password = hash
+}
+
+// signIn issues a token pair for a user whose first factor has been
+// verified, or an mfa_pending token if they also need a second factor
+func (s *UserService) signIn(ctx context.Context, user *models.User, client models.ClientInfo) (*LoginResult, error) {
+	mfa, err := s.mfaEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa {
+		token, _, err := s.tokens.GenerateMFAToken(user.ID, user.Email)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{User: user, MFAToken: token}, nil
+	}
+
+	tokens, err := s.startSession(ctx, s.store, user, client)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{User: user, Tokens: tokens}, nil
+}
+
+// GetUser returns a user by ID
+func (s *UserService) GetUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	user, err := s.store.Users().GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, "user")
+	}
+	return user, nil
+}
+
+// UpdateUser applies a partial profile update
+func (s *UserService) UpdateUser(ctx context.Context, id uuid.UUID, req models.UpdateUserRequest) (*models.User, error) {
+	if req.Timezone != nil {
+		if err := validateTimezone(*req.Timezone); err != nil {
+			return nil, err
+		}
+	}
+
+	var user *models.User
+	err := s.store.WithTx(ctx, func(tx database.Store) error {
+		var err error
+		user, err = tx.Users().GetByID(ctx, id)
+		if err != nil {
+			return notFound(err, "user")
+		}
+
+		if req.FirstName != nil {
+			user.FirstName = strings.TrimSpace(*req.FirstName)
+		}
+		if req.LastName != nil {
+			user.LastName = strings.TrimSpace(*req.LastName)
+		}
+		if req.Avatar != nil {
+			user.Avatar = req.Avatar
+		}
+		if req.Timezone != nil {
+			user.Timezone = *req.Timezone
+		}
+		user.UpdatedAt = s.now().UTC()
+
+		return tx.Users().Update(ctx, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetAvatar stores the URL of a user's uploaded avatar
+func (s *UserService) SetAvatar(ctx context.Context, id uuid.UUID, url string) (*models.User, error) {
+	return s.UpdateUser(ctx, id, models.UpdateUserRequest{Avatar: &url})
+}
+
+// ChangePassword replaces a user's password after checking the current one,
+// or sets the first password of an account created through a provider.
+// Recently used passwords are refused. Every token and API key issued
+// before the change is revoked and a fresh pair is returned so the caller
+// stays signed in.
+func (s *UserService) ChangePassword(ctx context.Context, id uuid.UUID, req models.ChangePasswordRequest, client models.ClientInfo) (*models.User, *auth.TokenPair, error) {
+	user, err := s.store.Users().GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, notFound(err, "user")
+	}
+
+	if user.HasPassword() {
+		if _, err := s.passwords.Verify(req.CurrentPassword, user.Password); err != nil {
+			return nil, nil, newError(ErrInvalidCredentials, "current password is incorrect")
+		}
+		if req.CurrentPassword == req.NewPassword {
+			return nil, nil, newError(ErrInvalidInput, "new password must be different from the current password")
+		}
+	}
+	if result := auth.ValidatePassword(req.NewPassword, user.Email, user.FirstName, user.LastName); !result.IsValid {
+		return nil, nil, newError(ErrInvalidInput, "%s", strings.Join(result.Errors, "; "))
+	}
+	if err := s.checkPasswordReuse(ctx, s.store, user, req.NewPassword); err != nil {
+		return nil, nil, err
+	}
+
+	hash, err := s.passwords.Hash(req.NewPassword)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := s.now().UTC()
+	var tokens *auth.TokenPair
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		if err := tx.Users().UpdatePassword(ctx, id, hash); err != nil {
+			return notFound(err, "user")
+		}
+		if err := s.recordPassword(ctx, tx, id, hash, now); err != nil {
+			return err
+		}
+		if err := s.revokeCredentials(ctx, tx, id, models.RevokeReasonPasswordChange, client, now); err != nil {
+			return err
+		}
+		if err := tx.Audit().Create(ctx, models.NewUserAuditLog(id, models.AuditPasswordChanged, "", client)); err != nil {
+			return err
+		}
+		tokens, err = s.startSession(ctx, tx, user, client)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.revocations.RevokeAllForUser(ctx, id, now); err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// DeleteUser removes a user and everything they own, and revokes their tokens
+func (s *UserService) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	if err := s.store.Users().Delete(ctx, id); err != nil {
+		return notFound(err, "user")
+	}
+	return s.revocations.RevokeAllForUser(ctx, id, s.now())
+}
+
+// GetStats summarizes a user's goals and group activity. The streaks are
+// the best among the user's habits, counted in their timezone; only habits
+// still being kept have a current streak.
+func (s *UserService) GetStats(ctx context.Context, id uuid.UUID) (*models.UserStats, error) {
+	goals, err := s.store.Goals().ListByUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := userCalendar(ctx, s.store, id, s.weekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	stats := &models.UserStats{TotalGoals: len(goals)}
+	for i, g := range goals {
+		active := false
+		switch g.Status {
+		case models.GoalStatusCompleted:
+			stats.CompletedGoals++
+		case models.GoalStatusActive, models.GoalStatusInProgress:
+			stats.ActiveGoals++
+			active = true
+		}
+		stats.TotalProgress += g.Progress()
+
+		if !g.IsHabit() {
+			continue
+		}
+		entries, err := s.store.Goals().ListProgress(ctx, g.ID, 0)
+		if err != nil {
+			return nil, err
+		}
+		streak := habitStreak(&goals[i], entries, cal, now)
+		stats.LongestStreak = max(stats.LongestStreak, streak.LongestStreak)
+		if active {
+			stats.CurrentStreak = max(stats.CurrentStreak, streak.CurrentStreak)
+		}
+	}
+	if stats.TotalGoals > 0 {
+		stats.CompletionRate = float64(stats.CompletedGoals) / float64(stats.TotalGoals) * 100
+	}
+
+	stats.GroupsJoined, err = s.store.Users().CountGroupsJoined(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// userCalendar returns the calendar of a user's timezone, or of UTC if theirs
+// is unknown, with weeks starting on weekStart
+func userCalendar(ctx context.Context, store database.Store, userID uuid.UUID, weekStart time.Weekday) (calendar.Calendar, error) {
+	user, err := store.Users().GetByID(ctx, userID)
+	if err != nil {
+		return calendar.Calendar{}, notFound(err, "user")
+	}
+	cal, err := calendar.Load(user.Timezone, weekStart)
+	if err != nil {
+		return calendar.New(time.UTC, weekStart), nil
+	}
+	return cal, nil
+}
+
+// validateTimezone checks that tz is a known IANA zone name
+func validateTimezone(tz string) error {
+	if tz == "" {
+		return newError(ErrInvalidInput, "timezone is required")
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return newError(ErrInvalidInput, "unknown timezone %q", tz)
+	}
+	return nil
+}
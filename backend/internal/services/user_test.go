@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/models"
+)
+
+func newTestTokenManager() *auth.TokenManager {
+	keys, err := auth.NewKeyRing(auth.NewMemorySigningKeyStore(), auth.KeyRingConfig{
+		Algorithm:        auth.AlgorithmEdDSA,
+		RotationInterval: 24 * time.Hour,
+		Overlap:          time.Hour,
+		TokenTTL:         time.Hour,
+	})
+	if err == nil {
+		err = keys.Sync(context.Background())
+	}
+	if err != nil {
+		panic(err)
+	}
+	return auth.NewTokenManager(keys, 15*time.Minute, time.Hour, "chainforge")
+}
+
+// testPasswords hashes with cheap argon2id parameters to keep the tests fast
+var testPasswords, _ = auth.NewPasswordHasher(auth.PasswordHashConfig{
+	Algorithm:         auth.HashArgon2id,
+	Argon2Memory:      64,
+	Argon2Iterations:  1,
+	Argon2Parallelism: 1,
+})
+
+func newTestUserService(store *memStore) *UserService {
+	svc, _ := newMailingUserService(store)
+	return svc
+}
+
+// seedUser inserts a user with a verified email and a subscription on the
+// given plan
+func seedUser(t *testing.T, store *memStore, plan models.SubscriptionPlan) *models.User {
+	t.Helper()
+	ctx := context.Background()
+
+	user := models.NewUser(uuid.NewString()+"@example.com", "hash", "Test", "User", "UTC")
+	user.EmailVerifiedAt = &user.CreatedAt
+	if err := store.Users().Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := store.Subscriptions().Create(ctx, models.NewSubscription(user.ID, plan)); err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	return user
+}
+
+// testClient is the device the tests sign in from
+var testClient = models.ClientInfo{
+	UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15",
+	IPAddress: "203.0.113.7",
+}
+
+func registerRequest(email string) models.CreateUserRequest {
+	return models.CreateUserRequest{
+		Email:     email,
+		Password:  "Correct-Horse-42",
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		Timezone:  "Europe/London",
+	}
+}
+
+func TestRegisterCreatesFreeSubscription(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+
+	user, tokens, err := svc.Register(context.Background(), registerRequest(" Ada@Example.com "), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if user.Email != "ada@example.com" {
+		t.Errorf("email = %q, want normalized address", user.Email)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Error("expected a token pair")
+	}
+
+	sub, err := store.Subscriptions().GetByUser(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("subscription not created: %v", err)
+	}
+	if sub.Plan != models.PlanFree {
+		t.Errorf("plan = %s, want free", sub.Plan)
+	}
+}
+
+func TestRegisterRollsBackWhenSubscriptionFails(t *testing.T) {
+	store := newMemStore()
+	store.failOn = "Subscriptions.Create"
+	svc := newTestUserService(store)
+
+	if _, _, err := svc.Register(context.Background(), registerRequest("ada@example.com"), testClient); err == nil {
+		t.Fatal("expected Register to fail")
+	}
+	if len(store.users) != 0 {
+		t.Errorf("user was kept after the transaction failed")
+	}
+}
+
+func TestRegisterRejectsDuplicateEmail(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+
+	if _, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	_, _, err := svc.Register(ctx, registerRequest("ADA@example.com"), testClient)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("err = %v, want ErrConflict", err)
+	}
+}
+
+func TestRegisterRejectsUnknownTimezone(t *testing.T) {
+	svc := newTestUserService(newMemStore())
+	req := registerRequest("ada@example.com")
+	req.Timezone = "Mars/Olympus_Mons"
+
+	if _, _, err := svc.Register(context.Background(), req, testClient); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("err = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestRegisterRejectsGuessablePasswords(t *testing.T) {
+	svc := newTestUserService(newMemStore())
+
+	for _, pw := range []string{"password1", "Ada@Example.com"} {
+		req := registerRequest("ada@example.com")
+		req.Password = pw
+		if _, _, err := svc.Register(context.Background(), req, testClient); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("password %q: err = %v, want ErrInvalidInput", pw, err)
+		}
+	}
+}
+
+func TestLoginChecksPassword(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+
+	if _, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	_, err := svc.Login(ctx, models.LoginRequest{Email: "ada@example.com", Password: "wrong-password"}, testClient)
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong password: err = %v, want ErrInvalidCredentials", err)
+	}
+	_, err = svc.Login(ctx, models.LoginRequest{Email: "nobody@example.com", Password: "Correct-Horse-42"}, testClient)
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("unknown email: err = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := svc.Login(ctx, models.LoginRequest{Email: "Ada@example.com", Password: "Correct-Horse-42"}, testClient); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+}
+
+func TestLoginRehashesLegacyPasswords(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+	login := models.LoginRequest{Email: "ada@example.com", Password: "Correct-Horse-42"}
+
+	user, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	legacy, _ := bcrypt.GenerateFromPassword([]byte(login.Password), bcrypt.MinCost)
+	if err := store.Users().UpdatePassword(ctx, user.ID, string(legacy)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Login(ctx, login, testClient); err != nil {
+		t.Fatalf("Login with a bcrypt hash: %v", err)
+	}
+	upgraded := store.users[user.ID].Password
+	if !strings.HasPrefix(upgraded, "$argon2id$") {
+		t.Fatalf("stored hash = %q, want it rehashed with argon2id", upgraded)
+	}
+
+	// A current hash is left alone
+	if _, err := svc.Login(ctx, login, testClient); err != nil {
+		t.Fatalf("Login with the new hash: %v", err)
+	}
+	if store.users[user.ID].Password != upgraded {
+		t.Error("a current hash was replaced")
+	}
+}
+
+func TestChangePasswordRevokesEarlierTokens(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+
+	user, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	_, tokens, err := svc.ChangePassword(ctx, user.ID, models.ChangePasswordRequest{
+		CurrentPassword: "Correct-Horse-42",
+		NewPassword:     "Battery-Staple-77",
+	}, testClient)
+	if err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+
+	fresh, err := svc.tokens.ValidateAccessToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("new access token: %v", err)
+	}
+	if revoked, _ := svc.revocations.IsRevoked(ctx, fresh); revoked {
+		t.Error("token issued by ChangePassword is revoked")
+	}
+
+	// Tokens from the same second as the change survive it, so backdate one
+	stale := *fresh
+	stale.ID = "stale"
+	stale.IssuedAt = jwt.NewNumericDate(fresh.IssuedAt.Add(-time.Second))
+	if revoked, _ := svc.revocations.IsRevoked(ctx, &stale); !revoked {
+		t.Error("token issued before the change is still valid")
+	}
+
+	if _, err := svc.Login(ctx, models.LoginRequest{Email: "ada@example.com", Password: "Battery-Staple-77"}, testClient); err != nil {
+		t.Fatalf("Login with new password: %v", err)
+	}
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/database"
+	"chainforge/internal/models"
+)
+
+// maxWebAuthnCredentials is how many passkeys one account can register
+const maxWebAuthnCredentials = 10
+
+// invalidPasskey is returned for every passkey sign-in that is rejected
+func invalidPasskey() error {
+	return newError(ErrInvalidCredentials, "passkey sign-in failed")
+}
+
+// BeginWebAuthnRegistration issues the options for registering a new
+// passkey on the signed-in user's device
+func (s *UserService) BeginWebAuthnRegistration(ctx context.Context, userID uuid.UUID) (*models.WebAuthnRegistrationOptions, error) {
+	user, err := s.store.Users().GetByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, "user")
+	}
+	creds, err := s.store.WebAuthn().ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(creds) >= maxWebAuthnCredentials {
+		return nil, newError(ErrConflict, "an account can have at most %d passkeys", maxWebAuthnCredentials)
+	}
+
+	challenge, err := s.newWebAuthnChallenge(ctx, &userID, models.WebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+
+	displayName := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if displayName == "" {
+		displayName = user.Email
+	}
+	params := make([]models.PublicKeyCredentialParameters, len(auth.SupportedCOSEAlgorithms))
+	for i, alg := range auth.SupportedCOSEAlgorithms {
+		params[i] = models.PublicKeyCredentialParameters{Type: "public-key", Alg: alg}
+	}
+
+	return &models.WebAuthnRegistrationOptions{
+		ChallengeID: challenge.ID,
+		PublicKey: models.PublicKeyCredentialCreationOptions{
+			RP: models.PublicKeyCredentialRPEntity{ID: s.webauthn.ID, Name: s.webauthn.Name},
+			User: models.PublicKeyCredentialUserEntity{
+				ID:          auth.EncodeBase64URL(userID[:]),
+				Name:        user.Email,
+				DisplayName: displayName,
+			},
+			Challenge:          auth.EncodeBase64URL(challenge.Challenge),
+			PubKeyCredParams:   params,
+			Timeout:            auth.WebAuthnTimeout.Milliseconds(),
+			ExcludeCredentials: credentialDescriptors(creds),
+			AuthenticatorSelection: models.AuthenticatorSelectionCriteria{
+				ResidentKey:      "required",
+				UserVerification: "required",
+			},
+			Attestation: "none",
+		},
+	}, nil
+}
+
+// FinishWebAuthnRegistration verifies the browser's response to
+// BeginWebAuthnRegistration and stores the new passkey
+func (s *UserService) FinishWebAuthnRegistration(ctx context.Context, userID uuid.UUID, req models.FinishWebAuthnRegistrationRequest, client models.ClientInfo) (*models.WebAuthnCredential, error) {
+	expired := newError(ErrInvalidInput, "passkey registration has expired, please try again")
+	challenge, err := s.store.WebAuthn().TakeChallenge(ctx, req.ChallengeID, models.WebAuthnRegistration, s.now().UTC())
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, expired
+		}
+		return nil, err
+	}
+	if challenge.UserID == nil || *challenge.UserID != userID {
+		return nil, expired
+	}
+
+	unverified := newError(ErrInvalidInput, "the passkey could not be verified")
+	rawID, err1 := auth.DecodeBase64URL(req.Credential.RawID)
+	clientData, err2 := auth.DecodeBase64URL(req.Credential.Response.ClientDataJSON)
+	attestation, err3 := auth.DecodeBase64URL(req.Credential.Response.AttestationObject)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return nil, unverified
+	}
+	verified, err := s.webauthn.VerifyRegistration(challenge.Challenge, clientData, attestation)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidWebAuthnResponse) {
+			return nil, unverified
+		}
+		return nil, err
+	}
+	if !bytes.Equal(verified.ID, rawID) {
+		return nil, unverified
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = sessionLabel(client.UserAgent)
+	}
+	cred := &models.WebAuthnCredential{
+		ID:           uuid.New(),
+		UserID:       userID,
+		CredentialID: verified.ID,
+		PublicKey:    verified.PublicKey,
+		SignCount:    verified.SignCount,
+		AAGUID:       verified.AAGUID,
+		Transports:   req.Credential.Response.Transports,
+		Name:         name,
+		CreatedAt:    s.now().UTC(),
+	}
+	if cred.Transports == nil {
+		cred.Transports = []string{}
+	}
+
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		if err := tx.WebAuthn().CreateCredential(ctx, cred); err != nil {
+			if errors.Is(err, database.ErrDuplicate) {
+				return newError(ErrConflict, "this passkey is already registered")
+			}
+			return err
+		}
+		details := fmt.Sprintf(`{"passkey_id":%q}`, cred.ID)
+		return tx.Audit().Create(ctx, models.NewUserAuditLog(userID, models.AuditPasskeyAdded, details, client))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// BeginWebAuthnLogin issues the options for signing in with a passkey.
+// With an email it lists that account's passkeys; otherwise, or if there is
+// no such account, the browser offers whichever passkeys it holds.
+func (s *UserService) BeginWebAuthnLogin(ctx context.Context, req models.BeginWebAuthnLoginRequest) (*models.WebAuthnLoginOptions, error) {
+	var userID *uuid.UUID
+	allow := []models.PublicKeyCredentialDescriptor{}
+	if req.Email != "" {
+		user, err := s.store.Users().GetByEmail(ctx, req.Email)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			creds, err := s.store.WebAuthn().ListCredentials(ctx, user.ID)
+			if err != nil {
+				return nil, err
+			}
+			userID, allow = &user.ID, credentialDescriptors(creds)
+		}
+	}
+
+	challenge, err := s.newWebAuthnChallenge(ctx, userID, models.WebAuthnLogin)
+	if err != nil {
+		return nil, err
+	}
+	return &models.WebAuthnLoginOptions{
+		ChallengeID: challenge.ID,
+		PublicKey: models.PublicKeyCredentialRequestOptions{
+			Challenge:        auth.EncodeBase64URL(challenge.Challenge),
+			Timeout:          auth.WebAuthnTimeout.Milliseconds(),
+			RPID:             s.webauthn.ID,
+			AllowCredentials: allow,
+			UserVerification: "required",
+		},
+	}, nil
+}
+
+// FinishWebAuthnLogin verifies the browser's response to BeginWebAuthnLogin
+// and signs the passkey's owner in. A passkey verifies the user on the
+// device, so no TOTP code is asked for.
+func (s *UserService) FinishWebAuthnLogin(ctx context.Context, req models.FinishWebAuthnLoginRequest, client models.ClientInfo) (*models.User, *auth.TokenPair, error) {
+	challenge, err := s.store.WebAuthn().TakeChallenge(ctx, req.ChallengeID, models.WebAuthnLogin, s.now().UTC())
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, nil, newError(ErrInvalidCredentials, "passkey sign-in has expired, please try again")
+		}
+		return nil, nil, err
+	}
+
+	resp := req.Credential.Response
+	rawID, err1 := auth.DecodeBase64URL(req.Credential.RawID)
+	clientData, err2 := auth.DecodeBase64URL(resp.ClientDataJSON)
+	authData, err3 := auth.DecodeBase64URL(resp.AuthenticatorData)
+	signature, err4 := auth.DecodeBase64URL(resp.Signature)
+	userHandle, err5 := auth.DecodeBase64URL(resp.UserHandle)
+	if errors.Join(err1, err2, err3, err4, err5) != nil {
+		return nil, nil, invalidPasskey()
+	}
+
+	cred, err := s.store.WebAuthn().GetCredential(ctx, rawID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, nil, invalidPasskey()
+		}
+		return nil, nil, err
+	}
+	if challenge.UserID != nil && *challenge.UserID != cred.UserID {
+		return nil, nil, invalidPasskey()
+	}
+	if len(userHandle) > 0 && !bytes.Equal(userHandle, cred.UserID[:]) {
+		return nil, nil, invalidPasskey()
+	}
+
+	signCount, err := s.webauthn.VerifyAssertion(challenge.Challenge, &auth.WebAuthnCredential{
+		ID:        cred.CredentialID,
+		PublicKey: cred.PublicKey,
+		SignCount: cred.SignCount,
+	}, clientData, authData, signature)
+	switch {
+	case errors.Is(err, auth.ErrWebAuthnCounterRegressed):
+		if err := s.recordPasskeyCounterRegression(ctx, cred); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, invalidPasskey()
+	case errors.Is(err, auth.ErrInvalidWebAuthnResponse):
+		return nil, nil, invalidPasskey()
+	case err != nil:
+		return nil, nil, err
+	}
+
+	user, err := s.store.Users().GetByID(ctx, cred.UserID)
+	if err != nil {
+		return nil, nil, notFound(err, "user")
+	}
+	if !user.IsActive {
+		return nil, nil, newError(ErrForbidden, "this account has been deactivated")
+	}
+
+	var tokens *auth.TokenPair
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		if err := tx.WebAuthn().UseCredential(ctx, cred.ID, signCount, s.now().UTC()); err != nil {
+			return err
+		}
+		tokens, err = s.startSession(ctx, tx, user, client)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// ListWebAuthnCredentials returns a user's passkeys
+func (s *UserService) ListWebAuthnCredentials(ctx context.Context, userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	return s.store.WebAuthn().ListCredentials(ctx, userID)
+}
+
+// RenameWebAuthnCredential changes the name of one of a user's passkeys
+func (s *UserService) RenameWebAuthnCredential(ctx context.Context, userID, id uuid.UUID, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return newError(ErrInvalidInput, "name is required")
+	}
+	if err := s.store.WebAuthn().RenameCredential(ctx, userID, id, name); err != nil {
+		return notFound(err, "passkey")
+	}
+	return nil
+}
+
+// DeleteWebAuthnCredential removes one of a user's passkeys
+func (s *UserService) DeleteWebAuthnCredential(ctx context.Context, userID, id uuid.UUID, client models.ClientInfo) error {
+	return s.store.WithTx(ctx, func(tx database.Store) error {
+		if err := tx.WebAuthn().DeleteCredential(ctx, userID, id); err != nil {
+			return notFound(err, "passkey")
+		}
+		details := fmt.Sprintf(`{"passkey_id":%q}`, id)
+		return tx.Audit().Create(ctx, models.NewUserAuditLog(userID, models.AuditPasskeyRemoved, details, client))
+	})
+}
+
+// newWebAuthnChallenge stores a fresh challenge for one ceremony
+func (s *UserService) newWebAuthnChallenge(ctx context.Context, userID *uuid.UUID, ceremony string) (*models.WebAuthnChallenge, error) {
+	value, err := auth.NewWebAuthnChallenge()
+	if err != nil {
+		return nil, err
+	}
+	challenge := models.NewWebAuthnChallenge(userID, ceremony, value, auth.WebAuthnTimeout)
+	if err := s.store.WebAuthn().CreateChallenge(ctx, challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// recordPasskeyCounterRegression logs a passkey whose counter went
+// backwards. The passkey is left in place: the legitimate authenticator
+// cannot be told apart from the clone.
+func (s *UserService) recordPasskeyCounterRegression(ctx context.Context, cred *models.WebAuthnCredential) error {
+	log.Printf("Security: signature counter of passkey %s of user %s went backwards", cred.ID, cred.UserID)
+	details := fmt.Sprintf("passkey %s presented a signature counter at or below %d", cred.ID, cred.SignCount)
+	return s.store.Tokens().CreateSecurityEvent(ctx, models.NewSecurityEvent(cred.UserID, models.SecurityEventPasskeyCounterRegressed, details))
+}
+
+// credentialDescriptors refers to passkeys in WebAuthn options
+func credentialDescriptors(creds []models.WebAuthnCredential) []models.PublicKeyCredentialDescriptor {
+	descriptors := make([]models.PublicKeyCredentialDescriptor, len(creds))
+	for i, c := range creds {
+		descriptors[i] = models.PublicKeyCredentialDescriptor{
+			Type:       "public-key",
+			ID:         auth.EncodeBase64URL(c.CredentialID),
+			Transports: c.Transports,
+		}
+	}
+	return descriptors
+}
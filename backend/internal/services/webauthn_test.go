@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"chainforge/internal/auth"
+	"chainforge/internal/models"
+)
+
+const testOrigin = "https://app.chainforge.test"
+
+var testRelyingParty = auth.NewRelyingParty("chainforge.test", "ChainForge", []string{testOrigin})
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+
+	user, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	passkey := registerPasskey(t, svc, user.ID)
+
+	creds, err := svc.ListWebAuthnCredentials(ctx, user.ID)
+	if err != nil || len(creds) != 1 {
+		t.Fatalf("ListWebAuthnCredentials = %d, %v; want 1", len(creds), err)
+	}
+	if creds[0].Name != "Safari on macOS" {
+		t.Errorf("passkey name = %q, want the device label", creds[0].Name)
+	}
+	if !hasAuditEntry(store, user.ID, models.AuditPasskeyAdded) {
+		t.Error("registering a passkey was not audited")
+	}
+
+	// Sign in without saying who we are, as a discoverable passkey does
+	options, err := svc.BeginWebAuthnLogin(ctx, models.BeginWebAuthnLoginRequest{})
+	if err != nil {
+		t.Fatalf("BeginWebAuthnLogin: %v", err)
+	}
+	req := passkey.assert(t, options)
+	signedIn, tokens, err := svc.FinishWebAuthnLogin(ctx, req, testClient)
+	if err != nil {
+		t.Fatalf("FinishWebAuthnLogin: %v", err)
+	}
+	if signedIn.ID != user.ID || tokens == nil || tokens.AccessToken == "" {
+		t.Fatalf("signed in as %s with %+v", signedIn.ID, tokens)
+	}
+	if used := store.passkeys[creds[0].ID]; used.SignCount != passkey.counter || used.LastUsedAt == nil {
+		t.Errorf("passkey after sign-in = %+v", used)
+	}
+
+	// Each challenge is good for one sign-in
+	if _, _, err := svc.FinishWebAuthnLogin(ctx, req, testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("replayed assertion: err = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestPasskeyLoginChecksOwnerAndCounter(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+
+	ada, _, err := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, _, err := svc.Register(ctx, registerRequest("grace@example.com"), testClient); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	passkey := registerPasskey(t, svc, ada.ID)
+
+	// A sign-in started for Grace cannot be finished with Ada's passkey
+	options, err := svc.BeginWebAuthnLogin(ctx, models.BeginWebAuthnLoginRequest{Email: "grace@example.com"})
+	if err != nil {
+		t.Fatalf("BeginWebAuthnLogin: %v", err)
+	}
+	if _, _, err := svc.FinishWebAuthnLogin(ctx, passkey.assert(t, options), testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("another user's passkey: err = %v, want ErrInvalidCredentials", err)
+	}
+
+	options, _ = svc.BeginWebAuthnLogin(ctx, models.BeginWebAuthnLoginRequest{Email: "ada@example.com"})
+	if len(options.PublicKey.AllowCredentials) != 1 {
+		t.Fatalf("allowed credentials = %+v, want Ada's passkey", options.PublicKey.AllowCredentials)
+	}
+	if _, _, err := svc.FinishWebAuthnLogin(ctx, passkey.assert(t, options), testClient); err != nil {
+		t.Fatalf("FinishWebAuthnLogin: %v", err)
+	}
+
+	// A cloned authenticator replays an old counter
+	passkey.counter--
+	options, _ = svc.BeginWebAuthnLogin(ctx, models.BeginWebAuthnLoginRequest{})
+	if _, _, err := svc.FinishWebAuthnLogin(ctx, passkey.assert(t, options), testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("regressed counter: err = %v, want ErrInvalidCredentials", err)
+	}
+	var events int
+	for _, e := range store.securityEvents {
+		if e.UserID == ada.ID && e.Type == models.SecurityEventPasskeyCounterRegressed {
+			events++
+		}
+	}
+	if events != 1 {
+		t.Errorf("counter regression events = %d, want 1", events)
+	}
+}
+
+func TestDeletePasskeyChecksOwner(t *testing.T) {
+	store := newMemStore()
+	svc := newTestUserService(store)
+	ctx := context.Background()
+
+	ada, _, _ := svc.Register(ctx, registerRequest("ada@example.com"), testClient)
+	grace, _, _ := svc.Register(ctx, registerRequest("grace@example.com"), testClient)
+	registerPasskey(t, svc, ada.ID)
+	creds, _ := svc.ListWebAuthnCredentials(ctx, ada.ID)
+
+	if err := svc.DeleteWebAuthnCredential(ctx, grace.ID, creds[0].ID, testClient); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleting another user's passkey: err = %v, want ErrNotFound", err)
+	}
+	if err := svc.DeleteWebAuthnCredential(ctx, ada.ID, creds[0].ID, testClient); err != nil {
+		t.Fatalf("DeleteWebAuthnCredential: %v", err)
+	}
+	if !hasAuditEntry(store, ada.ID, models.AuditPasskeyRemoved) {
+		t.Error("removing a passkey was not audited")
+	}
+}
+
+// testPasskey is a platform authenticator holding one ES256 passkey
+type testPasskey struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	counter      uint32
+}
+
+// registerPasskey creates a passkey for userID through the service
+func registerPasskey(t *testing.T, svc *UserService, userID uuid.UUID) *testPasskey {
+	t.Helper()
+	ctx := context.Background()
+	options, err := svc.BeginWebAuthnRegistration(ctx, userID)
+	if err != nil {
+		t.Fatalf("BeginWebAuthnRegistration: %v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testPasskey{key: key, credentialID: make([]byte, 16), userHandle: userID[:]}
+	rand.Read(p.credentialID)
+
+	x, y := make([]byte, 32), make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+	coseKey := cborMap(
+		cborInt(1), cborInt(2), cborInt(3), cborInt(auth.COSEAlgES256),
+		cborInt(-1), cborInt(1), cborInt(-2), cborBytes(x), cborInt(-3), cborBytes(y),
+	)
+	authData := p.authData(0x45) // user present, user verified, attested data
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(p.credentialID)))
+	authData = append(append(authData, p.credentialID...), coseKey...)
+	attestation := cborMap(
+		cborText("fmt"), cborText("none"),
+		cborText("attStmt"), cborMap(),
+		cborText("authData"), cborBytes(authData),
+	)
+
+	var req models.FinishWebAuthnRegistrationRequest
+	req.ChallengeID = options.ChallengeID
+	req.Credential.ID = auth.EncodeBase64URL(p.credentialID)
+	req.Credential.RawID = req.Credential.ID
+	req.Credential.Type = "public-key"
+	req.Credential.Response.ClientDataJSON = testWebAuthnClientData(t, "webauthn.create", options.PublicKey.Challenge)
+	req.Credential.Response.AttestationObject = auth.EncodeBase64URL(attestation)
+	req.Credential.Response.Transports = []string{"internal", "hybrid"}
+	if _, err := svc.FinishWebAuthnRegistration(ctx, userID, req, testClient); err != nil {
+		t.Fatalf("FinishWebAuthnRegistration: %v", err)
+	}
+	return p
+}
+
+// assert answers navigator.credentials.get for options
+func (p *testPasskey) assert(t *testing.T, options *models.WebAuthnLoginOptions) models.FinishWebAuthnLoginRequest {
+	t.Helper()
+	p.counter++
+	clientData := testWebAuthnClientData(t, "webauthn.get", options.PublicKey.Challenge)
+	rawClientData, _ := auth.DecodeBase64URL(clientData)
+	authData := p.authData(0x05)
+
+	clientDataHash := sha256.Sum256(rawClientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, p.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req models.FinishWebAuthnLoginRequest
+	req.ChallengeID = options.ChallengeID
+	req.Credential.ID = auth.EncodeBase64URL(p.credentialID)
+	req.Credential.RawID = req.Credential.ID
+	req.Credential.Type = "public-key"
+	req.Credential.Response.ClientDataJSON = clientData
+	req.Credential.Response.AuthenticatorData = auth.EncodeBase64URL(authData)
+	req.Credential.Response.Signature = auth.EncodeBase64URL(sig)
+	req.Credential.Response.UserHandle = auth.EncodeBase64URL(p.userHandle)
+	return req
+}
+
+func (p *testPasskey) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRelyingParty.ID))
+	return binary.BigEndian.AppendUint32(append(rpIDHash[:], flags), p.counter)
+}
+
+func testWebAuthnClientData(t *testing.T, ceremony, challenge string) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{"type": ceremony, "challenge": challenge, "origin": testOrigin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth.EncodeBase64URL(data)
+}
+
+func hasAuditEntry(store *memStore, userID uuid.UUID, action models.AuditAction) bool {
+	for _, e := range store.auditLogs {
+		if e.UserID != nil && *e.UserID == userID && e.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Minimal CBOR encoding for building authenticator responses
+
+func cborHead(major byte, n int) []byte {
+	if n < 24 {
+		return []byte{major<<5 | byte(n)}
+	}
+	if n < 256 {
+		return []byte{major<<5 | 24, byte(n)}
+	}
+	return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+}
+
+func cborInt(n int) []byte {
+	if n < 0 {
+		return cborHead(1, -1-n)
+	}
+	return cborHead(0, n)
+}
+
+func cborBytes(b []byte) []byte { return append(cborHead(2, len(b)), b...) }
+func cborText(s string) []byte  { return append(cborHead(3, len(s)), s...) }
+
+// cborMap encodes alternating encoded keys and values
+func cborMap(items ...[]byte) []byte {
+	out := cborHead(5, len(items)/2)
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
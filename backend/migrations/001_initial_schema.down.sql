@@ -0,0 +1,27 @@
+-- Revert ChainForge Initial Database Schema
+
+DROP TRIGGER IF EXISTS update_goal_progress_amount_delete;
+DROP TRIGGER IF EXISTS update_goal_progress_amount_update;
+DROP TRIGGER IF EXISTS update_goal_progress_amount;
+DROP TRIGGER IF EXISTS update_payment_methods_timestamp;
+DROP TRIGGER IF EXISTS update_group_goal_progress_timestamp;
+DROP TRIGGER IF EXISTS update_group_goals_timestamp;
+DROP TRIGGER IF EXISTS update_group_members_timestamp;
+DROP TRIGGER IF EXISTS update_groups_timestamp;
+DROP TRIGGER IF EXISTS update_goals_timestamp;
+DROP TRIGGER IF EXISTS update_subscriptions_timestamp;
+DROP TRIGGER IF EXISTS update_users_timestamp;
+
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS subscription_usage;
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS payment_methods;
+DROP TABLE IF EXISTS group_goal_progress;
+DROP TABLE IF EXISTS group_goal_periods;
+DROP TABLE IF EXISTS group_goals;
+DROP TABLE IF EXISTS group_members;
+DROP TABLE IF EXISTS groups;
+DROP TABLE IF EXISTS goal_progress;
+DROP TABLE IF EXISTS goals;
+DROP TABLE IF EXISTS subscriptions;
+DROP TABLE IF EXISTS users;
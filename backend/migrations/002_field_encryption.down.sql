@@ -0,0 +1,6 @@
+-- Revert application-level field encryption
+-- Encrypted column values are left in place and cannot be read without field_keys
+
+DROP INDEX IF EXISTS idx_user_email_index_hash;
+DROP TABLE IF EXISTS user_email_index;
+DROP TABLE IF EXISTS field_keys;
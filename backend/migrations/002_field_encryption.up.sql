@@ -0,0 +1,17 @@
+-- Application-level field encryption
+-- Wrapped data keys and the blind index used to look users up by email
+
+CREATE TABLE field_keys (
+    version INTEGER PRIMARY KEY,
+    wrapped_key BLOB NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- users.email holds ciphertext, so uniqueness and login lookups go through
+-- a keyed hash of the normalized address
+CREATE TABLE user_email_index (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    email_hash TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_user_email_index_hash ON user_email_index(email_hash);
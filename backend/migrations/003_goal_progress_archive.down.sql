@@ -0,0 +1,4 @@
+-- Drop archived goal progress
+
+DROP INDEX IF EXISTS idx_goal_progress_archive_goal;
+DROP TABLE IF EXISTS goal_progress_archive;
@@ -0,0 +1,13 @@
+-- Progress entries set aside when a goal is restarted
+
+CREATE TABLE goal_progress_archive (
+    id TEXT PRIMARY KEY,
+    goal_id TEXT NOT NULL REFERENCES goals(id) ON DELETE CASCADE,
+    amount REAL NOT NULL CHECK (amount >= 0),
+    note TEXT,
+    date DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    archived_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_goal_progress_archive_goal ON goal_progress_archive(goal_id, archived_at);
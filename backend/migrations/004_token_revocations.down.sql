@@ -0,0 +1,6 @@
+-- Drop token revocations
+
+DROP INDEX IF EXISTS idx_user_token_revocations_expires;
+DROP TABLE IF EXISTS user_token_revocations;
+DROP INDEX IF EXISTS idx_revoked_tokens_expires;
+DROP TABLE IF EXISTS revoked_tokens;
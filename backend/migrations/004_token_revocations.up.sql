@@ -0,0 +1,18 @@
+-- Revoked tokens and per-user revocation cutoffs
+
+CREATE TABLE revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_revoked_tokens_expires ON revoked_tokens(expires_at);
+
+-- Tokens issued to user_id before revoked_before are rejected. Rows are kept
+-- until every token they could match has expired.
+CREATE TABLE user_token_revocations (
+    user_id TEXT PRIMARY KEY,
+    revoked_before DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_user_token_revocations_expires ON user_token_revocations(expires_at);
@@ -0,0 +1,9 @@
+-- Drop refresh token families and security events
+
+DROP INDEX IF EXISTS idx_security_events_user;
+DROP TABLE IF EXISTS security_events;
+DROP INDEX IF EXISTS idx_refresh_tokens_family;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP INDEX IF EXISTS idx_refresh_token_families_expires;
+DROP INDEX IF EXISTS idx_refresh_token_families_user;
+DROP TABLE IF EXISTS refresh_token_families;
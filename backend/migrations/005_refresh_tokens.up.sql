@@ -0,0 +1,35 @@
+-- Refresh token families for single-use rotation, and security events
+
+CREATE TABLE refresh_token_families (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME,
+    revoke_reason TEXT
+);
+
+CREATE INDEX idx_refresh_token_families_user ON refresh_token_families(user_id);
+CREATE INDEX idx_refresh_token_families_expires ON refresh_token_families(expires_at);
+
+CREATE TABLE refresh_tokens (
+    jti TEXT PRIMARY KEY,
+    family_id TEXT NOT NULL REFERENCES refresh_token_families(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issued_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    replaced_by TEXT
+);
+
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family_id);
+
+CREATE TABLE security_events (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_security_events_user ON security_events(user_id, created_at);
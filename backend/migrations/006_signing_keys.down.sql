@@ -0,0 +1,4 @@
+-- Drop JWT signing keys
+
+DROP INDEX IF EXISTS idx_signing_keys_expires;
+DROP TABLE IF EXISTS signing_keys;
@@ -0,0 +1,14 @@
+-- Asymmetric JWT signing keys. private_key holds the PKCS #8 key, base64
+-- encoded and encrypted like other sensitive columns.
+
+CREATE TABLE signing_keys (
+    id TEXT PRIMARY KEY,
+    algorithm TEXT NOT NULL CHECK (algorithm IN ('RS256', 'EdDSA')),
+    private_key TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    activates_at DATETIME NOT NULL,
+    retires_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_signing_keys_expires ON signing_keys(expires_at);
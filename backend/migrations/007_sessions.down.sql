@@ -0,0 +1,4 @@
+-- Drop sessions
+
+DROP INDEX IF EXISTS idx_sessions_user;
+DROP TABLE IF EXISTS sessions;
@@ -0,0 +1,18 @@
+-- Device details for each sign-in, keyed by its refresh token family.
+-- ip_address is encrypted like other PII columns.
+
+CREATE TABLE sessions (
+    id TEXT PRIMARY KEY REFERENCES refresh_token_families(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    label TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_address TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    last_used_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_sessions_user ON sessions(user_id, last_used_at);
+
+-- Sign-ins from before this migration have no device details
+INSERT INTO sessions (id, user_id, label, created_at, last_used_at)
+SELECT id, user_id, 'Unknown device', created_at, created_at FROM refresh_token_families;
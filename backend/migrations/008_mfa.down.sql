@@ -0,0 +1,30 @@
+-- Drop two-factor authentication and restore the original audit_logs
+
+CREATE TABLE audit_logs_old (
+    id TEXT PRIMARY KEY,
+    user_id TEXT REFERENCES users(id),
+    entity_type TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    old_values TEXT,
+    new_values TEXT,
+    ip_address TEXT,
+    user_agent TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO audit_logs_old
+SELECT id, user_id, entity_type, entity_id, action, old_values, new_values, ip_address, user_agent, created_at
+FROM audit_logs;
+
+DROP TABLE audit_logs;
+ALTER TABLE audit_logs_old RENAME TO audit_logs;
+
+CREATE INDEX idx_audit_logs_user ON audit_logs(user_id);
+CREATE INDEX idx_audit_logs_entity ON audit_logs(entity_type, entity_id);
+CREATE INDEX idx_audit_logs_action ON audit_logs(action);
+CREATE INDEX idx_audit_logs_created ON audit_logs(created_at);
+
+DROP INDEX IF EXISTS idx_recovery_codes_user;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS totp_credentials;
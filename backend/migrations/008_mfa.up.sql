@@ -0,0 +1,49 @@
+-- TOTP two-factor authentication and recovery codes. totp_credentials.secret
+-- is encrypted like other sensitive columns.
+
+CREATE TABLE totp_credentials (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    confirmed_at DATETIME,
+    last_used_step INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL
+);
+
+CREATE TABLE recovery_codes (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_recovery_codes_user ON recovery_codes(user_id, code_hash);
+
+-- audit_logs.user_id blocked deleting any user with entries. Rebuild it so
+-- entries outlive the account; entity_id still names the user.
+
+CREATE TABLE audit_logs_new (
+    id TEXT PRIMARY KEY,
+    user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+    entity_type TEXT NOT NULL, -- 'user', 'goal', 'group', etc.
+    entity_id TEXT NOT NULL,
+    action TEXT NOT NULL, -- 'create', 'update', 'delete', 'mfa.enabled', etc.
+    old_values TEXT, -- JSON
+    new_values TEXT, -- JSON
+    ip_address TEXT,
+    user_agent TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO audit_logs_new
+SELECT id, user_id, entity_type, entity_id, action, old_values, new_values, ip_address, user_agent, created_at
+FROM audit_logs;
+
+DROP TABLE audit_logs;
+ALTER TABLE audit_logs_new RENAME TO audit_logs;
+
+CREATE INDEX idx_audit_logs_user ON audit_logs(user_id);
+CREATE INDEX idx_audit_logs_entity ON audit_logs(entity_type, entity_id);
+CREATE INDEX idx_audit_logs_action ON audit_logs(action);
+CREATE INDEX idx_audit_logs_created ON audit_logs(created_at);
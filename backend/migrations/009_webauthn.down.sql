@@ -0,0 +1,6 @@
+-- Drop passkeys
+
+DROP INDEX IF EXISTS idx_webauthn_challenges_expires;
+DROP TABLE IF EXISTS webauthn_challenges;
+DROP INDEX IF EXISTS idx_webauthn_credentials_user;
+DROP TABLE IF EXISTS webauthn_credentials;
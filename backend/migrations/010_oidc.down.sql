@@ -0,0 +1,5 @@
+-- Drop OpenID Connect identities
+
+DROP INDEX IF EXISTS idx_oidc_states_expires;
+DROP TABLE IF EXISTS oidc_states;
+DROP TABLE IF EXISTS user_identities;
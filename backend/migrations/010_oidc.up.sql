@@ -0,0 +1,29 @@
+-- Accounts at OpenID Connect providers linked to users, and the state of
+-- sign-ins waiting for the provider to redirect back. A user has at most
+-- one identity per provider. state_hash is the SHA-256 of the state
+-- parameter; user_id is set when the sign-in links an identity to an
+-- account that is already signed in.
+
+CREATE TABLE user_identities (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    email TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    last_used_at DATETIME,
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider)
+);
+
+CREATE TABLE oidc_states (
+    state_hash TEXT PRIMARY KEY,
+    provider TEXT NOT NULL,
+    user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_oidc_states_expires ON oidc_states(expires_at);
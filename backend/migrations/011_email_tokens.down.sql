@@ -0,0 +1,6 @@
+-- Drop email verification and password reset tokens
+
+DROP TABLE IF EXISTS email_verifications;
+DROP INDEX IF EXISTS idx_email_tokens_expires;
+DROP INDEX IF EXISTS idx_email_tokens_user;
+DROP TABLE IF EXISTS email_tokens;
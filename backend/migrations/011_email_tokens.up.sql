@@ -0,0 +1,29 @@
+-- Single-use tokens mailed to users to verify their email address or reset
+-- their password, and when each user verified their address. token_hash is
+-- the SHA-256 of the token.
+--
+-- Verification lives in its own table rather than a users column because
+-- removing the column again would mean rebuilding users, which cascades to
+-- everything that references it.
+
+CREATE TABLE email_tokens (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose TEXT NOT NULL, -- 'verify_email' or 'reset_password'
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_email_tokens_user ON email_tokens(user_id, purpose);
+CREATE INDEX idx_email_tokens_expires ON email_tokens(expires_at);
+
+CREATE TABLE email_verifications (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    verified_at DATETIME NOT NULL
+);
+
+-- Accounts created before verification existed keep working
+INSERT INTO email_verifications (user_id, verified_at)
+SELECT id, created_at FROM users;
@@ -0,0 +1,5 @@
+-- Drop the email outbox
+
+DROP INDEX IF EXISTS idx_email_outbox_user;
+DROP INDEX IF EXISTS idx_email_outbox_due;
+DROP TABLE IF EXISTS email_outbox;
@@ -0,0 +1,23 @@
+-- Rendered emails waiting to be delivered. Emails are queued in the same
+-- transaction as the change they announce and deleted once sent, so a send
+-- survives a restart between the two. next_attempt_at doubles as a lease:
+-- a worker claiming an email pushes it forward so no other worker picks it
+-- up while it is being sent. Emails that keep failing are kept as 'failed'.
+
+CREATE TABLE email_outbox (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    template TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending', -- 'pending' or 'failed'
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    last_error TEXT,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_email_outbox_due ON email_outbox(status, next_attempt_at);
+CREATE INDEX idx_email_outbox_user ON email_outbox(user_id);
@@ -0,0 +1,4 @@
+-- Drop failed sign-in counters
+
+DROP INDEX IF EXISTS idx_login_throttles_last_failure;
+DROP TABLE IF EXISTS login_throttles;
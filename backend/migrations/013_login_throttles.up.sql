@@ -0,0 +1,16 @@
+-- Failed sign-in counters per email address and per client IP. subject is
+-- the blind index of the address or IP once field encryption is enabled.
+-- Counters restart after a quiet window; locked_until is set when too many
+-- failures lock the email until the user follows the unlock link or the
+-- lock runs out.
+
+CREATE TABLE login_throttles (
+    scope TEXT NOT NULL, -- 'email' or 'ip'
+    subject TEXT NOT NULL,
+    failures INTEGER NOT NULL,
+    last_failure_at DATETIME NOT NULL,
+    locked_until DATETIME,
+    PRIMARY KEY (scope, subject)
+);
+
+CREATE INDEX idx_login_throttles_last_failure ON login_throttles(last_failure_at);
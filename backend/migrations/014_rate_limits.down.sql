@@ -0,0 +1,4 @@
+-- Drop rate limiter counters
+
+DROP INDEX IF EXISTS idx_rate_limits_reset_at;
+DROP TABLE IF EXISTS rate_limits;
@@ -0,0 +1,13 @@
+-- Request counters of the rate limiter when RATE_LIMIT_STORE is sqlite, so
+-- limits survive restarts and are shared by every server process. key names
+-- the policy and the client (a user ID or an IP) and is stored as a blind
+-- index once field encryption is enabled. A counter starts over once
+-- reset_at has passed.
+
+CREATE TABLE rate_limits (
+    key TEXT PRIMARY KEY,
+    hits INTEGER NOT NULL,
+    reset_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_rate_limits_reset_at ON rate_limits(reset_at);
@@ -0,0 +1,4 @@
+-- Drop personal API keys
+
+DROP INDEX IF EXISTS idx_api_keys_user;
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,18 @@
+-- Personal API keys. key_hash is the SHA-256 of the key, which is shown to
+-- the user only once; prefix is its first characters so users can tell
+-- their keys apart. scopes is a comma-separated list such as
+-- 'goals:read,progress:write'.
+
+CREATE TABLE api_keys (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    last_used_at DATETIME,
+    expires_at DATETIME,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_api_keys_user ON api_keys(user_id);
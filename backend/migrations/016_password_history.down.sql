@@ -0,0 +1,4 @@
+-- Drop password history
+
+DROP INDEX IF EXISTS idx_password_history_user;
+DROP TABLE IF EXISTS password_history;
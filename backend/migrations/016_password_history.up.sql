@@ -0,0 +1,16 @@
+-- The password hashes each user has had, newest last, so old passwords
+-- cannot be reused. The newest entry also records when the current
+-- password was set.
+
+CREATE TABLE password_history (
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_password_history_user ON password_history(user_id, created_at);
+
+-- When existing passwords were set is unknown; the last update of the
+-- account is the closest guess
+INSERT INTO password_history (user_id, password_hash, created_at)
+SELECT id, password_hash, updated_at FROM users WHERE password_hash <> '';
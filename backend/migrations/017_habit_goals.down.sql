@@ -0,0 +1,3 @@
+-- Drop habit schedules; habits are left behind as one-off targets
+
+DROP TABLE IF EXISTS goal_recurrences;
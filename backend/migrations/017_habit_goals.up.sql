@@ -0,0 +1,9 @@
+-- Habit goals recur. A goal with a row here is a habit: recurrence holds its
+-- schedule as JSON, such as '{"frequency":"weekly","times_per_week":3}', and
+-- its target_amount is due on each occurrence. Other goals are one-off
+-- targets.
+
+CREATE TABLE goal_recurrences (
+    goal_id TEXT PRIMARY KEY REFERENCES goals(id) ON DELETE CASCADE,
+    recurrence TEXT NOT NULL
+);
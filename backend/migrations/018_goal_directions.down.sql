@@ -0,0 +1,95 @@
+-- Drop goal directions and sum every goal's entries again; measured goals
+-- are left behind as increasing ones. target_amount must be above 0 again,
+-- so this fails while any goal aims for zero; change or delete those goals
+-- first.
+
+DROP TRIGGER IF EXISTS update_goal_progress_amount;
+DROP TRIGGER IF EXISTS update_goal_progress_amount_update;
+DROP TRIGGER IF EXISTS update_goal_progress_amount_delete;
+
+CREATE TRIGGER update_goal_progress_amount
+    AFTER INSERT ON goal_progress
+    FOR EACH ROW
+BEGIN
+    UPDATE goals
+    SET current_amount = (
+        SELECT COALESCE(SUM(amount), 0)
+        FROM goal_progress
+        WHERE goal_id = NEW.goal_id
+    )
+    WHERE id = NEW.goal_id;
+END;
+
+CREATE TRIGGER update_goal_progress_amount_update
+    AFTER UPDATE ON goal_progress
+    FOR EACH ROW
+BEGIN
+    UPDATE goals
+    SET current_amount = (
+        SELECT COALESCE(SUM(amount), 0)
+        FROM goal_progress
+        WHERE goal_id = NEW.goal_id
+    )
+    WHERE id = NEW.goal_id;
+END;
+
+CREATE TRIGGER update_goal_progress_amount_delete
+    AFTER DELETE ON goal_progress
+    FOR EACH ROW
+BEGIN
+    UPDATE goals
+    SET current_amount = (
+        SELECT COALESCE(SUM(amount), 0)
+        FROM goal_progress
+        WHERE goal_id = OLD.goal_id
+    )
+    WHERE id = OLD.goal_id;
+END;
+
+UPDATE goals
+SET current_amount = (SELECT COALESCE(SUM(amount), 0) FROM goal_progress WHERE goal_id = goals.id)
+WHERE id IN (SELECT goal_id FROM goal_directions);
+
+DROP TABLE IF EXISTS goal_directions;
+
+CREATE TABLE goals_new (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT,
+    target_amount REAL NOT NULL CHECK (target_amount > 0),
+    current_amount REAL NOT NULL DEFAULT 0 CHECK (current_amount >= 0),
+    unit TEXT NOT NULL,
+    category TEXT NOT NULL CHECK (category IN ('fitness', 'health', 'education', 'career', 'finance', 'hobbies', 'relationship', 'personal', 'other')),
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'in_progress', 'completed', 'canceled')),
+    start_date DATETIME NOT NULL,
+    end_date DATETIME,
+    punishment TEXT,
+    is_public BOOLEAN NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO goals_new (id, user_id, name, description, target_amount, current_amount, unit, category, status, start_date, end_date, punishment, is_public, created_at, updated_at)
+SELECT id, user_id, name, description, target_amount, current_amount, unit, category, status, start_date, end_date, punishment, is_public, created_at, updated_at
+FROM goals;
+
+DROP TABLE goals;
+
+-- Triggers on goal_progress update goals; the legacy rename leaves them
+-- alone rather than failing while goals is missing
+PRAGMA legacy_alter_table = ON;
+ALTER TABLE goals_new RENAME TO goals;
+PRAGMA legacy_alter_table = OFF;
+
+CREATE INDEX idx_goals_user ON goals(user_id);
+CREATE INDEX idx_goals_status ON goals(status);
+CREATE INDEX idx_goals_category ON goals(category);
+CREATE INDEX idx_goals_public ON goals(is_public);
+
+CREATE TRIGGER update_goals_timestamp
+    AFTER UPDATE ON goals
+    FOR EACH ROW
+BEGIN
+    UPDATE goals SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
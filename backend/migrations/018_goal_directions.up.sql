@@ -0,0 +1,112 @@
+-- Decreasing and stay_under goals may aim for zero, such as no cigarettes a
+-- day, so target_amount may be 0. Amounts and measurements stay
+-- non-negative. SQLite cannot change a CHECK constraint in place, so goals
+-- is rebuilt; migrations run with foreign keys off, which keeps the rows
+-- that reference it.
+
+CREATE TABLE goals_new (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT,
+    target_amount REAL NOT NULL CHECK (target_amount >= 0),
+    current_amount REAL NOT NULL DEFAULT 0 CHECK (current_amount >= 0),
+    unit TEXT NOT NULL,
+    category TEXT NOT NULL CHECK (category IN ('fitness', 'health', 'education', 'career', 'finance', 'hobbies', 'relationship', 'personal', 'other')),
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'in_progress', 'completed', 'canceled')),
+    start_date DATETIME NOT NULL,
+    end_date DATETIME,
+    punishment TEXT,
+    is_public BOOLEAN NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO goals_new (id, user_id, name, description, target_amount, current_amount, unit, category, status, start_date, end_date, punishment, is_public, created_at, updated_at)
+SELECT id, user_id, name, description, target_amount, current_amount, unit, category, status, start_date, end_date, punishment, is_public, created_at, updated_at
+FROM goals;
+
+DROP TABLE goals;
+
+-- Triggers on goal_progress update goals; the legacy rename leaves them
+-- alone rather than failing while goals is missing
+PRAGMA legacy_alter_table = ON;
+ALTER TABLE goals_new RENAME TO goals;
+PRAGMA legacy_alter_table = OFF;
+
+CREATE INDEX idx_goals_user ON goals(user_id);
+CREATE INDEX idx_goals_status ON goals(status);
+CREATE INDEX idx_goals_category ON goals(category);
+CREATE INDEX idx_goals_public ON goals(is_public);
+
+CREATE TRIGGER update_goals_timestamp
+    AFTER UPDATE ON goals
+    FOR EACH ROW
+BEGIN
+    UPDATE goals SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+-- Goals that decrease or keep within limits log measurements rather than
+-- amounts to add up. A goal with a row here is one of them; other goals
+-- increase. start_amount is where a goal stands before its first
+-- measurement and min_amount is the lower limit of stay_within goals, whose
+-- upper limit is target_amount.
+
+CREATE TABLE goal_directions (
+    goal_id TEXT PRIMARY KEY REFERENCES goals(id) ON DELETE CASCADE,
+    direction TEXT NOT NULL CHECK (direction IN ('decrease', 'stay_under', 'stay_within')),
+    start_amount REAL CHECK (start_amount >= 0),
+    min_amount REAL CHECK (min_amount >= 0)
+);
+
+-- current_amount of a measured goal is its latest measurement, or its
+-- start_amount until it has one; other goals keep the sum of their entries
+
+DROP TRIGGER update_goal_progress_amount;
+DROP TRIGGER update_goal_progress_amount_update;
+DROP TRIGGER update_goal_progress_amount_delete;
+
+CREATE TRIGGER update_goal_progress_amount
+    AFTER INSERT ON goal_progress
+    FOR EACH ROW
+BEGIN
+    UPDATE goals
+    SET current_amount = CASE
+        WHEN EXISTS (SELECT 1 FROM goal_directions WHERE goal_id = NEW.goal_id) THEN COALESCE(
+            (SELECT amount FROM goal_progress WHERE goal_id = NEW.goal_id ORDER BY date DESC, created_at DESC LIMIT 1),
+            (SELECT start_amount FROM goal_directions WHERE goal_id = NEW.goal_id),
+            0)
+        ELSE (SELECT COALESCE(SUM(amount), 0) FROM goal_progress WHERE goal_id = NEW.goal_id)
+    END
+    WHERE id = NEW.goal_id;
+END;
+
+CREATE TRIGGER update_goal_progress_amount_update
+    AFTER UPDATE ON goal_progress
+    FOR EACH ROW
+BEGIN
+    UPDATE goals
+    SET current_amount = CASE
+        WHEN EXISTS (SELECT 1 FROM goal_directions WHERE goal_id = NEW.goal_id) THEN COALESCE(
+            (SELECT amount FROM goal_progress WHERE goal_id = NEW.goal_id ORDER BY date DESC, created_at DESC LIMIT 1),
+            (SELECT start_amount FROM goal_directions WHERE goal_id = NEW.goal_id),
+            0)
+        ELSE (SELECT COALESCE(SUM(amount), 0) FROM goal_progress WHERE goal_id = NEW.goal_id)
+    END
+    WHERE id = NEW.goal_id;
+END;
+
+CREATE TRIGGER update_goal_progress_amount_delete
+    AFTER DELETE ON goal_progress
+    FOR EACH ROW
+BEGIN
+    UPDATE goals
+    SET current_amount = CASE
+        WHEN EXISTS (SELECT 1 FROM goal_directions WHERE goal_id = OLD.goal_id) THEN COALESCE(
+            (SELECT amount FROM goal_progress WHERE goal_id = OLD.goal_id ORDER BY date DESC, created_at DESC LIMIT 1),
+            (SELECT start_amount FROM goal_directions WHERE goal_id = OLD.goal_id),
+            0)
+        ELSE (SELECT COALESCE(SUM(amount), 0) FROM goal_progress WHERE goal_id = OLD.goal_id)
+    END
+    WHERE id = OLD.goal_id;
+END;
@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migration files so the server binary
+// can apply them without depending on the working directory.
+package migrations
+
+import "embed"
+
+// FS contains every *.sql file in this directory
+//
+//go:embed *.sql
+var FS embed.FS